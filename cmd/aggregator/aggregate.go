@@ -0,0 +1,464 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+	"github.com/lvonguyen/finops-platform/internal/anomaly"
+	"github.com/lvonguyen/finops-platform/internal/chargeback"
+	"github.com/lvonguyen/finops-platform/internal/compare"
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+	"github.com/lvonguyen/finops-platform/internal/notify"
+	"github.com/lvonguyen/finops-platform/internal/publish"
+	"github.com/lvonguyen/finops-platform/internal/reporter"
+	"github.com/lvonguyen/finops-platform/internal/store"
+	"github.com/lvonguyen/finops-platform/internal/summary"
+	"github.com/lvonguyen/finops-platform/internal/unitcost"
+)
+
+// runAggregate aggregates costs for the period, checks anomalies and budgets,
+// and writes a report
+func runAggregate(ctx context.Context, cfg *config.Config, agg *aggregator.Aggregator, opts options) {
+	start, end := opts.start, opts.end
+	if opts.stdout && opts.outputFormat != "markdown" {
+		log.Fatalf("--stdout needs --format markdown")
+	}
+	if opts.stream {
+		runAggregateStream(ctx, cfg, agg, opts)
+		return
+	}
+
+	// Aggregate costs
+	log.Printf("Aggregating costs from %s to %s", start.Format("2006-01-02"), end.Format("2006-01-02"))
+
+	results, err := agg.Aggregate(ctx, start, end)
+	if err != nil {
+		log.Fatalf("Failed to aggregate costs: %v", err)
+	}
+
+	log.Printf("Retrieved %d cost entries across %d providers", len(results.Entries), len(results.ByProvider))
+	for name, folded := range results.Overflow {
+		log.Printf("Warning: dimension %q exceeded its value limit; %d records grouped as %s", name, folded, aggregator.DimensionOther)
+	}
+	for _, c := range results.TagCaps {
+		log.Printf("Warning: tag %q %s: %d values on $%.2f of costs", c.Key, c.Reason, c.Values, c.Cost)
+	}
+	logProviderErrors(results.Errors)
+	checkFreshness(results, opts)
+	saveHistory(ctx, cfg, opts.history, results)
+
+	// Detect anomalies
+	anomalies, alerting := trackAlerts(ctx, opts.history, agg.DetectAnomalies(results))
+	if len(anomalies) > 0 {
+		log.Printf("Detected %d cost anomalies", len(anomalies))
+	}
+
+	// Check budgets
+	budgetAlerts := agg.CheckBudgets(results)
+	if len(budgetAlerts) > 0 {
+		log.Printf("Detected %d budget alerts", len(budgetAlerts))
+	}
+
+	// Compare with the preceding period for the headline
+	var headline string
+	var comparison *compare.Comparison
+	if opts.comparePrior {
+		comparison = priorPeriodComparison(ctx, agg, start, end, results)
+		if comparison != nil {
+			headline = comparison.Headline()
+		}
+	}
+
+	period := fmt.Sprintf("%s to %s", start.Format("2006-01-02"), end.Format("2006-01-02"))
+	narrative := summary.Generate(summary.Input{
+		Period:       period,
+		Results:      results,
+		Comparison:   comparison,
+		Anomalies:    anomalies,
+		BudgetAlerts: budgetAlerts,
+	})
+
+	// Generate report
+	rep := reporter.New(cfg.Reporter)
+
+	reportData := reporter.ReportData{
+		Period:       period,
+		Headline:     headline,
+		Summary:      narrative,
+		Results:      results,
+		Anomalies:    anomalies,
+		BudgetAlerts: budgetAlerts,
+		Cooldowns:    activeCooldowns(cfg),
+		Ramping:      anomaly.RampingAccounts(results.Records(), time.Now(), rampGrace(cfg)),
+		Releases:     releaseImpacts(cfg, opts, results),
+		UnitCosts:    unitCosts(ctx, cfg, results.Records(), start, end),
+		GeneratedAt:  time.Now(),
+	}
+
+	// The HTML report charts cost-center growth when history is kept
+	if opts.outputFormat == "html" && opts.history != nil {
+		lastMonth, _ := parseMonth("", opts.fiscal)
+		trend, err := costCenterTrend(ctx, cfg, opts, lastMonth)
+		if err != nil {
+			log.Printf("Warning: Failed to build cost center trend: %v", err)
+		}
+		reportData.Trend = trend
+	}
+
+	var outputPath string
+	switch opts.outputFormat {
+	case "html":
+		outputPath, err = rep.GenerateHTML(reportData)
+	case "markdown":
+		if opts.stdout {
+			err = reporter.WriteMarkdown(os.Stdout, reportData)
+		} else {
+			outputPath, err = rep.GenerateMarkdown(reportData)
+		}
+	case "csv":
+		outputPath, err = rep.GenerateCSVContext(ctx, reportData)
+	case "json":
+		outputPath, err = rep.GenerateJSON(reportData)
+	case "focus":
+		outputPath, err = rep.GenerateFOCUS(reportData)
+	case "parquet":
+		outputPath, err = rep.GenerateParquet(ctx, reportData)
+	case "arrow":
+		outputPath, err = rep.GenerateArrow(reportData)
+	default:
+		log.Fatalf("Unknown output format: %s", opts.outputFormat)
+	}
+
+	if err != nil {
+		log.Fatalf("Failed to generate report: %v", err)
+	}
+
+	if outputPath != "" {
+		log.Printf("Report generated: %s", outputPath)
+	}
+
+	custom, err := rep.GenerateCustom(reportData)
+	for _, path := range custom {
+		log.Printf("Report generated: %s", path)
+	}
+	if err != nil {
+		log.Printf("Warning: Failed to render custom templates: %v", err)
+	}
+
+	// Send alerts (unless dry-run)
+	if !opts.dryRun && (len(alerting) > 0 || len(budgetAlerts) > 0) {
+		if err := agg.SendAlerts(ctx, alerting, budgetAlerts); err != nil {
+			log.Printf("Warning: Failed to send some alerts: %v", err)
+		}
+	}
+
+	if !opts.dryRun && cfg.Alerting.Email.Enabled {
+		sendDigest(ctx, cfg, period, narrative, outputPath)
+	}
+
+	// Print summary, unless stdout carries the report
+	if !opts.stdout {
+		printSummary(results, anomalies, budgetAlerts)
+	}
+}
+
+// publishReports uploads the reports written since the run started and
+// prunes published reports past their retention
+func publishReports(publisher *publish.Publisher, dir string, since time.Time) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	published, err := publisher.PublishDir(ctx, dir, since)
+	for _, url := range published {
+		log.Printf("Report published: %s", url)
+	}
+	if err != nil {
+		log.Printf("Warning: Failed to publish reports: %v", err)
+	}
+	pruned, err := publisher.Prune(ctx, time.Now())
+	if err != nil {
+		log.Printf("Warning: Failed to prune published reports: %v", err)
+	}
+	if pruned > 0 {
+		log.Printf("Pruned %d published reports past retention", pruned)
+	}
+}
+
+// trackAlerts records the run's anomalies in the history store. It returns
+// the anomalies to report, without suppressed ones, and those to alert on,
+// without acknowledged ones either; without a store every anomaly is both.
+func trackAlerts(ctx context.Context, history store.CostStore, anomalies []aggregator.Anomaly) (reported, alerting []aggregator.Anomaly) {
+	if history == nil || len(anomalies) == 0 {
+		return anomalies, anomalies
+	}
+	tracker, err := anomaly.NewTracker(ctx, history)
+	if err != nil {
+		log.Printf("Warning: Failed to load anomaly states: %v", err)
+		return anomalies, anomalies
+	}
+
+	now := time.Now().UTC()
+	for _, an := range anomalies {
+		an.Status, err = tracker.Observe(ctx, an.ID, an.Service, an.Date, now)
+		if err != nil {
+			log.Printf("Warning: Failed to save anomaly state: %v", err)
+		}
+		switch an.Status {
+		case anomaly.StatusSuppressed:
+		case anomaly.StatusAcknowledged:
+			reported = append(reported, an)
+		default:
+			reported = append(reported, an)
+			alerting = append(alerting, an)
+		}
+	}
+	return reported, alerting
+}
+
+// saveHistory stores the aggregated records and applies retention when
+// automatic pruning is enabled
+func saveHistory(ctx context.Context, cfg *config.Config, history store.CostStore, results *aggregator.AggregationResult) {
+	if history == nil {
+		return
+	}
+
+	if err := history.SaveRecords(ctx, results.Records()); err != nil {
+		log.Printf("Warning: Failed to save history: %v", err)
+		return
+	}
+
+	if cfg.Store.Retention.AutoPrune {
+		stats, err := history.Prune(ctx, store.PolicyFrom(cfg), time.Now().UTC())
+		if err != nil {
+			log.Printf("Warning: Failed to prune history: %v", err)
+			return
+		}
+		if stats.DaysRolledUp > 0 || stats.MonthsDeleted > 0 {
+			log.Printf("Pruned history: %d days rolled into %d months, %d months deleted", stats.DaysRolledUp, stats.MonthsRolledUp, stats.MonthsDeleted)
+		}
+	}
+}
+
+// runAggregateStream aggregates without holding the entries in memory,
+// writing each to the CSV report as it arrives. Anomalies, budgets and the
+// history store need every entry at once, so they are left to runs without
+// --stream.
+func runAggregateStream(ctx context.Context, cfg *config.Config, agg *aggregator.Aggregator, opts options) {
+	if opts.outputFormat != "csv" {
+		log.Fatalf("--stream writes a CSV report; use --format csv")
+	}
+	log.Printf("Streaming costs from %s to %s", opts.start.Format("2006-01-02"), opts.end.Format("2006-01-02"))
+
+	out, err := reporter.New(cfg.Reporter).NewCSVStream()
+	if err != nil {
+		log.Fatalf("Failed to generate report: %v", err)
+	}
+	results, err := agg.AggregateStream(ctx, opts.start, opts.end, out.Write)
+	if err != nil {
+		out.Abort()
+		log.Fatalf("Failed to aggregate costs: %v", err)
+	}
+
+	log.Printf("Streamed %d cost entries across %d providers", out.Rows(), len(results.ByProvider))
+	for name, folded := range results.Overflow {
+		log.Printf("Warning: dimension %q exceeded its value limit; %d records grouped as %s", name, folded, aggregator.DimensionOther)
+	}
+	for _, c := range results.TagCaps {
+		log.Printf("Warning: tag %q %s: %d values on $%.2f of costs", c.Key, c.Reason, c.Values, c.Cost)
+	}
+	logProviderErrors(results.Errors)
+	if aggregator.CheckFreshness(results, opts.maxAge, time.Now()) != nil && opts.staleAction == "error" {
+		out.Abort()
+	}
+	checkFreshness(results, opts)
+
+	outputPath, err := out.Close()
+	if err != nil {
+		log.Fatalf("Failed to generate report: %v", err)
+	}
+	log.Printf("Report generated: %s", outputPath)
+	printSummary(results, nil, nil)
+}
+
+// priorPeriodComparison aggregates the period of equal length immediately
+// before start and compares it with current. It returns nil when the prior
+// period cannot be fetched.
+func priorPeriodComparison(ctx context.Context, agg *aggregator.Aggregator, start, end time.Time, current *aggregator.AggregationResult) *compare.Comparison {
+	priorStart := start.Add(-end.Sub(start))
+
+	previous, err := agg.Aggregate(ctx, priorStart, start)
+	if err != nil {
+		log.Printf("Warning: Failed to fetch prior period for comparison: %v", err)
+		return nil
+	}
+
+	return compare.Compare(previous, current)
+}
+
+// sendDigest emails the run's plain-English summary to the configured
+// recipients
+func sendDigest(ctx context.Context, cfg *config.Config, period, narrative, reportPath string) {
+	digest, err := notify.NewEmailDigest(cfg.Alerting.Email)
+	if err != nil {
+		log.Printf("Warning: Digest email not sent: %v", err)
+		return
+	}
+
+	body := narrative + "\n"
+	if reportPath != "" {
+		body += "\nFull report: " + reportPath + "\n"
+	}
+	if err := digest.Send(ctx, "Cloud cost digest: "+period, body); err != nil {
+		log.Printf("Warning: %v", err)
+		return
+	}
+	log.Printf("Digest email sent to %d recipients", len(cfg.Alerting.Email.Recipients))
+}
+
+// unitCosts divides the period's cost by the configured business metrics,
+// nil when none are configured or they cannot be read
+func unitCosts(ctx context.Context, cfg *config.Config, records []normalizer.CostRecord, start, end time.Time) []unitcost.Series {
+	if len(cfg.UnitCost.Metrics) == 0 {
+		return nil
+	}
+	calc, err := unitCalculator(cfg)
+	if err != nil {
+		log.Printf("Warning: Invalid unit cost configuration: %v", err)
+		return nil
+	}
+	series, err := calc.Compute(ctx, records, start, end)
+	if err != nil {
+		log.Printf("Warning: Failed to compute unit costs: %v", err)
+		return nil
+	}
+	return series
+}
+
+// unitCalculator builds the unit cost calculator, charging cost centers as
+// chargeback does
+func unitCalculator(cfg *config.Config) (*unitcost.Calculator, error) {
+	allocCfg, err := chargebackConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return unitcost.New(cfg.UnitCost, chargeback.NewAllocator(allocCfg).CostCenter)
+}
+
+func printSummary(results *aggregator.AggregationResult, anomalies []aggregator.Anomaly, budgetAlerts []aggregator.BudgetAlert) {
+	separator := strings.Repeat("=", 60)
+	fmt.Println("\n" + separator)
+	fmt.Println("COST AGGREGATION SUMMARY")
+	fmt.Println(separator)
+
+	if results.AsOf.IsZero() {
+		fmt.Println("\nData as of: no data")
+	} else {
+		fmt.Printf("\nData as of: %s\n", results.AsOf.Format("2006-01-02"))
+	}
+	fmt.Printf("Total Cost: $%.2f\n", results.TotalCost)
+	if len(results.Errors) > 0 {
+		missing := make([]string, len(results.Errors))
+		for i, e := range results.Errors {
+			missing[i] = fmt.Sprintf("%s (%s)", e.Provider, e.Kind)
+		}
+		fmt.Printf("Partial:    missing %s\n", strings.Join(missing, ", "))
+	}
+	if len(results.Adjustments) > 0 {
+		fmt.Printf("Raw Cost:   $%.2f (before adjustments)\n", results.RawTotalCost)
+		for name, change := range results.Adjustments {
+			fmt.Printf("  %-20s: $%.2f\n", name, change)
+		}
+	}
+	if results.InternalCost != 0 {
+		fmt.Printf("All charges: $%.2f\n", results.CombinedCost())
+		fmt.Printf("External:    $%.2f\n", results.ExternalCost)
+		fmt.Printf("Internal:    $%.2f\n", results.InternalCost)
+		for name, cost := range results.InternalRules {
+			fmt.Printf("  %-20s: $%.2f\n", name, cost)
+		}
+	}
+	if len(results.ByChargeType) > 1 || results.ExcludedCost != 0 {
+		fmt.Println("By Charge Type:")
+		for _, ct := range normalizer.ChargeTypes {
+			if cost, ok := results.ByChargeType[string(ct)]; ok {
+				fmt.Printf("  %-20s: $%.2f\n", ct, cost)
+			}
+		}
+		if results.ExcludedCost != 0 {
+			fmt.Printf("  Excluded from totals: $%.2f\n", results.ExcludedCost)
+		}
+	}
+	if results.Emissions > 0 {
+		fmt.Printf("Est. Emissions: %.1f kg CO2e\n", results.Emissions)
+	}
+	if results.ZeroCost > 0 {
+		if len(results.NoCost) > 0 {
+			fmt.Printf("No-cost services: %d (%d zero-cost records rolled up)\n", len(results.NoCost), results.ZeroCost)
+			for i, svc := range results.NoCostServices() {
+				if i == 5 {
+					break
+				}
+				fmt.Printf("  %-30s: %d records\n", svc.Service, svc.Records)
+			}
+		} else {
+			fmt.Printf("Zero-cost records dropped: %d\n", results.ZeroCost)
+		}
+	}
+	if results.Duplicates > 0 {
+		fmt.Printf("Duplicates dropped: %d records ($%.2f) reported by more than one provider\n", results.Duplicates, results.DuplicateCost)
+	}
+	if len(results.TagCaps) > 0 {
+		fmt.Println("Capped tags:")
+		for _, c := range results.TagCaps {
+			fmt.Printf("  %-30s: %s, %d values ($%.2f)\n", c.Key, c.Reason, c.Values, c.Cost)
+		}
+	}
+	fmt.Println("\nBy Provider:")
+	for provider, cost := range results.ByProvider {
+		fmt.Printf("  %-10s: $%.2f\n", provider, cost)
+	}
+
+	fmt.Println("\nTop 5 Services:")
+	for i, entry := range results.TopServices(5) {
+		fmt.Printf("  %d. %-30s: $%.2f\n", i+1, entry.Service, entry.Cost)
+	}
+
+	for _, name := range results.CustomNames() {
+		fmt.Printf("\nTop 5 by %s:\n", name)
+		for i, entry := range results.TopCustom(name, 5) {
+			fmt.Printf("  %d. %-30s: $%.2f\n", i+1, entry.Service, entry.Cost)
+		}
+	}
+
+	if rows := results.OrgUnits(); len(rows) > 0 {
+		fmt.Println("\nBy Org Unit:")
+		for _, row := range rows {
+			name := strings.Repeat("  ", row.Depth) + row.Name
+			fmt.Printf("  %-30s: $%.2f (%.1f%%)\n", name, row.Cost, row.Percent)
+		}
+	}
+
+	if len(anomalies) > 0 {
+		fmt.Printf("\nAnomalies Detected: %d\n", len(anomalies))
+		for _, a := range anomalies {
+			fmt.Printf("  - %s: %.1f%% above expected ($%.2f vs $%.2f expected)\n",
+				a.Service, a.PercentageDeviation, a.ActualCost, a.ExpectedCost)
+		}
+	}
+
+	if len(budgetAlerts) > 0 {
+		fmt.Printf("\nBudget Alerts: %d\n", len(budgetAlerts))
+		for _, b := range budgetAlerts {
+			fmt.Printf("  - %s: $%.2f / $%.2f (%.1f%%)\n",
+				b.BudgetName, b.CurrentSpend, b.BudgetLimit, b.PercentUsed)
+		}
+	}
+
+	fmt.Println("\n" + separator)
+}
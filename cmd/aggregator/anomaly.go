@@ -0,0 +1,479 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+	"github.com/lvonguyen/finops-platform/internal/anomaly"
+	"github.com/lvonguyen/finops-platform/internal/calendar"
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+	"github.com/lvonguyen/finops-platform/internal/store"
+)
+
+// monthStartHistory is how many earlier month starts a month start is
+// compared with under month_start seasonality
+const monthStartHistory = 3
+
+// runAnomaly fetches the lookback window plus the recent days and runs the
+// statistical detector, honoring the configured special-day calendar
+func runAnomaly(ctx context.Context, cfg *config.Config, agg *aggregator.Aggregator, opts options) {
+	cal, err := calendar.FromConfig(cfg.Calendar)
+	if err != nil {
+		log.Fatalf("Invalid calendar configuration: %v", err)
+	}
+
+	if cfg.Anomaly.Incremental && opts.history != nil {
+		runIncrementalAnomaly(ctx, cfg, cal, opts)
+		return
+	}
+
+	end := opts.fiscal.Today(time.Now())
+	start := end.AddDate(0, 0, -(cfg.Anomaly.LookbackDays + opts.days))
+
+	log.Printf("Fetching %d days of cost history for anomaly detection", cfg.Anomaly.LookbackDays+opts.days)
+
+	results, err := agg.Aggregate(ctx, start, end)
+	if err != nil {
+		log.Fatalf("Failed to aggregate costs: %v", err)
+	}
+	logProviderErrors(results.Errors)
+	checkFreshness(results, opts)
+	records := results.Records()
+
+	// Equivalent-day comparison needs the same special days from earlier years
+	holidayMode := anomaly.HolidayMode(cfg.Anomaly.HolidayMode)
+	if holidayMode == anomaly.HolidayEquivalent {
+		for d := end.AddDate(0, 0, -opts.days); d.Before(end); d = d.AddDate(0, 0, 1) {
+			day, ok := cal.Special(d)
+			if !ok {
+				continue
+			}
+			for _, prior := range cal.Equivalent(day.Key) {
+				if !prior.Date.Before(start) {
+					continue
+				}
+				priorResults, err := agg.Aggregate(ctx, prior.Date, prior.Date.AddDate(0, 0, 1))
+				if err != nil {
+					log.Printf("Warning: Failed to fetch %s (%s): %v", prior.Name, prior.Date.Format("2006-01-02"), err)
+					continue
+				}
+				records = append(records, priorResults.Records()...)
+			}
+		}
+	}
+
+	// Month starts are compared with earlier ones, mostly outside the lookback
+	if seasonality(cfg).MonthStart {
+		for d := end.AddDate(0, 0, -opts.days); d.Before(end); d = d.AddDate(0, 0, 1) {
+			if d.Day() != 1 {
+				continue
+			}
+			for i := 1; i <= monthStartHistory; i++ {
+				prior := d.AddDate(0, -i, 0)
+				if !prior.Before(start) {
+					continue
+				}
+				priorResults, err := agg.Aggregate(ctx, prior, prior.AddDate(0, 0, 1))
+				if err != nil {
+					log.Printf("Warning: Failed to fetch month start %s: %v", prior.Format("2006-01-02"), err)
+					continue
+				}
+				records = append(records, priorResults.Records()...)
+			}
+		}
+	}
+
+	detector := newDetector(cfg, cal, opts)
+
+	anomalies, suppressed := trackAnomalies(ctx, opts.history, detector.Detect(records), detector.Suppressed())
+	printAnomalies(anomalies, suppressed, activeCooldowns(cfg), detector.Ramping())
+
+	if opts.explain {
+		writeExplanations(cfg, detector.Explanations())
+	}
+}
+
+// trackAnomalies records detected anomalies in the history store and moves
+// those suppressed by a user to the suppressed list
+func trackAnomalies(ctx context.Context, history store.CostStore, anomalies, suppressed []anomaly.Anomaly) ([]anomaly.Anomaly, []anomaly.Anomaly) {
+	if history == nil || len(anomalies) == 0 {
+		return anomalies, suppressed
+	}
+	tracker, err := anomaly.NewTracker(ctx, history)
+	if err != nil {
+		log.Printf("Warning: Failed to load anomaly states: %v", err)
+		return anomalies, suppressed
+	}
+	kept, dismissed, err := tracker.Track(ctx, anomalies, time.Now().UTC())
+	if err != nil {
+		log.Printf("Warning: Failed to save anomaly states: %v", err)
+		return anomalies, suppressed
+	}
+	return kept, append(suppressed, dismissed...)
+}
+
+// anomalyCommands maps the anomaly mode subcommands to the status they set
+var anomalyCommands = map[string]string{
+	"ack":      anomaly.StatusAcknowledged,
+	"suppress": anomaly.StatusSuppressed,
+	"reopen":   anomaly.StatusOpen,
+}
+
+// runAnomalyCommand lists tracked anomalies (list) or changes the status of
+// one (ack, suppress or reopen followed by its ID)
+func runAnomalyCommand(history store.CostStore, args []string, note string) {
+	if history == nil {
+		log.Fatal("Anomaly acknowledgment requires store.enabled")
+	}
+	ctx := context.Background()
+	tracker, err := anomaly.NewTracker(ctx, history)
+	if err != nil {
+		log.Fatalf("Failed to load anomaly states: %v", err)
+	}
+
+	status, ok := anomalyCommands[args[0]]
+	switch {
+	case args[0] == "list" && len(args) == 1:
+		printAnomalyStates(tracker.States())
+	case ok && len(args) == 2:
+		st, err := tracker.Set(ctx, args[1], status, note, currentUser(), time.Now().UTC())
+		if err != nil {
+			log.Fatalf("Failed to update anomaly: %v", err)
+		}
+		log.Printf("Anomaly %s in %s on %s is now %s", st.ID, st.Scope, st.Date.Format("2006-01-02"), st.Status)
+	default:
+		log.Fatal("Usage: aggregator anomaly [--note text] list | ack <id> | suppress <id> | reopen <id>")
+	}
+}
+
+// currentUser names who runs the command, for the anomaly state audit
+func currentUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	return "cli"
+}
+
+func newDetector(cfg *config.Config, cal *calendar.Calendar, opts options) *anomaly.Detector {
+	changes, cooldown := loadChanges(cfg)
+	if err := anomaly.ValidateAlgorithm(cfg.Anomaly.Algorithm); err != nil {
+		log.Fatalf("Invalid anomaly configuration: %v", err)
+	}
+	if a := cfg.Anomaly.EWMAAlpha; a < 0 || a > 1 {
+		log.Fatalf("Invalid anomaly configuration: ewma_alpha %v must be between 0 and 1", a)
+	}
+	scopes, err := anomaly.ScopesFrom(cfg.Anomaly.Scopes)
+	if err != nil {
+		log.Fatalf("Invalid anomaly configuration: %v", err)
+	}
+	return anomaly.NewDetector(anomaly.DetectorConfig{
+		Sensitivity:  anomaly.Sensitivity(cfg.Anomaly.Sensitivity),
+		BaselineDays: cfg.Anomaly.LookbackDays,
+		RecentDays:   opts.days,
+		MinSpend:     cfg.Anomaly.MinimumCostThreshold,
+		Calendar:     cal,
+		HolidayMode:  anomaly.HolidayMode(cfg.Anomaly.HolidayMode),
+		Explain:      opts.explain,
+		Changes:      changes,
+		Cooldown:     cooldown,
+		RampGrace:    rampGrace(cfg),
+		RampAction:   cfg.Anomaly.RampAction,
+		MultiMetric:  cfg.Anomaly.MultiMetric,
+		Seasonality:  seasonality(cfg),
+		Algorithm:    cfg.Anomaly.Algorithm,
+		EWMAAlpha:    cfg.Anomaly.EWMAAlpha,
+		Scopes:       scopes,
+	})
+}
+
+// seasonality returns the seasonal patterns anomaly baselines model
+func seasonality(cfg *config.Config) anomaly.Seasonality {
+	s, err := anomaly.ParseSeasonality(cfg.Anomaly.Seasonality)
+	if err != nil {
+		log.Fatalf("Invalid anomaly configuration: %v", err)
+	}
+	return s
+}
+
+// rampGrace returns how long newly onboarded accounts are treated as ramping up
+func rampGrace(cfg *config.Config) time.Duration {
+	if err := anomaly.ValidateRampAction(cfg.Anomaly.RampAction); err != nil {
+		log.Fatalf("Invalid anomaly configuration: %v", err)
+	}
+	grace, err := parseDuration(cfg.Anomaly.RampGrace)
+	if err != nil {
+		log.Fatalf("Invalid anomaly ramp grace: %v", err)
+	}
+	return grace
+}
+
+// loadChanges reads the changes recorded by mark-change and the cooldown
+// that follows each
+func loadChanges(cfg *config.Config) ([]anomaly.Change, time.Duration) {
+	cooldown, err := parseDuration(cfg.Anomaly.Cooldown)
+	if err != nil {
+		log.Fatalf("Invalid anomaly cooldown: %v", err)
+	}
+	changes, err := anomaly.LoadChanges(cfg.Anomaly.ChangesFile)
+	if err != nil {
+		log.Fatalf("Failed to load changes: %v", err)
+	}
+	return changes, cooldown
+}
+
+// activeCooldowns returns the scopes currently in cooldown
+func activeCooldowns(cfg *config.Config) []anomaly.Cooldown {
+	changes, cooldown := loadChanges(cfg)
+	return anomaly.ActiveCooldowns(changes, time.Now(), cooldown)
+}
+
+// runMarkChange records a change so anomalies in its scope are suppressed
+// for the configured cooldown
+func runMarkChange(cfg *config.Config, scope, note string) {
+	change, err := anomaly.ParseScope(scope)
+	if err != nil {
+		log.Fatalf("Invalid -scope: %v", err)
+	}
+	change.Note = note
+	change.At = time.Now().UTC()
+
+	cooldown, err := parseDuration(cfg.Anomaly.Cooldown)
+	if err != nil {
+		log.Fatalf("Invalid anomaly cooldown: %v", err)
+	}
+	if err := anomaly.RecordChange(cfg.Anomaly.ChangesFile, change, cooldown); err != nil {
+		log.Fatalf("Failed to record change: %v", err)
+	}
+	log.Printf("Recorded change to %s; anomalies there are suppressed until %s", change.Scope(), change.Until(cooldown).Format("2006-01-02 15:04 MST"))
+}
+
+// incrementalJob is the checkpoint recording the last day evaluated by
+// incremental anomaly detection
+const incrementalJob = "anomaly-incremental"
+
+// runIncrementalAnomaly evaluates only the days that landed in the history
+// store since the previous run. The baseline window is carried between runs
+// in the checkpoint, and read from the stored history on the first run or
+// when the lookback has grown past it.
+func runIncrementalAnomaly(ctx context.Context, cfg *config.Config, cal *calendar.Calendar, opts options) {
+	now := time.Now().UTC()
+	today := opts.fiscal.Today(now)
+	windowStart := today.AddDate(0, 0, -(cfg.Anomaly.LookbackDays + opts.days))
+
+	since := today.AddDate(0, 0, -opts.days)
+	cp, ok, err := opts.history.LoadCheckpoint(ctx, incrementalJob)
+	if err != nil {
+		log.Fatalf("Failed to read detection checkpoint: %v", err)
+	}
+	if ok && cp.Completed.After(since) {
+		since = cp.Completed
+	}
+
+	inc := anomaly.NewIncremental(newDetector(cfg, cal, opts))
+	from := windowStart
+	restored := ok && len(cp.State) > 0 && !cp.From.After(windowStart)
+	if restored {
+		if err := inc.Restore(cp.State); err != nil {
+			log.Printf("Warning: %v; reading the window from history", err)
+			restored = false
+		} else {
+			from = since
+		}
+	}
+
+	stored, err := opts.history.QueryRange(ctx, from, now)
+	if err != nil {
+		log.Fatalf("Failed to read history: %v", err)
+	}
+	stored = opts.where.Select(stored)
+
+	var seed, fresh []normalizer.CostRecord
+	for _, r := range stored {
+		switch {
+		case store.IsRollup(r):
+		case r.Date.Before(since):
+			seed = append(seed, r)
+		default:
+			fresh = append(fresh, r)
+		}
+	}
+	if len(fresh) == 0 {
+		log.Printf("No new days in the history store since %s", since.Format("2006-01-02"))
+		return
+	}
+
+	// Equivalent-day comparison needs the same special days from earlier years
+	if anomaly.HolidayMode(cfg.Anomaly.HolidayMode) == anomaly.HolidayEquivalent {
+		seen := make(map[string]bool)
+		for _, r := range fresh {
+			day, ok := cal.Special(r.Date)
+			if !ok || seen[day.Key] {
+				continue
+			}
+			seen[day.Key] = true
+			for _, prior := range cal.Equivalent(day.Key) {
+				if !prior.Date.Before(windowStart) {
+					continue
+				}
+				priorRecords, err := opts.history.QueryRange(ctx, prior.Date, prior.Date.AddDate(0, 0, 1))
+				if err != nil {
+					log.Printf("Warning: Failed to read %s (%s): %v", prior.Name, prior.Date.Format("2006-01-02"), err)
+					continue
+				}
+				seed = append(seed, opts.where.Select(priorRecords)...)
+			}
+		}
+	}
+
+	latest := since
+	for _, r := range fresh {
+		if r.Date.After(latest) {
+			latest = r.Date
+		}
+	}
+	log.Printf("Evaluating %d new records from %s to %s", len(fresh), since.Format("2006-01-02"), latest.Format("2006-01-02"))
+	if restored {
+		log.Printf("Restored the detection window from the previous run")
+	}
+
+	inc.Seed(seed)
+	anomalies, suppressed := trackAnomalies(ctx, opts.history, inc.Update(fresh, now), inc.Suppressed())
+	printAnomalies(anomalies, suppressed, activeCooldowns(cfg), inc.Ramping())
+
+	if opts.explain {
+		writeExplanations(cfg, inc.Explanations())
+	}
+
+	cp = store.Checkpoint{Job: incrementalJob, From: windowStart, Completed: latest.AddDate(0, 0, 1), UpdatedAt: now}
+	if cp.State, err = inc.State(); err != nil {
+		log.Printf("Warning: Failed to encode the detection window: %v", err)
+	}
+	if err := opts.history.SaveCheckpoint(ctx, cp); err != nil {
+		log.Printf("Warning: Failed to save detection checkpoint: %v", err)
+	}
+}
+
+// writeExplanations dumps the detector's computations as JSON
+func writeExplanations(cfg *config.Config, explanations []anomaly.Explanation) {
+	if err := os.MkdirAll(cfg.Reporter.OutputDir, 0755); err != nil {
+		log.Fatalf("Failed to create output directory: %v", err)
+	}
+
+	data, err := json.MarshalIndent(explanations, "", "  ")
+	if err != nil {
+		log.Fatalf("Failed to encode explanations: %v", err)
+	}
+
+	path := filepath.Join(cfg.Reporter.OutputDir, fmt.Sprintf("anomaly-explain-%s.json", time.Now().Format("20060102-150405")))
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		log.Fatalf("Failed to write explanations: %v", err)
+	}
+	log.Printf("Wrote %d explanations: %s", len(explanations), path)
+}
+
+func printAnomalies(anomalies, suppressed []anomaly.Anomaly, cooldowns []anomaly.Cooldown, ramping []anomaly.RampAccount) {
+	separator := strings.Repeat("=", 60)
+	fmt.Println("\n" + separator)
+	fmt.Println("COST ANOMALIES")
+	fmt.Println(separator)
+
+	if len(anomalies) == 0 {
+		fmt.Println("\nNo anomalies detected")
+	}
+	for _, a := range anomalies {
+		fmt.Printf("\n[%s] %s %s %s", a.Severity, a.Date.Format("2006-01-02"), anomalyWhere(a), a.ID)
+		if a.Status == anomaly.StatusAcknowledged {
+			fmt.Print(", acknowledged")
+		}
+		fmt.Println()
+		if a.Resource != "" {
+			fmt.Printf("  resource %s\n", a.Resource)
+		}
+		fmt.Printf("  $%.2f vs $%.2f expected (%+.1f%%, z=%.2f)\n", a.ActualCost, a.ExpectedCost, a.PercentChange, a.Deviation)
+		fmt.Printf("  %s\n", a.Reason)
+		if s := a.Signals; s != nil {
+			fmt.Printf("  cost %+.1f%%, usage %+.1f%%", s.Cost, s.Usage)
+			if s.UsageUnit != "" {
+				fmt.Printf(" (%s)", s.UsageUnit)
+			}
+			fmt.Printf(", records %+.1f%%\n", s.Records)
+		}
+	}
+
+	if len(suppressed) > 0 {
+		fmt.Printf("\nSuppressed: %d\n", len(suppressed))
+		for _, a := range suppressed {
+			why := "after " + a.Cooldown
+			switch {
+			case a.Status == anomaly.StatusSuppressed:
+				why = "suppressed by a user"
+			case a.Cooldown == "":
+				why = a.Ramp
+			}
+			fmt.Printf("  - [%s] %s %s: $%.2f vs $%.2f expected, %s\n",
+				a.Severity, a.Date.Format("2006-01-02"), anomalyWhere(a), a.ActualCost, a.ExpectedCost, why)
+		}
+	}
+
+	if len(cooldowns) > 0 {
+		fmt.Println("\nActive Cooldowns:")
+		for _, c := range cooldowns {
+			fmt.Printf("  - %s until %s", c.Scope(), c.Until.Format("2006-01-02 15:04 MST"))
+			if c.Note != "" {
+				fmt.Printf(" (%s)", c.Note)
+			}
+			fmt.Println()
+		}
+	}
+
+	if len(ramping) > 0 {
+		fmt.Println("\nAccounts Ramping Up:")
+		for _, r := range ramping {
+			fmt.Printf("  - %s since %s (%d days, $%.2f -> $%.2f/day), grace until %s\n",
+				r.Scope(), r.FirstSeen.Format("2006-01-02"), r.Days, r.FirstCost, r.LatestCost, r.GraceEnds.Format("2006-01-02"))
+		}
+	}
+
+	fmt.Println("\n" + separator)
+}
+
+// anomalyWhere describes where an anomaly was found: its cloud service and
+// account, or its scope group
+func anomalyWhere(a anomaly.Anomaly) string {
+	if a.Group != "" {
+		return a.Group
+	}
+	return fmt.Sprintf("%s/%s (%s)", a.Cloud, a.Service, a.Account)
+}
+
+func printAnomalyStates(states []store.AnomalyState) {
+	separator := strings.Repeat("=", 60)
+	fmt.Println("\n" + separator)
+	fmt.Println("TRACKED ANOMALIES")
+	fmt.Println(separator)
+
+	if len(states) == 0 {
+		fmt.Println("\nNo anomalies tracked")
+	}
+	for _, st := range states {
+		fmt.Printf("\n%s [%s] %s %s\n", st.ID, st.Status, st.Date.Format("2006-01-02"), st.Scope)
+		fmt.Printf("  first seen %s, last seen %s\n", st.FirstSeen.Format("2006-01-02 15:04 MST"), st.LastSeen.Format("2006-01-02 15:04 MST"))
+		if st.UpdatedBy != "" {
+			fmt.Printf("  %s by %s on %s\n", st.Status, st.UpdatedBy, st.UpdatedAt.Format("2006-01-02 15:04 MST"))
+		}
+		if st.Note != "" {
+			fmt.Printf("  %s\n", st.Note)
+		}
+	}
+
+	fmt.Println("\n" + separator)
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/estimate"
+)
+
+// Budget check exit codes, for CI pipelines; 1 is left to fatal errors
+const (
+	budgetExitWarning  = 2 // a threshold was crossed or a budget is projected to be exceeded
+	budgetExitExceeded = 3 // month-to-date spend is over a budget
+)
+
+// runBudgetCheck evaluates budgets against month-to-date spend and a
+// month-end forecast, sends the alerts, and returns the exit code
+func runBudgetCheck(ctx context.Context, cfg *config.Config, agg *aggregator.Aggregator, opts options) int {
+	if len(cfg.Budgets) == 0 {
+		log.Fatal("No budgets configured")
+	}
+
+	// Data through yesterday; earlier history fits the period-end projection
+	asOf := opts.fiscal.Today(time.Now())
+	historyStart := asOf.AddDate(0, 0, -cfg.Forecast.HistoryDays)
+	for _, b := range cfg.Budgets {
+		if start, _, ok := aggregator.BudgetPeriod(b, asOf, opts.fiscal); ok && start.Before(historyStart) {
+			historyStart = start
+		}
+	}
+
+	records, err := forecastHistory(ctx, agg, opts, historyStart, asOf)
+	if err != nil {
+		log.Fatalf("Failed to load cost history: %v", err)
+	}
+
+	alerts := agg.EvaluateBudgets(records, asOf)
+
+	if opts.outputFormat == "json" {
+		if err := os.MkdirAll(cfg.Reporter.OutputDir, 0755); err != nil {
+			log.Fatalf("Failed to create output directory: %v", err)
+		}
+		outputPath := filepath.Join(cfg.Reporter.OutputDir, fmt.Sprintf("budget-%s.json", asOf.Format("20060102")))
+		data, err := json.MarshalIndent(alerts, "", "  ")
+		if err == nil {
+			err = os.WriteFile(outputPath, data, 0644)
+		}
+		if err != nil {
+			log.Fatalf("Failed to write budget check: %v", err)
+		}
+		log.Printf("Budget check written: %s", outputPath)
+	}
+
+	if !opts.dryRun && len(alerts) > 0 {
+		if err := agg.SendAlerts(ctx, nil, alerts); err != nil {
+			log.Printf("Warning: Failed to send some alerts: %v", err)
+		}
+	}
+
+	printBudgetCheck(cfg.Budgets, alerts, asOf)
+	return budgetExitCode(alerts)
+}
+
+// budgetExitCode returns budgetExitExceeded when spend is over a budget,
+// budgetExitWarning for any other alert, and 0 without alerts
+func budgetExitCode(alerts []aggregator.BudgetAlert) int {
+	code := 0
+	for _, a := range alerts {
+		if a.Kind == aggregator.BudgetActual && a.CurrentSpend >= a.BudgetLimit {
+			return budgetExitExceeded
+		}
+		code = budgetExitWarning
+	}
+	return code
+}
+
+func printBudgetCheck(budgets []config.Budget, alerts []aggregator.BudgetAlert, asOf time.Time) {
+	separator := strings.Repeat("=", 60)
+	fmt.Println("\n" + separator)
+	fmt.Printf("BUDGET CHECK: through %s\n", asOf.AddDate(0, 0, -1).Format("2006-01-02"))
+	fmt.Println(separator)
+
+	if len(alerts) == 0 {
+		fmt.Printf("\nAll %d budgets within thresholds\n", len(budgets))
+	}
+	for _, a := range alerts {
+		switch a.Kind {
+		case aggregator.BudgetForecast:
+			fmt.Printf("\n  [%s] %s: projected $%.2f / $%.2f by period end ($%.2f so far)\n",
+				a.Severity, a.BudgetName, a.ProjectedSpend, a.BudgetLimit, a.CurrentSpend)
+		case aggregator.BudgetRunRate:
+			fmt.Printf("\n  [%s] %s: burning $%.2f/day, $%.2f/day left ($%.2f / $%.2f)\n",
+				a.Severity, a.BudgetName, a.DailyBurn, a.AllowedBurn, a.CurrentSpend, a.BudgetLimit)
+		default:
+			fmt.Printf("\n  [%s] %s: $%.2f / $%.2f (%.1f%%, threshold %d%%), projected $%.2f\n",
+				a.Severity, a.BudgetName, a.CurrentSpend, a.BudgetLimit, a.PercentUsed, a.Threshold, a.ProjectedSpend)
+		}
+		fmt.Printf("    period %s\n", a.Period)
+		if a.Parent != "" {
+			fmt.Printf("    rolls up into %s\n", a.Parent)
+		}
+	}
+
+	fmt.Println("\n" + separator)
+}
+
+// runBudgetSync reconciles the declared budgets with the clouds' own,
+// optionally creating missing ones, and returns budgetExitWarning while
+// drift remains
+func runBudgetSync(ctx context.Context, cfg *config.Config, agg *aggregator.Aggregator, opts options) int {
+	if len(cfg.Budgets) == 0 {
+		log.Fatal("No budgets configured")
+	}
+
+	create := opts.createMissing && !opts.dryRun
+	if opts.createMissing && opts.dryRun {
+		log.Printf("Dry run: missing budgets will not be created")
+	}
+	sync := agg.SyncBudgets(ctx, create)
+
+	if opts.outputFormat == "json" {
+		if err := os.MkdirAll(cfg.Reporter.OutputDir, 0755); err != nil {
+			log.Fatalf("Failed to create output directory: %v", err)
+		}
+		outputPath := filepath.Join(cfg.Reporter.OutputDir, fmt.Sprintf("budget-sync-%s.json", time.Now().UTC().Format("20060102")))
+		data, err := json.MarshalIndent(sync, "", "  ")
+		if err == nil {
+			err = os.WriteFile(outputPath, data, 0644)
+		}
+		if err != nil {
+			log.Fatalf("Failed to write budget sync: %v", err)
+		}
+		log.Printf("Budget sync written: %s", outputPath)
+	}
+
+	printBudgetSync(sync)
+
+	if sync.Unresolved() {
+		return budgetExitWarning
+	}
+	return 0
+}
+
+func printBudgetSync(sync aggregator.BudgetSync) {
+	separator := strings.Repeat("=", 60)
+	fmt.Println("\n" + separator)
+	fmt.Println("BUDGET SYNC")
+	fmt.Println(separator)
+
+	fmt.Printf("\n  In sync: %d budgets\n", len(sync.InSync))
+	for _, d := range sync.Drift {
+		switch d.Kind {
+		case aggregator.DriftMissing:
+			status := "not in the cloud"
+			switch {
+			case d.Created:
+				status = "created"
+			case d.Error != "":
+				status = "not created: " + d.Error
+			}
+			fmt.Printf("\n  [missing] %s/%s $%.2f: %s\n", d.Provider, d.BudgetName, d.Limit, status)
+		case aggregator.DriftLimit:
+			fmt.Printf("\n  [limit] %s/%s: declared $%.2f, cloud $%.2f\n", d.Provider, d.BudgetName, d.Limit, d.CloudLimit)
+		case aggregator.DriftScope:
+			fmt.Printf("\n  [scope] %s/%s: declared %q, cloud %q\n", d.Provider, d.BudgetName, d.Scope, d.CloudScope)
+		case aggregator.DriftUnmanaged:
+			scope := ""
+			if d.CloudScope != "" {
+				scope = " in " + d.CloudScope
+			}
+			fmt.Printf("\n  [unmanaged] %s/%s $%.2f%s: not declared in config\n", d.Provider, d.BudgetName, d.CloudLimit, scope)
+		}
+	}
+
+	if len(sync.Skipped) > 0 {
+		names := make([]string, 0, len(sync.Skipped))
+		for name := range sync.Skipped {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Println("\n  Not synced:")
+		for _, name := range names {
+			fmt.Printf("    %s: %s\n", name, sync.Skipped[name])
+		}
+	}
+	for provider, err := range sync.Errors {
+		fmt.Printf("\n  [error] %s budgets could not be listed: %s\n", provider, err)
+	}
+
+	fmt.Println("\n" + separator)
+}
+
+// runEstimate checks an Infracost estimate of a change against the headroom
+// of the team's budget and its parents, and returns the budget check's exit
+// codes for CI: a warning, or a change that would exceed a budget
+func runEstimate(ctx context.Context, cfg *config.Config, agg *aggregator.Aggregator, opts options) int {
+	if opts.plan == "" || opts.budget == "" {
+		log.Fatal("Estimate mode requires -plan (Infracost JSON) and -budget (budget name or cost center)")
+	}
+	est, err := estimate.Load(opts.plan)
+	if err != nil {
+		log.Fatalf("Failed to load estimate: %v", err)
+	}
+	if base := cfg.Currency.Base; base != "" && est.Currency != "" && est.Currency != base {
+		log.Printf("Warning: estimate is in %s, budgets are in %s", est.Currency, base)
+	}
+
+	// Headroom is measured against the period-end projection, as in budget mode
+	asOf := opts.fiscal.Today(time.Now())
+	historyStart := asOf.AddDate(0, 0, -cfg.Forecast.HistoryDays)
+	for _, b := range cfg.Budgets {
+		if start, _, ok := aggregator.BudgetPeriod(b, asOf, opts.fiscal); ok && start.Before(historyStart) {
+			historyStart = start
+		}
+	}
+	records, err := forecastHistory(ctx, agg, opts, historyStart, asOf)
+	if err != nil {
+		log.Fatalf("Failed to load cost history: %v", err)
+	}
+
+	report, err := estimate.Check(est, cfg.Budgets, opts.budget, agg.ProjectBudgets(records, asOf), asOf, opts.fiscal)
+	if err != nil {
+		log.Fatalf("Failed to check estimate: %v", err)
+	}
+
+	if opts.outputFormat == "json" {
+		if err := os.MkdirAll(cfg.Reporter.OutputDir, 0755); err != nil {
+			log.Fatalf("Failed to create output directory: %v", err)
+		}
+		outputPath := filepath.Join(cfg.Reporter.OutputDir, fmt.Sprintf("estimate-%s.json", asOf.Format("20060102")))
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err == nil {
+			err = os.WriteFile(outputPath, data, 0644)
+		}
+		if err != nil {
+			log.Fatalf("Failed to write estimate check: %v", err)
+		}
+		log.Printf("Estimate check written: %s", outputPath)
+	}
+
+	printEstimate(report)
+
+	switch report.Verdict {
+	case estimate.Fail:
+		return budgetExitExceeded
+	case estimate.Warn:
+		return budgetExitWarning
+	}
+	return 0
+}
+
+func printEstimate(report *estimate.Report) {
+	separator := strings.Repeat("=", 60)
+	fmt.Println("\n" + separator)
+	fmt.Printf("COST ESTIMATE: %s\n", strings.ToUpper(report.Verdict))
+	fmt.Println(separator)
+
+	e := report.Estimate
+	fmt.Printf("\nMonthly cost after change: $%.2f (%+.2f)\n", e.MonthlyCost, e.MonthlyDelta)
+	for _, p := range e.Projects {
+		fmt.Printf("  %-40s $%.2f (%+.2f)\n", p.Name, p.MonthlyCost, p.MonthlyDelta)
+	}
+
+	fmt.Println("\nBudget headroom:")
+	for _, r := range report.Results {
+		fmt.Printf("\n  [%s] %s: projected $%.2f + $%.2f from the change = $%.2f / $%.2f (warn at %d%%)\n",
+			r.Verdict, r.Budget, r.Projected, r.Impact, r.WithChange, r.Limit, r.WarnAt)
+		fmt.Printf("    period %s, headroom $%.2f\n", r.Period, r.Headroom)
+	}
+
+	fmt.Println("\n" + separator)
+}
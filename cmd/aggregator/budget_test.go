@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+)
+
+func TestBudgetExitCode(t *testing.T) {
+	threshold := aggregator.BudgetAlert{Kind: aggregator.BudgetActual, CurrentSpend: 80, BudgetLimit: 100}
+	exceeded := aggregator.BudgetAlert{Kind: aggregator.BudgetActual, CurrentSpend: 100, BudgetLimit: 100}
+	projected := aggregator.BudgetAlert{Kind: aggregator.BudgetForecast, CurrentSpend: 60, BudgetLimit: 100, ProjectedSpend: 130}
+
+	tests := []struct {
+		name   string
+		alerts []aggregator.BudgetAlert
+		want   int
+	}{
+		{"no alerts", nil, 0},
+		{"threshold", []aggregator.BudgetAlert{threshold}, budgetExitWarning},
+		{"forecast", []aggregator.BudgetAlert{projected}, budgetExitWarning},
+		{"exceeded after a warning", []aggregator.BudgetAlert{projected, exceeded}, budgetExitExceeded},
+	}
+	for _, tt := range tests {
+		if got := budgetExitCode(tt.alerts); got != tt.want {
+			t.Errorf("%s: budgetExitCode() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
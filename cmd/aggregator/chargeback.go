@@ -0,0 +1,487 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+	"github.com/lvonguyen/finops-platform/internal/calendar"
+	"github.com/lvonguyen/finops-platform/internal/chargeback"
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/currency"
+	"github.com/lvonguyen/finops-platform/internal/hierarchy"
+)
+
+// runChargeback allocates a month's costs to cost centers, applies manual
+// overrides, and writes the chargeback report and override audit log
+func runChargeback(ctx context.Context, cfg *config.Config, agg *aggregator.Aggregator, opts options) {
+	allocCfg, err := chargebackConfig(cfg)
+	if err != nil {
+		log.Fatalf("Invalid chargeback configuration: %v", err)
+	}
+	if err := chargeback.ValidateColumns(cfg.Chargeback.Columns); err != nil {
+		log.Fatalf("Invalid chargeback configuration: %v", err)
+	}
+	invoiceOpts, err := chargeback.InvoiceOptionsFrom(cfg.Chargeback.Invoices)
+	if err != nil {
+		log.Fatalf("Invalid chargeback configuration: %v", err)
+	}
+
+	name, err := parseMonth(opts.month, opts.fiscal)
+	if err != nil {
+		log.Fatalf("Invalid month: %v", err)
+	}
+	start, end := opts.fiscal.Month(name)
+	month := name.Format("2006-01")
+
+	log.Printf("Allocating %s costs for %s (%s to %s)", allocCfg.Basis, month, start.Format("2006-01-02"), end.AddDate(0, 0, -1).Format("2006-01-02"))
+
+	results, err := agg.Aggregate(ctx, start, end)
+	if err != nil {
+		log.Fatalf("Failed to aggregate costs: %v", err)
+	}
+	logProviderErrors(results.Errors)
+	checkFreshness(results, opts)
+
+	// The previous month is allocated with the same rules so that changes
+	// reflect spend rather than configuration
+	var previous map[string]*chargeback.Allocation
+	if cfg.Chargeback.MonthOverMonth {
+		prevStart, prevEnd := opts.fiscal.Month(name.AddDate(0, -1, 0))
+		records, err := forecastHistory(ctx, agg, opts, prevStart, prevEnd)
+		if err != nil {
+			log.Printf("Warning: cannot compare with the previous month: %v", err)
+		} else {
+			previous = chargeback.NewAllocator(allocCfg).Allocate(records)
+		}
+	}
+
+	allocator := chargeback.NewAllocator(allocCfg)
+	report := chargeback.GenerateReport(allocator.Allocate(results.Records()), previous, month)
+	report.Overrides = allocator.AppliedOverrides()
+	report.Unresolved = allocator.Unresolved()
+	report.Untagged = allocator.UntaggedCharges()
+	report.Columns = cfg.Chargeback.Columns
+
+	// Convert each center into its billing currency; fails before writing
+	// anything if a rate is missing
+	conv, err := currency.Load(ctx, cfg.Currency)
+	if err != nil {
+		log.Fatalf("Invalid currency configuration: %v", err)
+	}
+	err = report.ApplyCurrencies(conv, chargeback.CurrencyMapping{
+		CostCenters: cfg.Chargeback.Currencies,
+		Accounts:    cfg.Chargeback.AccountCurrency,
+	})
+	if err != nil {
+		log.Fatalf("Cannot convert chargeback currencies: %v", err)
+	}
+
+	// Overrides only move charges, so allocated totals must still match spend
+	if diff := math.Abs(report.TotalCost - results.TotalCost); diff > 0.01 {
+		log.Printf("Warning: allocated total $%.2f differs from spend $%.2f by $%.2f", report.TotalCost, results.TotalCost, diff)
+	}
+
+	if err := os.MkdirAll(cfg.Reporter.OutputDir, 0755); err != nil {
+		log.Fatalf("Failed to create output directory: %v", err)
+	}
+
+	reportPath := filepath.Join(cfg.Reporter.OutputDir, fmt.Sprintf("chargeback-%s.csv", month))
+	save := report.SaveCSV
+	switch opts.outputFormat {
+	case "json":
+		reportPath = strings.TrimSuffix(reportPath, ".csv") + ".json"
+		save = report.SaveJSON
+	case "xlsx":
+		reportPath = strings.TrimSuffix(reportPath, ".csv") + ".xlsx"
+		save = report.SaveXLSX
+	}
+	if err := save(reportPath); err != nil {
+		log.Fatalf("Failed to write chargeback report: %v", err)
+	}
+	log.Printf("Chargeback report generated: %s", reportPath)
+
+	ratesPath := filepath.Join(cfg.Reporter.OutputDir, fmt.Sprintf("chargeback-%s-rates.csv", month))
+	if err := report.SaveRatesCSV(ratesPath); err != nil {
+		log.Fatalf("Failed to write blended rates: %v", err)
+	}
+	log.Printf("Blended rates: %s", ratesPath)
+
+	if jc := cfg.Chargeback.Journal; jc.ClearingAccount != "" {
+		journal, err := chargeback.BuildJournal(report, chargeback.JournalAccounts{
+			CostCenters: jc.Accounts,
+			Default:     jc.DefaultAccount,
+			Clearing:    jc.ClearingAccount,
+			Prefix:      jc.Prefix,
+		})
+		if err != nil {
+			log.Fatalf("Failed to build chargeback journal: %v", err)
+		}
+		journalPath := filepath.Join(cfg.Reporter.OutputDir, fmt.Sprintf("chargeback-%s-journal.csv", month))
+		save := journal.SaveCSV
+		if opts.outputFormat == "json" {
+			journalPath = strings.TrimSuffix(journalPath, ".csv") + ".json"
+			save = journal.SaveJSON
+		}
+		if err := save(journalPath); err != nil {
+			log.Fatalf("Failed to write chargeback journal: %v", err)
+		}
+		log.Printf("Journal %s balanced at %s %.2f: %s", journal.ID, journal.Currency, journal.Debits(), journalPath)
+	}
+
+	if cfg.Chargeback.Invoices.Enabled {
+		writeInvoices(chargeback.BuildInvoices(report, invoiceOpts, time.Now()), cfg, month)
+	}
+
+	if len(report.Overrides) > 0 {
+		auditPath := filepath.Join(cfg.Reporter.OutputDir, fmt.Sprintf("chargeback-%s-overrides.csv", month))
+		if err := report.SaveOverridesCSV(auditPath); err != nil {
+			log.Fatalf("Failed to write override audit log: %v", err)
+		}
+		log.Printf("Applied %d overrides, audit log: %s", len(report.Overrides), auditPath)
+	}
+
+	if len(report.Unresolved) > 0 {
+		unresolvedPath := filepath.Join(cfg.Reporter.OutputDir, fmt.Sprintf("chargeback-%s-unresolved.csv", month))
+		if err := report.SaveUnresolvedCSV(unresolvedPath); err != nil {
+			log.Fatalf("Failed to write unresolved allocation keys: %v", err)
+		}
+		var cost float64
+		for _, u := range report.Unresolved {
+			cost += u.Cost
+		}
+		log.Printf("Warning: allocation key unresolved for $%.2f, allocated as untagged: %s", cost, unresolvedPath)
+	}
+
+	printChargeback(report)
+}
+
+// writeInvoices saves the month's invoices in each configured format
+func writeInvoices(invoices chargeback.Invoices, cfg *config.Config, month string) {
+	formats := cfg.Chargeback.Invoices.Formats
+	if len(formats) == 0 {
+		formats = []string{"csv"}
+	}
+	for _, format := range formats {
+		path := filepath.Join(cfg.Reporter.OutputDir, fmt.Sprintf("chargeback-%s-invoices.%s", month, format))
+		save := invoices.SaveCSV
+		switch format {
+		case "json":
+			save = invoices.SaveJSON
+		case "xlsx":
+			save = invoices.SaveXLSX
+		case "pdf":
+			save = invoices.SavePDF
+		}
+		if err := save(path); err != nil {
+			log.Fatalf("Failed to write chargeback invoices: %v", err)
+		}
+		log.Printf("Wrote %d invoices: %s", len(invoices), path)
+	}
+}
+
+// chargebackConfig builds the allocator configuration, loading the shared
+// cost drivers and, when the allocation key references org units, the
+// account hierarchy
+func chargebackConfig(cfg *config.Config) (chargeback.AllocatorConfig, error) {
+	return allocatorConfig(cfg, cfg.Chargeback)
+}
+
+func allocatorConfig(cfg *config.Config, cb config.ChargebackConfig) (chargeback.AllocatorConfig, error) {
+	allocCfg, err := chargeback.ConfigFrom(cb)
+	if err != nil {
+		return chargeback.AllocatorConfig{}, err
+	}
+	if cb.DriversFile != "" {
+		if allocCfg.Drivers, err = chargeback.LoadDrivers(cb.DriversFile); err != nil {
+			return chargeback.AllocatorConfig{}, err
+		}
+	}
+	if err := allocCfg.CheckDrivers(); err != nil {
+		return chargeback.AllocatorConfig{}, err
+	}
+	if allocCfg.Key == nil || !allocCfg.Key.UsesHierarchy() {
+		return allocCfg, nil
+	}
+	if cfg.Hierarchy.File == "" {
+		return chargeback.AllocatorConfig{}, fmt.Errorf("allocation key %q references org units but hierarchy.file is not set", allocCfg.Key)
+	}
+	h, err := loadHierarchy(cfg)
+	if err != nil {
+		return chargeback.AllocatorConfig{}, err
+	}
+	allocCfg.Hierarchy = h
+	return allocCfg, nil
+}
+
+// loadHierarchy reads the account to org-unit mapping, returning nil when
+// none is configured
+func loadHierarchy(cfg *config.Config) (*hierarchy.Hierarchy, error) {
+	if cfg.Hierarchy.File == "" {
+		return nil, nil
+	}
+	h, err := hierarchy.Load(cfg.Hierarchy.File)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Loaded org hierarchy for %d accounts", h.Len())
+	return h, nil
+}
+
+// runSimulate allocates a month's costs under the current chargeback
+// configuration and each configured scenario, and compares the outcomes
+func runSimulate(ctx context.Context, cfg *config.Config, agg *aggregator.Aggregator, opts options) {
+	if len(cfg.Chargeback.Scenarios) == 0 {
+		log.Fatalf("No allocation scenarios configured under chargeback.scenarios")
+	}
+
+	current, err := chargebackConfig(cfg)
+	if err != nil {
+		log.Fatalf("Invalid chargeback configuration: %v", err)
+	}
+	scenarios := []chargeback.Scenario{{Name: "current", Config: current}}
+	for i, sc := range cfg.Chargeback.Scenarios {
+		if sc.Name == "" {
+			log.Fatalf("Allocation scenario %d has no name", i+1)
+		}
+		allocCfg, err := allocatorConfig(cfg, chargeback.ScenarioConfig(cfg.Chargeback, sc))
+		if err != nil {
+			log.Fatalf("Invalid allocation scenario %q: %v", sc.Name, err)
+		}
+		scenarios = append(scenarios, chargeback.Scenario{Name: sc.Name, Config: allocCfg})
+	}
+
+	name, err := parseMonth(opts.month, opts.fiscal)
+	if err != nil {
+		log.Fatalf("Invalid month: %v", err)
+	}
+	start, end := opts.fiscal.Month(name)
+	month := name.Format("2006-01")
+
+	log.Printf("Simulating %d allocation strategies for %s", len(scenarios), month)
+
+	results, err := agg.Aggregate(ctx, start, end)
+	if err != nil {
+		log.Fatalf("Failed to aggregate costs: %v", err)
+	}
+	logProviderErrors(results.Errors)
+	checkFreshness(results, opts)
+
+	sim := chargeback.Simulate(results.Records(), month, scenarios)
+
+	if err := os.MkdirAll(cfg.Reporter.OutputDir, 0755); err != nil {
+		log.Fatalf("Failed to create output directory: %v", err)
+	}
+	outputPath := filepath.Join(cfg.Reporter.OutputDir, fmt.Sprintf("chargeback-simulation-%s.csv", month))
+	save := sim.SaveCSV
+	if opts.outputFormat == "json" {
+		outputPath = strings.TrimSuffix(outputPath, ".csv") + ".json"
+		save = sim.SaveJSON
+	}
+	if err := save(outputPath); err != nil {
+		log.Fatalf("Failed to write simulation: %v", err)
+	}
+	log.Printf("Simulation report generated: %s", outputPath)
+
+	printSimulation(sim)
+}
+
+func printSimulation(sim *chargeback.Simulation) {
+	separator := strings.Repeat("=", 60)
+	fmt.Println("\n" + separator)
+	fmt.Printf("ALLOCATION SIMULATION %s ($%.2f)\n", sim.Month, sim.TotalCost)
+	fmt.Println(separator)
+
+	fmt.Printf("\n%-20s", "Cost Center")
+	for _, sc := range sim.Scenarios {
+		fmt.Printf(" %14s", sc.Name)
+	}
+	fmt.Println()
+	for _, center := range sim.CostCenters {
+		fmt.Printf("%-20s", center)
+		for i := range sim.Scenarios {
+			fmt.Printf(" %13.1f%%", sim.Share(i, center))
+		}
+		fmt.Println()
+	}
+
+	fmt.Printf("\n%-20s", "Untagged")
+	for _, sc := range sim.Scenarios {
+		fmt.Printf(" %13.1f%%", sc.UntaggedPercent)
+	}
+	fmt.Printf("\n%-20s", "Shared/distributed")
+	for _, sc := range sim.Scenarios {
+		fmt.Printf(" %13.1f%%", sc.SharedPercent)
+	}
+	fmt.Println()
+
+	fmt.Println("\n" + separator)
+}
+
+// parseMonth parses a fiscal month's name, YYYY-MM, defaulting to the
+// previous fiscal month
+func parseMonth(s string, fis *calendar.Fiscal) (time.Time, error) {
+	if s == "" {
+		return fis.MonthOf(fis.Today(time.Now())).AddDate(0, -1, 0), nil
+	}
+	return time.Parse("2006-01", s)
+}
+
+func printChargeback(report *chargeback.Report) {
+	separator := strings.Repeat("=", 60)
+	fmt.Println("\n" + separator)
+	fmt.Printf("CHARGEBACK %s\n", report.Month)
+	fmt.Println(separator)
+
+	fmt.Println()
+	for _, alloc := range report.Allocations {
+		fmt.Printf("  %-25s: $%.2f (direct $%.2f, allocated $%.2f)\n",
+			alloc.CostCenter, alloc.TotalCost, alloc.DirectCost, alloc.AllocatedCost)
+		if alloc.Currency != "" && alloc.Currency != report.BaseCurrency {
+			fmt.Printf("  %-25s  billed as %.2f %s\n", "", alloc.LocalTotal, alloc.Currency)
+		}
+		if alloc.Credits > 0 {
+			fmt.Printf("  %-25s  gross $%.2f, credits -$%.2f\n", "", alloc.GrossCost, alloc.Credits)
+		}
+		if alloc.EmissionsKg > 0 {
+			fmt.Printf("  %-25s  ~%.1f kg CO2e (estimated)\n", "", alloc.EmissionsKg)
+		}
+		if report.PreviousMonth != "" {
+			fmt.Printf("  %-25s  %s vs %s ($%.2f)\n", "", momChange(alloc.Change, alloc.PreviousCost), report.PreviousMonth, alloc.PreviousCost)
+		}
+	}
+	for _, alloc := range report.Ended {
+		fmt.Printf("  %-25s: $0.00 (no spend this month, $%.2f in %s)\n", alloc.CostCenter, alloc.TotalCost, report.PreviousMonth)
+	}
+	fmt.Printf("\nTotal: $%.2f\n", report.TotalCost)
+	if report.PreviousMonth != "" {
+		fmt.Printf("Previous month: $%.2f (%s)\n", report.PreviousTotal, momChange(report.TotalCost-report.PreviousTotal, report.PreviousTotal))
+	}
+
+	var outliers []chargeback.BlendedRate
+	for _, br := range report.Rates {
+		if br.Outlier() {
+			outliers = append(outliers, br)
+		}
+	}
+	if len(outliers) > 0 {
+		fmt.Printf("\nBlended Rate Outliers: %d\n", len(outliers))
+		for _, br := range outliers {
+			fmt.Printf("  - %s / %s: $%.4f per %s (%.1fx median)\n", br.CostCenter, br.Service, br.Rate, br.Unit, br.VsMedian)
+		}
+	}
+
+	if len(report.Overrides) > 0 {
+		fmt.Printf("\nOverrides Applied: %d\n", len(report.Overrides))
+		for _, o := range report.Overrides {
+			fmt.Printf("  - [%s] %s %s/%s $%.2f: %s -> %s\n",
+				o.OverrideID, o.Date.Format("2006-01-02"), o.Cloud, o.Service, o.Amount, o.From, o.To)
+		}
+	}
+
+	fmt.Println("\n" + separator)
+}
+
+// momChange describes a change from the previous month
+func momChange(change, previous float64) string {
+	if previous <= 0 {
+		return fmt.Sprintf("new, %+.2f", change)
+	}
+	return fmt.Sprintf("%+.2f, %+.1f%%", change, change/previous*100)
+}
+
+// runTrend reports each cost center's monthly spend, growth and share of
+// org spend from the history store
+func runTrend(ctx context.Context, cfg *config.Config, opts options) {
+	if opts.history == nil {
+		log.Fatal("Trend mode requires store.enabled")
+	}
+	if opts.months < 2 {
+		log.Fatal("Trend mode requires -months of at least 2")
+	}
+
+	end, err := parseMonth(opts.month, opts.fiscal)
+	if err != nil {
+		log.Fatalf("Invalid month: %v", err)
+	}
+
+	trend, err := costCenterTrend(ctx, cfg, opts, end)
+	if err != nil {
+		log.Fatalf("Failed to build cost center trend: %v", err)
+	}
+
+	if err := os.MkdirAll(cfg.Reporter.OutputDir, 0755); err != nil {
+		log.Fatalf("Failed to create output directory: %v", err)
+	}
+
+	outputPath := filepath.Join(cfg.Reporter.OutputDir, fmt.Sprintf("trend-%s.csv", end.Format("2006-01")))
+	save := trend.SaveCSV
+	if opts.outputFormat == "json" {
+		outputPath = strings.TrimSuffix(outputPath, ".csv") + ".json"
+		save = trend.SaveJSON
+	}
+	if err := save(outputPath); err != nil {
+		log.Fatalf("Failed to write trend report: %v", err)
+	}
+	log.Printf("Trend report generated: %s", outputPath)
+
+	printTrend(trend)
+}
+
+// costCenterTrend allocates each of the --months fiscal months ending with
+// end from the history store and combines them into a trend
+func costCenterTrend(ctx context.Context, cfg *config.Config, opts options, end time.Time) (*chargeback.Trend, error) {
+	allocCfg, err := chargebackConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	reports := make([]*chargeback.Report, 0, opts.months)
+	for i := opts.months - 1; i >= 0; i-- {
+		name := end.AddDate(0, -i, 0)
+		start, end := opts.fiscal.Month(name)
+		records, err := opts.history.QueryRange(ctx, start, end)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name.Format("2006-01"), err)
+		}
+		allocator := chargeback.NewAllocator(allocCfg)
+		reports = append(reports, chargeback.GenerateReport(allocator.Allocate(opts.where.Select(records)), nil, name.Format("2006-01")))
+	}
+
+	return chargeback.BuildTrend(reports), nil
+}
+
+func printTrend(trend *chargeback.Trend) {
+	separator := strings.Repeat("=", 60)
+	fmt.Println("\n" + separator)
+	fmt.Printf("COST CENTER TREND: %s\n", trend.Period())
+	fmt.Println(separator)
+
+	last := len(trend.Months) - 1
+	fmt.Printf("\nOrg spend: $%.2f -> $%.2f\n", trend.Totals[0], trend.Totals[last])
+
+	fmt.Println("\nBy Cost Center (latest month):")
+	for _, c := range trend.Centers {
+		p := c.Points[last]
+		status := ""
+		if c.Status != "" {
+			status = " [" + c.Status + "]"
+		}
+		fmt.Printf("  %-20s: $%12.2f (%5.1f%%) %+7.1f%%/mo%s\n", c.CostCenter, p.Cost, p.Share, c.Growth, status)
+	}
+
+	if fastest := trend.Fastest(); len(fastest) > 0 {
+		fmt.Println("\nFastest Growing:")
+		for i, c := range fastest {
+			fmt.Printf("  %d. %s: %+.1f%% per month\n", i+1, c.CostCenter, c.Growth)
+		}
+	}
+
+	fmt.Println("\n" + separator)
+}
@@ -0,0 +1,19 @@
+package main
+
+import "testing"
+
+func TestMomChange(t *testing.T) {
+	tests := []struct {
+		change, previous float64
+		want             string
+	}{
+		{50, 200, "+50.00, +25.0%"},
+		{-20, 80, "-20.00, -25.0%"},
+		{30, 0, "new, +30.00"},
+	}
+	for _, tt := range tests {
+		if got := momChange(tt.change, tt.previous); got != tt.want {
+			t.Errorf("momChange(%v, %v) = %q, want %q", tt.change, tt.previous, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/providers"
+)
+
+// clouds are the values of --cloud: the built-in providers, and the names
+// of configured plugins
+var clouds = append([]string{"all"}, providers.Names()...)
+
+// newRootCommand builds the command tree. Every command owns its flags:
+// commands that fetch costs read them into an options value of their own,
+// the others into local variables.
+func newRootCommand() *cobra.Command {
+	var configPath string
+	root := &cobra.Command{
+		Use:   "aggregator",
+		Short: "Multi-cloud cost aggregation, chargeback, anomaly detection and budgeting",
+		Long: `Multi-cloud cost aggregation, chargeback, anomaly detection and budgeting.
+
+Run a command with --help for its flags. The earlier "-mode <command>" form
+is still accepted.`,
+		SilenceUsage: true,
+	}
+	root.PersistentFlags().StringVar(&configPath, "config", "configs/config.yaml", "Path to configuration file")
+
+	// fetching builds a command that fetches costs with the provider, period
+	// and output flags, plus the flags it registers itself; run fetches and
+	// runs fn, for commands whose arguments choose another path
+	fetching := func(use, short string, fn fetchRun, flags func(fs *pflag.FlagSet, o *options)) (cmd *cobra.Command, run func()) {
+		f := &fetchFlags{}
+		o := &options{}
+		run = func() { fetch(configPath, f, *o, fn) }
+		cmd = &cobra.Command{
+			Use:   use,
+			Short: short,
+			Args:  cobra.NoArgs,
+			Run:   func(cmd *cobra.Command, args []string) { run() },
+		}
+		addFetchFlags(cmd, f, o)
+		if flags != nil {
+			flags(cmd.Flags(), o)
+		}
+		return cmd, run
+	}
+	command := func(use, short string, fn fetchRun, flags func(fs *pflag.FlagSet, o *options)) *cobra.Command {
+		cmd, _ := fetching(use, short, fn, flags)
+		return cmd
+	}
+	// local builds a command that works on files or the store only
+	local := func(use, short string, run func(cfg *config.Config)) *cobra.Command {
+		return &cobra.Command{
+			Use:   use,
+			Short: short,
+			Args:  cobra.NoArgs,
+			Run:   func(cmd *cobra.Command, args []string) { run(loadConfig(configPath)) },
+		}
+	}
+
+	monthFlag := func(fs *pflag.FlagSet, o *options) {
+		fs.StringVar(&o.month, "month", "", "Month (YYYY-MM), defaults to last month")
+	}
+	monthsFlag := func(fs *pflag.FlagSet, o *options) {
+		fs.IntVar(&o.months, "months", 6, "Number of months ending at --month to include")
+	}
+	monthsFlags := func(fs *pflag.FlagSet, o *options) {
+		monthFlag(fs, o)
+		monthsFlag(fs, o)
+	}
+	dryRunFlag := func(fs *pflag.FlagSet, o *options) {
+		fs.BoolVar(&o.dryRun, "dry-run", false, "Don't send alerts")
+	}
+	aggregateFlags := func(fs *pflag.FlagSet, o *options) {
+		dryRunFlag(fs, o)
+		fs.BoolVar(&o.comparePrior, "compare", true, "Compare against the preceding period of equal length for the report headline")
+		fs.BoolVar(&o.stdout, "stdout", false, "Print the markdown report to stdout instead of writing a file")
+		fs.BoolVar(&o.stream, "stream", false, "Write entries to the CSV report as they arrive instead of holding them in memory, for very large exports; skips anomalies, budgets and the store")
+		fs.StringVar(&o.groupBy, "group-by", "", "Also break costs down by these fields (comma-separated: cloud, account, region, service, resource, tag:<key>)")
+		monthsFlag(fs, o)
+	}
+
+	aggregate := command("aggregate", "Aggregate costs from all clouds and write the cost report (default)", exitZero(runAggregate), aggregateFlags)
+
+	// Without arguments report is the earlier name of aggregate
+	reportCmd, aggregateReport := fetching("report [run <name> | list]", "Write a named report from the config's reports, or list them", exitZero(runAggregate), aggregateFlags)
+	reportCmd.Args = cobra.MaximumNArgs(2)
+	reportCmd.ValidArgs = []string{"run", "list"}
+	reportCmd.Run = func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			aggregateReport()
+			return
+		}
+		// Named reports run their command the same way, once per format
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		runReportCommand(loadConfig(configPath), configPath, args, dryRun)
+	}
+
+	var note string
+	anomalyCmd, detect := fetching("anomaly [list | ack <id> | suppress <id> | reopen <id>]", "Detect cost anomalies, or list and change the status of tracked ones", exitZero(runAnomaly), func(fs *pflag.FlagSet, o *options) {
+		fs.IntVar(&o.days, "days", 7, "Number of recent days to evaluate")
+		fs.BoolVar(&o.explain, "explain", false, "Write the detector's computations for each anomaly and near miss")
+		fs.StringVar(&note, "note", "", "Why an anomaly is acknowledged or suppressed")
+	})
+	anomalyCmd.Args = cobra.MaximumNArgs(2)
+	anomalyCmd.ValidArgs = []string{"list", "ack", "suppress", "reopen"}
+	anomalyCmd.Run = func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			detect()
+			return
+		}
+		// Acknowledging or suppressing an anomaly only touches the store
+		history := openStore(loadConfig(configPath))
+		if history != nil {
+			defer history.Close()
+		}
+		runAnomalyCommand(history, args, note)
+	}
+
+	configCmd := &cobra.Command{Use: "config", Short: "Configuration file tools"}
+	configCmd.AddCommand(&cobra.Command{
+		Use:   "schema",
+		Short: "Print the config file's JSON Schema for editor completion and validation",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			// The schema describes config files, so none is loaded for it
+			runConfigCommand([]string{"schema"})
+		},
+	})
+
+	var before, after string
+	snapshotDiff := local("snapshot-diff", "Explain the differences between two saved JSON reports", func(cfg *config.Config) {
+		runSnapshotDiff(cfg, before, after)
+	})
+	snapshotDiff.Flags().StringVar(&before, "before", "", "Earlier saved JSON report")
+	snapshotDiff.Flags().StringVar(&after, "after", "", "Later saved JSON report")
+
+	var changeScope, changeNote string
+	markChange := local("mark-change", "Record a planned change, suppressing anomalies in its scope for anomaly.cooldown", func(cfg *config.Config) {
+		runMarkChange(cfg, changeScope, changeNote)
+	})
+	markChange.Flags().StringVar(&changeScope, "scope", "", "Changed scope as cloud/account/service (* or omitted parts match anything)")
+	markChange.Flags().StringVar(&changeNote, "note", "", "What changed, e.g. a deploy or ticket reference")
+
+	var label, releaseScope string
+	markRelease := local("mark-release", "Record a release marker", func(cfg *config.Config) {
+		runMarkRelease(cfg, label, releaseScope)
+	})
+	markRelease.Flags().StringVar(&label, "label", "", "Release label, e.g. a version or deploy ID")
+	markRelease.Flags().StringVar(&releaseScope, "scope", "", "Scope the release affects as cloud/account/service")
+
+	var listen string
+	releaseWebhook := local("release-webhook", "Accept release markers over HTTP", func(cfg *config.Config) {
+		runReleaseWebhook(cfg, listen)
+	})
+	releaseWebhook.Flags().StringVar(&listen, "listen", ":8090", "Address to accept release markers on")
+
+	root.AddCommand(
+		aggregate,
+		reportCmd,
+		anomalyCmd,
+		command("chargeback", "Allocate a month's costs to cost centers and write the chargeback report", exitZero(runChargeback), monthFlag),
+		command("simulate", "Compare cost center shares under the chargeback.scenarios allocation strategies", exitZero(runSimulate), monthFlag),
+		command("trend", "Cost center growth and share over recent months (needs the history store)", func(ctx context.Context, cfg *config.Config, agg *aggregator.Aggregator, opts options) int {
+			runTrend(ctx, cfg, opts)
+			return 0
+		}, monthsFlags),
+		command("forecast", "Forecast daily spend and end-of-month and quarter totals", exitZero(runForecast), func(fs *pflag.FlagSet, o *options) {
+			fs.IntVar(&o.horizon, "horizon", 30, "Number of days to project from today")
+		}),
+		command("budget", "Check period-to-date spend against budgets; exits 2 on warnings, 3 when exceeded", runBudgetCheck, dryRunFlag),
+		command("budget-sync", "Compare declared budgets with the clouds' own budgets; exits 2 while drift remains", runBudgetSync, func(fs *pflag.FlagSet, o *options) {
+			dryRunFlag(fs, o)
+			fs.BoolVar(&o.createMissing, "create-missing", false, "Create declared budgets missing from the cloud")
+		}),
+		command("estimate", "Check a change's Infracost estimate against the team's budget headroom", runEstimate, func(fs *pflag.FlagSet, o *options) {
+			fs.StringVar(&o.plan, "plan", "", "Infracost JSON output (breakdown or diff) for the change")
+			fs.StringVar(&o.budget, "budget", "", "Budget name or cost center the change is charged to")
+		}),
+		command("recommend", "Rightsizing and commitment recommendations ranked by monthly savings", func(ctx context.Context, cfg *config.Config, agg *aggregator.Aggregator, opts options) int {
+			runRecommend(ctx, cfg, opts)
+			return 0
+		}, nil),
+		command("commitments", "Commitment coverage and utilization, with savings from new commitments", exitZero(runCommitments), nil),
+		command("diff", "Compare spend between --start/--end and an earlier period", exitZero(runDiff), func(fs *pflag.FlagSet, o *options) {
+			fs.StringVar(&o.against, "against", "previous", "Period to compare with: previous (the preceding window of equal length), month or week (the same days a month or week earlier)")
+		}),
+		command("tag-remediation", "List resources breaking the tag policy, by the cost at stake", exitZero(runTagRemediation), nil),
+		command("tags", "Tag compliance against tag_policy.required, with its trend", exitZero(runTagCompliance), monthsFlags),
+		command("backfill", "Load historical costs into the history store in chunks", exitZero(runBackfill), func(fs *pflag.FlagSet, o *options) {
+			fs.StringVar(&o.backfillFrom, "backfill-from", "", "First day to load (YYYY-MM-DD)")
+			fs.StringVar(&o.backfillTo, "backfill-to", "", "Day to stop before (YYYY-MM-DD), defaults to today")
+		}),
+		command("ingest", "Load each provider's new and restated days into the history store", runIngest, nil),
+		command("reconcile", "Re-fetch recently ingested days, store billing restatements and report the days and services that changed", runReconcile, func(fs *pflag.FlagSet, o *options) {
+			fs.IntVar(&o.window, "days", 0, "Trailing days to re-fetch (default ingest.reconcile.window_days)")
+		}),
+		command("releases", "Cost before vs after each recent release in its scope", exitZero(runReleases), nil),
+		local("prune", "Apply the history store's retention", func(cfg *config.Config) {
+			history := openStore(cfg)
+			if history != nil {
+				defer history.Close()
+			}
+			runPrune(cfg, history)
+		}),
+		snapshotDiff,
+		markChange,
+		markRelease,
+		releaseWebhook,
+		local("validate-config", "Check the configuration and each enabled provider's credentials", func(cfg *config.Config) {
+			runValidateConfig(cfg, configPath)
+		}),
+		configCmd,
+		// Serve runs every job as its own process, which opens its own store
+		local("serve", "Run the serve.jobs commands on their cron schedules", func(cfg *config.Config) {
+			runServe(cfg, configPath)
+		}),
+	)
+	return root
+}
+
+// addFetchFlags adds the flags of commands that fetch costs
+func addFetchFlags(cmd *cobra.Command, f *fetchFlags, o *options) {
+	fs := cmd.Flags()
+	fs.StringVar(&o.cloud, "cloud", "all", "Provider to query: "+strings.Join(clouds, ", ")+" or a plugin's name")
+	fs.StringVar(&f.start, "start", "", "Start date (YYYY-MM-DD), defaults to the start of the current fiscal month")
+	fs.StringVar(&f.end, "end", "", "End date (YYYY-MM-DD), defaults to today")
+	fs.StringVar(&o.outputFormat, "format", "html", "Output format: html, csv, json, markdown, focus, parquet, arrow (chargeback: csv, json, xlsx)")
+	fs.StringVar(&f.maxAge, "max-age", "", "Warn or fail when the freshest data is older than this (e.g. 72h, 3d); overrides config")
+	fs.StringVar(&f.staleAction, "stale-action", "", "Action on stale data: warn or error; overrides config")
+	fs.StringVar(&f.partialAction, "partial-action", "", "Action when a provider fails or times out: warn, or error to exit 4 after writing reports; overrides config")
+	fs.StringVar(&f.accounts, "accounts", "", "Only fetch these accounts (comma-separated); overrides config")
+	fs.StringVar(&f.services, "services", "", "Only fetch these services (comma-separated); overrides config")
+	fs.StringVar(&f.regions, "regions", "", "Only fetch these regions (comma-separated); overrides config")
+	fs.StringVar(&f.tags, "tags", "", "Only fetch costs with these tags (comma-separated key=value); overrides config")
+	fs.StringVar(&f.where, "where", "", `Only count costs matching a filter expression, e.g. 'cloud = "aws" AND tag.env != "prod" AND cost > 100'; overrides config`)
+	fs.BoolVar(&f.refresh, "refresh", false, "Bypass the provider cache and re-fetch, updating it")
+
+	cmd.RegisterFlagCompletionFunc("cloud", cobra.FixedCompletions(clouds, cobra.ShellCompDirectiveNoFileComp))
+	cmd.RegisterFlagCompletionFunc("format", cobra.FixedCompletions(
+		[]string{"html", "csv", "json", "markdown", "focus", "parquet", "arrow", "xlsx"}, cobra.ShellCompDirectiveNoFileComp))
+}
+
+// legacyArgs rewrites the earlier single-flag-set form, "-config x -mode
+// chargeback -month 2024-01", as "chargeback --config x --month 2024-01".
+// Single-dash long flags become double-dash, and a command line starting
+// with a flag runs the --mode command, aggregate by default.
+func legacyArgs(args []string) []string {
+	out := make([]string, 0, len(args)+1)
+	mode := ""
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			out = append(out, args[i:]...)
+			break
+		}
+		if len(arg) > 2 && arg[0] == '-' && arg[1] != '-' && isLetter(arg[1]) {
+			arg = "-" + arg
+		}
+		switch {
+		case arg == "--mode" && i+1 < len(args):
+			mode = args[i+1]
+			i++
+			continue
+		case strings.HasPrefix(arg, "--mode="):
+			mode = strings.TrimPrefix(arg, "--mode=")
+			continue
+		}
+		out = append(out, arg)
+	}
+
+	switch {
+	case mode != "":
+		// Commands with arguments, e.g. "-mode anomaly ack <id>", keep them last
+		return append([]string{mode}, out...)
+	case len(out) == 0 || !strings.HasPrefix(out[0], "-") || isHelp(out[0]):
+		return out // names a command, or asks for help
+	default:
+		return append([]string{"aggregate"}, out...)
+	}
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isHelp(arg string) bool {
+	return arg == "-h" || arg == "--help"
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLegacyArgs(t *testing.T) {
+	tests := []struct {
+		args string
+		want string
+	}{
+		{"", ""},
+		{"--help", "--help"},
+		{"chargeback --month 2024-01", "chargeback --month 2024-01"},
+		{"-config x -mode chargeback -month 2024-01", "chargeback --config x --month 2024-01"},
+		{"--mode=anomaly ack abc", "anomaly ack abc"},
+		{"-mode anomaly -note why ack abc", "anomaly --note why ack abc"},
+		{"-format csv -dry-run", "aggregate --format csv --dry-run"},
+		{"-start 2024-01-01 -- -x", "aggregate --start 2024-01-01 -- -x"},
+	}
+	for _, tt := range tests {
+		got := strings.Join(legacyArgs(strings.Fields(tt.args)), " ")
+		if got != tt.want {
+			t.Errorf("legacyArgs(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+// TestCommandFlags checks each command registers only the flags it reads
+func TestCommandFlags(t *testing.T) {
+	tests := []struct {
+		command string
+		has     []string
+		lacks   []string
+	}{
+		{"aggregate", []string{"cloud", "format", "dry-run", "stream", "group-by", "months"}, []string{"days", "note", "horizon"}},
+		{"anomaly", []string{"cloud", "days", "explain", "note"}, []string{"dry-run", "month", "stream"}},
+		{"chargeback", []string{"cloud", "month"}, []string{"days", "months", "dry-run"}},
+		{"forecast", []string{"horizon"}, []string{"month", "days"}},
+		{"reconcile", []string{"days"}, []string{"explain"}},
+		{"mark-change", []string{"scope", "note"}, []string{"cloud", "format", "label"}},
+		{"mark-release", []string{"scope", "label"}, []string{"note", "cloud"}},
+		{"prune", nil, []string{"cloud", "start"}},
+	}
+	root := newRootCommand()
+	for _, tt := range tests {
+		cmd, _, err := root.Find([]string{tt.command})
+		if err != nil || cmd.Name() != tt.command {
+			t.Fatalf("command %s not found: %v", tt.command, err)
+		}
+		for _, name := range tt.has {
+			if cmd.Flags().Lookup(name) == nil {
+				t.Errorf("%s has no --%s", tt.command, name)
+			}
+		}
+		for _, name := range tt.lacks {
+			if cmd.Flags().Lookup(name) != nil {
+				t.Errorf("%s has --%s", tt.command, name)
+			}
+		}
+	}
+}
+
+// TestCommandFlagsAreSeparate checks setting a flag of one command leaves
+// the same flag of another at its default
+func TestCommandFlagsAreSeparate(t *testing.T) {
+	root := newRootCommand()
+	flags := func(command string, args ...string) func(flag string) string {
+		cmd, _, err := root.Find([]string{command})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := cmd.Flags().Parse(args); err != nil {
+			t.Fatal(err)
+		}
+		return func(flag string) string { return cmd.Flags().Lookup(flag).Value.String() }
+	}
+	aggregate := flags("aggregate", "--format", "csv", "--cloud", "aws")
+	chargeback := flags("chargeback")
+	anomaly := flags("anomaly", "--note", "expected")
+	markChange := flags("mark-change")
+
+	if aggregate("format") != "csv" || aggregate("cloud") != "aws" {
+		t.Fatalf("aggregate flags not set: --format %s --cloud %s", aggregate("format"), aggregate("cloud"))
+	}
+	if chargeback("format") != "html" || chargeback("cloud") != "all" {
+		t.Errorf("chargeback picked up aggregate's flags: --format %s --cloud %s", chargeback("format"), chargeback("cloud"))
+	}
+	if anomaly("note") != "expected" || markChange("note") != "" {
+		t.Errorf("--note: anomaly %q, mark-change %q; want expected and empty", anomaly("note"), markChange("note"))
+	}
+}
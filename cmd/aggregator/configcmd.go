@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+	"github.com/lvonguyen/finops-platform/internal/anomaly"
+	"github.com/lvonguyen/finops-platform/internal/calendar"
+	"github.com/lvonguyen/finops-platform/internal/chargeback"
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/currency"
+	"github.com/lvonguyen/finops-platform/internal/emissions"
+	"github.com/lvonguyen/finops-platform/internal/providers"
+	"github.com/lvonguyen/finops-platform/internal/providers/aws"
+	"github.com/lvonguyen/finops-platform/internal/providers/azure"
+	"github.com/lvonguyen/finops-platform/internal/providers/gcp"
+	"github.com/lvonguyen/finops-platform/internal/providers/oci"
+	"github.com/lvonguyen/finops-platform/internal/providers/plugin"
+	"github.com/lvonguyen/finops-platform/internal/reportdef"
+	"github.com/lvonguyen/finops-platform/internal/tagpolicy"
+)
+
+// runConfigCommand prints the configuration's JSON Schema (schema), for
+// editors to complete and check config files
+func runConfigCommand(args []string) {
+	if len(args) != 1 || args[0] != "schema" {
+		log.Fatal("Usage: aggregator config schema > config.schema.json")
+	}
+	out, err := json.MarshalIndent(config.Schema(), "", "  ")
+	if err != nil {
+		log.Fatalf("Failed to encode schema: %v", err)
+	}
+	fmt.Println(string(out))
+}
+
+// runValidateConfig checks the configuration without fetching costs: each
+// section is parsed as the modes using it would, and enabled providers'
+// credentials are exercised. Every problem is listed, and the run exits
+// non-zero if there were any.
+func runValidateConfig(cfg *config.Config, path string) {
+	fmt.Printf("\nValidating %s\n", path)
+	fmt.Println(strings.Repeat("=", 60))
+
+	failed := 0
+	check := func(name, detail string, err error, hint string) {
+		switch {
+		case err != nil:
+			failed++
+			fmt.Printf("[FAIL] %s: %v\n", name, err)
+			if hint != "" {
+				fmt.Printf("       %s\n", hint)
+			}
+		case detail != "":
+			fmt.Printf("[ok]   %s: %s\n", name, detail)
+		default:
+			fmt.Printf("[ok]   %s\n", name)
+		}
+	}
+
+	check("budgets", fmt.Sprintf("%d defined", len(cfg.Budgets)), aggregator.ValidateBudgets(cfg.Budgets), "")
+	_, err := chargebackConfig(cfg)
+	check("chargeback allocation", "", err, "")
+	for _, sc := range cfg.Chargeback.Scenarios {
+		_, err := allocatorConfig(cfg, chargeback.ScenarioConfig(cfg.Chargeback, sc))
+		check(fmt.Sprintf("allocation scenario %q", sc.Name), "", err, "")
+	}
+	check("chargeback columns", "", chargeback.ValidateColumns(cfg.Chargeback.Columns), "")
+	_, err = chargeback.InvoiceOptionsFrom(cfg.Chargeback.Invoices)
+	check("chargeback invoices", "", err, "")
+	_, err = tagpolicy.FromConfig(cfg.TagPolicy)
+	check("tag policy", "", err, "")
+	var dims []aggregator.Dimension
+	for _, dc := range cfg.Dimensions {
+		dim, err := aggregator.DimensionFrom(dc)
+		check(fmt.Sprintf("dimension %q", dc.Name), "", err, "")
+		if err == nil {
+			dims = append(dims, dim)
+		}
+	}
+	filter, err := aggregator.FilterFrom(cfg.Filter)
+	if err == nil {
+		err = filter.CheckDimensions(dims)
+	}
+	check("cost filter", "", err, "")
+	_, err = aggregator.InternalRulesFrom(cfg.Internal)
+	check("internal charges", "", err, "")
+	_, err = aggregator.ExcludedChargesFrom(cfg.ChargeTypes)
+	check("charge types", "", err, "")
+	_, err = aggregator.ZeroCostPolicyFrom(cfg.ZeroCost)
+	check("zero-cost policy", "", err, "")
+	_, err = aggregator.TagLimitsFrom(cfg.TagLimits)
+	check("tag limits", "", err, "")
+	_, err = aggregator.TimeoutsFrom(cfg.Fetch)
+	check("fetch timeouts", "", err, "")
+	_, err = calendar.FiscalFromConfig(cfg.Calendar)
+	check("calendar", "", err, "")
+	_, err = emissions.FromConfig(cfg.Emissions)
+	check("emissions", "", err, "")
+	_, err = loadHierarchy(cfg)
+	check("hierarchy", "", err, "")
+	err = anomaly.ValidateAlgorithm(cfg.Anomaly.Algorithm)
+	if err == nil {
+		_, err = anomaly.ScopesFrom(cfg.Anomaly.Scopes)
+	}
+	check("anomaly detection", "", err, "")
+	check("reports", fmt.Sprintf("%d defined", len(cfg.Reports)), reportdef.Validate(cfg.Reports), "")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	_, err = currency.Load(ctx, cfg.Currency)
+	check("currency", "", err, "")
+
+	// Credentials are only checked for the providers that are enabled
+	if cfg.AWS.Enabled || cfg.AWS.CUR.Enabled {
+		identity, err := aws.CheckCredentials(ctx, cfg.AWS)
+		check("aws credentials", identity, err,
+			"set AWS_PROFILE or AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY, and the region; with role_arn set, the role must trust this identity")
+	}
+	if cfg.Azure.Enabled {
+		check("azure credentials", "", azure.CheckCredentials(ctx, cfg.Azure),
+			"run az login, or set AZURE_TENANT_ID, AZURE_CLIENT_ID and AZURE_CLIENT_SECRET, or use a managed identity with use_msi")
+	}
+	if cfg.GCP.Enabled {
+		project, err := gcp.CheckCredentials(ctx, cfg.GCP)
+		check("gcp credentials", project, err,
+			"run gcloud auth application-default login, set GOOGLE_APPLICATION_CREDENTIALS, or check wif_config_path")
+	}
+	if cfg.OCI.Enabled {
+		tenancy, err := oci.CheckCredentials(cfg.OCI)
+		check("oci credentials", tenancy, err,
+			"set tenancy_id, user_id, fingerprint and private_key_path, or config_file and profile")
+	}
+	for _, pc := range cfg.Plugins {
+		_, err := plugin.New(pc)
+		if _, builtin := providers.Lookup(pc.Name); err == nil && builtin {
+			err = fmt.Errorf("name %q is taken by a built-in provider", pc.Name)
+		}
+		path := ""
+		if err == nil {
+			path, err = exec.LookPath(pc.Command)
+		}
+		check("plugin "+pc.Name, path, err,
+			"give each plugin a unique name and the path of an executable command")
+	}
+
+	fmt.Println(strings.Repeat("=", 60))
+	if failed > 0 {
+		fmt.Printf("%d check(s) failed\n", failed)
+		os.Exit(1)
+	}
+	fmt.Println("Configuration is valid")
+}
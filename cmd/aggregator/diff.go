@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+	"github.com/lvonguyen/finops-platform/internal/chargeback"
+	"github.com/lvonguyen/finops-platform/internal/compare"
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+// diffTop is how many keys each section of the printed diff lists
+const diffTop = 5
+
+// runDiff compares the -start/-end window with an earlier one and reports
+// the largest and fastest increases and the new and disappeared spend by
+// service, account, cost center and line item
+func runDiff(ctx context.Context, cfg *config.Config, agg *aggregator.Aggregator, opts options) {
+	var prevStart, prevEnd time.Time
+	switch opts.against {
+	case "previous":
+		prevStart, prevEnd = opts.start.Add(-opts.end.Sub(opts.start)), opts.start
+	case "month":
+		prevStart, prevEnd = opts.start.AddDate(0, -1, 0), opts.end.AddDate(0, -1, 0)
+	case "week":
+		prevStart, prevEnd = opts.start.AddDate(0, 0, -7), opts.end.AddDate(0, 0, -7)
+	default:
+		log.Fatalf("Unknown -against period: %s (want previous, month or week)", opts.against)
+	}
+	if !opts.start.Before(opts.end) {
+		log.Fatal("Diff mode requires -start before -end")
+	}
+	if prevEnd.After(opts.start) {
+		log.Fatalf("Diff mode: the %s period (%s to %s) overlaps %s to %s; shorten the window",
+			opts.against, prevStart.Format("2006-01-02"), prevEnd.Format("2006-01-02"), opts.start.Format("2006-01-02"), opts.end.Format("2006-01-02"))
+	}
+
+	previous, err := forecastHistory(ctx, agg, opts, prevStart, prevEnd)
+	if err != nil {
+		log.Fatalf("Failed to load cost history: %v", err)
+	}
+	current, err := forecastHistory(ctx, agg, opts, opts.start, opts.end)
+	if err != nil {
+		log.Fatalf("Failed to load cost history: %v", err)
+	}
+
+	var costCenter func(normalizer.CostRecord) string
+	if allocCfg, err := chargebackConfig(cfg); err != nil {
+		log.Printf("Warning: Cost centers left out of the diff: %v", err)
+	} else {
+		costCenter = chargeback.NewAllocator(allocCfg).CostCenter
+	}
+	diff := compare.DiffPeriods(previous, current, costCenter)
+	diff.PreviousStart, diff.PreviousEnd = prevStart, prevEnd
+	diff.CurrentStart, diff.CurrentEnd = opts.start, opts.end
+
+	if err := os.MkdirAll(cfg.Reporter.OutputDir, 0755); err != nil {
+		log.Fatalf("Failed to create output directory: %v", err)
+	}
+	outputPath := filepath.Join(cfg.Reporter.OutputDir, fmt.Sprintf("cost-diff-%s", time.Now().Format("20060102-150405")))
+	if opts.outputFormat == "json" {
+		outputPath += ".json"
+		err = diff.SaveJSON(outputPath)
+	} else {
+		outputPath += ".csv"
+		err = diff.SaveCSV(outputPath)
+	}
+	if err != nil {
+		log.Fatalf("Failed to write cost diff: %v", err)
+	}
+
+	printDiff(diff)
+	log.Printf("Cost diff written: %s", outputPath)
+}
+
+func printDiff(d *compare.PeriodDiff) {
+	separator := strings.Repeat("=", 60)
+	fmt.Println("\n" + separator)
+	fmt.Printf("COST DIFF %s to %s vs %s to %s\n",
+		d.CurrentStart.Format("2006-01-02"), d.CurrentEnd.AddDate(0, 0, -1).Format("2006-01-02"),
+		d.PreviousStart.Format("2006-01-02"), d.PreviousEnd.AddDate(0, 0, -1).Format("2006-01-02"))
+	fmt.Println(separator)
+	fmt.Printf("\nTotal: $%.2f -> $%.2f (%+.2f, %+.1f%%)\n", d.PreviousTotal, d.CurrentTotal, d.Change, d.PercentChange)
+
+	for _, dim := range d.Dimensions {
+		sections := []struct {
+			title  string
+			deltas []compare.Delta
+		}{
+			{"largest increases", dim.Increases},
+			{"fastest growth", dim.PercentIncreases},
+			{"new", dim.New},
+			{"disappeared", dim.Disappeared},
+		}
+		for _, sec := range sections {
+			if len(sec.deltas) == 0 {
+				continue
+			}
+			fmt.Printf("\nBy %s, %s:\n", strings.ReplaceAll(dim.Dimension, "_", " "), sec.title)
+			for i, delta := range sec.deltas {
+				if i == diffTop {
+					fmt.Printf("  ... %d more\n", len(sec.deltas)-diffTop)
+					break
+				}
+				pct := ""
+				if delta.Previous != 0 && delta.Current != 0 {
+					pct = fmt.Sprintf(" (%+.1f%%)", delta.PercentChange)
+				}
+				fmt.Printf("  %-40s $%10.2f -> $%10.2f %+10.2f%s\n", delta.Key, delta.Previous, delta.Current, delta.Change, pct)
+			}
+		}
+	}
+	fmt.Println(separator)
+}
+
+// runSnapshotDiff explains the differences between two saved runs, printing
+// a summary and writing the entry-level deltas to a CSV file
+func runSnapshotDiff(cfg *config.Config, beforePath, afterPath string) {
+	if beforePath == "" || afterPath == "" {
+		log.Fatal("Snapshot-diff mode requires -before and -after")
+	}
+
+	previous, err := compare.LoadSnapshot(beforePath)
+	if err != nil {
+		log.Fatalf("Failed to load snapshot: %v", err)
+	}
+	current, err := compare.LoadSnapshot(afterPath)
+	if err != nil {
+		log.Fatalf("Failed to load snapshot: %v", err)
+	}
+
+	diff := compare.DiffSnapshots(previous, current)
+
+	if err := os.MkdirAll(cfg.Reporter.OutputDir, 0755); err != nil {
+		log.Fatalf("Failed to create output directory: %v", err)
+	}
+	detailPath := filepath.Join(cfg.Reporter.OutputDir, fmt.Sprintf("snapshot-diff-%s.csv", time.Now().Format("20060102-150405")))
+	if err := diff.SaveCSV(detailPath); err != nil {
+		log.Fatalf("Failed to write snapshot diff: %v", err)
+	}
+
+	printSnapshotDiff(diff)
+	log.Printf("Detailed delta written: %s", detailPath)
+}
+
+func printSnapshotDiff(diff *compare.SnapshotDiff) {
+	separator := strings.Repeat("=", 60)
+	fmt.Println("\n" + separator)
+	fmt.Println("SNAPSHOT DIFF")
+	fmt.Println(separator)
+
+	fmt.Printf("\nData as of: %s -> %s\n", diff.PreviousAsOf.Format("2006-01-02"), diff.CurrentAsOf.Format("2006-01-02"))
+	fmt.Printf("Total: $%.2f -> $%.2f (%+.2f)\n", diff.PreviousTotal, diff.CurrentTotal, diff.Change)
+
+	dimensions := []struct {
+		name   string
+		deltas []compare.Delta
+	}{
+		{"Provider", diff.ByProvider},
+		{"Account", diff.ByAccount},
+		{"Service", diff.ByService},
+		{"Region", diff.ByRegion},
+		{"Date", diff.ByDate},
+	}
+	for _, dim := range dimensions {
+		var changed []compare.Delta
+		for _, d := range dim.deltas {
+			if math.Abs(d.Change) >= 0.005 {
+				changed = append(changed, d)
+			}
+		}
+		if len(changed) == 0 {
+			continue
+		}
+		fmt.Printf("\nBy %s:\n", dim.name)
+		for i, d := range changed {
+			if i == 5 {
+				fmt.Printf("  ... %d more\n", len(changed)-5)
+				break
+			}
+			fmt.Printf("  %-30s: $%.2f -> $%.2f (%+.2f)\n", d.Key, d.Previous, d.Current, d.Change)
+		}
+	}
+
+	counts := make(map[string]int)
+	for _, e := range diff.Entries {
+		counts[e.Status]++
+	}
+	fmt.Printf("\nEntries: %d added, %d removed, %d changed\n",
+		counts[compare.StatusAdded], counts[compare.StatusRemoved], counts[compare.StatusChanged])
+	for i, e := range diff.Entries {
+		if i == 10 {
+			break
+		}
+		fmt.Printf("  [%s] %s %s/%s/%s %s: %+.2f (%s)\n", e.Status, e.Date, e.Provider, e.AccountID, e.Service, e.Region, e.Change, e.Hint)
+	}
+
+	fmt.Println("\n" + separator)
+}
@@ -0,0 +1,549 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+	"github.com/lvonguyen/finops-platform/internal/cache"
+	"github.com/lvonguyen/finops-platform/internal/calendar"
+	"github.com/lvonguyen/finops-platform/internal/chargeback"
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/currency"
+	"github.com/lvonguyen/finops-platform/internal/emissions"
+	"github.com/lvonguyen/finops-platform/internal/notify"
+	"github.com/lvonguyen/finops-platform/internal/providers"
+	"github.com/lvonguyen/finops-platform/internal/providers/plugin"
+	"github.com/lvonguyen/finops-platform/internal/publish"
+	"github.com/lvonguyen/finops-platform/internal/query"
+	"github.com/lvonguyen/finops-platform/internal/resilience"
+	"github.com/lvonguyen/finops-platform/internal/store"
+	"github.com/lvonguyen/finops-platform/internal/telemetry"
+)
+
+// fetchFlags are the period, freshness and filter flags of the commands that
+// fetch costs; --cloud and --format are read straight into options
+type fetchFlags struct {
+	start         string
+	end           string
+	maxAge        string
+	staleAction   string
+	partialAction string
+	accounts      string
+	services      string
+	regions       string
+	tags          string
+	where         string
+	refresh       bool
+}
+
+// fetchRun is the body of a command that fetches costs, returning its exit
+// code
+type fetchRun func(ctx context.Context, cfg *config.Config, agg *aggregator.Aggregator, opts options) int
+
+// exitZero adapts a command body that exits on failure only
+func exitZero(fn func(ctx context.Context, cfg *config.Config, agg *aggregator.Aggregator, opts options)) fetchRun {
+	return func(ctx context.Context, cfg *config.Config, agg *aggregator.Aggregator, opts options) int {
+		fn(ctx, cfg, agg, opts)
+		return 0
+	}
+}
+
+// loadConfig loads the configuration file, exiting when it is invalid
+func loadConfig(path string) *config.Config {
+	cfg, err := config.Load(path)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	return cfg
+}
+
+// openStore opens the history store, nil when it is disabled
+func openStore(cfg *config.Config) store.CostStore {
+	if !cfg.Store.Enabled {
+		return nil
+	}
+	history, err := store.Open(cfg.Store)
+	if err != nil {
+		log.Fatalf("Failed to open history store: %v", err)
+	}
+	return history
+}
+
+// fetch sets up the aggregator, the selected providers and the alert sinks,
+// then runs a command with its options completed from the fetch flags
+func fetch(configPath string, f *fetchFlags, opts options, fn fetchRun) {
+	cfg := loadConfig(configPath)
+
+	// Commands that report their outcome for CI set this; exiting is deferred
+	// first so it runs after every other cleanup
+	exitCode := 0
+	defer func() {
+		if exitCode != 0 {
+			os.Exit(exitCode)
+		}
+	}()
+
+	// Upload the reports this run writes once it is done
+	if cfg.Reporter.Publish.Enabled {
+		publisher, err := publish.New(context.Background(), cfg.Reporter.Publish)
+		if err != nil {
+			log.Fatalf("Invalid publish configuration: %v", err)
+		}
+		defer publishReports(publisher, cfg.Reporter.OutputDir, time.Now().Truncate(time.Second))
+	}
+
+	// Setup the history store
+	history := openStore(cfg)
+	if history != nil {
+		defer history.Close()
+	}
+
+	// Billing days and periods follow the billing timezone and fiscal calendar
+	fiscal, err := calendar.FiscalFromConfig(cfg.Calendar)
+	if err != nil {
+		log.Fatalf("Invalid calendar configuration: %v", err)
+	}
+
+	// Parse dates
+	start, end := parseDates(f.start, f.end, fiscal)
+
+	// Setup context with cancellation
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Handle shutdown signals
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		log.Println("Received shutdown signal, cancelling...")
+		cancel()
+	}()
+
+	shutdownTracing, err := telemetry.Setup(ctx, cfg.Tracing)
+	if err != nil {
+		log.Fatalf("Invalid tracing configuration: %v", err)
+	}
+	defer func() {
+		// Flush spans even when the run was cancelled
+		flushCtx, flushCancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer flushCancel()
+		if err := shutdownTracing(flushCtx); err != nil {
+			log.Printf("Warning: Failed to flush traces: %v", err)
+		}
+	}()
+	defer logCallStats()
+
+	// Initialize aggregator
+	agg := aggregator.New(cfg)
+	for _, adj := range agg.Adjustments() {
+		if err := adj.Validate(); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+	internalRules, err := aggregator.InternalRulesFrom(cfg.Internal)
+	if err != nil {
+		log.Fatalf("Invalid internal charge configuration: %v", err)
+	}
+	agg.SetInternalCharges(internalRules, cfg.Internal.Mode == aggregator.InternalExclude)
+	excludedCharges, err := aggregator.ExcludedChargesFrom(cfg.ChargeTypes)
+	if err != nil {
+		log.Fatalf("Invalid charge type configuration: %v", err)
+	}
+	agg.SetExcludedCharges(excludedCharges)
+	factors, err := emissions.FromConfig(cfg.Emissions)
+	if err != nil {
+		log.Fatalf("Invalid emissions configuration: %v", err)
+	}
+	agg.SetEmissionFactors(factors)
+	orgs, err := loadHierarchy(cfg)
+	if err != nil {
+		log.Fatalf("Invalid hierarchy configuration: %v", err)
+	}
+	agg.SetHierarchy(orgs)
+	if err := aggregator.ValidateBudgets(cfg.Budgets); err != nil {
+		log.Fatalf("Invalid budget configuration: %v", err)
+	}
+	for _, b := range cfg.Budgets {
+		if b.CostCenter == "" {
+			continue
+		}
+		// Cost center budgets follow the chargeback allocation
+		allocCfg, err := chargebackConfig(cfg)
+		if err != nil {
+			log.Fatalf("Invalid chargeback configuration: %v", err)
+		}
+		agg.SetCostCenters(chargeback.NewAllocator(allocCfg).CostCenter)
+		break
+	}
+	var dims []aggregator.Dimension
+	for _, dc := range cfg.Dimensions {
+		dim, err := aggregator.DimensionFrom(dc)
+		if err == nil {
+			err = agg.RegisterDimension(dim)
+		}
+		if err != nil {
+			log.Fatalf("Invalid dimension configuration: %v", err)
+		}
+		dims = append(dims, dim)
+	}
+	// --group-by breaks costs down by record fields like computed dimensions
+	for _, field := range strings.Split(opts.groupBy, ",") {
+		if field == "" || hasDimension(dims, field) {
+			continue
+		}
+		dim, err := aggregator.GroupBy(field)
+		if err == nil {
+			err = agg.RegisterDimension(dim)
+		}
+		if err != nil {
+			log.Fatalf("Invalid --group-by: %v", err)
+		}
+		dims = append(dims, dim)
+	}
+
+	// Narrow provider queries; providers push the filter into their APIs
+	filterCfg := cfg.Filter
+	if f.accounts != "" {
+		filterCfg.Accounts = strings.Split(f.accounts, ",")
+	}
+	if f.services != "" {
+		filterCfg.Services = strings.Split(f.services, ",")
+	}
+	if f.regions != "" {
+		filterCfg.Regions = strings.Split(f.regions, ",")
+	}
+	if f.tags != "" {
+		filterCfg.Tags = strings.Split(f.tags, ",")
+	}
+	if f.where != "" {
+		filterCfg.Where = f.where
+	}
+	filter, err := aggregator.FilterFrom(filterCfg)
+	if err == nil {
+		err = filter.CheckDimensions(dims)
+	}
+	if err != nil {
+		log.Fatalf("Invalid cost filter: %v", err)
+	}
+	if !filter.IsZero() {
+		log.Printf("Filtering costs: %s", filter)
+	}
+	agg.SetFilter(filter)
+	zeroCost, err := aggregator.ZeroCostPolicyFrom(cfg.ZeroCost)
+	if err != nil {
+		log.Fatalf("Invalid zero-cost configuration: %v", err)
+	}
+	agg.SetZeroCost(zeroCost)
+	tagLimits, err := aggregator.TagLimitsFrom(cfg.TagLimits)
+	if err != nil {
+		log.Fatalf("Invalid tag limits configuration: %v", err)
+	}
+	agg.SetTagLimits(tagLimits)
+	conv, err := currency.Load(ctx, cfg.Currency)
+	if err != nil {
+		log.Fatalf("Invalid currency configuration: %v", err)
+	}
+	agg.SetCurrency(conv)
+	timeouts, err := aggregator.TimeoutsFrom(cfg.Fetch)
+	if err != nil {
+		log.Fatalf("Invalid fetch configuration: %v", err)
+	}
+	agg.SetTimeouts(timeouts)
+	agg.SetFiscal(fiscal)
+	register, err := providerRegistrar(cfg, agg, history, f.refresh)
+	if err != nil {
+		log.Fatalf("Invalid cache configuration: %v", err)
+	}
+
+	// Register the selected providers, built-in and plugins; API results
+	// are cached, file imports are not
+	if err := plugin.Register(cfg.Plugins); err != nil {
+		log.Fatalf("Invalid plugin configuration: %v", err)
+	}
+	selected, err := providers.Selected(opts.cloud, cfg)
+	if err != nil {
+		log.Fatalf("Invalid --cloud: %v (have all, %s)", err, strings.Join(providers.Names(), ", "))
+	}
+	for _, sp := range selected {
+		p, query, err := sp.New(ctx, cfg)
+		if err != nil {
+			log.Printf("Warning: Failed to initialize %s provider: %v", sp.Name, err)
+			continue
+		}
+		if query != nil {
+			register(sp.Name, p, query)
+		} else {
+			agg.RegisterProvider(sp.Name, p)
+		}
+	}
+
+	if cfg.Alerting.Queue.Enabled {
+		queueSink, err := notify.NewQueueSink(ctx, cfg.Alerting.Queue)
+		if err != nil {
+			log.Printf("Warning: Failed to initialize alert queue: %v", err)
+		} else {
+			defer queueSink.Close()
+			agg.RegisterSink(queueSink)
+		}
+	}
+
+	if cfg.Alerting.Slack.Enabled {
+		budgetChannels := make(map[string]string)
+		for _, b := range cfg.Budgets {
+			if b.NotifySlack != "" {
+				budgetChannels[b.Name] = b.NotifySlack
+			}
+		}
+		slackSink, err := notify.NewSlackSink(cfg.Alerting.Slack, budgetChannels)
+		if err != nil {
+			log.Printf("Warning: Failed to initialize Slack alerts: %v", err)
+		} else {
+			agg.RegisterSink(slackSink)
+		}
+	}
+
+	if cfg.Alerting.Paging.Enabled {
+		pageAt := make(map[string]int)
+		for _, b := range cfg.Budgets {
+			if b.PageAt != 0 {
+				pageAt[b.Name] = b.PageAt
+			}
+		}
+		pagerSink, err := notify.NewPagerSink(cfg.Alerting.Paging, pageAt)
+		if err != nil {
+			log.Printf("Warning: Failed to initialize paging: %v", err)
+		} else {
+			agg.RegisterSink(pagerSink)
+		}
+	}
+
+	for _, wh := range cfg.Alerting.Webhooks {
+		webhookSink, err := notify.NewWebhookSink(wh)
+		if err != nil {
+			log.Printf("Warning: Failed to initialize webhook: %v", err)
+			continue
+		}
+		agg.RegisterSink(webhookSink)
+	}
+
+	freshness := cfg.Freshness
+	if f.maxAge != "" {
+		freshness.MaxAge = f.maxAge
+	}
+	if f.staleAction != "" {
+		freshness.Action = f.staleAction
+	}
+	maxAgeDuration, err := parseDuration(freshness.MaxAge)
+	if err != nil {
+		log.Fatalf("Invalid max age: %v", err)
+	}
+	partialAction := cfg.Fetch.PartialAction
+	if f.partialAction != "" {
+		partialAction = f.partialAction
+	}
+	if partialAction != "warn" && partialAction != "error" {
+		log.Fatalf("Invalid partial action %q (want warn or error)", partialAction)
+	}
+
+	opts.start, opts.end = start, end
+	opts.maxAge = maxAgeDuration
+	opts.staleAction = freshness.Action
+	opts.history = history
+	opts.fiscal = fiscal
+	opts.where = filter.Where
+	exitCode = fn(ctx, cfg, agg, opts)
+
+	// Results missing a provider fail the run once its reports are written,
+	// unless the command already exits with a code of its own
+	if failures := agg.Failures(); len(failures) > 0 && partialAction == "error" && exitCode == 0 {
+		names := make([]string, len(failures))
+		for i, e := range failures {
+			names[i] = e.Provider
+		}
+		log.Printf("Partial results, missing %s: exiting %d", strings.Join(names, ", "), exitPartial)
+		exitCode = exitPartial
+	}
+}
+
+// exitPartial is the exit code of runs whose results are missing a provider,
+// with fetch.partial_action error
+const exitPartial = 4
+
+// options holds a command's parsed flags and what fetch derives from them
+type options struct {
+	start        time.Time
+	end          time.Time
+	outputFormat string
+	dryRun       bool
+	days         int
+	explain      bool
+	horizon      int
+	month        string
+	months       int
+	backfillFrom string
+	backfillTo   string
+	comparePrior bool
+	maxAge       time.Duration
+	staleAction  string
+	history      store.CostStore // nil when the store is disabled
+
+	createMissing bool
+	plan          string
+	budget        string
+	cloud         string
+	stdout        bool
+	against       string
+	stream        bool
+	window        int // reconcile's trailing days, 0 for the configured window
+	fiscal        *calendar.Fiscal
+	where         *query.Filter // scopes history reads as the cost filter scopes fetches
+	groupBy       string        // record fields to break costs down by, comma-separated
+}
+
+// checkFreshness applies the max-age guard, warning or exiting on stale data
+func checkFreshness(results *aggregator.AggregationResult, opts options) {
+	err := aggregator.CheckFreshness(results, opts.maxAge, time.Now())
+	if err == nil {
+		return
+	}
+	if opts.staleAction == "error" {
+		log.Fatalf("Refusing to report: %v", err)
+	}
+	log.Printf("Warning: %v", err)
+}
+
+// providerRegistrar returns a function registering providers with the
+// aggregator, wrapped in the result cache when it is enabled. The query
+// passed with each provider is its configuration, part of the cache key.
+func providerRegistrar(cfg *config.Config, agg *aggregator.Aggregator, history store.CostStore, refresh bool) (func(name string, p aggregator.CostProvider, query any), error) {
+	if !cfg.Cache.Enabled {
+		return func(name string, p aggregator.CostProvider, query any) {
+			agg.RegisterProvider(name, p)
+		}, nil
+	}
+
+	ttl, err := parseDuration(cfg.Cache.TTL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ttl: %w", err)
+	}
+	var backend cache.Backend
+	switch cfg.Cache.Backend {
+	case "file":
+		if backend, err = cache.NewFileBackend(cfg.Cache.Path); err != nil {
+			return nil, err
+		}
+	case "store":
+		if history == nil {
+			return nil, fmt.Errorf("backend store needs store.enabled")
+		}
+		backend = history
+	default:
+		return nil, fmt.Errorf("unknown backend %q (want file or store)", cfg.Cache.Backend)
+	}
+
+	return func(name string, p aggregator.CostProvider, query any) {
+		cached, err := cache.Wrap(p, backend, ttl, refresh, query)
+		if err != nil {
+			log.Printf("Warning: Not caching %s: %v", name, err)
+			agg.RegisterProvider(name, p)
+			return
+		}
+		agg.RegisterProvider(name, cached)
+	}, nil
+}
+
+// parseDuration parses a duration, also accepting whole days such as "3d"
+func parseDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid day count %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// logCallStats reports providers whose API calls were throttled, retried,
+// failed or rate limited during the run
+func logCallStats() {
+	for _, s := range resilience.AllStats() {
+		if s.Retries == 0 && s.Failures == 0 && s.Rejected == 0 && s.Waited < time.Second {
+			continue
+		}
+		log.Printf("API calls %s: %d attempts, %d retries, %d failed, %d rejected by open circuit (opened %d times), %s waiting for rate limit",
+			s.Provider, s.Calls, s.Retries, s.Failures, s.Rejected, s.BreakerOpens, s.Waited.Round(time.Second))
+	}
+}
+
+// logProviderErrors emits an operational alert for each failed provider,
+// distinguishing expired credentials from ordinary API failures
+func logProviderErrors(errs []aggregator.ProviderError) {
+	for _, e := range errs {
+		excluded := "provider excluded from results"
+		if e.Partial {
+			excluded = "provider failed partway, only the costs read before are included"
+		}
+		switch e.Kind {
+		case aggregator.ErrorKindAuth:
+			log.Printf("ALERT [auth expired] %s: credentials still rejected after refresh, re-authenticate this provider: %s", e.Provider, e.Message)
+		case aggregator.ErrorKindCurrency:
+			log.Printf("ALERT [currency] %s: %s, add its exchange rate to currency.rates: %s", e.Provider, excluded, e.Message)
+		case aggregator.ErrorKindTimeout:
+			log.Printf("ALERT [timeout] %s: %s, raise fetch.timeouts for it if it is just slow: %s", e.Provider, excluded, e.Message)
+		default:
+			log.Printf("ALERT [api error] %s: %s: %s", e.Provider, excluded, e.Message)
+		}
+	}
+}
+
+// hasDimension reports whether a dimension of the name is in dims
+func hasDimension(dims []aggregator.Dimension, name string) bool {
+	for _, d := range dims {
+		if d.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func parseDates(startStr, endStr string, fis *calendar.Fiscal) (time.Time, time.Time) {
+	today := fis.Today(time.Now())
+
+	var start, end time.Time
+	var err error
+
+	if startStr == "" {
+		// Default to the start of the current fiscal month
+		start, _ = fis.Month(fis.MonthOf(today))
+	} else {
+		start, err = time.Parse("2006-01-02", startStr)
+		if err != nil {
+			log.Fatalf("Invalid start date format: %v", err)
+		}
+	}
+
+	if endStr == "" {
+		// Default to today
+		end = today
+	} else {
+		end, err = time.Parse("2006-01-02", endStr)
+		if err != nil {
+			log.Fatalf("Invalid end date format: %v", err)
+		}
+	}
+
+	return start, end
+}
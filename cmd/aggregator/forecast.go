@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+	"github.com/lvonguyen/finops-platform/internal/chargeback"
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/forecast"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+	"github.com/lvonguyen/finops-platform/internal/store"
+)
+
+// runForecast projects spend over the horizon from recent history and shows
+// the base projection next to one adjusted for scheduled events
+func runForecast(ctx context.Context, cfg *config.Config, agg *aggregator.Aggregator, opts options) {
+	events, err := forecast.EventsFromConfig(cfg.Forecast.Events)
+	if err != nil {
+		log.Fatalf("Invalid forecast events: %v", err)
+	}
+
+	now := time.Now().UTC()
+	start := opts.fiscal.Today(now)
+	end := start.AddDate(0, 0, opts.horizon)
+	fitStart := start.AddDate(0, 0, -cfg.Forecast.HistoryDays)
+
+	// History also covers the quarter so far, for end-of-quarter projections
+	historyStart := fitStart
+	if qs, _ := opts.fiscal.Quarter(start); qs.Before(historyStart) {
+		historyStart = qs
+	}
+
+	records, err := forecastHistory(ctx, agg, opts, historyStart, start)
+	if err != nil {
+		log.Fatalf("Failed to load cost history: %v", err)
+	}
+	if len(records) == 0 {
+		log.Fatal("No cost history to forecast from")
+	}
+
+	primary, fallback := cfg.Chargeback.PrimaryTag, cfg.Chargeback.FallbackTag
+	fc, err := forecast.New(records, start, end, forecast.Options{
+		Method:   cfg.Forecast.Method,
+		FitStart: fitStart,
+		Fiscal:   opts.fiscal,
+		CostCenter: func(r normalizer.CostRecord) string {
+			cc := r.Tags[primary]
+			if cc == "" {
+				cc = r.Tags[fallback]
+			}
+			if cc == "" {
+				return chargeback.UntaggedCostCenter
+			}
+			// A split tag value is forecast under its largest share
+			if shares := chargeback.TagShares(cc); shares != nil {
+				return shares[0].CostCenter
+			}
+			return cc
+		},
+	})
+	if err != nil {
+		log.Fatalf("Invalid forecast configuration: %v", err)
+	}
+	if err := fc.Apply(events); err != nil {
+		log.Fatalf("Invalid forecast events: %v", err)
+	}
+
+	if err := os.MkdirAll(cfg.Reporter.OutputDir, 0755); err != nil {
+		log.Fatalf("Failed to create output directory: %v", err)
+	}
+	stamp := now.Format("20060102-150405")
+	if opts.outputFormat == "json" {
+		outputPath := filepath.Join(cfg.Reporter.OutputDir, fmt.Sprintf("forecast-%s.json", stamp))
+		if err := fc.SaveJSON(outputPath); err != nil {
+			log.Fatalf("Failed to write forecast: %v", err)
+		}
+		log.Printf("Forecast generated: %s", outputPath)
+	} else {
+		outputPath := filepath.Join(cfg.Reporter.OutputDir, fmt.Sprintf("forecast-%s.csv", stamp))
+		if err := fc.SaveCSV(outputPath); err != nil {
+			log.Fatalf("Failed to write forecast: %v", err)
+		}
+		log.Printf("Forecast generated: %s", outputPath)
+
+		projectionsPath := filepath.Join(cfg.Reporter.OutputDir, fmt.Sprintf("forecast-%s-projections.csv", stamp))
+		if err := fc.SaveProjectionsCSV(projectionsPath); err != nil {
+			log.Fatalf("Failed to write forecast projections: %v", err)
+		}
+		log.Printf("Period projections: %s", projectionsPath)
+	}
+
+	printForecast(fc)
+}
+
+// forecastHistory reads daily history from the store when it covers the
+// period, otherwise it fetches it from the providers
+func forecastHistory(ctx context.Context, agg *aggregator.Aggregator, opts options, start, end time.Time) ([]normalizer.CostRecord, error) {
+	if opts.history != nil {
+		stored, err := opts.history.QueryRange(ctx, start, end)
+		if err != nil {
+			return nil, err
+		}
+		records := make([]normalizer.CostRecord, 0, len(stored))
+		for _, r := range stored {
+			if !store.IsRollup(r) {
+				records = append(records, r)
+			}
+		}
+		if len(records) > 0 {
+			records = opts.where.Select(records)
+			log.Printf("Forecasting from %d stored records", len(records))
+			return records, nil
+		}
+	}
+
+	log.Printf("Fetching cost history from %s to %s", start.Format("2006-01-02"), end.Format("2006-01-02"))
+	results, err := agg.Aggregate(ctx, start, end)
+	if err != nil {
+		return nil, err
+	}
+	logProviderErrors(results.Errors)
+	checkFreshness(results, opts)
+	return results.Records(), nil
+}
+
+func printForecast(fc *forecast.Forecast) {
+	separator := strings.Repeat("=", 60)
+	fmt.Println("\n" + separator)
+	fmt.Printf("COST FORECAST: %s to %s (%s)\n", fc.Start.Format("2006-01-02"), fc.End.AddDate(0, 0, -1).Format("2006-01-02"), fc.Method)
+	fmt.Println(separator)
+
+	fmt.Printf("\n%-28s %15s %15s\n", "", "Base", "Adjusted")
+	fmt.Printf("%-28s %15s %15s\n", "Total", fmt.Sprintf("$%.2f", fc.BaseTotal), fmt.Sprintf("$%.2f", fc.AdjustedTotal))
+	if days := len(fc.Points); days > 0 {
+		fmt.Printf("%-28s %15s %15s\n", "Daily average",
+			fmt.Sprintf("$%.2f", fc.BaseTotal/float64(days)), fmt.Sprintf("$%.2f", fc.AdjustedTotal/float64(days)))
+	}
+
+	if len(fc.Events) > 0 {
+		fmt.Println("\nScheduled Events:")
+		for _, e := range fc.Events {
+			window := "from " + e.Start.Format("2006-01-02")
+			if !e.End.IsZero() {
+				window += " to " + e.End.Format("2006-01-02")
+			}
+			fmt.Printf("  %s (%s): %+.2f\n", e.Name, window, e.Impact)
+		}
+	}
+
+	for _, period := range []string{"month", "quarter"} {
+		printed := false
+		for _, p := range fc.Projections {
+			if p.Period != period || p.Dimension != forecast.DimensionProvider {
+				continue
+			}
+			if !printed {
+				fmt.Printf("\nEnd of %s (%s) by provider:\n", period, p.PeriodEnd.AddDate(0, 0, -1).Format("2006-01-02"))
+				fmt.Printf("  %-26s %15s %15s %15s\n", "", "Actual", "Forecast", "Projected")
+				printed = true
+			}
+			fmt.Printf("  %-26s %15s %15s %15s\n", p.Key,
+				fmt.Sprintf("$%.2f", p.Actual), fmt.Sprintf("$%.2f", p.Forecast), fmt.Sprintf("$%.2f", p.Total))
+		}
+	}
+
+	fmt.Println("\n" + separator)
+}
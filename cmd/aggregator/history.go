@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+	"github.com/lvonguyen/finops-platform/internal/backfill"
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/ingest"
+	"github.com/lvonguyen/finops-platform/internal/store"
+)
+
+// runPrune applies the retention policy to the history store
+func runPrune(cfg *config.Config, history store.CostStore) {
+	if history == nil {
+		log.Fatal("Prune mode requires store.enabled")
+	}
+
+	policy := store.PolicyFrom(cfg)
+	stats, err := history.Prune(context.Background(), policy, time.Now().UTC())
+	if err != nil {
+		log.Fatalf("Failed to prune history: %v", err)
+	}
+
+	log.Printf("Rolled %d days into %d monthly rollups (%d -> %d records), deleted %d months",
+		stats.DaysRolledUp, stats.MonthsRolledUp, stats.RecordsBefore, stats.RecordsAfter, stats.MonthsDeleted)
+	if stats.Duplicates > 0 {
+		log.Printf("Discarded %d daily records already counted in their month's rollup", stats.Duplicates)
+	}
+}
+
+// runBackfill loads a historical range into the history store in chunks,
+// resuming from the last completed chunk of an interrupted run
+func runBackfill(ctx context.Context, cfg *config.Config, agg *aggregator.Aggregator, opts options) {
+	if opts.history == nil {
+		log.Fatal("Backfill mode requires store.enabled")
+	}
+	if opts.backfillFrom == "" {
+		log.Fatal("Backfill mode requires -backfill-from")
+	}
+
+	from, to := parseDates(opts.backfillFrom, opts.backfillTo, opts.fiscal)
+
+	bfCfg, err := backfill.FromConfig(cfg.Backfill)
+	if err != nil {
+		log.Fatalf("Invalid backfill configuration: %v", err)
+	}
+
+	log.Printf("Backfilling %s to %s in %d-day chunks", from.Format("2006-01-02"), to.Format("2006-01-02"), bfCfg.ChunkDays)
+
+	bf := backfill.New(agg, opts.history, bfCfg, func(p backfill.Progress) {
+		log.Printf("Backfill chunk %d/%d (%.0f%%): %s to %s, %d records, ~%s remaining",
+			p.Chunk, p.Chunks, float64(p.Chunk)/float64(p.Chunks)*100,
+			p.Start.Format("2006-01-02"), p.End.Format("2006-01-02"), p.Records, p.Remaining.Round(time.Second))
+	})
+	if err := bf.Run(ctx, from, to); err != nil {
+		log.Fatalf("Backfill stopped: %v (re-run the same command to resume)", err)
+	}
+	log.Printf("Backfill complete")
+
+	if cfg.Store.Retention.AutoPrune {
+		stats, err := opts.history.Prune(ctx, store.PolicyFrom(cfg), time.Now().UTC())
+		if err != nil {
+			log.Printf("Warning: Failed to prune history: %v", err)
+		} else if stats.MonthsRolledUp > 0 || stats.MonthsDeleted > 0 {
+			log.Printf("Pruned history: %d days rolled into %d months, %d months deleted", stats.DaysRolledUp, stats.MonthsRolledUp, stats.MonthsDeleted)
+		}
+	}
+}
+
+// runIngest loads each provider's days since its last successful ingest,
+// re-fetching the restatement window, into the history store. It returns 1
+// when any provider failed; that provider is retried from its watermark on
+// the next run.
+func runIngest(ctx context.Context, cfg *config.Config, agg *aggregator.Aggregator, opts options) int {
+	if opts.history == nil {
+		log.Fatal("Ingest mode requires store.enabled")
+	}
+
+	now := time.Now().UTC()
+	today := opts.fiscal.Today(now)
+	results := ingest.New(agg, opts.history, ingest.FromConfig(cfg.Ingest)).Run(ctx, today)
+
+	failed := 0
+	for _, r := range results {
+		switch {
+		case r.Err != nil:
+			failed++
+			log.Printf("ALERT [ingest] %s: %v (watermark unchanged, the next run retries)", r.Provider, r.Err)
+		case !r.Start.Before(r.End):
+			log.Printf("Ingest %s: up to date", r.Provider)
+		case r.Watermark.IsZero():
+			log.Printf("Ingest %s: %s to %s (first ingest), %d records", r.Provider, r.Start.Format("2006-01-02"), r.End.Format("2006-01-02"), r.Records)
+		default:
+			log.Printf("Ingest %s: %s to %s (watermark %s), %d records", r.Provider, r.Start.Format("2006-01-02"), r.End.Format("2006-01-02"), r.Watermark.Format("2006-01-02"), r.Records)
+		}
+	}
+
+	if cfg.Store.Retention.AutoPrune && failed < len(results) {
+		stats, err := opts.history.Prune(ctx, store.PolicyFrom(cfg), now)
+		if err != nil {
+			log.Printf("Warning: Failed to prune history: %v", err)
+		} else if stats.MonthsRolledUp > 0 || stats.MonthsDeleted > 0 {
+			log.Printf("Pruned history: %d days rolled into %d months, %d months deleted", stats.DaysRolledUp, stats.MonthsRolledUp, stats.MonthsDeleted)
+		}
+	}
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// runReconcile re-fetches the trailing window of each provider's ingested
+// days, stores what the bills restated and writes the restatement report.
+// It returns 1 when any provider failed.
+func runReconcile(ctx context.Context, cfg *config.Config, agg *aggregator.Aggregator, opts options) int {
+	if opts.history == nil {
+		log.Fatal("Reconcile mode requires store.enabled")
+	}
+
+	icfg := ingest.FromConfig(cfg.Ingest)
+	if opts.window > 0 {
+		icfg.ReconcileDays = opts.window
+	}
+	today := opts.fiscal.Today(time.Now())
+	results := ingest.New(agg, opts.history, icfg).Reconcile(ctx, today)
+
+	if err := os.MkdirAll(cfg.Reporter.OutputDir, 0755); err != nil {
+		log.Fatalf("Failed to create output directory: %v", err)
+	}
+	outputPath := filepath.Join(cfg.Reporter.OutputDir, fmt.Sprintf("restatements-%s.csv", today.Format("2006-01-02")))
+	save := ingest.SaveCSV
+	if opts.outputFormat == "json" {
+		outputPath = strings.TrimSuffix(outputPath, ".csv") + ".json"
+		save = ingest.SaveJSON
+	}
+	if err := save(outputPath, results); err != nil {
+		log.Fatalf("Failed to write restatement report: %v", err)
+	}
+	log.Printf("Restatement report generated: %s", outputPath)
+
+	failed := 0
+	for _, r := range results {
+		switch {
+		case r.Err != nil:
+			failed++
+			log.Printf("ALERT [reconcile] %s: %v", r.Provider, r.Err)
+		case r.End.IsZero():
+			log.Printf("Reconcile %s: not ingested yet", r.Provider)
+		case !r.Start.Before(r.End):
+			log.Printf("Reconcile %s: nothing stored daily in the window", r.Provider)
+		}
+	}
+	printRestatements(results, icfg)
+
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+func printRestatements(results []ingest.Restatement, icfg ingest.Config) {
+	separator := strings.Repeat("=", 60)
+	fmt.Println("\n" + separator)
+	fmt.Println("RESTATEMENTS")
+	fmt.Println(separator)
+
+	for _, r := range results {
+		if r.Err != nil || !r.Start.Before(r.End) {
+			continue
+		}
+		fmt.Printf("\n%s, %s to %s: $%.2f stored -> $%.2f restated (%+.2f)\n", r.Provider,
+			r.Start.Format("2006-01-02"), r.End.AddDate(0, 0, -1).Format("2006-01-02"), r.Stored, r.Restated, r.Restated-r.Stored)
+		if len(r.Changes) == 0 {
+			fmt.Printf("  No changes over $%.2f", icfg.Threshold)
+			if icfg.ThresholdPercent > 0 {
+				fmt.Printf(" and %.1f%%", icfg.ThresholdPercent)
+			}
+			fmt.Println()
+		}
+		for i, c := range r.Changes {
+			if i == 10 {
+				fmt.Printf("  ... %d more in the report\n", len(r.Changes)-i)
+				break
+			}
+			fmt.Printf("  %s %-30s: $%.2f -> $%.2f (%+.2f)\n", c.Date.Format("2006-01-02"), c.Cloud+"/"+c.Service, c.Stored, c.Restated, c.Delta)
+		}
+		if r.Minor > 0 {
+			fmt.Printf("  %d smaller changes\n", r.Minor)
+		}
+	}
+}
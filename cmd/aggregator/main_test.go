@@ -0,0 +1,29 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/providers"
+)
+
+func TestBuiltinProviders(t *testing.T) {
+	if got, want := providers.Names(), []string{"aws", "azure", "csv-import", "focus", "gcp", "oci"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("registered providers = %v, want %v", got, want)
+	}
+
+	// The cloud APIs report being disabled themselves; file imports and
+	// OCI are only selected when enabled
+	selected, err := providers.Selected("all", &config.Config{FOCUS: config.FOCUSConfig{Enabled: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, p := range selected {
+		names = append(names, p.Name)
+	}
+	if want := []string{"aws", "azure", "focus", "gcp"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Selected(all) = %v, want %v", names, want)
+	}
+}
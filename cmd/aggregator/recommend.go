@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+	"github.com/lvonguyen/finops-platform/internal/commitments"
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/providers/aws"
+	"github.com/lvonguyen/finops-platform/internal/providers/azure"
+	"github.com/lvonguyen/finops-platform/internal/providers/gcp"
+	"github.com/lvonguyen/finops-platform/internal/recommendations"
+	"github.com/lvonguyen/finops-platform/internal/reporter"
+)
+
+// runRecommend collects rightsizing recommendations from the clouds'
+// advisors and reports them ranked by monthly savings
+func runRecommend(ctx context.Context, cfg *config.Config, opts options) {
+	recOpts, err := recommendations.OptionsFrom(cfg.Recommendations)
+	if err != nil {
+		log.Fatalf("Invalid recommendations configuration: %v", err)
+	}
+
+	sources := make(map[string]recommendations.Source)
+	if (opts.cloud == "all" || opts.cloud == "aws") && cfg.AWS.Enabled {
+		recommender, err := aws.NewRecommender(ctx, cfg.AWS, cfg.Recommendations)
+		if err != nil {
+			log.Printf("Warning: Failed to create AWS recommender: %v", err)
+		} else {
+			sources["aws"] = recommender
+		}
+	}
+	if (opts.cloud == "all" || opts.cloud == "azure") && cfg.Azure.Enabled {
+		recommender, err := azure.NewRecommender(ctx, cfg.Azure, cfg.Recommendations)
+		if err != nil {
+			log.Printf("Warning: Failed to create Azure recommender: %v", err)
+		} else {
+			sources["azure"] = recommender
+		}
+	}
+	if (opts.cloud == "all" || opts.cloud == "gcp") && cfg.GCP.Enabled {
+		recommender, err := gcp.NewRecommender(ctx, cfg.GCP, cfg.Recommendations)
+		if err != nil {
+			log.Printf("Warning: Failed to create GCP recommender: %v", err)
+		} else {
+			sources["gcp"] = recommender
+		}
+	}
+	if len(sources) == 0 {
+		log.Fatal("Recommend mode requires an enabled provider with recommendations (aws, azure or gcp)")
+	}
+
+	recs, errs := recommendations.Collect(ctx, sources, recOpts)
+	failed := make(map[string]string, len(errs))
+	for name, err := range errs {
+		log.Printf("Warning: %s recommendations incomplete: %v", name, err)
+		failed[name] = err.Error()
+	}
+
+	if err := os.MkdirAll(cfg.Reporter.OutputDir, 0755); err != nil {
+		log.Fatalf("Failed to create output directory: %v", err)
+	}
+	date := time.Now().UTC().Format("20060102")
+	var outputPath string
+	switch opts.outputFormat {
+	case "html":
+		rep := reporter.New(cfg.Reporter)
+		outputPath, err = rep.GenerateRecommendationsHTML(reporter.RecommendationsData{
+			Recommendations: recs,
+			TotalSavings:    recommendations.TotalSavings(recs),
+			Errors:          failed,
+			GeneratedAt:     time.Now(),
+		})
+	case "csv":
+		outputPath = filepath.Join(cfg.Reporter.OutputDir, fmt.Sprintf("recommendations-%s.csv", date))
+		err = recommendations.SaveCSV(outputPath, recs)
+	case "json":
+		outputPath = filepath.Join(cfg.Reporter.OutputDir, fmt.Sprintf("recommendations-%s.json", date))
+		err = recommendations.SaveJSON(outputPath, recs)
+	default:
+		log.Fatalf("Unknown output format for recommend mode: %s (want html, csv or json)", opts.outputFormat)
+	}
+	if err != nil {
+		log.Fatalf("Failed to write recommendations: %v", err)
+	}
+	log.Printf("Recommendations written: %s", outputPath)
+
+	printRecommendations(recs)
+}
+
+func printRecommendations(recs []recommendations.Recommendation) {
+	separator := strings.Repeat("=", 60)
+	fmt.Println("\n" + separator)
+	fmt.Println("RIGHTSIZING RECOMMENDATIONS")
+	fmt.Println(separator)
+
+	fmt.Printf("\nPotential monthly savings: $%.2f across %d resources\n", recommendations.TotalSavings(recs), len(recs))
+
+	if len(recs) > 0 {
+		fmt.Println("\nTop Recommendations:")
+		for i, r := range recs {
+			if i >= 10 {
+				break
+			}
+			change := r.Current + " -> " + r.Recommended
+			switch r.Action {
+			case recommendations.ActionTerminate:
+				change = "terminate " + r.Current
+			case recommendations.ActionPurchase:
+				change = "purchase " + r.Recommended
+			}
+			fmt.Printf("  %2d. %-30s $%10.2f/mo  %s (%s, %s)\n", i+1, r.ResourceID, r.Savings, change, r.Finding, r.Source)
+		}
+	}
+
+	fmt.Println("\n" + separator)
+}
+
+// runCommitments reports commitment coverage and utilization per provider,
+// under-utilized commitments and steady on-demand usage worth committing
+func runCommitments(ctx context.Context, cfg *config.Config, agg *aggregator.Aggregator, opts options) {
+	commitOpts, err := commitments.OptionsFrom(cfg.Commitments)
+	if err != nil {
+		log.Fatalf("Invalid commitments configuration: %v", err)
+	}
+
+	records, err := forecastHistory(ctx, agg, opts, opts.start, opts.end)
+	if err != nil {
+		log.Fatalf("Failed to load cost history: %v", err)
+	}
+	analysis := commitments.Analyze(records, opts.start, opts.end, commitOpts)
+
+	if err := os.MkdirAll(cfg.Reporter.OutputDir, 0755); err != nil {
+		log.Fatalf("Failed to create output directory: %v", err)
+	}
+	base := filepath.Join(cfg.Reporter.OutputDir, fmt.Sprintf("commitments-%s", time.Now().Format("20060102-150405")))
+	if opts.outputFormat == "json" {
+		if err := analysis.SaveJSON(base + ".json"); err != nil {
+			log.Fatalf("Failed to write commitments report: %v", err)
+		}
+		log.Printf("Commitments report generated: %s.json", base)
+	} else {
+		for suffix, save := range map[string]func(string) error{
+			".csv":             analysis.SaveCSV,
+			"-utilization.csv": analysis.SaveCommitmentsCSV,
+			"-uncovered.csv":   analysis.SaveUncoveredCSV,
+		} {
+			if err := save(base + suffix); err != nil {
+				log.Fatalf("Failed to write commitments report: %v", err)
+			}
+		}
+		log.Printf("Commitments report generated: %s.csv (with -utilization.csv and -uncovered.csv)", base)
+	}
+
+	printCommitments(analysis)
+}
+
+func printCommitments(a *commitments.Analysis) {
+	separator := strings.Repeat("=", 60)
+	fmt.Println("\n" + separator)
+	fmt.Printf("COMMITMENTS %s to %s\n", a.Start.Format("2006-01-02"), a.End.AddDate(0, 0, -1).Format("2006-01-02"))
+	fmt.Println(separator)
+
+	fmt.Println("\nBy Provider:")
+	for _, p := range a.Providers {
+		utilization := "n/a"
+		if p.UtilizationKnown {
+			utilization = fmt.Sprintf("%.1f%%", p.UtilizationPercent)
+		}
+		fmt.Printf("  %-10s: coverage %5.1f%% ($%.2f of $%.2f), utilization %s\n",
+			p.Cloud, p.CoveragePercent, p.CoveredCost, p.CoveredCost+p.OnDemandCost, utilization)
+	}
+
+	var under []commitments.Utilization
+	for _, u := range a.Commitments {
+		if u.UnderUtilized {
+			under = append(under, u)
+		}
+	}
+	if len(under) > 0 {
+		fmt.Printf("\nUnder-Utilized Commitments ($%.2f unused):\n", a.UnusedCost)
+		for _, u := range under {
+			fmt.Printf("  %-10s %-40s %5.1f%% used, $%.2f unused\n", u.Cloud, u.ID, u.UtilizationPercent, u.UnusedCost)
+		}
+	}
+
+	if len(a.Uncovered) > 0 {
+		fmt.Printf("\nSteady On-Demand Usage (est. $%.2f/mo savings from new commitments):\n", a.PotentialSavings)
+		for i, s := range a.Uncovered {
+			if i >= 10 {
+				break
+			}
+			fmt.Printf("  %-10s %-14s %-25s %-12s $%8.2f/day baseline, save $%.2f/mo\n",
+				s.Cloud, s.Account, s.Service, s.Region, s.BaselineDaily, s.EstimatedMonthlySavings)
+		}
+	}
+
+	fmt.Println("\n" + separator)
+}
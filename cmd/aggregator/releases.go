@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+	"github.com/lvonguyen/finops-platform/internal/calendar"
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/release"
+)
+
+// runMarkRelease records a release marker so its cost impact is reported
+func runMarkRelease(cfg *config.Config, label, scope string) {
+	marker, err := release.NewMarker(label, scope, time.Now().UTC())
+	if err != nil {
+		log.Fatalf("Invalid release marker: %v", err)
+	}
+	if err := release.Record(cfg.Releases.File, marker); err != nil {
+		log.Fatalf("Failed to record release: %v", err)
+	}
+	log.Printf("Recorded release %s affecting %s", marker.Label, marker.Scope())
+}
+
+// runReleaseWebhook accepts release markers from CI/CD pipelines over HTTP
+func runReleaseWebhook(cfg *config.Config, addr string) {
+	if cfg.Releases.Secret == "" {
+		log.Fatal("The release webhook requires releases.secret to check request signatures")
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/releases", release.Handler(cfg.Releases.File, []byte(cfg.Releases.Secret)))
+	log.Printf("Accepting release markers on %s/releases", addr)
+	server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	if err := server.ListenAndServe(); err != nil {
+		log.Fatalf("Release webhook failed: %v", err)
+	}
+}
+
+// runReleases compares cost before and after each recent release in its
+// scope and flags releases followed by anomalies
+func runReleases(ctx context.Context, cfg *config.Config, agg *aggregator.Aggregator, opts options) {
+	markers, err := release.Load(cfg.Releases.File)
+	if err != nil {
+		log.Fatalf("Failed to load release markers: %v", err)
+	}
+	if len(markers) == 0 {
+		log.Printf("No release markers recorded in %s", cfg.Releases.File)
+		return
+	}
+	cal, err := calendar.FromConfig(cfg.Calendar)
+	if err != nil {
+		log.Fatalf("Invalid calendar configuration: %v", err)
+	}
+
+	// Recent releases, the window before the oldest, and the anomaly baseline
+	window, recent := cfg.Releases.WindowDays, cfg.Releases.RecentDays
+	end := opts.fiscal.Today(time.Now())
+	since := end.AddDate(0, 0, -recent)
+	start := since.AddDate(0, 0, -(window + cfg.Anomaly.LookbackDays))
+
+	log.Printf("Fetching cost from %s to %s for release impact", start.Format("2006-01-02"), end.Format("2006-01-02"))
+	results, err := agg.Aggregate(ctx, start, end)
+	if err != nil {
+		log.Fatalf("Failed to aggregate costs: %v", err)
+	}
+	logProviderErrors(results.Errors)
+	checkFreshness(results, opts)
+	records := results.Records()
+
+	detectOpts := opts
+	detectOpts.days = recent
+	anomalies := newDetector(cfg, cal, detectOpts).Detect(records)
+	impacts := release.Impacts(markers, since, records, anomalies, window, results.AsOf)
+
+	if err := os.MkdirAll(cfg.Reporter.OutputDir, 0755); err != nil {
+		log.Fatalf("Failed to create output directory: %v", err)
+	}
+	outputPath := filepath.Join(cfg.Reporter.OutputDir, fmt.Sprintf("releases-%s.csv", end.Format("2006-01-02")))
+	save := release.SaveCSV
+	if opts.outputFormat == "json" {
+		outputPath = strings.TrimSuffix(outputPath, ".csv") + ".json"
+		save = release.SaveJSON
+	}
+	if err := save(outputPath, impacts); err != nil {
+		log.Fatalf("Failed to write release impact: %v", err)
+	}
+	log.Printf("Release impact report generated: %s", outputPath)
+
+	printReleases(impacts, window)
+}
+
+// releaseImpacts computes the impact of recent releases on the aggregated
+// period for the HTML report, without anomaly correlation
+func releaseImpacts(cfg *config.Config, opts options, results *aggregator.AggregationResult) []release.Impact {
+	markers, err := release.Load(cfg.Releases.File)
+	if err != nil {
+		log.Printf("Warning: Failed to load release markers: %v", err)
+		return nil
+	}
+	since := opts.fiscal.Today(time.Now()).AddDate(0, 0, -cfg.Releases.RecentDays)
+	return release.Impacts(markers, since, results.Records(), nil, cfg.Releases.WindowDays, results.AsOf)
+}
+
+func printReleases(impacts []release.Impact, window int) {
+	separator := strings.Repeat("=", 60)
+	fmt.Println("\n" + separator)
+	fmt.Printf("COST IMPACT OF RECENT RELEASES (%d days either side)\n", window)
+	fmt.Println(separator)
+
+	if len(impacts) == 0 {
+		fmt.Println("\nNo recent releases")
+	}
+	for _, i := range impacts {
+		fmt.Printf("\n%s %s (%s)\n", i.At.Format("2006-01-02 15:04"), i.Label, i.Scope())
+		fmt.Printf("  $%.2f/day before (%d days) -> $%.2f/day after (%d days): %+.2f/day (%+.1f%%)\n",
+			i.PreDaily, i.PreDays, i.PostDaily, i.PostDays, i.Delta, i.DeltaPercent)
+		if i.Correlated() {
+			fmt.Printf("  ! %d anomalies in scope after the release\n", i.Anomalies)
+		}
+		if len(i.Overlaps) > 0 {
+			fmt.Printf("  Windows clipped by overlapping releases: %s\n", strings.Join(i.Overlaps, ", "))
+		}
+		if i.Note != "" {
+			fmt.Printf("  Inconclusive: %s\n", i.Note)
+		}
+	}
+
+	fmt.Println("\n" + separator)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+	"github.com/lvonguyen/finops-platform/internal/calendar"
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/release"
+)
+
+func TestReleaseImpactsBillingDay(t *testing.T) {
+	// Twelve hours behind UTC, the billing day's start is never after the
+	// host clock's, so a clock-relative window would miss the first release
+	fis, err := calendar.FiscalFromConfig(config.CalendarConfig{Timezone: "Etc/GMT+12"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	since := fis.Today(time.Now()).AddDate(0, 0, -3)
+
+	path := filepath.Join(t.TempDir(), "releases.json")
+	for label, at := range map[string]time.Time{"first": since, "earlier": since.Add(-time.Second)} {
+		m, err := release.NewMarker(label, "", at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := release.Record(path, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := &config.Config{Releases: config.ReleasesConfig{File: path, WindowDays: 2, RecentDays: 3}}
+	results := &aggregator.AggregationResult{
+		Entries: []aggregator.CostEntry{{Provider: "aws", Date: since.AddDate(0, 0, -5), Cost: 1}},
+		AsOf:    since.AddDate(0, 0, 3),
+	}
+	impacts := releaseImpacts(cfg, options{fiscal: fis}, results)
+	if len(impacts) != 1 || impacts[0].Label != "first" {
+		t.Errorf("impacts = %+v, want the release at the start of the billing day", impacts)
+	}
+}
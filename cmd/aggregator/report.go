@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/calendar"
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/notify"
+	"github.com/lvonguyen/finops-platform/internal/reportdef"
+)
+
+// runReportCommand lists the named reports, or writes one. Each format is a
+// run of the report's command in its own process, as serve runs its jobs,
+// and the reports written are then emailed to the report's recipients.
+func runReportCommand(cfg *config.Config, configPath string, args []string, dryRun bool) {
+	if err := reportdef.Validate(cfg.Reports); err != nil {
+		log.Fatalf("Invalid report configuration: %v", err)
+	}
+	switch {
+	case args[0] == "list" && len(args) == 1:
+		printReports(cfg.Reports)
+		return
+	case args[0] != "run" || len(args) != 2:
+		log.Fatalf("Usage: report run <name>, or report list")
+	}
+	def, err := reportdef.Find(cfg.Reports, args[1])
+	if err != nil {
+		log.Fatal(err)
+	}
+	fiscal, err := calendar.FiscalFromConfig(cfg.Calendar)
+	if err != nil {
+		log.Fatalf("Invalid calendar configuration: %v", err)
+	}
+	runs, err := reportdef.Runs(def, fiscal, fiscal.Today(time.Now()))
+	if err != nil {
+		log.Fatalf("Invalid report configuration: %v", err)
+	}
+	self, err := os.Executable()
+	if err != nil {
+		log.Fatalf("Cannot locate the aggregator binary: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	since := time.Now().Truncate(time.Second)
+	failed := 0
+	for _, r := range runs {
+		log.Printf("Report %s (%s): %s", def.Name, r.Format, strings.Join(r.Args, " "))
+		cmd := exec.CommandContext(ctx, self, append([]string{r.Args[0], "--config", configPath}, r.Args[1:]...)...)
+		cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+		cmd.Cancel = func() error { return cmd.Process.Signal(os.Interrupt) }
+		cmd.WaitDelay = time.Minute
+		if err := cmd.Run(); err != nil {
+			log.Printf("Warning: Report %s (%s) failed: %v", def.Name, r.Format, err)
+			failed++
+		}
+	}
+
+	written, err := reportdef.Written(cfg.Reporter.OutputDir, since)
+	if err != nil {
+		log.Printf("Warning: Failed to list the reports written: %v", err)
+	}
+	switch {
+	case len(def.Recipients) == 0 || len(written) == 0:
+	case dryRun:
+		log.Printf("Dry run: not emailing %d reports to %d recipients", len(written), len(def.Recipients))
+	default:
+		sendReport(ctx, cfg, def, written)
+	}
+	if failed > 0 {
+		log.Printf("%d of %d runs of report %s failed", failed, len(runs), def.Name)
+		os.Exit(1)
+	}
+}
+
+// sendReport emails the files a named report wrote to its recipients, over
+// the digest's SMTP server
+func sendReport(ctx context.Context, cfg *config.Config, def config.ReportDefinition, files []string) {
+	email := cfg.Alerting.Email
+	email.Recipients = def.Recipients
+	digest, err := notify.NewEmailDigest(email)
+	if err != nil {
+		log.Printf("Warning: Report email not sent: %v", err)
+		return
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "The %s report was written:\n\n", def.Name)
+	for _, file := range files {
+		fmt.Fprintf(&body, "  %s\n", file)
+	}
+	if err := digest.Send(ctx, "Cloud cost report: "+def.Name, body.String()); err != nil {
+		log.Printf("Warning: %v", err)
+		return
+	}
+	log.Printf("Report email sent to %d recipients", len(def.Recipients))
+}
+
+func printReports(defs []config.ReportDefinition) {
+	fmt.Println()
+	fmt.Println("Reports")
+	fmt.Println(strings.Repeat("=", 60))
+	if len(defs) == 0 {
+		fmt.Println("No reports are defined")
+		return
+	}
+	for _, def := range defs {
+		period := def.Period
+		if period == "" {
+			period = "default period"
+		}
+		fmt.Printf("%-20s %s, %s", def.Name, def.Command, period)
+		if len(def.Formats) > 0 {
+			fmt.Printf(", %s", strings.Join(def.Formats, "/"))
+		}
+		if def.Schedule != "" {
+			fmt.Printf(", at %q", def.Schedule)
+		}
+		if len(def.Recipients) > 0 {
+			fmt.Printf(", to %s", strings.Join(def.Recipients, ", "))
+		}
+		fmt.Println()
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/anomaly"
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/grafana"
+	"github.com/lvonguyen/finops-platform/internal/portal"
+	"github.com/lvonguyen/finops-platform/internal/release"
+	"github.com/lvonguyen/finops-platform/internal/reportdef"
+	"github.com/lvonguyen/finops-platform/internal/schedule"
+	"github.com/lvonguyen/finops-platform/internal/store"
+	"github.com/lvonguyen/finops-platform/internal/unitcost"
+)
+
+// schedulableModes are the run modes serve mode can schedule
+var schedulableModes = map[string]bool{
+	"aggregate": true, "anomaly": true, "chargeback": true, "simulate": true, "trend": true,
+	"forecast": true, "tag-remediation": true, "tags": true, "backfill": true, "ingest": true, "reconcile": true, "releases": true, "prune": true, "report": true,
+}
+
+// runServe runs the configured jobs on their cron schedules until a shutdown
+// signal, then lets running jobs finish within the shutdown timeout
+func runServe(cfg *config.Config, configPath string) {
+	if err := reportdef.Validate(cfg.Reports); err != nil {
+		log.Fatalf("Invalid report configuration: %v", err)
+	}
+	jobs := make([]schedule.Job, 0, len(cfg.Serve.Jobs)+len(cfg.Reports))
+	for _, j := range cfg.Serve.Jobs {
+		if !schedulableModes[j.Mode] {
+			log.Fatalf("Invalid serve configuration: job %q: mode %q cannot be scheduled", j.Name, j.Mode)
+		}
+		timeout, err := parseDuration(j.Timeout)
+		if err != nil {
+			log.Fatalf("Invalid serve configuration: job %q: invalid timeout: %v", j.Name, err)
+		}
+		jobs = append(jobs, schedule.Job{Name: j.Name, Mode: j.Mode, Schedule: j.Schedule, Timeout: timeout, Args: j.Args})
+	}
+	// Named reports with a schedule run as jobs of their own
+	for _, def := range cfg.Reports {
+		if def.Schedule != "" {
+			jobs = append(jobs, schedule.Job{Name: "report " + def.Name, Mode: "report", Schedule: def.Schedule, Args: []string{"run", def.Name}})
+		}
+	}
+	if len(jobs) == 0 {
+		log.Fatal("Serve mode requires serve.jobs or scheduled reports")
+	}
+	shutdownTimeout, err := parseDuration(cfg.Serve.ShutdownTimeout)
+	if err != nil {
+		log.Fatalf("Invalid serve configuration: invalid shutdown timeout: %v", err)
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		log.Fatalf("Cannot locate the aggregator binary: %v", err)
+	}
+	sched, err := schedule.New(jobs, func(ctx context.Context, job schedule.Job) error {
+		// Each run is a separate process so a failing run cannot take the
+		// server down; timeouts interrupt it like Ctrl-C
+		args := append([]string{job.Mode, "--config", configPath}, job.Args...)
+		cmd := exec.CommandContext(ctx, self, args...)
+		cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+		cmd.Cancel = func() error { return cmd.Process.Signal(os.Interrupt) }
+		cmd.WaitDelay = time.Minute
+		return cmd.Run()
+	})
+	if err != nil {
+		log.Fatalf("Invalid serve configuration: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	var server *http.Server
+	if cfg.Serve.Listen != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/healthz", sched.Handler())
+		if cfg.Releases.Secret != "" {
+			mux.Handle("/releases", release.Handler(cfg.Releases.File, []byte(cfg.Releases.Secret)))
+			log.Printf("Release markers on %s/releases", cfg.Serve.Listen)
+		} else {
+			log.Printf("Warning: releases.secret is not set; release markers are not accepted on /releases")
+		}
+		if cfg.Store.Enabled {
+			history, err := store.Open(cfg.Store)
+			if err != nil {
+				log.Fatalf("Failed to open history store: %v", err)
+			}
+			defer history.Close()
+			mux.Handle("/anomalies", anomaly.Handler(history, cfg.Serve.AnomalyTokens))
+			if len(cfg.Serve.AnomalyTokens) == 0 {
+				log.Printf("Anomaly states on %s/anomalies (read-only: no serve.anomaly_tokens)", cfg.Serve.Listen)
+			} else {
+				log.Printf("Anomaly states on %s/anomalies", cfg.Serve.Listen)
+			}
+			mux.Handle("/grafana/", http.StripPrefix("/grafana", grafana.Handler(history)))
+			log.Printf("Grafana JSON datasource on %s/grafana", cfg.Serve.Listen)
+			if len(cfg.UnitCost.Metrics) > 0 {
+				calc, err := unitCalculator(cfg)
+				if err != nil {
+					log.Fatalf("Invalid unit cost configuration: %v", err)
+				}
+				mux.Handle("/unitcost", unitcost.Handler(history, calc))
+				log.Printf("Unit costs on %s/unitcost", cfg.Serve.Listen)
+			}
+			if cfg.Serve.Portal {
+				allocCfg, err := chargebackConfig(cfg)
+				if err != nil {
+					log.Fatalf("Invalid chargeback configuration: %v", err)
+				}
+				mux.Handle("/", portal.New(history, allocCfg))
+				log.Printf("Showback portal on %s/", cfg.Serve.Listen)
+			}
+		} else if cfg.Serve.Portal {
+			log.Printf("Warning: serve.portal needs store.enabled; the showback portal is not served")
+		}
+		server = &http.Server{Addr: cfg.Serve.Listen, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("Serve listener failed: %v", err)
+			}
+		}()
+		log.Printf("Job status on %s/healthz", cfg.Serve.Listen)
+	}
+
+	sched.Start()
+	for _, st := range sched.Statuses() {
+		log.Printf("Scheduled %s (%s) at %q, next run %s", st.Name, st.Mode, st.Schedule, st.Next.Format("2006-01-02 15:04 MST"))
+	}
+
+	<-ctx.Done()
+	log.Printf("Received shutdown signal, waiting up to %s for running jobs...", shutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if server != nil {
+		server.Shutdown(shutdownCtx)
+	}
+	if err := sched.Stop(shutdownCtx); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	log.Println("Stopped")
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+	"github.com/lvonguyen/finops-platform/internal/tagpolicy"
+)
+
+// runTagRemediation checks resources against the tag policy and writes a
+// per-resource remediation list sorted by the cost at stake
+func runTagRemediation(ctx context.Context, cfg *config.Config, agg *aggregator.Aggregator, opts options) {
+	policy, err := tagpolicy.FromConfig(cfg.TagPolicy)
+	if err != nil {
+		log.Fatalf("Invalid tag policy: %v", err)
+	}
+	if len(policy.Rules) == 0 {
+		log.Fatal("No required tags configured under tag_policy.required")
+	}
+
+	log.Printf("Checking tag policy from %s to %s", opts.start.Format("2006-01-02"), opts.end.Format("2006-01-02"))
+
+	results, err := agg.Aggregate(ctx, opts.start, opts.end)
+	if err != nil {
+		log.Fatalf("Failed to aggregate costs: %v", err)
+	}
+	logProviderErrors(results.Errors)
+	checkFreshness(results, opts)
+
+	violations := policy.Evaluate(results.Records())
+
+	if err := os.MkdirAll(cfg.Reporter.OutputDir, 0755); err != nil {
+		log.Fatalf("Failed to create output directory: %v", err)
+	}
+	outputPath := filepath.Join(cfg.Reporter.OutputDir, fmt.Sprintf("tag-remediation-%s.csv", time.Now().Format("20060102-150405")))
+	if err := tagpolicy.SaveCSV(outputPath, violations); err != nil {
+		log.Fatalf("Failed to write remediation list: %v", err)
+	}
+	log.Printf("Remediation list generated: %s", outputPath)
+
+	printTagViolations(violations)
+}
+
+func printTagViolations(violations []tagpolicy.Violation) {
+	separator := strings.Repeat("=", 60)
+	fmt.Println("\n" + separator)
+	fmt.Println("TAG POLICY VIOLATIONS")
+	fmt.Println(separator)
+
+	counts := make(map[tagpolicy.Severity]int)
+	atStake := make(map[string]float64)
+	for _, v := range violations {
+		counts[v.Severity]++
+		atStake[v.Cloud+"|"+v.Account+"|"+v.Service+"|"+v.Resource] = v.Cost
+	}
+	var total float64
+	for _, cost := range atStake {
+		total += cost
+	}
+
+	fmt.Printf("\nMissing required tags: %d\n", counts[tagpolicy.SeverityMissing])
+	fmt.Printf("Invalid tag values:    %d\n", counts[tagpolicy.SeverityInvalid])
+	fmt.Printf("Resources affected:    %d ($%.2f at stake)\n", len(atStake), total)
+
+	if len(violations) > 0 {
+		fmt.Println("\nTop Violations:")
+	}
+	for i, v := range violations {
+		if i == 10 {
+			break
+		}
+		resource := v.Resource
+		if resource == "" {
+			resource = "(no resource id)"
+		}
+		fmt.Printf("  %d. [%s] %s/%s %s: tag %s ($%.2f)\n", i+1, v.Severity, v.Cloud, v.Account, resource, v.Key, v.Cost)
+	}
+
+	fmt.Println("\n" + separator)
+}
+
+// runTagCompliance reports the share of spend missing required tags by
+// account and service, the most expensive non-compliant resources, and the
+// compliance trend: monthly over -months from the history store when it is
+// enabled, otherwise daily over the -start to -end period
+func runTagCompliance(ctx context.Context, cfg *config.Config, agg *aggregator.Aggregator, opts options) {
+	policy, err := tagpolicy.FromConfig(cfg.TagPolicy)
+	if err != nil {
+		log.Fatalf("Invalid tag policy: %v", err)
+	}
+	if len(policy.Rules) == 0 {
+		log.Fatal("No required tags configured under tag_policy.required")
+	}
+
+	start, end := opts.start, opts.end
+	granularity := tagpolicy.Daily
+	var records []normalizer.CostRecord
+	if opts.history != nil {
+		last, err := parseMonth(opts.month, opts.fiscal)
+		if err != nil {
+			log.Fatalf("Invalid month: %v", err)
+		}
+		start, _ = opts.fiscal.Month(last.AddDate(0, 1-max(opts.months, 1), 0))
+		_, end = opts.fiscal.Month(last)
+		granularity = tagpolicy.Monthly
+
+		log.Printf("Checking tag compliance from %s to %s in the history store", start.Format("2006-01"), last.Format("2006-01"))
+		if records, err = opts.history.QueryRange(ctx, start, end); err != nil {
+			log.Fatalf("Failed to read cost history: %v", err)
+		}
+		records = opts.where.Select(records)
+	} else {
+		log.Printf("Checking tag compliance from %s to %s", start.Format("2006-01-02"), end.Format("2006-01-02"))
+		results, err := agg.Aggregate(ctx, start, end)
+		if err != nil {
+			log.Fatalf("Failed to aggregate costs: %v", err)
+		}
+		logProviderErrors(results.Errors)
+		checkFreshness(results, opts)
+		records = results.Records()
+	}
+
+	compliance := policy.Compliance(records, start, end, granularity, opts.fiscal, cfg.TagPolicy.Top)
+
+	if err := os.MkdirAll(cfg.Reporter.OutputDir, 0755); err != nil {
+		log.Fatalf("Failed to create output directory: %v", err)
+	}
+	base := filepath.Join(cfg.Reporter.OutputDir, fmt.Sprintf("tag-compliance-%s", time.Now().Format("20060102-150405")))
+	if opts.outputFormat == "json" {
+		if err := compliance.SaveJSON(base + ".json"); err != nil {
+			log.Fatalf("Failed to write tag compliance report: %v", err)
+		}
+		log.Printf("Tag compliance report generated: %s.json", base)
+	} else {
+		for suffix, save := range map[string]func(string) error{
+			".csv":           compliance.SaveCSV,
+			"-resources.csv": compliance.SaveResourcesCSV,
+			"-trend.csv":     compliance.SaveTrendCSV,
+		} {
+			if err := save(base + suffix); err != nil {
+				log.Fatalf("Failed to write tag compliance report: %v", err)
+			}
+		}
+		log.Printf("Tag compliance report generated: %s.csv (with -resources.csv and -trend.csv)", base)
+	}
+
+	printTagCompliance(compliance)
+}
+
+func printTagCompliance(c *tagpolicy.Compliance) {
+	separator := strings.Repeat("=", 60)
+	fmt.Println("\n" + separator)
+	fmt.Printf("TAG COMPLIANCE %s to %s\n", c.Start.Format("2006-01-02"), c.End.AddDate(0, 0, -1).Format("2006-01-02"))
+	fmt.Println(separator)
+
+	fmt.Printf("\nNon-compliant spend: $%.2f of $%.2f (%.1f%%)\n", c.NonCompliantCost, c.TotalCost, c.NonCompliantPercent)
+
+	fmt.Println("\nBy Required Tag:")
+	for _, t := range c.ByTag {
+		fmt.Printf("  %-20s: %5.1f%% missing ($%.2f), $%.2f invalid\n", t.Key, t.MissingPercent, t.MissingCost, t.InvalidCost)
+	}
+
+	if len(c.ByScope) > 0 && c.ByScope[0].NonCompliantCost > 0 {
+		fmt.Println("\nLeast Compliant Accounts/Services:")
+	}
+	for i, s := range c.ByScope {
+		if i == 10 || s.NonCompliantCost <= 0 {
+			break
+		}
+		fmt.Printf("  %d. %s/%s %s: $%.2f (%.1f%% of its spend)\n", i+1, s.Cloud, s.Account, s.Service, s.NonCompliantCost, s.Percent)
+	}
+
+	if len(c.Resources) > 0 {
+		fmt.Println("\nTop Non-Compliant Resources:")
+	}
+	for i, r := range c.Resources {
+		if i == 10 {
+			break
+		}
+		resource := r.Resource
+		if resource == "" {
+			resource = "(no resource id)"
+		}
+		fmt.Printf("  %d. %s/%s %s: $%.2f, missing [%s]", i+1, r.Cloud, r.Account, resource, r.Cost, strings.Join(r.Missing, ", "))
+		if len(r.Invalid) > 0 {
+			fmt.Printf(", invalid [%s]", strings.Join(r.Invalid, ", "))
+		}
+		fmt.Println()
+	}
+
+	if len(c.Trend) > 1 {
+		fmt.Println("\nTrend:")
+		for _, p := range c.Trend {
+			fmt.Printf("  %s: %5.1f%% non-compliant ($%.2f)\n", p.Period, p.Percent, p.NonCompliantCost)
+		}
+	}
+
+	fmt.Println("\n" + separator)
+}
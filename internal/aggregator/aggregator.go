@@ -3,13 +3,27 @@ package aggregator
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/lvonguyen/finops-platform/internal/anomaly"
+	"github.com/lvonguyen/finops-platform/internal/calendar"
 	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/currency"
+	"github.com/lvonguyen/finops-platform/internal/emissions"
+	"github.com/lvonguyen/finops-platform/internal/hierarchy"
+	"github.com/lvonguyen/finops-platform/internal/money"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+	"github.com/lvonguyen/finops-platform/internal/notify"
+	"github.com/lvonguyen/finops-platform/internal/telemetry"
 )
 
 // CostProvider defines the interface for cloud cost providers
@@ -19,19 +33,78 @@ type CostProvider interface {
 	Name() string
 }
 
+// ErrAuthExpired marks provider errors caused by expired or rejected
+// credentials, as opposed to ordinary API failures
+var ErrAuthExpired = errors.New("credentials expired or rejected")
+
+// ErrStaleData is returned when the freshest cost data is older than allowed
+var ErrStaleData = errors.New("cost data is stale")
+
+// CredentialRefresher is implemented by providers that can re-acquire
+// credentials (re-assume a role, fetch a new token) after ErrAuthExpired
+type CredentialRefresher interface {
+	RefreshCredentials(ctx context.Context) error
+}
+
+// BudgetWriter is implemented by providers that can create budgets in the
+// cloud, for budget sync
+type BudgetWriter interface {
+	CreateBudget(ctx context.Context, b config.Budget) error
+}
+
+// Provider error kinds
+const (
+	ErrorKindAuth     = "auth"
+	ErrorKindAPI      = "api"
+	ErrorKindCurrency = "currency" // costs in a currency with no exchange rate
+	ErrorKindTimeout  = "timeout"  // no response within the provider's fetch timeout
+)
+
+// ProviderError records a provider that failed during aggregation
+type ProviderError struct {
+	Provider string `json:"provider"`
+	Kind     string `json:"kind"` // auth, api, currency or timeout
+	Message  string `json:"message"`
+
+	// Partial marks a streamed provider that failed after some of its costs
+	// were counted
+	Partial bool `json:"partial,omitempty"`
+}
+
 // CostEntry represents a single cost entry
 type CostEntry struct {
 	Provider    string            `json:"provider"`
 	AccountID   string            `json:"account_id"`
 	Service     string            `json:"service"`
 	Region      string            `json:"region"`
+	ResourceID  string            `json:"resource_id,omitempty"`
 	Date        time.Time         `json:"date"`
 	Cost        float64           `json:"cost"`
+	RawCost     float64           `json:"raw_cost,omitempty"`     // provider-reported cost, set when adjusted
+	Adjustment  string            `json:"adjustment,omitempty"`   // name of the adjustment applied
+	ChargeType  string            `json:"charge_type,omitempty"`  // usage, credit, refund, tax or fee
+	Internal    string            `json:"internal,omitempty"`     // internal charge rule that matched
+	EmissionsKg float64           `json:"emissions_kg,omitempty"` // estimated kg CO2e
 	Currency    string            `json:"currency"`
 	Tags        map[string]string `json:"tags"`
 	UsageType   string            `json:"usage_type"`
 	UsageAmount float64           `json:"usage_amount"`
 	UsageUnit   string            `json:"usage_unit"`
+
+	// FOCUS amortization and commitment discount details, when the source has them
+	EffectiveCost              float64 `json:"effective_cost,omitempty"`
+	CommitmentDiscountID       string  `json:"commitment_discount_id,omitempty"`
+	CommitmentDiscountCategory string  `json:"commitment_discount_category,omitempty"`
+	CommitmentDiscountType     string  `json:"commitment_discount_type,omitempty"`
+	CommitmentDiscountName     string  `json:"commitment_discount_name,omitempty"`
+	PricingModel               string  `json:"pricing_model,omitempty"` // on_demand, reserved, spot, savings_plan
+
+	// Amount as billed, set when converted into the base currency
+	OriginalCost     float64 `json:"original_cost,omitempty"`
+	OriginalCurrency string  `json:"original_currency,omitempty"`
+
+	// Provider's own charge type behind ChargeType, e.g. RIFee
+	LineItemType string `json:"line_item_type,omitempty"`
 }
 
 // BudgetStatus represents budget utilization
@@ -44,15 +117,76 @@ type BudgetStatus struct {
 	ForecastSpend float64 `json:"forecast_spend"`
 }
 
+// UnassignedApplication collects cost with no application tag
+const UnassignedApplication = "unassigned"
+
 // AggregationResult contains aggregated cost data
 type AggregationResult struct {
-	TotalCost   float64            `json:"total_cost"`
-	ByProvider  map[string]float64 `json:"by_provider"`
-	ByService   map[string]float64 `json:"by_service"`
-	ByAccount   map[string]float64 `json:"by_account"`
-	ByRegion    map[string]float64 `json:"by_region"`
-	ByDate      map[string]float64 `json:"by_date"`
-	Entries     []CostEntry        `json:"entries"`
+	AsOf          time.Time                   `json:"as_of"` // date of the freshest entry
+	TotalCost     float64                     `json:"total_cost"`
+	RawTotalCost  float64                     `json:"raw_total_cost"` // total before adjustments
+	Adjustments   map[string]float64          `json:"adjustments"`    // adjustment name -> change in cost
+	ExternalCost  float64                     `json:"external_cost"`
+	InternalCost  float64                     `json:"internal_cost"`    // intercompany and internal-transfer charges
+	InternalRules map[string]float64          `json:"internal_charges"` // internal charge rule -> cost
+	Emissions     float64                     `json:"emissions_kg"`     // estimated kg CO2e, 0 when not configured
+	ByEmissions   map[string]float64          `json:"by_emissions"`     // service -> estimated kg CO2e
+	ByProvider    map[string]float64          `json:"by_provider"`
+	ByService     map[string]float64          `json:"by_service"`
+	ByAccount     map[string]float64          `json:"by_account"`
+	ByOrgUnit     map[string]float64          `json:"by_org_unit,omitempty"` // org-unit path -> cost, when a hierarchy is set
+	ByRegion      map[string]float64          `json:"by_region"`
+	ByDate        map[string]float64          `json:"by_date"`
+	ByApplication map[string]float64          `json:"by_application"`
+	Applications  map[string]*ApplicationCost `json:"applications"`
+	Entries       []CostEntry                 `json:"entries"`
+	ZeroCost      int                         `json:"zero_cost_records,omitempty"` // zero-cost records dropped or rolled up
+	NoCost        map[string]int              `json:"no_cost_services,omitempty"`  // service -> rolled-up zero-cost records
+	Errors        []ProviderError             `json:"errors,omitempty"`
+
+	// Computed dimensions, when any are registered
+	ByCustom map[string]map[string]float64 `json:"by_custom,omitempty"`          // dimension -> value -> cost
+	Overflow map[string]int                `json:"dimension_overflow,omitempty"` // dimension -> records folded into DimensionOther
+
+	// Tag keys changed by the tag limits
+	TagCaps []normalizer.TagCap `json:"tag_caps,omitempty"`
+
+	// Cost by charge type (usage, credit, refund, tax, fee), including
+	// charge types excluded from the totals
+	ByChargeType map[string]float64 `json:"by_charge_type"`
+	ExcludedCost float64            `json:"excluded_cost,omitempty"` // charges of excluded types
+
+	// Records dropped because an earlier provider reported the same charge
+	// (see normalizer.Fingerprint), and their cost
+	Duplicates    int     `json:"duplicate_records,omitempty"`
+	DuplicateCost float64 `json:"duplicate_cost,omitempty"`
+}
+
+// ApplicationCost is the full cost stack of a tag-defined application
+type ApplicationCost struct {
+	Name       string             `json:"name"`
+	TotalCost  float64            `json:"total_cost"`
+	ByProvider map[string]float64 `json:"by_provider"`
+	ByService  map[string]float64 `json:"by_service"`
+	ByAccount  map[string]float64 `json:"by_account"`
+}
+
+// TopServices returns the application's top N services by cost
+func (a *ApplicationCost) TopServices(n int) []CostEntry {
+	return topN(a.ByService, n)
+}
+
+// applicationSplits parses an application tag value into weighted
+// applications; untagged resources are unassigned
+func applicationSplits(value string) []normalizer.Split {
+	if value == "" {
+		return []normalizer.Split{{Key: UnassignedApplication, Weight: 1}}
+	}
+	parsed, err := normalizer.ParseSplit(value)
+	if err != nil || len(parsed) == 0 {
+		return []normalizer.Split{{Key: value, Weight: 1}}
+	}
+	return parsed
 }
 
 // TopServices returns the top N services by cost
@@ -61,9 +195,14 @@ func (r *AggregationResult) TopServices(n int) []CostEntry {
 	serviceMap := make(map[string]float64)
 	for _, e := range r.Entries {
 		key := fmt.Sprintf("%s:%s", e.Provider, e.Service)
-		serviceMap[key] += e.Cost
+		serviceMap[key] = money.Add(serviceMap[key], e.Cost)
 	}
 
+	return topN(serviceMap, n)
+}
+
+// topN returns the n largest keys of a cost map as entries
+func topN(serviceMap map[string]float64, n int) []CostEntry {
 	// Convert to slice
 	type serviceCost struct {
 		Service string
@@ -90,8 +229,62 @@ func (r *AggregationResult) TopServices(n int) []CostEntry {
 	return result
 }
 
+// Records converts the aggregated entries into normalized cost records
+func (r *AggregationResult) Records() []normalizer.CostRecord {
+	records := make([]normalizer.CostRecord, 0, len(r.Entries))
+	for _, e := range r.Entries {
+		records = append(records, e.Record())
+	}
+	return records
+}
+
+// Record converts a provider cost entry into the normalized schema
+func (e CostEntry) Record() normalizer.CostRecord {
+	return normalizer.CostRecord{
+		Cloud:            e.Provider,
+		Account:          e.AccountID,
+		Region:           e.Region,
+		Service:          normalizer.NormalizeService(e.Provider, e.Service),
+		Resource:         e.ResourceID,
+		Cost:             e.Cost,
+		RawCost:          e.RawCost,
+		Adjustment:       e.Adjustment,
+		ChargeType:       normalizer.ParseChargeType(e.ChargeType),
+		LineItemType:     e.LineItemType,
+		EmissionsKg:      e.EmissionsKg,
+		Currency:         e.Currency,
+		UsageQuantity:    e.UsageAmount,
+		UsageUnit:        e.UsageUnit,
+		Date:             e.Date,
+		StartTime:        e.Date,
+		EndTime:          e.Date.AddDate(0, 0, 1),
+		Tags:             e.Tags,
+		CloudService:     e.Service,
+		CloudServiceType: e.UsageType,
+
+		EffectiveCost:              e.EffectiveCost,
+		CommitmentDiscountID:       e.CommitmentDiscountID,
+		CommitmentDiscountCategory: e.CommitmentDiscountCategory,
+		CommitmentDiscountType:     e.CommitmentDiscountType,
+		CommitmentDiscountName:     e.CommitmentDiscountName,
+		PricingModel:               e.PricingModel,
+
+		OriginalCost:     e.OriginalCost,
+		OriginalCurrency: e.OriginalCurrency,
+	}
+}
+
+// Raw returns the provider-reported cost before any adjustment
+func (e CostEntry) Raw() float64 {
+	if e.Adjustment == "" {
+		return e.Cost
+	}
+	return e.RawCost
+}
+
 // Anomaly represents a cost anomaly
 type Anomaly struct {
+	ID                  string    `json:"id"` // stable across runs, see anomaly.ID
 	Provider            string    `json:"provider"`
 	Service             string    `json:"service"`
 	AccountID           string    `json:"account_id"`
@@ -100,6 +293,7 @@ type Anomaly struct {
 	ExpectedCost        float64   `json:"expected_cost"`
 	PercentageDeviation float64   `json:"percentage_deviation"`
 	Severity            string    `json:"severity"`
+	Status              string    `json:"status,omitempty"` // workflow status, set when tracked
 }
 
 // BudgetAlert represents a budget threshold alert
@@ -112,21 +306,80 @@ type BudgetAlert struct {
 	PercentUsed  float64   `json:"percent_used"`
 	Severity     string    `json:"severity"`
 	AlertedAt    time.Time `json:"alerted_at"`
+
+	Parent         string  `json:"parent,omitempty"`          // budget this one rolls up into
+	Period         string  `json:"period,omitempty"`          // first to last day of the budget period, for budget mode
+	Kind           string  `json:"kind"`                      // actual, forecast or run_rate
+	Threshold      int     `json:"threshold,omitempty"`       // alert_at percentage crossed, for actual alerts
+	ProjectedSpend float64 `json:"projected_spend,omitempty"` // period-end projection, for forecast alerts
+	DailyBurn      float64 `json:"daily_burn,omitempty"`      // recent daily spend, for run-rate budgets
+	AllowedBurn    float64 `json:"allowed_burn,omitempty"`    // daily spend the rest of the budget allows
 }
 
 // Aggregator orchestrates cost aggregation across providers
 type Aggregator struct {
-	config    *config.Config
-	providers map[string]CostProvider
-	mu        sync.RWMutex
+	config      *config.Config
+	providers   map[string]CostProvider
+	adjustments []normalizer.Adjustment
+	sinks       []notify.Sink
+	mu          sync.RWMutex
+
+	internalRules   []InternalRule
+	excludeInternal bool
+	emissions       *emissions.Table
+	hierarchy       *hierarchy.Hierarchy
+	filter          CostFilter
+	zeroCost        ZeroCostPolicy
+	dimensions      []Dimension
+	tagLimits       normalizer.TagLimits
+	currency        *currency.Converter
+	costCenter      func(normalizer.CostRecord) string
+	excludedCharges map[normalizer.ChargeType]bool
+	timeouts        Timeouts
+	failures        map[string]ProviderError // latest error per provider, across aggregations
+	fiscal          *calendar.Fiscal
 }
 
 // New creates a new Aggregator
 func New(cfg *config.Config) *Aggregator {
+	adjustments := make([]normalizer.Adjustment, 0, len(cfg.Adjustments))
+	for _, adj := range cfg.Adjustments {
+		multiplier := adj.Multiplier
+		if multiplier == 0 && adj.Percent != 0 {
+			multiplier = 1 + adj.Percent/100
+		}
+		adjustments = append(adjustments, normalizer.Adjustment{
+			Name:       adj.Name,
+			Cloud:      adj.Provider,
+			Service:    adj.Service,
+			Multiplier: multiplier,
+		})
+	}
+
 	return &Aggregator{
-		config:    cfg,
-		providers: make(map[string]CostProvider),
+		config:      cfg,
+		providers:   make(map[string]CostProvider),
+		adjustments: adjustments,
+	}
+}
+
+// Adjustments returns the configured cost adjustments
+func (a *Aggregator) Adjustments() []normalizer.Adjustment {
+	return a.adjustments
+}
+
+// adjust applies the matching cost adjustment to an entry, keeping the raw
+// provider-reported cost alongside the adjusted figure
+func (a *Aggregator) adjust(entry CostEntry) CostEntry {
+	adj, ok := normalizer.FindAdjustment(a.adjustments, entry.Provider, entry.Service)
+	if !ok {
+		return entry
 	}
+	entry.RawCost = entry.Cost
+	entry.Cost *= adj.Multiplier
+	entry.EffectiveCost *= adj.Multiplier
+	entry.Adjustment = adj.Name
+	return entry
 }
 
 // RegisterProvider registers a cost provider
@@ -138,6 +391,10 @@ func (a *Aggregator) RegisterProvider(name string, provider CostProvider) {
 
 // Aggregate fetches and aggregates costs from all providers
 func (a *Aggregator) Aggregate(ctx context.Context, start, end time.Time) (*AggregationResult, error) {
+	ctx, span := telemetry.Start(ctx, "Aggregate",
+		attribute.String("finops.start", start.Format("2006-01-02")),
+		attribute.String("finops.end", end.Format("2006-01-02")))
+
 	a.mu.RLock()
 	providers := make(map[string]CostProvider)
 	for k, v := range a.providers {
@@ -145,63 +402,513 @@ func (a *Aggregator) Aggregate(ctx context.Context, start, end time.Time) (*Aggr
 	}
 	a.mu.RUnlock()
 
-	result := &AggregationResult{
-		ByProvider: make(map[string]float64),
-		ByService:  make(map[string]float64),
-		ByAccount:  make(map[string]float64),
-		ByRegion:   make(map[string]float64),
-		ByDate:     make(map[string]float64),
-		Entries:    make([]CostEntry, 0),
+	result, err := a.aggregate(ctx, providers, start, end)
+	records := 0
+	if result != nil {
+		records = len(result.Entries)
+		span.SetAttributes(attribute.Int("finops.provider_errors", len(result.Errors)))
+	}
+	telemetry.End(span, records, err)
+	return result, err
+}
+
+// Providers returns the names of the registered providers in sorted order
+func (a *Aggregator) Providers() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	names := make([]string, 0, len(a.providers))
+	for name := range a.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// AggregateProvider fetches and aggregates the costs of one registered
+// provider
+func (a *Aggregator) AggregateProvider(ctx context.Context, name string, start, end time.Time) (*AggregationResult, error) {
+	a.mu.RLock()
+	provider, ok := a.providers[name]
+	a.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("provider %s is not registered", name)
 	}
 
+	ctx, span := telemetry.Start(ctx, "Aggregate",
+		telemetry.AttrProvider.String(name),
+		attribute.String("finops.start", start.Format("2006-01-02")),
+		attribute.String("finops.end", end.Format("2006-01-02")))
+
+	result, err := a.aggregate(ctx, map[string]CostProvider{name: provider}, start, end)
+	records := 0
+	if result != nil {
+		records = len(result.Entries)
+	}
+	telemetry.End(span, records, err)
+	return result, err
+}
+
+func (a *Aggregator) aggregate(ctx context.Context, providers map[string]CostProvider, start, end time.Time) (*AggregationResult, error) {
+	acc := a.newAccumulator()
+	result := acc.result
+	result.Entries = make([]CostEntry, 0)
+	filter, tagLimits, conv, timeouts := acc.filter, acc.tagLimits, acc.conv, acc.timeouts
+
 	// Fetch from all providers concurrently
 	var wg sync.WaitGroup
 	var mu sync.Mutex
-	errCh := make(chan error, len(providers))
+	fetched := make(map[string][]CostEntry)
 
 	for name, provider := range providers {
 		wg.Add(1)
 		go func(name string, provider CostProvider) {
 			defer wg.Done()
 
-			entries, err := provider.GetCosts(ctx, start, end)
+			fetchCtx, span := telemetry.Start(ctx, "GetCosts "+name, telemetry.AttrProvider.String(name))
+			fetchCtx, cancel := withTimeout(fetchCtx, timeouts.For(name))
+			defer cancel()
+			entries, err := fetchCosts(fetchCtx, provider, start, end, filter)
+			telemetry.End(span, len(entries), err)
 			if err != nil {
-				errCh <- fmt.Errorf("%s: %w", name, err)
+				mu.Lock()
+				result.Errors = append(result.Errors, providerError(ctx, fetchCtx, name, timeouts.For(name), err))
+				mu.Unlock()
 				return
 			}
 
 			mu.Lock()
-			defer mu.Unlock()
-
-			for _, entry := range entries {
-				result.Entries = append(result.Entries, entry)
-				result.TotalCost += entry.Cost
-				result.ByProvider[entry.Provider] += entry.Cost
-				result.ByService[entry.Service] += entry.Cost
-				result.ByAccount[entry.AccountID] += entry.Cost
-				result.ByRegion[entry.Region] += entry.Cost
-				result.ByDate[entry.Date.Format("2006-01-02")] += entry.Cost
-			}
+			fetched[name] = entries
+			mu.Unlock()
 		}(name, provider)
 	}
 
 	wg.Wait()
-	close(errCh)
 
-	// Collect errors
-	var errs []error
-	for err := range errCh {
-		errs = append(errs, err)
+	// Process providers in a stable order so results do not depend on which
+	// finished first
+	names := make([]string, 0, len(fetched))
+	for name := range fetched {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var all []CostEntry
+	var dedup *normalizer.Deduper
+	if !acc.dedup.Disabled && len(names) > 1 {
+		dedup = normalizer.NewDeduper()
+		names = preferred(names, acc.dedup.Prefer)
+	}
+	for _, name := range names {
+		if err := convertCurrency(conv, fetched[name]); err != nil {
+			result.Errors = append(result.Errors, ProviderError{
+				Provider: name,
+				Kind:     ErrorKindCurrency,
+				Message:  err.Error(),
+			})
+			continue
+		}
+		if dedup == nil {
+			all = append(all, fetched[name]...)
+			continue
+		}
+		for _, entry := range fetched[name] {
+			if dedup.Duplicate(name, entry.Record()) {
+				result.Duplicates++
+				acc.duplicate += money.FromFloat(entry.Cost)
+				continue
+			}
+			all = append(all, entry)
+		}
+	}
+	result.TagCaps = guardTags(tagLimits, all)
+
+	for _, entry := range all {
+		if entry, ok := acc.add(entry); ok {
+			result.Entries = append(result.Entries, entry)
+		}
+	}
+
+	return a.finish(acc.close(), len(providers))
+}
+
+// preferred orders provider names for deduplication: the preferred ones
+// first, in their order, then the rest as given
+func preferred(names, prefer []string) []string {
+	ordered := make([]string, 0, len(names))
+	taken := make(map[string]bool, len(names))
+	for _, p := range prefer {
+		for _, name := range names {
+			if name == p && !taken[name] {
+				ordered = append(ordered, name)
+				taken[name] = true
+			}
+		}
 	}
+	for _, name := range names {
+		if !taken[name] {
+			ordered = append(ordered, name)
+		}
+	}
+	return ordered
+}
+
+// finish sorts and keeps the result's provider errors, failing when every
+// provider failed
+func (a *Aggregator) finish(result *AggregationResult, providers int) (*AggregationResult, error) {
+	sort.Slice(result.Errors, func(i, j int) bool {
+		return result.Errors[i].Provider < result.Errors[j].Provider
+	})
+	a.recordFailures(result.Errors)
 
-	if len(errs) > 0 && len(errs) == len(providers) {
+	if len(result.Errors) > 0 && len(result.Errors) == providers {
 		// All providers failed
-		return nil, fmt.Errorf("all providers failed: %v", errs)
+		errs := make([]string, 0, len(result.Errors))
+		for _, e := range result.Errors {
+			errs = append(errs, fmt.Sprintf("%s (%s): %s", e.Provider, e.Kind, e.Message))
+		}
+		return nil, fmt.Errorf("all providers failed: %s", strings.Join(errs, "; "))
 	}
 
 	return result, nil
 }
 
+// withTimeout bounds a provider's fetch; zero leaves it unbounded
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// providerError classifies a failed fetch: expired credentials, the
+// provider's own timeout running out, or any other API failure
+func providerError(ctx, fetchCtx context.Context, name string, timeout time.Duration, err error) ProviderError {
+	kind := ErrorKindAPI
+	switch {
+	case errors.Is(err, ErrAuthExpired):
+		kind = ErrorKindAuth
+	case ctx.Err() == nil && errors.Is(fetchCtx.Err(), context.DeadlineExceeded):
+		kind = ErrorKindTimeout
+		err = fmt.Errorf("no response within %s: %w", timeout, err)
+	}
+	return ProviderError{Provider: name, Kind: kind, Message: err.Error()}
+}
+
+// accumulator totals entries into a result under the aggregator's settings
+// as of its creation
+type accumulator struct {
+	a      *Aggregator
+	result *AggregationResult
+	ledger
+
+	internalRules   []InternalRule
+	excludeInternal bool
+	factors         *emissions.Table
+	orgs            *hierarchy.Hierarchy
+	filter          CostFilter
+	zeroCost        ZeroCostPolicy
+	dims            []Dimension
+	tagLimits       normalizer.TagLimits
+	conv            *currency.Converter
+	excludedCharges map[normalizer.ChargeType]bool
+	timeouts        Timeouts
+	appTag          string
+	dedup           config.DedupConfig
+}
+
+func (a *Aggregator) newAccumulator() *accumulator {
+	a.mu.RLock()
+	acc := &accumulator{
+		a:               a,
+		internalRules:   a.internalRules,
+		excludeInternal: a.excludeInternal,
+		factors:         a.emissions,
+		orgs:            a.hierarchy,
+		filter:          a.filter,
+		zeroCost:        a.zeroCost,
+		dims:            a.dimensions,
+		tagLimits:       a.tagLimits,
+		conv:            a.currency,
+		excludedCharges: a.excludedCharges,
+		timeouts:        a.timeouts,
+		appTag:          a.config.Applications.Tag,
+		dedup:           a.config.Dedup,
+	}
+	a.mu.RUnlock()
+
+	result := &AggregationResult{
+		Adjustments:   make(map[string]float64),
+		InternalRules: make(map[string]float64),
+		ByEmissions:   make(map[string]float64),
+		ByProvider:    make(map[string]float64),
+		ByService:     make(map[string]float64),
+		ByAccount:     make(map[string]float64),
+		ByRegion:      make(map[string]float64),
+		ByDate:        make(map[string]float64),
+		ByApplication: make(map[string]float64),
+		Applications:  make(map[string]*ApplicationCost),
+		ByChargeType:  make(map[string]float64),
+	}
+	if acc.orgs != nil {
+		result.ByOrgUnit = make(map[string]float64)
+	}
+	if acc.zeroCost.Mode == ZeroCostRollup {
+		result.NoCost = make(map[string]int)
+	}
+	if len(acc.dims) > 0 {
+		result.ByCustom = make(map[string]map[string]float64, len(acc.dims))
+		result.Overflow = make(map[string]int)
+		for _, d := range acc.dims {
+			result.ByCustom[d.Name] = make(map[string]float64)
+		}
+	}
+	acc.result = result
+	acc.ledger = newLedger(acc.orgs != nil, acc.dims)
+	return acc
+}
+
+// add applies the zero-cost policy, computed dimension filter, adjustments
+// and charge classification to an entry and totals it. It returns the entry
+// as counted, or false when the entry is left out of the totals.
+func (acc *accumulator) add(entry CostEntry) (CostEntry, bool) {
+	result := acc.result
+	if acc.zeroCost.Discards(entry) {
+		result.ZeroCost++
+		if result.NoCost != nil {
+			result.NoCost[entry.Service]++
+		}
+		return entry, false
+	}
+	if len(acc.filter.Dimensions) > 0 && !acc.filter.MatchesDimensions(acc.dims, entry.Record()) {
+		return entry, false
+	}
+	entry = classify(acc.internalRules, acc.a.adjust(entry))
+	if acc.filter.Where != nil && !acc.filter.Where.Match(entry.Record()) {
+		return entry, false
+	}
+	charge := entry.Charge()
+	acc.byChargeType.Add(string(charge), entry.Cost)
+	if acc.excludedCharges[charge] {
+		acc.excluded += money.FromFloat(entry.Cost)
+		return entry, false
+	}
+	if entry.Adjustment != "" {
+		acc.adjustments[entry.Adjustment] += money.FromFloat(entry.Cost) - money.FromFloat(entry.RawCost)
+	}
+	if entry.Internal != "" {
+		acc.internal += money.FromFloat(entry.Cost)
+		acc.byInternalRule.Add(entry.Internal, entry.Cost)
+		if acc.excludeInternal {
+			return entry, false
+		}
+	} else {
+		acc.external += money.FromFloat(entry.Cost)
+	}
+	if kg, ok := acc.factors.Estimate(entry.Provider, entry.Region, entry.Service, entry.UsageUnit, entry.UsageAmount, entry.Cost); ok {
+		entry.EmissionsKg = kg
+		result.Emissions += kg
+		result.ByEmissions[entry.Service] += kg
+	}
+	acc.total += money.FromFloat(entry.Cost)
+	acc.raw += money.FromFloat(entry.Raw())
+	acc.byProvider.Add(entry.Provider, entry.Cost)
+	acc.byService.Add(entry.Service, entry.Cost)
+	acc.byAccount.Add(entry.AccountID, entry.Cost)
+	if acc.orgs != nil {
+		acc.byOrgUnit.Add(acc.orgs.Key(entry.AccountID), entry.Cost)
+	}
+	acc.byRegion.Add(entry.Region, entry.Cost)
+	acc.byDate.Add(entry.Date.Format("2006-01-02"), entry.Cost)
+	if entry.Date.After(result.AsOf) {
+		result.AsOf = entry.Date
+	}
+	if acc.appTag != "" {
+		acc.addApplication(entry, acc.appTag)
+	}
+	if len(acc.dims) > 0 {
+		acc.addCustom(result, acc.dims, entry)
+	}
+	return entry, true
+}
+
+// ledger holds an accumulator's cost totals in fixed point, so that adding
+// many small charges loses nothing; close writes them into the result
+type ledger struct {
+	total, raw, external, internal, excluded, duplicate money.Micros
+
+	byChargeType   money.Totals
+	adjustments    money.Totals
+	byInternalRule money.Totals
+	byProvider     money.Totals
+	byService      money.Totals
+	byAccount      money.Totals
+	byOrgUnit      money.Totals
+	byRegion       money.Totals
+	byDate         money.Totals
+	byApplication  money.Totals
+	applications   map[string]*applicationLedger
+	custom         map[string]money.Totals
+}
+
+// applicationLedger is the fixed-point cost stack of an application
+type applicationLedger struct {
+	total      money.Micros
+	byProvider money.Totals
+	byService  money.Totals
+	byAccount  money.Totals
+}
+
+func newLedger(orgs bool, dims []Dimension) ledger {
+	l := ledger{
+		byChargeType:   make(money.Totals),
+		adjustments:    make(money.Totals),
+		byInternalRule: make(money.Totals),
+		byProvider:     make(money.Totals),
+		byService:      make(money.Totals),
+		byAccount:      make(money.Totals),
+		byRegion:       make(money.Totals),
+		byDate:         make(money.Totals),
+		byApplication:  make(money.Totals),
+		applications:   make(map[string]*applicationLedger),
+		custom:         make(map[string]money.Totals, len(dims)),
+	}
+	if orgs {
+		l.byOrgUnit = make(money.Totals)
+	}
+	for _, d := range dims {
+		l.custom[d.Name] = make(money.Totals)
+	}
+	return l
+}
+
+// addApplication attributes an entry to its application(s). Resources
+// tagged with several applications are split by the tag's weights, with
+// the parts adding up to the entry's cost.
+func (l *ledger) addApplication(entry CostEntry, tag string) {
+	splits := applicationSplits(entry.Tags[tag])
+	weights := make([]float64, len(splits))
+	for i, s := range splits {
+		weights[i] = s.Weight
+	}
+	costs := money.Split(entry.Cost, weights)
+	for i, s := range splits {
+		cost := costs[i]
+
+		app, ok := l.applications[s.Key]
+		if !ok {
+			app = &applicationLedger{
+				byProvider: make(money.Totals),
+				byService:  make(money.Totals),
+				byAccount:  make(money.Totals),
+			}
+			l.applications[s.Key] = app
+		}
+
+		l.byApplication.Add(s.Key, cost)
+		app.total += money.FromFloat(cost)
+		app.byProvider.Add(entry.Provider, cost)
+		app.byService.Add(entry.Service, cost)
+		app.byAccount.Add(entry.AccountID, cost)
+	}
+}
+
+// close writes the accumulated totals into the result and returns it
+func (acc *accumulator) close() *AggregationResult {
+	result := acc.result
+	result.TotalCost = acc.total.Float()
+	result.RawTotalCost = acc.raw.Float()
+	result.ExternalCost = acc.external.Float()
+	result.InternalCost = acc.internal.Float()
+	result.ExcludedCost = acc.excluded.Float()
+	result.DuplicateCost = acc.duplicate.Float()
+	acc.byChargeType.Floats(result.ByChargeType)
+	acc.adjustments.Floats(result.Adjustments)
+	acc.byInternalRule.Floats(result.InternalRules)
+	acc.byProvider.Floats(result.ByProvider)
+	acc.byService.Floats(result.ByService)
+	acc.byAccount.Floats(result.ByAccount)
+	if result.ByOrgUnit != nil {
+		acc.byOrgUnit.Floats(result.ByOrgUnit)
+	}
+	acc.byRegion.Floats(result.ByRegion)
+	acc.byDate.Floats(result.ByDate)
+	acc.byApplication.Floats(result.ByApplication)
+	for name, l := range acc.applications {
+		app := &ApplicationCost{
+			Name:       name,
+			TotalCost:  l.total.Float(),
+			ByProvider: make(map[string]float64, len(l.byProvider)),
+			ByService:  make(map[string]float64, len(l.byService)),
+			ByAccount:  make(map[string]float64, len(l.byAccount)),
+		}
+		l.byProvider.Floats(app.ByProvider)
+		l.byService.Floats(app.ByService)
+		l.byAccount.Floats(app.ByAccount)
+		result.Applications[name] = app
+	}
+	for name, costs := range acc.custom {
+		costs.Floats(result.ByCustom[name])
+	}
+	return result
+}
+
+// recordFailures keeps the result's provider errors for Failures
+func (a *Aggregator) recordFailures(errs []ProviderError) {
+	if len(errs) == 0 {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.failures == nil {
+		a.failures = make(map[string]ProviderError)
+	}
+	for _, e := range errs {
+		a.failures[e.Provider] = e
+	}
+}
+
+// CheckFreshness returns ErrStaleData when the freshest data in result is
+// older than maxAge at now. A zero maxAge disables the check.
+func CheckFreshness(result *AggregationResult, maxAge time.Duration, now time.Time) error {
+	if maxAge <= 0 {
+		return nil
+	}
+	if result.AsOf.IsZero() {
+		return fmt.Errorf("%w: no cost data available", ErrStaleData)
+	}
+
+	age := now.Sub(result.AsOf)
+	if age > maxAge {
+		return fmt.Errorf("%w: freshest data is from %s (%s old, max age %s)",
+			ErrStaleData, result.AsOf.Format("2006-01-02"), age.Round(time.Hour), maxAge)
+	}
+	return nil
+}
+
+// fetchCosts calls the provider, refreshing credentials and retrying once
+// when the provider reports expired credentials
+func fetchCosts(ctx context.Context, provider CostProvider, start, end time.Time, filter CostFilter) ([]CostEntry, error) {
+	entries, err := getCosts(ctx, provider, start, end, filter)
+	if err == nil || !errors.Is(err, ErrAuthExpired) {
+		return entries, err
+	}
+
+	refresher, ok := provider.(CredentialRefresher)
+	if !ok {
+		return nil, err
+	}
+
+	trace.SpanFromContext(ctx).AddEvent("credential refresh")
+	if rerr := refresher.RefreshCredentials(ctx); rerr != nil {
+		return nil, fmt.Errorf("%w (credential refresh failed: %v)", err, rerr)
+	}
+
+	entries, err = getCosts(ctx, provider, start, end, filter)
+	if err != nil {
+		return nil, fmt.Errorf("after credential refresh: %w", err)
+	}
+	return entries, nil
+}
+
 // DetectAnomalies identifies cost anomalies
 func (a *Aggregator) DetectAnomalies(result *AggregationResult) []Anomaly {
 	if !a.config.Anomaly.Enabled {
@@ -214,9 +921,11 @@ func (a *Aggregator) DetectAnomalies(result *AggregationResult) []Anomaly {
 
 	// Group by service for comparison
 	serviceDaily := make(map[string][]float64)
+	latest := make(map[string]CostEntry)
 	for _, entry := range result.Entries {
 		key := fmt.Sprintf("%s:%s:%s", entry.Provider, entry.AccountID, entry.Service)
 		serviceDaily[key] = append(serviceDaily[key], entry.Cost)
+		latest[key] = entry
 	}
 
 	// Calculate statistics and detect anomalies
@@ -243,8 +952,11 @@ func (a *Aggregator) DetectAnomalies(result *AggregationResult) []Anomaly {
 				severity = "high"
 			}
 
+			e := latest[key]
 			anomalies = append(anomalies, Anomaly{
+				ID:                  anomaly.ID(e.Provider, e.AccountID, e.Service, "", e.Date),
 				Service:             key,
+				Date:                e.Date,
 				ActualCost:          recent,
 				ExpectedCost:        mean,
 				PercentageDeviation: deviation,
@@ -268,56 +980,173 @@ func (a *Aggregator) DetectAnomalies(result *AggregationResult) []Anomaly {
 func (a *Aggregator) CheckBudgets(result *AggregationResult) []BudgetAlert {
 	alerts := make([]BudgetAlert, 0)
 
-	for _, budget := range a.config.Budgets {
-		var currentSpend float64
+	var records []normalizer.CostRecord
+	spend := rollupBudgets(a.config.Budgets, func(budget config.Budget) float64 {
+		if budget.CostCenter != "" {
+			if records == nil {
+				records = result.Records()
+			}
+			var spend float64
+			for _, r := range records {
+				spend = money.Add(spend, a.budgetShare(budget, r))
+			}
+			return spend
+		}
 
+		if budget.Application != "" {
+			return result.ByApplication[budget.Application]
+		}
+		if budget.Scope != "" {
+			return result.ByAccount[budget.Scope]
+		}
 		if budget.Provider == "all" {
-			currentSpend = result.TotalCost
-		} else {
-			currentSpend = result.ByProvider[budget.Provider]
+			return result.TotalCost
 		}
+		return result.ByProvider[budget.Provider]
+	}, money.Add)
 
-		if budget.Scope != "" {
-			currentSpend = result.ByAccount[budget.Scope]
+	fis := a.Fiscal()
+	today := fis.Today(time.Now())
+	for _, budget := range a.config.Budgets {
+		start, end, ok := BudgetPeriod(budget, today, fis)
+		if !ok {
+			continue
+		}
+		limit := BudgetLimit(budget, start, end, fis)
+		if limit <= 0 {
+			continue
 		}
+		currentSpend := spend[budget.Name]
+		percentUsed := (currentSpend / limit) * 100
 
-		percentUsed := (currentSpend / budget.MonthlyLimit) * 100
-
-		// Check each alert threshold
-		for _, alertAt := range budget.AlertAt {
-			if percentUsed >= float64(alertAt) {
-				severity := "info"
-				if alertAt >= 90 {
-					severity = "high"
-				} else if alertAt >= 75 {
-					severity = "medium"
-				} else if alertAt >= 50 {
-					severity = "low"
-				}
-
-				alerts = append(alerts, BudgetAlert{
-					BudgetName:   budget.Name,
-					Provider:     budget.Provider,
-					Scope:        budget.Scope,
-					BudgetLimit:  budget.MonthlyLimit,
-					CurrentSpend: currentSpend,
-					PercentUsed:  percentUsed,
-					Severity:     severity,
-					AlertedAt:    time.Now(),
-				})
-				break // Only alert once per budget
-			}
+		// Alert once per budget, at the highest threshold crossed
+		if alertAt, ok := crossedThreshold(budget.AlertAt, percentUsed); ok {
+			alerts = append(alerts, BudgetAlert{
+				BudgetName:   budget.Name,
+				Provider:     budget.Provider,
+				Scope:        budget.Scope,
+				Parent:       budget.Parent,
+				BudgetLimit:  limit,
+				CurrentSpend: currentSpend,
+				PercentUsed:  percentUsed,
+				Severity:     budgetSeverity(alertAt),
+				AlertedAt:    time.Now(),
+				Kind:         BudgetActual,
+				Threshold:    alertAt,
+			})
 		}
 	}
 
 	return alerts
 }
 
-// SendAlerts sends alerts for anomalies and budget issues
+// RegisterSink registers an alert destination
+func (a *Aggregator) RegisterSink(sink notify.Sink) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.sinks = append(a.sinks, sink)
+}
+
+// SetEmissionFactors enables estimating carbon emissions for each entry
+func (a *Aggregator) SetEmissionFactors(t *emissions.Table) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.emissions = t
+}
+
+// SetHierarchy maps accounts to org units so results include ByOrgUnit
+func (a *Aggregator) SetHierarchy(h *hierarchy.Hierarchy) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.hierarchy = h
+}
+
+// SetFiscal sets the fiscal calendar budget periods follow
+func (a *Aggregator) SetFiscal(f *calendar.Fiscal) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.fiscal = f
+}
+
+// Fiscal returns the fiscal calendar, nil for calendar months in UTC
+func (a *Aggregator) Fiscal() *calendar.Fiscal {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.fiscal
+}
+
+// OrgUnits returns the org-unit rollup, parents before children, or nil
+// when no hierarchy was set
+func (r *AggregationResult) OrgUnits() []hierarchy.Row {
+	if r.ByOrgUnit == nil {
+		return nil
+	}
+	return hierarchy.Rollup(r.ByOrgUnit).Rows(0)
+}
+
+// SendAlerts sends alerts for anomalies and budget issues to every
+// registered sink. A failing sink does not stop the others; the returned
+// error names every sink that failed.
 func (a *Aggregator) SendAlerts(ctx context.Context, anomalies []Anomaly, budgetAlerts []BudgetAlert) error {
-	// Implementation would send to Slack, email, etc.
-	// For now, just log
-	return nil
+	a.mu.RLock()
+	sinks := append([]notify.Sink(nil), a.sinks...)
+	a.mu.RUnlock()
+
+	events := make([]notify.Event, 0, len(anomalies)+len(budgetAlerts))
+	for _, an := range anomalies {
+		events = append(events, an.Event())
+	}
+	for _, b := range budgetAlerts {
+		events = append(events, b.Event())
+	}
+	if len(events) == 0 {
+		return nil
+	}
+
+	var errs []error
+	for _, sink := range sinks {
+		if err := sink.Send(ctx, events); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", sink.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Event converts the anomaly to a notification event keyed by service
+func (an Anomaly) Event() notify.Event {
+	return notify.Event{
+		SchemaVersion: notify.SchemaVersion,
+		Type:          notify.EventAnomaly,
+		Key:           an.Service,
+		Severity:      an.Severity,
+		Provider:      an.Provider,
+		Service:       an.Service,
+		Account:       an.AccountID,
+		Summary:       fmt.Sprintf("%s spend $%.2f is %.1f%% above expected $%.2f", an.Service, an.ActualCost, an.PercentageDeviation, an.ExpectedCost),
+		Amount:        an.ActualCost,
+		Reference:     an.ExpectedCost,
+		Percent:       an.PercentageDeviation,
+		OccurredAt:    an.Date,
+	}
+}
+
+// Event converts the budget alert to a notification event keyed by budget
+func (b BudgetAlert) Event() notify.Event {
+	return notify.Event{
+		SchemaVersion: notify.SchemaVersion,
+		Type:          notify.EventBudget,
+		Key:           b.key(),
+		Severity:      b.Severity,
+		Provider:      b.Provider,
+		Account:       b.Scope,
+		Budget:        b.BudgetName,
+		Kind:          b.Kind,
+		Summary:       b.summary(),
+		Amount:        b.CurrentSpend,
+		Reference:     b.BudgetLimit,
+		Percent:       b.PercentUsed,
+		OccurredAt:    b.AlertedAt,
+	}
 }
 
 func calculateStats(values []float64) (mean, stdDev float64) {
@@ -343,4 +1172,3 @@ func calculateStats(values []float64) (mean, stdDev float64) {
 
 	return mean, stdDev
 }
-
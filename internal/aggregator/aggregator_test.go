@@ -0,0 +1,483 @@
+package aggregator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+	"github.com/lvonguyen/finops-platform/internal/notify"
+)
+
+// fakeProvider returns fixed entries, failing with each of errs in turn
+// before it does
+type fakeProvider struct {
+	name      string
+	entries   []CostEntry
+	errs      []error
+	calls     int
+	refreshes int
+}
+
+func (p *fakeProvider) GetCosts(ctx context.Context, start, end time.Time) ([]CostEntry, error) {
+	p.calls++
+	if len(p.errs) > 0 {
+		err := p.errs[0]
+		p.errs = p.errs[1:]
+		return nil, err
+	}
+	return p.entries, nil
+}
+
+func (p *fakeProvider) GetBudgets(ctx context.Context) ([]BudgetStatus, error) {
+	return nil, nil
+}
+
+func (p *fakeProvider) Name() string {
+	return p.name
+}
+
+var day = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+// tenthCentEntries returns n entries of 0.1 cent spread over accounts
+func tenthCentEntries(n int, accounts ...string) []CostEntry {
+	entries := make([]CostEntry, n)
+	for i := range entries {
+		entries[i] = CostEntry{
+			Provider:  "aws",
+			AccountID: accounts[i%len(accounts)],
+			Service:   "S3",
+			Region:    "us-east-1",
+			Date:      day.AddDate(0, 0, i%28),
+			Cost:      0.001,
+			Currency:  "USD",
+			Tags:      map[string]string{"app": fmt.Sprintf("app-%d", i%2)},
+		}
+	}
+	return entries
+}
+
+func TestAggregateSumsInFixedPoint(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Applications.Tag = "app"
+	for _, stream := range []bool{false, true} {
+		t.Run(fmt.Sprintf("stream=%v", stream), func(t *testing.T) {
+			a := New(cfg)
+			a.RegisterProvider("aws", &fakeProvider{name: "aws", entries: tenthCentEntries(30000, "111", "222", "333")})
+
+			var result *AggregationResult
+			var err error
+			if stream {
+				result, err = a.AggregateStream(context.Background(), day, day.AddDate(0, 1, 0), nil)
+			} else {
+				result, err = a.Aggregate(context.Background(), day, day.AddDate(0, 1, 0))
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.TotalCost != 30 {
+				t.Errorf("TotalCost = %v, want 30", result.TotalCost)
+			}
+			for _, account := range []string{"111", "222", "333"} {
+				if got := result.ByAccount[account]; got != 10 {
+					t.Errorf("ByAccount[%s] = %v, want 10", account, got)
+				}
+			}
+			if got := result.ByService["S3"]; got != 30 {
+				t.Errorf("ByService[S3] = %v, want 30", got)
+			}
+			if got := result.ByApplication["app-0"]; got != 15 {
+				t.Errorf("ByApplication[app-0] = %v, want 15", got)
+			}
+			if got := result.Applications["app-1"].ByAccount["222"]; got != 5 {
+				t.Errorf("app-1 ByAccount[222] = %v, want 5", got)
+			}
+		})
+	}
+}
+
+// refreshingProvider is a fakeProvider that can refresh its credentials
+type refreshingProvider struct {
+	*fakeProvider
+	refreshErr error
+}
+
+func (p *refreshingProvider) RefreshCredentials(ctx context.Context) error {
+	p.refreshes++
+	return p.refreshErr
+}
+
+func TestFetchCostsRefreshesOnce(t *testing.T) {
+	expired := fmt.Errorf("token expired: %w", ErrAuthExpired)
+	tests := []struct {
+		name       string
+		provider   *fakeProvider
+		refresher  bool
+		refreshErr error
+		calls      int
+		refreshes  int
+		wantErr    string
+	}{
+		{"fails once", &fakeProvider{errs: []error{expired}}, true, nil, 2, 1, ""},
+		{"keeps failing", &fakeProvider{errs: []error{expired, expired, expired}}, true, nil, 2, 1, "after credential refresh"},
+		{"refresh fails", &fakeProvider{errs: []error{expired}}, true, fmt.Errorf("no session"), 1, 1, "credential refresh failed: no session"},
+		{"cannot refresh", &fakeProvider{errs: []error{expired}}, false, nil, 1, 0, "token expired"},
+		{"not an auth error", &fakeProvider{errs: []error{fmt.Errorf("throttled")}}, true, nil, 1, 0, "throttled"},
+	}
+	for _, tt := range tests {
+		for _, stream := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s/stream=%v", tt.name, stream), func(t *testing.T) {
+				fake := *tt.provider
+				fake.name = "aws"
+				fake.errs = append([]error(nil), tt.provider.errs...)
+				fake.entries = tenthCentEntries(10, "111")
+				var provider CostProvider = &fake
+				if tt.refresher {
+					provider = &refreshingProvider{fakeProvider: &fake, refreshErr: tt.refreshErr}
+				}
+
+				// A healthy second provider keeps the run from failing outright
+				a := New(&config.Config{})
+				a.RegisterProvider("aws", provider)
+				a.RegisterProvider("gcp", &fakeProvider{name: "gcp"})
+				var result *AggregationResult
+				var err error
+				entries := 0
+				if stream {
+					result, err = a.AggregateStream(context.Background(), day, day.AddDate(0, 1, 0), func(CostEntry) error {
+						entries++
+						return nil
+					})
+				} else {
+					result, err = a.Aggregate(context.Background(), day, day.AddDate(0, 1, 0))
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !stream {
+					entries = len(result.Entries)
+				}
+
+				if fake.calls != tt.calls || fake.refreshes != tt.refreshes {
+					t.Errorf("calls = %d, refreshes = %d, want %d and %d", fake.calls, fake.refreshes, tt.calls, tt.refreshes)
+				}
+				if tt.wantErr == "" {
+					if len(result.Errors) != 0 || entries != 10 {
+						t.Errorf("errors = %v, entries = %d, want none and 10", result.Errors, entries)
+					}
+					return
+				}
+				if len(result.Errors) != 1 || !strings.Contains(result.Errors[0].Message, tt.wantErr) {
+					t.Fatalf("errors = %+v, want one containing %q", result.Errors, tt.wantErr)
+				}
+				wantKind := ErrorKindAuth
+				if tt.name == "not an auth error" {
+					wantKind = ErrorKindAPI
+				}
+				if result.Errors[0].Kind != wantKind {
+					t.Errorf("kind = %q, want %q", result.Errors[0].Kind, wantKind)
+				}
+			})
+		}
+	}
+}
+
+func TestAggregateFailsWhenEveryProviderFails(t *testing.T) {
+	expired := fmt.Errorf("token expired: %w", ErrAuthExpired)
+	a := New(&config.Config{})
+	a.RegisterProvider("aws", &refreshingProvider{fakeProvider: &fakeProvider{name: "aws", errs: []error{expired, expired}}})
+	_, err := a.Aggregate(context.Background(), day, day.AddDate(0, 1, 0))
+	if err == nil || !strings.Contains(err.Error(), "all providers failed: aws (auth)") {
+		t.Fatalf("err = %v, want every provider failing", err)
+	}
+	if failures := a.Failures(); len(failures) != 1 || failures[0].Kind != ErrorKindAuth {
+		t.Errorf("Failures() = %+v, want the aws auth failure", failures)
+	}
+}
+
+func TestAggregateApplications(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Applications.Tag = "app"
+	a := New(cfg)
+	a.RegisterProvider("aws", &fakeProvider{name: "aws", entries: []CostEntry{
+		{Provider: "aws", AccountID: "111", Service: "EC2", Date: day, Cost: 100, Tags: map[string]string{"app": "web:60,data:40"}},
+		{Provider: "aws", AccountID: "222", Service: "S3", Date: day, Cost: 30, Tags: map[string]string{"app": "web"}},
+		{Provider: "aws", AccountID: "222", Service: "S3", Date: day, Cost: 5},
+	}})
+
+	result, err := a.Aggregate(context.Background(), day, day.AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]float64{"web": 90, "data": 40, UnassignedApplication: 5}
+	for app, cost := range want {
+		if got := result.ByApplication[app]; got != cost {
+			t.Errorf("ByApplication[%s] = %v, want %v", app, got, cost)
+		}
+	}
+	web := result.Applications["web"]
+	if web.ByService["EC2"] != 60 || web.ByService["S3"] != 30 || web.ByAccount["222"] != 30 {
+		t.Errorf("web = %+v, want EC2 60, S3 30 and account 222 at 30", web)
+	}
+}
+
+func TestBudgetShareByApplication(t *testing.T) {
+	budget := config.Budget{Provider: "all", Application: "web"}
+	tests := []struct {
+		tags   map[string]string
+		appTag string
+		want   float64
+	}{
+		{map[string]string{"app": "web"}, "app", 100},
+		{map[string]string{"app": "web:60,data:40"}, "app", 60},
+		{map[string]string{"app": "data"}, "app", 0},
+		{nil, "app", 0},
+		{map[string]string{"app": "web"}, "", 0},
+	}
+	for _, tt := range tests {
+		r := normalizer.CostRecord{Cloud: "aws", Account: "111", Cost: 100, Tags: tt.tags}
+		if got := BudgetShare(budget, r, tt.appTag); got != tt.want {
+			t.Errorf("BudgetShare(%v, tag %q) = %v, want %v", tt.tags, tt.appTag, got, tt.want)
+		}
+	}
+}
+
+func TestCheckFreshness(t *testing.T) {
+	a := New(&config.Config{})
+	a.RegisterProvider("aws", &fakeProvider{name: "aws", entries: tenthCentEntries(10, "111")})
+	result, err := a.Aggregate(context.Background(), day, day.AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The entries run from March 1 to 10
+	if want := day.AddDate(0, 0, 9); !result.AsOf.Equal(want) {
+		t.Fatalf("AsOf = %s, want %s", result.AsOf, want)
+	}
+
+	now := day.AddDate(0, 0, 11)
+	tests := []struct {
+		name   string
+		result *AggregationResult
+		maxAge time.Duration
+		stale  bool
+	}{
+		{"disabled", result, 0, false},
+		{"fresh", result, 48 * time.Hour, false},
+		{"stale", result, 24 * time.Hour, true},
+		{"no data", &AggregationResult{}, 24 * time.Hour, true},
+	}
+	for _, tt := range tests {
+		err := CheckFreshness(tt.result, tt.maxAge, now)
+		if got := errors.Is(err, ErrStaleData); got != tt.stale {
+			t.Errorf("%s: err = %v, want stale %v", tt.name, err, tt.stale)
+		}
+	}
+}
+
+func TestAggregateAdjustments(t *testing.T) {
+	cfg := &config.Config{Adjustments: []config.CostAdjustment{
+		{Name: "edp", Provider: "aws", Percent: -10},
+		{Name: "s3-credit", Provider: "aws", Service: "S3", Multiplier: 0.5},
+	}}
+	a := New(cfg)
+	a.RegisterProvider("aws", &fakeProvider{name: "aws", entries: []CostEntry{
+		{Provider: "aws", AccountID: "111", Service: "EC2", Date: day, Cost: 200},
+		{Provider: "aws", AccountID: "111", Service: "S3", Date: day, Cost: 40},
+	}})
+	a.RegisterProvider("gcp", &fakeProvider{name: "gcp", entries: []CostEntry{
+		{Provider: "gcp", AccountID: "proj", Service: "Compute Engine", Date: day, Cost: 60},
+	}})
+
+	result, err := a.Aggregate(context.Background(), day, day.AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.RawTotalCost != 300 || result.TotalCost != 260 {
+		t.Errorf("totals = %v raw, %v adjusted; want 300 and 260", result.RawTotalCost, result.TotalCost)
+	}
+	if result.Adjustments["edp"] != -20 || result.Adjustments["s3-credit"] != -20 {
+		t.Errorf("adjustments = %v, want edp and s3-credit at -20", result.Adjustments)
+	}
+	for _, e := range result.Entries {
+		if e.Service == "S3" && (e.Cost != 20 || e.Raw() != 40 || e.Adjustment != "s3-credit") {
+			t.Errorf("S3 entry = %+v, want 20 adjusted from 40 by s3-credit", e)
+		}
+		if e.Provider == "gcp" && (e.Adjustment != "" || e.Raw() != 60) {
+			t.Errorf("gcp entry = %+v, want it unadjusted", e)
+		}
+	}
+}
+
+// TestAdjustEffectiveCost checks an adjustment scales the amortized cost
+// with the billed one and Record keeps the commitment discount
+func TestAdjustEffectiveCost(t *testing.T) {
+	a := New(&config.Config{Adjustments: []config.CostAdjustment{{Name: "edp", Provider: "aws", Percent: -10}}})
+	e := a.adjust(CostEntry{Provider: "aws", Service: "EC2", Date: day, Cost: 100, EffectiveCost: 60,
+		CommitmentDiscountID: "sp-1", CommitmentDiscountType: "Compute Savings Plan"})
+	if e.Cost != 90 || e.EffectiveCost != 54 {
+		t.Errorf("adjusted cost %v, effective %v; want 90 and 54", e.Cost, e.EffectiveCost)
+	}
+	r := e.Record()
+	if r.Effective() != 54 || r.CommitmentDiscountID != "sp-1" || r.CommitmentDiscountType != "Compute Savings Plan" {
+		t.Errorf("record = %+v, want effective 54 under sp-1", r)
+	}
+}
+
+// recordingSink collects the events it is sent, failing with err
+type recordingSink struct {
+	name   string
+	err    error
+	events []notify.Event
+}
+
+func (s *recordingSink) Name() string { return s.name }
+
+func (s *recordingSink) Send(ctx context.Context, events []notify.Event) error {
+	s.events = append(s.events, events...)
+	return s.err
+}
+
+func TestSendAlertsReachesEverySink(t *testing.T) {
+	a := New(&config.Config{})
+	failing := &recordingSink{name: "queue:kafka", err: errors.New("broker unavailable")}
+	ok := &recordingSink{name: "slack"}
+	a.RegisterSink(failing)
+	a.RegisterSink(ok)
+
+	anomalies := []Anomaly{{Provider: "aws", Service: "EC2", AccountID: "111", ActualCost: 300, ExpectedCost: 100, PercentageDeviation: 200, Severity: "high"}}
+	budgets := []BudgetAlert{{BudgetName: "platform", Provider: "aws", CurrentSpend: 900, BudgetLimit: 1000, PercentUsed: 90}}
+	err := a.SendAlerts(context.Background(), anomalies, budgets)
+	if err == nil || !strings.Contains(err.Error(), "queue:kafka: broker unavailable") {
+		t.Errorf("err = %v, want the kafka sink failure", err)
+	}
+	if len(ok.events) != 2 || ok.events[0].Key != "EC2" || ok.events[1].Key != "platform" {
+		t.Fatalf("slack got %+v, want the EC2 anomaly and platform budget", ok.events)
+	}
+	if ok.events[0].Type != notify.EventAnomaly || ok.events[1].Type != notify.EventBudget {
+		t.Errorf("event types = %s, %s", ok.events[0].Type, ok.events[1].Type)
+	}
+
+	if err := a.SendAlerts(context.Background(), nil, nil); err != nil || len(ok.events) != 2 {
+		t.Errorf("nothing to send: err = %v, %d events", err, len(ok.events))
+	}
+}
+
+func TestAggregateTraces(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+	defer otel.SetTracerProvider(prev)
+
+	expired := fmt.Errorf("token expired: %w", ErrAuthExpired)
+	a := New(&config.Config{})
+	a.RegisterProvider("aws", &fakeProvider{name: "aws", entries: tenthCentEntries(5, "111")})
+	a.RegisterProvider("gcp", &refreshingProvider{fakeProvider: &fakeProvider{name: "gcp", errs: []error{expired, expired}}})
+	if _, err := a.Aggregate(context.Background(), day, day.AddDate(0, 1, 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, s := range sr.Ended() {
+		spans[s.Name()] = s
+	}
+	root, aws, gcp := spans["Aggregate"], spans["GetCosts aws"], spans["GetCosts gcp"]
+	if root == nil || aws == nil || gcp == nil {
+		t.Fatalf("got spans %v, want Aggregate and a GetCosts span per provider", spans)
+	}
+	for _, s := range []sdktrace.ReadOnlySpan{aws, gcp} {
+		if s.Parent().SpanID() != root.SpanContext().SpanID() {
+			t.Errorf("%s is not a child of Aggregate", s.Name())
+		}
+	}
+	attr := func(s sdktrace.ReadOnlySpan, key string) attribute.Value {
+		for _, kv := range s.Attributes() {
+			if string(kv.Key) == key {
+				return kv.Value
+			}
+		}
+		return attribute.Value{}
+	}
+	if got := attr(aws, "finops.records").AsInt64(); got != 5 || aws.Status().Code != codes.Unset {
+		t.Errorf("aws span: %d records, status %v; want 5 and no error", got, aws.Status())
+	}
+	if gcp.Status().Code != codes.Error || len(gcp.Events()) != 2 || gcp.Events()[0].Name != "credential refresh" {
+		t.Errorf("gcp span: status %v, events %+v; want the refresh and the error", gcp.Status(), gcp.Events())
+	}
+	if got := attr(root, "finops.provider_errors").AsInt64(); got != 1 {
+		t.Errorf("Aggregate span: %d provider errors, want 1", got)
+	}
+}
+
+func TestAggregateProvider(t *testing.T) {
+	a := New(&config.Config{})
+	a.RegisterProvider("gcp", &fakeProvider{name: "gcp", entries: []CostEntry{{Provider: "gcp", Service: "BigQuery", Date: day, Cost: 3}}})
+	aws := &fakeProvider{name: "aws", entries: tenthCentEntries(10, "111")}
+	a.RegisterProvider("aws", aws)
+
+	if got := strings.Join(a.Providers(), ","); got != "aws,gcp" {
+		t.Errorf("Providers() = %s, want aws,gcp", got)
+	}
+	result, err := a.AggregateProvider(context.Background(), "gcp", day, day.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.TotalCost != 3 || len(result.Entries) != 1 || aws.calls != 0 {
+		t.Errorf("total %v from %d entries, aws called %d times; want gcp's 3 alone", result.TotalCost, len(result.Entries), aws.calls)
+	}
+	if _, err := a.AggregateProvider(context.Background(), "azure", day, day.AddDate(0, 0, 1)); err == nil {
+		t.Error("unregistered provider aggregated, want an error")
+	}
+}
+
+func TestAggregateDedup(t *testing.T) {
+	explorer := []CostEntry{{Provider: "aws", AccountID: "111", Service: "EC2", Date: day, Cost: 10}}
+	cur := []CostEntry{
+		{Provider: "aws", AccountID: "111", Service: "EC2", Date: day, Cost: 10.5},
+		{Provider: "aws", AccountID: "111", Service: "S3", Date: day, Cost: 2.5},
+		{Provider: "aws", AccountID: "111", Service: "S3", Date: day, Cost: 2.5},
+	}
+	tests := []struct {
+		name                      string
+		dedup                     config.DedupConfig
+		total, duplicateCost, ec2 float64
+		duplicates                int
+	}{
+		{"by name", config.DedupConfig{}, 15, 10.5, 10, 1},
+		{"preferred", config.DedupConfig{Prefer: []string{"aws-cur"}}, 15.5, 10, 10.5, 1},
+		{"disabled", config.DedupConfig{Disabled: true}, 25.5, 0, 20.5, 0},
+	}
+	for _, tt := range tests {
+		cfg := &config.Config{Dedup: tt.dedup}
+		a := New(cfg)
+		a.RegisterProvider("aws", &fakeProvider{name: "aws", entries: explorer})
+		a.RegisterProvider("aws-cur", &fakeProvider{name: "aws-cur", entries: cur})
+		result, err := a.Aggregate(context.Background(), day, day.AddDate(0, 1, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.TotalCost != tt.total || result.ByService["EC2"] != tt.ec2 {
+			t.Errorf("%s: total %v with EC2 %v, want %v with EC2 %v", tt.name, result.TotalCost, result.ByService["EC2"], tt.total, tt.ec2)
+		}
+		if result.Duplicates != tt.duplicates || result.DuplicateCost != tt.duplicateCost {
+			t.Errorf("%s: dropped %d duplicates of %v, want %d of %v", tt.name, result.Duplicates, result.DuplicateCost, tt.duplicates, tt.duplicateCost)
+		}
+	}
+}
+
+func TestPreferred(t *testing.T) {
+	got := preferred([]string{"aws", "aws-cur", "azure", "gcp"}, []string{"gcp", "missing", "aws-cur"})
+	if want := []string{"gcp", "aws-cur", "aws", "azure"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("preferred() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,58 @@
+package aggregator_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/synthetic"
+)
+
+// newSynthetic returns a func aggregating n synthetic entries over the days
+// they cover
+func newSynthetic(n int) func() (*aggregator.AggregationResult, error) {
+	entries := synthetic.Cached(n)
+	cfg := &config.Config{}
+	cfg.Applications.Tag = "app"
+	agg := aggregator.New(cfg)
+	for _, p := range synthetic.Providers(entries) {
+		agg.RegisterProvider(p.Name(), p)
+	}
+	start, end := synthetic.Span(entries)
+	return func() (*aggregator.AggregationResult, error) {
+		return agg.Aggregate(context.Background(), start, end)
+	}
+}
+
+func BenchmarkAggregate(b *testing.B) {
+	for _, scale := range synthetic.Scales {
+		b.Run(scale.Name, func(b *testing.B) {
+			aggregate := newSynthetic(scale.Records)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := aggregate(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkTopServices(b *testing.B) {
+	for _, scale := range synthetic.Scales {
+		b.Run(scale.Name, func(b *testing.B) {
+			aggregate := newSynthetic(scale.Records)
+			result, err := aggregate()
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				result.TopServices(10)
+			}
+		})
+	}
+}
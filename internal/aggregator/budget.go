@@ -0,0 +1,333 @@
+package aggregator
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/forecast"
+	"github.com/lvonguyen/finops-platform/internal/money"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+// Budget alert kinds
+const (
+	BudgetActual   = "actual"   // period-to-date spend crossed an alert_at threshold
+	BudgetForecast = "forecast" // period-end projection exceeds the limit
+	BudgetRunRate  = "run_rate" // recent daily burn exceeds what is left per remaining day
+)
+
+// EvaluateBudgets checks each budget against spend so far in its period and
+// a period-end projection. Records are daily records before asOf; those
+// before the period only fit the linear trend that projects the rest of it.
+// A budget under its limit whose projection reaches it gets a forecast
+// alert, and a run-rate budget burning faster than its remaining budget
+// allows per remaining day gets a run-rate alert.
+func (a *Aggregator) EvaluateBudgets(records []normalizer.CostRecord, asOf time.Time) []BudgetAlert {
+	alerts := make([]BudgetAlert, 0)
+
+	byBudget := a.budgetRecords(records, asOf)
+	fis := a.Fiscal()
+	for _, budget := range a.config.Budgets {
+		start, end, ok := BudgetPeriod(budget, asOf, fis)
+		if !ok {
+			continue
+		}
+		limit := BudgetLimit(budget, start, end, fis)
+		if limit <= 0 {
+			continue
+		}
+
+		scoped := byBudget[budget.Name]
+		spend, projected := periodSpend(scoped, start, end, asOf)
+
+		var recent float64
+		burnFrom := asOf.AddDate(0, 0, -runRateDays)
+		if burnFrom.Before(start) {
+			burnFrom = start
+		}
+		for _, r := range scoped {
+			if !r.Date.Before(burnFrom) {
+				recent = money.Add(recent, r.Cost)
+			}
+		}
+
+		alert := BudgetAlert{
+			BudgetName:     budget.Name,
+			Provider:       budget.Provider,
+			Scope:          budget.Scope,
+			Parent:         budget.Parent,
+			Period:         describePeriod(start, end),
+			BudgetLimit:    limit,
+			CurrentSpend:   spend,
+			PercentUsed:    spend / limit * 100,
+			AlertedAt:      time.Now(),
+			ProjectedSpend: projected,
+		}
+		if alertAt, ok := crossedThreshold(budget.AlertAt, alert.PercentUsed); ok {
+			alert.Kind = BudgetActual
+			alert.Threshold = alertAt
+			alert.Severity = budgetSeverity(alertAt)
+			alerts = append(alerts, alert)
+		}
+		if spend < limit && projected >= limit {
+			alert.Kind = BudgetForecast
+			alert.Threshold = 0
+			alert.Severity = "medium"
+			alerts = append(alerts, alert)
+		}
+
+		// Run rate compares the recent daily burn with what may still be
+		// spent on each remaining day
+		days := end.Sub(asOf).Hours() / 24
+		burnDays := asOf.Sub(burnFrom).Hours() / 24
+		if budget.RunRate && spend < limit && days > 0 && burnDays > 0 {
+			alert.DailyBurn = recent / burnDays
+			alert.AllowedBurn = (limit - spend) / days
+			if alert.DailyBurn > alert.AllowedBurn {
+				alert.Kind = BudgetRunRate
+				alert.Threshold = 0
+				alert.Severity = "medium"
+				alerts = append(alerts, alert)
+			}
+		}
+	}
+
+	return alerts
+}
+
+// ProjectBudgets returns, by budget name, each budget's limit, spend so far
+// and period-end projection for its period containing asOf, computed as in
+// EvaluateBudgets. Budgets without a limit or a period containing asOf are
+// left out.
+func (a *Aggregator) ProjectBudgets(records []normalizer.CostRecord, asOf time.Time) map[string]BudgetStatus {
+	statuses := make(map[string]BudgetStatus)
+	byBudget := a.budgetRecords(records, asOf)
+	fis := a.Fiscal()
+	for _, budget := range a.config.Budgets {
+		start, end, ok := BudgetPeriod(budget, asOf, fis)
+		if !ok {
+			continue
+		}
+		limit := BudgetLimit(budget, start, end, fis)
+		if limit <= 0 {
+			continue
+		}
+		spend, projected := periodSpend(byBudget[budget.Name], start, end, asOf)
+		statuses[budget.Name] = BudgetStatus{
+			BudgetName:    budget.Name,
+			Provider:      budget.Provider,
+			Scope:         budget.Scope,
+			Limit:         limit,
+			CurrentSpend:  spend,
+			ForecastSpend: projected,
+		}
+	}
+	return statuses
+}
+
+// budgetRecords returns the records before asOf counting against each
+// budget, their cost narrowed to the budget's share. A parent's records are
+// its children's.
+func (a *Aggregator) budgetRecords(records []normalizer.CostRecord, asOf time.Time) map[string][]normalizer.CostRecord {
+	return rollupBudgets(a.config.Budgets, func(b config.Budget) []normalizer.CostRecord {
+		scoped := make([]normalizer.CostRecord, 0)
+		for _, r := range records {
+			if !r.Date.Before(asOf) {
+				continue
+			}
+			share := a.budgetShare(b, r)
+			if share == 0 {
+				continue
+			}
+			r.Cost = share
+			scoped = append(scoped, r)
+		}
+		return scoped
+	}, func(x, y []normalizer.CostRecord) []normalizer.CostRecord {
+		return append(x, y...)
+	})
+}
+
+// periodSpend returns a budget's spend from start and its projection to the
+// period end, extending the linear trend of all its records
+func periodSpend(scoped []normalizer.CostRecord, start, end, asOf time.Time) (spend, projected float64) {
+	for _, r := range scoped {
+		if !r.Date.Before(start) {
+			spend = money.Add(spend, r.Cost)
+		}
+	}
+	projected = spend
+	if len(scoped) > 0 && asOf.Before(end) {
+		projected += forecast.Linear(scoped, asOf, end).BaseTotal
+	}
+	return spend, projected
+}
+
+// BudgetShare returns the part of a record's cost that counts against a
+// budget: records of its provider (every provider for "all"), narrowed to
+// its account scope and, weighted by the application tag, its application
+func BudgetShare(b config.Budget, r normalizer.CostRecord, appTag string) float64 {
+	if b.Provider != "" && b.Provider != "all" && b.Provider != r.Cloud {
+		return 0
+	}
+	if b.Scope != "" && b.Scope != r.Account {
+		return 0
+	}
+	if b.Application == "" {
+		return r.Cost
+	}
+	if appTag == "" {
+		return 0
+	}
+	for _, s := range applicationSplits(r.Tags[appTag]) {
+		if s.Key == b.Application {
+			return r.Cost * s.Weight
+		}
+	}
+	return 0
+}
+
+// budgetShare narrows BudgetShare to the budget's cost center, as assigned
+// by the function set with SetCostCenters
+func (a *Aggregator) budgetShare(b config.Budget, r normalizer.CostRecord) float64 {
+	if b.CostCenter != "" {
+		a.mu.RLock()
+		costCenter := a.costCenter
+		a.mu.RUnlock()
+		if costCenter == nil || costCenter(r) != b.CostCenter {
+			return 0
+		}
+	}
+	return BudgetShare(b, r, a.config.Applications.Tag)
+}
+
+// SetCostCenters sets how records map to chargeback cost centers for
+// budgets with a cost_center
+func (a *Aggregator) SetCostCenters(costCenter func(normalizer.CostRecord) string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.costCenter = costCenter
+}
+
+// ValidateBudgets checks budget periods and limits, and that every budget
+// parent exists without parents forming a cycle
+func ValidateBudgets(budgets []config.Budget) error {
+	parents := make(map[string]string, len(budgets))
+	for _, b := range budgets {
+		if _, dup := parents[b.Name]; dup {
+			return fmt.Errorf("budget %q is defined twice", b.Name)
+		}
+		parents[b.Name] = b.Parent
+		if err := validatePeriod(b); err != nil {
+			return fmt.Errorf("budget %q: %w", b.Name, err)
+		}
+		if b.Limit < 0 || b.MonthlyLimit < 0 {
+			return fmt.Errorf("budget %q: limit must not be negative", b.Name)
+		}
+		alerts := make(map[int]bool, len(b.AlertAt))
+		for _, pct := range b.AlertAt {
+			if pct <= 0 {
+				return fmt.Errorf("budget %q: alert_at %d must be a positive percentage", b.Name, pct)
+			}
+			if alerts[pct] {
+				return fmt.Errorf("budget %q: alert_at lists %d%% twice", b.Name, pct)
+			}
+			alerts[pct] = true
+		}
+	}
+	for _, b := range budgets {
+		seen := map[string]bool{b.Name: true}
+		for p := b.Parent; p != ""; p = parents[p] {
+			if _, ok := parents[p]; !ok {
+				return fmt.Errorf("budget %q: parent %q is not a budget", b.Name, p)
+			}
+			if seen[p] {
+				return fmt.Errorf("budget %q: parents form a cycle through %q", b.Name, p)
+			}
+			seen[p] = true
+		}
+	}
+	return nil
+}
+
+// rollupBudgets computes a value per budget name: leaf for budgets without
+// children and, for parents, their children's values combined, so spend
+// rolls up from teams to business units to the organization
+func rollupBudgets[T any](budgets []config.Budget, leaf func(config.Budget) T, combine func(T, T) T) map[string]T {
+	children := make(map[string][]config.Budget)
+	for _, b := range budgets {
+		if b.Parent != "" {
+			children[b.Parent] = append(children[b.Parent], b)
+		}
+	}
+
+	values := make(map[string]T, len(budgets))
+	var value func(b config.Budget, depth int) T
+	value = func(b config.Budget, depth int) T {
+		if v, ok := values[b.Name]; ok {
+			return v
+		}
+		var v T
+		switch kids := children[b.Name]; {
+		case len(kids) == 0:
+			v = leaf(b)
+		case depth <= len(budgets): // guards against unvalidated cycles
+			for _, kid := range kids {
+				v = combine(v, value(kid, depth+1))
+			}
+		}
+		values[b.Name] = v
+		return v
+	}
+	for _, b := range budgets {
+		value(b, 0)
+	}
+	return values
+}
+
+// crossedThreshold returns the highest alert_at percentage reached
+func crossedThreshold(alertAt []int, percentUsed float64) (int, bool) {
+	highest, ok := 0, false
+	for _, t := range alertAt {
+		if percentUsed >= float64(t) && (!ok || t > highest) {
+			highest, ok = t, true
+		}
+	}
+	return highest, ok
+}
+
+func budgetSeverity(alertAt int) string {
+	switch {
+	case alertAt >= 90:
+		return "high"
+	case alertAt >= 75:
+		return "medium"
+	case alertAt >= 50:
+		return "low"
+	}
+	return "info"
+}
+
+// key separates a budget's forecast alerts from its threshold alerts
+func (b BudgetAlert) key() string {
+	switch b.Kind {
+	case BudgetForecast:
+		return b.BudgetName + " (forecast)"
+	case BudgetRunRate:
+		return b.BudgetName + " (run rate)"
+	}
+	return b.BudgetName
+}
+
+func (b BudgetAlert) summary() string {
+	switch b.Kind {
+	case BudgetForecast:
+		return fmt.Sprintf("%s projected to reach %.1f%% of budget by period end ($%.2f / $%.2f, $%.2f so far)",
+			b.BudgetName, b.ProjectedSpend/b.BudgetLimit*100, b.ProjectedSpend, b.BudgetLimit, b.CurrentSpend)
+	case BudgetRunRate:
+		return fmt.Sprintf("%s burning $%.2f/day, above the $%.2f/day left to stay within budget ($%.2f / $%.2f)",
+			b.BudgetName, b.DailyBurn, b.AllowedBurn, b.CurrentSpend, b.BudgetLimit)
+	}
+	return fmt.Sprintf("%s at %.1f%% of budget ($%.2f / $%.2f)", b.BudgetName, b.PercentUsed, b.CurrentSpend, b.BudgetLimit)
+}
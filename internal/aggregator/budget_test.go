@@ -0,0 +1,188 @@
+package aggregator
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+// daily returns a record of cost on each day from start until end
+func daily(cloud string, start, end time.Time, cost float64) []normalizer.CostRecord {
+	var records []normalizer.CostRecord
+	for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+		records = append(records, normalizer.CostRecord{Cloud: cloud, Account: "111", Service: "EC2", Date: d, Cost: cost})
+	}
+	return records
+}
+
+// near reports whether two amounts agree to a millionth
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+// alertList lists alerts as budget/kind/threshold/severity
+func alertList(alerts []BudgetAlert) string {
+	lines := make([]string, len(alerts))
+	for i, a := range alerts {
+		lines[i] = strings.Join([]string{a.BudgetName, a.Kind, strconv.Itoa(a.Threshold), a.Severity}, "/")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestEvaluateBudgets(t *testing.T) {
+	// 40 a day on AWS through March 15, so 600 so far and 1240 by month end
+	asOf := time.Date(2024, 3, 16, 0, 0, 0, 0, time.UTC)
+	records := daily("aws", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), asOf, 40)
+	records = append(records, daily("aws", asOf, asOf.AddDate(0, 0, 3), 1000)...) // after asOf, ignored
+
+	a := New(&config.Config{Budgets: []config.Budget{
+		{Name: "tight", Provider: "aws", MonthlyLimit: 1000, AlertAt: []int{50, 75, 90}},
+		{Name: "roomy", Provider: "all", MonthlyLimit: 2000, AlertAt: []int{50}},
+		{Name: "over", Provider: "aws", MonthlyLimit: 500, AlertAt: []int{80, 100}},
+		{Name: "gcp", Provider: "gcp", MonthlyLimit: 10, AlertAt: []int{50}},
+		{Name: "unlimited", Provider: "aws", AlertAt: []int{50}},
+	}})
+	alerts := a.EvaluateBudgets(records, asOf)
+
+	want := strings.Join([]string{
+		"tight/actual/50/low",
+		"tight/forecast/0/medium",
+		"over/actual/100/high",
+	}, "\n")
+	if got := alertList(alerts); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	tight := alerts[0]
+	if tight.CurrentSpend != 600 || tight.PercentUsed != 60 || !near(tight.ProjectedSpend, 1240) || tight.Period != "2024-03-01 to 2024-03-31" {
+		t.Errorf("tight alert = %+v, want 600 spent (60%%) projected to 1240 in March", tight)
+	}
+	if !strings.Contains(alerts[1].summary(), "projected to reach 124.0% of budget") {
+		t.Errorf("forecast summary = %s", alerts[1].summary())
+	}
+
+	statuses := a.ProjectBudgets(records, asOf)
+	if s := statuses["roomy"]; s.CurrentSpend != 600 || !near(s.ForecastSpend, 1240) || s.Limit != 2000 {
+		t.Errorf("roomy status = %+v", s)
+	}
+	if _, ok := statuses["unlimited"]; ok {
+		t.Error("budget without a limit was projected")
+	}
+}
+
+func TestValidateBudgets(t *testing.T) {
+	valid := []config.Budget{{Name: "org"}, {Name: "bu", Parent: "org"}, {Name: "team", Parent: "bu"}}
+	if err := ValidateBudgets(valid); err != nil {
+		t.Errorf("ValidateBudgets() = %v, want nil", err)
+	}
+	for name, budgets := range map[string][]config.Budget{
+		"duplicate":      {{Name: "org"}, {Name: "org"}},
+		"missing parent": {{Name: "team", Parent: "bu"}},
+		"cycle":          {{Name: "a", Parent: "c"}, {Name: "b", Parent: "a"}, {Name: "c", Parent: "b"}},
+		"own parent":     {{Name: "a", Parent: "a"}},
+	} {
+		if err := ValidateBudgets(budgets); err == nil {
+			t.Errorf("%s: ValidateBudgets() succeeded, want an error", name)
+		}
+	}
+}
+
+func TestRollupBudgets(t *testing.T) {
+	leaves := map[string]float64{"org": 1000, "bu": 100, "team-a": 1, "team-b": 2, "team-c": 4}
+	leaf := func(b config.Budget) float64 { return leaves[b.Name] }
+	sum := func(x, y float64) float64 { return x + y }
+
+	got := rollupBudgets([]config.Budget{
+		{Name: "org"},
+		{Name: "team-a", Parent: "bu"},
+		{Name: "bu", Parent: "org"},
+		{Name: "team-b", Parent: "bu"},
+		{Name: "team-c", Parent: "org"},
+	}, leaf, sum)
+	want := map[string]float64{"org": 7, "bu": 3, "team-a": 1, "team-b": 2, "team-c": 4}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rollupBudgets() = %v, want %v", got, want)
+	}
+
+	// Unvalidated cycles end rather than recursing forever
+	cyclic := rollupBudgets([]config.Budget{{Name: "team-a", Parent: "team-b"}, {Name: "team-b", Parent: "team-a"}}, leaf, sum)
+	if len(cyclic) != 2 {
+		t.Errorf("cyclic rollup = %v, want a value per budget", cyclic)
+	}
+}
+
+// TestEvaluateBudgetsRollup has two teams on their own accounts under an
+// organization budget, which only counts its teams' spend
+func TestEvaluateBudgetsRollup(t *testing.T) {
+	asOf := time.Date(2024, 3, 16, 0, 0, 0, 0, time.UTC)
+	monthStart := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	records := daily("aws", monthStart, asOf, 40)
+	for _, r := range daily("aws", monthStart, asOf, 10) {
+		r.Account = "222"
+		records = append(records, r)
+	}
+	records = append(records, normalizer.CostRecord{Cloud: "aws", Account: "333", Date: monthStart, Cost: 5000}) // no team's
+
+	a := New(&config.Config{Budgets: []config.Budget{
+		{Name: "org", Provider: "aws", MonthlyLimit: 800, AlertAt: []int{50}},
+		{Name: "team-a", Provider: "aws", Scope: "111", Parent: "org", MonthlyLimit: 500, AlertAt: []int{100}},
+		{Name: "team-b", Provider: "aws", Scope: "222", Parent: "org", MonthlyLimit: 1000, AlertAt: []int{50}},
+	}})
+	alerts := a.EvaluateBudgets(records, asOf)
+
+	// team-a's overspend counts towards the organization's 750 of 800
+	want := strings.Join([]string{
+		"org/actual/50/low",
+		"org/forecast/0/medium",
+		"team-a/actual/100/high",
+	}, "\n")
+	if got := alertList(alerts); got != want {
+		t.Fatalf("got\n%s\nwant\n%s", got, want)
+	}
+	if alerts[0].CurrentSpend != 750 || alerts[0].Parent != "" || alerts[2].CurrentSpend != 600 || alerts[2].Parent != "org" {
+		t.Errorf("alerts = %+v, want org at 750 and team-a at 600 rolling up into org", alerts)
+	}
+
+	result := &AggregationResult{ByAccount: map[string]float64{"111": 600, "222": 150, "333": 5000}}
+	for _, alert := range a.CheckBudgets(result) {
+		if alert.BudgetName == "org" && alert.CurrentSpend != 750 {
+			t.Errorf("CheckBudgets() org spend = %v, want its teams' 750", alert.CurrentSpend)
+		}
+	}
+}
+
+func TestCheckBudgetsRollupInMicros(t *testing.T) {
+	a := New(&config.Config{Budgets: []config.Budget{
+		{Name: "org", Provider: "aws", MonthlyLimit: 0.3, AlertAt: []int{100}},
+		{Name: "team-a", Provider: "aws", Scope: "111", Parent: "org", MonthlyLimit: 100},
+		{Name: "team-b", Provider: "aws", Scope: "222", Parent: "org", MonthlyLimit: 100},
+	}})
+	alerts := a.CheckBudgets(&AggregationResult{ByAccount: map[string]float64{"111": 0.1, "222": 0.2}})
+	if len(alerts) != 1 || alerts[0].CurrentSpend != 0.3 {
+		t.Errorf("alerts = %+v, want org at exactly 0.3", alerts)
+	}
+}
+
+func TestCostCenterBudget(t *testing.T) {
+	asOf := time.Date(2024, 3, 16, 0, 0, 0, 0, time.UTC)
+	var records []normalizer.CostRecord
+	for i, r := range daily("aws", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), asOf, 10) {
+		r.Tags = map[string]string{"cost_center": []string{"CC-1", "CC-2"}[i%2]}
+		records = append(records, r)
+	}
+	a := New(&config.Config{Budgets: []config.Budget{{Name: "cc-1", CostCenter: "CC-1", MonthlyLimit: 100}}})
+
+	// Without a cost center mapping nothing counts
+	if s := a.ProjectBudgets(records, asOf)["cc-1"]; s.CurrentSpend != 0 {
+		t.Errorf("unmapped spend = %v, want 0", s.CurrentSpend)
+	}
+	a.SetCostCenters(func(r normalizer.CostRecord) string { return r.Tags["cost_center"] })
+	if s := a.ProjectBudgets(records, asOf)["cc-1"]; s.CurrentSpend != 80 {
+		t.Errorf("CC-1 spend = %v, want 80 from its 8 days", s.CurrentSpend)
+	}
+}
@@ -0,0 +1,103 @@
+package aggregator
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/calendar"
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+// Budget periods, of the fiscal calendar (see calendar.Fiscal)
+const (
+	PeriodMonthly   = "monthly"   // fiscal month (default)
+	PeriodQuarterly = "quarterly" // fiscal quarter
+	PeriodAnnual    = "annual"    // fiscal year
+	PeriodCustom    = "custom"    // start to end, inclusive
+)
+
+// runRateDays is how many recent days a run-rate budget's daily burn is
+// averaged over
+const runRateDays = 7
+
+// BudgetPeriod returns the budget's period containing asOf as [start, end),
+// in the fiscal calendar fis (nil for calendar months). It returns false
+// when asOf is outside a custom period; asOf at the end of a period still
+// evaluates that period, as its last day's data is complete.
+func BudgetPeriod(b config.Budget, asOf time.Time, fis *calendar.Fiscal) (start, end time.Time, ok bool) {
+	switch b.Period {
+	case "", PeriodMonthly:
+		start, end = fis.Month(fis.MonthOf(asOf))
+		return start, end, true
+	case PeriodQuarterly:
+		start, end = fis.Quarter(asOf)
+		return start, end, true
+	case PeriodAnnual:
+		start, end = fis.Year(asOf)
+		return start, end, true
+	case PeriodCustom:
+		start, err := time.Parse("2006-01-02", b.Start)
+		if err != nil {
+			return time.Time{}, time.Time{}, false
+		}
+		last, err := time.Parse("2006-01-02", b.End)
+		if err != nil {
+			return time.Time{}, time.Time{}, false
+		}
+		end = last.AddDate(0, 0, 1)
+		return start, end, !asOf.Before(start) && !asOf.After(end)
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+// BudgetLimit returns the budget's limit for a period: limit when set, else
+// monthly_limit prorated by the share of each fiscal month the period covers
+func BudgetLimit(b config.Budget, start, end time.Time, fis *calendar.Fiscal) float64 {
+	if b.Limit > 0 {
+		return b.Limit
+	}
+	var limit float64
+	for name := fis.MonthOf(start); ; name = name.AddDate(0, 1, 0) {
+		month, next := fis.Month(name)
+		if !month.Before(end) {
+			break
+		}
+		from, to := month, next
+		if start.After(from) {
+			from = start
+		}
+		if end.Before(to) {
+			to = end
+		}
+		limit += b.MonthlyLimit * to.Sub(from).Hours() / next.Sub(month).Hours()
+	}
+	return limit
+}
+
+// validatePeriod checks a budget's period settings
+func validatePeriod(b config.Budget) error {
+	switch b.Period {
+	case "", PeriodMonthly, PeriodQuarterly, PeriodAnnual:
+		return nil
+	case PeriodCustom:
+		start, err := time.Parse("2006-01-02", b.Start)
+		if err != nil {
+			return fmt.Errorf("custom period needs start as YYYY-MM-DD: %w", err)
+		}
+		end, err := time.Parse("2006-01-02", b.End)
+		if err != nil {
+			return fmt.Errorf("custom period needs end as YYYY-MM-DD: %w", err)
+		}
+		if end.Before(start) {
+			return fmt.Errorf("custom period ends before it starts")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown period %q (want monthly, quarterly, annual or custom)", b.Period)
+	}
+}
+
+// describePeriod labels a period by its first and last day
+func describePeriod(start, end time.Time) string {
+	return start.Format("2006-01-02") + " to " + end.AddDate(0, 0, -1).Format("2006-01-02")
+}
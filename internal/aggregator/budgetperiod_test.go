@@ -0,0 +1,210 @@
+package aggregator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/calendar"
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+// date returns a UTC midnight
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestBudgetPeriod(t *testing.T) {
+	asOf := date(2024, 5, 15)
+	project := config.Budget{Period: PeriodCustom, Start: "2024-05-10", End: "2024-06-09"}
+	tests := []struct {
+		name       string
+		budget     config.Budget
+		asOf       time.Time
+		start, end time.Time
+		ok         bool
+	}{
+		{"default", config.Budget{}, asOf, date(2024, 5, 1), date(2024, 6, 1), true},
+		{"monthly", config.Budget{Period: PeriodMonthly}, asOf, date(2024, 5, 1), date(2024, 6, 1), true},
+		{"quarterly", config.Budget{Period: PeriodQuarterly}, asOf, date(2024, 4, 1), date(2024, 7, 1), true},
+		{"annual", config.Budget{Period: PeriodAnnual}, asOf, date(2024, 1, 1), date(2025, 1, 1), true},
+		{"custom", project, asOf, date(2024, 5, 10), date(2024, 6, 10), true},
+		{"custom, last day complete", project, date(2024, 6, 10), date(2024, 5, 10), date(2024, 6, 10), true},
+		{"custom, not started", project, date(2024, 5, 9), date(2024, 5, 10), date(2024, 6, 10), false},
+		{"custom, over", project, date(2024, 6, 11), date(2024, 5, 10), date(2024, 6, 10), false},
+		{"custom without dates", config.Budget{Period: PeriodCustom}, asOf, time.Time{}, time.Time{}, false},
+		{"unknown", config.Budget{Period: "weekly"}, asOf, time.Time{}, time.Time{}, false},
+	}
+	for _, tt := range tests {
+		start, end, ok := BudgetPeriod(tt.budget, tt.asOf, nil)
+		if ok != tt.ok || !start.Equal(tt.start) || !end.Equal(tt.end) {
+			t.Errorf("%s: BudgetPeriod() = %s, %s, %v; want %s, %s, %v", tt.name, start, end, ok, tt.start, tt.end, tt.ok)
+		}
+	}
+}
+
+func TestBudgetLimit(t *testing.T) {
+	tests := []struct {
+		name       string
+		budget     config.Budget
+		start, end time.Time
+		want       float64
+	}{
+		{"limit", config.Budget{Limit: 5000, MonthlyLimit: 310}, date(2024, 4, 1), date(2024, 7, 1), 5000},
+		{"month", config.Budget{MonthlyLimit: 310}, date(2024, 5, 1), date(2024, 6, 1), 310},
+		{"quarter", config.Budget{MonthlyLimit: 310}, date(2024, 4, 1), date(2024, 7, 1), 930},
+		// 22 of May's 31 days and 9 of June's 30
+		{"prorated", config.Budget{MonthlyLimit: 310}, date(2024, 5, 10), date(2024, 6, 10), 220 + 93},
+		{"none", config.Budget{}, date(2024, 5, 1), date(2024, 6, 1), 0},
+	}
+	for _, tt := range tests {
+		if got := BudgetLimit(tt.budget, tt.start, tt.end, nil); !near(got, tt.want) {
+			t.Errorf("%s: BudgetLimit() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFiscalBudgetPeriods(t *testing.T) {
+	april, err := calendar.FiscalFromConfig(config.CalendarConfig{FiscalYearStart: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	weeks, err := calendar.FiscalFromConfig(config.CalendarConfig{Periods: "4-4-5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		fis        *calendar.Fiscal
+		budget     config.Budget
+		asOf       time.Time
+		start, end time.Time
+		limit      float64
+	}{
+		{"April quarter", april, config.Budget{Period: PeriodQuarterly, MonthlyLimit: 310}, date(2024, 5, 15), date(2024, 4, 1), date(2024, 7, 1), 930},
+		{"April year", april, config.Budget{Period: PeriodAnnual, MonthlyLimit: 310}, date(2024, 2, 15), date(2023, 4, 1), date(2024, 4, 1), 3720},
+		{"4-4-5 month", weeks, config.Budget{MonthlyLimit: 280}, date(2024, 2, 10), date(2024, 1, 29), date(2024, 2, 26), 280},
+		{"4-4-5 quarter", weeks, config.Budget{Period: PeriodQuarterly, MonthlyLimit: 280}, date(2024, 2, 10), date(2024, 1, 1), date(2024, 4, 1), 840},
+		// 14 of January's 28 days and all of February
+		{"4-4-5 custom", weeks, config.Budget{Period: PeriodCustom, Start: "2024-01-15", End: "2024-02-25", MonthlyLimit: 280}, date(2024, 2, 10), date(2024, 1, 15), date(2024, 2, 26), 140 + 280},
+	}
+	for _, tt := range tests {
+		start, end, ok := BudgetPeriod(tt.budget, tt.asOf, tt.fis)
+		if !ok || !start.Equal(tt.start) || !end.Equal(tt.end) {
+			t.Errorf("%s: BudgetPeriod() = %s, %s, %v; want %s, %s", tt.name, start, end, ok, tt.start, tt.end)
+		}
+		if got := BudgetLimit(tt.budget, start, end, tt.fis); !near(got, tt.limit) {
+			t.Errorf("%s: BudgetLimit() = %v, want %v", tt.name, got, tt.limit)
+		}
+	}
+}
+
+func TestEvaluateFiscalBudgets(t *testing.T) {
+	// 40 a day since April 1, the fiscal year's first day
+	asOf := date(2024, 5, 16)
+	fis, err := calendar.FiscalFromConfig(config.CalendarConfig{FiscalYearStart: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := New(&config.Config{Budgets: []config.Budget{
+		{Name: "quarter", Period: PeriodQuarterly, MonthlyLimit: 1500, AlertAt: []int{25}},
+	}})
+	a.SetFiscal(fis)
+	if a.Fiscal() != fis {
+		t.Fatal("Fiscal() does not return the calendar set")
+	}
+	alerts := a.EvaluateBudgets(daily("aws", date(2024, 4, 1), asOf, 40), asOf)
+	if len(alerts) != 1 {
+		t.Fatalf("got %d alerts, want the quarter's", len(alerts))
+	}
+	q := alerts[0]
+	if q.BudgetLimit != 4500 || q.CurrentSpend != 1800 || q.Period != "2024-04-01 to 2024-06-30" {
+		t.Errorf("quarter alert = %+v, want 1800 of 4500 from Apr 1 to Jun 30", q)
+	}
+}
+
+func TestValidateBudgetPeriods(t *testing.T) {
+	valid := []config.Budget{
+		{Name: "month", MonthlyLimit: 100, AlertAt: []int{50, 80, 100}},
+		{Name: "quarter", Period: PeriodQuarterly, Limit: 300},
+		{Name: "project", Period: PeriodCustom, Start: "2024-05-10", End: "2024-05-10", Limit: 10},
+	}
+	if err := ValidateBudgets(valid); err != nil {
+		t.Errorf("ValidateBudgets() = %v, want nil", err)
+	}
+	for name, b := range map[string]config.Budget{
+		"unknown period": {Name: "b", Period: "weekly"},
+		"no start":       {Name: "b", Period: PeriodCustom, End: "2024-05-10"},
+		"bad end":        {Name: "b", Period: PeriodCustom, Start: "2024-05-10", End: "June"},
+		"backwards":      {Name: "b", Period: PeriodCustom, Start: "2024-05-10", End: "2024-05-09"},
+		"negative limit": {Name: "b", Limit: -1},
+		"zero alert":     {Name: "b", MonthlyLimit: 100, AlertAt: []int{0, 50}},
+		"repeated alert": {Name: "b", MonthlyLimit: 100, AlertAt: []int{80, 100, 80}},
+	} {
+		if err := ValidateBudgets([]config.Budget{b}); err == nil {
+			t.Errorf("%s: ValidateBudgets() succeeded, want an error", name)
+		}
+	}
+}
+
+func TestEvaluateBudgetPeriods(t *testing.T) {
+	// 40 a day since January, so 3000 so far this quarter; the January
+	// project budget is over
+	asOf := date(2024, 3, 16)
+	records := daily("aws", date(2024, 1, 1), asOf, 40)
+	a := New(&config.Config{Budgets: []config.Budget{
+		{Name: "quarter", Period: PeriodQuarterly, MonthlyLimit: 1500, AlertAt: []int{50, 100}},
+		{Name: "year", Period: PeriodAnnual, Limit: 30000, AlertAt: []int{10}},
+		{Name: "done", Period: PeriodCustom, Start: "2024-01-01", End: "2024-01-31", Limit: 100, AlertAt: []int{100}},
+	}})
+	alerts := a.EvaluateBudgets(records, asOf)
+
+	want := "quarter/actual/50/low\nyear/actual/10/info"
+	if got := alertList(alerts); got != want {
+		t.Fatalf("got\n%s\nwant\n%s", got, want)
+	}
+	q := alerts[0]
+	if q.BudgetLimit != 4500 || q.CurrentSpend != 3000 || q.Period != "2024-01-01 to 2024-03-31" {
+		t.Errorf("quarter alert = %+v, want 3000 of 4500 from Jan 1 to Mar 31", q)
+	}
+	if !near(q.ProjectedSpend, 3640) {
+		t.Errorf("quarter projection = %v, want 3640 by the end of March", q.ProjectedSpend)
+	}
+}
+
+func TestEvaluateRunRate(t *testing.T) {
+	// A 30-day project budget of 3000, half gone by day 16
+	asOf := date(2024, 3, 16)
+	budget := config.Budget{Name: "project", Period: PeriodCustom, Start: "2024-03-01", End: "2024-03-30", Limit: 3000, RunRate: true}
+	tests := []struct {
+		name   string
+		recent float64 // daily cost over the last week
+		alert  bool
+	}{
+		{"burning fast", 150, true},
+		{"within budget", 100, false},
+	}
+	for _, tt := range tests {
+		records := daily("aws", date(2024, 3, 1), date(2024, 3, 9), 20)
+		records = append(records, daily("aws", date(2024, 3, 9), asOf, tt.recent)...)
+		a := New(&config.Config{Budgets: []config.Budget{budget}})
+
+		var got *BudgetAlert
+		alerts := a.EvaluateBudgets(records, asOf)
+		for i := range alerts {
+			if alerts[i].Kind == BudgetRunRate {
+				got = &alerts[i]
+			}
+		}
+		if (got != nil) != tt.alert {
+			t.Errorf("%s: run-rate alert %+v, want one: %v", tt.name, got, tt.alert)
+			continue
+		}
+		if got == nil {
+			continue
+		}
+		spend := 8*20 + 7*tt.recent
+		if !near(got.DailyBurn, tt.recent) || !near(got.AllowedBurn, (3000-spend)/15) || got.Severity != "medium" {
+			t.Errorf("%s: alert = %+v, want %v a day against %v", tt.name, got, tt.recent, (3000-spend)/15)
+		}
+	}
+}
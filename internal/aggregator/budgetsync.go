@@ -0,0 +1,190 @@
+package aggregator
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+// Budget drift kinds
+const (
+	DriftMissing   = "missing"   // declared but not in the cloud
+	DriftLimit     = "limit"     // the cloud budget's monthly limit differs
+	DriftScope     = "scope"     // the cloud budget covers another account
+	DriftUnmanaged = "unmanaged" // in the cloud but not declared
+)
+
+// BudgetDrift is a difference between a declared budget and the cloud's
+type BudgetDrift struct {
+	Kind       string  `json:"kind"`
+	BudgetName string  `json:"budget_name"`
+	Provider   string  `json:"provider"`
+	Scope      string  `json:"scope,omitempty"`       // declared
+	CloudScope string  `json:"cloud_scope,omitempty"` // in the cloud
+	Limit      float64 `json:"limit,omitempty"`       // declared
+	CloudLimit float64 `json:"cloud_limit,omitempty"` // in the cloud
+	Created    bool    `json:"created,omitempty"`
+	Error      string  `json:"error,omitempty"` // why a missing budget was not created
+}
+
+// BudgetSync is the outcome of reconciling declared and cloud budgets
+type BudgetSync struct {
+	InSync  []string          `json:"in_sync"`
+	Drift   []BudgetDrift     `json:"drift"`
+	Skipped map[string]string `json:"skipped,omitempty"` // declared budget -> why it was not compared
+	Errors  map[string]string `json:"errors,omitempty"`  // provider -> why its budgets could not be listed
+}
+
+// Unresolved reports drift left after the sync: anything but created budgets
+func (s BudgetSync) Unresolved() bool {
+	for _, d := range s.Drift {
+		if !d.Created {
+			return true
+		}
+	}
+	return len(s.Errors) > 0
+}
+
+// SyncBudgets compares the declared budgets with each registered provider's
+// cloud budgets, matched by provider and name. With create, missing budgets
+// are created on providers implementing BudgetWriter; existing budgets are
+// never changed. Budgets spanning every provider or scoped to an application
+// have no cloud counterpart and are skipped.
+func (a *Aggregator) SyncBudgets(ctx context.Context, create bool) BudgetSync {
+	a.mu.RLock()
+	providers := make(map[string]CostProvider, len(a.providers))
+	for name, p := range a.providers {
+		providers[name] = p
+	}
+	a.mu.RUnlock()
+
+	sync := BudgetSync{Skipped: make(map[string]string), Errors: make(map[string]string)}
+	cloud := make(map[string][]BudgetStatus)
+	for name, p := range providers {
+		budgets, err := p.GetBudgets(ctx)
+		if err != nil {
+			sync.Errors[name] = err.Error()
+			continue
+		}
+		cloud[name] = budgets
+	}
+
+	parents := make(map[string]bool)
+	for _, b := range a.config.Budgets {
+		parents[b.Parent] = true
+	}
+
+	matched := make(map[string]map[int]bool)
+	for _, b := range a.config.Budgets {
+		if b.Limit > 0 {
+			b.MonthlyLimit = b.Limit
+		}
+		provider, ok := providers[b.Provider]
+		switch {
+		case b.Period != "" && b.Period != PeriodMonthly:
+			sync.Skipped[b.Name] = "not a monthly budget"
+			continue
+		case parents[b.Name]:
+			sync.Skipped[b.Name] = "rolls up child budgets"
+			continue
+		case b.Provider == "" || b.Provider == "all":
+			sync.Skipped[b.Name] = "spans every provider"
+			continue
+		case b.Application != "":
+			sync.Skipped[b.Name] = "scoped to an application"
+			continue
+		case b.CostCenter != "":
+			sync.Skipped[b.Name] = "scoped to a cost center"
+			continue
+		case !ok:
+			sync.Skipped[b.Name] = "provider " + b.Provider + " is not registered"
+			continue
+		}
+		if _, failed := sync.Errors[b.Provider]; failed {
+			continue
+		}
+
+		i := matchBudget(cloud[b.Provider], b)
+		if i < 0 {
+			drift := BudgetDrift{Kind: DriftMissing, BudgetName: b.Name, Provider: b.Provider, Scope: b.Scope, Limit: b.MonthlyLimit}
+			if create {
+				drift.Created, drift.Error = createBudget(ctx, provider, b)
+			}
+			sync.Drift = append(sync.Drift, drift)
+			continue
+		}
+
+		if matched[b.Provider] == nil {
+			matched[b.Provider] = make(map[int]bool)
+		}
+		matched[b.Provider][i] = true
+		actual := cloud[b.Provider][i]
+		inSync := true
+		if math.Abs(actual.Limit-b.MonthlyLimit) >= 0.005 {
+			sync.Drift = append(sync.Drift, BudgetDrift{Kind: DriftLimit, BudgetName: b.Name, Provider: b.Provider,
+				Scope: b.Scope, CloudScope: actual.Scope, Limit: b.MonthlyLimit, CloudLimit: actual.Limit})
+			inSync = false
+		}
+		if !strings.EqualFold(actual.Scope, b.Scope) {
+			sync.Drift = append(sync.Drift, BudgetDrift{Kind: DriftScope, BudgetName: b.Name, Provider: b.Provider,
+				Scope: b.Scope, CloudScope: actual.Scope, Limit: b.MonthlyLimit, CloudLimit: actual.Limit})
+			inSync = false
+		}
+		if inSync {
+			sync.InSync = append(sync.InSync, b.Name)
+		}
+	}
+
+	for name, budgets := range cloud {
+		for i, actual := range budgets {
+			if !matched[name][i] {
+				sync.Drift = append(sync.Drift, BudgetDrift{Kind: DriftUnmanaged, BudgetName: actual.BudgetName, Provider: name,
+					CloudScope: actual.Scope, CloudLimit: actual.Limit})
+			}
+		}
+	}
+
+	sort.SliceStable(sync.Drift, func(i, j int) bool {
+		if sync.Drift[i].Provider != sync.Drift[j].Provider {
+			return sync.Drift[i].Provider < sync.Drift[j].Provider
+		}
+		return sync.Drift[i].BudgetName < sync.Drift[j].BudgetName
+	})
+	return sync
+}
+
+// matchBudget returns the index of the cloud budget named like b,
+// preferring one in its scope, or -1
+func matchBudget(budgets []BudgetStatus, b config.Budget) int {
+	found := -1
+	for i, actual := range budgets {
+		if actual.BudgetName != b.Name {
+			continue
+		}
+		if strings.EqualFold(actual.Scope, b.Scope) {
+			return i
+		}
+		if found < 0 {
+			found = i
+		}
+	}
+	return found
+}
+
+// createBudget creates a missing budget, reporting why it could not
+func createBudget(ctx context.Context, p CostProvider, b config.Budget) (bool, string) {
+	writer, ok := p.(BudgetWriter)
+	if !ok {
+		return false, p.Name() + " cannot create budgets"
+	}
+	if b.MonthlyLimit <= 0 {
+		return false, "monthly_limit is not set"
+	}
+	if err := writer.CreateBudget(ctx, b); err != nil {
+		return false, err.Error()
+	}
+	return true, ""
+}
@@ -0,0 +1,135 @@
+package aggregator
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+// budgetProvider returns fixed cloud budgets, or fails with err
+type budgetProvider struct {
+	fakeProvider
+	budgets []BudgetStatus
+	err     error
+}
+
+func (p *budgetProvider) GetBudgets(ctx context.Context) ([]BudgetStatus, error) {
+	return p.budgets, p.err
+}
+
+// budgetWriter also creates budgets, recording their names
+type budgetWriter struct {
+	budgetProvider
+	created []string
+}
+
+func (p *budgetWriter) CreateBudget(ctx context.Context, b config.Budget) error {
+	p.created = append(p.created, b.Name)
+	return nil
+}
+
+// syncAggregator declares budgets of every kind against AWS, which can
+// create budgets, Azure, which cannot, and GCP, whose budgets cannot be read
+func syncAggregator() (*Aggregator, *budgetWriter) {
+	a := New(&config.Config{Budgets: []config.Budget{
+		{Name: "prod", Provider: "aws", Scope: "111", MonthlyLimit: 1000},
+		{Name: "dev", Provider: "aws", Scope: "222", MonthlyLimit: 500},
+		{Name: "new", Provider: "aws", Scope: "111", MonthlyLimit: 200},
+		{Name: "unlimited", Provider: "aws", Scope: "111"},
+		{Name: "team", Provider: "aws", Scope: "111", Limit: 50, Parent: "org"},
+		{Name: "org", Provider: "aws"},
+		{Name: "sub", Provider: "azure", Scope: "sub-1", MonthlyLimit: 100},
+		{Name: "project", Provider: "gcp", MonthlyLimit: 100},
+		{Name: "everything", Provider: "all", MonthlyLimit: 5000},
+		{Name: "checkout", Provider: "aws", Application: "checkout", MonthlyLimit: 100},
+		{Name: "quarter", Provider: "aws", Period: "quarterly", Limit: 900},
+		{Name: "cc", Provider: "aws", CostCenter: "CC-1", MonthlyLimit: 100},
+		{Name: "tenancy", Provider: "oci", MonthlyLimit: 100},
+	}})
+	aws := &budgetWriter{budgetProvider: budgetProvider{fakeProvider: fakeProvider{name: "aws"}, budgets: []BudgetStatus{
+		{BudgetName: "prod", Scope: "999", Limit: 10}, // same name, other account
+		{BudgetName: "prod", Scope: "111", Limit: 1000},
+		{BudgetName: "dev", Scope: "333", Limit: 400},
+		{BudgetName: "team", Scope: "111", Limit: 50},
+		{BudgetName: "legacy", Limit: 70},
+	}}}
+	a.RegisterProvider("aws", aws)
+	a.RegisterProvider("azure", &budgetProvider{fakeProvider: fakeProvider{name: "azure"}})
+	a.RegisterProvider("gcp", &budgetProvider{fakeProvider: fakeProvider{name: "gcp"}, err: errors.New("permission denied")})
+	return a, aws
+}
+
+func TestSyncBudgets(t *testing.T) {
+	a, aws := syncAggregator()
+	sync := a.SyncBudgets(context.Background(), true)
+
+	if want := []string{"prod", "team"}; !reflect.DeepEqual(sync.InSync, want) {
+		t.Errorf("InSync = %v, want %v", sync.InSync, want)
+	}
+	wantDrift := []BudgetDrift{
+		{Kind: DriftLimit, BudgetName: "dev", Provider: "aws", Scope: "222", CloudScope: "333", Limit: 500, CloudLimit: 400},
+		{Kind: DriftScope, BudgetName: "dev", Provider: "aws", Scope: "222", CloudScope: "333", Limit: 500, CloudLimit: 400},
+		{Kind: DriftUnmanaged, BudgetName: "legacy", Provider: "aws", CloudLimit: 70},
+		{Kind: DriftMissing, BudgetName: "new", Provider: "aws", Scope: "111", Limit: 200, Created: true},
+		{Kind: DriftUnmanaged, BudgetName: "prod", Provider: "aws", CloudScope: "999", CloudLimit: 10},
+		{Kind: DriftMissing, BudgetName: "unlimited", Provider: "aws", Scope: "111", Error: "monthly_limit is not set"},
+		{Kind: DriftMissing, BudgetName: "sub", Provider: "azure", Scope: "sub-1", Limit: 100, Error: "azure cannot create budgets"},
+	}
+	if !reflect.DeepEqual(sync.Drift, wantDrift) {
+		t.Errorf("Drift = %+v\nwant %+v", sync.Drift, wantDrift)
+	}
+	wantSkipped := map[string]string{
+		"org":        "rolls up child budgets",
+		"everything": "spans every provider",
+		"checkout":   "scoped to an application",
+		"quarter":    "not a monthly budget",
+		"cc":         "scoped to a cost center",
+		"tenancy":    "provider oci is not registered",
+	}
+	if !reflect.DeepEqual(sync.Skipped, wantSkipped) {
+		t.Errorf("Skipped = %v, want %v", sync.Skipped, wantSkipped)
+	}
+	if !reflect.DeepEqual(sync.Errors, map[string]string{"gcp": "permission denied"}) {
+		t.Errorf("Errors = %v, want gcp's", sync.Errors)
+	}
+	if !reflect.DeepEqual(aws.created, []string{"new"}) {
+		t.Errorf("created = %v, want [new]", aws.created)
+	}
+	if !sync.Unresolved() {
+		t.Error("Unresolved() = false, want true")
+	}
+}
+
+func TestSyncBudgetsReportOnly(t *testing.T) {
+	a, aws := syncAggregator()
+	sync := a.SyncBudgets(context.Background(), false)
+	if len(aws.created) != 0 {
+		t.Errorf("created %v without create", aws.created)
+	}
+	for _, d := range sync.Drift {
+		if d.Kind == DriftMissing && (d.Created || d.Error != "") {
+			t.Errorf("%s drift = %+v, want it only reported", d.BudgetName, d)
+		}
+	}
+}
+
+func TestBudgetSyncUnresolved(t *testing.T) {
+	tests := []struct {
+		name string
+		sync BudgetSync
+		want bool
+	}{
+		{"in sync", BudgetSync{InSync: []string{"prod"}}, false},
+		{"created", BudgetSync{Drift: []BudgetDrift{{Kind: DriftMissing, Created: true}}}, false},
+		{"drift", BudgetSync{Drift: []BudgetDrift{{Kind: DriftMissing, Created: true}, {Kind: DriftLimit}}}, true},
+		{"errors", BudgetSync{Errors: map[string]string{"gcp": "permission denied"}}, true},
+	}
+	for _, tt := range tests {
+		if got := tt.sync.Unresolved(); got != tt.want {
+			t.Errorf("%s: Unresolved() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package aggregator
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+// ExcludedChargesFrom validates the charge types configured to be left out
+// of totals
+func ExcludedChargesFrom(cfg config.ChargeTypesConfig) (map[normalizer.ChargeType]bool, error) {
+	excluded := make(map[normalizer.ChargeType]bool, len(cfg.Exclude))
+	for _, name := range cfg.Exclude {
+		ct := normalizer.ChargeType(strings.ToLower(name))
+		if !ct.Valid() {
+			return nil, fmt.Errorf("unknown charge type %q (want usage, credit, refund, tax or fee)", name)
+		}
+		excluded[ct] = true
+	}
+	return excluded, nil
+}
+
+// SetExcludedCharges sets the charge types dropped from totals and every
+// breakdown; they are still counted in ByChargeType
+func (a *Aggregator) SetExcludedCharges(excluded map[normalizer.ChargeType]bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.excludedCharges = excluded
+}
+
+// Charge returns the entry's charge type. Entries without one are usage, or
+// credits when their cost is negative.
+func (e CostEntry) Charge() normalizer.ChargeType {
+	if ct := normalizer.ParseChargeType(e.ChargeType); ct != "" {
+		return ct
+	}
+	if e.Cost < 0 {
+		return normalizer.ChargeCredit
+	}
+	return normalizer.ChargeUsage
+}
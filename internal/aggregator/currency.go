@@ -0,0 +1,65 @@
+package aggregator
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lvonguyen/finops-platform/internal/currency"
+)
+
+// SetCurrency converts every fetched entry into the converter's base
+// currency. A provider reporting a currency with no rate is excluded with a
+// currency error rather than summed as if it were the base.
+func (a *Aggregator) SetCurrency(conv *currency.Converter) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.currency = conv
+}
+
+// convertCurrency converts entries into the base currency in place, keeping
+// the billed amount and currency. Entries with no currency are taken to be
+// in the base already.
+func convertCurrency(conv *currency.Converter, entries []CostEntry) error {
+	if conv == nil {
+		return nil
+	}
+	base := conv.Base()
+
+	codes := make([]string, 0)
+	for _, e := range entries {
+		if e.Currency != "" {
+			codes = append(codes, e.Currency)
+		}
+	}
+	if missing := conv.Missing(codes); len(missing) > 0 {
+		return fmt.Errorf("%w for %s into %s", currency.ErrNoRate, strings.Join(missing, ", "), base)
+	}
+
+	for i := range entries {
+		if err := convertEntry(conv, &entries[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// convertEntry converts one entry to the base currency
+func convertEntry(conv *currency.Converter, e *CostEntry) error {
+	base := conv.Base()
+	code := strings.ToUpper(e.Currency)
+	if code == "" || code == base {
+		e.Currency = base
+		return nil
+	}
+	rate, err := conv.Rate(code, base)
+	if err != nil {
+		return err
+	}
+	e.OriginalCost = e.Cost
+	e.OriginalCurrency = code
+	e.Cost *= rate
+	e.RawCost *= rate
+	e.EffectiveCost *= rate
+	e.Currency = base
+	return nil
+}
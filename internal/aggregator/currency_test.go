@@ -0,0 +1,52 @@
+package aggregator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/currency"
+)
+
+func TestAggregateConvertsCurrency(t *testing.T) {
+	a := New(&config.Config{})
+	a.SetCurrency(currency.NewStatic("USD", map[string]float64{"EUR": 0.8}))
+	a.RegisterProvider("azure", &fakeProvider{name: "azure", entries: []CostEntry{
+		{Provider: "azure", AccountID: "sub", Service: "VM", Date: day, Cost: 80, EffectiveCost: 40, Currency: "eur"},
+		{Provider: "azure", AccountID: "sub", Service: "Storage", Date: day, Cost: 5},
+	}})
+	a.RegisterProvider("gcp", &fakeProvider{name: "gcp", entries: []CostEntry{
+		{Provider: "gcp", AccountID: "proj", Service: "BigQuery", Date: day, Cost: 1000, Currency: "JPY"},
+	}})
+	a.RegisterProvider("aws", &fakeProvider{name: "aws", entries: []CostEntry{
+		{Provider: "aws", AccountID: "111", Service: "EC2", Date: day, Cost: 20, Currency: "USD"},
+	}})
+
+	result, err := a.Aggregate(context.Background(), day, day.AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// GCP bills in yen, which has no rate, so it is left out with an error
+	if result.TotalCost != 125 {
+		t.Errorf("TotalCost = %v, want 125", result.TotalCost)
+	}
+	if len(result.Errors) != 1 || result.Errors[0].Provider != "gcp" || result.Errors[0].Kind != ErrorKindCurrency {
+		t.Errorf("errors = %+v, want a gcp currency error", result.Errors)
+	}
+
+	for _, e := range result.Entries {
+		switch e.Service {
+		case "VM":
+			if e.Cost != 100 || e.EffectiveCost != 50 || e.Currency != "USD" || e.OriginalCost != 80 || e.OriginalCurrency != "EUR" {
+				t.Errorf("converted entry = %+v, want 100 USD from 80 EUR", e)
+			}
+			if r := e.Record(); r.OriginalCost != 80 || r.OriginalCurrency != "EUR" {
+				t.Errorf("record = %+v, want the billed amount kept", r)
+			}
+		case "Storage", "EC2":
+			if e.Currency != "USD" || e.OriginalCurrency != "" {
+				t.Errorf("base entry = %+v, want it unconverted in USD", e)
+			}
+		}
+	}
+}
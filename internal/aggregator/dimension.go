@@ -0,0 +1,218 @@
+package aggregator
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+// DefaultMaxDimensionValues bounds a computed dimension's cardinality when
+// the dimension does not set its own limit
+const DefaultMaxDimensionValues = 500
+
+// Values of computed dimensions that are not produced by the dimension itself
+const (
+	DimensionNone  = "(none)"  // the dimension computed an empty value
+	DimensionOther = "(other)" // values past the dimension's cardinality limit
+)
+
+// Dimension is a computed cost dimension. Aggregation totals cost by its
+// value in AggregationResult.ByCustom[Name].
+type Dimension struct {
+	Name      string
+	Value     func(normalizer.CostRecord) string
+	MaxValues int // distinct values kept before the rest fold into DimensionOther
+}
+
+// RegisterDimension adds a computed dimension to every aggregation
+func (a *Aggregator) RegisterDimension(d Dimension) error {
+	if d.Name == "" {
+		return fmt.Errorf("dimension has no name")
+	}
+	if d.Value == nil {
+		return fmt.Errorf("dimension %q has no value function", d.Name)
+	}
+	if d.MaxValues < 0 {
+		return fmt.Errorf("dimension %q: max values must not be negative", d.Name)
+	}
+	if d.MaxValues == 0 {
+		d.MaxValues = DefaultMaxDimensionValues
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, existing := range a.dimensions {
+		if existing.Name == d.Name {
+			return fmt.Errorf("dimension %q already registered", d.Name)
+		}
+	}
+	a.dimensions = append(a.dimensions, d)
+	return nil
+}
+
+// GroupBy returns the dimension breaking costs down by one record field:
+// cloud, account, region, service, resource or tag:KEY
+func GroupBy(field string) (Dimension, error) {
+	if strings.ContainsAny(field, "{}") {
+		return Dimension{}, fmt.Errorf("invalid group-by field %q", field)
+	}
+	return DimensionFrom(config.DimensionConfig{Name: field, Template: "{" + field + "}"})
+}
+
+// DimensionFrom builds a dimension from configuration. The template joins
+// record fields ({cloud}, {account}, {region}, {service}, {resource},
+// {tag:KEY}); an optional pattern then extracts its first capture group,
+// or the whole match when it has none.
+func DimensionFrom(cfg config.DimensionConfig) (Dimension, error) {
+	if cfg.Template == "" {
+		return Dimension{}, fmt.Errorf("dimension %q has no template", cfg.Name)
+	}
+	if err := checkTemplate(cfg.Template); err != nil {
+		return Dimension{}, fmt.Errorf("dimension %q: %w", cfg.Name, err)
+	}
+
+	var pattern *regexp.Regexp
+	if cfg.Pattern != "" {
+		re, err := regexp.Compile(cfg.Pattern)
+		if err != nil {
+			return Dimension{}, fmt.Errorf("dimension %q: invalid pattern: %w", cfg.Name, err)
+		}
+		pattern = re
+	}
+
+	template := cfg.Template
+	return Dimension{
+		Name:      cfg.Name,
+		MaxValues: cfg.MaxValues,
+		Value: func(r normalizer.CostRecord) string {
+			value := renderTemplate(template, r)
+			if pattern == nil {
+				return value
+			}
+			m := pattern.FindStringSubmatch(value)
+			switch {
+			case m == nil:
+				return ""
+			case len(m) > 1:
+				return m[1]
+			default:
+				return m[0]
+			}
+		},
+	}, nil
+}
+
+// checkTemplate rejects unknown or unterminated fields
+func checkTemplate(template string) error {
+	for rest := template; ; {
+		open := strings.IndexByte(rest, '{')
+		if open < 0 {
+			return nil
+		}
+		end := strings.IndexByte(rest[open:], '}')
+		if end < 0 {
+			return fmt.Errorf("unterminated field in %q", template)
+		}
+		field := rest[open+1 : open+end]
+		switch {
+		case field == "cloud", field == "account", field == "region", field == "service", field == "resource":
+		case strings.HasPrefix(field, "tag:") && len(field) > len("tag:"):
+		default:
+			return fmt.Errorf("unknown field {%s}", field)
+		}
+		rest = rest[open+end+1:]
+	}
+}
+
+// renderTemplate substitutes a record's fields into a checked template
+func renderTemplate(template string, r normalizer.CostRecord) string {
+	var b strings.Builder
+	for rest := template; ; {
+		open := strings.IndexByte(rest, '{')
+		if open < 0 {
+			b.WriteString(rest)
+			return b.String()
+		}
+		end := open + strings.IndexByte(rest[open:], '}')
+		b.WriteString(rest[:open])
+		switch field := rest[open+1 : end]; field {
+		case "cloud":
+			b.WriteString(r.Cloud)
+		case "account":
+			b.WriteString(r.Account)
+		case "region":
+			b.WriteString(r.Region)
+		case "service":
+			b.WriteString(r.Service)
+		case "resource":
+			b.WriteString(r.Resource)
+		default:
+			b.WriteString(r.Tags[strings.TrimPrefix(field, "tag:")])
+		}
+		rest = rest[end+1:]
+	}
+}
+
+// addCustom totals an entry under each computed dimension, folding values
+// past a dimension's limit into DimensionOther and counting them in the
+// result's Overflow
+func (l *ledger) addCustom(r *AggregationResult, dims []Dimension, entry CostEntry) {
+	record := entry.Record()
+	for _, d := range dims {
+		value := d.Value(record)
+		if value == "" {
+			value = DimensionNone
+		}
+		costs := l.custom[d.Name]
+		if _, seen := costs[value]; !seen && len(costs) >= d.MaxValues {
+			value = DimensionOther
+			r.Overflow[d.Name]++
+		}
+		costs.Add(value, entry.Cost)
+	}
+}
+
+// TopCustom returns a computed dimension's top N values by cost
+func (r *AggregationResult) TopCustom(name string, n int) []CostEntry {
+	return topN(r.ByCustom[name], n)
+}
+
+// CustomNames returns the computed dimensions in the result, sorted
+func (r *AggregationResult) CustomNames() []string {
+	names := make([]string, 0, len(r.ByCustom))
+	for name := range r.ByCustom {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// MatchesDimensions reports whether a record passes the filter's computed
+// dimension constraints
+func (f CostFilter) MatchesDimensions(dims []Dimension, r normalizer.CostRecord) bool {
+	for _, d := range dims {
+		if values, ok := f.Dimensions[d.Name]; ok && !oneOf(values, d.Value(r)) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckDimensions returns an error when the filter constrains a dimension
+// that is not registered
+func (f CostFilter) CheckDimensions(dims []Dimension) error {
+	known := make(map[string]bool, len(dims))
+	for _, d := range dims {
+		known[d.Name] = true
+	}
+	for name := range f.Dimensions {
+		if !known[name] {
+			return fmt.Errorf("filter references unknown dimension %q", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package aggregator
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+func TestDimensionFrom(t *testing.T) {
+	r := normalizer.CostRecord{
+		Cloud: "aws", Account: "111", Region: "us-east-1", Service: "EC2", Resource: "i-0abc",
+		Tags: map[string]string{"environment": "prod", "cluster": "eks-payments-blue"},
+	}
+	tests := []struct {
+		cfg  config.DimensionConfig
+		want string
+	}{
+		{config.DimensionConfig{Name: "env-region", Template: "{tag:environment}/{region}"}, "prod/us-east-1"},
+		{config.DimensionConfig{Name: "all", Template: "{cloud}:{account}:{service}:{resource}"}, "aws:111:EC2:i-0abc"},
+		{config.DimensionConfig{Name: "team", Template: "{tag:cluster}", Pattern: `^eks-(\w+)-`}, "payments"},
+		{config.DimensionConfig{Name: "whole match", Template: "{tag:cluster}", Pattern: `blue|green`}, "blue"},
+		{config.DimensionConfig{Name: "no match", Template: "{tag:cluster}", Pattern: `^gke-`}, ""},
+		{config.DimensionConfig{Name: "missing tag", Template: "{tag:owner}"}, ""},
+	}
+	for _, tt := range tests {
+		d, err := DimensionFrom(tt.cfg)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.cfg.Name, err)
+		}
+		if got := d.Value(r); got != tt.want {
+			t.Errorf("%s: Value() = %q, want %q", tt.cfg.Name, got, tt.want)
+		}
+	}
+
+	for _, bad := range []config.DimensionConfig{
+		{Name: "empty"},
+		{Name: "unknown field", Template: "{owner}"},
+		{Name: "empty tag", Template: "{tag:}"},
+		{Name: "unterminated", Template: "{region"},
+		{Name: "bad pattern", Template: "{region}", Pattern: "("},
+	} {
+		if _, err := DimensionFrom(bad); err == nil {
+			t.Errorf("%s: dimension accepted", bad.Name)
+		}
+	}
+	if _, err := GroupBy("tag:{x}"); err == nil {
+		t.Error("GroupBy accepted a field with braces")
+	}
+	for field, want := range map[string]string{"service": "EC2", "tag:environment": "prod", "resource": "i-0abc"} {
+		d, err := GroupBy(field)
+		if err != nil || d.Name != field || d.Value(r) != want {
+			t.Errorf("GroupBy(%s) = %+v, %v; want a dimension of that name valued %q", field, d, err, want)
+		}
+	}
+	if _, err := GroupBy("owner"); err == nil {
+		t.Error("GroupBy accepted an unknown field")
+	}
+}
+
+func TestRegisterDimension(t *testing.T) {
+	region, err := GroupBy("region")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := New(&config.Config{})
+	if err := a.RegisterDimension(region); err != nil {
+		t.Fatal(err)
+	}
+	if a.dimensions[0].MaxValues != DefaultMaxDimensionValues {
+		t.Errorf("MaxValues = %d, want the default %d", a.dimensions[0].MaxValues, DefaultMaxDimensionValues)
+	}
+	for _, bad := range []Dimension{
+		region,
+		{Value: region.Value},
+		{Name: "no value"},
+		{Name: "negative", Value: region.Value, MaxValues: -1},
+	} {
+		if err := a.RegisterDimension(bad); err == nil {
+			t.Errorf("dimension %q registered", bad.Name)
+		}
+	}
+}
+
+func TestAggregateDimensions(t *testing.T) {
+	env, err := DimensionFrom(config.DimensionConfig{Name: "env", Template: "{tag:env}", MaxValues: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tagged := func(env string, cost float64) CostEntry {
+		e := CostEntry{Provider: "aws", Service: "EC2", Date: day, Cost: cost, Tags: map[string]string{}}
+		if env != "" {
+			e.Tags["env"] = env
+		}
+		return e
+	}
+	entries := []CostEntry{
+		tagged("prod", 100),
+		tagged("", 7),
+		tagged("dev", 20),
+		tagged("prod", 50),
+		tagged("qa", 5),
+	}
+
+	a := New(&config.Config{})
+	if err := a.RegisterDimension(env); err != nil {
+		t.Fatal(err)
+	}
+	a.RegisterProvider("aws", &fakeProvider{name: "aws", entries: entries})
+	result, err := a.Aggregate(context.Background(), day, day.AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]float64{"prod": 150, DimensionNone: 7, DimensionOther: 25}
+	if !reflect.DeepEqual(result.ByCustom["env"], want) {
+		t.Errorf("ByCustom[env] = %v, want %v", result.ByCustom["env"], want)
+	}
+	if result.Overflow["env"] != 2 {
+		t.Errorf("Overflow[env] = %d, want 2", result.Overflow["env"])
+	}
+	if names := result.CustomNames(); len(names) != 1 || names[0] != "env" {
+		t.Errorf("CustomNames() = %v, want [env]", names)
+	}
+	if top := result.TopCustom("env", 1); len(top) != 1 || top[0].Service != "prod" || top[0].Cost != 150 {
+		t.Errorf("TopCustom(env, 1) = %+v, want prod at 150", top)
+	}
+
+	filter, err := FilterFrom(config.FilterConfig{Dimensions: []string{"env=prod"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := filter.CheckDimensions(a.dimensions); err != nil {
+		t.Fatal(err)
+	}
+	if err := filter.CheckDimensions(nil); err == nil {
+		t.Error("CheckDimensions accepted an unregistered dimension")
+	}
+	a.SetFilter(filter)
+	result, err = a.Aggregate(context.Background(), day, day.AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.TotalCost != 150 || len(result.Entries) != 2 {
+		t.Errorf("filtered total %v over %d entries, want 150 over 2", result.TotalCost, len(result.Entries))
+	}
+}
@@ -0,0 +1,63 @@
+package aggregator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/emissions"
+)
+
+func TestAggregateEmissions(t *testing.T) {
+	table, err := emissions.FromConfig(config.EmissionsConfig{Enabled: true, Factors: []config.EmissionFactor{
+		{Provider: "aws", Service: "EC2", UsageUnit: "Hrs", PerUnit: 0.05},
+		{Provider: "aws", Service: "S3", PerDollar: 0.1},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := []CostEntry{
+		{Provider: "aws", AccountID: "111", Service: "EC2", Date: day, Cost: 40, UsageAmount: 200, UsageUnit: "Hrs"},
+		{Provider: "aws", AccountID: "111", Service: "EC2", Date: day, Cost: 10, UsageAmount: 100, UsageUnit: "Hrs"},
+		{Provider: "aws", AccountID: "111", Service: "S3", Date: day, Cost: 20},
+		{Provider: "aws", AccountID: "111", Service: "Support", Date: day, Cost: 15},
+	}
+
+	a := New(&config.Config{})
+	a.SetEmissionFactors(table)
+	a.RegisterProvider("aws", &fakeProvider{name: "aws", entries: entries})
+	result, err := a.Aggregate(context.Background(), day, day.AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Emissions != 17 {
+		t.Errorf("Emissions = %v, want 17", result.Emissions)
+	}
+	if result.ByEmissions["EC2"] != 15 || result.ByEmissions["S3"] != 2 {
+		t.Errorf("ByEmissions = %v, want EC2 15 and S3 2", result.ByEmissions)
+	}
+	if _, ok := result.ByEmissions["Support"]; ok {
+		t.Error("Support has emissions without a factor")
+	}
+	if kg := result.Entries[0].EmissionsKg; kg != 10 {
+		t.Errorf("first entry EmissionsKg = %v, want 10", kg)
+	}
+	if kg := result.Entries[0].Record().EmissionsKg; kg != 10 {
+		t.Errorf("first record EmissionsKg = %v, want 10", kg)
+	}
+}
+
+func TestAggregateWithoutEmissionFactors(t *testing.T) {
+	a := New(&config.Config{})
+	a.RegisterProvider("aws", &fakeProvider{name: "aws", entries: []CostEntry{
+		{Provider: "aws", Service: "EC2", Date: day, Cost: 40, UsageAmount: 200, UsageUnit: "Hrs"},
+	}})
+	result, err := a.Aggregate(context.Background(), day, day.AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Emissions != 0 || len(result.ByEmissions) != 0 {
+		t.Errorf("Emissions = %v, ByEmissions = %v, want none", result.Emissions, result.ByEmissions)
+	}
+}
@@ -0,0 +1,171 @@
+package aggregator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/query"
+)
+
+// CostFilter narrows the costs fetched from providers. Values within a field
+// are alternatives; fields must all match. Empty fields match anything.
+type CostFilter struct {
+	Accounts   []string
+	Services   []string
+	Regions    []string
+	Tags       map[string][]string // tag key -> accepted values
+	Dimensions map[string][]string // computed dimension -> accepted values, applied after fetching
+	Where      *query.Filter       // filter expression, applied after fetching
+}
+
+// FilteringProvider is implemented by providers that apply a CostFilter in
+// their API query. Providers that do not are filtered client-side.
+type FilteringProvider interface {
+	GetFilteredCosts(ctx context.Context, start, end time.Time, filter CostFilter) ([]CostEntry, error)
+}
+
+// FilterFrom builds a filter from configuration. Tags are given as
+// key=value pairs; repeating a key accepts any of its values.
+func FilterFrom(cfg config.FilterConfig) (CostFilter, error) {
+	f := CostFilter{Accounts: cfg.Accounts, Services: cfg.Services, Regions: cfg.Regions}
+	var err error
+	if f.Tags, err = parsePairs("tag", cfg.Tags); err != nil {
+		return CostFilter{}, err
+	}
+	if f.Dimensions, err = parsePairs("dimension", cfg.Dimensions); err != nil {
+		return CostFilter{}, err
+	}
+	if cfg.Where != "" {
+		if f.Where, err = query.Parse(cfg.Where); err != nil {
+			return CostFilter{}, err
+		}
+	}
+	return f, nil
+}
+
+// parsePairs groups key=value pairs by key
+func parsePairs(kind string, pairs []string) (map[string][]string, error) {
+	var m map[string][]string
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || key == "" || value == "" {
+			return nil, fmt.Errorf("invalid %s filter %q (want key=value)", kind, pair)
+		}
+		if m == nil {
+			m = make(map[string][]string)
+		}
+		m[key] = append(m[key], value)
+	}
+	return m, nil
+}
+
+// IsZero reports whether the filter matches everything
+func (f CostFilter) IsZero() bool {
+	return len(f.Accounts) == 0 && len(f.Services) == 0 && len(f.Regions) == 0 && len(f.Tags) == 0 && len(f.Dimensions) == 0 && f.Where == nil
+}
+
+// TagKeys returns the filtered tag keys in a stable order
+func (f CostFilter) TagKeys() []string {
+	keys := make([]string, 0, len(f.Tags))
+	for k := range f.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Matches reports whether an entry passes the filter, apart from its
+// computed dimensions and expression
+func (f CostFilter) Matches(e CostEntry) bool {
+	if !oneOf(f.Accounts, e.AccountID) || !oneOf(f.Services, e.Service) || !oneOf(f.Regions, e.Region) {
+		return false
+	}
+	for key, values := range f.Tags {
+		if !oneOf(values, e.Tags[key]) {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchesAccount reports whether the filter accepts an account, for
+// providers that query accounts one at a time
+func (f CostFilter) MatchesAccount(account string) bool {
+	return oneOf(f.Accounts, account)
+}
+
+// String describes the filter for logs
+func (f CostFilter) String() string {
+	var parts []string
+	add := func(name string, values []string) {
+		if len(values) > 0 {
+			parts = append(parts, name+"="+strings.Join(values, "|"))
+		}
+	}
+	add("accounts", f.Accounts)
+	add("services", f.Services)
+	add("regions", f.Regions)
+	for _, k := range f.TagKeys() {
+		add("tag:"+k, f.Tags[k])
+	}
+	dims := make([]string, 0, len(f.Dimensions))
+	for k := range f.Dimensions {
+		dims = append(dims, k)
+	}
+	sort.Strings(dims)
+	for _, k := range dims {
+		add("dimension:"+k, f.Dimensions[k])
+	}
+	if f.Where != nil {
+		parts = append(parts, "where:("+f.Where.String()+")")
+	}
+	return strings.Join(parts, " ")
+}
+
+// SetFilter restricts every provider query to costs matching f
+func (a *Aggregator) SetFilter(f CostFilter) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.filter = f
+}
+
+// getCosts fetches from the provider, pushing the filter down when the
+// provider supports it and applying it client-side otherwise. Computed
+// dimensions and the expression are left to aggregation.
+func getCosts(ctx context.Context, provider CostProvider, start, end time.Time, filter CostFilter) ([]CostEntry, error) {
+	filter.Dimensions, filter.Where = nil, nil
+	if filter.IsZero() {
+		return provider.GetCosts(ctx, start, end)
+	}
+	if fp, ok := provider.(FilteringProvider); ok {
+		return fp.GetFilteredCosts(ctx, start, end, filter)
+	}
+
+	entries, err := provider.GetCosts(ctx, start, end)
+	if err != nil {
+		return nil, err
+	}
+	kept := make([]CostEntry, 0, len(entries))
+	for _, e := range entries {
+		if filter.Matches(e) {
+			kept = append(kept, e)
+		}
+	}
+	return kept, nil
+}
+
+func oneOf(values []string, v string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, want := range values {
+		if want == v {
+			return true
+		}
+	}
+	return false
+}
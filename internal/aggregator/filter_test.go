@@ -0,0 +1,130 @@
+package aggregator
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+// filteringProvider records the filter pushed down to it
+type filteringProvider struct {
+	fakeProvider
+	filter *CostFilter
+}
+
+func (p *filteringProvider) GetFilteredCosts(ctx context.Context, start, end time.Time, filter CostFilter) ([]CostEntry, error) {
+	p.filter = &filter
+	return p.entries, nil
+}
+
+func TestFilterFrom(t *testing.T) {
+	f, err := FilterFrom(config.FilterConfig{
+		Accounts: []string{"111"},
+		Tags:     []string{"env=prod", "team=web", "env=staging"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string][]string{"env": {"prod", "staging"}, "team": {"web"}}; !reflect.DeepEqual(f.Tags, want) {
+		t.Errorf("tags = %v, want %v", f.Tags, want)
+	}
+	if got := f.String(); got != "accounts=111 tag:env=prod|staging tag:team=web" {
+		t.Errorf("String() = %q", got)
+	}
+
+	for _, tag := range []string{"env", "=prod", "env="} {
+		if _, err := FilterFrom(config.FilterConfig{Tags: []string{tag}}); err == nil {
+			t.Errorf("tag filter %q accepted", tag)
+		}
+	}
+}
+
+func TestCostFilterMatches(t *testing.T) {
+	f := CostFilter{
+		Accounts: []string{"111", "222"},
+		Regions:  []string{"us-east-1"},
+		Tags:     map[string][]string{"env": {"prod", "staging"}},
+	}
+	tests := []struct {
+		entry CostEntry
+		want  bool
+	}{
+		{CostEntry{AccountID: "111", Region: "us-east-1", Tags: map[string]string{"env": "prod"}}, true},
+		{CostEntry{AccountID: "222", Region: "us-east-1", Service: "S3", Tags: map[string]string{"env": "staging"}}, true},
+		{CostEntry{AccountID: "333", Region: "us-east-1", Tags: map[string]string{"env": "prod"}}, false},
+		{CostEntry{AccountID: "111", Region: "eu-west-1", Tags: map[string]string{"env": "prod"}}, false},
+		{CostEntry{AccountID: "111", Region: "us-east-1", Tags: map[string]string{"env": "dev"}}, false},
+		{CostEntry{AccountID: "111", Region: "us-east-1"}, false},
+	}
+	for _, tt := range tests {
+		if got := f.Matches(tt.entry); got != tt.want {
+			t.Errorf("Matches(%+v) = %v, want %v", tt.entry, got, tt.want)
+		}
+	}
+	if !(CostFilter{}).Matches(CostEntry{AccountID: "999"}) || !(CostFilter{}).IsZero() {
+		t.Error("empty filter does not match everything")
+	}
+}
+
+func TestAggregateFilter(t *testing.T) {
+	entries := []CostEntry{
+		{Provider: "aws", AccountID: "111", Service: "EC2", Date: day, Cost: 100},
+		{Provider: "aws", AccountID: "222", Service: "EC2", Date: day, Cost: 50},
+	}
+	pushed := &filteringProvider{fakeProvider: fakeProvider{name: "azure", entries: []CostEntry{
+		{Provider: "azure", AccountID: "111", Service: "VM", Date: day, Cost: 30},
+	}}}
+	plain := &fakeProvider{name: "aws", entries: entries}
+
+	a := New(&config.Config{})
+	a.RegisterProvider("azure", pushed)
+	a.RegisterProvider("aws", plain)
+	a.SetFilter(CostFilter{Accounts: []string{"111"}})
+	result, err := a.Aggregate(context.Background(), day, day.AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pushed.filter == nil || !reflect.DeepEqual(pushed.filter.Accounts, []string{"111"}) || pushed.calls != 0 {
+		t.Errorf("filter pushed down = %+v after %d GetCosts calls, want accounts [111] and none", pushed.filter, pushed.calls)
+	}
+	if result.ByProvider["aws"] != 100 || result.ByProvider["azure"] != 30 || result.ByAccount["222"] != 0 {
+		t.Errorf("by provider %v, by account %v; want account 222 filtered out", result.ByProvider, result.ByAccount)
+	}
+}
+
+func TestAggregateWhere(t *testing.T) {
+	f, err := FilterFrom(config.FilterConfig{Accounts: []string{"111"}, Where: `service = "EC2" AND cost > 20`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.IsZero() || f.String() != `accounts=111 where:(service = "EC2" AND cost > 20)` {
+		t.Errorf("filter = %q, want accounts and the expression", f.String())
+	}
+	if _, err := FilterFrom(config.FilterConfig{Where: "cost >"}); err == nil {
+		t.Error("invalid expression accepted")
+	}
+
+	// The expression is applied after fetching, never pushed down
+	pushed := &filteringProvider{fakeProvider: fakeProvider{name: "aws", entries: []CostEntry{
+		{Provider: "aws", AccountID: "111", Service: "EC2", Date: day, Cost: 100},
+		{Provider: "aws", AccountID: "111", Service: "EC2", Date: day, Cost: 10},
+		{Provider: "aws", AccountID: "111", Service: "S3", Date: day, Cost: 50},
+	}}}
+	a := New(&config.Config{})
+	a.RegisterProvider("aws", pushed)
+	a.SetFilter(f)
+	result, err := a.Aggregate(context.Background(), day, day.AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pushed.filter == nil || pushed.filter.Where != nil {
+		t.Errorf("filter pushed down = %+v, want it without the expression", pushed.filter)
+	}
+	if result.TotalCost != 100 || len(result.Entries) != 1 {
+		t.Errorf("total %v over %d entries, want only the 100 EC2 entry", result.TotalCost, len(result.Entries))
+	}
+}
@@ -0,0 +1,50 @@
+package aggregator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/hierarchy"
+)
+
+func TestAggregateByOrgUnit(t *testing.T) {
+	entries := []CostEntry{
+		{Provider: "aws", AccountID: "111", Service: "EC2", Date: day, Cost: 100},
+		{Provider: "aws", AccountID: "222", Service: "EC2", Date: day, Cost: 50},
+		{Provider: "aws", AccountID: "333", Service: "S3", Date: day, Cost: 25},
+	}
+
+	a := New(&config.Config{})
+	a.RegisterProvider("aws", &fakeProvider{name: "aws", entries: entries})
+	result, err := a.Aggregate(context.Background(), day, day.AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ByOrgUnit != nil || result.OrgUnits() != nil {
+		t.Errorf("ByOrgUnit = %v without a hierarchy, want nil", result.ByOrgUnit)
+	}
+
+	a.SetHierarchy(hierarchy.New(map[string][]string{
+		"111": {"Root", "Engineering"},
+		"222": {"Root", "Finance"},
+	}))
+	result, err = a.Aggregate(context.Background(), day, day.AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]float64{"Root/Engineering": 100, "Root/Finance": 50, hierarchy.Unassigned: 25}
+	if len(result.ByOrgUnit) != len(want) {
+		t.Errorf("ByOrgUnit = %v, want %v", result.ByOrgUnit, want)
+	}
+	for unit, cost := range want {
+		if result.ByOrgUnit[unit] != cost {
+			t.Errorf("ByOrgUnit[%s] = %v, want %v", unit, result.ByOrgUnit[unit], cost)
+		}
+	}
+
+	rows := result.OrgUnits()
+	if len(rows) != 4 || rows[0].Path != "Root" || rows[0].Cost != 150 || rows[3].Path != hierarchy.Unassigned {
+		t.Errorf("OrgUnits() = %+v, want Root (150), its two units, then unassigned", rows)
+	}
+}
@@ -0,0 +1,110 @@
+package aggregator
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+// Internal charge handling modes
+const (
+	InternalReport  = "report"  // keep internal charges in totals and report them separately
+	InternalExclude = "exclude" // drop internal charges from totals and every breakdown
+)
+
+// InternalRule classifies intercompany or internal-transfer charges. Every
+// set field must match; an empty field matches anything.
+type InternalRule struct {
+	Name     string
+	Provider string
+	Account  string
+	Service  string
+	Tag      string         // tag key that marks internal charges
+	Pattern  *regexp.Regexp // tag value pattern, nil for any non-empty value
+}
+
+// InternalRulesFrom compiles the configured internal charge rules
+func InternalRulesFrom(cfg config.InternalChargesConfig) ([]InternalRule, error) {
+	switch cfg.Mode {
+	case "", InternalReport, InternalExclude:
+	default:
+		return nil, fmt.Errorf("unknown internal charge mode %q", cfg.Mode)
+	}
+
+	rules := make([]InternalRule, 0, len(cfg.Rules))
+	for _, r := range cfg.Rules {
+		if r.Provider == "" && r.Account == "" && r.Service == "" && r.Tag == "" {
+			return nil, fmt.Errorf("internal charge rule %q matches every charge", r.Name)
+		}
+		if r.Pattern != "" && r.Tag == "" {
+			return nil, fmt.Errorf("internal charge rule %q has a pattern but no tag", r.Name)
+		}
+
+		rule := InternalRule{
+			Name:     r.Name,
+			Provider: r.Provider,
+			Account:  r.Account,
+			Service:  r.Service,
+			Tag:      r.Tag,
+		}
+		if r.Pattern != "" {
+			re, err := regexp.Compile(r.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("internal charge rule %q: invalid pattern: %w", r.Name, err)
+			}
+			rule.Pattern = re
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// Matches reports whether the rule classifies entry as internal
+func (r InternalRule) Matches(entry CostEntry) bool {
+	if r.Provider != "" && r.Provider != entry.Provider {
+		return false
+	}
+	if r.Account != "" && r.Account != entry.AccountID {
+		return false
+	}
+	if r.Service != "" && r.Service != entry.Service {
+		return false
+	}
+	if r.Tag != "" {
+		value := entry.Tags[r.Tag]
+		if value == "" {
+			return false
+		}
+		if r.Pattern != nil && !r.Pattern.MatchString(value) {
+			return false
+		}
+	}
+	return true
+}
+
+// SetInternalCharges sets the rules classifying internal charges and whether
+// matching charges are excluded from totals or only reported separately
+func (a *Aggregator) SetInternalCharges(rules []InternalRule, exclude bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.internalRules = rules
+	a.excludeInternal = exclude
+}
+
+// classify sets the entry's Internal field to the first matching rule
+func classify(rules []InternalRule, entry CostEntry) CostEntry {
+	for _, r := range rules {
+		if r.Matches(entry) {
+			entry.Internal = r.Name
+			return entry
+		}
+	}
+	return entry
+}
+
+// CombinedCost is external plus internal spend, whether or not internal
+// charges are included in TotalCost
+func (r *AggregationResult) CombinedCost() float64 {
+	return r.ExternalCost + r.InternalCost
+}
@@ -0,0 +1,62 @@
+package aggregator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+func TestInternalRulesFrom(t *testing.T) {
+	bad := []config.InternalChargesConfig{
+		{Mode: "ignore"},
+		{Rules: []config.InternalChargeRule{{Name: "all"}}},
+		{Rules: []config.InternalChargeRule{{Name: "pattern only", Account: "111", Pattern: "x"}}},
+		{Rules: []config.InternalChargeRule{{Name: "bad pattern", Tag: "billing", Pattern: "("}}},
+	}
+	for _, cfg := range bad {
+		if _, err := InternalRulesFrom(cfg); err == nil {
+			t.Errorf("config %+v accepted", cfg)
+		}
+	}
+}
+
+func TestAggregateInternalCharges(t *testing.T) {
+	rules, err := InternalRulesFrom(config.InternalChargesConfig{Rules: []config.InternalChargeRule{
+		{Name: "shared-services", Account: "999"},
+		{Name: "transfer", Tag: "billing", Pattern: "^intercompany"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := []CostEntry{
+		{Provider: "aws", AccountID: "111", Service: "EC2", Date: day, Cost: 100},
+		{Provider: "aws", AccountID: "999", Service: "EC2", Date: day, Cost: 30},
+		{Provider: "aws", AccountID: "111", Service: "S3", Date: day, Cost: 20, Tags: map[string]string{"billing": "intercompany-eu"}},
+		{Provider: "aws", AccountID: "111", Service: "S3", Date: day, Cost: 5, Tags: map[string]string{"billing": "external"}},
+	}
+
+	for _, exclude := range []bool{false, true} {
+		a := New(&config.Config{})
+		a.SetInternalCharges(rules, exclude)
+		a.RegisterProvider("aws", &fakeProvider{name: "aws", entries: entries})
+		result, err := a.Aggregate(context.Background(), day, day.AddDate(0, 1, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if result.ExternalCost != 105 || result.InternalCost != 50 || result.CombinedCost() != 155 {
+			t.Errorf("exclude=%v: external %v, internal %v, want 105 and 50", exclude, result.ExternalCost, result.InternalCost)
+		}
+		if result.InternalRules["shared-services"] != 30 || result.InternalRules["transfer"] != 20 {
+			t.Errorf("exclude=%v: internal rules = %v", exclude, result.InternalRules)
+		}
+		wantTotal, wantAccount := 155.0, 30.0
+		if exclude {
+			wantTotal, wantAccount = 105, 0
+		}
+		if result.TotalCost != wantTotal || result.ByAccount["999"] != wantAccount {
+			t.Errorf("exclude=%v: total %v, account 999 %v; want %v and %v", exclude, result.TotalCost, result.ByAccount["999"], wantTotal, wantAccount)
+		}
+	}
+}
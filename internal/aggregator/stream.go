@@ -0,0 +1,175 @@
+package aggregator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/lvonguyen/finops-platform/internal/currency"
+	"github.com/lvonguyen/finops-platform/internal/telemetry"
+)
+
+// CostStreamer is implemented by providers that can hand over entries one
+// at a time as they read them, such as large billing exports, instead of
+// returning them all at once. StreamCosts stops at the first error fn
+// returns.
+type CostStreamer interface {
+	StreamCosts(ctx context.Context, start, end time.Time, fn func(CostEntry) error) error
+}
+
+// AggregateStream aggregates like Aggregate without keeping the entries,
+// for data too large to hold in memory: each entry counted in the totals is
+// passed to fn (which may be nil) as it arrives, and the result's Entries
+// stay empty. Providers that cannot stream are fetched whole and passed on.
+//
+// Entries are passed on before their provider finishes, so a provider that
+// fails partway has some of its costs in the totals; its error is marked
+// Partial. Tag limits keep the first values seen of each key (see
+// normalizer.TagStream). Charges more than one provider reports are not
+// deduplicated, which would mean remembering every entry. fn is never
+// called concurrently, and an error from it stops the aggregation.
+func (a *Aggregator) AggregateStream(ctx context.Context, start, end time.Time, fn func(CostEntry) error) (*AggregationResult, error) {
+	ctx, span := telemetry.Start(ctx, "AggregateStream",
+		attribute.String("finops.start", start.Format("2006-01-02")),
+		attribute.String("finops.end", end.Format("2006-01-02")))
+
+	a.mu.RLock()
+	providers := make(map[string]CostProvider)
+	for k, v := range a.providers {
+		providers[k] = v
+	}
+	a.mu.RUnlock()
+
+	result, err := a.aggregateStream(ctx, providers, start, end, fn)
+	if result != nil {
+		span.SetAttributes(attribute.Int("finops.provider_errors", len(result.Errors)))
+	}
+	telemetry.End(span, 0, err)
+	return result, err
+}
+
+func (a *Aggregator) aggregateStream(ctx context.Context, providers map[string]CostProvider, start, end time.Time, fn func(CostEntry) error) (*AggregationResult, error) {
+	acc := a.newAccumulator()
+	tags := acc.tagLimits.Stream()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// mu guards the accumulator, the tag limits, fn and the errors
+	var mu sync.Mutex
+	var fnErr error
+	count := func(entry CostEntry) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if fnErr != nil {
+			return fnErr
+		}
+		entry.Tags = tags.Apply(entry.Tags, entry.Cost)
+		entry, ok := acc.add(entry)
+		if ok && fn != nil {
+			if err := fn(entry); err != nil {
+				fnErr = err
+				cancel()
+				return err
+			}
+		}
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	for name, provider := range providers {
+		wg.Add(1)
+		go func(name string, provider CostProvider) {
+			defer wg.Done()
+
+			timeout := acc.timeouts.For(name)
+			fetchCtx, span := telemetry.Start(ctx, "StreamCosts "+name, telemetry.AttrProvider.String(name))
+			fetchCtx, cancelFetch := withTimeout(fetchCtx, timeout)
+			defer cancelFetch()
+			n, err := streamCosts(fetchCtx, provider, start, end, acc.filter, acc.conv, count)
+			telemetry.End(span, n, err)
+			if err == nil {
+				return
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if fnErr != nil {
+				return // stopped by fn, which is the error returned
+			}
+			pe := providerError(ctx, fetchCtx, name, timeout, err)
+			if errors.Is(err, currency.ErrNoRate) {
+				pe.Kind = ErrorKindCurrency
+			}
+			pe.Partial = n > 0
+			acc.result.Errors = append(acc.result.Errors, pe)
+		}(name, provider)
+	}
+	wg.Wait()
+
+	if fnErr != nil {
+		return nil, fnErr
+	}
+	acc.result.TagCaps = tags.Caps()
+	return a.finish(acc.close(), len(providers))
+}
+
+// streamCosts passes a provider's entries within the filter to count,
+// converted to the base currency, returning how many were passed. Providers
+// that cannot stream are fetched whole first, so that they fail before any
+// of their entries are counted.
+func streamCosts(ctx context.Context, provider CostProvider, start, end time.Time, filter CostFilter, conv *currency.Converter, count func(CostEntry) error) (int, error) {
+	streamer, ok := provider.(CostStreamer)
+	if !ok {
+		entries, err := fetchCosts(ctx, provider, start, end, filter)
+		if err != nil {
+			return 0, err
+		}
+		if err := convertCurrency(conv, entries); err != nil {
+			return 0, err
+		}
+		for i, e := range entries {
+			if err := count(e); err != nil {
+				return i, err
+			}
+		}
+		return len(entries), nil
+	}
+
+	filter.Dimensions, filter.Where = nil, nil
+	n := 0
+	stream := func() error {
+		return streamer.StreamCosts(ctx, start, end, func(e CostEntry) error {
+			if !filter.Matches(e) {
+				return nil
+			}
+			if conv != nil {
+				if err := convertEntry(conv, &e); err != nil {
+					return err
+				}
+			}
+			n++
+			return count(e)
+		})
+	}
+	err := stream()
+
+	// Credentials that expire before anything was counted can be refreshed
+	// and the stream started over, as for fetches
+	refresher, ok := provider.(CredentialRefresher)
+	if err == nil || n > 0 || !ok || !errors.Is(err, ErrAuthExpired) {
+		return n, err
+	}
+	trace.SpanFromContext(ctx).AddEvent("credential refresh")
+	if rerr := refresher.RefreshCredentials(ctx); rerr != nil {
+		return 0, fmt.Errorf("%w (credential refresh failed: %v)", err, rerr)
+	}
+	if err := stream(); err != nil {
+		return n, fmt.Errorf("after credential refresh: %w", err)
+	}
+	return n, nil
+}
@@ -0,0 +1,102 @@
+package aggregator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+// streamingProvider streams its entries, failing with err after failAfter
+// of them when err is set
+type streamingProvider struct {
+	fakeProvider
+	failAfter int
+	err       error
+}
+
+func (p *streamingProvider) StreamCosts(ctx context.Context, start, end time.Time, fn func(CostEntry) error) error {
+	for i, e := range p.entries {
+		if p.err != nil && i == p.failAfter {
+			return p.err
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return p.err
+}
+
+func TestAggregateStream(t *testing.T) {
+	a := New(&config.Config{})
+	a.RegisterProvider("aws", &streamingProvider{fakeProvider: fakeProvider{name: "aws", entries: tenthCentEntries(3000, "111", "222")}})
+	a.RegisterProvider("gcp", &fakeProvider{name: "gcp", entries: []CostEntry{
+		{Provider: "gcp", AccountID: "p1", Service: "BigQuery", Date: day, Cost: 7, Currency: "USD"},
+	}})
+	a.SetFilter(CostFilter{Accounts: []string{"111", "p1"}})
+
+	streamed := make(map[string]int)
+	result, err := a.AggregateStream(context.Background(), day, day.AddDate(0, 1, 0), func(e CostEntry) error {
+		streamed[e.AccountID]++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Streamed entries are filtered like fetched ones, and none are kept
+	if streamed["111"] != 1500 || streamed["222"] != 0 || streamed["p1"] != 1 {
+		t.Errorf("streamed %v, want 1500 from 111 and 1 from p1", streamed)
+	}
+	if result.TotalCost != 8.5 || result.ByProvider["aws"] != 1.5 || len(result.Entries) != 0 {
+		t.Errorf("TotalCost = %v, ByProvider = %v with %d entries; want 8.5 and no entries", result.TotalCost, result.ByProvider, len(result.Entries))
+	}
+}
+
+func TestAggregateStreamPartial(t *testing.T) {
+	a := New(&config.Config{})
+	a.RegisterProvider("aws", &streamingProvider{
+		fakeProvider: fakeProvider{name: "aws", entries: tenthCentEntries(1000, "111")},
+		failAfter:    400,
+		err:          errors.New("part 2 unreadable"),
+	})
+	a.RegisterProvider("gcp", &streamingProvider{
+		fakeProvider: fakeProvider{name: "gcp", entries: tenthCentEntries(10, "p1")},
+		err:          errors.New("access denied"),
+	})
+	a.RegisterProvider("oci", &fakeProvider{name: "oci", entries: tenthCentEntries(1000, "t1")})
+
+	result, err := a.AggregateStream(context.Background(), day, day.AddDate(0, 1, 0), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The costs streamed before the failure stay in the totals
+	if result.TotalCost != 1.4 {
+		t.Errorf("TotalCost = %v, want 1.4", result.TotalCost)
+	}
+	want := []ProviderError{
+		{Provider: "aws", Kind: ErrorKindAPI, Message: "part 2 unreadable", Partial: true},
+		{Provider: "gcp", Kind: ErrorKindAPI, Message: "access denied"},
+	}
+	if len(result.Errors) != 2 || result.Errors[0] != want[0] || result.Errors[1] != want[1] {
+		t.Errorf("Errors = %+v, want %+v", result.Errors, want)
+	}
+}
+
+func TestAggregateStreamStopsOnError(t *testing.T) {
+	a := New(&config.Config{})
+	a.RegisterProvider("aws", &streamingProvider{fakeProvider: fakeProvider{name: "aws", entries: tenthCentEntries(1000, "111")}})
+
+	full := errors.New("disk full")
+	n := 0
+	_, err := a.AggregateStream(context.Background(), day, day.AddDate(0, 1, 0), func(CostEntry) error {
+		if n++; n == 10 {
+			return full
+		}
+		return nil
+	})
+	if !errors.Is(err, full) || n != 10 {
+		t.Errorf("AggregateStream() = %v after %d entries, want the writer's error after 10", err, n)
+	}
+}
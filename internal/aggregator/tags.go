@@ -0,0 +1,49 @@
+package aggregator
+
+import (
+	"fmt"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+// TagLimitsFrom validates the configured tag limits
+func TagLimitsFrom(cfg config.TagLimitsConfig) (normalizer.TagLimits, error) {
+	if cfg.MaxValues < 0 {
+		return normalizer.TagLimits{}, fmt.Errorf("max values must not be negative")
+	}
+	if cfg.MaxLength < 0 {
+		return normalizer.TagLimits{}, fmt.Errorf("max length must not be negative")
+	}
+	for _, key := range cfg.Ignore {
+		if key == "" || key == "*" {
+			return normalizer.TagLimits{}, fmt.Errorf("ignore list entry %q would drop every tag", key)
+		}
+	}
+	return normalizer.TagLimits{MaxValues: cfg.MaxValues, MaxLength: cfg.MaxLength, Ignore: cfg.Ignore}, nil
+}
+
+// SetTagLimits bounds the tags of aggregated entries
+func (a *Aggregator) SetTagLimits(l normalizer.TagLimits) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.tagLimits = l
+}
+
+// guardTags applies tag limits across the entries of every provider, so a
+// key's most expensive values are kept whichever cloud reported them
+func guardTags(limits normalizer.TagLimits, entries []CostEntry) []normalizer.TagCap {
+	if limits.IsZero() || len(entries) == 0 {
+		return nil
+	}
+	tags := make([]map[string]string, len(entries))
+	costs := make([]float64, len(entries))
+	for i, e := range entries {
+		tags[i], costs[i] = e.Tags, e.Cost
+	}
+	caps := limits.Apply(tags, costs)
+	for i := range entries {
+		entries[i].Tags = tags[i]
+	}
+	return caps
+}
@@ -0,0 +1,51 @@
+package aggregator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+func TestTagLimitsFrom(t *testing.T) {
+	for _, cfg := range []config.TagLimitsConfig{
+		{MaxValues: -1},
+		{MaxLength: -1},
+		{Ignore: []string{"*"}},
+		{Ignore: []string{""}},
+	} {
+		if _, err := TagLimitsFrom(cfg); err == nil {
+			t.Errorf("tag limits %+v accepted", cfg)
+		}
+	}
+}
+
+func TestAggregateTagLimits(t *testing.T) {
+	entry := func(provider, team string, cost float64) CostEntry {
+		return CostEntry{Provider: provider, Service: "Compute", Date: day, Cost: cost, Tags: map[string]string{"team": team}}
+	}
+
+	a := New(&config.Config{})
+	a.SetTagLimits(normalizer.TagLimits{MaxValues: 2})
+	a.RegisterProvider("aws", &fakeProvider{name: "aws", entries: []CostEntry{entry("aws", "web", 40), entry("aws", "ops", 5)}})
+	a.RegisterProvider("gcp", &fakeProvider{name: "gcp", entries: []CostEntry{entry("gcp", "data", 30), entry("gcp", "ml", 1)}})
+	result, err := a.Aggregate(context.Background(), day, day.AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	byTeam := make(map[string]float64)
+	for _, e := range result.Entries {
+		byTeam[e.Tags["team"]] += e.Cost
+	}
+	if len(byTeam) != 3 || byTeam["web"] != 40 || byTeam["data"] != 30 || byTeam[normalizer.TagOverflow] != 6 {
+		t.Errorf("cost by team = %v, want web and data kept across providers and 6 in %s", byTeam, normalizer.TagOverflow)
+	}
+	if result.TotalCost != 76 {
+		t.Errorf("total = %v, want 76", result.TotalCost)
+	}
+	if len(result.TagCaps) != 1 || result.TagCaps[0].Values != 2 || result.TagCaps[0].Cost != 6 {
+		t.Errorf("TagCaps = %+v, want team overflowing 2 values costing 6", result.TagCaps)
+	}
+}
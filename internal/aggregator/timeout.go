@@ -0,0 +1,68 @@
+package aggregator
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+// Timeouts bounds how long each provider's fetch may take. A provider that
+// runs out of time is reported in AggregationResult.Errors and the others'
+// results are kept.
+type Timeouts struct {
+	Default    time.Duration
+	ByProvider map[string]time.Duration
+}
+
+// TimeoutsFrom parses the configured fetch timeouts
+func TimeoutsFrom(cfg config.FetchConfig) (Timeouts, error) {
+	t := Timeouts{ByProvider: make(map[string]time.Duration, len(cfg.Timeouts))}
+	if cfg.Timeout != "" {
+		d, err := time.ParseDuration(cfg.Timeout)
+		if err != nil || d < 0 {
+			return Timeouts{}, fmt.Errorf("invalid timeout %q", cfg.Timeout)
+		}
+		t.Default = d
+	}
+	for name, s := range cfg.Timeouts {
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			return Timeouts{}, fmt.Errorf("invalid timeout %q for %s", s, name)
+		}
+		t.ByProvider[name] = d
+	}
+	return t, nil
+}
+
+// For returns the provider's timeout; zero means none
+func (t Timeouts) For(provider string) time.Duration {
+	if d, ok := t.ByProvider[provider]; ok {
+		return d
+	}
+	return t.Default
+}
+
+// SetTimeouts sets the per-provider fetch timeouts
+func (a *Aggregator) SetTimeouts(t Timeouts) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.timeouts = t
+}
+
+// Failures returns the provider errors of every aggregation so far, the
+// latest per provider, so a run that fetched several periods can tell
+// whether any of its results were partial
+func (a *Aggregator) Failures() []ProviderError {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	failures := make([]ProviderError, 0, len(a.failures))
+	for _, e := range a.failures {
+		failures = append(failures, e)
+	}
+	sort.Slice(failures, func(i, j int) bool {
+		return failures[i].Provider < failures[j].Provider
+	})
+	return failures
+}
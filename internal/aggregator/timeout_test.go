@@ -0,0 +1,82 @@
+package aggregator
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+// slowProvider answers only once its context is done
+type slowProvider struct{ fakeProvider }
+
+func (p *slowProvider) GetCosts(ctx context.Context, start, end time.Time) ([]CostEntry, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestTimeoutsFrom(t *testing.T) {
+	got, err := TimeoutsFrom(config.FetchConfig{Timeout: "30m", Timeouts: map[string]string{"azure": "1h"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Timeouts{Default: 30 * time.Minute, ByProvider: map[string]time.Duration{"azure": time.Hour}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("TimeoutsFrom() = %+v, want %+v", got, want)
+	}
+	if got.For("azure") != time.Hour || got.For("aws") != 30*time.Minute {
+		t.Errorf("For() = %v, %v; want 1h for azure, 30m for the rest", got.For("azure"), got.For("aws"))
+	}
+	if none, _ := TimeoutsFrom(config.FetchConfig{}); none.For("aws") != 0 {
+		t.Errorf("For() without timeouts = %v, want none", none.For("aws"))
+	}
+
+	for name, cfg := range map[string]config.FetchConfig{
+		"bad default":       {Timeout: "half an hour"},
+		"negative default":  {Timeout: "-1m"},
+		"zero for provider": {Timeouts: map[string]string{"aws": "0s"}},
+		"bad for provider":  {Timeouts: map[string]string{"aws": "1 hour"}},
+	} {
+		if _, err := TimeoutsFrom(cfg); err == nil {
+			t.Errorf("%s: TimeoutsFrom() succeeded, want an error", name)
+		}
+	}
+}
+
+func TestAggregateTimeout(t *testing.T) {
+	a := New(&config.Config{})
+	a.RegisterProvider("aws", &fakeProvider{name: "aws", entries: tenthCentEntries(10, "111")})
+	a.RegisterProvider("slow", &slowProvider{fakeProvider{name: "slow"}})
+	a.SetTimeouts(Timeouts{Default: time.Hour, ByProvider: map[string]time.Duration{"slow": 20 * time.Millisecond}})
+
+	// The slow provider is reported and the others' costs kept
+	result, err := a.Aggregate(context.Background(), day, day.AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ProviderError{{Provider: "slow", Kind: ErrorKindTimeout, Message: "no response within 20ms: context deadline exceeded"}}
+	if !reflect.DeepEqual(result.Errors, want) || result.TotalCost != 0.01 {
+		t.Errorf("Aggregate() = %v with errors %+v, want 0.01 with %+v", result.TotalCost, result.Errors, want)
+	}
+	if got := a.Failures(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Failures() = %+v, want %+v", got, want)
+	}
+
+	// A run cancelled as a whole is not a provider timing out
+	a.SetTimeouts(Timeouts{})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	result, err = a.Aggregate(ctx, day, day.AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Errors) != 1 || result.Errors[0].Kind != ErrorKindAPI {
+		t.Errorf("Errors = %+v, want the slow provider's API error", result.Errors)
+	}
+	// The latest error of each provider is kept across aggregations
+	if got := a.Failures(); len(got) != 1 || got[0].Kind != ErrorKindAPI {
+		t.Errorf("Failures() = %+v, want the latest", got)
+	}
+}
@@ -0,0 +1,76 @@
+package aggregator
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+// Zero-cost handling modes
+const (
+	ZeroCostKeep   = "keep"   // keep zero-cost records like any other
+	ZeroCostDrop   = "drop"   // discard zero-cost records
+	ZeroCostRollup = "rollup" // discard them but count records per service
+)
+
+// zeroCostEpsilon absorbs the rounding noise some providers report for free usage
+const zeroCostEpsilon = 1e-9
+
+// ZeroCostPolicy decides what happens to free-tier and zero-cost records
+type ZeroCostPolicy struct {
+	Mode      string
+	KeepUsage bool // retain zero-cost records with nonzero usage
+}
+
+// ZeroCostPolicyFrom validates the configured zero-cost handling
+func ZeroCostPolicyFrom(cfg config.ZeroCostConfig) (ZeroCostPolicy, error) {
+	switch cfg.Mode {
+	case "":
+		return ZeroCostPolicy{Mode: ZeroCostKeep, KeepUsage: cfg.KeepUsage}, nil
+	case ZeroCostKeep, ZeroCostDrop, ZeroCostRollup:
+		return ZeroCostPolicy{Mode: cfg.Mode, KeepUsage: cfg.KeepUsage}, nil
+	default:
+		return ZeroCostPolicy{}, fmt.Errorf("unknown zero-cost mode %q", cfg.Mode)
+	}
+}
+
+// Discards reports whether the policy removes entry
+func (p ZeroCostPolicy) Discards(entry CostEntry) bool {
+	if p.Mode != ZeroCostDrop && p.Mode != ZeroCostRollup {
+		return false
+	}
+	if math.Abs(entry.Cost) >= zeroCostEpsilon {
+		return false
+	}
+	return !p.KeepUsage || entry.UsageAmount == 0
+}
+
+// SetZeroCost sets how zero-cost records are handled during aggregation
+func (a *Aggregator) SetZeroCost(p ZeroCostPolicy) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.zeroCost = p
+}
+
+// NoCostService counts the zero-cost records rolled up for a service
+type NoCostService struct {
+	Service string `json:"service"`
+	Records int    `json:"records"`
+}
+
+// NoCostServices returns the rolled-up zero-cost services, most records first
+func (r *AggregationResult) NoCostServices() []NoCostService {
+	services := make([]NoCostService, 0, len(r.NoCost))
+	for name, n := range r.NoCost {
+		services = append(services, NoCostService{Service: name, Records: n})
+	}
+	sort.Slice(services, func(i, j int) bool {
+		if services[i].Records != services[j].Records {
+			return services[i].Records > services[j].Records
+		}
+		return services[i].Service < services[j].Service
+	})
+	return services
+}
@@ -0,0 +1,66 @@
+package aggregator
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+func TestZeroCostPolicyFrom(t *testing.T) {
+	p, err := ZeroCostPolicyFrom(config.ZeroCostConfig{})
+	if err != nil || p.Mode != ZeroCostKeep {
+		t.Errorf("default policy = %+v, %v, want keep", p, err)
+	}
+	if _, err := ZeroCostPolicyFrom(config.ZeroCostConfig{Mode: "hide"}); err == nil {
+		t.Error("zero-cost mode hide accepted")
+	}
+}
+
+func TestAggregateZeroCost(t *testing.T) {
+	entries := []CostEntry{
+		{Provider: "aws", Service: "EC2", Date: day, Cost: 10},
+		{Provider: "aws", Service: "Lambda", Date: day, Cost: 0},
+		{Provider: "aws", Service: "Lambda", Date: day, Cost: 1e-12},
+		{Provider: "aws", Service: "Lambda", Date: day, Cost: 0, UsageAmount: 3},
+		{Provider: "aws", Service: "S3", Date: day, Cost: 0},
+		{Provider: "aws", Service: "S3", Date: day, Cost: -2},
+	}
+	tests := []struct {
+		policy   ZeroCostPolicy
+		entries  int
+		zeroCost int
+		noCost   map[string]int
+	}{
+		{ZeroCostPolicy{Mode: ZeroCostKeep}, 6, 0, nil},
+		{ZeroCostPolicy{Mode: ZeroCostDrop}, 2, 4, nil},
+		{ZeroCostPolicy{Mode: ZeroCostDrop, KeepUsage: true}, 3, 3, nil},
+		{ZeroCostPolicy{Mode: ZeroCostRollup}, 2, 4, map[string]int{"Lambda": 3, "S3": 1}},
+	}
+	for _, tt := range tests {
+		a := New(&config.Config{})
+		a.SetZeroCost(tt.policy)
+		a.RegisterProvider("aws", &fakeProvider{name: "aws", entries: entries})
+		result, err := a.Aggregate(context.Background(), day, day.AddDate(0, 1, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(result.Entries) != tt.entries || result.ZeroCost != tt.zeroCost || !reflect.DeepEqual(result.NoCost, tt.noCost) {
+			t.Errorf("%+v: %d entries, %d zero-cost, no-cost %v; want %d, %d, %v",
+				tt.policy, len(result.Entries), result.ZeroCost, result.NoCost, tt.entries, tt.zeroCost, tt.noCost)
+		}
+		if result.TotalCost != 8 {
+			t.Errorf("%+v: total %v, want 8", tt.policy, result.TotalCost)
+		}
+	}
+}
+
+func TestNoCostServices(t *testing.T) {
+	r := &AggregationResult{NoCost: map[string]int{"S3": 2, "Lambda": 5, "IAM": 2}}
+	want := []NoCostService{{"Lambda", 5}, {"IAM", 2}, {"S3", 2}}
+	if got := r.NoCostServices(); !reflect.DeepEqual(got, want) {
+		t.Errorf("NoCostServices() = %v, want %v", got, want)
+	}
+}
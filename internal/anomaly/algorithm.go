@@ -0,0 +1,82 @@
+package anomaly
+
+import (
+	"fmt"
+	"math"
+)
+
+// Detection algorithms for DetectorConfig.Algorithm
+const (
+	// AlgorithmZScore scores against the baseline mean and standard deviation
+	AlgorithmZScore = "zscore"
+	// AlgorithmMAD scores against the median and median absolute deviation
+	// (the modified z-score), which a few spikes in the baseline barely move
+	AlgorithmMAD = "mad"
+	// AlgorithmEWMA scores against an exponentially weighted mean and
+	// deviation, which follow level shifts while clipping spikes
+	AlgorithmEWMA = "ewma"
+)
+
+// defaultEWMAAlpha weights the latest day at 30%
+const defaultEWMAAlpha = 0.3
+
+// Once ewmaWarmup values have set the scale, deviations feeding the ewma
+// baseline are clipped to ewmaClip standard deviations, so a single spike
+// moves it only a little
+const (
+	ewmaWarmup = 7
+	ewmaClip   = 3.0
+)
+
+// ValidateAlgorithm checks an anomaly.algorithm value
+func ValidateAlgorithm(algorithm string) error {
+	switch algorithm {
+	case "", AlgorithmZScore, AlgorithmMAD, AlgorithmEWMA:
+		return nil
+	default:
+		return fmt.Errorf("unknown anomaly algorithm %q (want zscore, mad or ewma)", algorithm)
+	}
+}
+
+// ewma returns the exponentially weighted mean and standard deviation of
+// values in date order, clipping outliers after the warm-up
+func ewma(values []float64, alpha float64) (mean, stdDev float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	mean = values[0]
+	var variance float64
+	for i, v := range values[1:] {
+		diff := v - mean
+		if limit := ewmaClip * math.Sqrt(variance); i+1 >= ewmaWarmup && limit > 0 {
+			diff = math.Max(-limit, math.Min(limit, diff))
+		}
+		incr := alpha * diff
+		mean += incr
+		variance = (1 - alpha) * (variance + diff*incr)
+	}
+	return mean, math.Sqrt(variance)
+}
+
+// score returns how many deviations cost lies from the expected cost under
+// the configured algorithm. ok is false when the baseline has no spread to
+// measure against.
+func (d *Detector) score(cost float64, b Baseline) (score, expected float64, ok bool) {
+	switch d.config.Algorithm {
+	case AlgorithmMAD:
+		if b.MAD == 0 {
+			return 0, b.Median, false
+		}
+		return 0.6745 * (cost - b.Median) / b.MAD, b.Median, true
+	case AlgorithmEWMA:
+		if b.EWMAStdDev == 0 {
+			return 0, b.EWMA, false
+		}
+		return (cost - b.EWMA) / b.EWMAStdDev, b.EWMA, true
+	default:
+		if b.StdDev == 0 {
+			return 0, b.Mean, false
+		}
+		return (cost - b.Mean) / b.StdDev, b.Mean, true
+	}
+}
@@ -0,0 +1,92 @@
+package anomaly
+
+import (
+	"math"
+	"testing"
+
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+func TestValidateAlgorithm(t *testing.T) {
+	for _, algorithm := range []string{"", AlgorithmZScore, AlgorithmMAD, AlgorithmEWMA} {
+		if err := ValidateAlgorithm(algorithm); err != nil {
+			t.Errorf("ValidateAlgorithm(%q) = %v", algorithm, err)
+		}
+	}
+	if err := ValidateAlgorithm("prophet"); err == nil {
+		t.Error("ValidateAlgorithm accepted prophet")
+	}
+}
+
+func TestEWMA(t *testing.T) {
+	if mean, sd := ewma(nil, 0.3); mean != 0 || sd != 0 {
+		t.Errorf("ewma(nil) = %v, %v; want 0, 0", mean, sd)
+	}
+	if mean, sd := ewma([]float64{100, 100, 100}, 0.3); mean != 100 || sd != 0 {
+		t.Errorf("steady ewma = %v, %v; want 100, 0", mean, sd)
+	}
+	// Half of the step to 200 is taken, with variance 0.5 * 100 * 50
+	if mean, sd := ewma([]float64{100, 200}, 0.5); mean != 150 || sd != 50 {
+		t.Errorf("ewma of a step = %v, %v; want 150, 50", mean, sd)
+	}
+
+	// After the warm-up a spike is clipped to 3 deviations
+	values := []float64{100, 102, 98, 101, 99, 100, 102, 98}
+	before, sd := ewma(values, 0.3)
+	after, _ := ewma(append(values, 10000), 0.3)
+	if limit := before + 0.3*ewmaClip*sd; after > limit+1e-9 {
+		t.Errorf("ewma after a spike = %v, want at most %v", after, limit)
+	}
+}
+
+func TestBaselineFromRobustStatistics(t *testing.T) {
+	b := NewDetector(DetectorConfig{}).baselineFrom([]float64{1, 2, 3, 4, 100})
+	if b.Median != 3 || b.MAD != 1 || b.Mean != 22 || b.Count != 5 {
+		t.Errorf("baseline = %+v, want median 3, MAD 1 and mean 22", b)
+	}
+	if b := NewDetector(DetectorConfig{}).baselineFrom([]float64{4, 1, 3, 2}); b.Median != 2.5 || b.MAD != 1 {
+		t.Errorf("even baseline = %+v, want median 2.5 and MAD 1", b)
+	}
+}
+
+// TestAlgorithmsSeePastSpike puts a 5000 spike in the baseline, which
+// masks a tripled cost today from the z-score but not the robust algorithms
+func TestAlgorithmsSeePastSpike(t *testing.T) {
+	records := history("EC2", 30)
+	records[20].Cost = 5000
+	point := charge("EC2", today, 300)
+
+	tests := []struct {
+		algorithm string
+		fired     bool
+	}{
+		{AlgorithmZScore, false},
+		{AlgorithmMAD, true},
+		{AlgorithmEWMA, true},
+	}
+	for _, tt := range tests {
+		d := NewDetector(DetectorConfig{Sensitivity: SensitivityMedium, BaselineDays: 30, RecentDays: 1, Algorithm: tt.algorithm, Explain: true})
+		anomalies := d.detect(append(records, point), []normalizer.CostRecord{point}, today)
+		if got := len(anomalies) == 1; got != tt.fired {
+			t.Errorf("%s: got %d anomalies, want fired %v", tt.algorithm, len(anomalies), tt.fired)
+			continue
+		}
+		if !tt.fired {
+			continue
+		}
+		a := anomalies[0]
+		switch tt.algorithm {
+		case AlgorithmMAD:
+			if a.ExpectedCost != 101 {
+				t.Errorf("mad: expected cost = %v, want the median 101", a.ExpectedCost)
+			}
+		case AlgorithmEWMA:
+			if a.ExpectedCost < 100 || a.ExpectedCost > 150 || math.IsNaN(a.Deviation) {
+				t.Errorf("ewma: expected cost = %v, want the spike clipped to near 100", a.ExpectedCost)
+			}
+		}
+		if e := d.Explanations(); len(e) != 1 || e[0].Algorithm != tt.algorithm {
+			t.Errorf("%s: explanations = %+v, want one by %s", tt.algorithm, e, tt.algorithm)
+		}
+	}
+}
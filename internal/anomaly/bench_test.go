@@ -0,0 +1,26 @@
+package anomaly_test
+
+import (
+	"testing"
+
+	"github.com/lvonguyen/finops-platform/internal/anomaly"
+	"github.com/lvonguyen/finops-platform/internal/synthetic"
+)
+
+func BenchmarkDetect(b *testing.B) {
+	for _, scale := range synthetic.Scales {
+		b.Run(scale.Name, func(b *testing.B) {
+			records := synthetic.Records(synthetic.Cached(scale.Records))
+			detector := anomaly.NewDetector(anomaly.DetectorConfig{
+				Sensitivity:  anomaly.SensitivityMedium,
+				BaselineDays: 30,
+				MinSpend:     10,
+			})
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				detector.Detect(records)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package anomaly
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Change is a known change (a deploy, a migration) to a scope. Anomalies in
+// the scope are suppressed for the detector's cooldown, starting on the day
+// of the change. Empty scope fields match anything.
+type Change struct {
+	Cloud   string    `json:"cloud,omitempty"`
+	Account string    `json:"account,omitempty"`
+	Service string    `json:"service,omitempty"`
+	Note    string    `json:"note,omitempty"`
+	At      time.Time `json:"at"`
+}
+
+// ParseScope parses "cloud/account/service"; trailing parts may be omitted
+// and empty or "*" parts match anything
+func ParseScope(s string) (Change, error) {
+	parts := strings.Split(s, "/")
+	if s == "" || len(parts) > 3 {
+		return Change{}, fmt.Errorf("invalid scope %q (want cloud/account/service)", s)
+	}
+	for i, p := range parts {
+		if p == "*" {
+			parts[i] = ""
+		}
+	}
+	for len(parts) < 3 {
+		parts = append(parts, "")
+	}
+	return Change{Cloud: parts[0], Account: parts[1], Service: parts[2]}, nil
+}
+
+// Scope returns the change's scope as cloud/account/service
+func (c Change) Scope() string {
+	parts := []string{c.Cloud, c.Account, c.Service}
+	for i, p := range parts {
+		if p == "" {
+			parts[i] = "*"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// Until returns when the cooldown after the change ends
+func (c Change) Until(cooldown time.Duration) time.Time {
+	return c.At.Add(cooldown)
+}
+
+// Active reports whether the change's cooldown covers now
+func (c Change) Active(now time.Time, cooldown time.Duration) bool {
+	return c.covers(now, cooldown)
+}
+
+// covers reports whether t falls between the start of the change's day and
+// the end of its cooldown, so daily data for the day of the change is covered
+func (c Change) covers(t time.Time, cooldown time.Duration) bool {
+	day := time.Date(c.At.Year(), c.At.Month(), c.At.Day(), 0, 0, 0, 0, c.At.Location())
+	return !t.Before(day) && t.Before(c.Until(cooldown))
+}
+
+func (c Change) matches(a Anomaly) bool {
+	return (c.Cloud == "" || c.Cloud == a.Cloud) &&
+		(c.Account == "" || c.Account == a.Account) &&
+		(c.Service == "" || c.Service == a.Service)
+}
+
+// cooldownFor returns the change whose cooldown covers the anomaly
+func (d *Detector) cooldownFor(a Anomaly) (Change, bool) {
+	if d.config.Cooldown <= 0 {
+		return Change{}, false
+	}
+	for _, c := range d.config.Changes {
+		if c.matches(a) && c.covers(a.Date, d.config.Cooldown) {
+			return c, true
+		}
+	}
+	return Change{}, false
+}
+
+func describeChange(c Change) string {
+	s := c.Scope() + " change at " + c.At.Format("2006-01-02 15:04 MST")
+	if c.Note != "" {
+		s += " (" + c.Note + ")"
+	}
+	return s
+}
+
+// Cooldown is a change whose cooldown is in effect
+type Cooldown struct {
+	Change
+	Until time.Time `json:"until"`
+}
+
+// ActiveCooldowns returns the changes whose cooldown covers now, most recent first
+func ActiveCooldowns(changes []Change, now time.Time, cooldown time.Duration) []Cooldown {
+	var active []Cooldown
+	for _, c := range changes {
+		if cooldown > 0 && c.Active(now, cooldown) {
+			active = append(active, Cooldown{Change: c, Until: c.Until(cooldown)})
+		}
+	}
+	sort.Slice(active, func(i, j int) bool {
+		return active[i].At.After(active[j].At)
+	})
+	return active
+}
+
+// LoadChanges reads recorded changes. A missing file means none.
+func LoadChanges(path string) ([]Change, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read changes: %w", err)
+	}
+
+	var changes []Change
+	if err := json.Unmarshal(data, &changes); err != nil {
+		return nil, fmt.Errorf("failed to parse changes: %w", err)
+	}
+	return changes, nil
+}
+
+// RecordChange appends a change to the file, dropping changes whose
+// cooldown ended before c was made
+func RecordChange(path string, c Change, cooldown time.Duration) error {
+	changes, err := LoadChanges(path)
+	if err != nil {
+		return err
+	}
+
+	kept := make([]Change, 0, len(changes)+1)
+	for _, existing := range changes {
+		if existing.Until(cooldown).After(c.At) {
+			kept = append(kept, existing)
+		}
+	}
+	kept = append(kept, c)
+
+	data, err := json.MarshalIndent(kept, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode changes: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create changes directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write changes: %w", err)
+	}
+	return nil
+}
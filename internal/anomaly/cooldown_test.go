@@ -0,0 +1,152 @@
+package anomaly
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+func TestParseScope(t *testing.T) {
+	tests := []struct {
+		scope string
+		want  Change
+		str   string
+	}{
+		{"aws", Change{Cloud: "aws"}, "aws/*/*"},
+		{"aws/111", Change{Cloud: "aws", Account: "111"}, "aws/111/*"},
+		{"aws/*/EC2", Change{Cloud: "aws", Service: "EC2"}, "aws/*/EC2"},
+		{"*/111/S3", Change{Account: "111", Service: "S3"}, "*/111/S3"},
+	}
+	for _, tt := range tests {
+		got, err := ParseScope(tt.scope)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want || got.Scope() != tt.str {
+			t.Errorf("ParseScope(%q) = %+v (%s), want %+v (%s)", tt.scope, got, got.Scope(), tt.want, tt.str)
+		}
+	}
+	for _, bad := range []string{"", "aws/111/EC2/extra"} {
+		if _, err := ParseScope(bad); err == nil {
+			t.Errorf("ParseScope(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestChangeActive(t *testing.T) {
+	c := Change{At: today.Add(15 * time.Hour)}
+	tests := []struct {
+		at   time.Time
+		want bool
+	}{
+		{today.Add(-time.Second), false},
+		{today, true}, // daily data for the day of the change
+		{today.Add(38 * time.Hour), true},
+		{today.Add(39 * time.Hour), false},
+	}
+	for _, tt := range tests {
+		if got := c.Active(tt.at, 24*time.Hour); got != tt.want {
+			t.Errorf("Active(%s) = %v, want %v", tt.at, got, tt.want)
+		}
+	}
+}
+
+func TestCooldownSuppressesAnomalies(t *testing.T) {
+	records := append(history("EC2", 30), history("S3", 30)...)
+	spikes := []normalizer.CostRecord{charge("EC2", today, 500), charge("S3", today, 500)}
+	records = append(records, spikes...)
+
+	tests := []struct {
+		name       string
+		change     Change
+		cooldown   time.Duration
+		fired      string
+		suppressed string
+	}{
+		{"no cooldown", Change{Cloud: "aws", Service: "EC2", At: today}, 0, "EC2,S3", ""},
+		{"service in cooldown", Change{Cloud: "aws", Service: "EC2", At: today.Add(9 * time.Hour)}, 24 * time.Hour, "S3", "EC2"},
+		{"account in cooldown", Change{Account: "111", At: today}, 24 * time.Hour, "", "EC2,S3"},
+		{"other account", Change{Account: "222", At: today}, 24 * time.Hour, "EC2,S3", ""},
+		{"cooldown over", Change{Cloud: "aws", At: today.AddDate(0, 0, -3)}, 48 * time.Hour, "EC2,S3", ""},
+	}
+	for _, tt := range tests {
+		d := NewDetector(DetectorConfig{
+			Sensitivity: SensitivityMedium, BaselineDays: 30, RecentDays: 1, Explain: true,
+			Changes: []Change{tt.change}, Cooldown: tt.cooldown,
+		})
+		anomalies := d.detect(records, spikes, today)
+
+		if got := services(anomalies); got != tt.fired {
+			t.Errorf("%s: fired %v, want %v", tt.name, got, tt.fired)
+		}
+		if got := services(d.Suppressed()); got != tt.suppressed {
+			t.Errorf("%s: suppressed %v, want %v", tt.name, got, tt.suppressed)
+		}
+		for _, a := range d.Suppressed() {
+			if a.Cooldown != describeChange(tt.change) {
+				t.Errorf("%s: %s cooldown = %q, want %q", tt.name, a.Service, a.Cooldown, describeChange(tt.change))
+			}
+		}
+		if len(d.Explanations()) != 2 {
+			t.Errorf("%s: got %d explanations, want one per spike", tt.name, len(d.Explanations()))
+		}
+	}
+}
+
+// services returns the sorted services of anomalies, comma-separated
+func services(anomalies []Anomaly) string {
+	var s []string
+	for _, a := range anomalies {
+		s = append(s, a.Service)
+	}
+	sort.Strings(s)
+	return strings.Join(s, ",")
+}
+
+func TestActiveCooldowns(t *testing.T) {
+	now := today.Add(12 * time.Hour)
+	changes := []Change{
+		{Service: "EC2", At: today.Add(-36 * time.Hour)},
+		{Service: "S3", At: today.Add(time.Hour)},
+		{Service: "RDS", At: today.Add(-2 * time.Hour)},
+	}
+	active := ActiveCooldowns(changes, now, 24*time.Hour)
+	if len(active) != 2 || active[0].Service != "S3" || active[1].Service != "RDS" {
+		t.Fatalf("ActiveCooldowns() = %+v, want S3 then RDS", active)
+	}
+	if !active[0].Until.Equal(today.Add(25 * time.Hour)) {
+		t.Errorf("S3 until %s, want %s", active[0].Until, today.Add(25*time.Hour))
+	}
+	if got := ActiveCooldowns(changes, now, 0); len(got) != 0 {
+		t.Errorf("ActiveCooldowns() without a cooldown = %+v, want none", got)
+	}
+}
+
+func TestRecordChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "changes.json")
+	if changes, err := LoadChanges(path); err != nil || changes != nil {
+		t.Fatalf("LoadChanges() of a missing file = %v, %v, want none", changes, err)
+	}
+
+	for _, c := range []Change{
+		{Service: "EC2", At: today},
+		{Service: "S3", At: today.Add(20 * time.Hour), Note: "resize"},
+		{Service: "RDS", At: today.Add(30 * time.Hour)},
+	} {
+		if err := RecordChange(path, c, 24*time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	changes, err := LoadChanges(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 || changes[0].Service != "S3" || changes[0].Note != "resize" || changes[1].Service != "RDS" {
+		t.Errorf("changes = %+v, want S3 and RDS with EC2's ended cooldown dropped", changes)
+	}
+}
@@ -2,10 +2,12 @@
 package anomaly
 
 import (
+	"fmt"
 	"math"
 	"sort"
 	"time"
 
+	"github.com/lvonguyen/finops-platform/internal/calendar"
 	"github.com/lvonguyen/finops-platform/internal/normalizer"
 )
 
@@ -18,35 +20,121 @@ const (
 	SensitivityHigh   Sensitivity = "high"
 )
 
+// HolidayMode controls how special calendar days are evaluated
+type HolidayMode string
+
+const (
+	// HolidayExclude drops special days from baselines and never flags them
+	HolidayExclude HolidayMode = "exclude"
+	// HolidayEquivalent compares a special day only against prior days with the same calendar key
+	HolidayEquivalent HolidayMode = "equivalent"
+)
+
 // DetectorConfig holds configuration for anomaly detection
 type DetectorConfig struct {
 	Sensitivity  Sensitivity
-	BaselineDays int     // Days for baseline calculation
-	MinSpend     float64 // Minimum spend to consider
+	BaselineDays int                // Days for baseline calculation
+	RecentDays   int                // Days evaluated against the baseline (default 7)
+	MinSpend     float64            // Minimum spend to consider
+	Calendar     *calendar.Calendar // Optional special days (holidays, sales events)
+	HolidayMode  HolidayMode
+	Explain      bool          // Record an Explanation for every anomaly and near miss
+	NearMiss     float64       // Fraction of the threshold at which a non-anomaly is explained (default 0.75)
+	Changes      []Change      // Known changes whose scopes are in cooldown
+	Cooldown     time.Duration // How long anomalies are suppressed after a change
+	RampGrace    time.Duration // How long after its first spend an account may be ramping up (0 disables)
+	RampAction   string        // soften (default) or suppress anomalies in ramping accounts
+	MultiMetric  bool          // Classify anomalies by whether usage and record counts moved with cost
+	Seasonality  Seasonality   // Weekday and month-start patterns modeled in baselines
+	Algorithm    string        // zscore (default), mad or ewma
+	EWMAAlpha    float64       // Weight of the latest day in the ewma baseline (default 0.3)
+
+	Scopes []Scope // Groupings baselined besides each cloud service
 }
 
 // Anomaly represents a detected cost anomaly
 type Anomaly struct {
+	ID            string    `json:"id"` // stable across runs, see ID
 	Date          time.Time `json:"date"`
 	Service       string    `json:"service"`
 	Account       string    `json:"account"`
 	Cloud         string    `json:"cloud"`
+	Resource      string    `json:"resource,omitempty"` // set when the provider reports resource-level costs
+	Group         string    `json:"group,omitempty"`    // scope and group value, for scope anomalies
 	ActualCost    float64   `json:"actual_cost"`
 	ExpectedCost  float64   `json:"expected_cost"`
 	Deviation     float64   `json:"deviation"`
 	PercentChange float64   `json:"percent_change"`
 	Reason        string    `json:"reason"`
-	Severity      string    `json:"severity"` // low, medium, high, critical
+	Severity      string    `json:"severity"`           // low, medium, high, critical
+	Cooldown      string    `json:"cooldown,omitempty"` // change that suppressed the anomaly
+	Ramp          string    `json:"ramp,omitempty"`     // ramp-up phase that softened or suppressed the anomaly
+	Kind          string    `json:"kind,omitempty"`     // rate_change, scaling, new_resources or removed_resources, with MultiMetric
+	Signals       *Signals  `json:"signals,omitempty"`
+
+	Status string `json:"status,omitempty"` // workflow status, set when tracked
+}
+
+// Scope returns the anomaly's cloud/account/service, with its resource when
+// it is resource-level, or its scope group
+func (a Anomaly) Scope() string {
+	if a.Group != "" {
+		return a.Group
+	}
+	scope := a.Cloud + "/" + a.Account + "/" + a.Service
+	if a.Resource != "" {
+		scope += "/" + a.Resource
+	}
+	return scope
+}
+
+// Explanation records what the detector computed for one data point and
+// why it did or did not fire
+type Explanation struct {
+	Date           time.Time `json:"date"`
+	Cloud          string    `json:"cloud"`
+	Service        string    `json:"service"`
+	Account        string    `json:"account"`
+	Group          string    `json:"group,omitempty"`
+	ActualCost     float64   `json:"actual_cost"`
+	Baseline       Baseline  `json:"baseline"`
+	BaselineStart  time.Time `json:"baseline_start"`
+	BaselineEnd    time.Time `json:"baseline_end"`
+	BaselineDays   int       `json:"baseline_days"`
+	RecentDays     int       `json:"recent_days"`
+	Comparison     string    `json:"comparison"` // baseline or equivalent special days
+	Algorithm      string    `json:"algorithm"`
+	Score          float64   `json:"score"` // the algorithm's deviation score, compared with the threshold
+	ZScore         float64   `json:"z_score"`
+	ModifiedZScore float64   `json:"modified_z_score"` // 0.6745 * (x - median) / MAD
+	Threshold      float64   `json:"threshold"`
+	Fired          bool      `json:"fired"`
+	Decision       string    `json:"decision"`
 }
 
 // Detector performs anomaly detection on cost data
 type Detector struct {
-	config      DetectorConfig
-	thresholds  map[Sensitivity]float64 // Z-score thresholds
+	config       DetectorConfig
+	thresholds   map[Sensitivity]float64 // Z-score thresholds
+	explanations []Explanation
+	suppressed   []Anomaly
+	ramping      []RampAccount
 }
 
 // NewDetector creates a new anomaly detector
 func NewDetector(cfg DetectorConfig) *Detector {
+	if cfg.RecentDays == 0 {
+		cfg.RecentDays = 7
+	}
+	if cfg.NearMiss == 0 {
+		cfg.NearMiss = 0.75
+	}
+	if cfg.Algorithm == "" {
+		cfg.Algorithm = AlgorithmZScore
+	}
+	if cfg.EWMAAlpha == 0 {
+		cfg.EWMAAlpha = defaultEWMAAlpha
+	}
 	return &Detector{
 		config: cfg,
 		thresholds: map[Sensitivity]float64{
@@ -59,37 +147,46 @@ func NewDetector(cfg DetectorConfig) *Detector {
 
 // Detect analyzes cost records for anomalies
 func (d *Detector) Detect(records []normalizer.CostRecord) []Anomaly {
-	if len(records) == 0 {
+	return d.detect(records, records, time.Now())
+}
+
+// detect computes baselines from records as of now and evaluates the
+// candidates that fall in the recent window. Batch detection passes the same
+// slice for both; incremental detection passes only newly landed records as
+// candidates.
+func (d *Detector) detect(records, candidates []normalizer.CostRecord, now time.Time) []Anomaly {
+	if len(candidates) == 0 {
 		return nil
 	}
 
+	d.explanations = nil
+	d.suppressed = nil
+	d.ramping = RampingAccounts(records, now, d.config.RampGrace)
+
 	// Group by service
-	byService := make(map[string][]normalizer.CostRecord)
+	byService := make(map[string]*group)
 	for _, r := range records {
-		key := r.Cloud + ":" + r.Service
-		byService[key] = append(byService[key], r)
+		key := groupKey(r)
+		if byService[key] == nil {
+			byService[key] = &group{threshold: d.thresholds[d.config.Sensitivity]}
+		}
+		byService[key].records = append(byService[key].records, r)
 	}
-
-	var anomalies []Anomaly
-
-	for serviceKey, serviceRecords := range byService {
-		// Sort by date
-		sort.Slice(serviceRecords, func(i, j int) bool {
-			return serviceRecords[i].Date.Before(serviceRecords[j].Date)
-		})
-
-		// Calculate baseline from historical data
-		baseline := d.calculateBaseline(serviceRecords)
-		if baseline.Mean < d.config.MinSpend {
-			continue // Skip low-spend services
+	for _, r := range candidates {
+		if g := byService[groupKey(r)]; g != nil {
+			g.candidates = append(g.candidates, r)
 		}
+	}
 
-		// Check recent records for anomalies
-		recentRecords := d.getRecentRecords(serviceRecords, 7)
-		for _, r := range recentRecords {
-			if anomaly := d.checkAnomaly(r, baseline, serviceKey); anomaly != nil {
-				anomalies = append(anomalies, *anomaly)
-			}
+	var anomalies []Anomaly
+	for _, g := range byService {
+		g.raw = g.records
+		anomalies = append(anomalies, d.detectGroup(g, now)...)
+	}
+	for _, scope := range d.config.Scopes {
+		for value, g := range scope.groups(records, candidates) {
+			g.threshold = d.thresholds[scope.sensitivity(value, d.config.Sensitivity)]
+			anomalies = append(anomalies, d.detectGroup(g, now)...)
 		}
 	}
 
@@ -101,27 +198,243 @@ func (d *Detector) Detect(records []normalizer.CostRecord) []Anomaly {
 	return anomalies
 }
 
+// group is a set of records baselined together: a cloud service's records,
+// or a scope group's daily totals
+type group struct {
+	label      string // scope and group value, empty for a cloud service
+	threshold  float64
+	records    []normalizer.CostRecord
+	candidates []normalizer.CostRecord
+	raw        []normalizer.CostRecord // line items behind records, for multi-metric scoring
+}
+
+// detectGroup evaluates a group's recent candidates against its baseline
+func (d *Detector) detectGroup(g *group, now time.Time) []Anomaly {
+	if len(g.candidates) == 0 {
+		return nil
+	}
+
+	// Sort by date
+	sort.Slice(g.records, func(i, j int) bool {
+		return g.records[i].Date.Before(g.records[j].Date)
+	})
+	sort.SliceStable(g.candidates, func(i, j int) bool {
+		return g.candidates[i].Date.Before(g.candidates[j].Date)
+	})
+
+	// Calculate baseline from historical data
+	baseline := d.calculateBaseline(g.records, now)
+	if baseline.Mean < d.config.MinSpend {
+		return nil // Skip low-spend groups
+	}
+
+	// Check recent records for anomalies
+	var anomalies []Anomaly
+	recentRecords := d.getRecentRecords(g.candidates, d.config.RecentDays, now)
+	for _, r := range recentRecords {
+		recordBaseline := baseline.forDay(r.Date)
+		comparison := "baseline"
+		if len(baseline.WeekdayFactors) == 7 {
+			comparison = "weekday baseline (" + r.Date.Weekday().String() + ")"
+		}
+		if day, ok := d.config.Calendar.Special(r.Date); ok {
+			if d.config.HolidayMode != HolidayEquivalent {
+				d.explain(g, r, baseline, comparison, false, "special day "+day.Name+" excluded from detection", now)
+				continue // Predictable calendar event
+			}
+			recordBaseline = d.equivalentBaseline(g.records, r.Date, day.Key)
+			comparison = "equivalent days (" + day.Key + ")"
+			if recordBaseline.Count < 2 {
+				d.explain(g, r, recordBaseline, comparison, false, "fewer than 2 prior equivalent days to compare against", now)
+				continue // No prior equivalent days to compare against
+			}
+		} else if d.monthStart(r.Date) {
+			recordBaseline = d.monthStartBaseline(g.records, r.Date)
+			comparison = "earlier month starts"
+			if recordBaseline.Count < 2 {
+				d.explain(g, r, recordBaseline, comparison, false, "fewer than 2 earlier month starts to compare against", now)
+				continue // Monthly charges would stand out against ordinary days
+			}
+		}
+
+		anomaly := d.checkAnomaly(g, r, recordBaseline)
+		if anomaly != nil && d.config.MultiMetric {
+			anomaly.Kind, anomaly.Signals = d.classify(*anomaly, g.raw, now)
+			if anomaly.Kind != "" {
+				anomaly.Reason = describeKind(anomaly.Kind)
+			}
+		}
+		if anomaly != nil {
+			if change, ok := d.cooldownFor(*anomaly); ok {
+				anomaly.Cooldown = describeChange(change)
+				d.suppressed = append(d.suppressed, *anomaly)
+				d.explain(g, r, recordBaseline, comparison, true, "suppressed: cooldown after "+anomaly.Cooldown, now)
+				continue
+			}
+			if ramp, ok := d.rampFor(*anomaly); ok {
+				anomaly.Ramp = describeRamp(ramp)
+				if d.config.RampAction == RampSuppress {
+					d.suppressed = append(d.suppressed, *anomaly)
+					d.explain(g, r, recordBaseline, comparison, true, "suppressed: "+anomaly.Ramp, now)
+					continue
+				}
+				anomaly.Severity = "low"
+				anomaly.Reason = "New account ramping up - " + anomaly.Reason
+			}
+			anomalies = append(anomalies, *anomaly)
+		}
+		d.explain(g, r, recordBaseline, comparison, anomaly != nil, "", now)
+	}
+	return anomalies
+}
+
+// Suppressed returns the anomalies the last Detect call held back because
+// their scope was in cooldown after a recorded change or their account was
+// ramping up
+func (d *Detector) Suppressed() []Anomaly {
+	return d.suppressed
+}
+
+// Explanations returns what the last Detect call computed, when Explain is
+// enabled: every anomaly plus the near misses and skipped special days
+func (d *Detector) Explanations() []Explanation {
+	return d.explanations
+}
+
+// explain records an Explanation for r. Points that did not fire are only
+// kept when close to the threshold or when an explicit decision is given.
+func (d *Detector) explain(g *group, r normalizer.CostRecord, baseline Baseline, comparison string, fired bool, decision string, now time.Time) {
+	if !d.config.Explain {
+		return
+	}
+
+	threshold := g.threshold
+	score, _, scored := d.score(r.Cost, baseline)
+	var z, modZ float64
+	if baseline.StdDev > 0 {
+		z = (r.Cost - baseline.Mean) / baseline.StdDev
+	}
+	if baseline.MAD > 0 {
+		modZ = 0.6745 * (r.Cost - baseline.Median) / baseline.MAD
+	}
+
+	if decision == "" {
+		switch {
+		case fired:
+			decision = fmt.Sprintf("fired: |score| %.2f >= threshold %.2f", math.Abs(score), threshold)
+		case !scored:
+			decision = "not fired: baseline has no variance"
+		case math.Abs(score) >= threshold*d.config.NearMiss:
+			decision = fmt.Sprintf("not fired: |score| %.2f below threshold %.2f (near miss)", math.Abs(score), threshold)
+		default:
+			return // Unremarkable, not worth the output size
+		}
+	}
+
+	end := now.AddDate(0, 0, -d.config.RecentDays)
+	d.explanations = append(d.explanations, Explanation{
+		Date:           r.Date,
+		Cloud:          r.Cloud,
+		Service:        r.Service,
+		Account:        r.Account,
+		Group:          g.label,
+		ActualCost:     r.Cost,
+		Baseline:       baseline,
+		BaselineStart:  end.AddDate(0, 0, -d.config.BaselineDays),
+		BaselineEnd:    end,
+		BaselineDays:   d.config.BaselineDays,
+		RecentDays:     d.config.RecentDays,
+		Comparison:     comparison,
+		Algorithm:      d.config.Algorithm,
+		Score:          score,
+		ZScore:         z,
+		ModifiedZScore: modZ,
+		Threshold:      threshold,
+		Fired:          fired,
+		Decision:       decision,
+	})
+}
+
 // Baseline holds statistical baseline for a service
 type Baseline struct {
-	Mean   float64
-	StdDev float64
-	Min    float64
-	Max    float64
-	Count  int
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"std_dev"`
+	Median float64 `json:"median"`
+	MAD    float64 `json:"mad"` // median absolute deviation
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	Count  int     `json:"count"`
+
+	// Exponentially weighted mean and deviation, by date
+	EWMA       float64 `json:"ewma"`
+	EWMAStdDev float64 `json:"ewma_std_dev"`
+
+	// Weekday factors, by time.Weekday, when the statistics above are
+	// weekday-adjusted; forDay scales them back to a given day
+	WeekdayFactors []float64 `json:"weekday_factors,omitempty"`
 }
 
-// calculateBaseline computes statistical baseline from historical data
-func (d *Detector) calculateBaseline(records []normalizer.CostRecord) Baseline {
+// calculateBaseline computes statistical baseline from the BaselineDays
+// preceding the recent window, skipping special calendar days and, when
+// compared on their own, month starts. With weekday seasonality, costs are
+// divided by their weekday's factor first, so weekly cycles do not count
+// as variance.
+func (d *Detector) calculateBaseline(records []normalizer.CostRecord, now time.Time) Baseline {
 	// Get baseline window
-	cutoff := time.Now().AddDate(0, 0, -d.config.BaselineDays)
+	end := now.AddDate(0, 0, -d.config.RecentDays)
+	start := end.AddDate(0, 0, -d.config.BaselineDays)
 	var values []float64
+	var dates []time.Time
 
 	for _, r := range records {
-		if r.Date.Before(cutoff) {
+		if r.Date.Before(start) || !r.Date.Before(end) {
+			continue
+		}
+		if _, ok := d.config.Calendar.Special(r.Date); ok {
+			continue
+		}
+		if d.monthStart(r.Date) {
+			continue
+		}
+		values = append(values, r.Cost)
+		dates = append(dates, r.Date)
+	}
+
+	if !d.config.Seasonality.Weekday {
+		return d.baselineFrom(values)
+	}
+	factors := weekdayFactors(dates, values)
+	if factors == nil {
+		return d.baselineFrom(values)
+	}
+	adjusted := make([]float64, len(values))
+	for i, v := range values {
+		adjusted[i] = v / factors[dates[i].Weekday()]
+	}
+	baseline := d.baselineFrom(adjusted)
+	baseline.WeekdayFactors = factors
+	return baseline
+}
+
+// equivalentBaseline computes a baseline from earlier special days sharing key
+func (d *Detector) equivalentBaseline(records []normalizer.CostRecord, before time.Time, key string) Baseline {
+	var values []float64
+
+	for _, r := range records {
+		if !r.Date.Before(before) {
+			continue
+		}
+		if day, ok := d.config.Calendar.Special(r.Date); ok && day.Key == key {
 			values = append(values, r.Cost)
 		}
 	}
 
+	return d.baselineFrom(values)
+}
+
+// baselineFrom computes summary statistics for a set of daily costs in
+// date order
+func (d *Detector) baselineFrom(values []float64) Baseline {
 	if len(values) == 0 {
 		return Baseline{}
 	}
@@ -150,18 +463,40 @@ func (d *Detector) calculateBaseline(records []normalizer.CostRecord) Baseline {
 	}
 	stdDev := math.Sqrt(sumSqDiff / float64(len(values)))
 
+	med := median(values)
+	deviations := make([]float64, len(values))
+	for i, v := range values {
+		deviations[i] = math.Abs(v - med)
+	}
+
+	ewmaMean, ewmaStdDev := ewma(values, d.config.EWMAAlpha)
+
 	return Baseline{
-		Mean:   mean,
-		StdDev: stdDev,
-		Min:    min,
-		Max:    max,
-		Count:  len(values),
+		Mean:       mean,
+		StdDev:     stdDev,
+		Median:     med,
+		MAD:        median(deviations),
+		Min:        min,
+		Max:        max,
+		Count:      len(values),
+		EWMA:       ewmaMean,
+		EWMAStdDev: ewmaStdDev,
 	}
 }
 
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
 // getRecentRecords returns records from the last N days
-func (d *Detector) getRecentRecords(records []normalizer.CostRecord, days int) []normalizer.CostRecord {
-	cutoff := time.Now().AddDate(0, 0, -days)
+func (d *Detector) getRecentRecords(records []normalizer.CostRecord, days int, now time.Time) []normalizer.CostRecord {
+	cutoff := now.AddDate(0, 0, -days)
 	var recent []normalizer.CostRecord
 
 	for _, r := range records {
@@ -173,30 +508,29 @@ func (d *Detector) getRecentRecords(records []normalizer.CostRecord, days int) [
 	return recent
 }
 
-// checkAnomaly checks if a record is anomalous
-func (d *Detector) checkAnomaly(r normalizer.CostRecord, baseline Baseline, serviceKey string) *Anomaly {
-	if baseline.StdDev == 0 {
+// checkAnomaly checks if a record is anomalous under the configured
+// algorithm and the group's threshold; Deviation holds the algorithm's score
+func (d *Detector) checkAnomaly(g *group, r normalizer.CostRecord, baseline Baseline) *Anomaly {
+	score, expected, ok := d.score(r.Cost, baseline)
+	if !ok {
 		return nil // Can't detect anomaly without variance
 	}
+	threshold := g.threshold
 
-	// Calculate Z-score
-	zScore := (r.Cost - baseline.Mean) / baseline.StdDev
-	threshold := d.thresholds[d.config.Sensitivity]
-
-	if math.Abs(zScore) < threshold {
+	if math.Abs(score) < threshold {
 		return nil // Not anomalous
 	}
 
 	// Calculate percent change
-	percentChange := ((r.Cost - baseline.Mean) / baseline.Mean) * 100
+	percentChange := ((r.Cost - expected) / expected) * 100
 
 	// Determine severity
 	severity := "low"
-	if math.Abs(zScore) >= 4.0 {
+	if math.Abs(score) >= 4.0 {
 		severity = "critical"
-	} else if math.Abs(zScore) >= 3.0 {
+	} else if math.Abs(score) >= 3.0 {
 		severity = "high"
-	} else if math.Abs(zScore) >= 2.0 {
+	} else if math.Abs(score) >= 2.0 {
 		severity = "medium"
 	}
 
@@ -210,13 +544,16 @@ func (d *Detector) checkAnomaly(r normalizer.CostRecord, baseline Baseline, serv
 	_ = direction // suppress unused warning
 
 	return &Anomaly{
+		ID:            ID(r.Cloud, r.Account, r.Service, r.Resource+g.label, r.Date),
 		Date:          r.Date,
 		Service:       r.Service,
 		Account:       r.Account,
 		Cloud:         r.Cloud,
+		Resource:      r.Resource,
+		Group:         g.label,
 		ActualCost:    r.Cost,
-		ExpectedCost:  baseline.Mean,
-		Deviation:     zScore,
+		ExpectedCost:  expected,
+		Deviation:     score,
 		PercentChange: percentChange,
 		Reason:        reason,
 		Severity:      severity,
@@ -237,6 +574,11 @@ func determineReason(r normalizer.CostRecord, baseline Baseline, percentChange f
 	return "Cost deviation from historical baseline"
 }
 
+// groupKey identifies the cloud service a record is baselined with
+func groupKey(r normalizer.CostRecord) string {
+	return r.Cloud + ":" + r.Service
+}
+
 // severityRank returns numeric rank for sorting
 func severityRank(severity string) int {
 	switch severity {
@@ -252,4 +594,3 @@ func severityRank(severity string) int {
 		return 0
 	}
 }
-
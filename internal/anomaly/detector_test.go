@@ -0,0 +1,131 @@
+package anomaly
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lvonguyen/finops-platform/internal/calendar"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+// TestHolidayModes puts today on Black Friday, with two earlier Black
+// Fridays of about 300 in the history
+func TestHolidayModes(t *testing.T) {
+	cal := calendar.New([]calendar.Day{
+		{Date: today, Name: "Black Friday"},
+		{Date: today.AddDate(-1, 0, 0), Name: "Black Friday"},
+		{Date: today.AddDate(-2, 0, 0), Name: "Black Friday"},
+	}, true)
+	records := append(history("EC2", 30),
+		charge("EC2", today.AddDate(-2, 0, 0), 290),
+		charge("EC2", today.AddDate(-1, 0, 0), 310))
+
+	tests := []struct {
+		name     string
+		calendar *calendar.Calendar
+		mode     HolidayMode
+		cost     float64
+		fired    bool
+		decision string
+	}{
+		{"ordinary day", nil, HolidayExclude, 300, true, "fired"},
+		{"excluded", cal, HolidayExclude, 300, false, "special day Black Friday excluded from detection"},
+		{"equivalent, as usual", cal, HolidayEquivalent, 305, false, ""},
+		{"equivalent, above usual", cal, HolidayEquivalent, 900, true, "fired"},
+	}
+	for _, tt := range tests {
+		d := NewDetector(DetectorConfig{Sensitivity: SensitivityMedium, BaselineDays: 30, RecentDays: 1, Calendar: tt.calendar, HolidayMode: tt.mode, Explain: true})
+		spike := charge("EC2", today, tt.cost)
+		anomalies := d.detect(append(records, spike), []normalizer.CostRecord{spike}, today)
+		if got := len(anomalies) == 1; got != tt.fired || len(anomalies) > 1 {
+			t.Errorf("%s: got %d anomalies, want fired %v", tt.name, len(anomalies), tt.fired)
+		}
+		// Explanations cover anomalies, near misses and explicit decisions
+		explanations := d.Explanations()
+		if tt.decision != "" && len(explanations) != 1 {
+			t.Fatalf("%s: got %d explanations, want 1", tt.name, len(explanations))
+		}
+		for _, e := range explanations {
+			if !strings.Contains(e.Decision, tt.decision) {
+				t.Errorf("%s: decision %q, want %q", tt.name, e.Decision, tt.decision)
+			}
+			if tt.mode == HolidayEquivalent && tt.calendar != nil && !strings.HasPrefix(e.Comparison, "equivalent days") {
+				t.Errorf("%s: compared against %s, want equivalent days", tt.name, e.Comparison)
+			}
+		}
+	}
+}
+
+// TestHolidayEquivalentNeedsTwoPriorDays checks a special day with a single
+// earlier equivalent is not scored
+func TestHolidayEquivalentNeedsTwoPriorDays(t *testing.T) {
+	cal := calendar.New([]calendar.Day{
+		{Date: today, Name: "Black Friday"},
+		{Date: today.AddDate(-1, 0, 0), Name: "Black Friday"},
+	}, true)
+	d := NewDetector(DetectorConfig{Sensitivity: SensitivityMedium, BaselineDays: 30, RecentDays: 1, Calendar: cal, HolidayMode: HolidayEquivalent, Explain: true})
+	spike := charge("EC2", today, 900)
+	records := append(history("EC2", 30), charge("EC2", today.AddDate(-1, 0, 0), 300), spike)
+
+	if anomalies := d.detect(records, []normalizer.CostRecord{spike}, today); len(anomalies) != 0 {
+		t.Fatalf("got %+v, want no anomalies", anomalies)
+	}
+	if e := d.Explanations(); len(e) != 1 || !strings.Contains(e[0].Decision, "fewer than 2 prior equivalent days") {
+		t.Errorf("explanations = %+v, want the too few equivalent days decision", e)
+	}
+}
+
+func TestExplanations(t *testing.T) {
+	records := history("EC2", 30)
+	cfg := DetectorConfig{Sensitivity: SensitivityMedium, BaselineDays: 30, RecentDays: 1, Explain: true}
+	b := NewDetector(cfg).calculateBaseline(records, today)
+
+	tests := []struct {
+		name     string
+		cost     float64
+		explain  bool
+		decision string // prefix; empty for no explanation
+	}{
+		{"spike", 300, true, "fired: |score|"},
+		{"near miss", b.Mean + 1.8*b.StdDev, true, "not fired: |score| 1.80 below threshold 2.00 (near miss)"},
+		{"ordinary", b.Mean + 0.5*b.StdDev, true, ""},
+		{"not explaining", 300, false, ""},
+	}
+	for _, tt := range tests {
+		cfg.Explain = tt.explain
+		d := NewDetector(cfg)
+		point := charge("EC2", today, tt.cost)
+		d.detect(append(records, point), []normalizer.CostRecord{point}, today)
+
+		explanations := d.Explanations()
+		if tt.decision == "" {
+			if len(explanations) != 0 {
+				t.Errorf("%s: got %+v, want no explanations", tt.name, explanations)
+			}
+			continue
+		}
+		if len(explanations) != 1 {
+			t.Fatalf("%s: got %d explanations, want 1", tt.name, len(explanations))
+		}
+		e := explanations[0]
+		if !strings.HasPrefix(e.Decision, tt.decision) {
+			t.Errorf("%s: decision %q, want %q", tt.name, e.Decision, tt.decision)
+		}
+		if e.Baseline.Mean != b.Mean || e.Baseline.Count != b.Count || e.Threshold != 2 || e.Comparison != "baseline" || e.Algorithm != AlgorithmZScore {
+			t.Errorf("%s: explanation %+v, want the z-score baseline at threshold 2", tt.name, e)
+		}
+		if !e.BaselineEnd.Equal(today.AddDate(0, 0, -1)) || !e.BaselineStart.Equal(today.AddDate(0, 0, -31)) {
+			t.Errorf("%s: baseline window %s to %s", tt.name, e.BaselineStart, e.BaselineEnd)
+		}
+	}
+}
+
+func TestAnomalyNamesResource(t *testing.T) {
+	d := NewDetector(DetectorConfig{Sensitivity: SensitivityMedium, BaselineDays: 30, RecentDays: 1})
+	spike := charge("EC2", today, 900)
+	spike.Resource = "i-0abc"
+	anomalies := d.detect(append(history("EC2", 30), spike), []normalizer.CostRecord{spike}, today)
+	if len(anomalies) != 1 || anomalies[0].Resource != "i-0abc" {
+		t.Errorf("anomalies = %+v, want one on resource i-0abc", anomalies)
+	}
+}
@@ -0,0 +1,130 @@
+package anomaly
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+// Incremental keeps a rolling window of history per service so that, as new
+// days land in the history store, only the new records are evaluated. It
+// reports the same anomalies batch detection would for those records.
+type Incremental struct {
+	detector *Detector
+	window   map[string]map[string][]normalizer.CostRecord // service -> day -> records
+}
+
+// NewIncremental creates an incremental detector using d's configuration
+func NewIncremental(d *Detector) *Incremental {
+	return &Incremental{
+		detector: d,
+		window:   make(map[string]map[string][]normalizer.CostRecord),
+	}
+}
+
+// Seed loads history without evaluating it
+func (inc *Incremental) Seed(records []normalizer.CostRecord) {
+	inc.land(records)
+}
+
+// Update adds newly landed records and returns the anomalies among them as
+// of now. Records for a service and day already in the window replace them,
+// matching the history store's save semantics, so re-landing a day is safe.
+func (inc *Incremental) Update(records []normalizer.CostRecord, now time.Time) []Anomaly {
+	inc.land(records)
+	inc.evict(now)
+
+	// Baselines only need the services that received new data, unless
+	// scopes total spend across services
+	touched := make(map[string]bool)
+	for _, r := range records {
+		touched[groupKey(r)] = true
+	}
+	all := len(inc.detector.config.Scopes) > 0
+	var history []normalizer.CostRecord
+	for service, days := range inc.window {
+		if !all && !touched[service] {
+			continue
+		}
+		for _, day := range days {
+			history = append(history, day...)
+		}
+	}
+
+	return inc.detector.detect(history, records, now)
+}
+
+// Explanations returns what the last Update computed, when Explain is enabled
+func (inc *Incremental) Explanations() []Explanation {
+	return inc.detector.Explanations()
+}
+
+// Suppressed returns the anomalies the last Update held back for a cooldown
+// or a ramp-up
+func (inc *Incremental) Suppressed() []Anomaly {
+	return inc.detector.Suppressed()
+}
+
+// Ramping returns the accounts the last Update found ramping up
+func (inc *Incremental) Ramping() []RampAccount {
+	return inc.detector.Ramping()
+}
+
+// State encodes the window, so that a later run can Restore it instead of
+// reading the whole baseline from the history store again
+func (inc *Incremental) State() ([]byte, error) {
+	return json.Marshal(inc.window)
+}
+
+// Restore replaces the window with one encoded by State
+func (inc *Incremental) Restore(state []byte) error {
+	window := make(map[string]map[string][]normalizer.CostRecord)
+	if err := json.Unmarshal(state, &window); err != nil {
+		return fmt.Errorf("invalid detection window: %w", err)
+	}
+	inc.window = window
+	return nil
+}
+
+// land replaces the window's records for every service and day present in
+// records; other services keep their records for those days
+func (inc *Incremental) land(records []normalizer.CostRecord) {
+	type key struct{ service, day string }
+	replaced := make(map[key]bool)
+	for _, r := range records {
+		service, day := groupKey(r), r.Date.Format("2006-01-02")
+		if inc.window[service] == nil {
+			inc.window[service] = make(map[string][]normalizer.CostRecord)
+		}
+		if k := (key{service, day}); !replaced[k] {
+			replaced[k] = true
+			delete(inc.window[service], day)
+		}
+		inc.window[service][day] = append(inc.window[service][day], r)
+	}
+}
+
+// evict drops days older than the baseline window. Special days are kept
+// when they may serve as equivalent-day baselines in later years.
+func (inc *Incremental) evict(now time.Time) {
+	cfg := inc.detector.config
+	cutoff := now.AddDate(0, 0, -(cfg.RecentDays + cfg.BaselineDays))
+
+	for service, days := range inc.window {
+		for day, records := range days {
+			date := records[0].Date
+			if !date.Before(cutoff) {
+				continue
+			}
+			if _, special := cfg.Calendar.Special(date); special && cfg.HolidayMode == HolidayEquivalent {
+				continue
+			}
+			delete(days, day)
+		}
+		if len(days) == 0 {
+			delete(inc.window, service)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package anomaly
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+var today = time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+
+func charge(service string, date time.Time, cost float64) normalizer.CostRecord {
+	return normalizer.CostRecord{Cloud: "aws", Account: "111", Service: service, Date: date, Cost: cost, Currency: "USD"}
+}
+
+// history returns days of steady spend on a service up to today
+func history(service string, days int) []normalizer.CostRecord {
+	var records []normalizer.CostRecord
+	for i := days; i > 0; i-- {
+		records = append(records, charge(service, today.AddDate(0, 0, -i), 100+float64(i%3)))
+	}
+	return records
+}
+
+func newTestIncremental() *Incremental {
+	return NewIncremental(NewDetector(DetectorConfig{Sensitivity: SensitivityMedium, BaselineDays: 30, RecentDays: 1}))
+}
+
+func dayCost(inc *Incremental, service string, date time.Time) (float64, int) {
+	var cost float64
+	records := inc.window["aws:"+service][date.Format("2006-01-02")]
+	for _, r := range records {
+		cost += r.Cost
+	}
+	return cost, len(records)
+}
+
+func TestIncrementalLandReplacesServiceDay(t *testing.T) {
+	inc := newTestIncremental()
+	day := today.AddDate(0, 0, -1)
+	inc.Seed([]normalizer.CostRecord{charge("EC2", day, 100), charge("S3", day, 40)})
+
+	// A restatement of one service's day leaves the others' records alone
+	inc.Seed([]normalizer.CostRecord{charge("EC2", day, 90), charge("EC2", day, 5)})
+	if cost, n := dayCost(inc, "EC2", day); cost != 95 || n != 2 {
+		t.Errorf("EC2 = %v in %d records, want 95 in 2", cost, n)
+	}
+	if cost, n := dayCost(inc, "S3", day); cost != 40 || n != 1 {
+		t.Errorf("S3 = %v in %d records, want 40 in 1", cost, n)
+	}
+}
+
+func TestIncrementalRestoredWindow(t *testing.T) {
+	spike := []normalizer.CostRecord{charge("EC2", today, 500), charge("S3", today, 101)}
+
+	full := newTestIncremental()
+	full.Seed(append(history("EC2", 30), history("S3", 30)...))
+	want := full.Update(spike, today)
+	if len(want) != 1 || want[0].Service != "EC2" {
+		t.Fatalf("anomalies = %+v, want the EC2 spike", want)
+	}
+
+	// A window carried over from the previous run detects the same
+	prev := newTestIncremental()
+	prev.Seed(append(history("EC2", 30), history("S3", 30)...))
+	state, err := prev.State()
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := newTestIncremental()
+	if err := next.Restore(state); err != nil {
+		t.Fatal(err)
+	}
+	got := next.Update(spike, today)
+	if len(got) != len(want) || got[0].ID != want[0].ID || got[0].ExpectedCost != want[0].ExpectedCost {
+		t.Errorf("restored anomalies = %+v, want %+v", got, want)
+	}
+
+	if err := next.Restore([]byte("{")); err == nil {
+		t.Error("Restore accepted an invalid state")
+	}
+}
+
+func TestIncrementalMatchesBatch(t *testing.T) {
+	// Detect runs as of the wall clock, so the days are relative to it; the
+	// history ends before the recent window and stays within the baseline
+	// window, so eviction drops nothing batch detection would use
+	now := time.Now()
+	day := now.UTC().Truncate(24 * time.Hour)
+	cfg := DetectorConfig{Sensitivity: SensitivityMedium, BaselineDays: 30, RecentDays: 3}
+
+	var seed []normalizer.CostRecord
+	for _, service := range []string{"EC2", "S3", "RDS"} {
+		for i := 32; i >= 3; i-- {
+			seed = append(seed, charge(service, day.AddDate(0, 0, -i), 100+float64(i%3)))
+		}
+	}
+	// EC2 spikes on its last two new days, S3 gets ordinary days and RDS
+	// gets nothing new
+	update := []normalizer.CostRecord{
+		charge("EC2", day.AddDate(0, 0, -2), 101),
+		charge("EC2", day.AddDate(0, 0, -1), 400),
+		charge("EC2", day, 600),
+		charge("S3", day.AddDate(0, 0, -1), 102),
+		charge("S3", day, 60),
+	}
+
+	inc := NewIncremental(NewDetector(cfg))
+	inc.Seed(seed)
+	got := inc.Update(update, now)
+	want := NewDetector(cfg).Detect(append(append([]normalizer.CostRecord(nil), seed...), update...))
+	if len(want) == 0 {
+		t.Fatal("batch detection found no anomalies")
+	}
+
+	byID := func(anomalies []Anomaly) {
+		sort.Slice(anomalies, func(i, j int) bool { return anomalies[i].ID < anomalies[j].ID })
+	}
+	byID(got)
+	byID(want)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("incremental anomalies = %+v, want batch %+v", got, want)
+	}
+}
@@ -0,0 +1,144 @@
+package anomaly
+
+import (
+	"math"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+// Anomaly kinds assigned by multi-metric scoring from which signals moved
+const (
+	KindRateChange       = "rate_change"       // cost moved while usage and resources stayed flat
+	KindScaling          = "scaling"           // usage moved with cost on the same resources
+	KindNewResources     = "new_resources"     // more billed resources than usual
+	KindRemovedResources = "removed_resources" // fewer billed resources than usual
+)
+
+// signalTolerance is the relative change in a day's usage or record count,
+// against its baseline mean, below which the signal counts as flat
+const signalTolerance = 0.2
+
+// Signals are the day's cost, usage and record count against their daily
+// baselines, as percent changes
+type Signals struct {
+	Cost      float64 `json:"cost_change"`
+	Usage     float64 `json:"usage_change"`
+	Records   float64 `json:"record_change"`
+	UsageUnit string  `json:"usage_unit,omitempty"` // unit compared; empty when the records carry no usage
+}
+
+// daily is one day's totals for a service
+type daily struct {
+	cost, usage float64
+	records     int
+}
+
+// classify scores the anomaly's day in its account, or its whole scope
+// group, across cost, usage and record count, returning the likely kind of
+// change and the signals behind it. Usage is compared in the unit carrying the most cost, since units
+// cannot be summed. The kind is empty when no signal explains the change.
+func (d *Detector) classify(a Anomaly, service []normalizer.CostRecord, now time.Time) (string, *Signals) {
+	var records []normalizer.CostRecord
+	for _, r := range service {
+		if a.Group != "" || r.Account == a.Account {
+			records = append(records, r)
+		}
+	}
+
+	unitCost := make(map[string]float64)
+	for _, r := range records {
+		if r.UsageQuantity != 0 && r.UsageUnit != "" {
+			unitCost[r.UsageUnit] += math.Abs(r.Cost)
+		}
+	}
+	var unit string
+	for u, c := range unitCost {
+		if c > unitCost[unit] || (c == unitCost[unit] && u < unit) {
+			unit = u
+		}
+	}
+
+	days := make(map[string]*daily)
+	for _, r := range records {
+		key := r.Date.Format("2006-01-02")
+		if days[key] == nil {
+			days[key] = &daily{}
+		}
+		days[key].cost += r.Cost
+		days[key].records++
+		if unit != "" && r.UsageUnit == unit {
+			days[key].usage += r.UsageQuantity
+		}
+	}
+	current := days[a.Date.Format("2006-01-02")]
+	if current == nil {
+		return "", nil
+	}
+
+	// Daily means over the same window the cost baseline uses
+	end := now.AddDate(0, 0, -d.config.RecentDays)
+	start := end.AddDate(0, 0, -d.config.BaselineDays)
+	var base daily
+	var n int
+	for key, day := range days {
+		date, _ := time.Parse("2006-01-02", key)
+		if date.Before(start) || !date.Before(end) {
+			continue
+		}
+		if _, ok := d.config.Calendar.Special(date); ok {
+			continue
+		}
+		base.cost += day.cost
+		base.usage += day.usage
+		base.records += day.records
+		n++
+	}
+	if n == 0 {
+		return "", nil
+	}
+
+	s := &Signals{
+		Cost:      change(current.cost, base.cost/float64(n)),
+		Usage:     change(current.usage, base.usage/float64(n)),
+		Records:   change(float64(current.records), float64(base.records)/float64(n)),
+		UsageUnit: unit,
+	}
+
+	moved := func(pct float64) bool { return math.Abs(pct) >= signalTolerance*100 }
+	switch {
+	case moved(s.Records) && s.Records > 0:
+		return KindNewResources, s
+	case moved(s.Records):
+		return KindRemovedResources, s
+	case unit == "":
+		return "", s // Without usage, scaling and rate changes look alike
+	case moved(s.Usage) && (s.Usage > 0) == (s.Cost > 0):
+		return KindScaling, s
+	default:
+		return KindRateChange, s
+	}
+}
+
+// change returns the percent change from base to v, 0 when base is 0
+func change(v, base float64) float64 {
+	if base == 0 {
+		return 0
+	}
+	return (v - base) / math.Abs(base) * 100
+}
+
+// describeKind explains an anomaly kind for its reason
+func describeKind(kind string) string {
+	switch kind {
+	case KindRateChange:
+		return "Rate change - cost moved with flat usage (pricing, discount or SKU change)"
+	case KindScaling:
+		return "Scaling - usage moved in proportion to cost"
+	case KindNewResources:
+		return "New resources - more billed resources than usual"
+	case KindRemovedResources:
+		return "Removed resources - fewer billed resources than usual"
+	}
+	return ""
+}
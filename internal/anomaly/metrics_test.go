@@ -0,0 +1,81 @@
+package anomaly
+
+import (
+	"testing"
+
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+// metered returns an EC2 charge with usage in hours
+func metered(days int, cost, hours float64) normalizer.CostRecord {
+	r := charge("EC2", today.AddDate(0, 0, -days), cost)
+	r.UsageQuantity, r.UsageUnit = hours, "Hrs"
+	return r
+}
+
+func TestMultiMetricKinds(t *testing.T) {
+	// steady is 30 days of perDay charges costing about 100 for 10 hours
+	steady := func(perDay int) []normalizer.CostRecord {
+		var records []normalizer.CostRecord
+		for i := 30; i > 0; i-- {
+			for j := 0; j < perDay; j++ {
+				records = append(records, metered(i, 100+float64(i%3), 10))
+			}
+		}
+		return records
+	}
+	unmetered := history("EC2", 30)
+
+	tests := []struct {
+		name    string
+		history []normalizer.CostRecord
+		today   []normalizer.CostRecord // the first is the spike
+		kind    string
+	}{
+		{"rate change", steady(1), []normalizer.CostRecord{metered(0, 300, 10)}, KindRateChange},
+		{"scaling", steady(1), []normalizer.CostRecord{metered(0, 300, 31)}, KindScaling},
+		{"new resources", steady(1), []normalizer.CostRecord{metered(0, 300, 10), metered(0, 1, 1), metered(0, 1, 1)}, KindNewResources},
+		{"removed resources", steady(2), []normalizer.CostRecord{metered(0, 300, 10)}, KindRemovedResources},
+		{"no usage", unmetered, []normalizer.CostRecord{charge("EC2", today, 300)}, ""},
+	}
+	for _, tt := range tests {
+		d := NewDetector(DetectorConfig{Sensitivity: SensitivityMedium, BaselineDays: 30, RecentDays: 1, MultiMetric: true})
+		anomalies := d.detect(append(tt.history, tt.today...), tt.today[:1], today)
+		if len(anomalies) != 1 {
+			t.Fatalf("%s: got %d anomalies, want 1", tt.name, len(anomalies))
+		}
+		a := anomalies[0]
+		if a.Kind != tt.kind {
+			t.Errorf("%s: kind %q, want %q (signals %+v)", tt.name, a.Kind, tt.kind, a.Signals)
+		}
+		if tt.kind != "" && a.Reason != describeKind(tt.kind) {
+			t.Errorf("%s: reason %q, want %q", tt.name, a.Reason, describeKind(tt.kind))
+		}
+		if a.Signals == nil || a.Signals.Cost <= 0 {
+			t.Errorf("%s: signals %+v, want a cost increase", tt.name, a.Signals)
+		}
+	}
+
+	d := NewDetector(DetectorConfig{Sensitivity: SensitivityMedium, BaselineDays: 30, RecentDays: 1})
+	spike := metered(0, 300, 10)
+	if anomalies := d.detect(append(steady(1), spike), []normalizer.CostRecord{spike}, today); len(anomalies) != 1 || anomalies[0].Kind != "" || anomalies[0].Signals != nil {
+		t.Errorf("without MultiMetric got %+v, want an unclassified anomaly", anomalies)
+	}
+}
+
+func TestSignals(t *testing.T) {
+	var records []normalizer.CostRecord
+	for i := 30; i > 0; i-- {
+		records = append(records, metered(i, 100, 10))
+	}
+	spike := metered(0, 250, 25)
+	d := NewDetector(DetectorConfig{BaselineDays: 30, RecentDays: 1})
+	kind, s := d.classify(Anomaly{Account: "111", Date: today}, append(records, spike), today)
+	if kind != KindScaling {
+		t.Errorf("kind = %q, want %q", kind, KindScaling)
+	}
+	want := Signals{Cost: 150, Usage: 150, Records: 0, UsageUnit: "Hrs"}
+	if s == nil || *s != want {
+		t.Errorf("signals = %+v, want %+v", s, want)
+	}
+}
@@ -0,0 +1,143 @@
+package anomaly
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+// Ramp actions for anomalies in accounts that are still ramping up
+const (
+	RampSoften   = "soften"   // report them at low severity
+	RampSuppress = "suppress" // hold them back, like a cooldown
+)
+
+const (
+	// rampStart is the largest first-day spend, as a fraction of the peak,
+	// that still counts as starting from near zero
+	rampStart = 0.1
+	// rampDip is how far daily spend may fall below its running peak
+	// (weekends, batch jobs) while still counting as growth
+	rampDip = 0.2
+)
+
+// RampAccount is a newly onboarded account whose spend is still growing
+// from near zero
+type RampAccount struct {
+	Cloud      string    `json:"cloud"`
+	Account    string    `json:"account"`
+	FirstSeen  time.Time `json:"first_seen"`  // first day with spend
+	Days       int       `json:"days"`        // days with spend so far
+	FirstCost  float64   `json:"first_cost"`  // spend on the first day
+	LatestCost float64   `json:"latest_cost"` // spend on the latest day
+	GraceEnds  time.Time `json:"grace_ends"`
+}
+
+// Scope returns the account as cloud/account
+func (r RampAccount) Scope() string {
+	return r.Cloud + "/" + r.Account
+}
+
+// ValidateRampAction checks a configured ramp action; empty means soften
+func ValidateRampAction(action string) error {
+	switch action {
+	case "", RampSoften, RampSuppress:
+		return nil
+	default:
+		return fmt.Errorf("unknown ramp action %q (want soften or suppress)", action)
+	}
+}
+
+// RampingAccounts returns the accounts in their onboarding ramp as of now:
+// first spend within grace of now, starting near zero, and growing without
+// falling far below their peak. Newest accounts come first.
+func RampingAccounts(records []normalizer.CostRecord, now time.Time, grace time.Duration) []RampAccount {
+	if grace <= 0 {
+		return nil
+	}
+
+	type account struct{ cloud, id string }
+	daily := make(map[account]map[string]float64)
+	for _, r := range records {
+		key := account{r.Cloud, r.Account}
+		if daily[key] == nil {
+			daily[key] = make(map[string]float64)
+		}
+		daily[key][r.Date.Format("2006-01-02")] += r.Cost
+	}
+
+	var ramping []RampAccount
+	for key, costs := range daily {
+		days := make([]string, 0, len(costs))
+		for day, cost := range costs {
+			if cost > 0 {
+				days = append(days, day)
+			}
+		}
+		if len(days) < 2 {
+			continue // Too little history to tell a ramp from a steady start
+		}
+		sort.Strings(days)
+
+		first, _ := time.Parse("2006-01-02", days[0])
+		if !now.Before(first.Add(grace)) {
+			continue
+		}
+
+		var peak float64
+		growing := true
+		for _, day := range days {
+			cost := costs[day]
+			if cost < peak*(1-rampDip) {
+				growing = false
+				break
+			}
+			if cost > peak {
+				peak = cost
+			}
+		}
+		if !growing || costs[days[0]] > peak*rampStart {
+			continue
+		}
+
+		ramping = append(ramping, RampAccount{
+			Cloud:      key.cloud,
+			Account:    key.id,
+			FirstSeen:  first,
+			Days:       len(days),
+			FirstCost:  costs[days[0]],
+			LatestCost: costs[days[len(days)-1]],
+			GraceEnds:  first.Add(grace),
+		})
+	}
+
+	sort.Slice(ramping, func(i, j int) bool {
+		if !ramping[i].FirstSeen.Equal(ramping[j].FirstSeen) {
+			return ramping[i].FirstSeen.After(ramping[j].FirstSeen)
+		}
+		return ramping[i].Scope() < ramping[j].Scope()
+	})
+	return ramping
+}
+
+// Ramping returns the accounts the last Detect call found ramping up
+func (d *Detector) Ramping() []RampAccount {
+	return d.ramping
+}
+
+// rampFor returns the ramp the anomaly's account is in
+func (d *Detector) rampFor(a Anomaly) (RampAccount, bool) {
+	for _, r := range d.ramping {
+		if r.Cloud == a.Cloud && r.Account == a.Account {
+			return r, true
+		}
+	}
+	return RampAccount{}, false
+}
+
+func describeRamp(r RampAccount) string {
+	return fmt.Sprintf("%s ramping up since %s (grace until %s)",
+		r.Scope(), r.FirstSeen.Format("2006-01-02"), r.GraceEnds.Format("2006-01-02"))
+}
@@ -0,0 +1,100 @@
+package anomaly
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+// spend returns one record per cost on consecutive days ending today
+func spend(account string, costs ...float64) []normalizer.CostRecord {
+	records := make([]normalizer.CostRecord, len(costs))
+	for i, cost := range costs {
+		records[i] = charge("EC2", today.AddDate(0, 0, i-len(costs)+1), cost)
+		records[i].Account = account
+	}
+	return records
+}
+
+func TestRampingAccounts(t *testing.T) {
+	grace := 14 * 24 * time.Hour
+	tests := []struct {
+		name    string
+		records []normalizer.CostRecord
+		ramping bool
+	}{
+		{"growing from near zero", spend("222", 1, 4, 3.5, 20, 50), true},
+		{"zero days before first spend", spend("222", 0, 0, 2, 30), true},
+		{"starts high", spend("222", 40, 45, 50), false},
+		{"falls below its peak", spend("222", 1, 20, 10, 25), false},
+		{"single day", spend("222", 0, 5), false},
+		{"older than grace", spend("222", append(append([]float64{0.5}, make([]float64, 14)...), 30)...), false},
+		{"within grace", spend("222", append(append([]float64{0.5}, make([]float64, 12)...), 30)...), true},
+	}
+	for _, tt := range tests {
+		got := RampingAccounts(tt.records, today, grace)
+		if (len(got) == 1) != tt.ramping {
+			t.Errorf("%s: RampingAccounts() = %+v, want ramping %v", tt.name, got, tt.ramping)
+		}
+	}
+
+	got := RampingAccounts(spend("222", 1, 4, 20), today, grace)
+	want := RampAccount{
+		Cloud: "aws", Account: "222", FirstSeen: today.AddDate(0, 0, -2), Days: 3,
+		FirstCost: 1, LatestCost: 20, GraceEnds: today.AddDate(0, 0, 12),
+	}
+	if len(got) != 1 || got[0] != want {
+		t.Errorf("RampingAccounts() = %+v, want %+v", got, want)
+	}
+	if got := RampingAccounts(spend("222", 1, 4, 20), today, 0); got != nil {
+		t.Errorf("RampingAccounts() without grace = %+v, want nil", got)
+	}
+}
+
+func TestRampActions(t *testing.T) {
+	spike := spend("222", 1, 5, 20, 500)
+	records := append(history("EC2", 30), spike...)
+
+	tests := []struct {
+		action     string
+		fired      int
+		suppressed int
+	}{
+		{RampSoften, 1, 0},
+		{RampSuppress, 0, 1},
+	}
+	for _, tt := range tests {
+		d := NewDetector(DetectorConfig{
+			Sensitivity: SensitivityMedium, BaselineDays: 30, RecentDays: 1,
+			RampGrace: 14 * 24 * time.Hour, RampAction: tt.action,
+		})
+		anomalies := d.detect(records, spike[len(spike)-1:], today)
+
+		if len(anomalies) != tt.fired || len(d.Suppressed()) != tt.suppressed {
+			t.Fatalf("%s: fired %d, suppressed %d; want %d and %d", tt.action, len(anomalies), len(d.Suppressed()), tt.fired, tt.suppressed)
+		}
+		if ramping := d.Ramping(); len(ramping) != 1 || ramping[0].Account != "222" {
+			t.Errorf("%s: Ramping() = %+v, want account 222", tt.action, ramping)
+		}
+		a := append(anomalies, d.Suppressed()...)[0]
+		if !strings.HasPrefix(a.Ramp, "aws/222 ramping up since 2024-03-28") {
+			t.Errorf("%s: Ramp = %q", tt.action, a.Ramp)
+		}
+		if tt.action == RampSoften && (a.Severity != "low" || !strings.HasPrefix(a.Reason, "New account ramping up - ")) {
+			t.Errorf("%s: severity %s, reason %q; want a low-severity ramp", tt.action, a.Severity, a.Reason)
+		}
+	}
+}
+
+func TestValidateRampAction(t *testing.T) {
+	for _, action := range []string{"", RampSoften, RampSuppress} {
+		if err := ValidateRampAction(action); err != nil {
+			t.Errorf("ValidateRampAction(%q) = %v", action, err)
+		}
+	}
+	if err := ValidateRampAction("ignore"); err == nil {
+		t.Error("ramp action ignore accepted")
+	}
+}
@@ -0,0 +1,180 @@
+package anomaly
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+// Dimensions a scope can group by, besides tag:<key>
+const (
+	DimCloud   = "cloud"
+	DimAccount = "account"
+	DimService = "service"
+	DimRegion  = "region"
+)
+
+const tagPrefix = "tag:"
+
+// Scope baselines the total daily spend of each group of records sharing
+// its GroupBy values, such as a cost center, so a team's spike is caught even
+// when no single service stands out. Records without a grouped tag are left
+// out.
+type Scope struct {
+	Name        string
+	GroupBy     []string               // cloud, account, service, region or tag:<key>
+	Sensitivity Sensitivity            // empty uses the detector's
+	Overrides   map[string]Sensitivity // by group value, the GroupBy values joined with "/"
+}
+
+// ScopesFrom builds the anomaly.scopes configuration
+func ScopesFrom(cfgs []config.AnomalyScope) ([]Scope, error) {
+	scopes := make([]Scope, 0, len(cfgs))
+	names := make(map[string]bool)
+	for _, c := range cfgs {
+		if c.Name == "" {
+			return nil, fmt.Errorf("anomaly scope has no name")
+		}
+		if names[c.Name] {
+			return nil, fmt.Errorf("anomaly scope %q is defined twice", c.Name)
+		}
+		names[c.Name] = true
+		if len(c.GroupBy) == 0 {
+			return nil, fmt.Errorf("anomaly scope %q has no group_by", c.Name)
+		}
+		for _, dim := range c.GroupBy {
+			switch {
+			case dim == DimCloud, dim == DimAccount, dim == DimService, dim == DimRegion:
+			case strings.HasPrefix(dim, tagPrefix) && len(dim) > len(tagPrefix):
+			default:
+				return nil, fmt.Errorf("anomaly scope %q: unknown dimension %q (want cloud, account, service, region or tag:<key>)", c.Name, dim)
+			}
+		}
+
+		s := Scope{Name: c.Name, GroupBy: c.GroupBy, Sensitivity: Sensitivity(c.Sensitivity)}
+		if err := validateSensitivity(s.Sensitivity); err != nil {
+			return nil, fmt.Errorf("anomaly scope %q: %w", c.Name, err)
+		}
+		if len(c.Overrides) > 0 {
+			s.Overrides = make(map[string]Sensitivity, len(c.Overrides))
+			for value, sens := range c.Overrides {
+				if err := validateSensitivity(Sensitivity(sens)); err != nil || sens == "" {
+					return nil, fmt.Errorf("anomaly scope %q: override for %q: invalid sensitivity %q", c.Name, value, sens)
+				}
+				s.Overrides[value] = Sensitivity(sens)
+			}
+		}
+		scopes = append(scopes, s)
+	}
+	return scopes, nil
+}
+
+func validateSensitivity(s Sensitivity) error {
+	switch s {
+	case "", SensitivityLow, SensitivityMedium, SensitivityHigh:
+		return nil
+	default:
+		return fmt.Errorf("unknown sensitivity %q (want low, medium or high)", s)
+	}
+}
+
+// value returns the record's group value, false when it lacks a grouped tag
+func (s Scope) value(r normalizer.CostRecord) (string, bool) {
+	parts := make([]string, len(s.GroupBy))
+	for i, dim := range s.GroupBy {
+		switch dim {
+		case DimCloud:
+			parts[i] = r.Cloud
+		case DimAccount:
+			parts[i] = r.Account
+		case DimService:
+			parts[i] = r.Service
+		case DimRegion:
+			parts[i] = r.Region
+		default:
+			v, ok := r.Tags[strings.TrimPrefix(dim, tagPrefix)]
+			if !ok || v == "" {
+				return "", false
+			}
+			parts[i] = v
+		}
+	}
+	return strings.Join(parts, "/"), true
+}
+
+// sensitivity returns the sensitivity of a group, falling back to def
+func (s Scope) sensitivity(value string, def Sensitivity) Sensitivity {
+	if sens, ok := s.Overrides[value]; ok {
+		return sens
+	}
+	if s.Sensitivity != "" {
+		return s.Sensitivity
+	}
+	return def
+}
+
+// groups totals records per group value and day. Each total carries the
+// grouped cloud, account, service and region, leaving the others empty, and
+// the group's line items are kept for multi-metric scoring. Only the days
+// of candidates are evaluated.
+func (s Scope) groups(records, candidates []normalizer.CostRecord) map[string]*group {
+	type dayKey struct{ value, day string }
+	totals := make(map[dayKey]*normalizer.CostRecord)
+	groups := make(map[string]*group)
+	for _, r := range records {
+		value, ok := s.value(r)
+		if !ok {
+			continue
+		}
+		g := groups[value]
+		if g == nil {
+			g = &group{label: s.Name + ": " + value}
+			groups[value] = g
+		}
+		g.raw = append(g.raw, r)
+
+		k := dayKey{value, r.Date.Format("2006-01-02")}
+		t := totals[k]
+		if t == nil {
+			t = &normalizer.CostRecord{Date: r.Date, Currency: r.Currency}
+			for _, dim := range s.GroupBy {
+				switch dim {
+				case DimCloud:
+					t.Cloud = r.Cloud
+				case DimAccount:
+					t.Account = r.Account
+				case DimService:
+					t.Service = r.Service
+				case DimRegion:
+					t.Region = r.Region
+				}
+			}
+			totals[k] = t
+		}
+		t.Cost += r.Cost
+	}
+
+	evaluated := make(map[dayKey]bool)
+	for _, r := range candidates {
+		if value, ok := s.value(r); ok {
+			evaluated[dayKey{value, r.Date.Format("2006-01-02")}] = true
+		}
+	}
+
+	keys := make([]dayKey, 0, len(totals))
+	for k := range totals {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].day < keys[j].day })
+	for _, k := range keys {
+		g := groups[k.value]
+		g.records = append(g.records, *totals[k])
+		if evaluated[k] {
+			g.candidates = append(g.candidates, *totals[k])
+		}
+	}
+	return groups
+}
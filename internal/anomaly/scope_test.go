@@ -0,0 +1,131 @@
+package anomaly
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+// teamCharge returns a cost center's charge for a service
+func teamCharge(costCenter, service string, days int, cost float64) normalizer.CostRecord {
+	r := charge(service, today.AddDate(0, 0, -days), cost)
+	r.Region = "us-east-1"
+	r.Tags = map[string]string{"cost_center": costCenter}
+	return r
+}
+
+func TestScopesFrom(t *testing.T) {
+	scopes, err := ScopesFrom([]config.AnomalyScope{
+		{Name: "cost center", GroupBy: []string{"tag:cost_center"}, Sensitivity: "high", Overrides: map[string]string{"CC-9": "low"}},
+		{Name: "account region", GroupBy: []string{DimAccount, DimRegion}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Scope{
+		{Name: "cost center", GroupBy: []string{"tag:cost_center"}, Sensitivity: SensitivityHigh, Overrides: map[string]Sensitivity{"CC-9": SensitivityLow}},
+		{Name: "account region", GroupBy: []string{DimAccount, DimRegion}},
+	}
+	if !reflect.DeepEqual(scopes, want) {
+		t.Errorf("ScopesFrom() = %+v, want %+v", scopes, want)
+	}
+
+	for name, cfgs := range map[string][]config.AnomalyScope{
+		"no name":           {{GroupBy: []string{DimAccount}}},
+		"defined twice":     {{Name: "a", GroupBy: []string{DimAccount}}, {Name: "a", GroupBy: []string{DimRegion}}},
+		"no group_by":       {{Name: "a"}},
+		"unknown dimension": {{Name: "a", GroupBy: []string{"zone"}}},
+		"empty tag":         {{Name: "a", GroupBy: []string{"tag:"}}},
+		"bad sensitivity":   {{Name: "a", GroupBy: []string{DimAccount}, Sensitivity: "extreme"}},
+		"empty override":    {{Name: "a", GroupBy: []string{DimAccount}, Overrides: map[string]string{"111": ""}}},
+	} {
+		if _, err := ScopesFrom(cfgs); err == nil {
+			t.Errorf("%s: ScopesFrom() succeeded, want an error", name)
+		}
+	}
+}
+
+func TestScopeSensitivity(t *testing.T) {
+	s := Scope{Sensitivity: SensitivityHigh, Overrides: map[string]Sensitivity{"CC-9": SensitivityLow}}
+	if got := s.sensitivity("CC-9", SensitivityMedium); got != SensitivityLow {
+		t.Errorf("override = %s, want low", got)
+	}
+	if got := s.sensitivity("CC-1", SensitivityMedium); got != SensitivityHigh {
+		t.Errorf("scope = %s, want high", got)
+	}
+	if got := (Scope{}).sensitivity("CC-1", SensitivityMedium); got != SensitivityMedium {
+		t.Errorf("default = %s, want medium", got)
+	}
+}
+
+func TestScopeGroups(t *testing.T) {
+	s := Scope{Name: "team", GroupBy: []string{DimAccount, "tag:cost_center"}}
+	records := []normalizer.CostRecord{
+		teamCharge("CC-1", "EC2", 1, 10),
+		teamCharge("CC-1", "S3", 1, 5),
+		teamCharge("CC-1", "EC2", 0, 12),
+		teamCharge("CC-2", "EC2", 0, 7),
+		charge("EC2", today, 100), // untagged, left out
+	}
+
+	groups := s.groups(records, records[2:])
+	if len(groups) != 2 {
+		t.Fatalf("got groups %v, want 111/CC-1 and 111/CC-2", groups)
+	}
+	g := groups["111/CC-1"]
+	want := []normalizer.CostRecord{
+		{Account: "111", Date: today.AddDate(0, 0, -1), Cost: 15, Currency: "USD"},
+		{Account: "111", Date: today, Cost: 12, Currency: "USD"},
+	}
+	if g.label != "team: 111/CC-1" || !reflect.DeepEqual(g.records, want) {
+		t.Errorf("CC-1 group %s = %+v, want daily totals %+v", g.label, g.records, want)
+	}
+	if !reflect.DeepEqual(g.candidates, want[1:]) || len(g.raw) != 3 {
+		t.Errorf("CC-1 candidates = %+v with %d line items, want today's total of 3", g.candidates, len(g.raw))
+	}
+}
+
+// TestScopeDetectsTeamSpike raises each of CC-1's services a little, which
+// is lost in the spread of CC-2's larger charges for the same services but
+// puts CC-1's total 2.5 deviations above its mean of 36
+func TestScopeDetectsTeamSpike(t *testing.T) {
+	services := []string{"EC2", "S3", "RDS"}
+	var records, candidates []normalizer.CostRecord
+	for days := 30; days >= 0; days-- {
+		for i, service := range services {
+			small := 10 + float64((days*(i+1))%5)
+			if days == 0 {
+				small = 14.6
+			}
+			r := teamCharge("CC-1", service, days, small)
+			other := teamCharge("CC-2", service, days, 100+float64(days%5))
+			records = append(records, r, other)
+			if days == 0 {
+				candidates = append(candidates, r, other)
+			}
+		}
+	}
+
+	scope := Scope{Name: "cost center", GroupBy: []string{"tag:cost_center"}}
+	tests := []struct {
+		name   string
+		scopes []Scope
+		groups []string
+	}{
+		{"services only", nil, nil},
+		{"cost center scope", []Scope{scope}, []string{"cost center: CC-1"}},
+		{"overridden to low", []Scope{{Name: scope.Name, GroupBy: scope.GroupBy, Overrides: map[string]Sensitivity{"CC-1": SensitivityLow}}}, nil},
+	}
+	for _, tt := range tests {
+		d := NewDetector(DetectorConfig{Sensitivity: SensitivityMedium, BaselineDays: 30, RecentDays: 1, Scopes: tt.scopes})
+		var groups []string
+		for _, a := range d.detect(records, candidates, today) {
+			groups = append(groups, a.Group)
+		}
+		if !reflect.DeepEqual(groups, tt.groups) {
+			t.Errorf("%s: anomalies in %q, want %q", tt.name, groups, tt.groups)
+		}
+	}
+}
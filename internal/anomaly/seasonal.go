@@ -0,0 +1,103 @@
+package anomaly
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+// Seasonal patterns baselines can account for
+const (
+	SeasonWeekday    = "weekday"     // scale the baseline by each weekday's typical share
+	SeasonMonthStart = "month_start" // compare the first of each month only with earlier month starts
+)
+
+// minWeekdaySamples is how many baseline values each weekday needs before
+// weekday factors are used
+const minWeekdaySamples = 2
+
+// Seasonality selects the seasonal patterns the detector models
+type Seasonality struct {
+	Weekday    bool
+	MonthStart bool
+}
+
+// ParseSeasonality reads the anomaly.seasonality list
+func ParseSeasonality(names []string) (Seasonality, error) {
+	var s Seasonality
+	for _, name := range names {
+		switch name {
+		case SeasonWeekday:
+			s.Weekday = true
+		case SeasonMonthStart:
+			s.MonthStart = true
+		default:
+			return Seasonality{}, fmt.Errorf("unknown seasonality %q (want weekday or month_start)", name)
+		}
+	}
+	return s, nil
+}
+
+// weekdayFactors returns each weekday's mean cost relative to the overall
+// mean, indexed by time.Weekday. It returns nil when a weekday has too few
+// values or none of its days had spend, as its factor would not be reliable.
+func weekdayFactors(dates []time.Time, values []float64) []float64 {
+	var sums [7]float64
+	var counts [7]int
+	var total float64
+	for i, v := range values {
+		wd := dates[i].Weekday()
+		sums[wd] += v
+		counts[wd]++
+		total += v
+	}
+	if total <= 0 {
+		return nil
+	}
+	mean := total / float64(len(values))
+
+	factors := make([]float64, 7)
+	for wd := range factors {
+		if counts[wd] < minWeekdaySamples || sums[wd] <= 0 {
+			return nil
+		}
+		factors[wd] = sums[wd] / float64(counts[wd]) / mean
+	}
+	return factors
+}
+
+// forDay scales a weekday-adjusted baseline to the typical level of day's
+// weekday; baselines without weekday factors are returned unchanged
+func (b Baseline) forDay(day time.Time) Baseline {
+	if len(b.WeekdayFactors) != 7 {
+		return b
+	}
+	f := b.WeekdayFactors[day.Weekday()]
+	b.Mean *= f
+	b.StdDev *= f
+	b.Median *= f
+	b.MAD *= f
+	b.Min *= f
+	b.Max *= f
+	b.EWMA *= f
+	b.EWMAStdDev *= f
+	return b
+}
+
+// monthStart reports whether t is a month start compared on its own
+func (d *Detector) monthStart(t time.Time) bool {
+	return d.config.Seasonality.MonthStart && t.Day() == 1
+}
+
+// monthStartBaseline computes a baseline from earlier month starts, where
+// monthly charges such as support fees and reservations land
+func (d *Detector) monthStartBaseline(records []normalizer.CostRecord, before time.Time) Baseline {
+	var values []float64
+	for _, r := range records {
+		if r.Date.Before(before) && r.Date.Day() == 1 {
+			values = append(values, r.Cost)
+		}
+	}
+	return d.baselineFrom(values)
+}
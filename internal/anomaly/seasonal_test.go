@@ -0,0 +1,149 @@
+package anomaly
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+// weekly returns days of EC2 spend up to now: about 100 on weekdays and 20
+// at weekends
+func weekly(now time.Time, days int) []normalizer.CostRecord {
+	var records []normalizer.CostRecord
+	for i := days; i > 0; i-- {
+		date := now.AddDate(0, 0, -i)
+		cost := 100 + float64(i%3)
+		if wd := date.Weekday(); wd == time.Saturday || wd == time.Sunday {
+			cost = 20 + float64(i%2)
+		}
+		records = append(records, charge("EC2", date, cost))
+	}
+	return records
+}
+
+func TestParseSeasonality(t *testing.T) {
+	s, err := ParseSeasonality([]string{SeasonWeekday, SeasonMonthStart})
+	if err != nil || !s.Weekday || !s.MonthStart {
+		t.Errorf("ParseSeasonality() = %+v, %v; want both patterns", s, err)
+	}
+	if s, err := ParseSeasonality(nil); err != nil || s != (Seasonality{}) {
+		t.Errorf("ParseSeasonality(nil) = %+v, %v; want none", s, err)
+	}
+	if _, err := ParseSeasonality([]string{"hourly"}); err == nil {
+		t.Error("ParseSeasonality accepted hourly")
+	}
+}
+
+func TestWeekdayFactors(t *testing.T) {
+	monday := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+	dates := func(days int) []time.Time {
+		d := make([]time.Time, days)
+		for i := range d {
+			d[i] = monday.AddDate(0, 0, i)
+		}
+		return d
+	}
+	// 100 on weekdays and 30 at weekends, for two weeks
+	values := []float64{100, 100, 100, 100, 100, 30, 30, 100, 100, 100, 100, 100, 30, 30}
+	mean := 1120.0 / 14
+
+	got := weekdayFactors(dates(14), values)
+	want := []float64{30 / mean, 100 / mean, 100 / mean, 100 / mean, 100 / mean, 100 / mean, 30 / mean}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("weekdayFactors() = %v, want %v", got, want)
+	}
+
+	noSunday := append([]float64(nil), values...)
+	noSunday[6], noSunday[13] = 0, 0
+	for name, tt := range map[string]struct {
+		dates  []time.Time
+		values []float64
+	}{
+		"one week": {dates(7), values[:7]},
+		"no spend": {dates(14), make([]float64, 14)},
+		"idle day": {dates(14), noSunday},
+	} {
+		if got := weekdayFactors(tt.dates, tt.values); got != nil {
+			t.Errorf("%s: weekdayFactors() = %v, want nil", name, got)
+		}
+	}
+}
+
+func TestWeekdaySeasonality(t *testing.T) {
+	sunday := today // March 31
+	friday := today.AddDate(0, 0, -2)
+	tests := []struct {
+		name     string
+		now      time.Time
+		cost     float64
+		weekday  bool
+		fired    bool
+		compared string
+	}{
+		{"weekday level on a Sunday, flat", sunday, 100, false, false, "baseline"},
+		{"weekday level on a Sunday, seasonal", sunday, 100, true, true, "weekday baseline (Sunday)"},
+		{"weekday level on a Friday, seasonal", friday, 101, true, false, "weekday baseline (Friday)"},
+		{"weekend level on a Friday, seasonal", friday, 20, true, true, "weekday baseline (Friday)"},
+	}
+	for _, tt := range tests {
+		d := NewDetector(DetectorConfig{Sensitivity: SensitivityMedium, BaselineDays: 28, RecentDays: 1, Explain: true,
+			Seasonality: Seasonality{Weekday: tt.weekday}})
+		point := charge("EC2", tt.now, tt.cost)
+		anomalies := d.detect(append(weekly(tt.now, 35), point), []normalizer.CostRecord{point}, tt.now)
+		if got := len(anomalies) == 1; got != tt.fired {
+			t.Errorf("%s: got %d anomalies, want fired %v", tt.name, len(anomalies), tt.fired)
+		}
+		for _, e := range d.Explanations() {
+			if e.Comparison != tt.compared {
+				t.Errorf("%s: compared against %s, want %s", tt.name, e.Comparison, tt.compared)
+			}
+		}
+	}
+}
+
+func TestMonthStartSeasonality(t *testing.T) {
+	april := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+	// 100 a day, with monthly charges of about 500 on the 1st
+	var records []normalizer.CostRecord
+	for d := april.AddDate(0, -3, 0); d.Before(april); d = d.AddDate(0, 0, 1) {
+		cost := 100 + float64(d.Day()%3)
+		if d.Day() == 1 {
+			cost = 490 + float64(d.Month())*5
+		}
+		records = append(records, charge("EC2", d, cost))
+	}
+	point := charge("EC2", april, 503)
+
+	tests := []struct {
+		name       string
+		monthStart bool
+		history    []normalizer.CostRecord
+		fired      bool
+		decision   string
+	}{
+		{"flat", false, records, true, "fired"},
+		{"month start", true, records, false, ""},
+		{"one earlier month start", true, records[len(records)-31:], false, "fewer than 2 earlier month starts"},
+	}
+	for _, tt := range tests {
+		d := NewDetector(DetectorConfig{Sensitivity: SensitivityMedium, BaselineDays: 30, RecentDays: 1, Explain: true,
+			Seasonality: Seasonality{MonthStart: tt.monthStart}})
+		anomalies := d.detect(append(tt.history, point), []normalizer.CostRecord{point}, april)
+		if got := len(anomalies) == 1; got != tt.fired {
+			t.Errorf("%s: got %d anomalies, want fired %v", tt.name, len(anomalies), tt.fired)
+		}
+		explanations := d.Explanations()
+		if tt.decision != "" && (len(explanations) != 1 || !strings.HasPrefix(explanations[0].Decision, tt.decision)) {
+			t.Errorf("%s: explanations = %+v, want %q", tt.name, explanations, tt.decision)
+		}
+	}
+
+	// Month starts are left out of the ordinary baseline
+	d := NewDetector(DetectorConfig{BaselineDays: 30, RecentDays: 1, Seasonality: Seasonality{MonthStart: true}})
+	if b := d.calculateBaseline(records, april); b.Count != 29 || b.Max > 102 {
+		t.Errorf("baseline = %+v, want 29 ordinary days", b)
+	}
+}
@@ -0,0 +1,216 @@
+package anomaly
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/store"
+)
+
+// Workflow statuses of a tracked anomaly
+const (
+	StatusOpen         = "open"         // alerts on every run it is detected
+	StatusAcknowledged = "acknowledged" // still reported, no longer alerted
+	StatusSuppressed   = "suppressed"   // neither reported nor alerted
+)
+
+// ValidateStatus checks an anomaly status
+func ValidateStatus(status string) error {
+	switch status {
+	case StatusOpen, StatusAcknowledged, StatusSuppressed:
+		return nil
+	default:
+		return fmt.Errorf("unknown anomaly status %q (want open, acknowledged or suppressed)", status)
+	}
+}
+
+// ID returns a stable identifier for an anomaly in a scope on a day, so the
+// same anomaly found again by a later run keeps its ID
+func ID(cloud, account, service, resource string, date time.Time) string {
+	key := strings.Join([]string{cloud, account, service, resource, date.UTC().Format("2006-01-02")}, "\x00")
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:6])
+}
+
+// StateStore persists anomaly states; the history store implements it
+type StateStore interface {
+	SaveAnomalyState(ctx context.Context, st store.AnomalyState) error
+	LoadAnomalyStates(ctx context.Context) ([]store.AnomalyState, error)
+}
+
+// Tracker carries the workflow state of anomalies between runs
+type Tracker struct {
+	store  StateStore
+	states map[string]store.AnomalyState
+}
+
+// NewTracker loads the recorded anomaly states
+func NewTracker(ctx context.Context, st StateStore) (*Tracker, error) {
+	states, err := st.LoadAnomalyStates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	t := &Tracker{store: st, states: make(map[string]store.AnomalyState, len(states))}
+	for _, s := range states {
+		t.states[s.ID] = s
+	}
+	return t, nil
+}
+
+// Observe records that an anomaly was detected at now, opening it if it is
+// new, and returns its status
+func (t *Tracker) Observe(ctx context.Context, id, scope string, date, now time.Time) (string, error) {
+	st, ok := t.states[id]
+	if !ok {
+		st = store.AnomalyState{ID: id, Status: StatusOpen, Scope: scope, Date: date, FirstSeen: now, UpdatedAt: now}
+	}
+	st.LastSeen = now
+	if err := t.store.SaveAnomalyState(ctx, st); err != nil {
+		return st.Status, err
+	}
+	t.states[id] = st
+	return st.Status, nil
+}
+
+// Track observes detected anomalies and sets their ID and status. Suppressed
+// anomalies are returned separately.
+func (t *Tracker) Track(ctx context.Context, anomalies []Anomaly, now time.Time) (kept, suppressed []Anomaly, err error) {
+	for _, a := range anomalies {
+		a.Status, err = t.Observe(ctx, a.ID, a.Scope(), a.Date, now)
+		if err != nil {
+			return nil, nil, err
+		}
+		if a.Status == StatusSuppressed {
+			suppressed = append(suppressed, a)
+		} else {
+			kept = append(kept, a)
+		}
+	}
+	return kept, suppressed, nil
+}
+
+// Set changes the status of a detected anomaly on behalf of by
+func (t *Tracker) Set(ctx context.Context, id, status, note, by string, now time.Time) (store.AnomalyState, error) {
+	if err := ValidateStatus(status); err != nil {
+		return store.AnomalyState{}, err
+	}
+	st, ok := t.states[id]
+	if !ok {
+		return store.AnomalyState{}, fmt.Errorf("no anomaly with ID %s has been detected", id)
+	}
+	st.Status = status
+	st.Note = note
+	st.UpdatedAt = now
+	st.UpdatedBy = by
+	if err := t.store.SaveAnomalyState(ctx, st); err != nil {
+		return store.AnomalyState{}, err
+	}
+	t.states[id] = st
+	return st, nil
+}
+
+// States returns every tracked anomaly, most recent first
+func (t *Tracker) States() []store.AnomalyState {
+	states := make([]store.AnomalyState, 0, len(t.states))
+	for _, st := range t.states {
+		states = append(states, st)
+	}
+	sort.Slice(states, func(i, j int) bool {
+		if !states[i].Date.Equal(states[j].Date) {
+			return states[i].Date.After(states[j].Date)
+		}
+		return states[i].ID < states[j].ID
+	})
+	return states
+}
+
+// stateRequest is the body accepted by Handler
+type stateRequest struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	Note   string `json:"note"`
+}
+
+// Handler returns an HTTP handler that lists tracked anomalies on GET and
+// changes one's status on POST ({"id": "3f2a9c01b7de", "status":
+// "acknowledged", "note": "INC-1234"}). Changes need a bearer token from
+// tokens, which maps who may make them to their tokens, and are logged with
+// that name; without tokens the handler is read-only.
+func Handler(st StateStore, tokens map[string]string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var by string
+		if r.Method == http.MethodPost {
+			if len(tokens) == 0 {
+				http.Error(w, "anomaly states are read-only: no tokens are configured", http.StatusForbidden)
+				return
+			}
+			var ok bool
+			if by, ok = authorize(r, tokens); !ok {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="anomalies"`)
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+		t, err := NewTracker(r.Context(), st)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if r.Method == http.MethodGet {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(t.States())
+			return
+		}
+
+		var req stateRequest
+		if err := json.NewDecoder(io.LimitReader(r.Body, 1<<16)).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := ValidateStatus(req.Status); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if _, ok := t.states[req.ID]; !ok {
+			http.Error(w, fmt.Sprintf("no anomaly with ID %s has been detected", req.ID), http.StatusNotFound)
+			return
+		}
+		updated, err := t.Set(r.Context(), req.ID, req.Status, req.Note, by, time.Now().UTC())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		log.Printf("Anomaly %s in %s set to %s by %s from %s", updated.ID, updated.Scope, updated.Status, by, r.RemoteAddr)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(updated)
+	})
+}
+
+// authorize returns the name whose token a request bears
+func authorize(r *http.Request, tokens map[string]string) (string, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return "", false
+	}
+	for name, want := range tokens {
+		if want != "" && subtle.ConstantTimeCompare([]byte(token), []byte(want)) == 1 {
+			return name, true
+		}
+	}
+	return "", false
+}
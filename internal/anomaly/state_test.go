@@ -0,0 +1,104 @@
+package anomaly
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lvonguyen/finops-platform/internal/store"
+)
+
+// memStates is an in-memory StateStore
+type memStates map[string]store.AnomalyState
+
+func (m memStates) SaveAnomalyState(ctx context.Context, st store.AnomalyState) error {
+	m[st.ID] = st
+	return nil
+}
+
+func (m memStates) LoadAnomalyStates(ctx context.Context) ([]store.AnomalyState, error) {
+	states := make([]store.AnomalyState, 0, len(m))
+	for _, st := range m {
+		states = append(states, st)
+	}
+	return states, nil
+}
+
+func TestHandlerAuthorizesChanges(t *testing.T) {
+	tokens := map[string]string{"alice": "s3cret-a", "bob": "s3cret-b"}
+	body := `{"id": "abc123", "status": "acknowledged", "note": "INC-1"}`
+	tests := []struct {
+		name   string
+		tokens map[string]string
+		method string
+		auth   string
+		want   int
+		by     string
+	}{
+		{"list needs no token", tokens, http.MethodGet, "", http.StatusOK, ""},
+		{"change without token", tokens, http.MethodPost, "", http.StatusUnauthorized, ""},
+		{"change with wrong token", tokens, http.MethodPost, "Bearer nope", http.StatusUnauthorized, ""},
+		{"token without bearer", tokens, http.MethodPost, "s3cret-a", http.StatusUnauthorized, ""},
+		{"change by alice", tokens, http.MethodPost, "Bearer s3cret-a", http.StatusOK, "alice"},
+		{"change by bob", tokens, http.MethodPost, "Bearer s3cret-b", http.StatusOK, "bob"},
+		{"read-only without tokens", nil, http.MethodPost, "Bearer s3cret-a", http.StatusForbidden, ""},
+		{"empty token matches nothing", map[string]string{"carol": ""}, http.MethodPost, "Bearer ", http.StatusUnauthorized, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			states := memStates{"abc123": {ID: "abc123", Status: StatusOpen, Scope: "aws/111/EC2"}}
+			req := httptest.NewRequest(tt.method, "/anomalies", strings.NewReader(body))
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			Handler(states, tt.tokens).ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d (%s), want %d", rec.Code, strings.TrimSpace(rec.Body.String()), tt.want)
+			}
+			st := states["abc123"]
+			if tt.by == "" {
+				if st.Status != StatusOpen || st.UpdatedBy != "" {
+					t.Errorf("state changed to %+v", st)
+				}
+				return
+			}
+			if st.Status != StatusAcknowledged || st.Note != "INC-1" || st.UpdatedBy != tt.by {
+				t.Errorf("state = %+v, want acknowledged by %s", st, tt.by)
+			}
+			var got store.AnomalyState
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil || got.UpdatedBy != tt.by {
+				t.Errorf("response = %+v (%v), want updated by %s", got, err, tt.by)
+			}
+		})
+	}
+}
+
+func TestHandlerRejectsBadChanges(t *testing.T) {
+	tokens := map[string]string{"alice": "s3cret-a"}
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"invalid JSON", `{`, http.StatusBadRequest},
+		{"unknown status", `{"id": "abc123", "status": "closed"}`, http.StatusBadRequest},
+		{"unknown anomaly", `{"id": "ffffff", "status": "suppressed"}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			states := memStates{"abc123": {ID: "abc123", Status: StatusOpen}}
+			req := httptest.NewRequest(http.MethodPost, "/anomalies", strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer s3cret-a")
+			rec := httptest.NewRecorder()
+			Handler(states, tokens).ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
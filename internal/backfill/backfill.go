@@ -0,0 +1,200 @@
+// Package backfill loads historical cost data into the history store in
+// paced, resumable chunks
+package backfill
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/store"
+)
+
+// Source fetches aggregated costs for [start, end)
+type Source interface {
+	Aggregate(ctx context.Context, start, end time.Time) (*aggregator.AggregationResult, error)
+}
+
+// Config paces a backfill
+type Config struct {
+	ChunkDays   int           // days per chunk
+	MinInterval time.Duration // minimum time between chunk queries
+	MaxRetries  int           // retries per chunk, with exponential backoff
+}
+
+// Progress is reported after each completed chunk
+type Progress struct {
+	Chunk     int
+	Chunks    int
+	Start     time.Time
+	End       time.Time
+	Records   int
+	Remaining time.Duration // estimate from the pace so far
+}
+
+// Backfiller fetches a date range chunk by chunk, saving each chunk and a
+// checkpoint so an interrupted run resumes after the last completed chunk
+type Backfiller struct {
+	source   Source
+	store    store.CostStore
+	cfg      Config
+	progress func(Progress)
+}
+
+// New creates a backfiller. progress may be nil.
+func New(source Source, st store.CostStore, cfg Config, progress func(Progress)) *Backfiller {
+	if cfg.ChunkDays <= 0 {
+		cfg.ChunkDays = 7
+	}
+	if progress == nil {
+		progress = func(Progress) {}
+	}
+	return &Backfiller{source: source, store: st, cfg: cfg, progress: progress}
+}
+
+// FromConfig parses the backfill configuration
+func FromConfig(cfg config.BackfillConfig) (Config, error) {
+	c := Config{ChunkDays: cfg.ChunkDays, MaxRetries: cfg.MaxRetries}
+	if cfg.MinInterval != "" {
+		d, err := time.ParseDuration(cfg.MinInterval)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid min_interval %q: %w", cfg.MinInterval, err)
+		}
+		c.MinInterval = d
+	}
+	return c, nil
+}
+
+// JobName identifies the checkpoint for a range
+func JobName(from, to time.Time) string {
+	return fmt.Sprintf("backfill-%s-%s", from.Format("20060102"), to.Format("20060102"))
+}
+
+// Run backfills [from, to). Chunks already recorded in the range's checkpoint
+// are skipped. Re-running a chunk is safe because the store replaces whole
+// days on save.
+func (b *Backfiller) Run(ctx context.Context, from, to time.Time) error {
+	if !from.Before(to) {
+		return fmt.Errorf("backfill range is empty: %s to %s", from.Format("2006-01-02"), to.Format("2006-01-02"))
+	}
+
+	job := JobName(from, to)
+	cp, ok, err := b.store.LoadCheckpoint(ctx, job)
+	if err != nil {
+		return err
+	}
+	resume := from
+	if ok && cp.Completed.After(from) {
+		resume = cp.Completed
+		log.Printf("Resuming backfill from %s", resume.Format("2006-01-02"))
+	}
+	if !resume.Before(to) {
+		log.Printf("Backfill %s to %s already complete", from.Format("2006-01-02"), to.Format("2006-01-02"))
+		return nil
+	}
+
+	chunks := chunkCount(from, to, b.cfg.ChunkDays)
+	skipped := chunkCount(from, resume, b.cfg.ChunkDays)
+	done := skipped
+	began := time.Now()
+	var lastQuery time.Time
+
+	for start := resume; start.Before(to); {
+		end := start.AddDate(0, 0, b.cfg.ChunkDays)
+		if end.After(to) {
+			end = to
+		}
+
+		if wait := b.cfg.MinInterval - time.Since(lastQuery); !lastQuery.IsZero() && wait > 0 {
+			if err := sleep(ctx, wait); err != nil {
+				return err
+			}
+		}
+		lastQuery = time.Now()
+
+		records, err := b.fetchChunk(ctx, start, end)
+		if err != nil {
+			return fmt.Errorf("chunk %s to %s: %w", start.Format("2006-01-02"), end.Format("2006-01-02"), err)
+		}
+
+		cp := store.Checkpoint{Job: job, From: from, To: to, Completed: end, UpdatedAt: time.Now().UTC()}
+		if err := b.store.SaveCheckpoint(ctx, cp); err != nil {
+			return err
+		}
+
+		done++
+		remaining := time.Since(began) / time.Duration(done-skipped) * time.Duration(chunks-done)
+		b.progress(Progress{Chunk: done, Chunks: chunks, Start: start, End: end, Records: records, Remaining: remaining})
+
+		start = end
+	}
+
+	return nil
+}
+
+// fetchChunk aggregates and saves one chunk, retrying with exponential
+// backoff. A chunk with provider errors is never saved, because saving
+// replaces whole days and would drop the failed provider's data.
+func (b *Backfiller) fetchChunk(ctx context.Context, start, end time.Time) (int, error) {
+	backoff := b.cfg.MinInterval
+	if backoff < time.Second {
+		backoff = time.Second
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= b.cfg.MaxRetries; attempt++ {
+		if attempt > 0 {
+			log.Printf("Retrying chunk %s in %s: %v", start.Format("2006-01-02"), backoff, lastErr)
+			if err := sleep(ctx, backoff); err != nil {
+				return 0, err
+			}
+			backoff *= 2
+		}
+
+		results, err := b.source.Aggregate(ctx, start, end)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if len(results.Errors) > 0 {
+			lastErr = providerErrors(results.Errors)
+			continue
+		}
+
+		records := results.Records()
+		if err := b.store.SaveRecords(ctx, records); err != nil {
+			return 0, err
+		}
+		return len(records), nil
+	}
+
+	return 0, lastErr
+}
+
+func providerErrors(errs []aggregator.ProviderError) error {
+	msgs := make([]string, len(errs))
+	for i, e := range errs {
+		msgs[i] = fmt.Sprintf("%s (%s): %s", e.Provider, e.Kind, e.Message)
+	}
+	return fmt.Errorf("provider errors: %s", strings.Join(msgs, "; "))
+}
+
+func chunkCount(from, to time.Time, chunkDays int) int {
+	days := int(to.Sub(from).Hours() / 24)
+	return (days + chunkDays - 1) / chunkDays
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
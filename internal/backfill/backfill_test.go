@@ -0,0 +1,109 @@
+package backfill
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+	"github.com/lvonguyen/finops-platform/internal/store"
+)
+
+var from = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// fakeSource returns one entry per day of a chunk, failing chunks that
+// start on a day in fail
+type fakeSource struct {
+	fail   map[time.Time]error
+	chunks []time.Time
+}
+
+func (s *fakeSource) Aggregate(ctx context.Context, start, end time.Time) (*aggregator.AggregationResult, error) {
+	s.chunks = append(s.chunks, start)
+	if err := s.fail[start]; err != nil {
+		return nil, err
+	}
+	result := &aggregator.AggregationResult{}
+	for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+		result.Entries = append(result.Entries, aggregator.CostEntry{Provider: "aws", AccountID: "111", Service: "EC2", Date: d, Cost: 1, Currency: "USD"})
+	}
+	return result, nil
+}
+
+func TestRunResumesAfterFailure(t *testing.T) {
+	ctx := context.Background()
+	st, err := store.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	to := from.AddDate(0, 0, 20)
+	second := from.AddDate(0, 0, 7)
+	source := &fakeSource{fail: map[time.Time]error{second: errors.New("throttled")}}
+	var progress []Progress
+	b := New(source, st, Config{ChunkDays: 7}, func(p Progress) { progress = append(progress, p) })
+
+	if err := b.Run(ctx, from, to); err == nil {
+		t.Fatal("run with a failing chunk succeeded")
+	}
+	cp, ok, err := st.LoadCheckpoint(ctx, JobName(from, to))
+	if err != nil || !ok || !cp.Completed.Equal(second) {
+		t.Fatalf("checkpoint = %+v, %v, %v; want completed through %s", cp, ok, err, second)
+	}
+
+	// The rerun starts at the failed chunk
+	delete(source.fail, second)
+	source.chunks = nil
+	if err := b.Run(ctx, from, to); err != nil {
+		t.Fatal(err)
+	}
+	if len(source.chunks) != 2 || !source.chunks[0].Equal(second) {
+		t.Errorf("rerun fetched chunks %v, want the 2nd and 3rd", source.chunks)
+	}
+
+	if len(progress) != 3 {
+		t.Fatalf("got %d progress reports, want 3", len(progress))
+	}
+	last := progress[2]
+	if last.Chunk != 3 || last.Chunks != 3 || last.Records != 6 || !last.End.Equal(to) {
+		t.Errorf("last progress = %+v, want chunk 3 of 3 with 6 records ending %s", last, to)
+	}
+
+	records, err := st.QueryRange(ctx, from, to)
+	if err != nil || len(records) != 20 {
+		t.Errorf("store holds %d records, %v; want 20", len(records), err)
+	}
+
+	// A completed range is not fetched again
+	source.chunks = nil
+	if err := b.Run(ctx, from, to); err != nil || len(source.chunks) != 0 {
+		t.Errorf("completed rerun fetched %v, %v", source.chunks, err)
+	}
+}
+
+func TestFetchChunkSkipsPartialResults(t *testing.T) {
+	ctx := context.Background()
+	st, err := store.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	partial := sourceFunc(func(ctx context.Context, start, end time.Time) (*aggregator.AggregationResult, error) {
+		return &aggregator.AggregationResult{
+			Entries: []aggregator.CostEntry{{Provider: "aws", Date: start, Cost: 1}},
+			Errors:  []aggregator.ProviderError{{Provider: "gcp", Kind: aggregator.ErrorKindAPI, Message: "quota exceeded"}},
+		}, nil
+	})
+	b := New(partial, st, Config{ChunkDays: 7}, nil)
+	if _, err := b.fetchChunk(ctx, from, from.AddDate(0, 0, 7)); err == nil {
+		t.Fatal("chunk with provider errors saved")
+	}
+	if records, _ := st.QueryRange(ctx, from, from.AddDate(0, 0, 7)); len(records) != 0 {
+		t.Errorf("store holds %d records, want none", len(records))
+	}
+}
+
+type sourceFunc func(ctx context.Context, start, end time.Time) (*aggregator.AggregationResult, error)
+
+func (f sourceFunc) Aggregate(ctx context.Context, start, end time.Time) (*aggregator.AggregationResult, error) {
+	return f(ctx, start, end)
+}
@@ -0,0 +1,197 @@
+// Package cache reuses provider results between runs so repeated invocations
+// do not re-query billing APIs that charge per request
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+// Backend stores opaque cache entries. The history store implements it.
+type Backend interface {
+	SaveCache(ctx context.Context, key string, data []byte) error
+	LoadCache(ctx context.Context, key string) ([]byte, bool, error)
+}
+
+// FileBackend keeps one file per cache entry under a directory
+type FileBackend struct {
+	dir string
+}
+
+// NewFileBackend opens (creating if needed) a file cache rooted at dir
+func NewFileBackend(dir string) (*FileBackend, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	return &FileBackend{dir: dir}, nil
+}
+
+// SaveCache writes an entry through a temporary file so a crash never leaves
+// a half-written entry behind
+func (b *FileBackend) SaveCache(ctx context.Context, key string, data []byte) error {
+	path := filepath.Join(b.dir, key+".json")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	return nil
+}
+
+// LoadCache reads an entry
+func (b *FileBackend) LoadCache(ctx context.Context, key string) ([]byte, bool, error) {
+	data, err := os.ReadFile(filepath.Join(b.dir, key+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read cache entry: %w", err)
+	}
+	return data, true, nil
+}
+
+// entry is a cached provider result
+type entry struct {
+	StoredAt time.Time              `json:"stored_at"`
+	Entries  []aggregator.CostEntry `json:"entries"`
+}
+
+// Provider serves a provider's costs from the cache while they are younger
+// than the TTL, fetching and storing them otherwise. Budgets are not cached.
+type Provider struct {
+	inner   aggregator.CostProvider
+	backend Backend
+	ttl     time.Duration
+	refresh bool   // ignore cached results, but still store fresh ones
+	query   string // the provider's query settings, part of every key
+}
+
+// Wrap caches a provider's results in backend. Query describes the
+// provider's settings that shape its results, such as its group-by
+// dimensions, so changing them does not serve stale entries.
+func Wrap(p aggregator.CostProvider, backend Backend, ttl time.Duration, refresh bool, query any) (*Provider, error) {
+	data, err := json.Marshal(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s query settings: %w", p.Name(), err)
+	}
+	return &Provider{inner: p, backend: backend, ttl: ttl, refresh: refresh, query: string(data)}, nil
+}
+
+// Name returns the wrapped provider's name
+func (p *Provider) Name() string {
+	return p.inner.Name()
+}
+
+// GetBudgets returns the wrapped provider's budgets
+func (p *Provider) GetBudgets(ctx context.Context) ([]aggregator.BudgetStatus, error) {
+	return p.inner.GetBudgets(ctx)
+}
+
+// GetCosts returns the provider's costs for [start, end)
+func (p *Provider) GetCosts(ctx context.Context, start, end time.Time) ([]aggregator.CostEntry, error) {
+	return p.fetch(ctx, start, end, "", func() ([]aggregator.CostEntry, error) {
+		return p.inner.GetCosts(ctx, start, end)
+	})
+}
+
+// GetFilteredCosts pushes the filter down when the wrapped provider supports
+// it, caching each filter separately; otherwise the unfiltered result is
+// cached and filtered client-side
+func (p *Provider) GetFilteredCosts(ctx context.Context, start, end time.Time, filter aggregator.CostFilter) ([]aggregator.CostEntry, error) {
+	if fp, ok := p.inner.(aggregator.FilteringProvider); ok {
+		return p.fetch(ctx, start, end, filter.String(), func() ([]aggregator.CostEntry, error) {
+			return fp.GetFilteredCosts(ctx, start, end, filter)
+		})
+	}
+
+	entries, err := p.GetCosts(ctx, start, end)
+	if err != nil {
+		return nil, err
+	}
+	kept := make([]aggregator.CostEntry, 0, len(entries))
+	for _, e := range entries {
+		if filter.Matches(e) {
+			kept = append(kept, e)
+		}
+	}
+	return kept, nil
+}
+
+// RefreshCredentials refreshes the wrapped provider's credentials
+func (p *Provider) RefreshCredentials(ctx context.Context) error {
+	refresher, ok := p.inner.(aggregator.CredentialRefresher)
+	if !ok {
+		return fmt.Errorf("%s cannot refresh credentials", p.inner.Name())
+	}
+	return refresher.RefreshCredentials(ctx)
+}
+
+// CreateBudget creates a budget through the wrapped provider
+func (p *Provider) CreateBudget(ctx context.Context, b config.Budget) error {
+	writer, ok := p.inner.(aggregator.BudgetWriter)
+	if !ok {
+		return fmt.Errorf("%s cannot create budgets", p.inner.Name())
+	}
+	return writer.CreateBudget(ctx, b)
+}
+
+// fetch returns a fresh cached result or calls get and caches what it
+// returns. Cache failures are logged and never fail the fetch.
+func (p *Provider) fetch(ctx context.Context, start, end time.Time, filter string, get func() ([]aggregator.CostEntry, error)) ([]aggregator.CostEntry, error) {
+	key := p.key(start, end, filter)
+	if !p.refresh {
+		data, ok, err := p.backend.LoadCache(ctx, key)
+		if err != nil {
+			log.Printf("Warning: Failed to read %s cache: %v", p.Name(), err)
+		}
+		if ok {
+			var cached entry
+			if err := json.Unmarshal(data, &cached); err != nil {
+				log.Printf("Warning: Ignoring unreadable %s cache entry: %v", p.Name(), err)
+			} else if age := time.Since(cached.StoredAt); age < p.ttl {
+				log.Printf("Using %s costs cached %s ago", p.Name(), age.Round(time.Minute))
+				return cached.Entries, nil
+			}
+		}
+	}
+
+	entries, err := get()
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(entry{StoredAt: time.Now().UTC(), Entries: entries})
+	if err == nil {
+		err = p.backend.SaveCache(ctx, key, data)
+	}
+	if err != nil {
+		log.Printf("Warning: Failed to cache %s costs: %v", p.Name(), err)
+	}
+	return entries, nil
+}
+
+// key identifies a result by provider, query settings, date range and filter
+func (p *Provider) key(start, end time.Time, filter string) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		p.Name(),
+		p.query,
+		start.UTC().Format(time.RFC3339),
+		end.UTC().Format(time.RFC3339),
+		filter,
+	}, "\n")))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,219 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+)
+
+var day = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+// countingProvider returns fixed entries, counting its calls
+type countingProvider struct {
+	entries []aggregator.CostEntry
+	err     error
+	calls   int
+}
+
+func (p *countingProvider) GetCosts(ctx context.Context, start, end time.Time) ([]aggregator.CostEntry, error) {
+	p.calls++
+	return p.entries, p.err
+}
+
+func (p *countingProvider) GetBudgets(ctx context.Context) ([]aggregator.BudgetStatus, error) {
+	return nil, nil
+}
+
+func (p *countingProvider) Name() string {
+	return "aws"
+}
+
+// filteringProvider applies filters itself
+type filteringProvider struct {
+	countingProvider
+}
+
+func (p *filteringProvider) GetFilteredCosts(ctx context.Context, start, end time.Time, filter aggregator.CostFilter) ([]aggregator.CostEntry, error) {
+	p.calls++
+	var kept []aggregator.CostEntry
+	for _, e := range p.entries {
+		if filter.Matches(e) {
+			kept = append(kept, e)
+		}
+	}
+	return kept, nil
+}
+
+// entries returns an EC2 and an S3 charge
+func entries() []aggregator.CostEntry {
+	return []aggregator.CostEntry{
+		{Provider: "aws", AccountID: "111", Service: "EC2", Date: day, Cost: 10},
+		{Provider: "aws", AccountID: "222", Service: "S3", Date: day, Cost: 2},
+	}
+}
+
+// fileBackend opens a file backend in a temporary directory
+func fileBackend(t *testing.T) *FileBackend {
+	t.Helper()
+	b, err := NewFileBackend(filepath.Join(t.TempDir(), "cache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// wrap caches inner in backend, failing the test on error
+func wrap(t *testing.T, inner aggregator.CostProvider, backend Backend, ttl time.Duration, refresh bool, query any) *Provider {
+	t.Helper()
+	p, err := Wrap(inner, backend, ttl, refresh, query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestProviderCaches(t *testing.T) {
+	ctx := context.Background()
+	inner := &countingProvider{entries: entries()}
+	backend := fileBackend(t)
+	p := wrap(t, inner, backend, time.Hour, false, []string{"SERVICE"})
+
+	for i := 0; i < 2; i++ {
+		got, err := p.GetCosts(ctx, day, day.AddDate(0, 0, 1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 2 || got[0].Cost != 10 || !got[0].Date.Equal(day) {
+			t.Errorf("call %d: got %+v", i, got)
+		}
+	}
+	if inner.calls != 1 {
+		t.Errorf("provider called %d times, want 1", inner.calls)
+	}
+
+	// Another range, other query settings and a forced refresh each fetch
+	if _, err := p.GetCosts(ctx, day, day.AddDate(0, 0, 2)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wrap(t, inner, backend, time.Hour, false, []string{"SERVICE", "REGION"}).GetCosts(ctx, day, day.AddDate(0, 0, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wrap(t, inner, backend, time.Hour, true, []string{"SERVICE"}).GetCosts(ctx, day, day.AddDate(0, 0, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if inner.calls != 4 {
+		t.Errorf("provider called %d times, want 4", inner.calls)
+	}
+}
+
+func TestProviderExpires(t *testing.T) {
+	ctx := context.Background()
+	inner := &countingProvider{entries: entries()}
+	backend := fileBackend(t)
+	p := wrap(t, inner, backend, time.Hour, false, nil)
+
+	stale, err := json.Marshal(entry{StoredAt: time.Now().Add(-2 * time.Hour), Entries: entries()[:1]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := p.key(day, day.AddDate(0, 0, 1), "")
+	if err := backend.SaveCache(ctx, key, stale); err != nil {
+		t.Fatal(err)
+	}
+	got, err := p.GetCosts(ctx, day, day.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inner.calls != 1 || len(got) != 2 {
+		t.Errorf("stale entry: %d calls, %d entries; want a fresh fetch of 2", inner.calls, len(got))
+	}
+
+	// The fresh result replaced the stale one
+	if _, err := p.GetCosts(ctx, day, day.AddDate(0, 0, 1)); err != nil || inner.calls != 1 {
+		t.Errorf("after refetch: %d calls, %v; want the cached result", inner.calls, err)
+	}
+
+	// Unreadable entries are refetched
+	if err := backend.SaveCache(ctx, key, []byte("{")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.GetCosts(ctx, day, day.AddDate(0, 0, 1)); err != nil || inner.calls != 2 {
+		t.Errorf("unreadable entry: %d calls, %v; want a fresh fetch", inner.calls, err)
+	}
+}
+
+func TestProviderDoesNotCacheErrors(t *testing.T) {
+	inner := &countingProvider{err: errors.New("throttled")}
+	p := wrap(t, inner, fileBackend(t), time.Hour, false, nil)
+	for i := 0; i < 2; i++ {
+		if _, err := p.GetCosts(context.Background(), day, day.AddDate(0, 0, 1)); err == nil {
+			t.Fatal("GetCosts() succeeded, want the provider's error")
+		}
+	}
+	if inner.calls != 2 {
+		t.Errorf("provider called %d times, want 2", inner.calls)
+	}
+}
+
+func TestProviderFilters(t *testing.T) {
+	ctx := context.Background()
+	ec2 := aggregator.CostFilter{Services: []string{"EC2"}}
+	s3 := aggregator.CostFilter{Services: []string{"S3"}}
+
+	// Unfiltered results are cached once and filtered client-side
+	plain := &countingProvider{entries: entries()}
+	p := wrap(t, plain, fileBackend(t), time.Hour, false, nil)
+	for _, f := range []aggregator.CostFilter{ec2, s3} {
+		got, err := p.GetFilteredCosts(ctx, day, day.AddDate(0, 0, 1), f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 1 || got[0].Service != f.Services[0] {
+			t.Errorf("filter %s: got %+v", f, got)
+		}
+	}
+	if plain.calls != 1 {
+		t.Errorf("plain provider called %d times, want 1", plain.calls)
+	}
+
+	// Filters pushed down are cached separately
+	pushed := &filteringProvider{countingProvider{entries: entries()}}
+	p = wrap(t, pushed, fileBackend(t), time.Hour, false, nil)
+	for _, f := range []aggregator.CostFilter{ec2, s3, ec2} {
+		got, err := p.GetFilteredCosts(ctx, day, day.AddDate(0, 0, 1), f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 1 || got[0].Service != f.Services[0] {
+			t.Errorf("filter %s: got %+v", f, got)
+		}
+	}
+	if pushed.calls != 2 {
+		t.Errorf("filtering provider called %d times, want 2", pushed.calls)
+	}
+}
+
+func TestFileBackend(t *testing.T) {
+	ctx := context.Background()
+	b := fileBackend(t)
+	if _, ok, err := b.LoadCache(ctx, "k"); ok || err != nil {
+		t.Errorf("missing entry: ok %v, err %v; want neither", ok, err)
+	}
+	for _, v := range []string{"v1", "v2"} {
+		if err := b.SaveCache(ctx, "k", []byte(v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if data, ok, err := b.LoadCache(ctx, "k"); err != nil || !ok || string(data) != "v2" {
+		t.Errorf("LoadCache() = %q, %v, %v; want v2", data, ok, err)
+	}
+	if _, err := os.Stat(filepath.Join(b.dir, "k.json.tmp")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("temporary file left behind: %v", err)
+	}
+}
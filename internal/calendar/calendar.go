@@ -0,0 +1,115 @@
+// Package calendar provides special-day and business-day lookups shared by
+// anomaly detection and forecasting.
+package calendar
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+// Day is a special calendar day such as a public holiday or a sales event
+type Day struct {
+	Date time.Time
+	Name string
+	Key  string // days sharing a key are equivalent across years (e.g. black_friday)
+}
+
+// Calendar holds special days and weekend handling
+type Calendar struct {
+	days         map[string]Day
+	weekendsWork bool
+}
+
+// New creates a calendar from a list of special days
+func New(days []Day, weekendsWork bool) *Calendar {
+	c := &Calendar{
+		days:         make(map[string]Day, len(days)),
+		weekendsWork: weekendsWork,
+	}
+	for _, d := range days {
+		if d.Key == "" {
+			d.Key = keyFromName(d.Name)
+		}
+		d.Date = truncate(d.Date)
+		c.days[dateKey(d.Date)] = d
+	}
+	return c
+}
+
+// FromConfig builds a calendar from configuration
+func FromConfig(cfg config.CalendarConfig) (*Calendar, error) {
+	days := make([]Day, 0, len(cfg.SpecialDays))
+	for _, sd := range cfg.SpecialDays {
+		date, err := time.Parse("2006-01-02", sd.Date)
+		if err != nil {
+			return nil, fmt.Errorf("invalid special day %q: %w", sd.Date, err)
+		}
+		days = append(days, Day{Date: date, Name: sd.Name, Key: sd.Key})
+	}
+	return New(days, cfg.WeekendsAreBusinessDays), nil
+}
+
+// Special returns the special day on t, if any
+func (c *Calendar) Special(t time.Time) (Day, bool) {
+	if c == nil {
+		return Day{}, false
+	}
+	d, ok := c.days[dateKey(t)]
+	return d, ok
+}
+
+// IsBusinessDay reports whether t is neither a weekend nor a special day
+func (c *Calendar) IsBusinessDay(t time.Time) bool {
+	if _, ok := c.Special(t); ok {
+		return false
+	}
+	if c != nil && c.weekendsWork {
+		return true
+	}
+	wd := t.Weekday()
+	return wd != time.Saturday && wd != time.Sunday
+}
+
+// BusinessDays counts business days in [start, end)
+func (c *Calendar) BusinessDays(start, end time.Time) int {
+	n := 0
+	for d := truncate(start); d.Before(end); d = d.AddDate(0, 0, 1) {
+		if c.IsBusinessDay(d) {
+			n++
+		}
+	}
+	return n
+}
+
+// Equivalent returns the special days sharing key, in date order
+func (c *Calendar) Equivalent(key string) []Day {
+	if c == nil {
+		return nil
+	}
+	var days []Day
+	for _, d := range c.days {
+		if d.Key == key {
+			days = append(days, d)
+		}
+	}
+	sort.Slice(days, func(i, j int) bool {
+		return days[i].Date.Before(days[j].Date)
+	})
+	return days
+}
+
+func keyFromName(name string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), " ", "_")
+}
+
+func dateKey(t time.Time) string {
+	return t.Format("2006-01-02")
+}
+
+func truncate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
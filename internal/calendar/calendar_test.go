@@ -0,0 +1,92 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+func date(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestBusinessDays(t *testing.T) {
+	// 2024-07-04 is a Thursday; the week of July 1 has one holiday
+	days := []Day{{Date: date("2024-07-04"), Name: "Independence Day"}}
+	tests := []struct {
+		name         string
+		weekendsWork bool
+		want         int
+	}{
+		{"weekends off", false, 4},
+		{"weekends work", true, 6},
+	}
+	for _, tt := range tests {
+		c := New(days, tt.weekendsWork)
+		if got := c.BusinessDays(date("2024-07-01"), date("2024-07-08")); got != tt.want {
+			t.Errorf("%s: BusinessDays = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+
+	var none *Calendar
+	if got := none.BusinessDays(date("2024-07-01"), date("2024-07-08")); got != 5 {
+		t.Errorf("nil calendar: BusinessDays = %d, want 5", got)
+	}
+}
+
+func TestSpecial(t *testing.T) {
+	c := New([]Day{{Date: time.Date(2024, 11, 29, 15, 30, 0, 0, time.UTC), Name: "Black Friday"}}, false)
+
+	day, ok := c.Special(date("2024-11-29"))
+	if !ok || day.Name != "Black Friday" || day.Key != "black_friday" {
+		t.Fatalf("Special = %+v, %v; want Black Friday keyed black_friday", day, ok)
+	}
+	if _, ok := c.Special(date("2024-11-28")); ok {
+		t.Error("2024-11-28 is special, want ordinary")
+	}
+}
+
+func TestEquivalent(t *testing.T) {
+	c := New([]Day{
+		{Date: date("2024-11-29"), Name: "Black Friday"},
+		{Date: date("2022-11-25"), Name: "Black Friday"},
+		{Date: date("2023-11-24"), Name: "Sale", Key: "black_friday"},
+		{Date: date("2023-12-25"), Name: "Christmas"},
+	}, false)
+
+	got := c.Equivalent("black_friday")
+	want := []string{"2022-11-25", "2023-11-24", "2024-11-29"}
+	if len(got) != len(want) {
+		t.Fatalf("got %d equivalent days, want %d", len(got), len(want))
+	}
+	for i, d := range got {
+		if dateKey(d.Date) != want[i] {
+			t.Errorf("day %d = %s, want %s", i, dateKey(d.Date), want[i])
+		}
+	}
+}
+
+func TestFromConfig(t *testing.T) {
+	c, err := FromConfig(config.CalendarConfig{
+		SpecialDays:             []config.SpecialDay{{Date: "2024-12-25", Name: "Christmas Day"}},
+		WeekendsAreBusinessDays: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if day, ok := c.Special(date("2024-12-25")); !ok || day.Key != "christmas_day" {
+		t.Errorf("Special = %+v, %v; want christmas_day", day, ok)
+	}
+	if !c.IsBusinessDay(date("2024-12-28")) {
+		t.Error("Saturday is not a business day with weekends working")
+	}
+
+	if _, err := FromConfig(config.CalendarConfig{SpecialDays: []config.SpecialDay{{Date: "12/25/2024"}}}); err == nil {
+		t.Error("invalid date accepted")
+	}
+}
@@ -0,0 +1,165 @@
+package calendar
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+// Fiscal maps the current instant to a billing day, and billing days to
+// fiscal months, quarters and years. Billing days are UTC midnights, as in
+// cost records; the timezone only decides which day it is now.
+//
+// Fiscal months are named YYYY-MM after the calendar month they stand for.
+// With week-based periods (4-4-5 and its variants) the fiscal year starts on
+// the week's first day nearest the 1st of its start month, and the last month
+// absorbs the 53rd week of long years. A nil Fiscal is the UTC calendar.
+type Fiscal struct {
+	loc       *time.Location
+	yearStart time.Month
+	weeks     []int // weeks in each month of a quarter; nil for calendar months
+	weekStart time.Weekday
+}
+
+var utcCalendar = &Fiscal{loc: time.UTC, yearStart: time.January}
+
+var weekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "monday": time.Monday, "tuesday": time.Tuesday, "wednesday": time.Wednesday,
+	"thursday": time.Thursday, "friday": time.Friday, "saturday": time.Saturday,
+}
+
+// FiscalFromConfig builds the fiscal calendar from configuration
+func FiscalFromConfig(cfg config.CalendarConfig) (*Fiscal, error) {
+	f := &Fiscal{loc: time.UTC, yearStart: time.January, weekStart: time.Monday}
+	if cfg.Timezone != "" {
+		loc, err := time.LoadLocation(cfg.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone %q: %w", cfg.Timezone, err)
+		}
+		f.loc = loc
+	}
+	if cfg.FiscalYearStart != 0 {
+		if cfg.FiscalYearStart < 1 || cfg.FiscalYearStart > 12 {
+			return nil, fmt.Errorf("invalid fiscal_year_start %d (want a month, 1 to 12)", cfg.FiscalYearStart)
+		}
+		f.yearStart = time.Month(cfg.FiscalYearStart)
+	}
+	if cfg.WeekStart != "" {
+		wd, ok := weekdays[strings.ToLower(cfg.WeekStart)]
+		if !ok {
+			return nil, fmt.Errorf("invalid week_start %q", cfg.WeekStart)
+		}
+		f.weekStart = wd
+	}
+
+	if cfg.Periods != "" && cfg.Periods != "calendar" {
+		for _, p := range strings.Split(cfg.Periods, "-") {
+			n, err := strconv.Atoi(p)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid periods %q", cfg.Periods)
+			}
+			f.weeks = append(f.weeks, n)
+		}
+		if len(f.weeks) != 3 || f.weeks[0]+f.weeks[1]+f.weeks[2] != 13 {
+			return nil, fmt.Errorf("invalid periods %q (want calendar, or the weeks of each month in a 13-week quarter such as 4-4-5)", cfg.Periods)
+		}
+	}
+	return f, nil
+}
+
+// Today returns the billing day now falls on in the billing timezone
+func (f *Fiscal) Today(now time.Time) time.Time {
+	if f == nil {
+		f = utcCalendar
+	}
+	now = now.In(f.loc)
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// MonthOf returns the name, the first of a calendar month, of the fiscal
+// month containing day
+func (f *Fiscal) MonthOf(day time.Time) time.Time {
+	if f == nil {
+		f = utcCalendar
+	}
+	if f.weeks == nil {
+		return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	y := day.Year() + 1
+	for f.yearStartDay(y).After(day) {
+		y--
+	}
+	start := f.yearStartDay(y)
+	for k := 0; k < 11; k++ {
+		start = start.AddDate(0, 0, 7*f.weeks[k%3])
+		if day.Before(start) {
+			return time.Date(y, f.yearStart+time.Month(k), 1, 0, 0, 0, 0, time.UTC)
+		}
+	}
+	return time.Date(y, f.yearStart+11, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// Month returns the days [start, end) of the fiscal month named by month,
+// any day of the calendar month it stands for
+func (f *Fiscal) Month(month time.Time) (start, end time.Time) {
+	if f == nil {
+		f = utcCalendar
+	}
+	name := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if f.weeks == nil {
+		return name, name.AddDate(0, 1, 0)
+	}
+	k := f.index(name)
+	y := name.Year()
+	if name.Month() < f.yearStart {
+		y--
+	}
+	start = f.yearStartDay(y)
+	for i := 0; i < k; i++ {
+		start = start.AddDate(0, 0, 7*f.weeks[i%3])
+	}
+	if k == 11 {
+		return start, f.yearStartDay(y + 1)
+	}
+	return start, start.AddDate(0, 0, 7*f.weeks[k%3])
+}
+
+// Quarter returns the days [start, end) of the fiscal quarter containing day
+func (f *Fiscal) Quarter(day time.Time) (start, end time.Time) {
+	name := f.MonthOf(day)
+	first := name.AddDate(0, -(f.index(name) % 3), 0)
+	start, _ = f.Month(first)
+	_, end = f.Month(first.AddDate(0, 2, 0))
+	return start, end
+}
+
+// Year returns the days [start, end) of the fiscal year containing day
+func (f *Fiscal) Year(day time.Time) (start, end time.Time) {
+	name := f.MonthOf(day)
+	first := name.AddDate(0, -f.index(name), 0)
+	start, _ = f.Month(first)
+	_, end = f.Month(first.AddDate(0, 11, 0))
+	return start, end
+}
+
+// index returns a fiscal month's position in its year, from 0
+func (f *Fiscal) index(name time.Time) int {
+	if f == nil {
+		f = utcCalendar
+	}
+	return (int(name.Month()) - int(f.yearStart) + 12) % 12
+}
+
+// yearStartDay returns the first day of the week-based fiscal year starting
+// in calendar year y
+func (f *Fiscal) yearStartDay(y int) time.Time {
+	first := time.Date(y, f.yearStart, 1, 0, 0, 0, 0, time.UTC)
+	offset := (int(f.weekStart) - int(first.Weekday()) + 7) % 7
+	if offset > 3 {
+		offset -= 7
+	}
+	return first.AddDate(0, 0, offset)
+}
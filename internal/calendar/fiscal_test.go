@@ -0,0 +1,129 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+// fiscal builds a fiscal calendar, failing the test on an invalid one
+func fiscal(t *testing.T, cfg config.CalendarConfig) *Fiscal {
+	t.Helper()
+	f, err := FiscalFromConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestFiscalFromConfig(t *testing.T) {
+	for name, cfg := range map[string]config.CalendarConfig{
+		"unknown timezone": {Timezone: "Mars/Olympus_Mons"},
+		"month 13":         {FiscalYearStart: 13},
+		"unknown weekday":  {WeekStart: "funday"},
+		"12 weeks":         {Periods: "4-4-4"},
+		"not a number":     {Periods: "4-x-5"},
+		"two months":       {Periods: "6-7"},
+	} {
+		if _, err := FiscalFromConfig(cfg); err == nil {
+			t.Errorf("%s: FiscalFromConfig() succeeded, want an error", name)
+		}
+	}
+}
+
+func TestToday(t *testing.T) {
+	now := time.Date(2024, 3, 1, 5, 0, 0, 0, time.UTC)
+	tests := []struct {
+		timezone string
+		want     time.Time
+	}{
+		{"", date("2024-03-01")},
+		{"UTC", date("2024-03-01")},
+		{"America/Los_Angeles", date("2024-02-29")},
+		{"Asia/Tokyo", date("2024-03-01")},
+	}
+	for _, tt := range tests {
+		if got := fiscal(t, config.CalendarConfig{Timezone: tt.timezone}).Today(now); !got.Equal(tt.want) {
+			t.Errorf("%q: Today() = %s, want %s", tt.timezone, got, tt.want)
+		}
+	}
+	var f *Fiscal
+	if got := f.Today(now.In(time.FixedZone("PST", -8*3600))); !got.Equal(date("2024-03-01")) {
+		t.Errorf("nil Today() = %s, want the UTC day", got)
+	}
+}
+
+func TestCalendarPeriods(t *testing.T) {
+	tests := []struct {
+		name                 string
+		f                    *Fiscal
+		day                  string
+		month, quarter, year [2]string
+	}{
+		{"nil", nil, "2024-02-29",
+			[2]string{"2024-02-01", "2024-03-01"}, [2]string{"2024-01-01", "2024-04-01"}, [2]string{"2024-01-01", "2025-01-01"}},
+		{"April", fiscal(t, config.CalendarConfig{FiscalYearStart: 4}), "2024-02-29",
+			[2]string{"2024-02-01", "2024-03-01"}, [2]string{"2024-01-01", "2024-04-01"}, [2]string{"2023-04-01", "2024-04-01"}},
+		{"April", fiscal(t, config.CalendarConfig{FiscalYearStart: 4}), "2024-05-15",
+			[2]string{"2024-05-01", "2024-06-01"}, [2]string{"2024-04-01", "2024-07-01"}, [2]string{"2024-04-01", "2025-04-01"}},
+	}
+	for _, tt := range tests {
+		day := date(tt.day)
+		if start, end := tt.f.Month(tt.f.MonthOf(day)); !start.Equal(date(tt.month[0])) || !end.Equal(date(tt.month[1])) {
+			t.Errorf("%s %s: month = %s to %s, want %v", tt.name, tt.day, start, end, tt.month)
+		}
+		if start, end := tt.f.Quarter(day); !start.Equal(date(tt.quarter[0])) || !end.Equal(date(tt.quarter[1])) {
+			t.Errorf("%s %s: quarter = %s to %s, want %v", tt.name, tt.day, start, end, tt.quarter)
+		}
+		if start, end := tt.f.Year(day); !start.Equal(date(tt.year[0])) || !end.Equal(date(tt.year[1])) {
+			t.Errorf("%s %s: year = %s to %s, want %v", tt.name, tt.day, start, end, tt.year)
+		}
+	}
+}
+
+func TestWeekPeriods(t *testing.T) {
+	// 2024 starts on a Monday and has 52 weeks; 2020 starts on Monday
+	// Dec 30, 2019 and has 53, the last month absorbing the extra week
+	f := fiscal(t, config.CalendarConfig{Periods: "4-4-5"})
+	tests := []struct {
+		day, name            string
+		month, quarter, year [2]string
+	}{
+		{"2024-01-01", "2024-01-01",
+			[2]string{"2024-01-01", "2024-01-29"}, [2]string{"2024-01-01", "2024-04-01"}, [2]string{"2024-01-01", "2024-12-30"}},
+		{"2024-01-29", "2024-02-01",
+			[2]string{"2024-01-29", "2024-02-26"}, [2]string{"2024-01-01", "2024-04-01"}, [2]string{"2024-01-01", "2024-12-30"}},
+		{"2024-03-31", "2024-03-01",
+			[2]string{"2024-02-26", "2024-04-01"}, [2]string{"2024-01-01", "2024-04-01"}, [2]string{"2024-01-01", "2024-12-30"}},
+		{"2024-12-29", "2024-12-01",
+			[2]string{"2024-11-25", "2024-12-30"}, [2]string{"2024-09-30", "2024-12-30"}, [2]string{"2024-01-01", "2024-12-30"}},
+		{"2024-12-31", "2025-01-01",
+			[2]string{"2024-12-30", "2025-01-27"}, [2]string{"2024-12-30", "2025-03-31"}, [2]string{"2024-12-30", "2025-12-29"}},
+		{"2019-12-31", "2020-01-01",
+			[2]string{"2019-12-30", "2020-01-27"}, [2]string{"2019-12-30", "2020-03-30"}, [2]string{"2019-12-30", "2021-01-04"}},
+		{"2021-01-02", "2020-12-01",
+			[2]string{"2020-11-23", "2021-01-04"}, [2]string{"2020-09-28", "2021-01-04"}, [2]string{"2019-12-30", "2021-01-04"}},
+	}
+	for _, tt := range tests {
+		day := date(tt.day)
+		if got := f.MonthOf(day); !got.Equal(date(tt.name)) {
+			t.Errorf("MonthOf(%s) = %s, want %s", tt.day, got, tt.name)
+		}
+		if start, end := f.Month(date(tt.name)); !start.Equal(date(tt.month[0])) || !end.Equal(date(tt.month[1])) {
+			t.Errorf("%s: month = %s to %s, want %v", tt.day, start, end, tt.month)
+		}
+		if start, end := f.Quarter(day); !start.Equal(date(tt.quarter[0])) || !end.Equal(date(tt.quarter[1])) {
+			t.Errorf("%s: quarter = %s to %s, want %v", tt.day, start, end, tt.quarter)
+		}
+		if start, end := f.Year(day); !start.Equal(date(tt.year[0])) || !end.Equal(date(tt.year[1])) {
+			t.Errorf("%s: year = %s to %s, want %v", tt.day, start, end, tt.year)
+		}
+	}
+
+	// A Sunday week start moves the year's first day to the nearest Sunday
+	f = fiscal(t, config.CalendarConfig{Periods: "5-4-4", WeekStart: "Sunday", FiscalYearStart: 7})
+	if start, end := f.Month(date("2024-07-01")); !start.Equal(date("2024-06-30")) || !end.Equal(date("2024-08-04")) {
+		t.Errorf("July 2024 = %s to %s, want Jun 30 to Aug 4", start, end)
+	}
+}
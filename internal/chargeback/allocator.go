@@ -4,19 +4,194 @@ package chargeback
 import (
 	"encoding/csv"
 	"fmt"
+	"math"
 	"os"
 	"sort"
 	"time"
 
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/hierarchy"
+	"github.com/lvonguyen/finops-platform/internal/money"
 	"github.com/lvonguyen/finops-platform/internal/normalizer"
 )
 
 // AllocatorConfig holds configuration for cost allocation
 type AllocatorConfig struct {
-	PrimaryTag     string // Primary tag for allocation (e.g., cost_center)
-	FallbackTag    string // Fallback tag if primary missing
-	UntaggedPool   string // Where to allocate untagged costs
+	PrimaryTag      string // Primary tag for allocation (e.g., cost_center)
+	FallbackTag     string // Fallback tag if primary missing
+	UntaggedPool    string // Where to allocate untagged costs
 	SharedCostSplit []SharedCostRule
+	Overrides       []Override // Manual reassignments applied after tag-based allocation
+	Credits         CreditMode
+	CreditPool      string               // Cost center receiving pooled credits, DefaultCreditPool if empty
+	Key             *KeyExpr             // Composite cost center expression, replaces the tags when set
+	Hierarchy       *hierarchy.Hierarchy // Account to org-unit mapping used by Key
+	Basis           CostBasis            // Cost allocated, unblended when empty
+	Commitments     []Commitment         // Upfront purchases amortized by the amortized basis
+	SharedCosts     []SharedCost         // Charges split by driver instead of by tags
+	Drivers         Drivers              // Custom driver values used by SharedCosts
+	Splits          []Split              // Charges divided across cost centers by weight
+}
+
+// CreditMode controls how credits reach cost centers
+type CreditMode string
+
+const (
+	CreditsAsTagged     CreditMode = ""             // credits follow their tags like any other charge
+	CreditsProportional CreditMode = "proportional" // credits reduce each center's bill by its share of gross cost
+	CreditsPooled       CreditMode = "pool"         // credits are held centrally in the credit pool
+)
+
+// DefaultCreditPool is the cost center that holds pooled credits
+const DefaultCreditPool = "CENTRAL-CREDITS"
+
+// Override moves matching charges to another cost center, e.g. to correct a
+// mis-tagged resource without editing its source tags
+type Override struct {
+	ID             string // Reference for audit (ticket, dispute number)
+	Match          RecordMatcher
+	CostCenter     string // Target cost center
+	EffectiveMonth string // YYYY-MM; applies to charges from this month on, empty for always
+	Reason         string
+}
+
+// RecordMatcher selects records; empty fields match anything
+type RecordMatcher struct {
+	Cloud    string
+	Account  string
+	Service  string
+	Resource string
+	Tags     map[string]string
+}
+
+// OverrideEntry is the audit record of an override applied to one charge
+type OverrideEntry struct {
+	OverrideID string    `json:"override_id"`
+	Date       time.Time `json:"date"`
+	Cloud      string    `json:"cloud"`
+	Account    string    `json:"account"`
+	Service    string    `json:"service"`
+	Resource   string    `json:"resource"`
+	From       string    `json:"from"` // automatic cost center, or UntaggedCostCenter
+	To         string    `json:"to"`
+	Amount     float64   `json:"amount"`
+	Reason     string    `json:"reason"`
+}
+
+// UntaggedCostCenter labels charges that tag-based allocation left unassigned
+const UntaggedCostCenter = "UNTAGGED"
+
+// ConfigFrom builds an allocator configuration from settings
+func ConfigFrom(cfg config.ChargebackConfig) (AllocatorConfig, error) {
+	ac := AllocatorConfig{
+		PrimaryTag:   cfg.PrimaryTag,
+		FallbackTag:  cfg.FallbackTag,
+		UntaggedPool: cfg.UntaggedPool,
+		Credits:      CreditMode(cfg.Credits),
+		CreditPool:   cfg.CreditPool,
+		Basis:        CostBasis(cfg.CostBasis),
+	}
+	switch ac.Credits {
+	case CreditsAsTagged, CreditsProportional, CreditsPooled:
+	default:
+		return AllocatorConfig{}, fmt.Errorf("unknown credit mode %q (want proportional or pool)", cfg.Credits)
+	}
+	switch ac.Basis {
+	case "":
+		ac.Basis = BasisUnblended
+	case BasisUnblended, BasisBlended, BasisAmortized:
+	default:
+		return AllocatorConfig{}, fmt.Errorf("unknown cost basis %q (want unblended, blended, or amortized)", cfg.CostBasis)
+	}
+	for _, c := range cfg.Commitments {
+		if c.ID == "" {
+			return AllocatorConfig{}, fmt.Errorf("commitment has no id")
+		}
+		if c.TermMonths <= 0 {
+			return AllocatorConfig{}, fmt.Errorf("commitment %q: term_months must be positive", c.ID)
+		}
+		start, err := time.Parse("2006-01", c.Start)
+		if err != nil {
+			return AllocatorConfig{}, fmt.Errorf("commitment %q: invalid start month %q", c.ID, c.Start)
+		}
+		ac.Commitments = append(ac.Commitments, Commitment{
+			ID:         c.ID,
+			Cloud:      c.Cloud,
+			Account:    c.Account,
+			Upfront:    c.Upfront,
+			MonthlyFee: c.MonthlyFee,
+			Start:      start,
+			TermMonths: c.TermMonths,
+		})
+	}
+	if cfg.AllocationKey != "" {
+		key, err := ParseKeyExpr(cfg.AllocationKey)
+		if err != nil {
+			return AllocatorConfig{}, err
+		}
+		ac.Key = key
+	}
+	var splitTotal float64
+	for _, s := range cfg.SharedCostSplit {
+		if s.CostCenter == "" {
+			return AllocatorConfig{}, fmt.Errorf("shared_cost_split entry has no cost center")
+		}
+		if s.Percentage <= 0 {
+			return AllocatorConfig{}, fmt.Errorf("shared_cost_split %q: percentage must be positive", s.CostCenter)
+		}
+		splitTotal += s.Percentage
+		ac.SharedCostSplit = append(ac.SharedCostSplit, SharedCostRule{CostCenter: s.CostCenter, Percentage: s.Percentage})
+	}
+	if splitTotal > 100+1e-9 {
+		return AllocatorConfig{}, fmt.Errorf("shared_cost_split percentages total %g%%, more than 100%%", splitTotal)
+	}
+	for _, sc := range cfg.SharedCosts {
+		driver := sc.Driver
+		if driver == "" {
+			driver = DriverSpend
+		}
+		ac.SharedCosts = append(ac.SharedCosts, SharedCost{
+			Name: sc.Name,
+			Match: RecordMatcher{
+				Cloud:   sc.Cloud,
+				Account: sc.Account,
+				Service: sc.Service,
+				Tags:    sc.Tags,
+			},
+			Driver: driver,
+		})
+	}
+	for _, sc := range cfg.Splits {
+		split, err := splitFrom(sc)
+		if err != nil {
+			return AllocatorConfig{}, err
+		}
+		ac.Splits = append(ac.Splits, split)
+	}
+	for _, o := range cfg.Overrides {
+		if o.CostCenter == "" {
+			return AllocatorConfig{}, fmt.Errorf("override %q has no target cost center", o.ID)
+		}
+		if o.EffectiveMonth != "" {
+			if _, err := time.Parse("2006-01", o.EffectiveMonth); err != nil {
+				return AllocatorConfig{}, fmt.Errorf("override %q: invalid effective month %q", o.ID, o.EffectiveMonth)
+			}
+		}
+		ac.Overrides = append(ac.Overrides, Override{
+			ID: o.ID,
+			Match: RecordMatcher{
+				Cloud:    o.Cloud,
+				Account:  o.Account,
+				Service:  o.Service,
+				Resource: o.Resource,
+				Tags:     o.Tags,
+			},
+			CostCenter:     o.CostCenter,
+			EffectiveMonth: o.EffectiveMonth,
+			Reason:         o.Reason,
+		})
+	}
+	return ac, nil
 }
 
 // SharedCostRule defines how to split shared costs
@@ -27,18 +202,35 @@ type SharedCostRule struct {
 
 // Allocation represents allocated costs for a cost center
 type Allocation struct {
-	CostCenter   string             `json:"cost_center"`
-	TotalCost    float64            `json:"total_cost"`
-	DirectCost   float64            `json:"direct_cost"`   // Directly tagged
-	AllocatedCost float64           `json:"allocated_cost"` // Allocated from shared
-	ByCloud      map[string]float64 `json:"by_cloud"`
-	ByService    map[string]float64 `json:"by_service"`
-	Records      []normalizer.CostRecord `json:"-"`
+	CostCenter      string                  `json:"cost_center"`
+	TotalCost       float64                 `json:"total_cost"` // net of credits
+	GrossCost       float64                 `json:"gross_cost"`
+	Credits         float64                 `json:"credits"`                 // credits applied, as a positive amount
+	Currency        string                  `json:"currency,omitempty"`      // billing currency
+	ExchangeRate    float64                 `json:"exchange_rate,omitempty"` // base to billing currency
+	LocalTotal      float64                 `json:"local_total,omitempty"`   // TotalCost in the billing currency
+	DirectCost      float64                 `json:"direct_cost"`             // Directly tagged
+	AllocatedCost   float64                 `json:"allocated_cost"`          // Allocated from shared
+	ByCloud         map[string]float64      `json:"by_cloud"`
+	ByService       map[string]float64      `json:"by_service"`
+	ByPricing       map[string]float64      `json:"by_pricing"`        // direct charges by pricing model, "" when unknown
+	SharedByService map[string]float64      `json:"shared_by_service"` // allocated shared cost per service
+	EmissionsKg     float64                 `json:"emissions_kg"`      // estimated kg CO2e, direct plus shared
+	Records         []normalizer.CostRecord `json:"-"`
+
+	// Month-over-month change, set when the report has a previous period
+	PreviousCost  float64 `json:"previous_cost,omitempty"`
+	Change        float64 `json:"change,omitempty"`
+	PercentChange float64 `json:"percent_change,omitempty"` // 0 when there was no previous spend
 }
 
 // Allocator performs tag-based cost allocation
 type Allocator struct {
-	config AllocatorConfig
+	config     AllocatorConfig
+	applied    []OverrideEntry
+	unresolved map[string]*UnresolvedKey
+	untagged   float64
+	untaggedBy map[string]*UntaggedCharge
 }
 
 // NewAllocator creates a new cost allocator
@@ -46,43 +238,227 @@ func NewAllocator(cfg AllocatorConfig) *Allocator {
 	return &Allocator{config: cfg}
 }
 
-// Allocate distributes costs to cost centers based on tags
+// Allocate distributes costs to cost centers based on tags, at the
+// configured cost basis
 func (a *Allocator) Allocate(records []normalizer.CostRecord) map[string]*Allocation {
+	records = costView(a.config, records)
 	allocations := make(map[string]*Allocation)
-	var untaggedCosts []normalizer.CostRecord
-
+	var untaggedCosts, credits []normalizer.CostRecord
+	shared := make([][]normalizer.CostRecord, len(a.config.SharedCosts))
+	a.applied = nil
+	a.unresolved = nil
+	a.untagged = 0
+	a.untaggedBy = make(map[string]*UntaggedCharge)
+
+records:
 	for _, r := range records {
+		if a.config.Credits != CreditsAsTagged && r.IsCredit() {
+			credits = append(credits, r)
+			continue
+		}
+
+		for i, sc := range a.config.SharedCosts {
+			if sc.Match.Matches(r) {
+				shared[i] = append(shared[i], r)
+				continue records
+			}
+		}
+
 		costCenter := a.getCostCenter(r)
+		if !a.overridden(r) {
+			if shares := a.shares(r, costCenter); shares != nil {
+				for i, part := range splitRecord(r, shares) {
+					addDirect(allocations, shares[i].CostCenter, part)
+				}
+				continue
+			}
+		}
+		costCenter = a.applyOverride(r, costCenter)
 
 		if costCenter == "" {
 			untaggedCosts = append(untaggedCosts, r)
+			a.untagged = money.Add(a.untagged, r.Cost)
+			a.trackUntagged(r)
 			continue
 		}
+		addDirect(allocations, costCenter, r)
+	}
 
-		if _, exists := allocations[costCenter]; !exists {
-			allocations[costCenter] = &Allocation{
-				CostCenter: costCenter,
-				ByCloud:    make(map[string]float64),
-				ByService:  make(map[string]float64),
-			}
+	// Split shared costs by their drivers, then handle untagged costs
+	for _, r := range a.allocateShared(allocations, shared) {
+		untaggedCosts = append(untaggedCosts, r)
+		a.untagged = money.Add(a.untagged, r.Cost)
+		a.trackUntagged(r)
+	}
+	a.allocateUntagged(allocations, untaggedCosts)
+
+	a.applyCredits(allocations, credits)
+
+	return allocations
+}
+
+// addDirect charges a record to a cost center directly
+func addDirect(allocations map[string]*Allocation, costCenter string, r normalizer.CostRecord) {
+	if _, exists := allocations[costCenter]; !exists {
+		allocations[costCenter] = newAllocation(costCenter)
+	}
+
+	alloc := allocations[costCenter]
+	alloc.TotalCost = money.Add(alloc.TotalCost, r.Cost)
+	alloc.DirectCost = money.Add(alloc.DirectCost, r.Cost)
+	alloc.ByCloud[r.Cloud] = money.Add(alloc.ByCloud[r.Cloud], r.Cost)
+	alloc.ByService[r.Service] = money.Add(alloc.ByService[r.Service], r.Cost)
+	alloc.ByPricing[r.PricingModel] = money.Add(alloc.ByPricing[r.PricingModel], r.Cost)
+	alloc.EmissionsKg += r.EmissionsKg
+	alloc.Records = append(alloc.Records, r)
+}
+
+// UntaggedCost returns the cost the last Allocate call found no cost center
+// for, before it was pooled or distributed
+func (a *Allocator) UntaggedCost() float64 {
+	return a.untagged
+}
+
+// UntaggedCharge totals the untagged charges of one cloud, account and
+// service
+type UntaggedCharge struct {
+	Cloud   string  `json:"cloud"`
+	Account string  `json:"account"`
+	Service string  `json:"service"`
+	Records int     `json:"records"`
+	Cost    float64 `json:"cost"`
+}
+
+func (a *Allocator) trackUntagged(r normalizer.CostRecord) {
+	k := r.Cloud + "|" + r.Account + "|" + r.Service
+	u, ok := a.untaggedBy[k]
+	if !ok {
+		u = &UntaggedCharge{Cloud: r.Cloud, Account: r.Account, Service: r.Service}
+		a.untaggedBy[k] = u
+	}
+	u.Records++
+	u.Cost = money.Add(u.Cost, r.Cost)
+}
+
+// UntaggedCharges breaks down UntaggedCost by cloud, account and service,
+// largest first
+func (a *Allocator) UntaggedCharges() []UntaggedCharge {
+	result := make([]UntaggedCharge, 0, len(a.untaggedBy))
+	for _, u := range a.untaggedBy {
+		result = append(result, *u)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Cost != result[j].Cost {
+			return result[i].Cost > result[j].Cost
 		}
+		return result[i].Account+result[i].Service < result[j].Account+result[j].Service
+	})
+	return result
+}
 
-		alloc := allocations[costCenter]
-		alloc.TotalCost += r.Cost
-		alloc.DirectCost += r.Cost
-		alloc.ByCloud[r.Cloud] += r.Cost
-		alloc.ByService[r.Service] += r.Cost
-		alloc.Records = append(alloc.Records, r)
+// AppliedOverrides returns the audit log of overrides applied by the last
+// Allocate call
+func (a *Allocator) AppliedOverrides() []OverrideEntry {
+	return a.applied
+}
+
+// applyOverride returns the target of the first override matching a record,
+// logging the move, or the automatically allocated cost center when no
+// override matches. An override to the center the record already has ends
+// the search without a move to log.
+func (a *Allocator) applyOverride(r normalizer.CostRecord, costCenter string) string {
+	for _, o := range a.config.Overrides {
+		if !o.appliesTo(r) {
+			continue
+		}
+		if o.CostCenter == costCenter {
+			return costCenter
+		}
+
+		from := costCenter
+		if from == "" {
+			from = UntaggedCostCenter
+		}
+		a.applied = append(a.applied, OverrideEntry{
+			OverrideID: o.ID,
+			Date:       r.Date,
+			Cloud:      r.Cloud,
+			Account:    r.Account,
+			Service:    r.Service,
+			Resource:   r.Resource,
+			From:       from,
+			To:         o.CostCenter,
+			Amount:     r.Cost,
+			Reason:     o.Reason,
+		})
+		return o.CostCenter
 	}
+	return costCenter
+}
 
-	// Handle untagged costs
-	a.allocateUntagged(allocations, untaggedCosts)
+// appliesTo reports whether the override covers a record
+func (o Override) appliesTo(r normalizer.CostRecord) bool {
+	if o.EffectiveMonth != "" && r.Date.Format("2006-01") < o.EffectiveMonth {
+		return false
+	}
+	return o.Match.Matches(r)
+}
 
-	return allocations
+// Matches reports whether every non-empty matcher field equals the record's
+func (m RecordMatcher) Matches(r normalizer.CostRecord) bool {
+	if m.Cloud != "" && m.Cloud != r.Cloud {
+		return false
+	}
+	if m.Account != "" && m.Account != r.Account {
+		return false
+	}
+	if m.Service != "" && m.Service != r.Service && m.Service != r.CloudService {
+		return false
+	}
+	if m.Resource != "" && m.Resource != r.Resource {
+		return false
+	}
+	for k, v := range m.Tags {
+		if r.Tags[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// CostCenter returns the cost center a record is charged to directly, after
+// overrides, or "" when it is untagged or a shared cost. Shared and untagged
+// costs are not split, a split charge is attributed to its largest share,
+// and the override audit log is left untouched.
+func (a *Allocator) CostCenter(r normalizer.CostRecord) string {
+	for _, sc := range a.config.SharedCosts {
+		if sc.Match.Matches(r) {
+			return ""
+		}
+	}
+	for _, o := range a.config.Overrides {
+		if o.appliesTo(r) {
+			return o.CostCenter
+		}
+	}
+	costCenter := a.getCostCenter(r)
+	if shares := a.shares(r, costCenter); shares != nil {
+		return shares[0].CostCenter
+	}
+	return costCenter
 }
 
-// getCostCenter extracts the cost center from a record's tags
+// getCostCenter extracts the cost center from a record's tags, or from the
+// allocation key when one is configured
 func (a *Allocator) getCostCenter(r normalizer.CostRecord) string {
+	if a.config.Key != nil {
+		cc, missing := a.config.Key.Resolve(r, a.config.Hierarchy)
+		if len(missing) > 0 {
+			a.trackUnresolved(r, missing)
+		}
+		return cc
+	}
+
 	// Try primary tag
 	if cc, ok := r.Tags[a.config.PrimaryTag]; ok && cc != "" {
 		return cc
@@ -102,86 +478,196 @@ func (a *Allocator) allocateUntagged(allocations map[string]*Allocation, untagge
 		return
 	}
 
-	// Calculate total untagged cost
-	var totalUntagged float64
+	// Calculate total untagged cost; emissions follow the cost
+	var totalUntagged, untaggedEmissions float64
+	untaggedByService := make(map[string]float64)
 	for _, r := range untagged {
-		totalUntagged += r.Cost
+		totalUntagged = money.Add(totalUntagged, r.Cost)
+		untaggedEmissions += r.EmissionsKg
+		untaggedByService[r.Service] = money.Add(untaggedByService[r.Service], r.Cost)
 	}
 
 	// If we have shared cost rules, use them
 	if len(a.config.SharedCostSplit) > 0 {
+		// The rules' shares and the remainder add up to the untagged total
 		remainingPct := 100.0
-
+		weights := make([]float64, 0, len(a.config.SharedCostSplit)+1)
 		for _, rule := range a.config.SharedCostSplit {
+			weights = append(weights, rule.Percentage)
+			remainingPct -= rule.Percentage
+		}
+		amounts := money.Split(totalUntagged, append(weights, max(remainingPct, 0)))
+
+		for i, rule := range a.config.SharedCostSplit {
 			if _, exists := allocations[rule.CostCenter]; !exists {
-				allocations[rule.CostCenter] = &Allocation{
-					CostCenter: rule.CostCenter,
-					ByCloud:    make(map[string]float64),
-					ByService:  make(map[string]float64),
-				}
+				allocations[rule.CostCenter] = newAllocation(rule.CostCenter)
 			}
 
-			allocated := totalUntagged * (rule.Percentage / 100)
-			allocations[rule.CostCenter].AllocatedCost += allocated
-			allocations[rule.CostCenter].TotalCost += allocated
-			remainingPct -= rule.Percentage
+			alloc := allocations[rule.CostCenter]
+			alloc.AllocatedCost = money.Add(alloc.AllocatedCost, amounts[i])
+			alloc.TotalCost = money.Add(alloc.TotalCost, amounts[i])
+			alloc.addShared(untaggedByService, rule.Percentage/100)
+			alloc.EmissionsKg += untaggedEmissions * rule.Percentage / 100
 		}
 
 		// Distribute remaining proportionally
 		if remainingPct > 0 {
-			a.distributeProportionally(allocations, totalUntagged*(remainingPct/100))
+			a.distributeProportionally(allocations, amounts[len(amounts)-1], untaggedByService, untaggedEmissions)
 		}
 	} else if a.config.UntaggedPool != "" {
 		// Allocate all to untagged pool
 		if _, exists := allocations[a.config.UntaggedPool]; !exists {
-			allocations[a.config.UntaggedPool] = &Allocation{
-				CostCenter: a.config.UntaggedPool,
-				ByCloud:    make(map[string]float64),
-				ByService:  make(map[string]float64),
-			}
+			allocations[a.config.UntaggedPool] = newAllocation(a.config.UntaggedPool)
 		}
-		allocations[a.config.UntaggedPool].TotalCost += totalUntagged
-		allocations[a.config.UntaggedPool].AllocatedCost += totalUntagged
+		pool := allocations[a.config.UntaggedPool]
+		pool.TotalCost = money.Add(pool.TotalCost, totalUntagged)
+		pool.AllocatedCost = money.Add(pool.AllocatedCost, totalUntagged)
+		pool.EmissionsKg += untaggedEmissions
 
 		for _, r := range untagged {
-			allocations[a.config.UntaggedPool].ByCloud[r.Cloud] += r.Cost
-			allocations[a.config.UntaggedPool].ByService[r.Service] += r.Cost
+			pool.ByCloud[r.Cloud] = money.Add(pool.ByCloud[r.Cloud], r.Cost)
+			pool.ByService[r.Service] = money.Add(pool.ByService[r.Service], r.Cost)
 		}
 	} else {
 		// Distribute proportionally to existing cost centers
-		a.distributeProportionally(allocations, totalUntagged)
+		a.distributeProportionally(allocations, totalUntagged, untaggedByService, untaggedEmissions)
 	}
 }
 
-// distributeProportionally allocates costs based on existing spend
-func (a *Allocator) distributeProportionally(allocations map[string]*Allocation, amount float64) {
+// distributeProportionally allocates costs based on existing spend, the
+// centers' shares adding up to amount. The shared emissions move with the
+// same share of byService.
+func (a *Allocator) distributeProportionally(allocations map[string]*Allocation, amount float64, byService map[string]float64, emissions float64) {
+	centers := sortedCenters(allocations)
+	weights := make([]float64, len(centers))
 	var totalDirect float64
-	for _, alloc := range allocations {
-		totalDirect += alloc.DirectCost
+	for i, center := range centers {
+		weights[i] = allocations[center].DirectCost
+		totalDirect = money.Add(totalDirect, weights[i])
 	}
 
 	if totalDirect == 0 {
 		return
 	}
 
-	for _, alloc := range allocations {
-		proportion := alloc.DirectCost / totalDirect
-		allocated := amount * proportion
-		alloc.AllocatedCost += allocated
-		alloc.TotalCost += allocated
+	var totalShared float64
+	for _, cost := range byService {
+		totalShared = money.Add(totalShared, cost)
+	}
+
+	for i, allocated := range money.Split(amount, weights) {
+		alloc := allocations[centers[i]]
+		alloc.AllocatedCost = money.Add(alloc.AllocatedCost, allocated)
+		alloc.TotalCost = money.Add(alloc.TotalCost, allocated)
+		if totalShared != 0 {
+			alloc.addShared(byService, allocated/totalShared)
+			alloc.EmissionsKg += emissions * allocated / totalShared
+		}
+	}
+}
+
+// applyCredits records each center's gross cost, then reduces it by its
+// credits. A center is never credited more than its gross cost; any excess
+// goes to the credit pool.
+func (a *Allocator) applyCredits(allocations map[string]*Allocation, credits []normalizer.CostRecord) {
+	var centers []string
+	var gross []float64
+	for _, center := range sortedCenters(allocations) {
+		alloc := allocations[center]
+		alloc.GrossCost = alloc.TotalCost
+		if alloc.GrossCost > 0 {
+			centers = append(centers, center)
+			gross = append(gross, alloc.GrossCost)
+		}
+	}
+
+	var totalCredits float64
+	for _, r := range credits {
+		totalCredits = money.Add(totalCredits, math.Abs(r.Cost))
+	}
+	if totalCredits == 0 {
+		return
+	}
+
+	remaining := totalCredits
+	if a.config.Credits == CreditsProportional && len(centers) > 0 {
+		for i, share := range money.Split(totalCredits, gross) {
+			alloc := allocations[centers[i]]
+			share = math.Min(share, alloc.GrossCost)
+			alloc.Credits = money.Add(alloc.Credits, share)
+			alloc.TotalCost = money.Sub(alloc.TotalCost, share)
+			remaining = money.Sub(remaining, share)
+		}
+	}
+
+	if remaining <= 0.005 {
+		return
+	}
+
+	pool := a.config.CreditPool
+	if pool == "" {
+		pool = DefaultCreditPool
+	}
+	if _, exists := allocations[pool]; !exists {
+		allocations[pool] = newAllocation(pool)
+	}
+	allocations[pool].Credits = money.Add(allocations[pool].Credits, remaining)
+	allocations[pool].TotalCost = money.Sub(allocations[pool].TotalCost, remaining)
+}
+
+// sortedCenters returns the cost centers of allocations in name order, so
+// that rounding remainders land on the same center every run
+func sortedCenters(allocations map[string]*Allocation) []string {
+	centers := make([]string, 0, len(allocations))
+	for center := range allocations {
+		centers = append(centers, center)
+	}
+	sort.Strings(centers)
+	return centers
+}
+
+func newAllocation(costCenter string) *Allocation {
+	return &Allocation{
+		CostCenter:      costCenter,
+		ByCloud:         make(map[string]float64),
+		ByService:       make(map[string]float64),
+		ByPricing:       make(map[string]float64),
+		SharedByService: make(map[string]float64),
+	}
+}
+
+// addShared records a fraction of the shared per-service costs
+func (alloc *Allocation) addShared(byService map[string]float64, fraction float64) {
+	for service, cost := range byService {
+		alloc.SharedByService[service] = money.Add(alloc.SharedByService[service], money.Mul(cost, fraction))
 	}
 }
 
 // Report holds a generated chargeback report
 type Report struct {
-	Month       string
-	Allocations []*Allocation
-	TotalCost   float64
-	Generated   time.Time
+	Month        string
+	Allocations  []*Allocation
+	TotalCost    float64
+	BaseCurrency string // currency of TotalCost and every allocation's TotalCost
+	Generated    time.Time
+	Overrides    []OverrideEntry
+	Rates        []BlendedRate
+	Unresolved   []UnresolvedKey // charges whose allocation key could not be resolved
+	Columns      []string        // CSV columns, DefaultColumns when empty
+
+	// Untagged breaks down the charges allocated as untagged costs
+	Untagged []UntaggedCharge
+
+	// Previous period the allocations are compared with, empty when none
+	PreviousMonth string
+	PreviousTotal float64
+	Ended         []*Allocation // previous allocations of centers with no spend this month
 }
 
-// GenerateReport creates a chargeback report from allocations
-func GenerateReport(allocations map[string]*Allocation, month string) *Report {
+// GenerateReport creates a chargeback report from allocations. When the
+// previous month's allocations are given, each center's change from that
+// month is included.
+func GenerateReport(allocations, previous map[string]*Allocation, month string) *Report {
 	report := &Report{
 		Month:     month,
 		Generated: time.Now(),
@@ -189,19 +675,93 @@ func GenerateReport(allocations map[string]*Allocation, month string) *Report {
 
 	for _, alloc := range allocations {
 		report.Allocations = append(report.Allocations, alloc)
-		report.TotalCost += alloc.TotalCost
+		report.TotalCost = money.Add(report.TotalCost, alloc.TotalCost)
 	}
 
 	// Sort by cost descending
 	sort.Slice(report.Allocations, func(i, j int) bool {
 		return report.Allocations[i].TotalCost > report.Allocations[j].TotalCost
 	})
+	report.Rates = BlendedRates(report.Allocations)
+	if previous != nil {
+		report.compare(previous)
+	}
 
 	return report
 }
 
-// SaveCSV saves the report as a CSV file
+// compare records each center's month-over-month change
+func (r *Report) compare(previous map[string]*Allocation) {
+	if t, err := time.Parse("2006-01", r.Month); err == nil {
+		r.PreviousMonth = t.AddDate(0, -1, 0).Format("2006-01")
+	}
+
+	current := make(map[string]bool, len(r.Allocations))
+	for _, alloc := range r.Allocations {
+		current[alloc.CostCenter] = true
+		if prev, ok := previous[alloc.CostCenter]; ok {
+			alloc.PreviousCost = prev.TotalCost
+		}
+		alloc.Change = money.Sub(alloc.TotalCost, alloc.PreviousCost)
+		if alloc.PreviousCost > 0 {
+			alloc.PercentChange = alloc.Change / alloc.PreviousCost * 100
+		}
+	}
+
+	for center, prev := range previous {
+		r.PreviousTotal = money.Add(r.PreviousTotal, prev.TotalCost)
+		if !current[center] && prev.TotalCost != 0 {
+			r.Ended = append(r.Ended, prev)
+		}
+	}
+	sort.Slice(r.Ended, func(i, j int) bool { return r.Ended[i].TotalCost > r.Ended[j].TotalCost })
+}
+
+// SaveOverridesCSV writes the override audit log as a CSV file
+func (r *Report) SaveOverridesCSV(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	header := []string{"Override", "Date", "Cloud", "Account", "Service", "Resource", "From", "To", "Amount", "Reason"}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, o := range r.Overrides {
+		row := []string{
+			o.OverrideID,
+			o.Date.Format("2006-01-02"),
+			o.Cloud,
+			o.Account,
+			o.Service,
+			o.Resource,
+			o.From,
+			o.To,
+			fmt.Sprintf("%.2f", o.Amount),
+			o.Reason,
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SaveCSV saves the report as a CSV file with the report's columns, or
+// DefaultColumns when none are set
 func (r *Report) SaveCSV(path string) error {
+	cols, err := r.columns()
+	if err != nil {
+		return err
+	}
+
 	file, err := os.Create(path)
 	if err != nil {
 		return fmt.Errorf("failed to create file: %w", err)
@@ -212,23 +772,19 @@ func (r *Report) SaveCSV(path string) error {
 	defer writer.Flush()
 
 	// Header
-	header := []string{"Cost Center", "Total Cost", "Direct Cost", "Allocated Cost", "AWS", "Azure", "GCP", "% of Total"}
+	header := make([]string, len(cols))
+	for i, c := range cols {
+		header[i] = c.header
+	}
 	if err := writer.Write(header); err != nil {
 		return err
 	}
 
 	// Data rows
 	for _, alloc := range r.Allocations {
-		pct := (alloc.TotalCost / r.TotalCost) * 100
-		row := []string{
-			alloc.CostCenter,
-			fmt.Sprintf("%.2f", alloc.TotalCost),
-			fmt.Sprintf("%.2f", alloc.DirectCost),
-			fmt.Sprintf("%.2f", alloc.AllocatedCost),
-			fmt.Sprintf("%.2f", alloc.ByCloud["aws"]),
-			fmt.Sprintf("%.2f", alloc.ByCloud["azure"]),
-			fmt.Sprintf("%.2f", alloc.ByCloud["gcp"]),
-			fmt.Sprintf("%.1f%%", pct),
+		row := make([]string, len(cols))
+		for i, c := range cols {
+			row[i] = c.value(alloc)
 		}
 		if err := writer.Write(row); err != nil {
 			return err
@@ -236,12 +792,28 @@ func (r *Report) SaveCSV(path string) error {
 	}
 
 	// Total row
-	totalRow := []string{
-		"TOTAL",
-		fmt.Sprintf("%.2f", r.TotalCost),
-		"", "", "", "", "",
-		"100.0%",
+	totalRow := make([]string, len(cols))
+	for i, c := range cols {
+		totalRow[i] = c.total
 	}
 	return writer.Write(totalRow)
 }
 
+// emissions formats an emissions estimate, empty when the report has none
+func (r *Report) emissions(kg float64) string {
+	for _, alloc := range r.Allocations {
+		if alloc.EmissionsKg != 0 {
+			return fmt.Sprintf("%.2f", kg)
+		}
+	}
+	return ""
+}
+
+// localAmount formats the allocation in its billing currency, empty when
+// currencies were not applied
+func localAmount(alloc *Allocation) string {
+	if alloc.Currency == "" {
+		return ""
+	}
+	return fmt.Sprintf("%.2f", alloc.LocalTotal)
+}
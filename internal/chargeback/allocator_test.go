@@ -0,0 +1,261 @@
+package chargeback
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+var month = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+// record returns a usage charge tagged with a cost center
+func record(costCenter, service string, cost float64) normalizer.CostRecord {
+	r := normalizer.CostRecord{
+		Cloud:    "aws",
+		Account:  "111",
+		Service:  service,
+		Cost:     cost,
+		Currency: "USD",
+		Date:     month,
+		Tags:     map[string]string{},
+	}
+	if costCenter != "" {
+		r.Tags["cost_center"] = costCenter
+	}
+	return r
+}
+
+func TestChargebackTotalsTenthCents(t *testing.T) {
+	centers := []string{"CC-1", "CC-2", "CC-3"}
+	records := make([]normalizer.CostRecord, 30000)
+	for i := range records {
+		records[i] = record(centers[i%len(centers)], fmt.Sprintf("svc-%d", i%7), 0.001)
+	}
+
+	a := NewAllocator(AllocatorConfig{PrimaryTag: "cost_center"})
+	report := GenerateReport(a.Allocate(records), nil, "2024-03")
+	if report.TotalCost != 30 {
+		t.Errorf("TotalCost = %v, want 30", report.TotalCost)
+	}
+	for _, alloc := range report.Allocations {
+		if alloc.TotalCost != 10 || alloc.DirectCost != 10 {
+			t.Errorf("%s: TotalCost = %v, DirectCost = %v, want 10", alloc.CostCenter, alloc.TotalCost, alloc.DirectCost)
+		}
+	}
+}
+
+func TestApplyOverridePrecedence(t *testing.T) {
+	web := RecordMatcher{Service: "EC2"}
+	resource := RecordMatcher{Service: "EC2", Resource: "i-123"}
+	tests := []struct {
+		name      string
+		overrides []Override
+		tagged    string
+		resource  string
+		want      string
+		applied   []string // IDs of the overrides logged
+	}{
+		{"no overrides", nil, "CC-1", "i-123", "CC-1", nil},
+		{"no match", []Override{{ID: "o1", Match: RecordMatcher{Service: "S3"}, CostCenter: "CC-2"}}, "CC-1", "i-123", "CC-1", nil},
+		{"moves", []Override{{ID: "o1", Match: web, CostCenter: "CC-2"}}, "CC-1", "i-123", "CC-2", []string{"o1"}},
+		{"first match wins", []Override{
+			{ID: "o1", Match: resource, CostCenter: "CC-2"},
+			{ID: "o2", Match: web, CostCenter: "CC-3"},
+		}, "CC-1", "i-123", "CC-2", []string{"o1"}},
+		{"later override for other resources", []Override{
+			{ID: "o1", Match: resource, CostCenter: "CC-2"},
+			{ID: "o2", Match: web, CostCenter: "CC-3"},
+		}, "CC-1", "i-456", "CC-3", []string{"o2"}},
+		{"match to own center ends search", []Override{
+			{ID: "o1", Match: resource, CostCenter: "CC-1"},
+			{ID: "o2", Match: web, CostCenter: "CC-3"},
+		}, "CC-1", "i-123", "CC-1", nil},
+		{"untagged", []Override{{ID: "o1", Match: web, CostCenter: "CC-2"}}, "", "i-123", "CC-2", []string{"o1"}},
+		{"not yet effective", []Override{{ID: "o1", Match: web, CostCenter: "CC-2", EffectiveMonth: "2024-04"}}, "CC-1", "i-123", "CC-1", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewAllocator(AllocatorConfig{PrimaryTag: "cost_center", Overrides: tt.overrides})
+			r := record(tt.tagged, "EC2", 5)
+			r.Resource = tt.resource
+			if got := a.applyOverride(r, tt.tagged); got != tt.want {
+				t.Errorf("cost center = %q, want %q", got, tt.want)
+			}
+			var applied []string
+			for _, e := range a.AppliedOverrides() {
+				applied = append(applied, e.OverrideID)
+			}
+			if fmt.Sprint(applied) != fmt.Sprint(tt.applied) {
+				t.Errorf("applied = %v, want %v", applied, tt.applied)
+			}
+		})
+	}
+}
+
+func TestCostCenter(t *testing.T) {
+	a := NewAllocator(AllocatorConfig{
+		PrimaryTag:  "cost_center",
+		FallbackTag: "team",
+		Overrides: []Override{
+			{ID: "o1", Match: RecordMatcher{Service: "RDS"}, CostCenter: "CC-9"},
+			{ID: "o2", Match: RecordMatcher{Service: "S3"}, CostCenter: "CC-8", EffectiveMonth: "2024-04"},
+		},
+		SharedCosts: []SharedCost{{Name: "support", Match: RecordMatcher{Service: "Support"}, Driver: DriverSpend}},
+	})
+	fallback := record("", "EC2", 1)
+	fallback.Tags["team"] = "CC-3"
+	tests := []struct {
+		name string
+		r    normalizer.CostRecord
+		want string
+	}{
+		{"tagged", record("CC-1", "EC2", 1), "CC-1"},
+		{"fallback tag", fallback, "CC-3"},
+		{"override", record("CC-1", "RDS", 1), "CC-9"},
+		{"override not yet effective", record("CC-1", "S3", 1), "CC-1"},
+		{"shared cost", record("CC-1", "Support", 1), ""},
+		{"untagged", record("", "EC2", 1), ""},
+	}
+	for _, tt := range tests {
+		if got := a.CostCenter(tt.r); got != tt.want {
+			t.Errorf("%s: CostCenter() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+	if n := len(a.AppliedOverrides()); n != 0 {
+		t.Errorf("logged %d overrides, want CostCenter to leave the log alone", n)
+	}
+}
+
+func TestAllocateCredits(t *testing.T) {
+	credit := func(cost float64) normalizer.CostRecord {
+		r := record("CC-1", "EC2", cost)
+		r.ChargeType = normalizer.ChargeCredit
+		return r
+	}
+	type totals struct{ gross, credits, net float64 }
+	tests := []struct {
+		name   string
+		mode   CreditMode
+		credit float64
+		want   map[string]totals
+	}{
+		{"as tagged", CreditsAsTagged, -40, map[string]totals{
+			"CC-1": {260, 0, 260},
+			"CC-2": {100, 0, 100},
+		}},
+		{"proportional", CreditsProportional, -40, map[string]totals{
+			"CC-1": {300, 30, 270},
+			"CC-2": {100, 10, 90},
+		}},
+		{"proportional excess pooled", CreditsProportional, -500, map[string]totals{
+			"CC-1":            {300, 300, 0},
+			"CC-2":            {100, 100, 0},
+			DefaultCreditPool: {0, 100, -100},
+		}},
+		{"pooled", CreditsPooled, -40, map[string]totals{
+			"CC-1":            {300, 0, 300},
+			"CC-2":            {100, 0, 100},
+			DefaultCreditPool: {0, 40, -40},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewAllocator(AllocatorConfig{PrimaryTag: "cost_center", Credits: tt.mode})
+			allocations := a.Allocate([]normalizer.CostRecord{
+				record("CC-1", "EC2", 300),
+				record("CC-2", "S3", 100),
+				credit(tt.credit),
+			})
+			if len(allocations) != len(tt.want) {
+				t.Fatalf("got %d cost centers, want %d", len(allocations), len(tt.want))
+			}
+			for center, want := range tt.want {
+				alloc := allocations[center]
+				if alloc == nil {
+					t.Fatalf("no allocation for %s", center)
+				}
+				if got := (totals{alloc.GrossCost, alloc.Credits, alloc.TotalCost}); got != want {
+					t.Errorf("%s: gross, credits, net = %v, want %v", center, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestConfigFromRejectsUnknownCreditMode(t *testing.T) {
+	if _, err := ConfigFrom(config.ChargebackConfig{PrimaryTag: "cost_center", Credits: "spread"}); err == nil {
+		t.Error("credit mode spread accepted")
+	}
+}
+
+// totalled returns allocations with the given net totals
+func totalled(totals map[string]float64) map[string]*Allocation {
+	allocations := make(map[string]*Allocation, len(totals))
+	for center, total := range totals {
+		allocations[center] = &Allocation{CostCenter: center, TotalCost: total}
+	}
+	return allocations
+}
+
+func TestGenerateReportMonthOverMonth(t *testing.T) {
+	report := GenerateReport(
+		totalled(map[string]float64{"CC-1": 150, "CC-2": 50, "CC-3": 20}),
+		totalled(map[string]float64{"CC-1": 100, "CC-2": 100, "CC-4": 30, "CC-5": 0}),
+		"2024-01",
+	)
+	if report.PreviousMonth != "2023-12" || report.PreviousTotal != 230 {
+		t.Errorf("previous = %s, %v; want 2023-12, 230", report.PreviousMonth, report.PreviousTotal)
+	}
+
+	tests := []struct {
+		center                          string
+		previous, change, percentChange float64
+	}{
+		{"CC-1", 100, 50, 50},
+		{"CC-2", 100, -50, -50},
+		{"CC-3", 0, 20, 0}, // new this month
+	}
+	for i, tt := range tests {
+		alloc := report.Allocations[i]
+		if alloc.CostCenter != tt.center || alloc.PreviousCost != tt.previous || alloc.Change != tt.change || alloc.PercentChange != tt.percentChange {
+			t.Errorf("allocation %d = %s %v, %v, %v%%; want %s %v, %v, %v%%", i, alloc.CostCenter, alloc.PreviousCost, alloc.Change, alloc.PercentChange,
+				tt.center, tt.previous, tt.change, tt.percentChange)
+		}
+	}
+	// Centers that stopped spending are listed, those that never did are not
+	if len(report.Ended) != 1 || report.Ended[0].CostCenter != "CC-4" {
+		t.Errorf("Ended = %+v, want CC-4", report.Ended)
+	}
+
+	if report := GenerateReport(totalled(map[string]float64{"CC-1": 150}), nil, "2024-01"); report.PreviousMonth != "" || report.Allocations[0].Change != 0 {
+		t.Errorf("report without a previous month compares with %q", report.PreviousMonth)
+	}
+}
+
+func TestConfigFromSharedCostSplit(t *testing.T) {
+	ac, err := ConfigFrom(config.ChargebackConfig{PrimaryTag: "cost_center", SharedCostSplit: []config.SharedCostSplit{
+		{CostCenter: "CC-1", Percentage: 60},
+		{CostCenter: "CC-2", Percentage: 40},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []SharedCostRule{{"CC-1", 60}, {"CC-2", 40}}; !reflect.DeepEqual(ac.SharedCostSplit, want) {
+		t.Errorf("SharedCostSplit = %+v, want %+v", ac.SharedCostSplit, want)
+	}
+
+	for name, split := range map[string][]config.SharedCostSplit{
+		"no cost center": {{Percentage: 10}},
+		"zero percent":   {{CostCenter: "CC-1"}},
+		"over 100%":      {{CostCenter: "CC-1", Percentage: 70}, {CostCenter: "CC-2", Percentage: 30.5}},
+		"negative":       {{CostCenter: "CC-1", Percentage: -10}},
+	} {
+		if _, err := ConfigFrom(config.ChargebackConfig{PrimaryTag: "cost_center", SharedCostSplit: split}); err == nil {
+			t.Errorf("%s: ConfigFrom() succeeded, want an error", name)
+		}
+	}
+}
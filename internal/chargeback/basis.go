@@ -0,0 +1,186 @@
+package chargeback
+
+import (
+	"sort"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/money"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+// CostBasis selects which cost of each charge is allocated
+type CostBasis string
+
+const (
+	BasisUnblended CostBasis = "unblended" // billed cost, as charged
+	BasisBlended   CostBasis = "blended"   // usage repriced at the average rate across cost centers
+	BasisAmortized CostBasis = "amortized" // commitments spread over their term onto the usage they cover
+)
+
+// UnusedCommitmentService is the service of the charge holding a configured
+// commitment's cost for a month without covered usage
+const UnusedCommitmentService = "Unused Commitment"
+
+// Commitment is a reservation or savings plan whose upfront purchase the
+// provider bills but does not amortize
+type Commitment struct {
+	ID         string // commitment discount ID carried by the covered usage
+	Cloud      string
+	Account    string
+	Upfront    float64
+	MonthlyFee float64
+	Start      time.Time // first month of the term
+	TermMonths int
+}
+
+// monthly returns the commitment's amortized cost for one month
+func (c Commitment) monthly() float64 {
+	return money.Add(c.Upfront/float64(c.TermMonths), c.MonthlyFee)
+}
+
+// active reports whether month falls within the commitment's term
+func (c Commitment) active(month time.Time) bool {
+	return !month.Before(c.Start) && month.Before(c.Start.AddDate(0, c.TermMonths, 0))
+}
+
+// costView returns the records with Cost set to the configured cost basis
+func costView(cfg AllocatorConfig, records []normalizer.CostRecord) []normalizer.CostRecord {
+	switch cfg.Basis {
+	case BasisBlended:
+		return blended(records)
+	case BasisAmortized:
+		return amortized(records, cfg.Commitments)
+	}
+	return records
+}
+
+// blended reprices usage at the average unit rate of its cloud, service,
+// service type, region and unit, so each cost center pays the same rate
+// whichever account holds the discounts. Credits and charges without usage
+// keep their cost, and each group's total is unchanged.
+func blended(records []normalizer.CostRecord) []normalizer.CostRecord {
+	type group struct {
+		cost  float64
+		usage []float64
+		index []int // of the group's records in view
+	}
+	key := func(r normalizer.CostRecord) string {
+		return r.Cloud + "|" + r.Service + "|" + r.CloudServiceType + "|" + r.Region + "|" + r.UsageUnit
+	}
+	priced := func(r normalizer.CostRecord) bool {
+		return r.UsageQuantity > 0 && !r.IsCredit()
+	}
+
+	groups := make(map[string]*group)
+	for i, r := range records {
+		if !priced(r) {
+			continue
+		}
+		g, ok := groups[key(r)]
+		if !ok {
+			g = &group{}
+			groups[key(r)] = g
+		}
+		g.cost = money.Add(g.cost, r.Cost)
+		g.usage = append(g.usage, r.UsageQuantity)
+		g.index = append(g.index, i)
+	}
+
+	view := make([]normalizer.CostRecord, len(records))
+	copy(view, records)
+	for _, g := range groups {
+		for j, cost := range money.Split(g.cost, g.usage) {
+			view[g.index[j]].Cost = cost
+		}
+	}
+	return view
+}
+
+// amortized sets each charge to its amortized cost. Providers that amortize
+// commitments report it as the effective cost, which already spreads
+// upfront fees over the term and prices covered usage at its share of the
+// commitment. A configured commitment's own charges are instead replaced by
+// its monthly cost, split over the month's covered usage by quantity; in a
+// month without covered usage the whole amount is left as an untagged
+// UnusedCommitmentService charge.
+func amortized(records []normalizer.CostRecord, commitments []Commitment) []normalizer.CostRecord {
+	byID := make(map[string]Commitment, len(commitments))
+	for _, c := range commitments {
+		byID[c.ID] = c
+	}
+	monthOf := func(t time.Time) time.Time {
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	// commitment returns the configured commitment covering a record
+	commitment := func(r normalizer.CostRecord) (Commitment, bool) {
+		c, ok := byID[r.CommitmentDiscountID]
+		return c, ok && r.CommitmentDiscountID != "" && c.active(monthOf(r.Date))
+	}
+
+	type usageKey struct {
+		id    string
+		month time.Time
+	}
+	usage := make(map[usageKey]float64)
+	months := make(map[time.Time]bool)
+	for _, r := range records {
+		months[monthOf(r.Date)] = true
+		if c, ok := commitment(r); ok && r.UsageQuantity > 0 {
+			usage[usageKey{c.ID, monthOf(r.Date)}] += r.UsageQuantity
+		}
+	}
+
+	view := make([]normalizer.CostRecord, 0, len(records))
+	covered := make(map[usageKey][]int) // covered usage records in view
+	for _, r := range records {
+		c, ok := commitment(r)
+		switch {
+		case !ok:
+			r.Cost = r.Effective()
+		case r.UsageQuantity > 0:
+			k := usageKey{c.ID, monthOf(r.Date)}
+			covered[k] = append(covered[k], len(view))
+		default:
+			// Purchases and fees, replaced by the monthly cost
+			continue
+		}
+		view = append(view, r)
+	}
+	// Each month's cost is split over its covered usage by quantity
+	for k, index := range covered {
+		quantities := make([]float64, len(index))
+		for j, i := range index {
+			quantities[j] = view[i].UsageQuantity
+		}
+		for j, cost := range money.Split(byID[k.id].monthly(), quantities) {
+			view[index[j]].Cost = cost
+		}
+	}
+
+	sorted := make([]time.Time, 0, len(months))
+	for m := range months {
+		sorted = append(sorted, m)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Before(sorted[j]) })
+	for _, c := range commitments {
+		for _, month := range sorted {
+			if !c.active(month) || usage[usageKey{c.ID, month}] > 0 {
+				continue
+			}
+			view = append(view, normalizer.CostRecord{
+				SchemaVersion:        normalizer.SchemaVersion,
+				Cloud:                c.Cloud,
+				Account:              c.Account,
+				Service:              UnusedCommitmentService,
+				Resource:             c.ID,
+				Cost:                 c.monthly(),
+				ChargeType:           normalizer.ChargeUsage,
+				CommitmentDiscountID: c.ID,
+				Date:                 month,
+				StartTime:            month,
+				EndTime:              month.AddDate(0, 1, 0),
+			})
+		}
+	}
+	return view
+}
@@ -0,0 +1,142 @@
+package chargeback
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+// priced returns a cost center's usage of EC2 at a cost
+func priced(costCenter string, hours, cost float64) normalizer.CostRecord {
+	r := usage(costCenter, "EC2", hours, "Hrs")
+	r.Cost = cost
+	return r
+}
+
+// covered returns usage of a commitment on a date
+func covered(costCenter string, date time.Time, hours float64, id string) normalizer.CostRecord {
+	r := priced(costCenter, hours, 0)
+	r.Date = date
+	r.CommitmentDiscountID = id
+	return r
+}
+
+// costs returns the cost of each record
+func costs(records []normalizer.CostRecord) []float64 {
+	result := make([]float64, len(records))
+	for i, r := range records {
+		result[i] = r.Cost
+	}
+	return result
+}
+
+func TestBlended(t *testing.T) {
+	otherRegion := priced("CC-2", 10, 9)
+	otherRegion.Region = "eu-west-1"
+	records := []normalizer.CostRecord{
+		priced("CC-1", 100, 20),  // discounted
+		priced("CC-2", 300, 180), // on demand
+		otherRegion,
+		record("CC-1", "EC2", -20), // credits keep their cost
+		record("CC-2", "Support", 30),
+	}
+
+	// 200 over 400 hours is 0.5 an hour in us-east-1
+	want := []float64{50, 150, 9, -20, 30}
+	if got := costs(blended(records)); !reflect.DeepEqual(got, want) {
+		t.Errorf("blended costs = %v, want %v", got, want)
+	}
+	if records[0].Cost != 20 {
+		t.Error("blended modified its input")
+	}
+}
+
+func TestAmortized(t *testing.T) {
+	march := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	sp := Commitment{ID: "sp-1", Cloud: "aws", Account: "999", Upfront: 1200, MonthlyFee: 50, Start: march, TermMonths: 12}
+
+	purchase := record("", "Savings Plans", 1200)
+	purchase.CommitmentDiscountID = "sp-1"
+	purchase.Date = march
+	amortizedByProvider := priced("CC-3", 10, 8)
+	amortizedByProvider.EffectiveCost = 5
+	amortizedByProvider.CommitmentDiscountID = "ri-other"
+
+	records := []normalizer.CostRecord{
+		purchase,
+		covered("CC-1", march, 30, "sp-1"),
+		covered("CC-2", march.AddDate(0, 0, 5), 10, "sp-1"),
+		amortizedByProvider,
+		record("CC-3", "S3", 7),                             // April has no covered usage
+		covered("CC-1", march.AddDate(0, -1, 0), 5, "sp-1"), // before the term
+	}
+	records[4].Date = march.AddDate(0, 1, 0)
+	records[5].Cost = 4 // fully covered, so 0 outside the configured term
+
+	view := amortized(records, []Commitment{sp})
+
+	// The purchase is replaced by 1200/12 + 50 a month, split 30:10 in
+	// March and left unused in April
+	want := []float64{112.5, 37.5, 5, 7, 0, 150}
+	if got := costs(view); !reflect.DeepEqual(got, want) {
+		t.Fatalf("amortized costs = %v, want %v", got, want)
+	}
+	unused := view[5]
+	if unused.Service != UnusedCommitmentService || unused.Account != "999" || !unused.Date.Equal(march.AddDate(0, 1, 0)) || unused.Tags != nil {
+		t.Errorf("unused charge = %+v, want April's untagged charge on account 999", unused)
+	}
+}
+
+func TestAllocateAmortized(t *testing.T) {
+	sp := Commitment{ID: "sp-1", Upfront: 1200, Start: month, TermMonths: 12}
+	purchase := record("CC-1", "Savings Plans", 1200)
+	purchase.CommitmentDiscountID = "sp-1"
+	records := []normalizer.CostRecord{purchase, covered("CC-1", month, 75, "sp-1"), covered("CC-2", month, 25, "sp-1")}
+
+	tests := []struct {
+		basis CostBasis
+		want  map[string]float64
+	}{
+		{BasisUnblended, map[string]float64{"CC-1": 1200, "CC-2": 0}},
+		{BasisAmortized, map[string]float64{"CC-1": 75, "CC-2": 25}},
+	}
+	for _, tt := range tests {
+		a := NewAllocator(AllocatorConfig{PrimaryTag: "cost_center", Basis: tt.basis, Commitments: []Commitment{sp}})
+		allocations := a.Allocate(records)
+		for center, want := range tt.want {
+			if got := allocations[center].TotalCost; got != want {
+				t.Errorf("%s: %s TotalCost = %v, want %v", tt.basis, center, got, want)
+			}
+		}
+	}
+}
+
+func TestConfigFromCostBasis(t *testing.T) {
+	ac, err := ConfigFrom(config.ChargebackConfig{PrimaryTag: "cost_center", Commitments: []config.CommitmentConfig{
+		{ID: "ri-1", Upfront: 3600, Start: "2024-01", TermMonths: 36},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ac.Basis != BasisUnblended {
+		t.Errorf("default basis = %s, want unblended", ac.Basis)
+	}
+	want := []Commitment{{ID: "ri-1", Upfront: 3600, Start: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), TermMonths: 36}}
+	if !reflect.DeepEqual(ac.Commitments, want) {
+		t.Errorf("commitments = %+v, want %+v", ac.Commitments, want)
+	}
+
+	for name, cfg := range map[string]config.ChargebackConfig{
+		"unknown basis": {CostBasis: "net"},
+		"no id":         {Commitments: []config.CommitmentConfig{{Start: "2024-01", TermMonths: 12}}},
+		"no term":       {Commitments: []config.CommitmentConfig{{ID: "ri-1", Start: "2024-01"}}},
+		"bad start":     {Commitments: []config.CommitmentConfig{{ID: "ri-1", Start: "2024-01-01", TermMonths: 12}}},
+	} {
+		if _, err := ConfigFrom(cfg); err == nil {
+			t.Errorf("%s: ConfigFrom() succeeded, want an error", name)
+		}
+	}
+}
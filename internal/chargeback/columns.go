@@ -0,0 +1,287 @@
+package chargeback
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/lvonguyen/finops-platform/internal/money"
+)
+
+// Chargeback CSV columns. ColumnClouds expands to one column per cloud with
+// spend in the report; "tag:KEY" lists the values of a tag in a center's
+// directly allocated charges.
+const (
+	ColumnCostCenter  = "cost_center"
+	ColumnTotal       = "total"
+	ColumnDirect      = "direct"
+	ColumnAllocated   = "allocated"
+	ColumnClouds      = "clouds"
+	ColumnPercent     = "percent"
+	ColumnGross       = "gross"
+	ColumnCredits     = "credits"
+	ColumnNet         = "net"
+	ColumnCurrency    = "currency"
+	ColumnLocalAmount = "local_amount"
+	ColumnEmissions   = "emissions"
+	ColumnUplift      = "uplift"      // adjustments (discounts, markups) on direct charges
+	ColumnEnvironment = "environment" // shorthand for tag:environment
+	ColumnPricing     = "pricing"     // direct charges split by pricing model, one column per model
+	ColumnPrevious    = "previous"    // the center's total in the previous month
+	ColumnChange      = "mom_change"  // change from the previous month
+	ColumnChangePct   = "mom_percent" // percent change, "new" without previous spend
+)
+
+// DefaultColumns is the classic chargeback CSV layout; reports compared with
+// a previous month add MonthOverMonthColumns
+var DefaultColumns = []string{
+	ColumnCostCenter, ColumnTotal, ColumnDirect, ColumnAllocated, ColumnClouds, ColumnPercent,
+	ColumnGross, ColumnCredits, ColumnNet, ColumnCurrency, ColumnLocalAmount, ColumnEmissions,
+}
+
+// MonthOverMonthColumns show each center's change from the previous month
+var MonthOverMonthColumns = []string{ColumnPrevious, ColumnChange, ColumnChangePct}
+
+// cloudNames are the display names of known clouds and SaaS vendors;
+// others use their ID
+var cloudNames = map[string]string{
+	"aws":          "AWS",
+	"azure":        "Azure",
+	"gcp":          "GCP",
+	"oci":          "OCI",
+	"digitalocean": "DigitalOcean",
+	"kubernetes":   "Kubernetes",
+	"datadog":      "Datadog",
+	"github":       "GitHub",
+	"pagerduty":    "PagerDuty",
+}
+
+// pricingNames are the column headers of CostRecord pricing models
+var pricingNames = map[string]string{
+	"on_demand":    "On-Demand",
+	"reserved":     "Reserved",
+	"savings_plan": "Savings Plan",
+	"spot":         "Spot",
+	"":             "Unspecified Pricing",
+}
+
+// cloudOrder keeps the clouds of the classic layout first
+var cloudOrder = []string{"aws", "azure", "gcp"}
+
+// ValidateColumns checks configured chargeback CSV columns
+func ValidateColumns(columns []string) error {
+	for _, c := range columns {
+		if key, ok := strings.CutPrefix(c, "tag:"); ok {
+			if key == "" {
+				return fmt.Errorf("column %q has no tag key", c)
+			}
+			continue
+		}
+		switch c {
+		case ColumnCostCenter, ColumnTotal, ColumnDirect, ColumnAllocated, ColumnClouds, ColumnPercent,
+			ColumnGross, ColumnCredits, ColumnNet, ColumnCurrency, ColumnLocalAmount, ColumnEmissions,
+			ColumnUplift, ColumnEnvironment, ColumnPricing, ColumnPrevious, ColumnChange, ColumnChangePct:
+		default:
+			return fmt.Errorf("unknown chargeback column %q", c)
+		}
+	}
+	return nil
+}
+
+// Clouds returns the clouds with spend in the report: AWS, Azure and GCP
+// first, then the rest by name
+func (r *Report) Clouds() []string {
+	seen := make(map[string]bool)
+	for _, alloc := range r.Allocations {
+		for cloud := range alloc.ByCloud {
+			seen[cloud] = true
+		}
+	}
+
+	var clouds []string
+	for _, cloud := range cloudOrder {
+		if seen[cloud] {
+			clouds = append(clouds, cloud)
+			delete(seen, cloud)
+		}
+	}
+	var rest []string
+	for cloud := range seen {
+		rest = append(rest, cloud)
+	}
+	sort.Strings(rest)
+	return append(clouds, rest...)
+}
+
+// PricingModels returns the pricing models of the report's direct charges:
+// on-demand, reserved, savings plan and spot first, then the rest by name
+func (r *Report) PricingModels() []string {
+	seen := make(map[string]bool)
+	for _, alloc := range r.Allocations {
+		for model := range alloc.ByPricing {
+			seen[model] = true
+		}
+	}
+
+	var models []string
+	for _, model := range []string{"on_demand", "reserved", "savings_plan", "spot"} {
+		if seen[model] {
+			models = append(models, model)
+			delete(seen, model)
+		}
+	}
+	var rest []string
+	for model := range seen {
+		rest = append(rest, model)
+	}
+	sort.Strings(rest)
+	return append(models, rest...)
+}
+
+// column is one CSV column: its header, per-center value and total
+type column struct {
+	header string
+	value  func(alloc *Allocation) string
+	total  string
+}
+
+// columns expands the configured column names for the report's data
+func (r *Report) columns() ([]column, error) {
+	names := r.Columns
+	if len(names) == 0 {
+		names = DefaultColumns
+		if r.PreviousMonth != "" {
+			names = append(append([]string{}, DefaultColumns...), MonthOverMonthColumns...)
+		}
+	}
+	if err := ValidateColumns(names); err != nil {
+		return nil, err
+	}
+
+	var gross, credits, emissions float64
+	for _, alloc := range r.Allocations {
+		gross = money.Add(gross, alloc.GrossCost)
+		credits = money.Add(credits, alloc.Credits)
+		emissions += alloc.EmissionsKg
+	}
+	amount := func(header string, v func(*Allocation) float64, total string) column {
+		return column{header: header, value: func(a *Allocation) string { return fmt.Sprintf("%.2f", v(a)) }, total: total}
+	}
+
+	var cols []column
+	for _, name := range names {
+		switch name {
+		case ColumnCostCenter:
+			cols = append(cols, column{header: "Cost Center", value: func(a *Allocation) string { return a.CostCenter }, total: "TOTAL"})
+		case ColumnTotal:
+			cols = append(cols, amount("Total Cost", func(a *Allocation) float64 { return a.TotalCost }, fmt.Sprintf("%.2f", r.TotalCost)))
+		case ColumnDirect:
+			cols = append(cols, amount("Direct Cost", func(a *Allocation) float64 { return a.DirectCost }, ""))
+		case ColumnAllocated:
+			cols = append(cols, amount("Allocated Cost", func(a *Allocation) float64 { return a.AllocatedCost }, ""))
+		case ColumnClouds:
+			for _, cloud := range r.Clouds() {
+				cloud := cloud
+				header := cloudNames[cloud]
+				if header == "" {
+					header = cloud
+				}
+				cols = append(cols, amount(header, func(a *Allocation) float64 { return a.ByCloud[cloud] }, ""))
+			}
+		case ColumnPercent:
+			cols = append(cols, column{
+				header: "% of Total",
+				value:  func(a *Allocation) string { return fmt.Sprintf("%.1f%%", a.TotalCost/r.TotalCost*100) },
+				total:  "100.0%",
+			})
+		case ColumnGross:
+			cols = append(cols, amount("Gross Cost", func(a *Allocation) float64 { return a.GrossCost }, fmt.Sprintf("%.2f", gross)))
+		case ColumnCredits:
+			cols = append(cols, amount("Credits Applied", func(a *Allocation) float64 { return a.Credits }, fmt.Sprintf("%.2f", credits)))
+		case ColumnNet:
+			cols = append(cols, amount("Net Cost", func(a *Allocation) float64 { return a.TotalCost }, fmt.Sprintf("%.2f", r.TotalCost)))
+		case ColumnCurrency:
+			cols = append(cols, column{header: "Currency", value: func(a *Allocation) string { return a.Currency }, total: r.BaseCurrency})
+		case ColumnLocalAmount:
+			cols = append(cols, column{header: "Local Amount", value: localAmount})
+		case ColumnEmissions:
+			cols = append(cols, column{
+				header: "Est. Emissions (kg CO2e)",
+				value:  func(a *Allocation) string { return r.emissions(a.EmissionsKg) },
+				total:  r.emissions(emissions),
+			})
+		case ColumnUplift:
+			var total float64
+			for _, alloc := range r.Allocations {
+				total = money.Add(total, alloc.Uplift())
+			}
+			cols = append(cols, amount("Uplift", (*Allocation).Uplift, fmt.Sprintf("%.2f", total)))
+		case ColumnPricing:
+			for _, model := range r.PricingModels() {
+				model := model
+				header := pricingNames[model]
+				if header == "" {
+					header = model
+				}
+				cols = append(cols, amount(header, func(a *Allocation) float64 { return a.ByPricing[model] }, ""))
+			}
+		case ColumnPrevious:
+			cols = append(cols, amount("Previous Cost", func(a *Allocation) float64 { return a.PreviousCost }, fmt.Sprintf("%.2f", r.PreviousTotal)))
+		case ColumnChange:
+			cols = append(cols, amount("MoM Change", func(a *Allocation) float64 { return a.Change }, fmt.Sprintf("%.2f", r.TotalCost-r.PreviousTotal)))
+		case ColumnChangePct:
+			cols = append(cols, column{
+				header: "MoM % Change",
+				value:  func(a *Allocation) string { return momPercent(a.Change, a.PreviousCost) },
+				total:  momPercent(r.TotalCost-r.PreviousTotal, r.PreviousTotal),
+			})
+		default:
+			key := strings.TrimPrefix(name, "tag:")
+			header := "Tag: " + key
+			if name == ColumnEnvironment {
+				key, header = "environment", "Environment"
+			}
+			cols = append(cols, column{header: header, value: func(a *Allocation) string { return a.TagValues(key) }})
+		}
+	}
+	return cols, nil
+}
+
+// momPercent formats a change as a percent of the previous cost, "new"
+// when there was none
+func momPercent(change, previous float64) string {
+	if previous <= 0 {
+		if change == 0 {
+			return ""
+		}
+		return "new"
+	}
+	return fmt.Sprintf("%+.1f%%", change/previous*100)
+}
+
+// Uplift returns the change cost adjustments made to the center's direct
+// charges, negative for discounts
+func (alloc *Allocation) Uplift() float64 {
+	var uplift float64
+	for _, rec := range alloc.Records {
+		if rec.Adjustment != "" {
+			uplift = money.Add(uplift, money.Sub(rec.Cost, rec.RawCost))
+		}
+	}
+	return uplift
+}
+
+// TagValues lists the distinct values of a tag on the center's direct
+// charges, separated by semicolons
+func (alloc *Allocation) TagValues(key string) string {
+	seen := make(map[string]bool)
+	var values []string
+	for _, rec := range alloc.Records {
+		if v := rec.Tags[key]; v != "" && !seen[v] {
+			seen[v] = true
+			values = append(values, v)
+		}
+	}
+	sort.Strings(values)
+	return strings.Join(values, ";")
+}
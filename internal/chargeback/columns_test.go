@@ -0,0 +1,164 @@
+package chargeback
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+// csvLines saves the report as CSV and returns its lines
+func csvLines(t *testing.T, r *Report) []string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "chargeback.csv")
+	if err := r.SaveCSV(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+// onCloud returns a record on a cloud with a tag set
+func onCloud(costCenter, cloud string, cost float64, tag, value string) normalizer.CostRecord {
+	r := record(costCenter, "Compute", cost)
+	r.Cloud = cloud
+	if tag != "" {
+		r.Tags[tag] = value
+	}
+	return r
+}
+
+func TestValidateColumns(t *testing.T) {
+	if err := ValidateColumns([]string{ColumnCostCenter, ColumnClouds, ColumnEnvironment, "tag:team"}); err != nil {
+		t.Error(err)
+	}
+	for _, bad := range []string{"tag:", "region", "Total"} {
+		if err := ValidateColumns([]string{ColumnCostCenter, bad}); err == nil {
+			t.Errorf("column %q accepted", bad)
+		}
+	}
+}
+
+func TestReportClouds(t *testing.T) {
+	a := NewAllocator(AllocatorConfig{PrimaryTag: "cost_center"})
+	report := GenerateReport(a.Allocate([]normalizer.CostRecord{
+		onCloud("CC-1", "oci", 10, "", ""),
+		onCloud("CC-1", "gcp", 10, "", ""),
+		onCloud("CC-2", "datadog", 10, "", ""),
+		onCloud("CC-2", "aws", 10, "", ""),
+	}), nil, "2024-03")
+	if got := strings.Join(report.Clouds(), ","); got != "aws,gcp,datadog,oci" {
+		t.Errorf("Clouds() = %s, want aws,gcp,datadog,oci", got)
+	}
+}
+
+func TestSaveCSVColumns(t *testing.T) {
+	a := NewAllocator(AllocatorConfig{PrimaryTag: "cost_center"})
+	report := GenerateReport(a.Allocate([]normalizer.CostRecord{
+		onCloud("CC-1", "aws", 60, "environment", "prod"),
+		onCloud("CC-1", "oci", 15, "environment", "dev"),
+		onCloud("CC-1", "aws", 5, "team", "web"),
+		onCloud("CC-2", "acme", 20, "environment", "prod"),
+	}), nil, "2024-03")
+
+	report.Columns = []string{ColumnCostCenter, ColumnClouds, ColumnTotal, ColumnPercent, ColumnEnvironment, "tag:team"}
+	want := []string{
+		"Cost Center,AWS,acme,OCI,Total Cost,% of Total,Environment,Tag: team",
+		"CC-1,65.00,0.00,15.00,80.00,80.0%,dev;prod,web",
+		"CC-2,0.00,20.00,0.00,20.00,20.0%,prod,",
+		"TOTAL,,,,100.00,100.0%,,",
+	}
+	if got := csvLines(t, report); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	report.Columns = nil
+	if got := csvLines(t, report)[0]; got != "Cost Center,Total Cost,Direct Cost,Allocated Cost,AWS,acme,OCI,% of Total,Gross Cost,Credits Applied,Net Cost,Currency,Local Amount,Est. Emissions (kg CO2e)" {
+		t.Errorf("default header = %s", got)
+	}
+
+	report.Columns = []string{ColumnCostCenter, "owner"}
+	if err := report.SaveCSV(filepath.Join(t.TempDir(), "bad.csv")); err == nil {
+		t.Error("SaveCSV accepted an unknown column")
+	}
+}
+
+func TestSaveCSVPricingColumns(t *testing.T) {
+	priced := func(costCenter, model string, cost float64) normalizer.CostRecord {
+		r := record(costCenter, "Compute", cost)
+		r.PricingModel = model
+		return r
+	}
+	a := NewAllocator(AllocatorConfig{PrimaryTag: "cost_center"})
+	report := GenerateReport(a.Allocate([]normalizer.CostRecord{
+		priced("CC-1", "spot", 5),
+		priced("CC-1", "reserved", 40),
+		priced("CC-1", "on_demand", 15),
+		priced("CC-2", "dedicated", 10),
+		priced("CC-2", "", 30),
+	}), nil, "2024-03")
+
+	if got := strings.Join(report.PricingModels(), ","); got != "on_demand,reserved,spot,,dedicated" {
+		t.Errorf("PricingModels() = %s, want on_demand,reserved,spot,,dedicated", got)
+	}
+	report.Columns = []string{ColumnCostCenter, ColumnPricing, ColumnTotal}
+	want := []string{
+		"Cost Center,On-Demand,Reserved,Spot,Unspecified Pricing,dedicated,Total Cost",
+		"CC-1,15.00,40.00,5.00,0.00,0.00,60.00",
+		"CC-2,0.00,0.00,0.00,30.00,10.00,40.00",
+		"TOTAL,,,,,,100.00",
+	}
+	if got := csvLines(t, report); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestSaveCSVMonthOverMonthColumns(t *testing.T) {
+	report := GenerateReport(
+		totalled(map[string]float64{"CC-1": 150, "CC-2": 50, "CC-3": 20}),
+		totalled(map[string]float64{"CC-1": 100, "CC-2": 100, "CC-4": 30}),
+		"2024-03",
+	)
+	if got := csvLines(t, report)[0]; !strings.HasSuffix(got, ",Est. Emissions (kg CO2e),Previous Cost,MoM Change,MoM % Change") {
+		t.Errorf("default header = %s, want the month-over-month columns last", got)
+	}
+
+	report.Columns = []string{ColumnCostCenter, ColumnTotal, ColumnPrevious, ColumnChange, ColumnChangePct}
+	want := []string{
+		"Cost Center,Total Cost,Previous Cost,MoM Change,MoM % Change",
+		"CC-1,150.00,100.00,50.00,+50.0%",
+		"CC-2,50.00,100.00,-50.00,-50.0%",
+		"CC-3,20.00,0.00,20.00,new",
+		"TOTAL,220.00,230.00,-10.00,-4.3%",
+	}
+	if got := csvLines(t, report); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	if got := csvLines(t, GenerateReport(totalled(map[string]float64{"CC-1": 150}), nil, "2024-03"))[0]; strings.Contains(got, "MoM") {
+		t.Errorf("header without a previous month = %s", got)
+	}
+}
+
+func TestMomPercent(t *testing.T) {
+	tests := []struct {
+		change, previous float64
+		want             string
+	}{
+		{25, 100, "+25.0%"},
+		{-100, 100, "-100.0%"},
+		{0, 100, "+0.0%"},
+		{10, 0, "new"},
+		{0, 0, ""},
+	}
+	for _, tt := range tests {
+		if got := momPercent(tt.change, tt.previous); got != tt.want {
+			t.Errorf("momPercent(%v, %v) = %q, want %q", tt.change, tt.previous, got, tt.want)
+		}
+	}
+}
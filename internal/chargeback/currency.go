@@ -0,0 +1,84 @@
+package chargeback
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/lvonguyen/finops-platform/internal/currency"
+)
+
+// CurrencyMapping assigns billing currencies to cost centers
+type CurrencyMapping struct {
+	CostCenters map[string]string // cost center -> currency
+	Accounts    map[string]string // account -> currency, used when a center has no explicit mapping
+}
+
+// currencyFor resolves a center's billing currency: its explicit mapping,
+// else the currency shared by every account its direct charges come from,
+// else the base currency
+func (m CurrencyMapping) currencyFor(alloc *Allocation, base string) string {
+	if c, ok := m.CostCenters[alloc.CostCenter]; ok && c != "" {
+		return strings.ToUpper(c)
+	}
+
+	shared := ""
+	for _, r := range alloc.Records {
+		c, ok := m.Accounts[r.Account]
+		if !ok {
+			return base
+		}
+		c = strings.ToUpper(c)
+		if shared != "" && shared != c {
+			return base
+		}
+		shared = c
+	}
+	if shared == "" {
+		return base
+	}
+	return shared
+}
+
+// ApplyCurrencies converts each allocation into its billing currency while
+// the report total stays in the base currency. Every needed rate is checked
+// before anything is converted.
+func (r *Report) ApplyCurrencies(conv *currency.Converter, m CurrencyMapping) error {
+	base := conv.Base()
+
+	currencies := make(map[*Allocation]string, len(r.Allocations))
+	var needed []string
+	for _, alloc := range r.Allocations {
+		c := m.currencyFor(alloc, base)
+		currencies[alloc] = c
+		needed = append(needed, c)
+	}
+	if missing := conv.Missing(needed); len(missing) > 0 {
+		return fmt.Errorf("%w for %s", currency.ErrNoRate, strings.Join(missing, ", "))
+	}
+
+	r.BaseCurrency = base
+	for _, alloc := range r.Allocations {
+		rate, err := conv.Rate(base, currencies[alloc])
+		if err != nil {
+			return err
+		}
+		alloc.Currency = currencies[alloc]
+		alloc.ExchangeRate = rate
+		alloc.LocalTotal = alloc.TotalCost * rate
+	}
+	return nil
+}
+
+// SaveJSON saves the report as a JSON file
+func (r *Report) SaveJSON(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	return nil
+}
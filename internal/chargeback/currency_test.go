@@ -0,0 +1,70 @@
+package chargeback
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lvonguyen/finops-platform/internal/currency"
+)
+
+func charged(center string, total float64, accounts ...string) *Allocation {
+	alloc := &Allocation{CostCenter: center, TotalCost: total}
+	for _, account := range accounts {
+		r := record(center, "EC2", 0)
+		r.Account = account
+		alloc.Records = append(alloc.Records, r)
+	}
+	return alloc
+}
+
+func TestApplyCurrencies(t *testing.T) {
+	conv := currency.NewStatic("USD", map[string]float64{"EUR": 0.9, "GBP": 0.8})
+	m := CurrencyMapping{
+		CostCenters: map[string]string{"CC-UK": "gbp"},
+		Accounts:    map[string]string{"de-1": "EUR", "de-2": "EUR", "uk-1": "GBP"},
+	}
+	report := &Report{Allocations: []*Allocation{
+		charged("CC-UK", 100, "de-1"),
+		charged("CC-DE", 100, "de-1", "de-2"),
+		charged("CC-MIXED", 100, "de-1", "uk-1"),
+		charged("CC-US", 100, "de-1", "us-1"),
+		charged("CC-SHARED", 100),
+	}}
+	if err := report.ApplyCurrencies(conv, m); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]struct {
+		currency string
+		local    float64
+	}{
+		"CC-UK":     {"GBP", 80},
+		"CC-DE":     {"EUR", 90},
+		"CC-MIXED":  {"USD", 100},
+		"CC-US":     {"USD", 100},
+		"CC-SHARED": {"USD", 100},
+	}
+	if report.BaseCurrency != "USD" {
+		t.Errorf("BaseCurrency = %s, want USD", report.BaseCurrency)
+	}
+	for _, alloc := range report.Allocations {
+		w := want[alloc.CostCenter]
+		if alloc.Currency != w.currency || alloc.LocalTotal != w.local || alloc.TotalCost != 100 {
+			t.Errorf("%s = %s %v (base %v), want %s %v", alloc.CostCenter, alloc.Currency, alloc.LocalTotal, alloc.TotalCost, w.currency, w.local)
+		}
+	}
+}
+
+func TestApplyCurrenciesMissingRate(t *testing.T) {
+	conv := currency.NewStatic("USD", nil)
+	report := &Report{Allocations: []*Allocation{charged("CC-DE", 100), charged("CC-JP", 100)}}
+	err := report.ApplyCurrencies(conv, CurrencyMapping{CostCenters: map[string]string{"CC-DE": "EUR", "CC-JP": "JPY"}})
+	if !errors.Is(err, currency.ErrNoRate) {
+		t.Fatalf("err = %v, want ErrNoRate", err)
+	}
+	for _, alloc := range report.Allocations {
+		if alloc.Currency != "" {
+			t.Errorf("%s converted to %s despite the missing rate", alloc.CostCenter, alloc.Currency)
+		}
+	}
+}
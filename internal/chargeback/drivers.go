@@ -0,0 +1,179 @@
+package chargeback
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/lvonguyen/finops-platform/internal/money"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+// Built-in allocation drivers
+const (
+	DriverSpend = "spend" // each center's direct spend
+	DriverEven  = "even"  // an equal share for every center with direct spend
+)
+
+// SharedCost splits the charges it matches across cost centers by a driver
+type SharedCost struct {
+	Name   string
+	Match  RecordMatcher
+	Driver string // DriverSpend, DriverEven, or a driver in AllocatorConfig.Drivers
+}
+
+// Drivers holds custom driver values: driver -> cost center -> value
+type Drivers map[string]map[string]float64
+
+// driversFile is the YAML layout of a drivers file
+type driversFile struct {
+	Drivers Drivers `yaml:"drivers"` // driver -> {cost center: value}
+}
+
+// LoadDrivers reads a drivers file. YAML files hold a drivers map of driver
+// to cost center values; CSV files hold driver,cost_center,value rows.
+func LoadDrivers(path string) (Drivers, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read drivers file: %w", err)
+	}
+
+	if strings.ToLower(filepath.Ext(path)) != ".csv" {
+		var f driversFile
+		if err := yaml.Unmarshal(data, &f); err != nil {
+			return nil, fmt.Errorf("failed to parse drivers file: %w", err)
+		}
+		for driver, values := range f.Drivers {
+			for center, v := range values {
+				if v < 0 {
+					return nil, fmt.Errorf("drivers file: %s value for %s is negative", driver, center)
+				}
+			}
+		}
+		return f.Drivers, nil
+	}
+
+	rows, err := csv.NewReader(strings.NewReader(string(data))).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse drivers file: %w", err)
+	}
+	drivers := make(Drivers)
+	for i, row := range rows {
+		if len(row) < 3 {
+			return nil, fmt.Errorf("drivers file line %d: want driver,cost_center,value", i+1)
+		}
+		if i == 0 && strings.EqualFold(strings.TrimSpace(row[0]), "driver") {
+			continue // header
+		}
+		driver, center := strings.TrimSpace(row[0]), strings.TrimSpace(row[1])
+		v, err := strconv.ParseFloat(strings.TrimSpace(row[2]), 64)
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("drivers file line %d: invalid value %q", i+1, row[2])
+		}
+		if drivers[driver] == nil {
+			drivers[driver] = make(map[string]float64)
+		}
+		drivers[driver][center] += v
+	}
+	return drivers, nil
+}
+
+// CheckDrivers reports shared cost rules whose driver is neither built in
+// nor loaded with a positive total
+func (ac AllocatorConfig) CheckDrivers() error {
+	for _, sc := range ac.SharedCosts {
+		if sc.Driver == DriverSpend || sc.Driver == DriverEven {
+			continue
+		}
+		var total float64
+		for _, v := range ac.Drivers[sc.Driver] {
+			total += v
+		}
+		if total <= 0 {
+			return fmt.Errorf("shared cost %q: driver %q has no values (set chargeback.drivers_file)", sc.Name, sc.Driver)
+		}
+	}
+	return nil
+}
+
+// allocateShared splits each shared cost's charges by its driver. A rule
+// whose driver finds no recipients leaves its charges untagged.
+func (a *Allocator) allocateShared(allocations map[string]*Allocation, shared [][]normalizer.CostRecord) []normalizer.CostRecord {
+	var untagged []normalizer.CostRecord
+	for i, records := range shared {
+		if len(records) == 0 {
+			continue
+		}
+		weights := a.driverWeights(allocations, a.config.SharedCosts[i].Driver)
+		var totalWeight float64
+		for _, w := range weights {
+			totalWeight += w
+		}
+		if totalWeight <= 0 {
+			untagged = append(untagged, records...)
+			continue
+		}
+
+		var amount, emissions float64
+		byService := make(map[string]float64)
+		for _, r := range records {
+			amount = money.Add(amount, r.Cost)
+			emissions += r.EmissionsKg
+			byService[r.Service] = money.Add(byService[r.Service], r.Cost)
+		}
+
+		centers := make([]string, 0, len(weights))
+		for center := range weights {
+			centers = append(centers, center)
+		}
+		sort.Strings(centers)
+		shares := make([]float64, len(centers))
+		for j, center := range centers {
+			shares[j] = weights[center]
+		}
+		amounts := money.Split(amount, shares)
+		for j, center := range centers {
+			share := weights[center] / totalWeight
+			if share == 0 {
+				continue
+			}
+			if _, exists := allocations[center]; !exists {
+				allocations[center] = newAllocation(center)
+			}
+			alloc := allocations[center]
+			alloc.AllocatedCost = money.Add(alloc.AllocatedCost, amounts[j])
+			alloc.TotalCost = money.Add(alloc.TotalCost, amounts[j])
+			alloc.addShared(byService, share)
+			alloc.EmissionsKg += emissions * share
+		}
+	}
+	return untagged
+}
+
+// driverWeights returns each cost center's weight under a driver
+func (a *Allocator) driverWeights(allocations map[string]*Allocation, driver string) map[string]float64 {
+	weights := make(map[string]float64)
+	switch driver {
+	case DriverSpend, DriverEven:
+		for center, alloc := range allocations {
+			if alloc.DirectCost <= 0 {
+				continue
+			}
+			weights[center] = 1
+			if driver == DriverSpend {
+				weights[center] = alloc.DirectCost
+			}
+		}
+	default:
+		for center, v := range a.config.Drivers[driver] {
+			weights[center] = v
+		}
+	}
+	return weights
+}
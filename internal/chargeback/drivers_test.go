@@ -0,0 +1,140 @@
+package chargeback
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+// writeDrivers writes a drivers file and returns its path
+func writeDrivers(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadDrivers(t *testing.T) {
+	want := Drivers{
+		"headcount": {"CC-1": 12, "CC-2": 4},
+		"requests":  {"CC-1": 1000},
+	}
+	tests := []struct {
+		name, content string
+	}{
+		{"drivers.yaml", "drivers:\n  headcount:\n    CC-1: 12\n    CC-2: 4\n  requests:\n    CC-1: 1000\n"},
+		{"drivers.csv", "driver,cost_center,value\nheadcount,CC-1,12\nheadcount, CC-2 ,4\nrequests,CC-1,600\nrequests,CC-1,400\n"},
+	}
+	for _, tt := range tests {
+		got, err := LoadDrivers(writeDrivers(t, tt.name, tt.content))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: LoadDrivers() = %v, want %v", tt.name, got, want)
+		}
+	}
+}
+
+func TestLoadDriversErrors(t *testing.T) {
+	tests := []struct {
+		name, content string
+	}{
+		{"negative.yaml", "drivers:\n  headcount:\n    CC-1: -1\n"},
+		{"invalid.yaml", "drivers: [headcount]\n"},
+		{"short.csv", "headcount,CC-1\n"},
+		{"value.csv", "headcount,CC-1,many\n"},
+		{"negative.csv", "headcount,CC-1,-3\n"},
+	}
+	for _, tt := range tests {
+		if _, err := LoadDrivers(writeDrivers(t, tt.name, tt.content)); err == nil {
+			t.Errorf("%s: LoadDrivers() succeeded, want an error", tt.name)
+		}
+	}
+	if _, err := LoadDrivers(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("missing file: LoadDrivers() succeeded, want an error")
+	}
+}
+
+func TestCheckDrivers(t *testing.T) {
+	ac := AllocatorConfig{
+		SharedCosts: []SharedCost{{Name: "support", Driver: DriverEven}, {Name: "network", Driver: "requests"}},
+		Drivers:     Drivers{"requests": {"CC-1": 10}, "headcount": {"CC-1": 0}},
+	}
+	if err := ac.CheckDrivers(); err != nil {
+		t.Errorf("CheckDrivers() = %v, want nil", err)
+	}
+	for _, driver := range []string{"headcount", "vcpu_hours"} {
+		ac.SharedCosts[1].Driver = driver
+		if err := ac.CheckDrivers(); err == nil {
+			t.Errorf("driver %s accepted without values", driver)
+		}
+	}
+}
+
+func TestAllocateSharedCosts(t *testing.T) {
+	records := []normalizer.CostRecord{
+		record("CC-1", "EC2", 300),
+		record("CC-2", "EC2", 100),
+		record("", "Support", 100),
+		record("CC-2", "Support", 60), // tagged, but still a shared cost
+	}
+	tests := []struct {
+		driver string
+		want   map[string]float64 // allocated shared cost
+	}{
+		{DriverSpend, map[string]float64{"CC-1": 120, "CC-2": 40}},
+		{DriverEven, map[string]float64{"CC-1": 80, "CC-2": 80}},
+		{"headcount", map[string]float64{"CC-1": 32, "CC-2": 96, "CC-3": 32}},
+		{"vcpu_hours", map[string]float64{"untagged": 160}}, // no values
+	}
+	for _, tt := range tests {
+		a := NewAllocator(AllocatorConfig{
+			PrimaryTag:   "cost_center",
+			UntaggedPool: "untagged",
+			SharedCosts:  []SharedCost{{Name: "support", Match: RecordMatcher{Service: "Support"}, Driver: tt.driver}},
+			Drivers:      Drivers{"headcount": {"CC-1": 1, "CC-2": 3, "CC-3": 1, "CC-4": 0}},
+		})
+		allocations := a.Allocate(records)
+
+		got := make(map[string]float64)
+		for center, alloc := range allocations {
+			if alloc.AllocatedCost != 0 {
+				got[center] = alloc.AllocatedCost
+			}
+			if alloc.DirectCost+alloc.AllocatedCost != alloc.TotalCost {
+				t.Errorf("%s: %s total %v is not direct %v plus allocated %v", tt.driver, center, alloc.TotalCost, alloc.DirectCost, alloc.AllocatedCost)
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: allocated = %v, want %v", tt.driver, got, tt.want)
+		}
+		if d := allocations["CC-2"].DirectCost; d != 100 {
+			t.Errorf("%s: CC-2 direct cost = %v, want 100 without its shared charge", tt.driver, d)
+		}
+	}
+}
+
+func TestConfigFromSharedCosts(t *testing.T) {
+	ac, err := ConfigFrom(config.ChargebackConfig{PrimaryTag: "cost_center", SharedCosts: []config.SharedCostConfig{
+		{Name: "network", Service: "VPC", Tags: map[string]string{"shared": "true"}},
+		{Name: "support", Cloud: "aws", Driver: "headcount"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []SharedCost{
+		{Name: "network", Match: RecordMatcher{Service: "VPC", Tags: map[string]string{"shared": "true"}}, Driver: DriverSpend},
+		{Name: "support", Match: RecordMatcher{Cloud: "aws"}, Driver: "headcount"},
+	}
+	if !reflect.DeepEqual(ac.SharedCosts, want) {
+		t.Errorf("SharedCosts = %+v, want %+v", ac.SharedCosts, want)
+	}
+}
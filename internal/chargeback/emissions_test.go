@@ -0,0 +1,73 @@
+package chargeback
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+// emitting returns a record carrying an emissions estimate
+func emitting(costCenter string, cost, kg float64) normalizer.CostRecord {
+	r := record(costCenter, "EC2", cost)
+	r.EmissionsKg = kg
+	return r
+}
+
+func TestAllocateEmissions(t *testing.T) {
+	records := []normalizer.CostRecord{
+		emitting("CC-1", 300, 30),
+		emitting("CC-2", 100, 5),
+		emitting("", 40, 8),
+	}
+	tests := []struct {
+		name string
+		cfg  AllocatorConfig
+		want map[string]float64
+	}{
+		{"proportional", AllocatorConfig{PrimaryTag: "cost_center"}, map[string]float64{"CC-1": 36, "CC-2": 7}},
+		{"pooled", AllocatorConfig{PrimaryTag: "cost_center", UntaggedPool: "shared"}, map[string]float64{"CC-1": 30, "CC-2": 5, "shared": 8}},
+		{"split rules", AllocatorConfig{PrimaryTag: "cost_center", SharedCostSplit: []SharedCostRule{{CostCenter: "CC-2", Percentage: 50}}},
+			map[string]float64{"CC-1": 33, "CC-2": 10}},
+	}
+	for _, tt := range tests {
+		allocations := NewAllocator(tt.cfg).Allocate(records)
+		for center, want := range tt.want {
+			alloc := allocations[center]
+			if alloc == nil {
+				t.Fatalf("%s: no allocation for %s", tt.name, center)
+			}
+			if math.Abs(alloc.EmissionsKg-want) > 1e-9 {
+				t.Errorf("%s: %s EmissionsKg = %v, want %v", tt.name, center, alloc.EmissionsKg, want)
+			}
+		}
+	}
+}
+
+func TestSaveCSVEmissionsColumn(t *testing.T) {
+	for _, kg := range []float64{0, 12.5} {
+		a := NewAllocator(AllocatorConfig{PrimaryTag: "cost_center"})
+		report := GenerateReport(a.Allocate([]normalizer.CostRecord{emitting("CC-1", 100, kg)}), nil, "2024-03")
+		path := filepath.Join(t.TempDir(), "chargeback.csv")
+		if err := report.SaveCSV(path); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := ","
+		if kg != 0 {
+			want = ",12.50"
+		}
+		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n")[1:] {
+			if !strings.HasSuffix(line, want) {
+				t.Errorf("emissions %v: row %q, want it to end in %q", kg, line, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,479 @@
+package chargeback
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/pdf"
+)
+
+// Invoice line kinds
+const (
+	LineCharge   = "charge"   // direct charges for a service
+	LineShared   = "shared"   // allocated shared and untagged cost
+	LineCredit   = "credit"   // credits applied to the center
+	LineMarkup   = "markup"   // uplift rule adding to the bill
+	LineDiscount = "discount" // negotiated discount
+)
+
+// DefaultDueDays is the payment term of invoices when none is configured
+const DefaultDueDays = 30
+
+// InvoiceRule marks up or discounts invoices
+type InvoiceRule struct {
+	Name        string
+	Percent     float64         // +10 adds 10% of the matching charges, -5 takes 5% off
+	CostCenters map[string]bool // centers invoiced with the rule, every center when empty
+	Services    map[string]bool // charges the rule applies to, all charges when empty
+}
+
+// InvoiceOptions configures invoice generation
+type InvoiceOptions struct {
+	Prefix  string // invoice number prefix, "INV" when empty
+	DueDays int    // days from issue to due date, DefaultDueDays when 0
+	Rules   []InvoiceRule
+}
+
+// InvoiceOptionsFrom builds invoice options from settings
+func InvoiceOptionsFrom(cfg config.InvoiceConfig) (InvoiceOptions, error) {
+	opts := InvoiceOptions{Prefix: cfg.Prefix, DueDays: cfg.DueDays}
+	if opts.DueDays < 0 {
+		return InvoiceOptions{}, fmt.Errorf("invoice due_days must not be negative")
+	}
+	for _, rc := range cfg.Rules {
+		if rc.Name == "" {
+			return InvoiceOptions{}, fmt.Errorf("invoice rule needs a name")
+		}
+		if rc.Percent == 0 || rc.Percent <= -100 {
+			return InvoiceOptions{}, fmt.Errorf("invoice rule %q: percent must be nonzero and above -100", rc.Name)
+		}
+		rule := InvoiceRule{Name: rc.Name, Percent: rc.Percent}
+		if len(rc.CostCenters) > 0 {
+			rule.CostCenters = make(map[string]bool)
+			for _, cc := range rc.CostCenters {
+				rule.CostCenters[cc] = true
+			}
+		}
+		if len(rc.Services) > 0 {
+			rule.Services = make(map[string]bool)
+			for _, s := range rc.Services {
+				rule.Services[s] = true
+			}
+		}
+		opts.Rules = append(opts.Rules, rule)
+	}
+	for _, f := range cfg.Formats {
+		switch f {
+		case "csv", "json", "xlsx", "pdf":
+		default:
+			return InvoiceOptions{}, fmt.Errorf("unknown invoice format %q (want csv, json, xlsx or pdf)", f)
+		}
+	}
+	return opts, nil
+}
+
+// InvoiceLine is one line of an invoice, in the invoice's currency
+type InvoiceLine struct {
+	Line        int     `json:"line"`
+	Kind        string  `json:"kind"`
+	Description string  `json:"description"`
+	Service     string  `json:"service,omitempty"`
+	Amount      float64 `json:"amount"`
+}
+
+// Invoice bills one cost center for a month's chargeback
+type Invoice struct {
+	Number       string        `json:"number"`
+	CostCenter   string        `json:"cost_center"`
+	Month        string        `json:"month"`
+	Issued       time.Time     `json:"issued"`
+	Due          time.Time     `json:"due"`
+	Currency     string        `json:"currency"`
+	ExchangeRate float64       `json:"exchange_rate"` // report base currency to Currency
+	Lines        []InvoiceLine `json:"lines"`
+	Subtotal     float64       `json:"subtotal"`    // charges net of credits
+	Adjustments  float64       `json:"adjustments"` // markups less discounts
+	Total        float64       `json:"total"`
+}
+
+// Invoices are a month's invoices, one per billed cost center
+type Invoices []*Invoice
+
+// BuildInvoices bills each cost center with a positive chargeback total in
+// its billing currency: a line per service for direct and for shared
+// charges, credits, then a line per matching rule. Amounts are rounded to
+// cents line by line, so every total is the sum of its lines. Invoices are
+// numbered by cost center name.
+func BuildInvoices(r *Report, opts InvoiceOptions, issued time.Time) Invoices {
+	prefix := opts.Prefix
+	if prefix == "" {
+		prefix = "INV"
+	}
+	dueDays := opts.DueDays
+	if dueDays == 0 {
+		dueDays = DefaultDueDays
+	}
+
+	billed := make([]*Allocation, 0, len(r.Allocations))
+	for _, alloc := range r.Allocations {
+		if toCents(alloc.TotalCost) > 0 {
+			billed = append(billed, alloc)
+		}
+	}
+	sort.Slice(billed, func(i, j int) bool { return billed[i].CostCenter < billed[j].CostCenter })
+
+	invoices := make(Invoices, 0, len(billed))
+	for i, alloc := range billed {
+		inv := &Invoice{
+			Number:       fmt.Sprintf("%s-%s-%04d", prefix, strings.ReplaceAll(r.Month, "-", ""), i+1),
+			CostCenter:   alloc.CostCenter,
+			Month:        r.Month,
+			Issued:       issued,
+			Due:          issued.AddDate(0, 0, dueDays),
+			Currency:     alloc.Currency,
+			ExchangeRate: alloc.ExchangeRate,
+		}
+		if inv.Currency == "" {
+			inv.Currency = r.BaseCurrency
+		}
+		if inv.Currency == "" {
+			inv.Currency = "USD"
+		}
+		if inv.ExchangeRate == 0 {
+			inv.ExchangeRate = 1
+		}
+		inv.bill(alloc, opts.Rules)
+		invoices = append(invoices, inv)
+	}
+	return invoices
+}
+
+// bill adds the center's charges, credits and rule adjustments
+func (inv *Invoice) bill(alloc *Allocation, rules []InvoiceRule) {
+	local := func(v float64) int64 { return toCents(v * inv.ExchangeRate) }
+	add := func(kind, description, service string, cents int64) {
+		if cents == 0 {
+			return
+		}
+		inv.Lines = append(inv.Lines, InvoiceLine{
+			Line:        len(inv.Lines) + 1,
+			Kind:        kind,
+			Description: description,
+			Service:     service,
+			Amount:      fromCents(cents),
+		})
+	}
+
+	// Charges per service, largest first; each rule applies to its services'
+	// charges, before credits
+	var charged int64
+	byService := make(map[string]int64)
+	for _, part := range []struct {
+		kind     string
+		costs    map[string]float64
+		describe func(string) string
+	}{
+		{LineCharge, alloc.ByService, func(s string) string { return s }},
+		{LineShared, alloc.SharedByService, func(s string) string { return s + " (shared)" }},
+	} {
+		services := make([]string, 0, len(part.costs))
+		for s := range part.costs {
+			services = append(services, s)
+		}
+		sort.Slice(services, func(i, j int) bool {
+			if part.costs[services[i]] != part.costs[services[j]] {
+				return part.costs[services[i]] > part.costs[services[j]]
+			}
+			return services[i] < services[j]
+		})
+		for _, s := range services {
+			cents := local(part.costs[s])
+			add(part.kind, part.describe(s), s, cents)
+			byService[s] += cents
+			charged += cents
+		}
+	}
+	// Allocated cost not broken down by service, such as an untagged pool;
+	// a difference within the lines' rounding goes to the largest line
+	if rest := local(alloc.GrossCost) - charged; abs64(rest) > int64(len(inv.Lines)) {
+		add(LineShared, "Other allocated costs", "", rest)
+		charged += rest
+	} else if rest != 0 && len(inv.Lines) > 0 {
+		largest := 0
+		for i, l := range inv.Lines {
+			if math.Abs(l.Amount) > math.Abs(inv.Lines[largest].Amount) {
+				largest = i
+			}
+		}
+		inv.Lines[largest].Amount = fromCents(toCents(inv.Lines[largest].Amount) + rest)
+		byService[inv.Lines[largest].Service] += rest
+		charged += rest
+	}
+	subtotal := charged
+	if alloc.Credits > 0 {
+		credit := -local(alloc.Credits)
+		add(LineCredit, "Credits", "", credit)
+		subtotal += credit
+	}
+
+	var adjustments int64
+	for _, rule := range rules {
+		if rule.CostCenters != nil && !rule.CostCenters[alloc.CostCenter] {
+			continue
+		}
+		base := charged
+		if rule.Services != nil {
+			base = 0
+			for s := range rule.Services {
+				base += byService[s]
+			}
+		}
+		cents := toCents(fromCents(base) * rule.Percent / 100)
+		kind := LineMarkup
+		if rule.Percent < 0 {
+			kind = LineDiscount
+		}
+		add(kind, fmt.Sprintf("%s (%+g%%)", rule.Name, rule.Percent), "", cents)
+		adjustments += cents
+	}
+
+	inv.Subtotal = fromCents(subtotal)
+	inv.Adjustments = fromCents(adjustments)
+	inv.Total = fromCents(subtotal + adjustments)
+}
+
+// SaveCSV writes every invoice line, one per row, with the invoice details
+// repeated for import into billing systems
+func (invs Invoices) SaveCSV(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	header := []string{"Invoice", "Cost Center", "Month", "Issued", "Due", "Currency", "Line", "Kind", "Description", "Amount", "Invoice Total"}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	for _, inv := range invs {
+		for _, l := range inv.Lines {
+			row := []string{
+				inv.Number,
+				inv.CostCenter,
+				inv.Month,
+				inv.Issued.Format("2006-01-02"),
+				inv.Due.Format("2006-01-02"),
+				inv.Currency,
+				fmt.Sprintf("%d", l.Line),
+				l.Kind,
+				l.Description,
+				fmt.Sprintf("%.2f", l.Amount),
+				fmt.Sprintf("%.2f", inv.Total),
+			}
+			if err := writer.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// SaveJSON writes the invoices as a JSON file
+func (invs Invoices) SaveJSON(path string) error {
+	data, err := json.MarshalIndent(invs, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	return nil
+}
+
+// SaveXLSX writes a workbook with one sheet per invoice; subtotals and the
+// total are formulas over the lines
+func (invs Invoices) SaveXLSX(path string) error {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	w := &workbook{f: f, money: make(map[string]int)}
+	if err := w.styles(); err != nil {
+		return fmt.Errorf("failed to create styles: %w", err)
+	}
+	for i, inv := range invs {
+		sheet := inv.Number
+		if i == 0 {
+			w.err = f.SetSheetName("Sheet1", sheet)
+		} else if w.err == nil {
+			_, w.err = f.NewSheet(sheet)
+		}
+		w.invoice(sheet, inv)
+	}
+	if w.err != nil {
+		return fmt.Errorf("failed to build workbook: %w", w.err)
+	}
+
+	f.SetActiveSheet(0)
+	if err := f.SaveAs(path); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	return nil
+}
+
+// invoice writes one invoice's header, lines and totals
+func (w *workbook) invoice(sheet string, inv *Invoice) {
+	w.set(sheet, 1, 1, "Invoice "+inv.Number, w.title)
+	for i, field := range [][2]string{
+		{"Bill to", inv.CostCenter},
+		{"Period", inv.Month},
+		{"Issued", inv.Issued.Format("2006-01-02")},
+		{"Due", inv.Due.Format("2006-01-02")},
+		{"Currency", inv.Currency},
+	} {
+		w.set(sheet, 1, 2+i, field[0], w.bold)
+		w.set(sheet, 2, 2+i, field[1], 0)
+	}
+
+	const head = 8
+	w.headerRow(sheet, head, []string{"Line", "Description", "Kind", "Amount"}, []float64{14, 48, 12, 18})
+	money := w.moneyStyle(inv.Currency, false)
+	boldMoney := w.moneyStyle(inv.Currency, true)
+
+	row := head + 1
+	var adjustmentRows []string
+	first := row
+	for _, l := range inv.Lines {
+		if l.Kind == LineMarkup || l.Kind == LineDiscount {
+			continue
+		}
+		w.set(sheet, 1, row, l.Line, 0)
+		w.set(sheet, 2, row, l.Description, 0)
+		w.set(sheet, 3, row, l.Kind, 0)
+		w.set(sheet, 4, row, l.Amount, money)
+		row++
+	}
+	subtotal := row
+	w.set(sheet, 2, subtotal, "Subtotal", w.bold)
+	w.formula(sheet, 4, subtotal, sumRange(4, first, subtotal-1), boldMoney)
+	row++
+	for _, l := range inv.Lines {
+		if l.Kind != LineMarkup && l.Kind != LineDiscount {
+			continue
+		}
+		w.set(sheet, 1, row, l.Line, 0)
+		w.set(sheet, 2, row, l.Description, 0)
+		w.set(sheet, 3, row, l.Kind, 0)
+		w.set(sheet, 4, row, l.Amount, money)
+		adjustmentRows = append(adjustmentRows, cell(4, row))
+		row++
+	}
+	w.set(sheet, 2, row, "Total due", w.bold)
+	w.formula(sheet, 4, row, strings.Join(append([]string{cell(4, subtotal)}, adjustmentRows...), "+"), boldMoney)
+}
+
+// Invoice PDF layout, in points
+const (
+	pdfMargin   = 54.0
+	pdfLeading  = 14.0
+	pdfBodySize = 9.0
+	pdfLastLine = pdf.PageHeight - 72
+)
+
+// SavePDF writes a printable PDF with each invoice starting on a new page
+func (invs Invoices) SavePDF(path string) error {
+	doc := pdf.New()
+	for _, inv := range invs {
+		inv.render(doc)
+	}
+	return doc.Save(path)
+}
+
+// render lays an invoice out over as many pages as its lines need
+func (inv *Invoice) render(doc *pdf.Document) {
+	right := pdf.PageWidth - pdfMargin
+	amount := func(v float64) string {
+		return fmt.Sprintf("%s %s", inv.Currency, formatAmount(v))
+	}
+
+	page := doc.AddPage()
+	y := pdfMargin + 20
+	page.Text(pdfMargin, y, pdf.HelveticaBold, 20, "INVOICE")
+	page.Text(right-180, y, pdf.HelveticaBold, 12, inv.Number)
+	y += 30
+	for _, field := range [][2]string{
+		{"Bill to", inv.CostCenter},
+		{"Period", inv.Month},
+		{"Issued", inv.Issued.Format("2006-01-02")},
+		{"Due", inv.Due.Format("2006-01-02")},
+	} {
+		page.Text(pdfMargin, y, pdf.HelveticaBold, 10, field[0])
+		page.Text(pdfMargin+70, y, pdf.Helvetica, 10, field[1])
+		y += pdfLeading
+	}
+
+	header := func() {
+		y += pdfLeading
+		page.Text(pdfMargin, y, pdf.HelveticaBold, 10, "Description")
+		page.Text(right-60, y, pdf.HelveticaBold, 10, "Amount")
+		y += 4
+		page.Rule(pdfMargin, right, y)
+		y += pdfLeading
+	}
+	header()
+	row := func(font pdf.Font, label, value string) {
+		if y > pdfLastLine {
+			page = doc.AddPage()
+			y = pdfMargin
+			page.Text(pdfMargin, y, pdf.Helvetica, 9, inv.Number+" (continued)")
+			header()
+		}
+		page.Text(pdfMargin, y, font, pdfBodySize, label)
+		page.TextRight(right, y, pdfBodySize, value)
+		y += pdfLeading
+	}
+
+	for _, l := range inv.Lines {
+		if l.Kind != LineMarkup && l.Kind != LineDiscount {
+			row(pdf.Helvetica, l.Description, amount(l.Amount))
+		}
+	}
+	page.Rule(pdfMargin, right, y-pdfLeading+4)
+	row(pdf.HelveticaBold, "Subtotal", amount(inv.Subtotal))
+	for _, l := range inv.Lines {
+		if l.Kind == LineMarkup || l.Kind == LineDiscount {
+			row(pdf.Helvetica, l.Description, amount(l.Amount))
+		}
+	}
+	page.Rule(pdfMargin, right, y-pdfLeading+4)
+	row(pdf.HelveticaBold, "Total due", amount(inv.Total))
+}
+
+// formatAmount formats an amount with thousands separators
+func formatAmount(v float64) string {
+	sign := ""
+	if v < 0 {
+		sign, v = "-", -v
+	}
+	s := fmt.Sprintf("%.2f", v)
+	whole, cents := s[:len(s)-3], s[len(s)-3:]
+	var b strings.Builder
+	for i, d := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(d)
+	}
+	return sign + b.String() + cents
+}
@@ -0,0 +1,239 @@
+package chargeback
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+var issued = time.Date(2024, 4, 2, 0, 0, 0, 0, time.UTC)
+
+// invoiceReport has a center billed nothing, one credited with shared
+// costs, one billed in euros, an unbroken-down pool and cents to round
+func invoiceReport() *Report {
+	return &Report{
+		Month:        "2024-03",
+		BaseCurrency: "USD",
+		Allocations: []*Allocation{
+			{CostCenter: "CC-0"},
+			{
+				CostCenter: "CC-1", TotalCost: 110, GrossCost: 120, Credits: 10,
+				ByService:       map[string]float64{"EC2": 80, "S3": 20},
+				SharedByService: map[string]float64{"EC2": 20},
+			},
+			{
+				CostCenter: "CC-2", TotalCost: 50, GrossCost: 50, Currency: "EUR", ExchangeRate: 0.9,
+				ByService: map[string]float64{"RDS": 50},
+			},
+			{CostCenter: "CC-3", TotalCost: 30, GrossCost: 30},
+			{
+				CostCenter: "CC-4", TotalCost: 1, GrossCost: 1,
+				ByService: map[string]float64{"A": 0.333, "B": 0.333, "C": 0.334},
+			},
+		},
+	}
+}
+
+// invoiceOptions adds a platform fee to every invoice and discounts CC-1's S3
+func invoiceOptions() InvoiceOptions {
+	return InvoiceOptions{Prefix: "CB", Rules: []InvoiceRule{
+		{Name: "Platform fee", Percent: 10},
+		{Name: "S3 discount", Percent: -5, CostCenters: map[string]bool{"CC-1": true}, Services: map[string]bool{"S3": true}},
+	}}
+}
+
+func TestInvoiceOptionsFrom(t *testing.T) {
+	opts, err := InvoiceOptionsFrom(config.InvoiceConfig{Prefix: "CB", DueDays: 14, Formats: []string{"csv", "pdf"}, Rules: []config.InvoiceRuleConfig{
+		{Name: "Platform fee", Percent: 10},
+		{Name: "S3 discount", Percent: -5, CostCenters: []string{"CC-1"}, Services: []string{"S3"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := invoiceOptions()
+	want.DueDays = 14
+	if !reflect.DeepEqual(opts, want) {
+		t.Errorf("InvoiceOptionsFrom() = %+v, want %+v", opts, want)
+	}
+
+	for name, cfg := range map[string]config.InvoiceConfig{
+		"negative due days": {DueDays: -1},
+		"unnamed rule":      {Rules: []config.InvoiceRuleConfig{{Percent: 10}}},
+		"zero percent":      {Rules: []config.InvoiceRuleConfig{{Name: "fee"}}},
+		"whole discount":    {Rules: []config.InvoiceRuleConfig{{Name: "free", Percent: -100}}},
+		"unknown format":    {Formats: []string{"docx"}},
+	} {
+		if _, err := InvoiceOptionsFrom(cfg); err == nil {
+			t.Errorf("%s: InvoiceOptionsFrom() succeeded, want an error", name)
+		}
+	}
+}
+
+func TestBuildInvoices(t *testing.T) {
+	invoices := BuildInvoices(invoiceReport(), invoiceOptions(), issued)
+	if len(invoices) != 4 {
+		t.Fatalf("got %d invoices, want 4 without CC-0's", len(invoices))
+	}
+
+	tests := []struct {
+		number, costCenter, currency string
+		subtotal, adjustments, total float64
+		lines                        []InvoiceLine
+	}{
+		{"CB-202403-0001", "CC-1", "USD", 110, 11, 121, []InvoiceLine{
+			{1, LineCharge, "EC2", "EC2", 80},
+			{2, LineCharge, "S3", "S3", 20},
+			{3, LineShared, "EC2 (shared)", "EC2", 20},
+			{4, LineCredit, "Credits", "", -10},
+			{5, LineMarkup, "Platform fee (+10%)", "", 12},
+			{6, LineDiscount, "S3 discount (-5%)", "", -1},
+		}},
+		{"CB-202403-0002", "CC-2", "EUR", 45, 4.5, 49.5, []InvoiceLine{
+			{1, LineCharge, "RDS", "RDS", 45},
+			{2, LineMarkup, "Platform fee (+10%)", "", 4.5},
+		}},
+		{"CB-202403-0003", "CC-3", "USD", 30, 3, 33, []InvoiceLine{
+			{1, LineShared, "Other allocated costs", "", 30},
+			{2, LineMarkup, "Platform fee (+10%)", "", 3},
+		}},
+		// The cent lost rounding each third goes to the largest line
+		{"CB-202403-0004", "CC-4", "USD", 1, 0.1, 1.1, []InvoiceLine{
+			{1, LineCharge, "C", "C", 0.34},
+			{2, LineCharge, "A", "A", 0.33},
+			{3, LineCharge, "B", "B", 0.33},
+			{4, LineMarkup, "Platform fee (+10%)", "", 0.1},
+		}},
+	}
+	for i, tt := range tests {
+		inv := invoices[i]
+		if inv.Number != tt.number || inv.CostCenter != tt.costCenter || inv.Currency != tt.currency {
+			t.Errorf("invoice %d = %s for %s in %s, want %s for %s in %s", i, inv.Number, inv.CostCenter, inv.Currency, tt.number, tt.costCenter, tt.currency)
+		}
+		if inv.Subtotal != tt.subtotal || inv.Adjustments != tt.adjustments || inv.Total != tt.total {
+			t.Errorf("%s: totals = %v + %v = %v, want %v + %v = %v", tt.number, inv.Subtotal, inv.Adjustments, inv.Total, tt.subtotal, tt.adjustments, tt.total)
+		}
+		if !reflect.DeepEqual(inv.Lines, tt.lines) {
+			t.Errorf("%s: lines = %+v, want %+v", tt.number, inv.Lines, tt.lines)
+		}
+		if !inv.Issued.Equal(issued) || !inv.Due.Equal(issued.AddDate(0, 0, DefaultDueDays)) {
+			t.Errorf("%s: issued %v due %v, want due in %d days", tt.number, inv.Issued, inv.Due, DefaultDueDays)
+		}
+	}
+
+	// Without a prefix invoices are numbered INV
+	if invoices := BuildInvoices(invoiceReport(), InvoiceOptions{DueDays: 7}, issued); invoices[0].Number != "INV-202403-0001" || !invoices[0].Due.Equal(issued.AddDate(0, 0, 7)) {
+		t.Errorf("invoice = %s due %v, want INV-202403-0001 due in 7 days", invoices[0].Number, invoices[0].Due)
+	}
+}
+
+func TestInvoicesSave(t *testing.T) {
+	invoices := BuildInvoices(invoiceReport(), invoiceOptions(), issued)
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "invoices.csv")
+	if err := invoices.SaveCSV(path); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 15 {
+		t.Fatalf("got %d CSV rows, want a header and 14 lines", len(rows))
+	}
+	if want := []string{"CB-202403-0001", "CC-1", "2024-03", "2024-04-02", "2024-05-02", "USD", "6", LineDiscount, "S3 discount (-5%)", "-1.00", "121.00"}; !reflect.DeepEqual(rows[6], want) {
+		t.Errorf("CSV row 6 = %q, want %q", rows[6], want)
+	}
+
+	path = filepath.Join(dir, "invoices.json")
+	if err := invoices.SaveJSON(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Invoices
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 4 || !reflect.DeepEqual(decoded[1].Lines, invoices[1].Lines) || decoded[1].ExchangeRate != 0.9 {
+		t.Errorf("JSON invoices = %+v, want %+v", decoded, invoices)
+	}
+
+	path = filepath.Join(dir, "invoices.xlsx")
+	if err := invoices.SaveXLSX(path); err != nil {
+		t.Fatal(err)
+	}
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if got := f.GetSheetList(); len(got) != 4 || got[0] != "CB-202403-0001" {
+		t.Fatalf("sheets = %v, want one per invoice", got)
+	}
+	// Lines, then the subtotal, the adjustments and the total due
+	for _, c := range []struct{ cell, want string }{
+		{"B2", "CC-1"},
+		{"B6", "USD"},
+		{"D12", "-10"},
+		{"B13", "Subtotal"},
+		{"D13", "110"},
+		{"C15", LineDiscount},
+		{"B16", "Total due"},
+		{"D16", "121"},
+	} {
+		if got, err := f.CalcCellValue("CB-202403-0001", c.cell, excelize.Options{RawCellValue: true}); err != nil || got != c.want {
+			t.Errorf("%s = %q, %v; want %q", c.cell, got, err, c.want)
+		}
+	}
+	if got, _ := f.GetCellFormula("CB-202403-0001", "D16"); got != "D13+D14+D15" {
+		t.Errorf("total formula = %q, want D13+D14+D15", got)
+	}
+
+	path = filepath.Join(dir, "invoices.pdf")
+	if err := invoices.SavePDF(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err = os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"/Count 4 ", "(CB-202403-0002)", "(EUR 49.50)", "(S3 discount \\(-5%\\))", "(USD -1.00)"} {
+		if !bytes.Contains(data, []byte(want)) {
+			t.Errorf("PDF is missing %s", want)
+		}
+	}
+}
+
+func TestFormatAmount(t *testing.T) {
+	tests := []struct {
+		v    float64
+		want string
+	}{
+		{0, "0.00"},
+		{999.999, "1,000.00"},
+		{1234567.5, "1,234,567.50"},
+		{-12345, "-12,345.00"},
+	}
+	for _, tt := range tests {
+		if got := formatAmount(tt.v); got != tt.want {
+			t.Errorf("formatAmount(%v) = %q, want %q", tt.v, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package chargeback
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// JournalAccounts maps cost centers to the general ledger accounts their
+// chargeback is posted to
+type JournalAccounts struct {
+	CostCenters map[string]string // cost center -> expense account
+	Default     string            // expense account for unmapped centers
+	Clearing    string            // account the cloud bill is cleared from
+	Prefix      string            // journal ID prefix, "CB" when empty
+}
+
+// JournalLine is one side of a journal entry. Exactly one of Debit and
+// Credit is nonzero.
+type JournalLine struct {
+	Line       int     `json:"line"`
+	Account    string  `json:"account"`
+	CostCenter string  `json:"cost_center,omitempty"`
+	Debit      float64 `json:"debit"`
+	Credit     float64 `json:"credit"`
+	Memo       string  `json:"memo"`
+}
+
+// Journal is a double-entry posting of a chargeback report: each center's
+// expense account is debited and the clearing account credited
+type Journal struct {
+	ID       string        `json:"id"`
+	Date     time.Time     `json:"date"` // last day of the month
+	Currency string        `json:"currency"`
+	Total    float64       `json:"total"` // report total, rounded to cents
+	Lines    []JournalLine `json:"lines"`
+}
+
+// BuildJournal posts a report to the ledger in its base currency. Amounts are
+// rounded to cents, with the rounding remainder posted a cent at a time to
+// the largest centers so the journal nets exactly to the rounded report
+// total. A remainder of more than a cent per center is not rounding, and is
+// an error.
+func BuildJournal(r *Report, accounts JournalAccounts) (*Journal, error) {
+	if accounts.Clearing == "" {
+		return nil, fmt.Errorf("journal has no clearing account")
+	}
+	start, err := time.Parse("2006-01", r.Month)
+	if err != nil {
+		return nil, fmt.Errorf("invalid report month %q: %w", r.Month, err)
+	}
+	prefix := accounts.Prefix
+	if prefix == "" {
+		prefix = "CB"
+	}
+
+	var unmapped []string
+	cents := make([]int64, len(r.Allocations))
+	var sum int64
+	for i, alloc := range r.Allocations {
+		if accounts.CostCenters[alloc.CostCenter] == "" && accounts.Default == "" {
+			unmapped = append(unmapped, alloc.CostCenter)
+		}
+		cents[i] = toCents(alloc.TotalCost)
+		sum += cents[i]
+	}
+	if len(unmapped) > 0 {
+		sort.Strings(unmapped)
+		return nil, fmt.Errorf("no journal account for cost centers %s and no default account", strings.Join(unmapped, ", "))
+	}
+	total := toCents(r.TotalCost)
+	if err := spreadRemainder(cents, total-sum); err != nil {
+		return nil, fmt.Errorf("report %s: %w", r.Month, err)
+	}
+
+	j := &Journal{
+		ID:       fmt.Sprintf("%s-%s", prefix, r.Month),
+		Date:     start.AddDate(0, 1, -1),
+		Currency: r.BaseCurrency,
+		Total:    fromCents(total),
+	}
+	add := func(account, center string, amount int64, memo string) {
+		line := JournalLine{Line: len(j.Lines) + 1, Account: account, CostCenter: center, Memo: memo}
+		// Negative amounts (net credits) post to the opposite side
+		if amount >= 0 {
+			line.Debit = fromCents(amount)
+		} else {
+			line.Credit = fromCents(-amount)
+		}
+		j.Lines = append(j.Lines, line)
+	}
+	for i, alloc := range r.Allocations {
+		if cents[i] == 0 {
+			continue
+		}
+		account := accounts.CostCenters[alloc.CostCenter]
+		if account == "" {
+			account = accounts.Default
+		}
+		add(account, alloc.CostCenter, cents[i], fmt.Sprintf("Cloud chargeback %s %s", r.Month, alloc.CostCenter))
+	}
+	if total != 0 {
+		add(accounts.Clearing, "", -total, fmt.Sprintf("Cloud chargeback %s clearing", r.Month))
+	}
+
+	if err := j.Check(); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// spreadRemainder posts a rounding remainder to the lines a cent each, the
+// largest lines first (the first of equals)
+func spreadRemainder(cents []int64, remainder int64) error {
+	if abs64(remainder) > int64(len(cents)) {
+		return fmt.Errorf("allocations differ from the report total by %.2f, more than a cent per cost center of rounding", fromCents(remainder))
+	}
+	order := make([]int, len(cents))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return abs64(cents[order[a]]) > abs64(cents[order[b]])
+	})
+	step := int64(1)
+	if remainder < 0 {
+		step = -1
+	}
+	for i := int64(0); i < abs64(remainder); i++ {
+		cents[order[i]] += step
+	}
+	return nil
+}
+
+// Check verifies the journal balances and nets to its total
+func (j *Journal) Check() error {
+	var debits, credits, clearing int64
+	for _, l := range j.Lines {
+		debits += toCents(l.Debit)
+		credits += toCents(l.Credit)
+		if l.CostCenter == "" {
+			clearing += toCents(l.Credit) - toCents(l.Debit)
+		}
+	}
+	if debits != credits {
+		return fmt.Errorf("journal %s does not balance: debits %.2f, credits %.2f", j.ID, fromCents(debits), fromCents(credits))
+	}
+	if total := toCents(j.Total); clearing != total {
+		return fmt.Errorf("journal %s clears %.2f, not the report total %.2f", j.ID, fromCents(clearing), fromCents(total))
+	}
+	return nil
+}
+
+// Debits returns the journal's total debits
+func (j *Journal) Debits() float64 {
+	var cents int64
+	for _, l := range j.Lines {
+		cents += toCents(l.Debit)
+	}
+	return fromCents(cents)
+}
+
+func toCents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+func fromCents(cents int64) float64 {
+	return float64(cents) / 100
+}
+
+func abs64(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// SaveCSV writes the journal one line per row, in the flat layout ERP
+// journal imports expect
+func (j *Journal) SaveCSV(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	header := []string{"Journal", "Date", "Line", "Account", "Cost Center", "Debit", "Credit", "Currency", "Memo"}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	amount := func(v float64) string {
+		if v == 0 {
+			return ""
+		}
+		return fmt.Sprintf("%.2f", v)
+	}
+	for _, l := range j.Lines {
+		row := []string{
+			j.ID,
+			j.Date.Format("2006-01-02"),
+			fmt.Sprintf("%d", l.Line),
+			l.Account,
+			l.CostCenter,
+			amount(l.Debit),
+			amount(l.Credit),
+			j.Currency,
+			l.Memo,
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SaveJSON writes the journal as a JSON file
+func (j *Journal) SaveJSON(path string) error {
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	return nil
+}
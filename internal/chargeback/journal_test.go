@@ -0,0 +1,66 @@
+package chargeback
+
+import (
+	"strings"
+	"testing"
+)
+
+func journalReport(total float64, costs ...float64) *Report {
+	r := &Report{Month: "2024-03", TotalCost: total, BaseCurrency: "USD"}
+	for i, cost := range costs {
+		alloc := newAllocation(string(rune('A' + i)))
+		alloc.TotalCost = cost
+		r.Allocations = append(r.Allocations, alloc)
+	}
+	return r
+}
+
+var journalAccounts = JournalAccounts{Default: "6100", Clearing: "2100"}
+
+func TestBuildJournalBalances(t *testing.T) {
+	tests := []struct {
+		name  string
+		total float64
+		costs []float64
+		want  []float64 // debits of the center lines
+	}{
+		{"exact", 30, []float64{10, 20}, []float64{10, 20}},
+		{"thirds", 100, []float64{33.333, 33.333, 33.333}, []float64{33.34, 33.33, 33.33}},
+		{"remainder over two lines", 0.06, []float64{0.014, 0.014, 0.014, 0.014}, []float64{0.02, 0.02, 0.01, 0.01}},
+		{"credit center", 5, []float64{7.004, -2.004}, []float64{7, -2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j, err := BuildJournal(journalReport(tt.total, tt.costs...), journalAccounts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := j.Check(); err != nil {
+				t.Fatal(err)
+			}
+			var debits, credits int64
+			for i, l := range j.Lines {
+				debits += toCents(l.Debit)
+				credits += toCents(l.Credit)
+				if i < len(tt.want) {
+					if got := l.Debit - l.Credit; toCents(got) != toCents(tt.want[i]) {
+						t.Errorf("line %d (%s) = %.2f, want %.2f", l.Line, l.CostCenter, got, tt.want[i])
+					}
+				}
+			}
+			if debits != credits {
+				t.Errorf("debits %d != credits %d", debits, credits)
+			}
+			if j.Total != tt.total {
+				t.Errorf("Total = %v, want %v", j.Total, tt.total)
+			}
+		})
+	}
+}
+
+func TestBuildJournalRejectsLargeRemainder(t *testing.T) {
+	_, err := BuildJournal(journalReport(100, 40, 50), journalAccounts)
+	if err == nil || !strings.Contains(err.Error(), "differ from the report total by 10.00") {
+		t.Fatalf("err = %v, want a remainder error", err)
+	}
+}
@@ -0,0 +1,257 @@
+package chargeback
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lvonguyen/finops-platform/internal/hierarchy"
+	"github.com/lvonguyen/finops-platform/internal/money"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+// KeyExpr builds a composite cost center from tags and the account's place
+// in the org hierarchy, e.g. "{ou:2}-{tag:team}". References:
+//
+//	{tag:KEY}  a tag value
+//	{ou}       the account's full org-unit path, joined with "/"
+//	{ou:N}     the org unit N levels below the root; negative N counts up from the account
+//	{account}  the account ID
+//	{cloud}    the cloud provider
+//
+// Alternatives separated by "|" are tried in order, e.g. {tag:cost_center|tag:team}.
+// Text outside braces is copied as is.
+type KeyExpr struct {
+	src   string
+	parts []keyPart
+}
+
+type keyPart struct {
+	literal      string
+	alternatives []keyRef // empty for literal text
+}
+
+type keyRef struct {
+	kind  string // tag, ou, account, cloud
+	tag   string
+	level int // 0 for the full path
+}
+
+// ParseKeyExpr compiles an allocation-key expression
+func ParseKeyExpr(s string) (*KeyExpr, error) {
+	e := &KeyExpr{src: s}
+	refs := 0
+
+	for rest := s; rest != ""; {
+		open := strings.IndexByte(rest, '{')
+		if close := strings.IndexByte(rest, '}'); close >= 0 && (open < 0 || close < open) {
+			return nil, fmt.Errorf("allocation key %q: unmatched '}'", s)
+		}
+		if open < 0 {
+			e.parts = append(e.parts, keyPart{literal: rest})
+			break
+		}
+		if open > 0 {
+			e.parts = append(e.parts, keyPart{literal: rest[:open]})
+		}
+
+		end := strings.IndexByte(rest[open:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("allocation key %q: unclosed '{'", s)
+		}
+		body := rest[open+1 : open+end]
+		rest = rest[open+end+1:]
+
+		var part keyPart
+		for _, alt := range strings.Split(body, "|") {
+			ref, err := parseKeyRef(strings.TrimSpace(alt))
+			if err != nil {
+				return nil, fmt.Errorf("allocation key %q: %w", s, err)
+			}
+			part.alternatives = append(part.alternatives, ref)
+		}
+		e.parts = append(e.parts, part)
+		refs++
+	}
+
+	if refs == 0 {
+		return nil, fmt.Errorf("allocation key %q references no tags or org units", s)
+	}
+	return e, nil
+}
+
+func parseKeyRef(s string) (keyRef, error) {
+	kind, arg, hasArg := strings.Cut(s, ":")
+	switch kind {
+	case "tag":
+		if arg == "" {
+			return keyRef{}, fmt.Errorf("{tag} needs a key, e.g. {tag:team}")
+		}
+		return keyRef{kind: kind, tag: arg}, nil
+	case "ou":
+		if !hasArg {
+			return keyRef{kind: kind}, nil
+		}
+		level, err := strconv.Atoi(arg)
+		if err != nil || level == 0 {
+			return keyRef{}, fmt.Errorf("invalid org-unit level %q", arg)
+		}
+		return keyRef{kind: kind, level: level}, nil
+	case "account", "cloud":
+		if hasArg {
+			return keyRef{}, fmt.Errorf("{%s} takes no argument", kind)
+		}
+		return keyRef{kind: kind}, nil
+	}
+	return keyRef{}, fmt.Errorf("unknown reference {%s}", s)
+}
+
+// String returns the expression source
+func (e *KeyExpr) String() string {
+	return e.src
+}
+
+// UsesHierarchy reports whether the expression references org units
+func (e *KeyExpr) UsesHierarchy() bool {
+	for _, p := range e.parts {
+		for _, ref := range p.alternatives {
+			if ref.kind == "ou" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Resolve evaluates the expression for a record. When a reference has no
+// value it returns the unresolved references instead of a key.
+func (e *KeyExpr) Resolve(r normalizer.CostRecord, h *hierarchy.Hierarchy) (string, []string) {
+	var b strings.Builder
+	var missing []string
+
+	for _, p := range e.parts {
+		if p.alternatives == nil {
+			b.WriteString(p.literal)
+			continue
+		}
+
+		value := ""
+		for _, ref := range p.alternatives {
+			if value = ref.resolve(r, h); value != "" {
+				break
+			}
+		}
+		if value == "" {
+			missing = append(missing, p.String())
+			continue
+		}
+		b.WriteString(value)
+	}
+
+	if len(missing) > 0 {
+		return "", missing
+	}
+	return b.String(), nil
+}
+
+func (ref keyRef) resolve(r normalizer.CostRecord, h *hierarchy.Hierarchy) string {
+	switch ref.kind {
+	case "tag":
+		return r.Tags[ref.tag]
+	case "ou":
+		if ref.level == 0 {
+			p, _ := h.Path(r.Account)
+			return strings.Join(p, "/")
+		}
+		unit, _ := h.Level(r.Account, ref.level)
+		return unit
+	case "account":
+		return r.Account
+	case "cloud":
+		return r.Cloud
+	}
+	return ""
+}
+
+func (p keyPart) String() string {
+	refs := make([]string, len(p.alternatives))
+	for i, ref := range p.alternatives {
+		switch {
+		case ref.kind == "tag":
+			refs[i] = "tag:" + ref.tag
+		case ref.kind == "ou" && ref.level != 0:
+			refs[i] = "ou:" + strconv.Itoa(ref.level)
+		default:
+			refs[i] = ref.kind
+		}
+	}
+	return "{" + strings.Join(refs, "|") + "}"
+}
+
+// UnresolvedKey totals charges whose allocation key could not be resolved
+type UnresolvedKey struct {
+	Cloud   string  `json:"cloud"`
+	Account string  `json:"account"`
+	Service string  `json:"service"`
+	Missing string  `json:"missing"` // unresolved references, e.g. "{ou:2}, {tag:team}"
+	Records int     `json:"records"`
+	Cost    float64 `json:"cost"`
+}
+
+// trackUnresolved adds a record to the unresolved totals
+func (a *Allocator) trackUnresolved(r normalizer.CostRecord, missing []string) {
+	if a.unresolved == nil {
+		a.unresolved = make(map[string]*UnresolvedKey)
+	}
+	m := strings.Join(missing, ", ")
+	k := r.Cloud + "|" + r.Account + "|" + r.Service + "|" + m
+	u, ok := a.unresolved[k]
+	if !ok {
+		u = &UnresolvedKey{Cloud: r.Cloud, Account: r.Account, Service: r.Service, Missing: m}
+		a.unresolved[k] = u
+	}
+	u.Records++
+	u.Cost = money.Add(u.Cost, r.Cost)
+}
+
+// Unresolved returns the charges the last Allocate call could not resolve an
+// allocation key for, largest first. They are allocated as untagged costs.
+func (a *Allocator) Unresolved() []UnresolvedKey {
+	result := make([]UnresolvedKey, 0, len(a.unresolved))
+	for _, u := range a.unresolved {
+		result = append(result, *u)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Cost != result[j].Cost {
+			return result[i].Cost > result[j].Cost
+		}
+		return result[i].Account < result[j].Account
+	})
+	return result
+}
+
+// SaveUnresolvedCSV writes the unresolved allocation keys as a CSV file
+func (r *Report) SaveUnresolvedCSV(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	if err := writer.Write([]string{"Cloud", "Account", "Service", "Unresolved", "Records", "Cost"}); err != nil {
+		return err
+	}
+	for _, u := range r.Unresolved {
+		row := []string{u.Cloud, u.Account, u.Service, u.Missing, strconv.Itoa(u.Records), fmt.Sprintf("%.2f", u.Cost)}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
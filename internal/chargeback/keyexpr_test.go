@@ -0,0 +1,104 @@
+package chargeback
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/lvonguyen/finops-platform/internal/hierarchy"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+func TestParseKeyExprErrors(t *testing.T) {
+	for _, s := range []string{
+		"static",
+		"{tag:team",
+		"team}",
+		"{tag}",
+		"{ou:0}",
+		"{ou:x}",
+		"{account:1}",
+		"{region}",
+	} {
+		if _, err := ParseKeyExpr(s); err == nil {
+			t.Errorf("ParseKeyExpr(%q) succeeded", s)
+		}
+	}
+}
+
+func TestKeyExprResolve(t *testing.T) {
+	h := hierarchy.New(map[string][]string{"111": {"Root", "Engineering", "Platform"}})
+	r := record("", "EC2", 10)
+	r.Tags["team"] = "web"
+
+	tests := []struct {
+		expr    string
+		account string
+		want    string
+		missing []string
+	}{
+		{"{ou:2}-{tag:team}", "111", "Engineering-web", nil},
+		{"{ou}", "111", "Root/Engineering/Platform", nil},
+		{"{ou:-1}/{account}", "111", "Platform/111", nil},
+		{"{cloud}:{tag:cost_center|tag:team}", "111", "aws:web", nil},
+		{"{ou:2}-{tag:team}", "999", "", []string{"{ou:2}"}},
+		{"{tag:owner}-{ou:4|account}", "111", "", []string{"{tag:owner}"}},
+	}
+	for _, tt := range tests {
+		e, err := ParseKeyExpr(tt.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Account = tt.account
+		got, missing := e.Resolve(r, h)
+		if got != tt.want || !reflect.DeepEqual(missing, tt.missing) {
+			t.Errorf("%s for %s = %q, %v, want %q, %v", tt.expr, tt.account, got, missing, tt.want, tt.missing)
+		}
+	}
+}
+
+func TestAllocateByKeyExpr(t *testing.T) {
+	key, err := ParseKeyExpr("{ou:2}-{tag:team}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !key.UsesHierarchy() {
+		t.Error("UsesHierarchy() = false for an {ou:2} key")
+	}
+	h := hierarchy.New(map[string][]string{"111": {"Root", "Engineering"}, "222": {"Root", "Finance"}})
+	charge := func(account, team string, cost float64) normalizer.CostRecord {
+		r := record("", "EC2", cost)
+		r.Account = account
+		if team != "" {
+			r.Tags["team"] = team
+		}
+		return r
+	}
+
+	a := NewAllocator(AllocatorConfig{Key: key, Hierarchy: h, UntaggedPool: "unallocated"})
+	allocations := a.Allocate([]normalizer.CostRecord{
+		charge("111", "web", 100),
+		charge("222", "ledger", 50),
+		charge("333", "web", 20),
+		charge("333", "data", 5),
+		charge("111", "", 7),
+	})
+
+	want := map[string]float64{"Engineering-web": 100, "Finance-ledger": 50, "unallocated": 32}
+	if len(allocations) != len(want) {
+		t.Errorf("got %d cost centers, want %d", len(allocations), len(want))
+	}
+	for center, cost := range want {
+		if alloc := allocations[center]; alloc == nil || alloc.TotalCost != cost {
+			t.Errorf("%s: got %+v, want total %v", center, alloc, cost)
+		}
+	}
+
+	unresolved := a.Unresolved()
+	wantUnresolved := []UnresolvedKey{
+		{Cloud: "aws", Account: "333", Service: "EC2", Missing: "{ou:2}", Records: 2, Cost: 25},
+		{Cloud: "aws", Account: "111", Service: "EC2", Missing: "{tag:team}", Records: 1, Cost: 7},
+	}
+	if !reflect.DeepEqual(unresolved, wantUnresolved) {
+		t.Errorf("Unresolved() = %+v, want %+v", unresolved, wantUnresolved)
+	}
+}
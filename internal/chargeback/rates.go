@@ -0,0 +1,157 @@
+package chargeback
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// RateOutlierFactor flags blended rates this many times above or below the
+// median rate for the same service
+const RateOutlierFactor = 2.0
+
+// BlendedRate is a cost center's effective unit rate for a service after
+// shared costs have been allocated
+type BlendedRate struct {
+	CostCenter string  `json:"cost_center"`
+	Service    string  `json:"service"`
+	Cost       float64 `json:"cost"`  // direct plus allocated shared cost
+	Usage      float64 `json:"usage"` // the cost center's own measured usage
+	Unit       string  `json:"unit"`  // "mixed" when the service reports several units
+	Rate       float64 `json:"rate"`  // Cost / Usage, 0 when there is no usage
+	NoUsage    bool    `json:"no_usage"`
+	VsMedian   float64 `json:"vs_median"` // Rate / median rate for the service, 0 when unknown
+}
+
+// Outlier reports whether the rate is far from the service's median rate
+func (b BlendedRate) Outlier() bool {
+	return b.VsMedian > RateOutlierFactor || (b.VsMedian > 0 && b.VsMedian < 1/RateOutlierFactor)
+}
+
+// BlendedRates computes per cost center and service blended unit rates
+func BlendedRates(allocations []*Allocation) []BlendedRate {
+	var rates []BlendedRate
+	byService := make(map[string][]float64)
+
+	for _, alloc := range allocations {
+		usage := make(map[string]float64)
+		units := make(map[string]string)
+		for _, r := range alloc.Records {
+			usage[r.Service] += r.UsageQuantity
+			if unit, seen := units[r.Service]; !seen {
+				units[r.Service] = r.UsageUnit
+			} else if unit != r.UsageUnit {
+				units[r.Service] = "mixed"
+			}
+		}
+
+		services := make(map[string]bool)
+		for s := range alloc.ByService {
+			services[s] = true
+		}
+		for s := range alloc.SharedByService {
+			services[s] = true
+		}
+
+		for service := range services {
+			br := BlendedRate{
+				CostCenter: alloc.CostCenter,
+				Service:    service,
+				Cost:       alloc.ByService[service] + alloc.SharedByService[service],
+				Usage:      usage[service],
+				Unit:       units[service],
+			}
+			if br.Usage > 0 && br.Unit != "mixed" {
+				br.Rate = br.Cost / br.Usage
+				byService[service] = append(byService[service], br.Rate)
+			} else {
+				br.NoUsage = br.Usage <= 0
+			}
+			rates = append(rates, br)
+		}
+	}
+
+	medians := make(map[string]float64, len(byService))
+	for service, values := range byService {
+		medians[service] = median(values)
+	}
+	for i := range rates {
+		if m := medians[rates[i].Service]; m > 0 && rates[i].Rate > 0 {
+			rates[i].VsMedian = rates[i].Rate / m
+		}
+	}
+
+	sort.Slice(rates, func(i, j int) bool {
+		if rates[i].Service != rates[j].Service {
+			return rates[i].Service < rates[j].Service
+		}
+		return rates[i].CostCenter < rates[j].CostCenter
+	})
+
+	return rates
+}
+
+// SaveRatesCSV writes the blended rates as a CSV file
+func (r *Report) SaveRatesCSV(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	header := []string{"Service", "Cost Center", "Cost", "Usage", "Unit", "Blended Rate", "Vs Median", "Note"}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, br := range r.Rates {
+		rate, vsMedian, note := "", "", ""
+		switch {
+		case br.NoUsage:
+			note = "no usage"
+		case br.Unit == "mixed":
+			note = "mixed units"
+		default:
+			rate = fmt.Sprintf("%.6f", br.Rate)
+		}
+		if br.VsMedian > 0 {
+			vsMedian = fmt.Sprintf("%.2fx", br.VsMedian)
+			if br.Outlier() {
+				note = "outlier"
+			}
+		}
+
+		row := []string{
+			br.Service,
+			br.CostCenter,
+			fmt.Sprintf("%.2f", br.Cost),
+			fmt.Sprintf("%.4f", br.Usage),
+			br.Unit,
+			rate,
+			vsMedian,
+			note,
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n == 0 {
+		return 0
+	}
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
@@ -0,0 +1,69 @@
+package chargeback
+
+import (
+	"testing"
+
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+// usage returns a record of a cost center's measured usage of a service
+func usage(costCenter, service string, quantity float64, unit string) normalizer.CostRecord {
+	r := record(costCenter, service, 0)
+	r.UsageQuantity = quantity
+	r.UsageUnit = unit
+	return r
+}
+
+func TestBlendedRates(t *testing.T) {
+	allocations := []*Allocation{
+		{
+			CostCenter:      "CC-1",
+			ByService:       map[string]float64{"EC2": 80, "S3": 10},
+			SharedByService: map[string]float64{"EC2": 20},
+			Records: []normalizer.CostRecord{
+				usage("CC-1", "EC2", 100, "Hrs"),
+				usage("CC-1", "S3", 5, "GB-Mo"),
+				usage("CC-1", "S3", 5, "Requests"),
+			},
+		},
+		{
+			CostCenter: "CC-2",
+			ByService:  map[string]float64{"EC2": 100},
+			Records:    []normalizer.CostRecord{usage("CC-2", "EC2", 200, "Hrs")},
+		},
+		{
+			CostCenter: "CC-3",
+			ByService:  map[string]float64{"EC2": 500},
+			Records:    []normalizer.CostRecord{usage("CC-3", "EC2", 200, "Hrs")},
+		},
+		{
+			CostCenter:      "CC-4",
+			SharedByService: map[string]float64{"EC2": 7},
+		},
+	}
+
+	// EC2 rates are 1, 0.5 and 2.5, with a median of 1
+	want := []BlendedRate{
+		{CostCenter: "CC-1", Service: "EC2", Cost: 100, Usage: 100, Unit: "Hrs", Rate: 1, VsMedian: 1},
+		{CostCenter: "CC-2", Service: "EC2", Cost: 100, Usage: 200, Unit: "Hrs", Rate: 0.5, VsMedian: 0.5},
+		{CostCenter: "CC-3", Service: "EC2", Cost: 500, Usage: 200, Unit: "Hrs", Rate: 2.5, VsMedian: 2.5},
+		{CostCenter: "CC-4", Service: "EC2", Cost: 7, NoUsage: true},
+		{CostCenter: "CC-1", Service: "S3", Cost: 10, Usage: 10, Unit: "mixed"},
+	}
+	got := BlendedRates(allocations)
+	if len(got) != len(want) {
+		t.Fatalf("got %d rates %+v, want %d", len(got), got, len(want))
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("rate %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	outliers := map[string]bool{"CC-3": true}
+	for _, br := range got[:4] {
+		if br.Outlier() != outliers[br.CostCenter] {
+			t.Errorf("%s EC2 outlier = %v, want %v", br.CostCenter, br.Outlier(), outliers[br.CostCenter])
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package chargeback
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/money"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+// Scenario is an allocation strategy to simulate
+type Scenario struct {
+	Name   string
+	Config AllocatorConfig
+}
+
+// ScenarioConfig applies a scenario's settings over the chargeback
+// configuration; empty scenario fields keep the base setting
+func ScenarioConfig(base config.ChargebackConfig, s config.AllocationScenario) config.ChargebackConfig {
+	cfg := base
+	if s.PrimaryTag != "" {
+		cfg.PrimaryTag = s.PrimaryTag
+	}
+	if s.FallbackTag != "" {
+		cfg.FallbackTag = s.FallbackTag
+	}
+	if s.AllocationKey != "" {
+		cfg.AllocationKey = s.AllocationKey
+	}
+	if s.UntaggedPool != "" {
+		cfg.UntaggedPool = s.UntaggedPool
+	}
+	if s.SharedCostSplit != nil {
+		cfg.SharedCostSplit = s.SharedCostSplit
+	}
+	return cfg
+}
+
+// ScenarioResult is the outcome of one strategy
+type ScenarioResult struct {
+	Name            string             `json:"name"`
+	CostCenters     map[string]float64 `json:"cost_centers"`
+	UntaggedCost    float64            `json:"untagged_cost"`    // no cost center before pooling or distribution
+	UntaggedPercent float64            `json:"untagged_percent"` // of total cost
+	SharedCost      float64            `json:"shared_cost"`      // moved to centers by shared splits, pools or proportional distribution
+	SharedPercent   float64            `json:"shared_percent"`
+	Unresolved      int                `json:"unresolved_keys"` // record groups the allocation key could not resolve
+}
+
+// Simulation compares allocation strategies over the same records
+type Simulation struct {
+	Month       string           `json:"month"`
+	TotalCost   float64          `json:"total_cost"`
+	CostCenters []string         `json:"-"` // every center, largest first
+	Scenarios   []ScenarioResult `json:"scenarios"`
+}
+
+// Simulate allocates records under each scenario
+func Simulate(records []normalizer.CostRecord, month string, scenarios []Scenario) *Simulation {
+	sim := &Simulation{Month: month}
+	// Scenarios share the chargeback cost basis
+	total := records
+	if len(scenarios) > 0 {
+		total = costView(scenarios[0].Config, records)
+	}
+	for _, r := range total {
+		sim.TotalCost = money.Add(sim.TotalCost, r.Cost)
+	}
+
+	largest := make(map[string]float64)
+	for _, s := range scenarios {
+		allocator := NewAllocator(s.Config)
+		allocations := allocator.Allocate(records)
+
+		result := ScenarioResult{
+			Name:         s.Name,
+			CostCenters:  make(map[string]float64, len(allocations)),
+			UntaggedCost: allocator.UntaggedCost(),
+			Unresolved:   len(allocator.Unresolved()),
+		}
+		for center, alloc := range allocations {
+			result.CostCenters[center] = alloc.TotalCost
+			result.SharedCost = money.Add(result.SharedCost, alloc.AllocatedCost)
+			if alloc.TotalCost > largest[center] {
+				largest[center] = alloc.TotalCost
+			}
+		}
+		if sim.TotalCost != 0 {
+			result.UntaggedPercent = result.UntaggedCost / sim.TotalCost * 100
+			result.SharedPercent = result.SharedCost / sim.TotalCost * 100
+		}
+		sim.Scenarios = append(sim.Scenarios, result)
+	}
+
+	for center := range largest {
+		sim.CostCenters = append(sim.CostCenters, center)
+	}
+	sort.Slice(sim.CostCenters, func(i, j int) bool {
+		a, b := sim.CostCenters[i], sim.CostCenters[j]
+		if largest[a] != largest[b] {
+			return largest[a] > largest[b]
+		}
+		return a < b
+	})
+	return sim
+}
+
+// Share returns a center's share of total cost under a scenario
+func (s *Simulation) Share(scenario int, center string) float64 {
+	if s.TotalCost == 0 {
+		return 0
+	}
+	return s.Scenarios[scenario].CostCenters[center] / s.TotalCost * 100
+}
+
+// SaveCSV writes the comparison with one cost and share column pair per
+// scenario, followed by the untagged and shared totals
+func (s *Simulation) SaveCSV(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	header := []string{"Cost Center"}
+	for _, sc := range s.Scenarios {
+		header = append(header, sc.Name+" Cost", sc.Name+" %")
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, center := range s.CostCenters {
+		row := []string{center}
+		for i, sc := range s.Scenarios {
+			row = append(row, fmt.Sprintf("%.2f", sc.CostCenters[center]), fmt.Sprintf("%.2f", s.Share(i, center)))
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	untagged, shared := []string{"(untagged before allocation)"}, []string{"(shared/distributed)"}
+	for _, sc := range s.Scenarios {
+		untagged = append(untagged, fmt.Sprintf("%.2f", sc.UntaggedCost), fmt.Sprintf("%.2f", sc.UntaggedPercent))
+		shared = append(shared, fmt.Sprintf("%.2f", sc.SharedCost), fmt.Sprintf("%.2f", sc.SharedPercent))
+	}
+	if err := writer.Write(untagged); err != nil {
+		return err
+	}
+	return writer.Write(shared)
+}
+
+// SaveJSON saves the comparison as a JSON file
+func (s *Simulation) SaveJSON(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package chargeback
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+func TestScenarioConfig(t *testing.T) {
+	base := config.ChargebackConfig{PrimaryTag: "cost_center", FallbackTag: "team", UntaggedPool: "shared"}
+	got := ScenarioConfig(base, config.AllocationScenario{Name: "by team", PrimaryTag: "team"})
+	if got.PrimaryTag != "team" || got.FallbackTag != "team" || got.UntaggedPool != "shared" {
+		t.Errorf("ScenarioConfig() = %+v, want the primary tag replaced and the rest kept", got)
+	}
+	if base.PrimaryTag != "cost_center" {
+		t.Errorf("base config changed to %+v", base)
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	teamOnly := record("", "S3", 100)
+	teamOnly.Tags["team"] = "web"
+	records := []normalizer.CostRecord{
+		record("CC-1", "EC2", 300),
+		record("CC-2", "EC2", 100),
+		teamOnly,
+	}
+	sim := Simulate(records, "2024-03", []Scenario{
+		{Name: "pooled", Config: AllocatorConfig{PrimaryTag: "cost_center", UntaggedPool: "shared"}},
+		{Name: "fallback", Config: AllocatorConfig{PrimaryTag: "cost_center", FallbackTag: "team"}},
+		{Name: "proportional", Config: AllocatorConfig{PrimaryTag: "cost_center"}},
+	})
+
+	if sim.TotalCost != 500 || len(sim.Scenarios) != 3 {
+		t.Fatalf("total %v over %d scenarios, want 500 over 3", sim.TotalCost, len(sim.Scenarios))
+	}
+	tests := []struct {
+		scenario int
+		centers  map[string]float64
+		untagged float64
+		shared   float64
+	}{
+		{0, map[string]float64{"CC-1": 300, "CC-2": 100, "shared": 100}, 100, 100},
+		{1, map[string]float64{"CC-1": 300, "CC-2": 100, "web": 100}, 0, 0},
+		{2, map[string]float64{"CC-1": 375, "CC-2": 125}, 100, 100},
+	}
+	for _, tt := range tests {
+		got := sim.Scenarios[tt.scenario]
+		if len(got.CostCenters) != len(tt.centers) {
+			t.Errorf("%s: centers %v, want %v", got.Name, got.CostCenters, tt.centers)
+		}
+		for center, cost := range tt.centers {
+			if got.CostCenters[center] != cost {
+				t.Errorf("%s: %s = %v, want %v", got.Name, center, got.CostCenters[center], cost)
+			}
+		}
+		if got.UntaggedCost != tt.untagged || got.UntaggedPercent != tt.untagged/5 || got.SharedCost != tt.shared {
+			t.Errorf("%s: untagged %v (%v%%), shared %v; want %v and %v", got.Name, got.UntaggedCost, got.UntaggedPercent, got.SharedCost, tt.untagged, tt.shared)
+		}
+	}
+
+	if got := strings.Join(sim.CostCenters, ","); got != "CC-1,CC-2,shared,web" {
+		t.Errorf("cost centers = %s, want largest first", got)
+	}
+	if share := sim.Share(2, "CC-1"); share != 75 {
+		t.Errorf("Share(proportional, CC-1) = %v, want 75", share)
+	}
+}
+
+func TestSimulationSaveCSV(t *testing.T) {
+	sim := Simulate([]normalizer.CostRecord{record("CC-1", "EC2", 80), record("", "EC2", 20)}, "2024-03", []Scenario{
+		{Name: "pooled", Config: AllocatorConfig{PrimaryTag: "cost_center", UntaggedPool: "shared"}},
+		{Name: "proportional", Config: AllocatorConfig{PrimaryTag: "cost_center"}},
+	})
+	path := filepath.Join(t.TempDir(), "simulation.csv")
+	if err := sim.SaveCSV(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{
+		"Cost Center,pooled Cost,pooled %,proportional Cost,proportional %",
+		"CC-1,80.00,80.00,100.00,100.00",
+		"shared,20.00,20.00,0.00,0.00",
+		"(untagged before allocation),20.00,20.00,20.00,20.00",
+		"(shared/distributed),20.00,20.00,20.00,20.00",
+	}, "\n") + "\n"
+	if string(data) != want {
+		t.Errorf("got\n%s\nwant\n%s", data, want)
+	}
+}
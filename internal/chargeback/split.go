@@ -0,0 +1,131 @@
+package chargeback
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/money"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+// Split divides matching charges across cost centers, for resources several
+// teams share
+type Split struct {
+	ID     string
+	Match  RecordMatcher
+	Shares []Share
+}
+
+// Share is one cost center's part of a split charge
+type Share struct {
+	CostCenter string
+	Fraction   float64 // the fractions of a split sum to 1
+}
+
+// splitFrom builds a split rule from settings
+func splitFrom(sc config.AllocationSplit) (Split, error) {
+	weights := make(map[string]float64, len(sc.Shares))
+	for cc, w := range sc.Shares {
+		if cc == "" || w <= 0 {
+			return Split{}, fmt.Errorf("split %q: shares need a cost center and a positive weight", sc.ID)
+		}
+		weights[cc] = w
+	}
+	if len(weights) == 0 {
+		return Split{}, fmt.Errorf("split %q has no shares", sc.ID)
+	}
+	return Split{
+		ID: sc.ID,
+		Match: RecordMatcher{
+			Cloud:    sc.Cloud,
+			Account:  sc.Account,
+			Service:  sc.Service,
+			Resource: sc.Resource,
+			Tags:     sc.Tags,
+		},
+		Shares: normalizeShares(weights),
+	}, nil
+}
+
+// TagShares reads a cost center tag value naming several owners, such as
+// "team-a:60,team-b:40" or "team-a,team-b", by the rules application tags
+// are split with (see normalizer.ParseSplit). It returns nil for a single
+// cost center name and for values that are not a valid split, which are
+// then taken as a name.
+func TagShares(value string) []Share {
+	splits, err := normalizer.ParseSplit(value)
+	if err != nil || len(splits) == 0 || (len(splits) == 1 && splits[0].Key == value) {
+		return nil
+	}
+	weights := make(map[string]float64, len(splits))
+	for _, s := range splits {
+		weights[s.Key] += s.Weight
+	}
+	return normalizeShares(weights)
+}
+
+// normalizeShares turns weights into fractions, largest first
+func normalizeShares(weights map[string]float64) []Share {
+	var total float64
+	for _, w := range weights {
+		total += w
+	}
+	shares := make([]Share, 0, len(weights))
+	for cc, w := range weights {
+		shares = append(shares, Share{CostCenter: cc, Fraction: w / total})
+	}
+	sort.Slice(shares, func(i, j int) bool {
+		if shares[i].Fraction != shares[j].Fraction {
+			return shares[i].Fraction > shares[j].Fraction
+		}
+		return shares[i].CostCenter < shares[j].CostCenter
+	})
+	return shares
+}
+
+// shares returns how a record is split: by the first split rule matching
+// it, else by its cost center tag value when that names several owners. It
+// returns nil for single ownership.
+func (a *Allocator) shares(r normalizer.CostRecord, costCenter string) []Share {
+	for _, s := range a.config.Splits {
+		if s.Match.Matches(r) {
+			return s.Shares
+		}
+	}
+	return TagShares(costCenter)
+}
+
+// overridden reports whether an override covers a record; overrides take
+// precedence over splits
+func (a *Allocator) overridden(r normalizer.CostRecord) bool {
+	for _, o := range a.config.Overrides {
+		if o.appliesTo(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitRecord divides a record's cost, usage and emissions between shares,
+// the parts' costs adding up to the record's
+func splitRecord(r normalizer.CostRecord, shares []Share) []normalizer.CostRecord {
+	fractions := make([]float64, len(shares))
+	for i, s := range shares {
+		fractions[i] = s.Fraction
+	}
+	costs := money.Split(r.Cost, fractions)
+
+	parts := make([]normalizer.CostRecord, len(shares))
+	for i, s := range shares {
+		part := r
+		part.Cost = costs[i]
+		part.RawCost = money.Mul(r.RawCost, s.Fraction)
+		part.EffectiveCost = money.Mul(r.EffectiveCost, s.Fraction)
+		part.OriginalCost = money.Mul(r.OriginalCost, s.Fraction)
+		part.UsageQuantity *= s.Fraction
+		part.EmissionsKg *= s.Fraction
+		parts[i] = part
+	}
+	return parts
+}
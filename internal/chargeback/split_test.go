@@ -0,0 +1,132 @@
+package chargeback
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+func TestTagShares(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []Share
+	}{
+		{"team-a:60,team-b:40", []Share{{"team-a", 0.6}, {"team-b", 0.4}}},
+		{"team-a:60%,team-b:40%", []Share{{"team-a", 0.6}, {"team-b", 0.4}}},
+		{" team-b : 1 , team-a: 3 ", []Share{{"team-a", 0.75}, {"team-b", 0.25}}},
+		{"team-b,team-a", []Share{{"team-a", 0.5}, {"team-b", 0.5}}},
+		{"team-a:1,team-a:1", []Share{{"team-a", 1}}},
+		{"team-a:100", []Share{{"team-a", 1}}},
+		{"team-a", nil},
+		{"", nil},
+		{"team-a:60,team-b", nil},
+		{"team-a:0,team-b:10", nil},
+		{"team-a:ten", nil},
+		{":50", nil},
+	}
+	for _, tt := range tests {
+		if got := TagShares(tt.value); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("TagShares(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+
+	// A tag splits the same way for chargeback as for applications
+	for _, value := range []string{"web:60%,data:40%", "web,data", "web:3,data:1"} {
+		splits, err := normalizer.ParseSplit(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fractions := make(map[string]float64)
+		for _, s := range splits {
+			fractions[s.Key] = s.Weight
+		}
+		for _, share := range TagShares(value) {
+			if share.Fraction != fractions[share.CostCenter] {
+				t.Errorf("%s: %s has %v for chargeback and %v for applications", value, share.CostCenter, share.Fraction, fractions[share.CostCenter])
+			}
+		}
+		if len(TagShares(value)) != len(fractions) {
+			t.Errorf("%s: %v for chargeback, %v for applications", value, TagShares(value), splits)
+		}
+	}
+}
+
+func TestConfigFromSplits(t *testing.T) {
+	ac, err := ConfigFrom(config.ChargebackConfig{PrimaryTag: "cost_center", Splits: []config.AllocationSplit{
+		{ID: "eks", Service: "EKS", Tags: map[string]string{"cluster": "shared"}, Shares: map[string]float64{"CC-1": 2, "CC-2": 1, "CC-3": 1}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Split{{
+		ID:     "eks",
+		Match:  RecordMatcher{Service: "EKS", Tags: map[string]string{"cluster": "shared"}},
+		Shares: []Share{{"CC-1", 0.5}, {"CC-2", 0.25}, {"CC-3", 0.25}},
+	}}
+	if !reflect.DeepEqual(ac.Splits, want) {
+		t.Errorf("Splits = %+v, want %+v", ac.Splits, want)
+	}
+
+	for name, split := range map[string]config.AllocationSplit{
+		"no shares":       {ID: "s"},
+		"zero weight":     {ID: "s", Shares: map[string]float64{"CC-1": 0}},
+		"no cost center":  {ID: "s", Shares: map[string]float64{"": 1}},
+		"negative weight": {ID: "s", Shares: map[string]float64{"CC-1": 2, "CC-2": -1}},
+	} {
+		if _, err := ConfigFrom(config.ChargebackConfig{Splits: []config.AllocationSplit{split}}); err == nil {
+			t.Errorf("%s: ConfigFrom() succeeded, want an error", name)
+		}
+	}
+}
+
+func TestAllocateSplits(t *testing.T) {
+	a := NewAllocator(AllocatorConfig{
+		PrimaryTag: "cost_center",
+		Splits:     []Split{{ID: "eks", Match: RecordMatcher{Service: "EKS"}, Shares: []Share{{"CC-1", 0.5}, {"CC-2", 0.25}, {"CC-3", 0.25}}}},
+		Overrides:  []Override{{ID: "o1", Match: RecordMatcher{Service: "RDS"}, CostCenter: "CC-9"}},
+	})
+	cluster := record("CC-4", "EKS", 10.01)
+	cluster.UsageQuantity = 100
+	cluster.EmissionsKg = 8
+	records := []normalizer.CostRecord{
+		cluster,
+		record("CC-1:2,CC-2:1", "S3", 30),
+		record("CC-1:1,CC-2:1", "RDS", 7), // the override takes precedence
+		record("CC-2", "EC2", 5),
+	}
+	allocations := a.Allocate(records)
+
+	want := map[string]float64{"CC-1": 25.005, "CC-2": 17.5025, "CC-3": 2.5025, "CC-9": 7}
+	got := make(map[string]float64)
+	for cc, alloc := range allocations {
+		got[cc] = alloc.TotalCost
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("allocations = %v, want %v", got, want)
+	}
+
+	// The parts add up to the charge and carry their share of its usage
+	var cost, usage, emissions float64
+	for _, cc := range []string{"CC-1", "CC-2", "CC-3"} {
+		for _, r := range allocations[cc].Records {
+			if r.Service == "EKS" {
+				cost += r.Cost
+				usage += r.UsageQuantity
+				emissions += r.EmissionsKg
+			}
+		}
+	}
+	if cost != 10.01 || usage != 100 || emissions != 8 {
+		t.Errorf("split parts total %v cost, %v usage, %v kg; want the charge's 10.01, 100 and 8", cost, usage, emissions)
+	}
+
+	// A split charge is attributed to its largest share
+	if cc := a.CostCenter(cluster); cc != "CC-1" {
+		t.Errorf("CostCenter(cluster) = %q, want CC-1", cc)
+	}
+	if cc := a.CostCenter(record("CC-2:3,CC-1:1", "S3", 1)); cc != "CC-2" {
+		t.Errorf("CostCenter(split tag) = %q, want CC-2", cc)
+	}
+}
@@ -0,0 +1,268 @@
+package chargeback
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/lvonguyen/finops-platform/internal/money"
+)
+
+// TrendHighlight is how many of the fastest-growing cost centers a trend
+// flags
+const TrendHighlight = 3
+
+// TrendPoint is a cost center's spend in one month
+type TrendPoint struct {
+	Month  string  `json:"month"`
+	Cost   float64 `json:"cost"`
+	Share  float64 `json:"share"`  // percent of org spend that month
+	Growth float64 `json:"growth"` // percent change from the previous month, 0 when there was no spend
+}
+
+// CenterTrend is one cost center's spend across the trend's months
+type CenterTrend struct {
+	CostCenter string       `json:"cost_center"`
+	Points     []TrendPoint `json:"points"` // one per month, zero cost where the center had no spend
+	Growth     float64      `json:"growth"` // compound monthly growth rate in percent, from first to last month with spend
+	Status     string       `json:"status"` // "new", "gone", or empty when present throughout
+	Fastest    bool         `json:"fastest"`
+}
+
+// Trend is a multi-month view of each cost center's spend and share
+type Trend struct {
+	Months  []string      `json:"months"`
+	Totals  []float64     `json:"totals"` // org spend per month
+	Centers []CenterTrend `json:"centers"`
+}
+
+// Center status values
+const (
+	TrendNew  = "new"  // no spend in the first month
+	TrendGone = "gone" // no spend in the last month
+)
+
+// BuildTrend combines monthly chargeback reports, in month order, into a
+// trend. Centers missing from a month get a zero point; the TrendHighlight
+// fastest-growing centers with at least two months of spend are flagged.
+func BuildTrend(reports []*Report) *Trend {
+	t := &Trend{
+		Months: make([]string, len(reports)),
+		Totals: make([]float64, len(reports)),
+	}
+
+	costs := make(map[string][]float64)
+	for i, r := range reports {
+		t.Months[i] = r.Month
+		t.Totals[i] = r.TotalCost
+		for _, alloc := range r.Allocations {
+			if costs[alloc.CostCenter] == nil {
+				costs[alloc.CostCenter] = make([]float64, len(reports))
+			}
+			costs[alloc.CostCenter][i] = money.Add(costs[alloc.CostCenter][i], alloc.TotalCost)
+		}
+	}
+
+	for center, monthly := range costs {
+		ct := CenterTrend{CostCenter: center, Points: make([]TrendPoint, len(monthly))}
+		for i, cost := range monthly {
+			p := TrendPoint{Month: t.Months[i], Cost: cost}
+			if t.Totals[i] != 0 {
+				p.Share = cost / t.Totals[i] * 100
+			}
+			if i > 0 && monthly[i-1] > 0 {
+				p.Growth = (cost - monthly[i-1]) / monthly[i-1] * 100
+			}
+			ct.Points[i] = p
+		}
+
+		last := len(monthly) - 1
+		switch {
+		case monthly[0] <= 0 && monthly[last] > 0:
+			ct.Status = TrendNew
+		case monthly[0] > 0 && monthly[last] <= 0:
+			ct.Status = TrendGone
+		}
+		ct.Growth = compoundGrowth(monthly)
+
+		t.Centers = append(t.Centers, ct)
+	}
+
+	// Largest spenders in the latest month first
+	sort.Slice(t.Centers, func(i, j int) bool {
+		a, b := t.Centers[i].latest(), t.Centers[j].latest()
+		if a != b {
+			return a > b
+		}
+		return t.Centers[i].CostCenter < t.Centers[j].CostCenter
+	})
+
+	for _, i := range t.fastestIndexes(TrendHighlight) {
+		t.Centers[i].Fastest = true
+	}
+
+	return t
+}
+
+// latest returns the center's cost in the trend's last month
+func (c CenterTrend) latest() float64 {
+	if len(c.Points) == 0 {
+		return 0
+	}
+	return c.Points[len(c.Points)-1].Cost
+}
+
+// compoundGrowth is the compound monthly growth rate in percent between the
+// first and last months with spend. A center whose spend stopped reports
+// -100; one with fewer than two months of spend reports 0.
+func compoundGrowth(monthly []float64) float64 {
+	first, last := -1, -1
+	for i, cost := range monthly {
+		if cost > 0 {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+	}
+	if first < 0 {
+		return 0
+	}
+	if last < len(monthly)-1 {
+		return -100
+	}
+	if last == first {
+		return 0
+	}
+	return (math.Pow(monthly[last]/monthly[first], 1/float64(last-first)) - 1) * 100
+}
+
+// fastestIndexes returns the indexes of the n centers with the highest
+// positive growth over at least two months of spend
+func (t *Trend) fastestIndexes(n int) []int {
+	var candidates []int
+	for i, c := range t.Centers {
+		months := 0
+		for _, p := range c.Points {
+			if p.Cost > 0 {
+				months++
+			}
+		}
+		if months >= 2 && c.Growth > 0 {
+			candidates = append(candidates, i)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return t.Centers[candidates[i]].Growth > t.Centers[candidates[j]].Growth
+	})
+	if len(candidates) > n {
+		candidates = candidates[:n]
+	}
+	return candidates
+}
+
+// Period describes the trend's month range, e.g. "2024-01 to 2024-06"
+func (t *Trend) Period() string {
+	if len(t.Months) == 0 {
+		return ""
+	}
+	return t.Months[0] + " to " + t.Months[len(t.Months)-1]
+}
+
+// Fastest returns the flagged fastest-growing centers, fastest first
+func (t *Trend) Fastest() []CenterTrend {
+	var fastest []CenterTrend
+	for _, c := range t.Centers {
+		if c.Fastest {
+			fastest = append(fastest, c)
+		}
+	}
+	sort.Slice(fastest, func(i, j int) bool { return fastest[i].Growth > fastest[j].Growth })
+	return fastest
+}
+
+// Sparkline returns SVG polyline points plotting the center's monthly cost
+// in a width x height box, scaled to the center's own peak
+func (c CenterTrend) Sparkline(width, height int) string {
+	if len(c.Points) == 0 {
+		return ""
+	}
+
+	var peak float64
+	for _, p := range c.Points {
+		peak = math.Max(peak, p.Cost)
+	}
+
+	step := 0.0
+	if len(c.Points) > 1 {
+		step = float64(width) / float64(len(c.Points)-1)
+	}
+
+	coords := make([]string, len(c.Points))
+	for i, p := range c.Points {
+		y := float64(height)
+		if peak > 0 {
+			y -= p.Cost / peak * float64(height)
+		}
+		coords[i] = fmt.Sprintf("%.1f,%.1f", float64(i)*step, y)
+	}
+	return strings.Join(coords, " ")
+}
+
+// SaveCSV writes one row per cost center and month
+func (t *Trend) SaveCSV(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	header := []string{"Cost Center", "Month", "Cost", "% of Total", "MoM Growth", "Compound Growth", "Status", "Fastest Growing"}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, c := range t.Centers {
+		for _, p := range c.Points {
+			row := []string{
+				c.CostCenter,
+				p.Month,
+				fmt.Sprintf("%.2f", p.Cost),
+				fmt.Sprintf("%.1f%%", p.Share),
+				fmt.Sprintf("%.1f%%", p.Growth),
+				fmt.Sprintf("%.1f%%", c.Growth),
+				c.Status,
+				fmt.Sprintf("%t", c.Fastest),
+			}
+			if err := writer.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+
+	for i, month := range t.Months {
+		if err := writer.Write([]string{"TOTAL", month, fmt.Sprintf("%.2f", t.Totals[i]), "100.0%", "", "", "", ""}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SaveJSON saves the trend as a JSON file
+func (t *Trend) SaveJSON(path string) error {
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package chargeback
+
+import (
+	"math"
+	"testing"
+)
+
+// monthReport builds a chargeback report from cost center totals
+func monthReport(month string, costs map[string]float64) *Report {
+	r := &Report{Month: month}
+	for center, cost := range costs {
+		if cost == 0 {
+			continue
+		}
+		r.Allocations = append(r.Allocations, &Allocation{CostCenter: center, TotalCost: cost})
+		r.TotalCost += cost
+	}
+	return r
+}
+
+func TestBuildTrend(t *testing.T) {
+	monthly := map[string][3]float64{
+		"DOUBLING": {100, 200, 400},
+		"FLAT":     {100, 100, 100},
+		"NEW":      {0, 50, 100},
+		"GONE":     {100, 50, 0},
+		"SLOW":     {10, 11, 12.1},
+		"STEADY":   {100, 110, 121},
+	}
+	var reports []*Report
+	for i, month := range []string{"2024-01", "2024-02", "2024-03"} {
+		costs := make(map[string]float64)
+		for center, c := range monthly {
+			costs[center] = c[i]
+		}
+		reports = append(reports, monthReport(month, costs))
+	}
+
+	trend := BuildTrend(reports)
+	if trend.Period() != "2024-01 to 2024-03" || math.Abs(trend.Totals[2]-733.1) > 1e-9 {
+		t.Fatalf("period %s, totals %v", trend.Period(), trend.Totals)
+	}
+
+	want := []struct {
+		center  string
+		growth  float64
+		status  string
+		fastest bool
+	}{
+		{"DOUBLING", 100, "", true},
+		{"STEADY", 10, "", true},
+		{"FLAT", 0, "", false},
+		{"NEW", 100, TrendNew, true},
+		{"SLOW", 10, "", false},
+		{"GONE", -100, TrendGone, false},
+	}
+	if len(trend.Centers) != len(want) {
+		t.Fatalf("got %d centers, want %d", len(trend.Centers), len(want))
+	}
+	for i, w := range want {
+		c := trend.Centers[i]
+		if c.CostCenter != w.center || math.Abs(c.Growth-w.growth) > 1e-9 || c.Status != w.status || c.Fastest != w.fastest {
+			t.Errorf("center %d = %s growth %.4f status %q fastest %v, want %+v", i, c.CostCenter, c.Growth, c.Status, c.Fastest, w)
+		}
+	}
+
+	gone := trend.Centers[5]
+	if p := gone.Points[2]; p.Cost != 0 || p.Growth != -100 || p.Share != 0 {
+		t.Errorf("GONE last point = %+v, want no spend, -100%% growth", p)
+	}
+	if p := trend.Centers[3].Points[1]; p.Growth != 0 || math.Abs(p.Share-50/521.0*100) > 1e-9 {
+		t.Errorf("NEW first spend point = %+v, want no growth and its share", p)
+	}
+	if got := trend.Fastest(); len(got) != 3 || got[2].CostCenter != "STEADY" {
+		t.Errorf("Fastest = %+v, want STEADY last of 3", got)
+	}
+	if got := trend.Centers[0].Sparkline(100, 20); got != "0.0,15.0 50.0,10.0 100.0,0.0" {
+		t.Errorf("Sparkline = %q", got)
+	}
+}
@@ -0,0 +1,320 @@
+package chargeback
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Workbook sheets
+const (
+	sheetSummary  = "Summary"
+	sheetDetail   = "Detail"
+	sheetUntagged = "Untagged"
+)
+
+// SaveXLSX saves the report as an Excel workbook for finance: a summary of
+// every cost center, each center's charges by service, and the untagged
+// charges. Totals and shares are formulas, so edits recalculate.
+func (r *Report) SaveXLSX(path string) error {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	w := &workbook{f: f, base: r.BaseCurrency, money: make(map[string]int)}
+	if w.base == "" {
+		w.base = "USD"
+	}
+	if err := w.styles(); err != nil {
+		return fmt.Errorf("failed to create styles: %w", err)
+	}
+
+	if err := f.SetSheetName("Sheet1", sheetSummary); err != nil {
+		return err
+	}
+	for _, name := range []string{sheetDetail, sheetUntagged} {
+		if _, err := f.NewSheet(name); err != nil {
+			return err
+		}
+	}
+
+	w.summary(r)
+	w.detail(r)
+	w.untagged(r)
+	if w.err != nil {
+		return fmt.Errorf("failed to build workbook: %w", w.err)
+	}
+
+	f.SetActiveSheet(0)
+	if err := f.SaveAs(path); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	return nil
+}
+
+// workbook writes cells, keeping the first error
+type workbook struct {
+	f     *excelize.File
+	base  string
+	err   error
+	money map[string]int // currency -> number format style
+
+	title, header, bold, percent, boldPercent, rate int
+}
+
+func (w *workbook) styles() error {
+	var err error
+	newStyle := func(s *excelize.Style) int {
+		if err != nil {
+			return 0
+		}
+		var id int
+		id, err = w.f.NewStyle(s)
+		return id
+	}
+	border := []excelize.Border{{Type: "bottom", Color: "000000", Style: 1}}
+	pct := "0.0%"
+	rate := "0.000000"
+
+	w.title = newStyle(&excelize.Style{Font: &excelize.Font{Bold: true, Size: 14}})
+	w.header = newStyle(&excelize.Style{
+		Font:   &excelize.Font{Bold: true},
+		Fill:   excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"D9E1F2"}},
+		Border: border,
+	})
+	w.bold = newStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	w.percent = newStyle(&excelize.Style{CustomNumFmt: &pct})
+	w.boldPercent = newStyle(&excelize.Style{CustomNumFmt: &pct, Font: &excelize.Font{Bold: true}})
+	w.rate = newStyle(&excelize.Style{CustomNumFmt: &rate})
+	return err
+}
+
+// moneyStyle returns the style of amounts in a currency, bold for totals
+func (w *workbook) moneyStyle(currency string, bold bool) int {
+	key := currency
+	if bold {
+		key += "/bold"
+	}
+	if id, ok := w.money[key]; ok {
+		return id
+	}
+	format := fmt.Sprintf(`#,##0.00 "%s";-#,##0.00 "%s"`, currency, currency)
+	if currency == "USD" {
+		format = `"$"#,##0.00;-"$"#,##0.00`
+	}
+	style := &excelize.Style{CustomNumFmt: &format}
+	if bold {
+		style.Font = &excelize.Font{Bold: true}
+	}
+	id, err := w.f.NewStyle(style)
+	if err != nil && w.err == nil {
+		w.err = err
+	}
+	w.money[key] = id
+	return id
+}
+
+func (w *workbook) set(sheet string, col, row int, value any, style int) {
+	if w.err != nil {
+		return
+	}
+	cell, _ := excelize.CoordinatesToCellName(col, row)
+	if err := w.f.SetCellValue(sheet, cell, value); err != nil {
+		w.err = err
+		return
+	}
+	w.style(sheet, col, row, style)
+}
+
+func (w *workbook) formula(sheet string, col, row int, formula string, style int) {
+	if w.err != nil {
+		return
+	}
+	cell, _ := excelize.CoordinatesToCellName(col, row)
+	if err := w.f.SetCellFormula(sheet, cell, formula); err != nil {
+		w.err = err
+		return
+	}
+	w.style(sheet, col, row, style)
+}
+
+func (w *workbook) style(sheet string, col, row, style int) {
+	if style == 0 || w.err != nil {
+		return
+	}
+	cell, _ := excelize.CoordinatesToCellName(col, row)
+	w.err = w.f.SetCellStyle(sheet, cell, cell, style)
+}
+
+// headerRow writes column headings, freezes the rows above and including
+// them, and sizes the columns
+func (w *workbook) headerRow(sheet string, row int, headings []string, widths []float64) {
+	for i, h := range headings {
+		w.set(sheet, i+1, row, h, w.header)
+		col, _ := excelize.ColumnNumberToName(i + 1)
+		if w.err == nil && i < len(widths) {
+			w.err = w.f.SetColWidth(sheet, col, col, widths[i])
+		}
+	}
+	if w.err == nil {
+		topLeft, _ := excelize.CoordinatesToCellName(1, row+1)
+		w.err = w.f.SetPanes(sheet, &excelize.Panes{
+			Freeze: true, YSplit: row, TopLeftCell: topLeft, ActivePane: "bottomLeft",
+		})
+	}
+}
+
+// cell names a cell for formulas
+func cell(col, row int) string {
+	name, _ := excelize.CoordinatesToCellName(col, row)
+	return name
+}
+
+// summary lists every cost center: direct, allocated shared and credited
+// amounts, the net total and its share of the whole
+func (w *workbook) summary(r *Report) {
+	const sheet = sheetSummary
+	w.set(sheet, 1, 1, "Chargeback "+r.Month, w.title)
+	w.set(sheet, 1, 2, "Generated", 0)
+	w.set(sheet, 2, 2, r.Generated.Format("2006-01-02 15:04"), 0)
+	w.set(sheet, 1, 3, "Currency", 0)
+	w.set(sheet, 2, 3, w.base, 0)
+
+	local := false
+	for _, alloc := range r.Allocations {
+		if alloc.Currency != "" {
+			local = true
+			break
+		}
+	}
+
+	headings := []string{"Cost Center", "Direct", "Allocated", "Credits", "Total", "Share"}
+	widths := []float64{28, 16, 16, 16, 16, 10}
+	if local {
+		headings = append(headings, "Billing Currency", "Exchange Rate", "Local Total")
+		widths = append(widths, 16, 14, 18)
+	}
+	const head = 5
+	w.headerRow(sheet, head, headings, widths)
+
+	first := head + 1
+	last := head + len(r.Allocations)
+	total := last + 1
+	money := w.moneyStyle(w.base, false)
+	for i, alloc := range r.Allocations {
+		row := first + i
+		w.set(sheet, 1, row, alloc.CostCenter, 0)
+		w.set(sheet, 2, row, alloc.DirectCost, money)
+		w.set(sheet, 3, row, alloc.AllocatedCost, money)
+		w.set(sheet, 4, row, alloc.Credits, money)
+		w.formula(sheet, 5, row, fmt.Sprintf("%s+%s-%s", cell(2, row), cell(3, row), cell(4, row)), money)
+		w.formula(sheet, 6, row, fmt.Sprintf("IF($E$%d=0,0,%s/$E$%d)", total, cell(5, row), total), w.percent)
+		if local && alloc.Currency != "" {
+			w.set(sheet, 7, row, alloc.Currency, 0)
+			w.set(sheet, 8, row, alloc.ExchangeRate, w.rate)
+			w.formula(sheet, 9, row, fmt.Sprintf("%s*%s", cell(5, row), cell(8, row)), w.moneyStyle(alloc.Currency, false))
+		}
+	}
+
+	boldMoney := w.moneyStyle(w.base, true)
+	w.set(sheet, 1, total, "Total", w.bold)
+	for col := 2; col <= 5; col++ {
+		w.formula(sheet, col, total, sumRange(col, first, last), boldMoney)
+	}
+	w.formula(sheet, 6, total, sumRange(6, first, last), w.boldPercent)
+}
+
+// detail lists each cost center's direct and shared charges by service,
+// with a subtotal per center. Credits are on the summary only.
+func (w *workbook) detail(r *Report) {
+	const sheet = sheetDetail
+	w.headerRow(sheet, 1, []string{"Cost Center", "Service", "Direct", "Shared", "Total"}, []float64{28, 36, 16, 16, 16})
+	money := w.moneyStyle(w.base, false)
+	boldMoney := w.moneyStyle(w.base, true)
+
+	row := 2
+	for _, alloc := range r.Allocations {
+		services := make(map[string]bool)
+		for s := range alloc.ByService {
+			services[s] = true
+		}
+		for s := range alloc.SharedByService {
+			services[s] = true
+		}
+		names := make([]string, 0, len(services))
+		for s := range services {
+			names = append(names, s)
+		}
+		sort.Slice(names, func(i, j int) bool {
+			ci := alloc.ByService[names[i]] + alloc.SharedByService[names[i]]
+			cj := alloc.ByService[names[j]] + alloc.SharedByService[names[j]]
+			if ci != cj {
+				return ci > cj
+			}
+			return names[i] < names[j]
+		})
+
+		first := row
+		for _, s := range names {
+			w.set(sheet, 1, row, alloc.CostCenter, 0)
+			w.set(sheet, 2, row, s, 0)
+			w.set(sheet, 3, row, alloc.ByService[s], money)
+			w.set(sheet, 4, row, alloc.SharedByService[s], money)
+			w.formula(sheet, 5, row, fmt.Sprintf("%s+%s", cell(3, row), cell(4, row)), money)
+			row++
+		}
+
+		w.set(sheet, 1, row, alloc.CostCenter+" total", w.bold)
+		for col := 3; col <= 5; col++ {
+			w.formula(sheet, col, row, subtotalRange(col, first, row-1), boldMoney)
+		}
+		row++
+	}
+
+	w.set(sheet, 1, row, "Total", w.bold)
+	for col := 3; col <= 5; col++ {
+		w.formula(sheet, col, row, subtotalRange(col, 2, row-1), boldMoney)
+	}
+}
+
+// untagged breaks down the charges no cost center tag claimed
+func (w *workbook) untagged(r *Report) {
+	const sheet = sheetUntagged
+	w.headerRow(sheet, 1, []string{"Cloud", "Account", "Service", "Records", "Cost", "Share"}, []float64{12, 28, 36, 10, 16, 10})
+	money := w.moneyStyle(w.base, false)
+
+	first := 2
+	last := first + len(r.Untagged) - 1
+	total := last + 1
+	for i, u := range r.Untagged {
+		row := first + i
+		w.set(sheet, 1, row, u.Cloud, 0)
+		w.set(sheet, 2, row, u.Account, 0)
+		w.set(sheet, 3, row, u.Service, 0)
+		w.set(sheet, 4, row, u.Records, 0)
+		w.set(sheet, 5, row, u.Cost, money)
+		w.formula(sheet, 6, row, fmt.Sprintf("IF($E$%d=0,0,%s/$E$%d)", total, cell(5, row), total), w.percent)
+	}
+
+	w.set(sheet, 1, total, "Total", w.bold)
+	w.formula(sheet, 4, total, sumRange(4, first, last), w.bold)
+	w.formula(sheet, 5, total, sumRange(5, first, last), w.moneyStyle(w.base, true))
+	w.formula(sheet, 6, total, sumRange(6, first, last), w.boldPercent)
+}
+
+// sumRange sums a column over rows [first, last], 0 when empty
+func sumRange(col, first, last int) string {
+	if last < first {
+		return "0"
+	}
+	return fmt.Sprintf("SUM(%s:%s)", cell(col, first), cell(col, last))
+}
+
+// subtotalRange sums a column over rows [first, last], skipping the
+// subtotals within it
+func subtotalRange(col, first, last int) string {
+	if last < first {
+		return "0"
+	}
+	return fmt.Sprintf("SUBTOTAL(9,%s:%s)", cell(col, first), cell(col, last))
+}
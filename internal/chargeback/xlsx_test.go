@@ -0,0 +1,173 @@
+package chargeback
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+// xlsxReport has a center billed in another currency, shared and credited
+// amounts and untagged charges
+func xlsxReport() *Report {
+	return &Report{
+		Month:        "2024-03",
+		Generated:    time.Date(2024, 4, 1, 8, 0, 0, 0, time.UTC),
+		BaseCurrency: "USD",
+		Allocations: []*Allocation{
+			{
+				CostCenter: "CC-1", DirectCost: 100, AllocatedCost: 20, Credits: 10,
+				ByService:       map[string]float64{"EC2": 80, "S3": 20},
+				SharedByService: map[string]float64{"EC2": 20},
+			},
+			{
+				CostCenter: "CC-2", DirectCost: 50, Currency: "EUR", ExchangeRate: 0.9,
+				ByService: map[string]float64{"RDS": 50},
+			},
+		},
+		Untagged: []UntaggedCharge{
+			{Cloud: "aws", Account: "111", Service: "EC2", Records: 2, Cost: 40},
+			{Cloud: "aws", Account: "222", Service: "S3", Records: 1, Cost: 10},
+		},
+	}
+}
+
+// openXLSX saves the report as a workbook and opens it
+func openXLSX(t *testing.T, r *Report) *excelize.File {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "chargeback.xlsx")
+	if err := r.SaveXLSX(path); err != nil {
+		t.Fatal(err)
+	}
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+func TestSaveXLSX(t *testing.T) {
+	f := openXLSX(t, xlsxReport())
+
+	if got := f.GetSheetList(); len(got) != 3 || got[0] != sheetSummary || got[1] != sheetDetail || got[2] != sheetUntagged {
+		t.Fatalf("sheets = %v, want Summary, Detail and Untagged", got)
+	}
+
+	tests := []struct {
+		sheet, cell string
+		want        string
+	}{
+		{sheetSummary, "A1", "Chargeback 2024-03"},
+		{sheetSummary, "B3", "USD"},
+		{sheetSummary, "A6", "CC-1"},
+		{sheetSummary, "E6", "110"},
+		{sheetSummary, "F6", "0.6875"},
+		{sheetSummary, "G6", ""},
+		{sheetSummary, "G7", "EUR"},
+		{sheetSummary, "I7", "45"},
+		{sheetSummary, "A8", "Total"},
+		{sheetSummary, "D8", "10"},
+		{sheetSummary, "E8", "160"},
+		{sheetSummary, "F8", "1"},
+		{sheetDetail, "B2", "EC2"},
+		{sheetDetail, "E2", "100"},
+		{sheetDetail, "B3", "S3"},
+		{sheetDetail, "A4", "CC-1 total"},
+		{sheetDetail, "E4", "120"},
+		{sheetDetail, "B5", "RDS"},
+		{sheetDetail, "E6", "50"},
+		{sheetDetail, "A7", "Total"},
+		{sheetUntagged, "B2", "111"},
+		{sheetUntagged, "F2", "0.8"},
+		{sheetUntagged, "D4", "3"},
+		{sheetUntagged, "E4", "50"},
+	}
+	for _, tt := range tests {
+		got, err := f.CalcCellValue(tt.sheet, tt.cell, excelize.Options{RawCellValue: true})
+		if err != nil {
+			t.Errorf("%s!%s: %v", tt.sheet, tt.cell, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s!%s = %q, want %q", tt.sheet, tt.cell, got, tt.want)
+		}
+	}
+
+	// Totals stay formulas, so edits recalculate. The grand total of the
+	// detail skips the subtotals in Excel, though not in excelize's engine.
+	for _, c := range []struct{ sheet, cell, want string }{
+		{sheetSummary, "E6", "B6+C6-D6"},
+		{sheetSummary, "E8", "SUM(E6:E7)"},
+		{sheetDetail, "E7", "SUBTOTAL(9,E2:E6)"},
+	} {
+		if got, _ := f.GetCellFormula(c.sheet, c.cell); got != c.want {
+			t.Errorf("%s!%s formula = %q, want %q", c.sheet, c.cell, got, c.want)
+		}
+	}
+
+	// Amounts are formatted in the currency they are in
+	for cell, want := range map[string]string{
+		"E6": `"$"#,##0.00;-"$"#,##0.00`,
+		"I7": `#,##0.00 "EUR";-#,##0.00 "EUR"`,
+		"F6": "0.0%",
+	} {
+		id, err := f.GetCellStyle(sheetSummary, cell)
+		if err != nil {
+			t.Fatal(err)
+		}
+		style, err := f.GetStyle(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if style.CustomNumFmt == nil || *style.CustomNumFmt != want {
+			t.Errorf("Summary!%s number format = %v, want %s", cell, style.CustomNumFmt, want)
+		}
+	}
+}
+
+func TestSaveXLSXEmpty(t *testing.T) {
+	f := openXLSX(t, &Report{Month: "2024-03"})
+	for _, c := range []struct{ sheet, cell, want string }{
+		{sheetSummary, "B3", "USD"},
+		{sheetSummary, "E6", "0"},
+		{sheetDetail, "E2", "0"},
+		{sheetUntagged, "E2", "0"},
+	} {
+		if got, err := f.CalcCellValue(c.sheet, c.cell, excelize.Options{RawCellValue: true}); err != nil || got != c.want {
+			t.Errorf("%s!%s = %q, %v; want %q", c.sheet, c.cell, got, err, c.want)
+		}
+	}
+}
+
+func TestUntaggedCharges(t *testing.T) {
+	s3 := record("", "S3", 5)
+	s3.Account = "222"
+	a := NewAllocator(AllocatorConfig{PrimaryTag: "cost_center"})
+	a.Allocate([]normalizer.CostRecord{
+		record("CC-1", "EC2", 100),
+		record("", "EC2", 30),
+		record("", "EC2", 10),
+		s3,
+	})
+
+	want := []UntaggedCharge{
+		{Cloud: "aws", Account: "111", Service: "EC2", Records: 2, Cost: 40},
+		{Cloud: "aws", Account: "222", Service: "S3", Records: 1, Cost: 5},
+	}
+	got := a.UntaggedCharges()
+	if len(got) != len(want) {
+		t.Fatalf("UntaggedCharges() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("UntaggedCharges()[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	if a.UntaggedCost() != 45 {
+		t.Errorf("UntaggedCost() = %v, want 45", a.UntaggedCost())
+	}
+}
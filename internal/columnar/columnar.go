@@ -0,0 +1,171 @@
+// Package columnar reads and writes Apache Parquet and Arrow IPC files with
+// arrow-go, so reports land in lakehouse tools and billing exports in
+// Parquet read the same way as their CSV counterparts
+package columnar
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet"
+	"github.com/apache/arrow-go/v18/parquet/compress"
+	"github.com/apache/arrow-go/v18/parquet/file"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+)
+
+// batchSize is the number of rows ReadRows decodes at a time
+const batchSize = 64 * 1024
+
+// writer hides the Close of the destination, which the arrow-go writers
+// would otherwise close; the caller owns it
+type writer struct{ io.Writer }
+
+// WriteParquet writes a record batch as a gzip-compressed Parquet file. The
+// Arrow schema is stored alongside, so timestamps keep their time zone.
+func WriteParquet(w io.Writer, rec arrow.Record) error {
+	props := parquet.NewWriterProperties(parquet.WithCompression(compress.Codecs.Gzip))
+	fw, err := pqarrow.NewFileWriter(rec.Schema(), writer{w}, props, pqarrow.NewArrowWriterProperties(pqarrow.WithStoreSchema()))
+	if err != nil {
+		return fmt.Errorf("failed to start Parquet file: %w", err)
+	}
+	if err := fw.Write(rec); err != nil {
+		fw.Close()
+		return fmt.Errorf("failed to write Parquet rows: %w", err)
+	}
+	if err := fw.Close(); err != nil {
+		return fmt.Errorf("failed to finish Parquet file: %w", err)
+	}
+	return nil
+}
+
+// WriteArrow writes a record batch as an Arrow IPC file
+func WriteArrow(w io.Writer, rec arrow.Record) error {
+	fw, err := ipc.NewFileWriter(writer{w}, ipc.WithSchema(rec.Schema()))
+	if err != nil {
+		return fmt.Errorf("failed to start Arrow file: %w", err)
+	}
+	if err := fw.Write(rec); err != nil {
+		fw.Close()
+		return fmt.Errorf("failed to write Arrow rows: %w", err)
+	}
+	if err := fw.Close(); err != nil {
+		return fmt.Errorf("failed to finish Arrow file: %w", err)
+	}
+	return nil
+}
+
+// Header returns the column names of a Parquet file
+func Header(r parquet.ReaderAtSeeker) ([]string, error) {
+	pf, err := file.NewParquetReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open Parquet file: %w", err)
+	}
+	defer pf.Close()
+	_, header, err := open(pf)
+	return header, err
+}
+
+// ReadRows reads a Parquet file a row at a time, passing fn the column
+// names and the row's cells as the text a CSV export would hold: numbers
+// in full precision, timestamps as RFC 3339 in UTC, dates as YYYY-MM-DD,
+// maps as JSON objects and nulls as empty strings. The row slice is reused
+// between calls.
+func ReadRows(ctx context.Context, r parquet.ReaderAtSeeker, fn func(header, row []string) error) error {
+	pf, err := file.NewParquetReader(r)
+	if err != nil {
+		return fmt.Errorf("failed to open Parquet file: %w", err)
+	}
+	defer pf.Close()
+
+	fr, header, err := open(pf)
+	if err != nil {
+		return err
+	}
+
+	rr, err := fr.GetRecordReader(ctx, nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to read Parquet rows: %w", err)
+	}
+	defer rr.Release()
+
+	row := make([]string, len(header))
+	for rr.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rec := rr.Record()
+		for i := 0; i < int(rec.NumRows()); i++ {
+			for c := range row {
+				row[c] = Cell(rec.Column(c), i)
+			}
+			if err := fn(header, row); err != nil {
+				return err
+			}
+		}
+	}
+	if err := rr.Err(); err != nil && err != io.EOF {
+		return fmt.Errorf("failed to read Parquet rows: %w", err)
+	}
+	return nil
+}
+
+// open reads the Arrow schema of a Parquet file
+func open(pf *file.Reader) (*pqarrow.FileReader, []string, error) {
+	fr, err := pqarrow.NewFileReader(pf, pqarrow.ArrowReadProperties{BatchSize: batchSize}, memory.DefaultAllocator)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read Parquet schema: %w", err)
+	}
+	schema, err := fr.Schema()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read Parquet schema: %w", err)
+	}
+	header := make([]string, schema.NumFields())
+	for i, f := range schema.Fields() {
+		header[i] = f.Name
+	}
+	return fr, header, nil
+}
+
+// Cell returns the text of one value of a column, as ReadRows passes it
+func Cell(col arrow.Array, i int) string {
+	if col.IsNull(i) {
+		return ""
+	}
+	switch a := col.(type) {
+	case *array.String:
+		return a.Value(i)
+	case *array.LargeString:
+		return a.Value(i)
+	case *array.Binary:
+		return string(a.Value(i))
+	case *array.Float64:
+		return strconv.FormatFloat(a.Value(i), 'f', -1, 64)
+	case *array.Float32:
+		return strconv.FormatFloat(float64(a.Value(i)), 'f', -1, 32)
+	case *array.Timestamp:
+		unit := a.DataType().(*arrow.TimestampType).Unit
+		return a.Value(i).ToTime(unit).UTC().Format(time.RFC3339)
+	case *array.Date32:
+		return a.Value(i).ToTime().Format("2006-01-02")
+	case *array.Date64:
+		return a.Value(i).ToTime().Format("2006-01-02")
+	case *array.Map:
+		start, end := a.ValueOffsets(i)
+		keys, items := a.Keys(), a.Items()
+		m := make(map[string]string, end-start)
+		for j := int(start); j < int(end); j++ {
+			m[Cell(keys, j)] = Cell(items, j)
+		}
+		b, _ := json.Marshal(m)
+		return string(b)
+	}
+	return col.ValueStr(i)
+}
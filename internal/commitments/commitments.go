@@ -0,0 +1,380 @@
+// Package commitments measures how much eligible usage runs on reservations,
+// savings plans and committed use discounts, how fully those commitments are
+// used, and what steady on-demand usage more commitments could cover
+package commitments
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+// Defaults of the commitments configuration
+const (
+	DefaultMinUtilization = 80 // percent
+	DefaultDiscountRate   = 30 // percent
+)
+
+// minSteadyDays is the shortest window steady-state usage is looked for in
+const minSteadyDays = 7
+
+// daysPerMonth turns a daily baseline into a monthly commitment
+const daysPerMonth = 30
+
+// defaultServices can be covered by commitments on every cloud, as
+// normalized service names or the CUR product codes they arrive as
+var defaultServices = []string{
+	"Compute", "Database", "Serverless",
+	"AmazonEC2", "AmazonRDS", "AmazonElastiCache", "AmazonRedshift", "AWSLambda",
+}
+
+// unusedChargeTypes are the line item types marking the unused part of a
+// commitment. CUR fee lines carry it as their effective cost, Azure and
+// FOCUS unused lines as the line's cost.
+var unusedChargeTypes = map[string]bool{
+	"RIFee":                   true,
+	"SavingsPlanRecurringFee": true,
+	"UnusedReservation":       true,
+	"UnusedSavingsPlan":       true,
+}
+
+// Options tunes the analysis
+type Options struct {
+	Services       map[string]bool // eligible services; empty for the defaults and any service seen covered
+	MinUtilization float64         // percent below which a commitment is under-utilized
+	DiscountRate   float64         // percent a new commitment saves over on-demand
+}
+
+// OptionsFrom builds the commitments configuration
+func OptionsFrom(cfg config.CommitmentsConfig) (Options, error) {
+	opts := Options{MinUtilization: cfg.MinUtilization, DiscountRate: cfg.DiscountRate}
+	if opts.MinUtilization == 0 {
+		opts.MinUtilization = DefaultMinUtilization
+	}
+	if opts.DiscountRate == 0 {
+		opts.DiscountRate = DefaultDiscountRate
+	}
+	if opts.MinUtilization < 0 || opts.MinUtilization > 100 {
+		return Options{}, fmt.Errorf("min_utilization must be between 0 and 100")
+	}
+	if opts.DiscountRate < 0 || opts.DiscountRate >= 100 {
+		return Options{}, fmt.Errorf("discount_rate must be between 0 and 100")
+	}
+	for _, s := range cfg.Services {
+		if opts.Services == nil {
+			opts.Services = make(map[string]bool)
+		}
+		opts.Services[s] = true
+	}
+	return opts, nil
+}
+
+// Analysis is the commitment coverage and utilization over a window
+type Analysis struct {
+	Start            time.Time          `json:"start"`
+	End              time.Time          `json:"end"`
+	Providers        []ProviderCoverage `json:"providers"`
+	Commitments      []Utilization      `json:"commitments"` // lowest utilization first
+	Uncovered        []SteadyUsage      `json:"uncovered"`   // largest savings first
+	UnusedCost       float64            `json:"unused_cost"`
+	PotentialSavings float64            `json:"potential_savings"` // monthly, from committing the uncovered baseline
+}
+
+// ProviderCoverage is one cloud's commitment coverage and utilization.
+// Coverage is the share of eligible usage cost running on commitments, at
+// effective cost; utilization is known only where the billing data reports
+// unused commitment.
+type ProviderCoverage struct {
+	Cloud              string  `json:"cloud"`
+	CoveredCost        float64 `json:"covered_cost"`
+	OnDemandCost       float64 `json:"on_demand_cost"` // eligible usage not covered
+	CoveragePercent    float64 `json:"coverage_percent"`
+	UsedCost           float64 `json:"used_cost"` // of commitments with known utilization
+	UnusedCost         float64 `json:"unused_cost"`
+	UtilizationPercent float64 `json:"utilization_percent"`
+	UtilizationKnown   bool    `json:"utilization_known"`
+}
+
+// Utilization is how much of one commitment was used
+type Utilization struct {
+	Cloud              string  `json:"cloud"`
+	Account            string  `json:"account"`
+	ID                 string  `json:"id"`
+	Name               string  `json:"name,omitempty"`
+	Type               string  `json:"type,omitempty"` // e.g. Reserved Instance, Savings Plan
+	UsedCost           float64 `json:"used_cost"`
+	UnusedCost         float64 `json:"unused_cost"`
+	UtilizationPercent float64 `json:"utilization_percent"`
+	Known              bool    `json:"known"`
+	UnderUtilized      bool    `json:"under_utilized"`
+}
+
+// SteadyUsage is on-demand usage of one scope that ran every day of the
+// window; its lowest day is a baseline a commitment could cover
+type SteadyUsage struct {
+	Cloud                   string  `json:"cloud"`
+	Account                 string  `json:"account"`
+	Service                 string  `json:"service"`
+	Region                  string  `json:"region"`
+	OnDemandCost            float64 `json:"on_demand_cost"`
+	BaselineDaily           float64 `json:"baseline_daily"`
+	EstimatedMonthlySavings float64 `json:"estimated_monthly_savings"`
+}
+
+// Analyze computes coverage and utilization of the records in [start, end).
+// Steady-state usage needs a window of at least a week.
+func Analyze(records []normalizer.CostRecord, start, end time.Time, opts Options) *Analysis {
+	eligible := opts.Services
+	if len(eligible) == 0 {
+		eligible = make(map[string]bool)
+		for _, s := range defaultServices {
+			eligible[s] = true
+		}
+		for _, r := range records {
+			if covered(r) {
+				eligible[r.Service] = true
+			}
+		}
+	}
+
+	type scopeKey struct{ cloud, account, service, region string }
+	providers := make(map[string]*ProviderCoverage)
+	byID := make(map[string]*Utilization)
+	daily := make(map[scopeKey]map[string]float64)
+	provider := func(cloud string) *ProviderCoverage {
+		p, ok := providers[cloud]
+		if !ok {
+			p = &ProviderCoverage{Cloud: cloud}
+			providers[cloud] = p
+		}
+		return p
+	}
+	commitment := func(r normalizer.CostRecord) *Utilization {
+		key := r.Cloud + "|" + r.CommitmentDiscountID
+		u, ok := byID[key]
+		if !ok {
+			u = &Utilization{Cloud: r.Cloud, Account: r.Account, ID: r.CommitmentDiscountID}
+			byID[key] = u
+		}
+		if u.Name == "" {
+			u.Name = r.CommitmentDiscountName
+		}
+		if u.Type == "" {
+			u.Type = r.CommitmentDiscountType
+		}
+		return u
+	}
+
+	for _, r := range records {
+		if r.Date.Before(start) || !r.Date.Before(end) {
+			continue
+		}
+		switch {
+		case unusedChargeTypes[r.LineItemType] && r.CommitmentDiscountID != "":
+			u := commitment(r)
+			// Fee lines are billed to the purchasing account
+			u.Account, u.Known = r.Account, true
+			if strings.HasSuffix(r.LineItemType, "Fee") {
+				u.UnusedCost += r.EffectiveCost
+			} else {
+				u.UnusedCost += r.Effective()
+			}
+		case covered(r):
+			cost := r.Effective()
+			provider(r.Cloud).CoveredCost += cost
+			if r.CommitmentDiscountID != "" {
+				commitment(r).UsedCost += cost
+			}
+		case onDemand(r) && eligible[r.Service]:
+			provider(r.Cloud).OnDemandCost += r.Cost
+			key := scopeKey{r.Cloud, r.Account, r.Service, r.Region}
+			if daily[key] == nil {
+				daily[key] = make(map[string]float64)
+			}
+			daily[key][r.Date.Format("2006-01-02")] += r.Cost
+		}
+	}
+
+	a := &Analysis{Start: start, End: end}
+	for _, u := range byID {
+		if u.Known {
+			p := provider(u.Cloud)
+			p.UsedCost += u.UsedCost
+			p.UnusedCost += u.UnusedCost
+			p.UtilizationKnown = true
+			if total := u.UsedCost + u.UnusedCost; total > 0 {
+				u.UtilizationPercent = u.UsedCost / total * 100
+			}
+			u.UnderUtilized = u.UnusedCost > 0 && u.UtilizationPercent < opts.MinUtilization
+			a.UnusedCost += u.UnusedCost
+		}
+		a.Commitments = append(a.Commitments, *u)
+	}
+	sort.Slice(a.Commitments, func(i, j int) bool {
+		ci, cj := a.Commitments[i], a.Commitments[j]
+		if ci.Known != cj.Known {
+			return ci.Known
+		}
+		if ci.UtilizationPercent != cj.UtilizationPercent {
+			return ci.UtilizationPercent < cj.UtilizationPercent
+		}
+		return ci.ID < cj.ID
+	})
+
+	for _, p := range providers {
+		if total := p.CoveredCost + p.OnDemandCost; total > 0 {
+			p.CoveragePercent = p.CoveredCost / total * 100
+		}
+		if total := p.UsedCost + p.UnusedCost; total > 0 {
+			p.UtilizationPercent = p.UsedCost / total * 100
+		}
+		a.Providers = append(a.Providers, *p)
+	}
+	sort.Slice(a.Providers, func(i, j int) bool { return a.Providers[i].Cloud < a.Providers[j].Cloud })
+
+	days := int(end.Sub(start).Hours() / 24)
+	if days >= minSteadyDays {
+		for key, byDay := range daily {
+			baseline, total := math.Inf(1), 0.0
+			for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+				cost := byDay[d.Format("2006-01-02")]
+				baseline = math.Min(baseline, cost)
+				total += cost
+			}
+			if baseline <= 0 {
+				continue
+			}
+			s := SteadyUsage{
+				Cloud:                   key.cloud,
+				Account:                 key.account,
+				Service:                 key.service,
+				Region:                  key.region,
+				OnDemandCost:            total,
+				BaselineDaily:           baseline,
+				EstimatedMonthlySavings: baseline * daysPerMonth * opts.DiscountRate / 100,
+			}
+			a.PotentialSavings += s.EstimatedMonthlySavings
+			a.Uncovered = append(a.Uncovered, s)
+		}
+	}
+	sort.Slice(a.Uncovered, func(i, j int) bool {
+		if a.Uncovered[i].EstimatedMonthlySavings != a.Uncovered[j].EstimatedMonthlySavings {
+			return a.Uncovered[i].EstimatedMonthlySavings > a.Uncovered[j].EstimatedMonthlySavings
+		}
+		return a.Uncovered[i].Service < a.Uncovered[j].Service
+	})
+	return a
+}
+
+// covered reports usage running on a commitment. Fees and purchases carry
+// the commitment too but have no effective cost of their own.
+func covered(r normalizer.CostRecord) bool {
+	if r.IsCredit() || unusedChargeTypes[r.LineItemType] {
+		return false
+	}
+	return r.CommitmentDiscountID != "" || r.PricingModel == "reserved" || r.PricingModel == "savings_plan"
+}
+
+// onDemand reports usage billed at on-demand rates; spot, credits, taxes
+// and fees are not
+func onDemand(r normalizer.CostRecord) bool {
+	if r.IsCredit() || r.Cost <= 0 || r.CommitmentDiscountID != "" {
+		return false
+	}
+	if r.PricingModel != "" && r.PricingModel != "on_demand" {
+		return false
+	}
+	return r.Charge() == normalizer.ChargeUsage
+}
+
+// SaveCSV writes the coverage and utilization of each provider
+func (a *Analysis) SaveCSV(path string) error {
+	rows := [][]string{{"Cloud", "Covered Cost", "On-Demand Cost", "Coverage %", "Used Commitment", "Unused Commitment", "Utilization %"}}
+	for _, p := range a.Providers {
+		utilization := ""
+		if p.UtilizationKnown {
+			utilization = fmt.Sprintf("%.1f", p.UtilizationPercent)
+		}
+		rows = append(rows, []string{
+			p.Cloud,
+			fmt.Sprintf("%.2f", p.CoveredCost),
+			fmt.Sprintf("%.2f", p.OnDemandCost),
+			fmt.Sprintf("%.1f", p.CoveragePercent),
+			fmt.Sprintf("%.2f", p.UsedCost),
+			fmt.Sprintf("%.2f", p.UnusedCost),
+			utilization,
+		})
+	}
+	return writeCSV(path, rows)
+}
+
+// SaveCommitmentsCSV writes one row per commitment
+func (a *Analysis) SaveCommitmentsCSV(path string) error {
+	rows := [][]string{{"Cloud", "Account", "Commitment", "Name", "Type", "Used Cost", "Unused Cost", "Utilization %", "Under-Utilized"}}
+	for _, u := range a.Commitments {
+		utilization := ""
+		if u.Known {
+			utilization = fmt.Sprintf("%.1f", u.UtilizationPercent)
+		}
+		rows = append(rows, []string{
+			u.Cloud,
+			u.Account,
+			u.ID,
+			u.Name,
+			u.Type,
+			fmt.Sprintf("%.2f", u.UsedCost),
+			fmt.Sprintf("%.2f", u.UnusedCost),
+			utilization,
+			fmt.Sprintf("%t", u.UnderUtilized),
+		})
+	}
+	return writeCSV(path, rows)
+}
+
+// SaveUncoveredCSV writes the steady on-demand usage by scope
+func (a *Analysis) SaveUncoveredCSV(path string) error {
+	rows := [][]string{{"Cloud", "Account", "Service", "Region", "On-Demand Cost", "Daily Baseline", "Estimated Monthly Savings"}}
+	for _, s := range a.Uncovered {
+		rows = append(rows, []string{
+			s.Cloud,
+			s.Account,
+			s.Service,
+			s.Region,
+			fmt.Sprintf("%.2f", s.OnDemandCost),
+			fmt.Sprintf("%.2f", s.BaselineDaily),
+			fmt.Sprintf("%.2f", s.EstimatedMonthlySavings),
+		})
+	}
+	return writeCSV(path, rows)
+}
+
+// SaveJSON writes the whole analysis
+func (a *Analysis) SaveJSON(path string) error {
+	data, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func writeCSV(path string, rows [][]string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	if err := writer.WriteAll(rows); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package commitments
+
+import (
+	"encoding/csv"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+var march1 = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+// near reports whether two costs or percentages agree to a fraction of a cent
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+// week returns a week from March 1 of AWS Compute in us-east-1 growing from
+// 10 a day on demand, 6 a day on reservation ri-1 with 18 of it unused; GCP
+// Compute on demand every day but the last; and Azure usage of a savings
+// plan, spot, storage and a record after the week that do not count
+func week() []normalizer.CostRecord {
+	var records []normalizer.CostRecord
+	for i := 0; i < 7; i++ {
+		d := march1.AddDate(0, 0, i)
+		records = append(records,
+			normalizer.CostRecord{Cloud: "aws", Account: "111", Service: "Compute", Region: "us-east-1", Date: d, Cost: 10 + float64(i), PricingModel: "on_demand"},
+			normalizer.CostRecord{Cloud: "aws", Account: "222", Service: "Compute", Region: "us-east-1", Date: d, EffectiveCost: 6, PricingModel: "reserved",
+				LineItemType: "DiscountedUsage", CommitmentDiscountID: "ri-1", CommitmentDiscountType: "Reserved Instance"},
+			normalizer.CostRecord{Cloud: "aws", Account: "111", Service: "Compute", Region: "us-east-1", Date: d, Cost: 4, PricingModel: "spot"},
+			normalizer.CostRecord{Cloud: "aws", Account: "111", Service: "Storage", Region: "us-east-1", Date: d, Cost: 3},
+		)
+		if i < 6 {
+			records = append(records, normalizer.CostRecord{Cloud: "gcp", Account: "p1", Service: "Compute", Region: "us-central1", Date: d, Cost: 5})
+		}
+	}
+	return append(records,
+		normalizer.CostRecord{Cloud: "aws", Account: "111", Service: "Compute", Date: march1, Cost: 60, EffectiveCost: 18,
+			LineItemType: "RIFee", CommitmentDiscountID: "ri-1", CommitmentDiscountName: "m5 fleet"},
+		normalizer.CostRecord{Cloud: "azure", Account: "sub-1", Service: "Compute", Date: march1, Cost: 20, PricingModel: "savings_plan",
+			CommitmentDiscountID: "sp-1", EffectiveCost: 20, CommitmentDiscountType: "Savings Plan"},
+		normalizer.CostRecord{Cloud: "aws", Account: "111", Service: "Compute", Region: "us-east-1", Date: march1.AddDate(0, 0, 7), Cost: 100},
+	)
+}
+
+func TestOptionsFrom(t *testing.T) {
+	opts, err := OptionsFrom(config.CommitmentsConfig{Services: []string{"Compute"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Options{Services: map[string]bool{"Compute": true}, MinUtilization: DefaultMinUtilization, DiscountRate: DefaultDiscountRate}
+	if !reflect.DeepEqual(opts, want) {
+		t.Errorf("OptionsFrom() = %+v, want %+v", opts, want)
+	}
+
+	for name, cfg := range map[string]config.CommitmentsConfig{
+		"negative utilization": {MinUtilization: -1},
+		"utilization over 100": {MinUtilization: 101},
+		"negative discount":    {DiscountRate: -5},
+		"discount of 100":      {DiscountRate: 100},
+	} {
+		if _, err := OptionsFrom(cfg); err == nil {
+			t.Errorf("%s: OptionsFrom() succeeded, want an error", name)
+		}
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	opts, _ := OptionsFrom(config.CommitmentsConfig{})
+	a := Analyze(week(), march1, march1.AddDate(0, 0, 7), opts)
+
+	// AWS: 42 on the reservation against 91 on demand, with 18 of the
+	// reservation unused
+	wantProviders := []ProviderCoverage{
+		{Cloud: "aws", CoveredCost: 42, OnDemandCost: 91, CoveragePercent: 42 / 133.0 * 100, UsedCost: 42, UnusedCost: 18, UtilizationPercent: 70, UtilizationKnown: true},
+		{Cloud: "azure", CoveredCost: 20, CoveragePercent: 100},
+		{Cloud: "gcp", OnDemandCost: 30},
+	}
+	if len(a.Providers) != len(wantProviders) {
+		t.Fatalf("Providers = %+v, want %+v", a.Providers, wantProviders)
+	}
+	for i, got := range a.Providers {
+		want := wantProviders[i]
+		if got.Cloud != want.Cloud || !near(got.CoveredCost, want.CoveredCost) || !near(got.OnDemandCost, want.OnDemandCost) ||
+			!near(got.CoveragePercent, want.CoveragePercent) || !near(got.UsedCost, want.UsedCost) || !near(got.UnusedCost, want.UnusedCost) ||
+			!near(got.UtilizationPercent, want.UtilizationPercent) || got.UtilizationKnown != want.UtilizationKnown {
+			t.Errorf("provider %d = %+v, want %+v", i, got, want)
+		}
+	}
+
+	// Known utilization first; the fee line names the reservation and moves
+	// it to the purchasing account
+	if len(a.Commitments) != 2 {
+		t.Fatalf("Commitments = %+v, want ri-1 and sp-1", a.Commitments)
+	}
+	ri := a.Commitments[0]
+	if ri.ID != "ri-1" || ri.Account != "111" || ri.Name != "m5 fleet" || ri.Type != "Reserved Instance" ||
+		!near(ri.UtilizationPercent, 70) || !ri.Known || !ri.UnderUtilized {
+		t.Errorf("commitment 0 = %+v, want ri-1 of 111 under-utilized at 70%%", ri)
+	}
+	if sp := a.Commitments[1]; sp.ID != "sp-1" || sp.Known || sp.UnderUtilized || sp.UsedCost != 20 {
+		t.Errorf("commitment 1 = %+v, want sp-1 with 20 used and unknown utilization", sp)
+	}
+
+	// Only AWS ran every day: a baseline of 10 committed for 30 days at 30% off
+	wantUncovered := []SteadyUsage{{Cloud: "aws", Account: "111", Service: "Compute", Region: "us-east-1", OnDemandCost: 91, BaselineDaily: 10, EstimatedMonthlySavings: 90}}
+	if !reflect.DeepEqual(a.Uncovered, wantUncovered) {
+		t.Errorf("Uncovered = %+v, want %+v", a.Uncovered, wantUncovered)
+	}
+	if a.UnusedCost != 18 || a.PotentialSavings != 90 {
+		t.Errorf("unused %v, savings %v; want 18 and 90", a.UnusedCost, a.PotentialSavings)
+	}
+}
+
+func TestAnalyzeOptions(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      Options
+		days      int
+		onDemand  float64 // AWS
+		uncovered int
+		under     bool
+	}{
+		{"short window", Options{MinUtilization: 80, DiscountRate: 30}, 6, 75, 0, true},
+		{"other services", Options{Services: map[string]bool{"Database": true}, MinUtilization: 80, DiscountRate: 30}, 7, 0, 0, true},
+		{"lower threshold", Options{MinUtilization: 60, DiscountRate: 30}, 7, 91, 1, false},
+	}
+	for _, tt := range tests {
+		a := Analyze(week(), march1, march1.AddDate(0, 0, tt.days), tt.opts)
+		if aws := a.Providers[0]; aws.OnDemandCost != tt.onDemand {
+			t.Errorf("%s: AWS on demand = %v, want %v", tt.name, aws.OnDemandCost, tt.onDemand)
+		}
+		if len(a.Uncovered) != tt.uncovered {
+			t.Errorf("%s: Uncovered = %+v, want %d", tt.name, a.Uncovered, tt.uncovered)
+		}
+		if ri := a.Commitments[0]; ri.UnderUtilized != tt.under {
+			t.Errorf("%s: ri-1 under-utilized = %v, want %v", tt.name, ri.UnderUtilized, tt.under)
+		}
+	}
+}
+
+func TestSaveCSV(t *testing.T) {
+	opts, _ := OptionsFrom(config.CommitmentsConfig{})
+	a := Analyze(week(), march1, march1.AddDate(0, 0, 7), opts)
+	path := filepath.Join(t.TempDir(), "commitments.csv")
+	if err := a.SaveCSV(path); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"Cloud", "Covered Cost", "On-Demand Cost", "Coverage %", "Used Commitment", "Unused Commitment", "Utilization %"},
+		{"aws", "42.00", "91.00", "31.6", "42.00", "18.00", "70.0"},
+		{"azure", "20.00", "0.00", "100.0", "0.00", "0.00", ""},
+		{"gcp", "0.00", "30.00", "0.0", "0.00", "0.00", ""},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows = %q, want %q", rows, want)
+	}
+}
@@ -0,0 +1,152 @@
+// Package compare analyzes period-over-period cost changes
+package compare
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+)
+
+// Dimension names used in deltas
+const (
+	DimensionProvider = "cloud"
+	DimensionService  = "service"
+	DimensionAccount  = "account"
+)
+
+// Delta is the change for a single key within a dimension
+type Delta struct {
+	Dimension     string  `json:"dimension"`
+	Key           string  `json:"key"`
+	Previous      float64 `json:"previous"`
+	Current       float64 `json:"current"`
+	Change        float64 `json:"change"`
+	PercentChange float64 `json:"percent_change"` // 0 when there was no previous spend
+	Contribution  float64 `json:"contribution"`   // percent of the total change
+}
+
+// Comparison holds the change between two aggregation results
+type Comparison struct {
+	PreviousTotal float64 `json:"previous_total"`
+	CurrentTotal  float64 `json:"current_total"`
+	Change        float64 `json:"change"`
+	PercentChange float64 `json:"percent_change"`
+	ByProvider    []Delta `json:"by_provider"`
+	ByService     []Delta `json:"by_service"`
+	ByAccount     []Delta `json:"by_account"`
+}
+
+// Compare computes the change from previous to current. Deltas in each
+// dimension are sorted by absolute change, largest first.
+func Compare(previous, current *aggregator.AggregationResult) *Comparison {
+	c := &Comparison{
+		PreviousTotal: previous.TotalCost,
+		CurrentTotal:  current.TotalCost,
+		Change:        current.TotalCost - previous.TotalCost,
+	}
+	c.PercentChange = percent(c.Change, previous.TotalCost)
+
+	c.ByProvider = deltas(DimensionProvider, previous.ByProvider, current.ByProvider, c.Change)
+	c.ByService = deltas(DimensionService, previous.ByService, current.ByService, c.Change)
+	c.ByAccount = deltas(DimensionAccount, previous.ByAccount, current.ByAccount, c.Change)
+
+	return c
+}
+
+// TopDriver returns the key whose change contributed most to the total
+// change, considering only keys that moved in the same direction
+func (c *Comparison) TopDriver() (Delta, bool) {
+	if c.Change == 0 {
+		return Delta{}, false
+	}
+
+	var best Delta
+	found := false
+	for _, dim := range [][]Delta{c.ByService, c.ByAccount, c.ByProvider} {
+		for _, d := range dim {
+			if (d.Change > 0) != (c.Change > 0) || d.Change == 0 {
+				continue
+			}
+			if !found || math.Abs(d.Change) > math.Abs(best.Change) {
+				best = d
+				found = true
+			}
+		}
+	}
+	return best, found
+}
+
+// Headline summarizes the change and its top driver in one sentence. It
+// returns an empty string when there is no prior spend to compare against.
+func (c *Comparison) Headline() string {
+	if c.PreviousTotal == 0 {
+		return ""
+	}
+
+	if c.Change == 0 {
+		return fmt.Sprintf("Spend flat vs prior period at $%.2f", c.CurrentTotal)
+	}
+	direction := "up"
+	if c.Change < 0 {
+		direction = "down"
+	}
+
+	headline := fmt.Sprintf("Spend %s %.1f%% ($%.2f) vs prior period", direction, math.Abs(c.PercentChange), math.Abs(c.Change))
+
+	driver, ok := c.TopDriver()
+	if !ok {
+		return headline
+	}
+
+	return fmt.Sprintf("%s, driven by %s %s (%s, %.0f%% of change)",
+		headline, driver.Dimension, driver.Key, signedDollars(driver.Change), driver.Contribution)
+}
+
+func signedDollars(v float64) string {
+	if v < 0 {
+		return fmt.Sprintf("-$%.2f", -v)
+	}
+	return fmt.Sprintf("+$%.2f", v)
+}
+
+func deltas(dimension string, previous, current map[string]float64, totalChange float64) []Delta {
+	keys := make(map[string]bool, len(current))
+	for k := range previous {
+		keys[k] = true
+	}
+	for k := range current {
+		keys[k] = true
+	}
+
+	result := make([]Delta, 0, len(keys))
+	for k := range keys {
+		d := Delta{
+			Dimension: dimension,
+			Key:       k,
+			Previous:  previous[k],
+			Current:   current[k],
+			Change:    current[k] - previous[k],
+		}
+		d.PercentChange = percent(d.Change, d.Previous)
+		d.Contribution = percent(d.Change, totalChange)
+		result = append(result, d)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if math.Abs(result[i].Change) != math.Abs(result[j].Change) {
+			return math.Abs(result[i].Change) > math.Abs(result[j].Change)
+		}
+		return result[i].Key < result[j].Key
+	})
+
+	return result
+}
+
+func percent(part, whole float64) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return part / whole * 100
+}
@@ -0,0 +1,98 @@
+package compare
+
+import (
+	"testing"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+)
+
+func result(byService map[string]float64, account string) *aggregator.AggregationResult {
+	r := &aggregator.AggregationResult{
+		ByProvider: map[string]float64{},
+		ByService:  byService,
+		ByAccount:  map[string]float64{},
+	}
+	for _, cost := range byService {
+		r.TotalCost += cost
+	}
+	r.ByProvider["aws"] = r.TotalCost
+	r.ByAccount[account] = r.TotalCost
+	return r
+}
+
+func TestCompare(t *testing.T) {
+	previous := result(map[string]float64{"EC2": 100, "S3": 50, "Lambda": 50}, "111")
+	current := result(map[string]float64{"EC2": 180, "S3": 40, "RDS": 30}, "111")
+
+	c := Compare(previous, current)
+	if c.Change != 50 || c.PercentChange != 25 {
+		t.Fatalf("change = %v (%v%%), want 50 (25%%)", c.Change, c.PercentChange)
+	}
+
+	want := []Delta{
+		{Dimension: DimensionService, Key: "EC2", Previous: 100, Current: 180, Change: 80, PercentChange: 80, Contribution: 160},
+		{Dimension: DimensionService, Key: "Lambda", Previous: 50, Current: 0, Change: -50, PercentChange: -100, Contribution: -100},
+		{Dimension: DimensionService, Key: "RDS", Previous: 0, Current: 30, Change: 30, PercentChange: 0, Contribution: 60},
+		{Dimension: DimensionService, Key: "S3", Previous: 50, Current: 40, Change: -10, PercentChange: -20, Contribution: -20},
+	}
+	if len(c.ByService) != len(want) {
+		t.Fatalf("got %d service deltas, want %d", len(c.ByService), len(want))
+	}
+	for i, d := range c.ByService {
+		if d != want[i] {
+			t.Errorf("delta %d = %+v, want %+v", i, d, want[i])
+		}
+	}
+}
+
+func TestHeadline(t *testing.T) {
+	tests := []struct {
+		name              string
+		previous, current map[string]float64
+		want              string
+	}{
+		{
+			"increase",
+			map[string]float64{"EC2": 100, "S3": 100},
+			map[string]float64{"EC2": 180, "S3": 90},
+			"Spend up 35.0% ($70.00) vs prior period, driven by service EC2 (+$80.00, 114% of change)",
+		},
+		{
+			"decrease",
+			map[string]float64{"EC2": 100, "S3": 100},
+			map[string]float64{"EC2": 110, "S3": 40},
+			"Spend down 25.0% ($50.00) vs prior period, driven by service S3 (-$60.00, 120% of change)",
+		},
+		{
+			"flat",
+			map[string]float64{"EC2": 100},
+			map[string]float64{"EC2": 100},
+			"Spend flat vs prior period at $100.00",
+		},
+		{
+			"no prior spend",
+			map[string]float64{},
+			map[string]float64{"EC2": 100},
+			"",
+		},
+	}
+	for _, tt := range tests {
+		got := Compare(result(tt.previous, "111"), result(tt.current, "111")).Headline()
+		if got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestTopDriverIgnoresOffsettingKeys checks a key moving against the total
+// is never the driver, however large
+func TestTopDriverIgnoresOffsettingKeys(t *testing.T) {
+	c := Compare(
+		result(map[string]float64{"EC2": 500, "S3": 10}, "111"),
+		result(map[string]float64{"EC2": 300, "S3": 260}, "111"),
+	)
+	driver, ok := c.TopDriver()
+	if !ok || driver.Key != "S3" {
+		t.Errorf("driver = %+v, %v; want S3", driver, ok)
+	}
+}
@@ -0,0 +1,173 @@
+package compare
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+// Dimensions only reported by period diffs
+const (
+	DimensionCostCenter = "cost_center"
+	DimensionLineItem   = "line_item" // cloud/account/service
+)
+
+// PercentFloor is the least spend a key needs in the earlier period to rank
+// by percentage increase, so a few cents growing tenfold do not crowd out
+// real growth
+const PercentFloor = 10.0
+
+// PeriodDiff compares the spend of two periods
+type PeriodDiff struct {
+	PreviousStart time.Time       `json:"previous_start"`
+	PreviousEnd   time.Time       `json:"previous_end"` // exclusive
+	CurrentStart  time.Time       `json:"current_start"`
+	CurrentEnd    time.Time       `json:"current_end"` // exclusive
+	PreviousTotal float64         `json:"previous_total"`
+	CurrentTotal  float64         `json:"current_total"`
+	Change        float64         `json:"change"`
+	PercentChange float64         `json:"percent_change"`
+	Dimensions    []DimensionDiff `json:"dimensions"`
+}
+
+// DimensionDiff ranks one dimension's changes. Keys with spend in only one
+// period are listed as new or disappeared rather than as increases.
+type DimensionDiff struct {
+	Dimension        string  `json:"dimension"`
+	Deltas           []Delta `json:"deltas"`            // every key, largest absolute change first
+	Increases        []Delta `json:"increases"`         // largest increase first
+	PercentIncreases []Delta `json:"percent_increases"` // fastest growth first, of keys over PercentFloor
+	New              []Delta `json:"new"`               // largest first
+	Disappeared      []Delta `json:"disappeared"`       // largest first
+}
+
+// DiffPeriods compares two periods' records by service, account, cost
+// center and line item. costCenter resolves a record's cost center, as
+// chargeback does; without it cost centers are left out.
+func DiffPeriods(previous, current []normalizer.CostRecord, costCenter func(normalizer.CostRecord) string) *PeriodDiff {
+	type dimension struct {
+		name string
+		key  func(normalizer.CostRecord) string
+	}
+	dimensions := []dimension{
+		{DimensionService, func(r normalizer.CostRecord) string { return r.Service }},
+		{DimensionAccount, func(r normalizer.CostRecord) string { return r.Account }},
+	}
+	if costCenter != nil {
+		dimensions = append(dimensions, dimension{DimensionCostCenter, costCenter})
+	}
+	dimensions = append(dimensions, dimension{DimensionLineItem, func(r normalizer.CostRecord) string {
+		return r.Cloud + "/" + r.Account + "/" + r.Service
+	}})
+
+	d := &PeriodDiff{}
+	for _, r := range previous {
+		d.PreviousTotal += r.Cost
+	}
+	for _, r := range current {
+		d.CurrentTotal += r.Cost
+	}
+	d.Change = d.CurrentTotal - d.PreviousTotal
+	d.PercentChange = percent(d.Change, d.PreviousTotal)
+
+	for _, dim := range dimensions {
+		prev, curr := make(map[string]float64), make(map[string]float64)
+		for _, r := range previous {
+			prev[dim.key(r)] += r.Cost
+		}
+		for _, r := range current {
+			curr[dim.key(r)] += r.Cost
+		}
+		d.Dimensions = append(d.Dimensions, rank(dim.name, deltas(dim.name, prev, curr, d.Change)))
+	}
+	return d
+}
+
+// rank sorts a dimension's deltas, which come largest change first, into
+// increases, new and disappeared keys
+func rank(dimension string, all []Delta) DimensionDiff {
+	dd := DimensionDiff{Dimension: dimension, Deltas: all}
+	for _, d := range all {
+		switch {
+		case d.Previous == 0 && d.Current != 0:
+			dd.New = append(dd.New, d)
+		case d.Current == 0 && d.Previous != 0:
+			dd.Disappeared = append(dd.Disappeared, d)
+		case d.Change > 0:
+			dd.Increases = append(dd.Increases, d)
+			if d.Previous >= PercentFloor {
+				dd.PercentIncreases = append(dd.PercentIncreases, d)
+			}
+		}
+	}
+	sort.SliceStable(dd.Increases, func(i, j int) bool { return dd.Increases[i].Change > dd.Increases[j].Change })
+	sort.SliceStable(dd.PercentIncreases, func(i, j int) bool {
+		return dd.PercentIncreases[i].PercentChange > dd.PercentIncreases[j].PercentChange
+	})
+	return dd
+}
+
+// status describes whether a key is new, disappeared or changed
+func status(d Delta) string {
+	switch {
+	case d.Previous == 0 && d.Current != 0:
+		return StatusAdded
+	case d.Current == 0 && d.Previous != 0:
+		return StatusRemoved
+	}
+	return StatusChanged
+}
+
+// SaveCSV writes every key's change, by dimension
+func (d *PeriodDiff) SaveCSV(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	header := []string{"Dimension", "Key", "Previous", "Current", "Change", "PercentChange", "Status"}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	for _, dim := range d.Dimensions {
+		for _, delta := range dim.Deltas {
+			if delta.Change == 0 {
+				continue
+			}
+			row := []string{
+				delta.Dimension,
+				delta.Key,
+				fmt.Sprintf("%.2f", delta.Previous),
+				fmt.Sprintf("%.2f", delta.Current),
+				fmt.Sprintf("%.2f", delta.Change),
+				fmt.Sprintf("%.1f", delta.PercentChange),
+				status(delta),
+			}
+			if err := writer.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// SaveJSON writes the diff as JSON
+func (d *PeriodDiff) SaveJSON(path string) error {
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package compare
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+// charged returns a record charged to a cost center
+func charged(cloud, account, service, costCenter string, cost float64) normalizer.CostRecord {
+	return normalizer.CostRecord{Cloud: cloud, Account: account, Service: service, Cost: cost, Tags: map[string]string{"cost_center": costCenter}}
+}
+
+// keys lists the keys of deltas in order
+func keys(deltas []Delta) []string {
+	var out []string
+	for _, d := range deltas {
+		out = append(out, d.Key)
+	}
+	return out
+}
+
+// periods returns two periods: EC2 grows by half, S3 triples from under
+// the percent floor, BigQuery doubles, Lambda stops and RDS starts
+func periods() (previous, current []normalizer.CostRecord) {
+	previous = []normalizer.CostRecord{
+		charged("aws", "111", "EC2", "CC-A", 100),
+		charged("aws", "111", "S3", "CC-A", 5),
+		charged("aws", "222", "Lambda", "CC-B", 50),
+		charged("gcp", "p1", "BigQuery", "CC-B", 20),
+	}
+	current = []normalizer.CostRecord{
+		charged("aws", "111", "EC2", "CC-A", 150),
+		charged("aws", "111", "S3", "CC-A", 15),
+		charged("aws", "222", "RDS", "CC-B", 30),
+		charged("gcp", "p1", "BigQuery", "CC-B", 40),
+	}
+	return previous, current
+}
+
+func TestDiffPeriods(t *testing.T) {
+	previous, current := periods()
+	d := DiffPeriods(previous, current, func(r normalizer.CostRecord) string { return r.Tags["cost_center"] })
+	if d.PreviousTotal != 175 || d.CurrentTotal != 235 || d.Change != 60 || d.PercentChange != 60.0/175*100 {
+		t.Errorf("totals = %v -> %v (%v, %v%%), want 175 -> 235", d.PreviousTotal, d.CurrentTotal, d.Change, d.PercentChange)
+	}
+
+	var dimensions []string
+	for _, dim := range d.Dimensions {
+		dimensions = append(dimensions, dim.Dimension)
+	}
+	if want := []string{DimensionService, DimensionAccount, DimensionCostCenter, DimensionLineItem}; !reflect.DeepEqual(dimensions, want) {
+		t.Fatalf("dimensions = %v, want %v", dimensions, want)
+	}
+
+	tests := []struct {
+		dimension                              int
+		increases, percent, added, disappeared []string
+	}{
+		{0, []string{"EC2", "BigQuery", "S3"}, []string{"BigQuery", "EC2"}, []string{"RDS"}, []string{"Lambda"}},
+		{1, []string{"111", "p1"}, []string{"p1", "111"}, nil, nil},
+		// CC-B holds level as RDS replaces Lambda
+		{2, []string{"CC-A"}, []string{"CC-A"}, nil, nil},
+		{3, []string{"aws/111/EC2", "gcp/p1/BigQuery", "aws/111/S3"}, []string{"gcp/p1/BigQuery", "aws/111/EC2"}, []string{"aws/222/RDS"}, []string{"aws/222/Lambda"}},
+	}
+	for _, tt := range tests {
+		dim := d.Dimensions[tt.dimension]
+		if got := keys(dim.Increases); !reflect.DeepEqual(got, tt.increases) {
+			t.Errorf("%s increases = %v, want %v", dim.Dimension, got, tt.increases)
+		}
+		if got := keys(dim.PercentIncreases); !reflect.DeepEqual(got, tt.percent) {
+			t.Errorf("%s percent increases = %v, want %v", dim.Dimension, got, tt.percent)
+		}
+		if got := keys(dim.New); !reflect.DeepEqual(got, tt.added) {
+			t.Errorf("%s new = %v, want %v", dim.Dimension, got, tt.added)
+		}
+		if got := keys(dim.Disappeared); !reflect.DeepEqual(got, tt.disappeared) {
+			t.Errorf("%s disappeared = %v, want %v", dim.Dimension, got, tt.disappeared)
+		}
+	}
+
+	// Without cost centers the dimension is left out
+	d = DiffPeriods(previous, current, nil)
+	if len(d.Dimensions) != 3 || d.Dimensions[2].Dimension != DimensionLineItem {
+		t.Errorf("dimensions = %+v, want service, account and line item", d.Dimensions)
+	}
+}
+
+func TestPeriodDiffSaveCSV(t *testing.T) {
+	previous, current := periods()
+	d := DiffPeriods(previous, current, nil)
+	path := filepath.Join(t.TempDir(), "diff.csv")
+	if err := d.SaveCSV(path); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A header, 5 services, 3 accounts and 5 line items
+	if len(rows) != 14 {
+		t.Fatalf("got %d rows, want 14", len(rows))
+	}
+	statuses := make(map[string]string)
+	for _, row := range rows[1:] {
+		statuses[row[0]+" "+row[1]] = row[6]
+	}
+	for key, want := range map[string]string{
+		"service EC2":              StatusChanged,
+		"service RDS":              StatusAdded,
+		"service Lambda":           StatusRemoved,
+		"line_item aws/222/Lambda": StatusRemoved,
+	} {
+		if statuses[key] != want {
+			t.Errorf("%s status = %q, want %q", key, statuses[key], want)
+		}
+	}
+}
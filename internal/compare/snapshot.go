@@ -0,0 +1,208 @@
+package compare
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+)
+
+// Dimensions only reported by snapshot diffs
+const (
+	DimensionRegion = "region"
+	DimensionDate   = "date"
+)
+
+// Entry delta statuses
+const (
+	StatusAdded   = "added"
+	StatusRemoved = "removed"
+	StatusChanged = "changed"
+)
+
+// EntryDelta is the change for one provider/account/service/region/date line
+type EntryDelta struct {
+	Provider  string  `json:"provider"`
+	AccountID string  `json:"account_id"`
+	Service   string  `json:"service"`
+	Region    string  `json:"region"`
+	Date      string  `json:"date"`
+	Previous  float64 `json:"previous"`
+	Current   float64 `json:"current"`
+	Change    float64 `json:"change"`
+	Status    string  `json:"status"`
+	Hint      string  `json:"hint,omitempty"` // likely cause, e.g. late data or new account
+}
+
+// SnapshotDiff explains why two runs over the same window disagree
+type SnapshotDiff struct {
+	*Comparison
+	PreviousAsOf time.Time    `json:"previous_as_of"`
+	CurrentAsOf  time.Time    `json:"current_as_of"`
+	ByRegion     []Delta      `json:"by_region"`
+	ByDate       []Delta      `json:"by_date"`
+	Entries      []EntryDelta `json:"entries"` // changed lines only, largest first
+}
+
+// LoadSnapshot reads a saved JSON report or a bare aggregation result
+func LoadSnapshot(path string) (*aggregator.AggregationResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+
+	// JSON reports wrap the aggregation result in a Results field
+	var report struct {
+		Results *aggregator.AggregationResult
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot %s: %w", path, err)
+	}
+	if report.Results != nil {
+		return report.Results, nil
+	}
+
+	var result aggregator.AggregationResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot %s: %w", path, err)
+	}
+	return &result, nil
+}
+
+// DiffSnapshots compares two runs at the dimension and entry level
+func DiffSnapshots(previous, current *aggregator.AggregationResult) *SnapshotDiff {
+	d := &SnapshotDiff{
+		Comparison:   Compare(previous, current),
+		PreviousAsOf: previous.AsOf,
+		CurrentAsOf:  current.AsOf,
+	}
+	d.ByRegion = deltas(DimensionRegion, previous.ByRegion, current.ByRegion, d.Change)
+	d.ByDate = deltas(DimensionDate, previous.ByDate, current.ByDate, d.Change)
+
+	before := entryTotals(previous.Entries)
+	after := entryTotals(current.Entries)
+	knownAccounts := make(map[string]bool, len(previous.ByAccount))
+	for account := range previous.ByAccount {
+		knownAccounts[account] = true
+	}
+
+	keys := make(map[entryKey]bool, len(after))
+	for k := range before {
+		keys[k] = true
+	}
+	for k := range after {
+		keys[k] = true
+	}
+
+	for k := range keys {
+		prev, hadPrev := before[k]
+		curr, hasCurr := after[k]
+		change := curr - prev
+		if hadPrev && hasCurr && math.Abs(change) < 0.005 {
+			continue
+		}
+
+		ed := EntryDelta{
+			Provider:  k.provider,
+			AccountID: k.account,
+			Service:   k.service,
+			Region:    k.region,
+			Date:      k.date,
+			Previous:  prev,
+			Current:   curr,
+			Change:    change,
+			Status:    StatusChanged,
+		}
+		switch {
+		case !hadPrev:
+			ed.Status = StatusAdded
+		case !hasCurr:
+			ed.Status = StatusRemoved
+		}
+		ed.Hint = entryHint(ed, previous.AsOf, knownAccounts)
+		d.Entries = append(d.Entries, ed)
+	}
+
+	sort.Slice(d.Entries, func(i, j int) bool {
+		a, b := d.Entries[i], d.Entries[j]
+		if math.Abs(a.Change) != math.Abs(b.Change) {
+			return math.Abs(a.Change) > math.Abs(b.Change)
+		}
+		return a.key() < b.key()
+	})
+
+	return d
+}
+
+// SaveCSV writes the entry-level deltas as a CSV file
+func (d *SnapshotDiff) SaveCSV(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	header := []string{"Provider", "AccountID", "Service", "Region", "Date", "Previous", "Current", "Change", "Status", "Hint"}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, e := range d.Entries {
+		row := []string{
+			e.Provider,
+			e.AccountID,
+			e.Service,
+			e.Region,
+			e.Date,
+			fmt.Sprintf("%.2f", e.Previous),
+			fmt.Sprintf("%.2f", e.Current),
+			fmt.Sprintf("%.2f", e.Change),
+			e.Status,
+			e.Hint,
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// entryHint guesses the most likely cause of an entry-level change
+func entryHint(e EntryDelta, previousAsOf time.Time, knownAccounts map[string]bool) string {
+	if e.Status == StatusAdded && !knownAccounts[e.AccountID] {
+		return "new account"
+	}
+	if date, err := time.Parse("2006-01-02", e.Date); err == nil && !previousAsOf.IsZero() && date.After(previousAsOf) {
+		return "new data since previous run"
+	}
+	if e.Status == StatusRemoved {
+		return "no longer reported"
+	}
+	return "restated by provider"
+}
+
+type entryKey struct {
+	provider, account, service, region, date string
+}
+
+func (e EntryDelta) key() string {
+	return e.Provider + "|" + e.AccountID + "|" + e.Service + "|" + e.Region + "|" + e.Date
+}
+
+func entryTotals(entries []aggregator.CostEntry) map[entryKey]float64 {
+	totals := make(map[entryKey]float64, len(entries))
+	for _, e := range entries {
+		k := entryKey{e.Provider, e.AccountID, e.Service, e.Region, e.Date.Format("2006-01-02")}
+		totals[k] += e.Cost
+	}
+	return totals
+}
@@ -0,0 +1,98 @@
+package compare
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+)
+
+var march = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+func snapshot(asOf time.Time, entries ...aggregator.CostEntry) *aggregator.AggregationResult {
+	r := &aggregator.AggregationResult{
+		AsOf:       asOf,
+		ByProvider: map[string]float64{},
+		ByService:  map[string]float64{},
+		ByAccount:  map[string]float64{},
+		ByRegion:   map[string]float64{},
+		ByDate:     map[string]float64{},
+		Entries:    entries,
+	}
+	for _, e := range entries {
+		r.TotalCost += e.Cost
+		r.ByProvider[e.Provider] += e.Cost
+		r.ByService[e.Service] += e.Cost
+		r.ByAccount[e.AccountID] += e.Cost
+		r.ByRegion[e.Region] += e.Cost
+		r.ByDate[e.Date.Format("2006-01-02")] += e.Cost
+	}
+	return r
+}
+
+func line(account, service string, date time.Time, cost float64) aggregator.CostEntry {
+	return aggregator.CostEntry{Provider: "aws", AccountID: account, Service: service, Region: "us-east-1", Date: date, Cost: cost}
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	previous := snapshot(march.AddDate(0, 0, 1),
+		line("111", "EC2", march, 100),
+		line("111", "S3", march, 10),
+		line("111", "Lambda", march, 5),
+		line("111", "RDS", march, 50),
+	)
+	current := snapshot(march.AddDate(0, 0, 2),
+		line("111", "EC2", march, 100.001),
+		line("111", "S3", march, 12),
+		line("111", "RDS", march, 50),
+		line("111", "EC2", march.AddDate(0, 0, 2), 40),
+		line("222", "EC2", march, 30),
+	)
+
+	d := DiffSnapshots(previous, current)
+	want := []EntryDelta{
+		{Provider: "aws", AccountID: "111", Service: "EC2", Region: "us-east-1", Date: "2024-03-03", Current: 40, Change: 40, Status: StatusAdded, Hint: "new data since previous run"},
+		{Provider: "aws", AccountID: "222", Service: "EC2", Region: "us-east-1", Date: "2024-03-01", Current: 30, Change: 30, Status: StatusAdded, Hint: "new account"},
+		{Provider: "aws", AccountID: "111", Service: "Lambda", Region: "us-east-1", Date: "2024-03-01", Previous: 5, Change: -5, Status: StatusRemoved, Hint: "no longer reported"},
+		{Provider: "aws", AccountID: "111", Service: "S3", Region: "us-east-1", Date: "2024-03-01", Previous: 10, Current: 12, Change: 2, Status: StatusChanged, Hint: "restated by provider"},
+	}
+	if len(d.Entries) != len(want) {
+		t.Fatalf("got %d entry deltas %+v, want %d", len(d.Entries), d.Entries, len(want))
+	}
+	for i, e := range d.Entries {
+		if e != want[i] {
+			t.Errorf("entry %d = %+v, want %+v", i, e, want[i])
+		}
+	}
+	if len(d.ByDate) != 2 || d.ByDate[0].Key != "2024-03-03" {
+		t.Errorf("by date = %+v, want 2024-03-03 first", d.ByDate)
+	}
+}
+
+func TestLoadSnapshot(t *testing.T) {
+	result := snapshot(march, line("111", "EC2", march, 100))
+	dir := t.TempDir()
+
+	report, _ := json.Marshal(map[string]any{"Period": "March", "Results": result})
+	bare, _ := json.Marshal(result)
+	for name, data := range map[string][]byte{"report.json": report, "result.json": bare} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := LoadSnapshot(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got.TotalCost != 100 || len(got.Entries) != 1 || !got.AsOf.Equal(march) {
+			t.Errorf("%s: got total %v, %d entries as of %s", name, got.TotalCost, len(got.Entries), got.AsOf)
+		}
+	}
+
+	if _, err := LoadSnapshot(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("missing snapshot loaded")
+	}
+}
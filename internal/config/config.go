@@ -2,21 +2,361 @@
 package config
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"reflect"
 
 	"gopkg.in/yaml.v3"
 )
 
 // Config holds all configuration
 type Config struct {
-	AWS      AWSConfig      `yaml:"aws"`
-	Azure    AzureConfig    `yaml:"azure"`
-	GCP      GCPConfig      `yaml:"gcp"`
-	Budgets  []Budget       `yaml:"budgets"`
-	Anomaly  AnomalyConfig  `yaml:"anomaly"`
-	Alerting AlertingConfig `yaml:"alerting"`
-	Reporter ReporterConfig `yaml:"reporter"`
+	AWS          AWSConfig             `yaml:"aws"`
+	Azure        AzureConfig           `yaml:"azure"`
+	GCP          GCPConfig             `yaml:"gcp"`
+	OCI          OCIConfig             `yaml:"oci"`
+	FOCUS        FOCUSConfig           `yaml:"focus"`
+	CSVImport    CSVImportConfig       `yaml:"csv_import"`
+	Adjustments  []CostAdjustment      `yaml:"adjustments"`
+	Internal     InternalChargesConfig `yaml:"internal_charges"`
+	Budgets      []Budget              `yaml:"budgets"`
+	Calendar     CalendarConfig        `yaml:"calendar"`
+	Applications ApplicationsConfig    `yaml:"applications"`
+	Chargeback   ChargebackConfig      `yaml:"chargeback"`
+	TagPolicy    TagPolicyConfig       `yaml:"tag_policy"`
+	Anomaly      AnomalyConfig         `yaml:"anomaly"`
+	Alerting     AlertingConfig        `yaml:"alerting"`
+	Reporter     ReporterConfig        `yaml:"reporter"`
+	Freshness    FreshnessConfig       `yaml:"freshness"`
+	Store        StoreConfig           `yaml:"store"`
+	Cache        CacheConfig           `yaml:"cache"`
+	Currency     CurrencyConfig        `yaml:"currency"`
+	Forecast     ForecastConfig        `yaml:"forecast"`
+	Backfill     BackfillConfig        `yaml:"backfill"`
+	Ingest       IngestConfig          `yaml:"ingest"`
+	Emissions    EmissionsConfig       `yaml:"emissions"`
+	Hierarchy    HierarchyConfig       `yaml:"hierarchy"`
+	Filter       FilterConfig          `yaml:"filter"`
+	ZeroCost     ZeroCostConfig        `yaml:"zero_cost"`
+	Dimensions   []DimensionConfig     `yaml:"dimensions"`
+	Releases     ReleasesConfig        `yaml:"releases"`
+	TagLimits    TagLimitsConfig       `yaml:"tag_limits"`
+	Serve        ServeConfig           `yaml:"serve"`
+	Tracing      TracingConfig         `yaml:"tracing"`
+
+	Recommendations RecommendationsConfig `yaml:"recommendations"`
+	Commitments     CommitmentsConfig     `yaml:"commitments"`
+	UnitCost        UnitCostConfig        `yaml:"unit_cost"`
+
+	ChargeTypes ChargeTypesConfig `yaml:"charge_types"`
+
+	Plugins []PluginConfig `yaml:"plugins"`
+
+	Fetch FetchConfig `yaml:"fetch"`
+
+	Dedup DedupConfig `yaml:"dedup"`
+
+	Reports []ReportDefinition `yaml:"reports"`
+}
+
+// ReportDefinition is a named report, written by "report run <name>" and on
+// its schedule in serve mode, so a team's standard reports are reproducible
+// and reviewed like the rest of the config
+type ReportDefinition struct {
+	Name       string   `yaml:"name"`
+	Command    string   `yaml:"command" default:"aggregate"` // aggregate, chargeback, simulate, trend, tags, diff, commitments, forecast or recommend
+	Period     string   `yaml:"period"`                      // month-to-date, last-month, quarter-to-date, last-quarter or last-N-days; empty for the command's default
+	Cloud      string   `yaml:"cloud"`                       // provider to query, defaults to all
+	Accounts   []string `yaml:"accounts"`                    // filters replace filter's, as the command-line flags do
+	Services   []string `yaml:"services"`
+	Regions    []string `yaml:"regions"`
+	Tags       []string `yaml:"tags"`       // key=value
+	Where      string   `yaml:"where"`      // filter expression
+	GroupBy    []string `yaml:"group_by"`   // aggregate breakdowns: cloud, account, region, service, resource or tag:<key>
+	Formats    []string `yaml:"formats"`    // one run per format, defaulting to the command's own
+	Recipients []string `yaml:"recipients"` // emailed the reports written, over alerting.email's SMTP server
+	Schedule   string   `yaml:"schedule"`   // cron spec serve mode runs the report on, e.g. "0 7 * * 1"
+	Args       []string `yaml:"args"`       // further flags of the command, e.g. ["--months", "12"]
+}
+
+// DedupConfig decides which provider's records count when more than one
+// reports the same charge, such as a FOCUS export overlapping a billing API
+type DedupConfig struct {
+	Disabled bool     `yaml:"disabled"` // count every provider's records
+	Prefer   []string `yaml:"prefer"`   // provider names whose records win, in order; the rest follow by name
+}
+
+// FetchConfig bounds each provider's fetch and decides whether a run with
+// some providers missing fails
+type FetchConfig struct {
+	Timeout       string            `yaml:"timeout" default:"30m"`                                     // per provider fetch
+	Timeouts      map[string]string `yaml:"timeouts"`                                                  // provider name -> timeout, overriding timeout
+	PartialAction string            `yaml:"partial_action" default:"warn" validate:"oneof=warn|error"` // warn, or error to exit 4 once reports are written
+}
+
+// PluginConfig runs an out-of-tree cost provider: an external program
+// answering JSON requests on stdin with JSON on stdout
+type PluginConfig struct {
+	Name     string            `yaml:"name"`    // provider name, also selectable with --cloud
+	Command  string            `yaml:"command"` // executable, run once per call
+	Args     []string          `yaml:"args"`
+	Env      map[string]string `yaml:"env"`                  // added to the environment, e.g. API_TOKEN: ${VENDOR_TOKEN}
+	Timeout  string            `yaml:"timeout" default:"5m"` // per call
+	Settings map[string]string `yaml:"settings"`             // sent with every request
+	Cache    bool              `yaml:"cache"`                // cache results like the cloud APIs', for plugins calling billing APIs
+}
+
+// RecommendationsConfig selects the rightsizing recommendations collected by
+// recommend mode
+type RecommendationsConfig struct {
+	Sources     []string `yaml:"sources"`      // compute_optimizer, cost_explorer, advisor, recommender (default: all)
+	Regions     []string `yaml:"regions"`      // Compute Optimizer regions (default: aws.region)
+	MinSavings  float64  `yaml:"min_savings"`  // monthly savings below this are left out
+	CrossFamily bool     `yaml:"cross_family"` // Cost Explorer may suggest other instance families
+
+	// GCP Recommender scope: VM recommendations are per zone, committed use
+	// discounts per region of those zones
+	Projects []string `yaml:"projects"` // default: gcp.project_id
+	Zones    []string `yaml:"zones"`
+}
+
+// CommitmentsConfig tunes the coverage and utilization analysis of
+// commitments mode
+type CommitmentsConfig struct {
+	Services       []string `yaml:"services"`        // services commitments can cover (default: compute, database and any seen covered)
+	MinUtilization float64  `yaml:"min_utilization"` // percent below which a commitment is under-utilized (default 80)
+	DiscountRate   float64  `yaml:"discount_rate"`   // percent a new commitment saves over on-demand (default 30)
+}
+
+// UnitCostConfig divides cost by business metrics for unit economics in
+// reports and the serve mode API
+type UnitCostConfig struct {
+	PrometheusURL string             `yaml:"prometheus_url"` // e.g. http://prometheus:9090, for metrics with a query
+	Metrics       []UnitMetricConfig `yaml:"metrics"`
+}
+
+// UnitMetricConfig is a business metric and the cost divided by it: a cost
+// center's, a normalized service's, or all spend when neither is set
+type UnitMetricConfig struct {
+	Name       string `yaml:"name"` // e.g. orders
+	Unit       string `yaml:"unit"` // one unit, e.g. order
+	CostCenter string `yaml:"cost_center"`
+	Service    string `yaml:"service"`
+	Query      string `yaml:"query"` // PromQL giving a day's total at its end, e.g. sum(increase(orders_total[1d]))
+	CSV        string `yaml:"csv"`   // or a file of date,value rows
+}
+
+// TracingConfig configures OpenTelemetry tracing of provider fetches
+type TracingConfig struct {
+	Enabled     bool              `yaml:"enabled"`
+	Endpoint    string            `yaml:"endpoint"` // OTLP/HTTP collector host:port (default: localhost:4318)
+	Insecure    bool              `yaml:"insecure"` // plain HTTP
+	Headers     map[string]string `yaml:"headers"`
+	ServiceName string            `yaml:"service_name" default:"finops-platform"`
+	SampleRatio float64           `yaml:"sample_ratio" default:"1" validate:"min=0,max=1"` // fraction of runs traced (default: 1)
+}
+
+// ServeConfig configures the long-lived serve mode
+type ServeConfig struct {
+	Listen          string         `yaml:"listen"`                        // address for /healthz and /releases, empty for none
+	ShutdownTimeout string         `yaml:"shutdown_timeout" default:"5m"` // how long running jobs may finish after a shutdown signal
+	Jobs            []ScheduledJob `yaml:"jobs"`
+
+	Portal bool `yaml:"portal"` // showback web UI on / (needs the history store)
+
+	AnomalyTokens map[string]string `yaml:"anomaly_tokens"` // who may change anomaly states on /anomalies -> their bearer token; none leaves it read-only
+}
+
+// ScheduledJob runs a mode on a cron schedule
+type ScheduledJob struct {
+	Name     string   `yaml:"name"`
+	Mode     string   `yaml:"mode"`     // aggregate, anomaly, chargeback, forecast, ...
+	Schedule string   `yaml:"schedule"` // e.g. "0 6 * * *", "@hourly" or "@every 4h"
+	Timeout  string   `yaml:"timeout"`  // e.g. 30m; the run is interrupted when exceeded
+	Args     []string `yaml:"args"`     // extra flags, e.g. ["--format", "json"]
+}
+
+// TagLimitsConfig guards against high-cardinality and overlong tag values.
+// Zero limits are unlimited.
+type TagLimitsConfig struct {
+	MaxValues int      `yaml:"max_values"` // distinct values per key; the cheapest beyond it become "OTHER"
+	MaxLength int      `yaml:"max_length"` // longer values are truncated
+	Ignore    []string `yaml:"ignore"`     // keys dropped entirely; a trailing * matches a prefix
+}
+
+// ReleasesConfig locates deployment and release markers and sets the window
+// compared around each
+type ReleasesConfig struct {
+	File       string `yaml:"file" default:"./data/releases.json"` // JSON markers written by mark-release and the release webhook
+	WindowDays int    `yaml:"window_days" default:"7"`             // days compared before and after each release
+	RecentDays int    `yaml:"recent_days" default:"30"`            // releases this recent are reported
+
+	Secret string `yaml:"secret"` // HMAC-SHA256 key release webhook requests are signed with; the webhook is not served without one
+}
+
+// DimensionConfig defines a computed cost dimension reported alongside the
+// built-in breakdowns
+type DimensionConfig struct {
+	Name      string `yaml:"name"`
+	Template  string `yaml:"template"`   // e.g. "{tag:environment}/{region}"
+	Pattern   string `yaml:"pattern"`    // optional regular expression; the first capture group becomes the value
+	MaxValues int    `yaml:"max_values"` // distinct values kept before the rest are grouped as "(other)"
+}
+
+// ZeroCostConfig controls free-tier and other zero-cost line items
+type ZeroCostConfig struct {
+	Mode      string `yaml:"mode" validate:"oneof=keep|drop|rollup"` // keep (default), drop, or rollup into a no-cost services count
+	KeepUsage bool   `yaml:"keep_usage"`                             // retain zero-cost records that still report usage
+}
+
+// FilterConfig limits the costs fetched from providers. Providers apply it in
+// their API queries where they can. Empty lists match anything.
+type FilterConfig struct {
+	Accounts   []string `yaml:"accounts"`
+	Services   []string `yaml:"services"`
+	Regions    []string `yaml:"regions"`
+	Tags       []string `yaml:"tags"`       // key=value; repeat a key to accept several values
+	Dimensions []string `yaml:"dimensions"` // name=value on computed dimensions, applied after fetching
+
+	// Filter expression, e.g. cloud = "aws" AND tag.env != "prod" AND
+	// cost > 100, applied after fetching (see the query package)
+	Where string `yaml:"where"`
+}
+
+// HierarchyConfig locates the account to org-unit mapping exported from AWS
+// Organizations, Azure management groups or GCP folders
+type HierarchyConfig struct {
+	File string `yaml:"file"` // YAML (accounts: {id: Root/Eng/Platform}) or CSV (account,path)
+}
+
+// EmissionsConfig holds the emission factors used to estimate carbon
+// footprint alongside cost
+type EmissionsConfig struct {
+	Enabled bool             `yaml:"enabled"`
+	Factors []EmissionFactor `yaml:"factors"`
+}
+
+// EmissionFactor converts usage or spend into estimated kg CO2e. Empty scope
+// fields match anything; the most specific matching factor wins.
+type EmissionFactor struct {
+	Provider  string  `yaml:"provider"`
+	Region    string  `yaml:"region"`
+	Service   string  `yaml:"service"`
+	UsageUnit string  `yaml:"usage_unit"`         // e.g. Hrs, GB-Mo
+	PerUnit   float64 `yaml:"kg_co2e_per_unit"`   // per unit of usage
+	PerDollar float64 `yaml:"kg_co2e_per_dollar"` // spend-based fallback
+}
+
+// BackfillConfig paces historical loads into the history store
+type BackfillConfig struct {
+	ChunkDays   int    `yaml:"chunk_days" default:"7"`  // days fetched per provider query
+	MinInterval string `yaml:"min_interval"`            // pause between chunks to stay under API quotas, e.g. 2s
+	MaxRetries  int    `yaml:"max_retries" default:"3"` // retries of a failed chunk before giving up
+}
+
+// IngestConfig configures incremental ingestion into the history store
+type IngestConfig struct {
+	LookbackDays    int `yaml:"lookback_days" default:"30"`   // days loaded for a provider on its first ingest
+	RestatementDays int `yaml:"restatement_days" default:"3"` // trailing ingested days re-fetched each run for billing restatements
+
+	Reconcile ReconcileConfig `yaml:"reconcile"`
+}
+
+// ReconcileConfig configures the reconcile command, which re-fetches a
+// trailing window of ingested days and reports what the bills restated
+type ReconcileConfig struct {
+	WindowDays       int     `yaml:"window_days" default:"30" validate:"min=1"` // trailing ingested days re-fetched
+	Threshold        float64 `yaml:"threshold" default:"1" validate:"min=0"`    // smallest change per day and service reported, in the base currency
+	ThresholdPercent float64 `yaml:"threshold_percent" validate:"min=0"`        // and in percent of the stored cost; 0 ignores
+}
+
+// InternalChargesConfig identifies intercompany and internal-transfer
+// charges that are not external cloud spend
+type InternalChargesConfig struct {
+	Mode  string               `yaml:"mode"` // report (default) keeps them in totals, exclude drops them
+	Rules []InternalChargeRule `yaml:"rules"`
+}
+
+// ChargeTypesConfig selects the charge types counted in totals. Everything
+// is included by default, so credits, refunds and taxes net against usage
+// as they do on the bill.
+type ChargeTypesConfig struct {
+	Exclude []string `yaml:"exclude"` // usage, credit, refund, tax, fee
+}
+
+// InternalChargeRule matches internal charges; every set field must match
+type InternalChargeRule struct {
+	Name     string `yaml:"name"`
+	Provider string `yaml:"provider"`
+	Account  string `yaml:"account"`
+	Service  string `yaml:"service"`
+	Tag      string `yaml:"tag"`     // tag key marking internal charges
+	Pattern  string `yaml:"pattern"` // regular expression for the tag value, empty for any value
+}
+
+// ForecastConfig configures spend forecasting
+type ForecastConfig struct {
+	HistoryDays int             `yaml:"history_days" default:"60"`                                    // days of history the trend is fitted to
+	Method      string          `yaml:"method" default:"linear" validate:"oneof=linear|holt-winters"` // linear (default) or holt-winters
+	Events      []ForecastEvent `yaml:"events"`
+}
+
+// ForecastEvent is a known scheduled change in spend, such as a migration
+// or a planned decommission
+type ForecastEvent struct {
+	Name       string  `yaml:"name"`
+	Date       string  `yaml:"date"`       // YYYY-MM-DD the change takes effect
+	EndDate    string  `yaml:"end_date"`   // optional YYYY-MM-DD the change stops, exclusive
+	Delta      float64 `yaml:"delta"`      // change in daily spend, e.g. -5000
+	Multiplier float64 `yaml:"multiplier"` // scales scoped spend, e.g. 0.5 halves it
+	Provider   string  `yaml:"provider"`   // scope; empty fields match everything
+	Account    string  `yaml:"account"`
+	Service    string  `yaml:"service"`
+}
+
+// CurrencyConfig sets the base currency every cost is converted into and
+// where exchange rates come from
+type CurrencyConfig struct {
+	Base  string             `yaml:"base" default:"USD"` // e.g. USD
+	Rates map[string]float64 `yaml:"rates"`              // units of each currency per one unit of base; override fetched rates
+
+	Source    string `yaml:"source" default:"static"`                // static (default, rates only) or ecb
+	CacheFile string `yaml:"cache_file" default:"./data/rates.json"` // fetched rates, reused between runs
+	CacheTTL  string `yaml:"cache_ttl" default:"24h"`                // how long fetched rates are reused, e.g. 24h
+}
+
+// StoreConfig configures the cost history store
+type StoreConfig struct {
+	Enabled   bool            `yaml:"enabled"`
+	Driver    string          `yaml:"driver" validate:"oneof=file|sqlite|postgres"` // file (default), sqlite or postgres
+	Path      string          `yaml:"path" default:"./data/history"`                // directory for file, database file for sqlite
+	DSN       string          `yaml:"dsn"`                                          // postgres connection string, e.g. ${FINOPS_STORE_DSN}
+	Retention RetentionConfig `yaml:"retention"`
+}
+
+// CacheConfig configures caching of provider results between runs
+type CacheConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Backend string `yaml:"backend" default:"file" validate:"oneof=file|store"` // file (default) or store (the history store)
+	Path    string `yaml:"path" default:"./data/cache"`                        // directory for the file backend
+	TTL     string `yaml:"ttl" default:"12h"`                                  // how long results are reused, e.g. 12h or 1d
+}
+
+// RetentionConfig bounds the history store: daily line items for DailyDays,
+// then monthly rollups for MonthlyMonths
+type RetentionConfig struct {
+	DailyDays     int  `yaml:"daily_days"`     // 0 keeps daily data forever
+	MonthlyMonths int  `yaml:"monthly_months"` // 0 keeps rollups forever
+	AutoPrune     bool `yaml:"auto_prune"`     // prune after every ingest
+}
+
+// FreshnessConfig guards against reporting on stale data
+type FreshnessConfig struct {
+	MaxAge string `yaml:"max_age"`                                           // e.g. 72h or 3d; empty disables the check
+	Action string `yaml:"action" default:"warn" validate:"oneof=warn|error"` // warn or error
 }
 
 // AWSConfig holds AWS-specific configuration
@@ -24,9 +364,47 @@ type AWSConfig struct {
 	Enabled     bool     `yaml:"enabled"`
 	RoleARN     string   `yaml:"role_arn"`
 	Region      string   `yaml:"region"`
-	AccountIDs  []string `yaml:"account_ids"`
+	AccountIDs  []string `yaml:"account_ids"` // accounts collected, all when empty
 	Granularity string   `yaml:"granularity"` // DAILY, MONTHLY
 	GroupBy     []string `yaml:"group_by"`    // SERVICE, LINKED_ACCOUNT, etc.
+
+	// MemberRole is a role name assumed in each account to query its costs
+	// there; without it the management account's costs are filtered to
+	// AccountIDs. AccountNames tags entries with names from Organizations.
+	MemberRole   string `yaml:"member_role"`
+	Concurrency  int    `yaml:"concurrency" default:"4"` // accounts queried at once with member_role (default 4)
+	AccountNames bool   `yaml:"account_names"`
+
+	CUR CURConfig `yaml:"cur"` // read CUR exports instead of Cost Explorer
+
+	// ResourceLevel replaces the ResourceServices totals of the last 14 days
+	// with Cost Explorer's per-resource data, which must be enabled in the
+	// Cost Explorer settings. CUR exports are always resource-level.
+	ResourceLevel    bool     `yaml:"resource_level"`
+	ResourceServices []string `yaml:"resource_services" default:"Amazon Elastic Compute Cloud - Compute"` // default: Amazon Elastic Compute Cloud - Compute
+
+	Calls CallConfig `yaml:"calls"` // Cost Explorer pacing and retries (default rate_limit: 1)
+}
+
+// CallConfig paces, retries and circuit-breaks a provider's API calls
+type CallConfig struct {
+	RateLimit        float64 `yaml:"rate_limit"`                    // calls per second, 0 for unlimited
+	MaxRetries       int     `yaml:"max_retries" default:"4"`       // retries of a throttled or failed call (default 4)
+	BaseDelay        string  `yaml:"base_delay" default:"1s"`       // first backoff, doubling per retry with jitter (default 1s)
+	MaxDelay         string  `yaml:"max_delay" default:"30s"`       // longest backoff (default 30s)
+	BreakerThreshold int     `yaml:"breaker_threshold" default:"5"` // consecutive failed calls that open the circuit (default 5)
+	BreakerCooldown  string  `yaml:"breaker_cooldown" default:"1m"` // how long an open circuit fails calls fast (default 1m)
+}
+
+// CURConfig reads Cost and Usage Report exports from S3 in place of Cost
+// Explorer, for resource-level line items with every tag
+type CURConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Bucket  string `yaml:"bucket"`
+	Prefix  string `yaml:"prefix"`                                            // S3 path prefix configured on the export
+	Name    string `yaml:"name"`                                              // export (CUR 2.0) or report (legacy) name
+	Version string `yaml:"version" default:"2.0" validate:"oneof=2.0|legacy"` // "2.0" for Data Exports or "legacy"
+	Region  string `yaml:"region"`                                            // bucket region, defaults to the AWS region
 }
 
 // AzureConfig holds Azure-specific configuration
@@ -36,39 +414,398 @@ type AzureConfig struct {
 	SubscriptionIDs []string `yaml:"subscription_ids"`
 	UseMSI          bool     `yaml:"use_msi"`
 	Granularity     string   `yaml:"granularity"`
+
+	Amortized         bool `yaml:"amortized"`          // also query AmortizedCost, for effective cost
+	ReservationDetail bool `yaml:"reservation_detail"` // break costs down by pricing model and reservation
+
+	// Scopes queried besides SubscriptionIDs. Management groups and billing
+	// accounts are queried whole, broken down by subscription, unless
+	// DiscoverSubscriptions queries each subscription under the groups.
+	ManagementGroups      []string `yaml:"management_groups"`
+	BillingAccounts       []string `yaml:"billing_accounts"`
+	DiscoverSubscriptions bool     `yaml:"discover_subscriptions"`
+
+	Tenants []AzureTenantConfig `yaml:"tenants"` // further tenants, each with its own credentials
+
+	Calls CallConfig `yaml:"calls"`
+}
+
+// AzureTenantConfig is another tenant queried with its own credentials: a
+// service principal's client secret, or the default credential chain
+// signed in to the tenant when ClientID is empty
+type AzureTenantConfig struct {
+	TenantID     string `yaml:"tenant_id"`
+	ClientID     string `yaml:"client_id"`
+	ClientSecret string `yaml:"client_secret"` // e.g. ${AZURE_TENANT2_CLIENT_SECRET}
+
+	SubscriptionIDs       []string `yaml:"subscription_ids"`
+	ManagementGroups      []string `yaml:"management_groups"`
+	BillingAccounts       []string `yaml:"billing_accounts"`
+	DiscoverSubscriptions bool     `yaml:"discover_subscriptions"`
 }
 
 // GCPConfig holds GCP-specific configuration
 type GCPConfig struct {
 	Enabled        bool   `yaml:"enabled"`
 	BillingAccount string `yaml:"billing_account"`
-	ProjectID      string `yaml:"project_id"`
+	ProjectID      string `yaml:"project_id"` // project that runs BigQuery jobs
 	WIFConfigPath  string `yaml:"wif_config_path"`
+	BillingTable   string `yaml:"billing_table"` // project.dataset.gcp_billing_export_v1_XXXXXX
+
+	// Partition pruning for the billing export table
+	PartitionColumn  string `yaml:"partition_column"`   // _PARTITIONTIME (default), _PARTITIONDATE, or export_time
+	PartitionLagDays int    `yaml:"partition_lag_days"` // extra days scanned after the window for late rows (default 3)
+
+	// Tag entries with each project's folders and organization from Cloud Resource Manager
+	ResolveHierarchy bool `yaml:"resolve_hierarchy"`
+
+	Calls CallConfig `yaml:"calls"`
+}
+
+// OCIConfig holds Oracle Cloud Infrastructure configuration. Credentials
+// come from the fields below or a profile of an OCI CLI config file.
+type OCIConfig struct {
+	Enabled        bool   `yaml:"enabled"`
+	TenancyID      string `yaml:"tenancy_id"`
+	UserID         string `yaml:"user_id"`
+	Fingerprint    string `yaml:"fingerprint"`
+	PrivateKeyPath string `yaml:"private_key_path"`
+	Region         string `yaml:"region"`      // home region; the Usage API only answers there
+	ConfigFile     string `yaml:"config_file"` // e.g. ~/.oci/config; set fields take precedence
+	Profile        string `yaml:"profile"`     // profile in config_file, DEFAULT when empty
+	Granularity    string `yaml:"granularity"` // DAILY, MONTHLY
+
+	CompartmentDepth int `yaml:"compartment_depth" default:"1"` // compartments roll up to this depth below the tenancy (default 1)
+
+	Calls CallConfig `yaml:"calls"`
+}
+
+// FOCUSConfig configures import of FOCUS-formatted cost files
+type FOCUSConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Path    string `yaml:"path"` // a FOCUS CSV or Parquet file, or a directory of them
+}
+
+// CSVImportConfig configures import of SaaS vendor invoices (Datadog,
+// GitHub, PagerDuty, ...) from CSV files with per-vendor column mappings
+type CSVImportConfig struct {
+	Enabled bool        `yaml:"enabled"`
+	Sources []CSVSource `yaml:"sources"`
+}
+
+// CSVSource is one vendor's invoice files and how to read them
+type CSVSource struct {
+	Name       string     `yaml:"name"`        // reported as the provider, e.g. datadog
+	Path       string     `yaml:"path"`        // a CSV file or a directory of them
+	DateFormat string     `yaml:"date_format"` // Go layout, default 2006-01-02
+	Account    string     `yaml:"account"`     // account when there is no account column, default the name
+	Service    string     `yaml:"service"`     // service when there is no service column, default the name
+	Currency   string     `yaml:"currency"`    // currency when there is no currency column, default USD
+	Columns    CSVColumns `yaml:"columns"`
+}
+
+// CSVColumns maps CSV header names to cost fields. Date and cost are required.
+type CSVColumns struct {
+	Date     string            `yaml:"date"`
+	Cost     string            `yaml:"cost"`
+	Service  string            `yaml:"service"`
+	Account  string            `yaml:"account"`
+	Region   string            `yaml:"region"`
+	Resource string            `yaml:"resource"`
+	Currency string            `yaml:"currency"`
+	Usage    string            `yaml:"usage"`
+	Unit     string            `yaml:"unit"`
+	Tags     map[string]string `yaml:"tags"` // tag key -> column
+
+	// ChargeType holds usage, credit, refund, tax, fee or a provider's own
+	// line item type; without it negative amounts are credits
+	ChargeType string `yaml:"charge_type"`
+}
+
+// CostAdjustment applies a negotiated discount or known billing adjustment
+// to a provider's (or one service's) reported cost
+type CostAdjustment struct {
+	Name       string  `yaml:"name"`
+	Provider   string  `yaml:"provider"`   // aws, azure, gcp; empty for all
+	Service    string  `yaml:"service"`    // empty for every service of the provider
+	Multiplier float64 `yaml:"multiplier"` // e.g. 0.85
+	Percent    float64 `yaml:"percent"`    // alternative to multiplier, e.g. -15
 }
 
 // Budget defines a budget threshold
 type Budget struct {
-	Name          string  `yaml:"name"`
-	Provider      string  `yaml:"provider"` // aws, azure, gcp, or all
-	Scope         string  `yaml:"scope"`    // account ID, subscription, project
-	MonthlyLimit  float64 `yaml:"monthly_limit"`
-	AlertAt       []int   `yaml:"alert_at"` // percentages to alert at (e.g., 50, 75, 90, 100)
-	NotifyEmails  []string `yaml:"notify_emails"`
-	NotifySlack   string  `yaml:"notify_slack"`
+	Name         string   `yaml:"name"`
+	Provider     string   `yaml:"provider"`    // aws, azure, gcp, or all
+	Scope        string   `yaml:"scope"`       // account ID, subscription, project
+	Application  string   `yaml:"application"` // application name from the applications tag
+	MonthlyLimit float64  `yaml:"monthly_limit"`
+	AlertAt      []int    `yaml:"alert_at"` // percentages to alert at (e.g., 50, 75, 90, 100)
+	NotifyEmails []string `yaml:"notify_emails"`
+	NotifySlack  string   `yaml:"notify_slack"`
+
+	// PageAt overrides alerting.paging.budget_percent; negative never pages
+	PageAt int `yaml:"page_at"`
+
+	// Parent nests the budget under another (org, business unit, team); a
+	// budget with children tracks their combined spend instead of its own
+	Parent     string `yaml:"parent"`
+	CostCenter string `yaml:"cost_center"` // chargeback cost center, allocated as by chargeback mode
+
+	// Period is monthly (default), quarterly, annual, or custom from Start
+	// to End. Limit covers the whole period; without it, monthly_limit is
+	// prorated by the share of each month the period covers.
+	Period  string  `yaml:"period"`
+	Start   string  `yaml:"start"` // YYYY-MM-DD, custom periods
+	End     string  `yaml:"end"`   // YYYY-MM-DD inclusive, custom periods
+	Limit   float64 `yaml:"limit"`
+	RunRate bool    `yaml:"run_rate"` // alert when recent daily burn exceeds the remaining budget per remaining day
+}
+
+// ApplicationsConfig defines the tag that groups resources into applications
+type ApplicationsConfig struct {
+	Tag string `yaml:"tag" default:"app"` // e.g. app; multi-valued as "checkout:70,search:30"
+}
+
+// ChargebackConfig configures tag-based cost allocation
+type ChargebackConfig struct {
+	PrimaryTag      string               `yaml:"primary_tag" default:"cost_center"`
+	FallbackTag     string               `yaml:"fallback_tag" default:"team"`
+	AllocationKey   string               `yaml:"allocation_key"` // e.g. "{ou:2}-{tag:team}", replaces primary/fallback tags
+	UntaggedPool    string               `yaml:"untagged_pool"`  // cost center for untagged costs, empty to distribute
+	SharedCostSplit []SharedCostSplit    `yaml:"shared_cost_split"`
+	Overrides       []AllocationOverride `yaml:"overrides"`
+	Credits         string               `yaml:"credits" validate:"oneof=proportional|pool"` // empty (credits follow tags), proportional, or pool
+	CreditPool      string               `yaml:"credit_pool"`                                // cost center holding pooled credits
+	Currencies      map[string]string    `yaml:"currencies"`                                 // cost center -> billing currency
+	AccountCurrency map[string]string    `yaml:"account_currencies"`                         // account -> billing currency, for unmapped centers
+	Scenarios       []AllocationScenario `yaml:"scenarios"`                                  // alternative strategies compared by simulate mode
+	Columns         []string             `yaml:"columns"`                                    // chargeback CSV columns, empty for the default set
+	Journal         JournalConfig        `yaml:"journal"`                                    // double-entry export for ERP import
+
+	// CostBasis is the cost allocated: unblended (default, as billed),
+	// blended (usage at the average rate across cost centers), or amortized
+	// (commitment purchases spread over their term onto the usage they cover)
+	CostBasis   string             `yaml:"cost_basis"`
+	Commitments []CommitmentConfig `yaml:"commitments"` // upfront purchases the providers do not amortize
+
+	SharedCosts []SharedCostConfig `yaml:"shared_costs"` // charges split by driver instead of by tags
+	DriversFile string             `yaml:"drivers_file"` // driver values per cost center, YAML or CSV
+
+	Splits []AllocationSplit `yaml:"splits"` // resources several cost centers share
+
+	Invoices InvoiceConfig `yaml:"invoices"` // per cost center invoices with markups and discounts
+
+	// MonthOverMonth also allocates the previous month and adds each
+	// center's change to the report
+	MonthOverMonth bool `yaml:"month_over_month"`
+}
+
+// SharedCostConfig splits matching charges (networking, support, security
+// tooling) across cost centers by a driver: spend (direct spend, the
+// default), even, or a driver from the drivers file such as headcount,
+// requests or vcpu_hours. Match fields left empty match anything.
+type SharedCostConfig struct {
+	Name    string            `yaml:"name"`
+	Cloud   string            `yaml:"cloud"`
+	Account string            `yaml:"account"`
+	Service string            `yaml:"service"`
+	Tags    map[string]string `yaml:"tags"`
+	Driver  string            `yaml:"driver"`
+}
+
+// CommitmentConfig describes a reservation or savings plan for the
+// amortized cost basis. Its monthly cost, Upfront / TermMonths plus
+// MonthlyFee, replaces the commitment's own charges during the term.
+type CommitmentConfig struct {
+	ID         string  `yaml:"id"` // commitment discount ID carried by the covered usage
+	Cloud      string  `yaml:"cloud"`
+	Account    string  `yaml:"account"` // purchasing account
+	Upfront    float64 `yaml:"upfront"`
+	MonthlyFee float64 `yaml:"monthly_fee"`
+	Start      string  `yaml:"start"` // YYYY-MM, first month of the term
+	TermMonths int     `yaml:"term_months"`
+}
+
+// JournalConfig maps cost centers to ledger accounts for the chargeback
+// journal export. The journal is written when a clearing account is set.
+type JournalConfig struct {
+	Accounts        map[string]string `yaml:"accounts"`         // cost center -> expense account
+	DefaultAccount  string            `yaml:"default_account"`  // for centers not in accounts
+	ClearingAccount string            `yaml:"clearing_account"` // credited with the total cloud bill
+	Prefix          string            `yaml:"prefix"`           // journal ID prefix, "CB" by default
+}
+
+// InvoiceConfig configures chargeback invoices, one per cost center
+type InvoiceConfig struct {
+	Enabled bool                `yaml:"enabled"`
+	Prefix  string              `yaml:"prefix"`   // invoice number prefix, "INV" by default
+	DueDays int                 `yaml:"due_days"` // payment term in days (default: 30)
+	Formats []string            `yaml:"formats"`  // csv, json, xlsx, pdf (default: csv)
+	Rules   []InvoiceRuleConfig `yaml:"rules"`
+}
+
+// InvoiceRuleConfig adds a percentage of matching charges to invoices: a
+// positive percent is an uplift such as a platform fee, a negative one a
+// negotiated discount. Empty lists match everything.
+type InvoiceRuleConfig struct {
+	Name        string   `yaml:"name"`
+	Percent     float64  `yaml:"percent"`
+	CostCenters []string `yaml:"cost_centers"`
+	Services    []string `yaml:"services"`
+}
+
+// AllocationScenario is an alternative allocation strategy. Fields left
+// empty keep the chargeback setting.
+type AllocationScenario struct {
+	Name            string            `yaml:"name"`
+	PrimaryTag      string            `yaml:"primary_tag"`
+	FallbackTag     string            `yaml:"fallback_tag"`
+	AllocationKey   string            `yaml:"allocation_key"`
+	UntaggedPool    string            `yaml:"untagged_pool"`
+	SharedCostSplit []SharedCostSplit `yaml:"shared_cost_split"`
+}
+
+// SharedCostSplit assigns a fixed percentage of untagged costs to a cost center
+type SharedCostSplit struct {
+	CostCenter string  `yaml:"cost_center"`
+	Percentage float64 `yaml:"percentage"`
+}
+
+// AllocationOverride reassigns matching charges to another cost center.
+// Match fields left empty match anything.
+type AllocationOverride struct {
+	ID             string            `yaml:"id"` // ticket or dispute reference, logged for audit
+	Cloud          string            `yaml:"cloud"`
+	Account        string            `yaml:"account"`
+	Service        string            `yaml:"service"`
+	Resource       string            `yaml:"resource"`
+	Tags           map[string]string `yaml:"tags"`
+	CostCenter     string            `yaml:"cost_center"`
+	EffectiveMonth string            `yaml:"effective_month"` // YYYY-MM, applies from this month on
+	Reason         string            `yaml:"reason"`
+}
+
+// AllocationSplit divides matching charges across cost centers by weight,
+// e.g. shares {team-a: 60, team-b: 40}. Weights need not sum to 100; each
+// center gets its weight over their sum. Match fields left empty match
+// anything.
+type AllocationSplit struct {
+	ID       string             `yaml:"id"`
+	Cloud    string             `yaml:"cloud"`
+	Account  string             `yaml:"account"`
+	Service  string             `yaml:"service"`
+	Resource string             `yaml:"resource"`
+	Tags     map[string]string  `yaml:"tags"`
+	Shares   map[string]float64 `yaml:"shares"` // cost center -> weight
+}
+
+// TagPolicyConfig lists the tags every resource must carry
+type TagPolicyConfig struct {
+	Required []RequiredTag `yaml:"required"`
+	Top      int           `yaml:"top" default:"20"` // non-compliant resources listed by tags mode (default: 20)
+}
+
+// RequiredTag is a mandatory tag key with an optional value pattern
+type RequiredTag struct {
+	Key     string `yaml:"key"`
+	Pattern string `yaml:"pattern"` // regular expression the value must match, empty for any non-empty value
+
+	// Scope of the rule; empty applies it everywhere
+	Clouds   []string `yaml:"clouds"`
+	Accounts []string `yaml:"accounts"`
+}
+
+// CalendarConfig lists special days that shift spend predictably, and sets
+// the billing timezone and fiscal calendar
+type CalendarConfig struct {
+	SpecialDays             []SpecialDay `yaml:"special_days"`
+	WeekendsAreBusinessDays bool         `yaml:"weekends_are_business_days"`
+
+	// Billing day and fiscal periods behind "today", default date ranges,
+	// chargeback months, budget periods and forecast projections
+	Timezone        string `yaml:"timezone" default:"UTC"`                                                 // IANA name of the timezone the billing day is taken in
+	FiscalYearStart int    `yaml:"fiscal_year_start" default:"1" validate:"min=1,max=12"`                  // month the fiscal year starts
+	Periods         string `yaml:"periods" default:"calendar" validate:"oneof=calendar|4-4-5|4-5-4|5-4-4"` // fiscal months: calendar months, or weeks per month of each quarter
+	WeekStart       string `yaml:"week_start" default:"monday"`                                            // first weekday of week-based fiscal years
+}
+
+// SpecialDay is a holiday or calendar event
+type SpecialDay struct {
+	Date string `yaml:"date"` // YYYY-MM-DD
+	Name string `yaml:"name"`
+	Key  string `yaml:"key"` // groups equivalent days across years, defaults to the name
 }
 
 // AnomalyConfig configures anomaly detection
 type AnomalyConfig struct {
-	Enabled               bool    `yaml:"enabled"`
-	LookbackDays          int     `yaml:"lookback_days"`
-	DeviationThreshold    float64 `yaml:"deviation_threshold"`    // percentage (e.g., 25 = 25%)
-	MinimumCostThreshold  float64 `yaml:"minimum_cost_threshold"` // ignore services below this
+	Enabled              bool    `yaml:"enabled"`
+	LookbackDays         int     `yaml:"lookback_days" default:"30"`
+	DeviationThreshold   float64 `yaml:"deviation_threshold" default:"25"`                                   // percentage (e.g., 25 = 25%)
+	MinimumCostThreshold float64 `yaml:"minimum_cost_threshold"`                                             // ignore services below this
+	Sensitivity          string  `yaml:"sensitivity" default:"medium" validate:"oneof=low|medium|high"`      // low, medium, high
+	HolidayMode          string  `yaml:"holiday_mode" default:"exclude" validate:"oneof=exclude|equivalent"` // exclude or equivalent
+	Incremental          bool    `yaml:"incremental"`                                                        // evaluate only days new to the history store
+	Cooldown             string  `yaml:"cooldown" default:"24h"`                                             // suppress anomalies this long after a marked change, e.g. 24h or 2d
+	ChangesFile          string  `yaml:"changes_file" default:"./data/changes.json"`                         // where mark-change records changes
+	RampGrace            string  `yaml:"ramp_grace" default:"14d"`                                           // how long new accounts may ramp up from near zero, e.g. 14d; "0" disables
+	RampAction           string  `yaml:"ramp_action" default:"soften" validate:"oneof=soften|suppress"`      // soften or suppress anomalies in ramping accounts
+	MultiMetric          bool    `yaml:"multi_metric"`                                                       // classify anomalies from cost, usage and record count together
+
+	Seasonality []string `yaml:"seasonality"`                                // weekday, month_start
+	Algorithm   string   `yaml:"algorithm" validate:"oneof=zscore|mad|ewma"` // zscore (default), mad, or ewma
+	EWMAAlpha   float64  `yaml:"ewma_alpha" validate:"min=0,max=1"`          // weight of the latest day for ewma (default 0.3)
+
+	Scopes []AnomalyScope `yaml:"scopes"`
+}
+
+// AnomalyScope baselines total spend per group of dimension values, such as
+// a cost center tag, besides each cloud service
+type AnomalyScope struct {
+	Name        string            `yaml:"name"`
+	GroupBy     []string          `yaml:"group_by"`                                     // cloud, account, service, region or tag:<key>
+	Sensitivity string            `yaml:"sensitivity" validate:"oneof=low|medium|high"` // overrides anomaly.sensitivity
+	Overrides   map[string]string `yaml:"overrides"`                                    // sensitivity by group value, values joined with "/"
 }
 
 // AlertingConfig configures alerting channels
 type AlertingConfig struct {
 	Email EmailConfig `yaml:"email"`
 	Slack SlackConfig `yaml:"slack"`
+	Queue QueueConfig `yaml:"queue"`
+
+	Paging PagingConfig `yaml:"paging"`
+
+	Webhooks []WebhookConfig `yaml:"webhooks"`
+}
+
+// WebhookConfig configures posting alerts as JSON to an HTTP endpoint
+type WebhookConfig struct {
+	Name    string            `yaml:"name"`
+	URL     string            `yaml:"url"`
+	Secret  string            `yaml:"secret"`  // HMAC-SHA256 signing key; empty sends unsigned
+	Headers map[string]string `yaml:"headers"` // e.g. Authorization
+	Types   []string          `yaml:"types"`   // anomaly, budget, forecast; empty sends all
+}
+
+// PagingConfig configures opening incidents for critical alerts
+type PagingConfig struct {
+	Enabled       bool     `yaml:"enabled"`
+	Backend       string   `yaml:"backend" validate:"oneof=pagerduty|opsgenie"` // pagerduty or opsgenie
+	RoutingKey    string   `yaml:"routing_key"`                                 // pagerduty Events API v2 integration key
+	APIKey        string   `yaml:"api_key"`                                     // opsgenie
+	APIURL        string   `yaml:"api_url"`                                     // opsgenie, e.g. https://api.eu.opsgenie.com
+	Severities    []string `yaml:"severities" default:"critical"`               // anomaly severities that page (default: critical)
+	BudgetPercent int      `yaml:"budget_percent" default:"100"`                // percent of a budget used that pages (default: 100)
+}
+
+// QueueConfig configures publishing alerts to a message queue
+type QueueConfig struct {
+	Enabled   bool     `yaml:"enabled"`
+	Backend   string   `yaml:"backend" validate:"oneof=kafka|sns|pubsub"` // kafka, sns, or pubsub
+	Topic     string   `yaml:"topic"`                                     // Kafka topic, SNS topic ARN, or Pub/Sub topic ID
+	Brokers   []string `yaml:"brokers"`                                   // kafka
+	Region    string   `yaml:"region"`                                    // sns
+	ProjectID string   `yaml:"project_id"`                                // pubsub
 }
 
 // EmailConfig configures email alerting
@@ -77,6 +814,8 @@ type EmailConfig struct {
 	SMTPHost   string   `yaml:"smtp_host"`
 	SMTPPort   int      `yaml:"smtp_port"`
 	FromAddr   string   `yaml:"from_addr"`
+	Username   string   `yaml:"username"` // SMTP auth, optional
+	Password   string   `yaml:"password"`
 	Recipients []string `yaml:"recipients"`
 	// Or use Microsoft Graph
 	UseMSGraph bool   `yaml:"use_ms_graph"`
@@ -93,11 +832,32 @@ type SlackConfig struct {
 
 // ReporterConfig configures report generation
 type ReporterConfig struct {
-	OutputDir   string `yaml:"output_dir"`
-	HTMLTemplate string `yaml:"html_template"`
+	OutputDir    string    `yaml:"output_dir" default:"./reports"`
+	HTMLTemplate string    `yaml:"html_template"` // replaces the built-in HTML report
+	CSV          CSVConfig `yaml:"csv"`
+
+	TemplatesDir string        `yaml:"templates_dir"` // *.tmpl files rendered alongside each report
+	Publish      PublishConfig `yaml:"publish"`
+}
+
+// PublishConfig uploads the reports each run writes to object storage
+type PublishConfig struct {
+	Enabled       bool   `yaml:"enabled"`
+	URL           string `yaml:"url"`            // s3://bucket/prefix, gs://bucket/prefix or az://account/container/prefix
+	Region        string `yaml:"region"`         // s3, defaults to the AWS SDK's
+	RetentionDays int    `yaml:"retention_days"` // delete published reports older than this; 0 keeps them
 }
 
-// Load loads configuration from a YAML file
+// CSVConfig tunes CSV report writing for large entry counts
+type CSVConfig struct {
+	SortBy    string `yaml:"sort_by" default:"date" validate:"oneof=date|cost"` // date or cost
+	FlushRows int    `yaml:"flush_rows" default:"5000"`                         // rows buffered between flushes
+	Workers   int    `yaml:"workers" default:"1"`                               // >1 formats shards in parallel, then merges
+}
+
+// Load loads configuration from a YAML file. Unknown keys are rejected, so
+// misspelt settings fail loudly instead of being ignored; empty fields take
+// their default tags, and every field breaking its validate tag is reported.
 func Load(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -108,21 +868,24 @@ func Load(path string) (*Config, error) {
 	data = []byte(os.ExpandEnv(string(data)))
 
 	var cfg Config
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
 		return nil, fmt.Errorf("failed to parse config: %w", err)
 	}
 
 	// Set defaults
-	if cfg.Anomaly.LookbackDays == 0 {
-		cfg.Anomaly.LookbackDays = 30
-	}
-	if cfg.Anomaly.DeviationThreshold == 0 {
-		cfg.Anomaly.DeviationThreshold = 25
+	v := reflect.ValueOf(&cfg).Elem()
+	if err := applyDefaults(v); err != nil {
+		return nil, err
 	}
-	if cfg.Reporter.OutputDir == "" {
-		cfg.Reporter.OutputDir = "./reports"
+	// Cost Explorer throttles bursts of more than a few calls a second
+	if cfg.AWS.Calls.RateLimit == 0 {
+		cfg.AWS.Calls.RateLimit = 1
 	}
 
+	if err := validate(v, ""); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
 	return &cfg, nil
 }
-
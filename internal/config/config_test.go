@@ -0,0 +1,95 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeConfig writes a config file and returns its path
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	t.Setenv("FINOPS_REPORT_DIR", "/var/reports")
+	cfg, err := Load(writeConfig(t, `
+reporter:
+  output_dir: ${FINOPS_REPORT_DIR}
+  csv:
+    sort_by: cost
+tracing:
+  sample_ratio: 0
+reports:
+  - name: weekly
+  - name: invoices
+    command: chargeback
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		field     string
+		got, want any
+	}{
+		{"reporter.output_dir", cfg.Reporter.OutputDir, "/var/reports"},
+		{"reporter.csv.sort_by", cfg.Reporter.CSV.SortBy, "cost"},
+		{"reporter.csv.flush_rows", cfg.Reporter.CSV.FlushRows, 5000},
+		{"fetch.timeout", cfg.Fetch.Timeout, "30m"},
+		{"fetch.partial_action", cfg.Fetch.PartialAction, "warn"},
+		{"tracing.service_name", cfg.Tracing.ServiceName, "finops-platform"},
+		// Zero is indistinguishable from unset, so takes the default
+		{"tracing.sample_ratio", cfg.Tracing.SampleRatio, 1.0},
+		{"reports[0].command", cfg.Reports[0].Command, "aggregate"},
+		{"reports[1].command", cfg.Reports[1].Command, "chargeback"},
+		{"aws.calls.rate_limit", cfg.AWS.Calls.RateLimit, 1.0},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.field, tt.got, tt.want)
+		}
+	}
+
+	// An empty file is all defaults
+	cfg, err = Load(writeConfig(t, ""))
+	if err != nil || cfg.Reporter.OutputDir != "./reports" {
+		t.Errorf("Load(empty) = %+v, %v; want the defaults", cfg, err)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name, content string
+		want          []string
+	}{
+		{"unknown key", "reporter:\n  output_directory: ./out\n", []string{"field output_directory not found"}},
+		{"wrong type", "reporter:\n  csv:\n    flush_rows: many\n", []string{"failed to parse config"}},
+		{"every violation", "fetch:\n  partial_action: fail\ntracing:\n  sample_ratio: 2\n", []string{
+			`fetch.partial_action: "fail" is not one of warn, error`,
+			"tracing.sample_ratio: 2 is above the maximum of 1",
+		}},
+	}
+	for _, tt := range tests {
+		_, err := Load(writeConfig(t, tt.content))
+		if err == nil {
+			t.Errorf("%s: Load() succeeded, want an error", tt.name)
+			continue
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("%s: Load() = %v, want %s", tt.name, err, want)
+			}
+		}
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.yaml")); err == nil || !strings.Contains(err.Error(), "failed to read config file") {
+		t.Errorf("Load(missing) = %v, want a read error", err)
+	}
+}
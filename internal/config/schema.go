@@ -0,0 +1,92 @@
+package config
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// SchemaID is the JSON Schema dialect of Schema
+const SchemaID = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema describes the configuration file as JSON Schema, for editors to
+// complete and check config files. Unknown keys are rejected, as Load
+// rejects them; defaults, allowed values and bounds come from the fields'
+// tags.
+func Schema() map[string]any {
+	s := typeSchema(reflect.TypeOf(Config{}))
+	s["$schema"] = SchemaID
+	s["title"] = "FinOps Platform configuration"
+	return s
+}
+
+// typeSchema describes values of type t
+func typeSchema(t reflect.Type) map[string]any {
+	switch t.Kind() {
+	case reflect.Struct:
+		props := make(map[string]any, t.NumField())
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.IsExported() {
+				props[yamlName(f)] = fieldSchema(f)
+			}
+		}
+		return map[string]any{"type": "object", "properties": props, "additionalProperties": false}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Int:
+		return map[string]any{"type": "integer"}
+	case reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	default:
+		return map[string]any{"type": "string"}
+	}
+}
+
+// fieldSchema adds a field's default and rules to its type's schema
+func fieldSchema(f reflect.StructField) map[string]any {
+	s := typeSchema(f.Type)
+	def, hasDefault := f.Tag.Lookup("default")
+	if hasDefault {
+		s["default"] = schemaDefault(f.Type, def)
+	}
+	if tag, ok := f.Tag.Lookup("validate"); ok {
+		r, _ := parseRules(tag) // malformed tags are reported by Load
+		if len(r.oneOf) > 0 {
+			enum := r.oneOf
+			// Without a default, leaving the value empty is a choice of its own
+			if !hasDefault {
+				enum = append([]string{""}, enum...)
+			}
+			s["enum"] = enum
+		}
+		if r.min != nil {
+			s["minimum"] = *r.min
+		}
+		if r.max != nil {
+			s["maximum"] = *r.max
+		}
+	}
+	return s
+}
+
+// schemaDefault converts a default tag to its JSON value
+func schemaDefault(t reflect.Type, def string) any {
+	switch t.Kind() {
+	case reflect.Int, reflect.Float64:
+		if n, err := strconv.ParseFloat(def, 64); err == nil {
+			return n
+		}
+	case reflect.Bool:
+		if b, err := strconv.ParseBool(def); err == nil {
+			return b
+		}
+	case reflect.Slice:
+		return strings.Split(def, ",")
+	}
+	return def
+}
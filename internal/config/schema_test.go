@@ -0,0 +1,49 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestSchema(t *testing.T) {
+	s := Schema()
+	if s["$schema"] != SchemaID || s["additionalProperties"] != false {
+		t.Errorf("Schema() = %v, want a closed object of dialect %s", s, SchemaID)
+	}
+	if _, err := json.Marshal(s); err != nil {
+		t.Errorf("Schema() does not encode: %v", err)
+	}
+	props := s["properties"].(map[string]any)
+	fetch := props["fetch"].(map[string]any)["properties"].(map[string]any)
+	if got := fetch["timeouts"]; !reflect.DeepEqual(got, map[string]any{"type": "object", "additionalProperties": map[string]any{"type": "string"}}) {
+		t.Errorf("fetch.timeouts = %v, want a map of strings", got)
+	}
+
+	got := typeSchema(reflect.TypeOf(tagged{}))["properties"].(map[string]any)
+	want := map[string]any{
+		"name":    map[string]any{"type": "string", "default": "main"},
+		"count":   map[string]any{"type": "integer", "default": 3.0, "minimum": 1.0},
+		"ratio":   map[string]any{"type": "number", "default": 0.5, "minimum": 0.0, "maximum": 1.0},
+		"enabled": map[string]any{"type": "boolean", "default": true},
+		"clouds":  map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "default": []string{"aws", "gcp"}},
+		// Without a default the value may be left empty
+		"mode": map[string]any{"type": "string", "enum": []string{"", "fast", "safe"}},
+		"items": map[string]any{"type": "array", "items": map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"kind": map[string]any{"type": "string", "default": "usage", "enum": []string{"usage", "credit"}},
+				"size": map[string]any{"type": "integer", "maximum": 10.0},
+			},
+			"additionalProperties": false,
+		}},
+	}
+	for name, w := range want {
+		if !reflect.DeepEqual(got[name], w) {
+			t.Errorf("%s = %v, want %v", name, got[name], w)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("properties = %v, want %d without unexported fields", got, len(want))
+	}
+}
@@ -0,0 +1,182 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Fields declare their defaults and constraints in struct tags:
+//
+//	default:"24h"                 set when the field is empty or zero; lists split on commas
+//	validate:"oneof=warn|error"   a non-empty value must be one of these
+//	validate:"min=0,max=1"        bounds of a number
+//
+// Both apply wherever the struct appears, including in lists.
+
+// applyDefaults sets each empty field that has a default tag
+func applyDefaults(v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			if def, ok := f.Tag.Lookup("default"); ok && v.Field(i).IsZero() {
+				if err := setValue(v.Field(i), def); err != nil {
+					return fmt.Errorf("%s.%s: invalid default %q: %w", t.Name(), f.Name, def, err)
+				}
+			}
+			if err := applyDefaults(v.Field(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if err := applyDefaults(v.Index(i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// setValue parses s into a string, number, bool or list of strings
+func setValue(v reflect.Value, s string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(n))
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", v.Type())
+		}
+		v.Set(reflect.ValueOf(strings.Split(s, ",")))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// rules is a parsed validate tag
+type rules struct {
+	oneOf    []string
+	min, max *float64
+}
+
+func parseRules(tag string) (rules, error) {
+	var r rules
+	for _, rule := range strings.Split(tag, ",") {
+		name, arg, _ := strings.Cut(rule, "=")
+		switch name {
+		case "oneof":
+			r.oneOf = strings.Split(arg, "|")
+		case "min", "max":
+			n, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				return rules{}, fmt.Errorf("invalid %s %q", name, arg)
+			}
+			if name == "min" {
+				r.min = &n
+			} else {
+				r.max = &n
+			}
+		default:
+			return rules{}, fmt.Errorf("unknown rule %q", name)
+		}
+	}
+	return r, nil
+}
+
+// validate checks each field against its validate tag, reporting every
+// violation by the field's YAML path
+func validate(v reflect.Value, path string) error {
+	var errs []error
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			fieldPath := yamlName(f)
+			if path != "" {
+				fieldPath = path + "." + fieldPath
+			}
+			if tag, ok := f.Tag.Lookup("validate"); ok {
+				r, err := parseRules(tag)
+				if err != nil {
+					return fmt.Errorf("%s.%s: %w", t.Name(), f.Name, err)
+				}
+				if err := r.check(v.Field(i)); err != nil {
+					errs = append(errs, fmt.Errorf("%s: %w", fieldPath, err))
+				}
+			}
+			errs = append(errs, validate(v.Field(i), fieldPath))
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			errs = append(errs, validate(v.Index(i), fmt.Sprintf("%s[%d]", path, i)))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (r rules) check(v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.String:
+		s := v.String()
+		if len(r.oneOf) > 0 && s != "" && !contains(r.oneOf, s) {
+			return fmt.Errorf("%q is not one of %s", s, strings.Join(r.oneOf, ", "))
+		}
+	case reflect.Int, reflect.Float64:
+		n := v.Convert(reflect.TypeOf(float64(0))).Float()
+		if r.min != nil && n < *r.min {
+			return fmt.Errorf("%v is below the minimum of %v", n, *r.min)
+		}
+		if r.max != nil && n > *r.max {
+			return fmt.Errorf("%v is above the maximum of %v", n, *r.max)
+		}
+	}
+	return nil
+}
+
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// yamlName is the key a field is read from
+func yamlName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+	if name == "" {
+		return strings.ToLower(f.Name)
+	}
+	return name
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// tagged exercises every default and rule type
+type tagged struct {
+	Name    string   `yaml:"name" default:"main"`
+	Count   int      `yaml:"count" default:"3" validate:"min=1"`
+	Ratio   float64  `yaml:"ratio" default:"0.5" validate:"min=0,max=1"`
+	Enabled bool     `yaml:"enabled" default:"true"`
+	Clouds  []string `yaml:"clouds" default:"aws,gcp"`
+	Mode    string   `yaml:"mode" validate:"oneof=fast|safe"`
+	Items   []item   `yaml:"items"`
+	hidden  string   // neither defaulted, validated nor described
+}
+
+type item struct {
+	Kind string `yaml:"kind" default:"usage" validate:"oneof=usage|credit"`
+	Size int    `validate:"max=10"`
+}
+
+func TestApplyDefaults(t *testing.T) {
+	v := tagged{Count: 7, Items: []item{{}, {Kind: "credit"}}}
+	if err := applyDefaults(reflect.ValueOf(&v).Elem()); err != nil {
+		t.Fatal(err)
+	}
+	want := tagged{Name: "main", Count: 7, Ratio: 0.5, Enabled: true, Clouds: []string{"aws", "gcp"}, Items: []item{{Kind: "usage"}, {Kind: "credit"}}}
+	if !reflect.DeepEqual(v, want) {
+		t.Errorf("applyDefaults() = %+v, want %+v", v, want)
+	}
+
+	var bad struct {
+		Count int `default:"three"`
+	}
+	if err := applyDefaults(reflect.ValueOf(&bad).Elem()); err == nil || !strings.Contains(err.Error(), `invalid default "three"`) {
+		t.Errorf("applyDefaults() = %v, want an invalid default error", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := tagged{Count: 1, Ratio: 1, Mode: "safe", Items: []item{{Kind: "usage", Size: 10}}}
+	if err := validate(reflect.ValueOf(valid), ""); err != nil {
+		t.Errorf("validate(valid) = %v", err)
+	}
+
+	err := validate(reflect.ValueOf(tagged{Count: 0, Ratio: -0.5, Mode: "slow", Items: []item{{}, {Kind: "refund", Size: 11}}}), "")
+	if err == nil {
+		t.Fatal("validate() succeeded, want every violation")
+	}
+	want := []string{
+		"count: 0 is below the minimum of 1",
+		"ratio: -0.5 is below the minimum of 0",
+		`mode: "slow" is not one of fast, safe`,
+		`items[1].kind: "refund" is not one of usage, credit`,
+		"items[1].size: 11 is above the maximum of 10",
+	}
+	if got := strings.Split(err.Error(), "\n"); !reflect.DeepEqual(got, want) {
+		t.Errorf("validate() = %q, want %q", got, want)
+	}
+
+	var malformed struct {
+		Mode string `validate:"in=a|b"`
+	}
+	if err := validate(reflect.ValueOf(malformed), ""); err == nil || !strings.Contains(err.Error(), `unknown rule "in"`) {
+		t.Errorf("validate(malformed) = %v, want an unknown rule error", err)
+	}
+}
+
+// TestConfigTags checks every tag of the configuration parses, so that a
+// mistyped tag fails here rather than in Load
+func TestConfigTags(t *testing.T) {
+	var cfg Config
+	v := reflect.ValueOf(&cfg).Elem()
+	if err := applyDefaults(v); err != nil {
+		t.Fatal(err)
+	}
+	if err := validate(v, ""); err != nil {
+		t.Errorf("defaults are invalid: %v", err)
+	}
+}
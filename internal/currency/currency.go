@@ -0,0 +1,132 @@
+// Package currency converts cost amounts between currencies
+package currency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+// ErrNoRate is returned when no exchange rate is known for a currency
+var ErrNoRate = errors.New("no exchange rate")
+
+// Converter converts amounts using rates quoted against a base currency
+type Converter struct {
+	base  string
+	rates map[string]float64 // units of currency per one unit of base
+}
+
+// NewStatic creates a converter from a fixed rate table
+func NewStatic(base string, rates map[string]float64) *Converter {
+	c := &Converter{
+		base:  strings.ToUpper(base),
+		rates: make(map[string]float64, len(rates)+1),
+	}
+	for code, rate := range rates {
+		c.rates[strings.ToUpper(code)] = rate
+	}
+	c.rates[c.base] = 1
+	return c
+}
+
+// FromConfig creates a converter from the configured rate table
+func FromConfig(cfg config.CurrencyConfig) (*Converter, error) {
+	for code, rate := range cfg.Rates {
+		if rate <= 0 {
+			return nil, fmt.Errorf("invalid exchange rate %v for %s", rate, code)
+		}
+	}
+	return NewStatic(cfg.Base, cfg.Rates), nil
+}
+
+// Load creates a converter from the configured rate source, caching fetched
+// rates in the cache file. Rates in the config table override fetched ones.
+func Load(ctx context.Context, cfg config.CurrencyConfig) (*Converter, error) {
+	static, err := FromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var source Source
+	switch strings.ToLower(cfg.Source) {
+	case "", "static":
+		return static, nil
+	case "ecb":
+		source = NewECBSource()
+	default:
+		return nil, fmt.Errorf("unknown exchange rate source %q", cfg.Source)
+	}
+
+	if cfg.CacheFile != "" {
+		ttl, err := time.ParseDuration(cfg.CacheTTL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid currency cache_ttl %q: %w", cfg.CacheTTL, err)
+		}
+		source = NewCachedSource(source, cfg.CacheFile, ttl)
+	}
+
+	rates, err := source.Rates(ctx, static.base)
+	if err != nil {
+		return nil, err
+	}
+	merged := make(map[string]float64, len(rates)+len(cfg.Rates))
+	for code, rate := range rates {
+		merged[code] = rate
+	}
+	for code, rate := range cfg.Rates {
+		merged[strings.ToUpper(code)] = rate
+	}
+	return NewStatic(static.base, merged), nil
+}
+
+// Base returns the base currency
+func (c *Converter) Base() string {
+	return c.base
+}
+
+// Rate returns the multiplier converting an amount in from into to
+func (c *Converter) Rate(from, to string) (float64, error) {
+	from, to = strings.ToUpper(from), strings.ToUpper(to)
+	if from == to {
+		return 1, nil
+	}
+
+	fromRate, ok := c.rates[from]
+	if !ok {
+		return 0, fmt.Errorf("%w for %s", ErrNoRate, from)
+	}
+	toRate, ok := c.rates[to]
+	if !ok {
+		return 0, fmt.Errorf("%w for %s", ErrNoRate, to)
+	}
+	return toRate / fromRate, nil
+}
+
+// Convert converts amount from one currency to another
+func (c *Converter) Convert(amount float64, from, to string) (float64, error) {
+	rate, err := c.Rate(from, to)
+	if err != nil {
+		return 0, err
+	}
+	return amount * rate, nil
+}
+
+// Missing returns the currencies in codes that have no rate, sorted
+func (c *Converter) Missing(codes []string) []string {
+	seen := make(map[string]bool)
+	var missing []string
+	for _, code := range codes {
+		code = strings.ToUpper(code)
+		if _, ok := c.rates[code]; !ok && !seen[code] {
+			missing = append(missing, code)
+			seen[code] = true
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
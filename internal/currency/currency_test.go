@@ -0,0 +1,44 @@
+package currency
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+func TestConverterRate(t *testing.T) {
+	c := NewStatic("usd", map[string]float64{"eur": 0.9, "GBP": 0.8})
+	tests := []struct {
+		from, to string
+		want     float64
+	}{
+		{"USD", "EUR", 0.9},
+		{"eur", "usd", 1 / 0.9},
+		{"EUR", "GBP", 0.8 / 0.9},
+		{"JPY", "JPY", 1},
+	}
+	for _, tt := range tests {
+		got, err := c.Rate(tt.from, tt.to)
+		if err != nil || math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("Rate(%s, %s) = %v, %v; want %v", tt.from, tt.to, got, err, tt.want)
+		}
+	}
+	if _, err := c.Convert(10, "USD", "JPY"); !errors.Is(err, ErrNoRate) {
+		t.Errorf("Convert to JPY = %v, want ErrNoRate", err)
+	}
+	if got := c.Missing([]string{"eur", "jpy", "CHF", "JPY", "usd"}); len(got) != 2 || got[0] != "CHF" || got[1] != "JPY" {
+		t.Errorf("Missing = %v, want [CHF JPY]", got)
+	}
+}
+
+func TestFromConfigRejectsBadRates(t *testing.T) {
+	if _, err := FromConfig(config.CurrencyConfig{Base: "USD", Rates: map[string]float64{"EUR": 0}}); err == nil {
+		t.Error("zero rate accepted")
+	}
+	c, err := FromConfig(config.CurrencyConfig{Base: "USD", Rates: map[string]float64{"EUR": 0.9}})
+	if err != nil || c.Base() != "USD" {
+		t.Errorf("FromConfig = %v, %v; want a USD converter", c, err)
+	}
+}
@@ -0,0 +1,188 @@
+package currency
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Source supplies exchange rates as units of each currency per one unit of
+// base
+type Source interface {
+	Rates(ctx context.Context, base string) (map[string]float64, error)
+	Name() string
+}
+
+// StaticSource serves a fixed rate table
+type StaticSource map[string]float64
+
+// Rates returns the table as is
+func (s StaticSource) Rates(ctx context.Context, base string) (map[string]float64, error) {
+	return s, nil
+}
+
+// Name returns the source name
+func (s StaticSource) Name() string {
+	return "static"
+}
+
+// ECBURL is the European Central Bank daily reference rate feed
+const ECBURL = "https://www.ecb.europa.eu/stats/eurofxref/eurofxref-daily.xml"
+
+// ECBSource fetches the European Central Bank daily reference rates, quoted
+// against EUR, and rebases them on the requested currency
+type ECBSource struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewECBSource creates a source reading the ECB daily feed
+func NewECBSource() *ECBSource {
+	return &ECBSource{URL: ECBURL, Client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// Name returns the source name
+func (s *ECBSource) Name() string {
+	return "ecb"
+}
+
+// Rates fetches the latest reference rates
+func (s *ECBSource) Rates(ctx context.Context, base string) (map[string]float64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch ECB rates: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("failed to fetch ECB rates: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var feed struct {
+		Cube struct {
+			Cube struct {
+				Time  string `xml:"time,attr"`
+				Rates []struct {
+					Currency string  `xml:"currency,attr"`
+					Rate     float64 `xml:"rate,attr"`
+				} `xml:"Cube"`
+			} `xml:"Cube"`
+		} `xml:"Cube"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&feed); err != nil {
+		return nil, fmt.Errorf("failed to parse ECB rates: %w", err)
+	}
+
+	eur := map[string]float64{"EUR": 1}
+	for _, r := range feed.Cube.Cube.Rates {
+		if r.Rate > 0 {
+			eur[strings.ToUpper(r.Currency)] = r.Rate
+		}
+	}
+	if len(eur) == 1 {
+		return nil, fmt.Errorf("ECB feed has no rates")
+	}
+	baseRate, ok := eur[strings.ToUpper(base)]
+	if !ok {
+		return nil, fmt.Errorf("%w for base %s in ECB feed", ErrNoRate, base)
+	}
+
+	rates := make(map[string]float64, len(eur))
+	for code, rate := range eur {
+		rates[code] = rate / baseRate
+	}
+	return rates, nil
+}
+
+// cachedRates is the cache file format
+type cachedRates struct {
+	Source    string             `json:"source"`
+	Base      string             `json:"base"`
+	FetchedAt time.Time          `json:"fetched_at"`
+	Rates     map[string]float64 `json:"rates"`
+}
+
+// CachedSource reuses another source's rates from a file until they are
+// older than the TTL, and falls back to stale rates when a fetch fails
+type CachedSource struct {
+	source Source
+	path   string
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+// NewCachedSource caches source's rates in the file at path for ttl
+func NewCachedSource(source Source, path string, ttl time.Duration) *CachedSource {
+	return &CachedSource{source: source, path: path, ttl: ttl, now: time.Now}
+}
+
+// Name returns the wrapped source's name
+func (c *CachedSource) Name() string {
+	return c.source.Name()
+}
+
+// Rates returns cached rates when fresh, otherwise fetches and caches them
+func (c *CachedSource) Rates(ctx context.Context, base string) (map[string]float64, error) {
+	cached, ok := c.load(base)
+	if ok && c.now().Sub(cached.FetchedAt) < c.ttl {
+		return cached.Rates, nil
+	}
+
+	rates, err := c.source.Rates(ctx, base)
+	if err != nil {
+		if ok {
+			log.Printf("Warning: %v; using %s rates from %s", err, c.source.Name(), cached.FetchedAt.Format(time.RFC3339))
+			return cached.Rates, nil
+		}
+		return nil, err
+	}
+
+	if err := c.save(cachedRates{Source: c.source.Name(), Base: base, FetchedAt: c.now(), Rates: rates}); err != nil {
+		log.Printf("Warning: failed to cache exchange rates: %v", err)
+	}
+	return rates, nil
+}
+
+// load reads the cache, ignoring it when it is missing, unreadable or for
+// another source or base
+func (c *CachedSource) load(base string) (cachedRates, bool) {
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		return cachedRates{}, false
+	}
+	var cached cachedRates
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return cachedRates{}, false
+	}
+	if cached.Source != c.source.Name() || !strings.EqualFold(cached.Base, base) || len(cached.Rates) == 0 {
+		return cachedRates{}, false
+	}
+	return cached, true
+}
+
+func (c *CachedSource) save(cached cachedRates) error {
+	data, err := json.MarshalIndent(cached, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
@@ -0,0 +1,135 @@
+package currency
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+const ecbFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<gesmes:Envelope xmlns:gesmes="http://www.gesmes.org/xml/2002-08-01" xmlns="http://www.ecb.int/vocabulary/2002-08-01/eurofxref">
+	<gesmes:subject>Reference rates</gesmes:subject>
+	<Cube>
+		<Cube time="2024-03-01">
+			<Cube currency="USD" rate="1.25"/>
+			<Cube currency="GBP" rate="0.85"/>
+			<Cube currency="JPY" rate="162.5"/>
+		</Cube>
+	</Cube>
+</gesmes:Envelope>`
+
+// countingSource serves rates, or err, and counts its calls
+type countingSource struct {
+	rates map[string]float64
+	err   error
+	calls int
+}
+
+func (s *countingSource) Rates(ctx context.Context, base string) (map[string]float64, error) {
+	s.calls++
+	return s.rates, s.err
+}
+
+func (s *countingSource) Name() string { return "counting" }
+
+func TestECBSourceRebases(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, ecbFeed)
+	}))
+	defer srv.Close()
+	s := &ECBSource{URL: srv.URL, Client: srv.Client()}
+
+	rates, err := s.Rates(context.Background(), "usd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]float64{"USD": 1, "EUR": 0.8, "GBP": 0.68, "JPY": 130}
+	for code, rate := range want {
+		if math.Abs(rates[code]-rate) > 1e-9 {
+			t.Errorf("%s = %v, want %v", code, rates[code], rate)
+		}
+	}
+
+	if _, err := s.Rates(context.Background(), "CHF"); !errors.Is(err, ErrNoRate) {
+		t.Errorf("CHF base = %v, want ErrNoRate", err)
+	}
+}
+
+func TestECBSourceErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/down" {
+			http.Error(w, "maintenance", http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, `<Envelope><Cube><Cube time="2024-03-01"></Cube></Cube></Envelope>`)
+	}))
+	defer srv.Close()
+
+	if _, err := (&ECBSource{URL: srv.URL + "/down", Client: srv.Client()}).Rates(context.Background(), "EUR"); err == nil {
+		t.Error("503 accepted")
+	}
+	if _, err := (&ECBSource{URL: srv.URL, Client: srv.Client()}).Rates(context.Background(), "EUR"); err == nil {
+		t.Error("empty feed accepted")
+	}
+}
+
+func TestCachedSource(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	src := &countingSource{rates: map[string]float64{"USD": 1, "EUR": 0.9}}
+	path := filepath.Join(t.TempDir(), "cache", "rates.json")
+	cached := func() *CachedSource {
+		c := NewCachedSource(src, path, 24*time.Hour)
+		c.now = func() time.Time { return now }
+		return c
+	}
+
+	if rates, err := cached().Rates(context.Background(), "USD"); err != nil || rates["EUR"] != 0.9 || src.calls != 1 {
+		t.Fatalf("first fetch = %v, %v after %d calls", rates, err, src.calls)
+	}
+
+	// A fresh cache is used across runs
+	now = now.Add(time.Hour)
+	if rates, err := cached().Rates(context.Background(), "USD"); err != nil || rates["EUR"] != 0.9 || src.calls != 1 {
+		t.Errorf("cached fetch = %v, %v after %d calls, want no new call", rates, err, src.calls)
+	}
+
+	// Another base is not served from the cache
+	if _, err := cached().Rates(context.Background(), "EUR"); err != nil || src.calls != 2 {
+		t.Errorf("EUR base = %v after %d calls, want a new call", err, src.calls)
+	}
+
+	// Once stale the rates are refetched, and kept when the fetch fails
+	now = now.Add(48 * time.Hour)
+	src.rates, src.err = nil, errors.New("feed down")
+	if rates, err := cached().Rates(context.Background(), "EUR"); err != nil || rates["EUR"] != 0.9 || src.calls != 3 {
+		t.Errorf("stale fetch = %v, %v after %d calls, want the stale rates", rates, err, src.calls)
+	}
+
+	// With nothing cached a failed fetch is an error
+	empty := NewCachedSource(src, filepath.Join(t.TempDir(), "rates.json"), time.Hour)
+	if _, err := empty.Rates(context.Background(), "USD"); err == nil {
+		t.Error("failed fetch with no cache returned rates")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	c, err := Load(context.Background(), config.CurrencyConfig{Base: "USD", Rates: map[string]float64{"EUR": 0.8}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := c.Convert(10, "EUR", "USD"); math.Abs(got-12.5) > 1e-9 {
+		t.Errorf("static EUR->USD = %v, want 12.5", got)
+	}
+
+	if _, err := Load(context.Background(), config.CurrencyConfig{Base: "USD", Source: "oanda"}); err == nil {
+		t.Error("unknown source accepted")
+	}
+}
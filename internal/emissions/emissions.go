@@ -0,0 +1,110 @@
+// Package emissions estimates the carbon footprint of cloud usage from
+// configurable emission factors. All figures are estimates.
+package emissions
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+// Factor converts usage (or, failing that, spend) into kilograms of CO2e.
+// Empty scope fields match anything; the most specific matching factor wins.
+type Factor struct {
+	Provider    string
+	Region      string
+	Service     string
+	UsageUnit   string  // matched case-insensitively, e.g. Hrs or GB-Mo
+	PerUnit     float64 // kg CO2e per unit of usage
+	PerDollar   float64 // kg CO2e per dollar, used when usage does not apply
+	specificity int
+}
+
+// Table is a set of emission factors
+type Table struct {
+	factors []Factor
+}
+
+// FromConfig builds the factor table, returning nil when emissions are disabled
+func FromConfig(cfg config.EmissionsConfig) (*Table, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	t := &Table{}
+	for i, f := range cfg.Factors {
+		if f.PerUnit < 0 || f.PerDollar < 0 {
+			return nil, fmt.Errorf("emission factor %d: factors must not be negative", i+1)
+		}
+		if f.PerUnit == 0 && f.PerDollar == 0 {
+			return nil, fmt.Errorf("emission factor %d: set kg_co2e_per_unit or kg_co2e_per_dollar", i+1)
+		}
+		if f.PerUnit > 0 && f.UsageUnit == "" {
+			return nil, fmt.Errorf("emission factor %d: kg_co2e_per_unit requires usage_unit", i+1)
+		}
+
+		factor := Factor{
+			Provider:  f.Provider,
+			Region:    f.Region,
+			Service:   f.Service,
+			UsageUnit: f.UsageUnit,
+			PerUnit:   f.PerUnit,
+			PerDollar: f.PerDollar,
+		}
+		for _, field := range []string{f.Provider, f.Region, f.Service, f.UsageUnit} {
+			if field != "" {
+				factor.specificity++
+			}
+		}
+		t.factors = append(t.factors, factor)
+	}
+	return t, nil
+}
+
+// Estimate returns the estimated kg CO2e for a line item, false when no
+// factor applies
+func (t *Table) Estimate(provider, region, service, unit string, usage, cost float64) (float64, bool) {
+	if t == nil {
+		return 0, false
+	}
+
+	var best *Factor
+	for i := range t.factors {
+		f := &t.factors[i]
+		if !f.matches(provider, region, service, unit, usage) {
+			continue
+		}
+		if best == nil || f.specificity > best.specificity {
+			best = f
+		}
+	}
+	if best == nil {
+		return 0, false
+	}
+
+	if best.PerUnit > 0 && usage > 0 {
+		return usage * best.PerUnit, true
+	}
+	return cost * best.PerDollar, true
+}
+
+func (f *Factor) matches(provider, region, service, unit string, usage float64) bool {
+	if f.Provider != "" && f.Provider != provider {
+		return false
+	}
+	if f.Region != "" && f.Region != region {
+		return false
+	}
+	if f.Service != "" && f.Service != service {
+		return false
+	}
+	if f.UsageUnit != "" && !strings.EqualFold(f.UsageUnit, unit) {
+		return false
+	}
+	// A usage-only factor cannot estimate a line item without usage
+	if f.PerDollar == 0 && usage <= 0 {
+		return false
+	}
+	return true
+}
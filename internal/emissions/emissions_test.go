@@ -0,0 +1,75 @@
+package emissions
+
+import (
+	"testing"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+func TestFromConfig(t *testing.T) {
+	if table, err := FromConfig(config.EmissionsConfig{Factors: []config.EmissionFactor{{PerDollar: 1}}}); table != nil || err != nil {
+		t.Errorf("disabled: got %v, %v, want nil table", table, err)
+	}
+
+	bad := []config.EmissionFactor{
+		{PerDollar: -1},
+		{Service: "EC2"},
+		{PerUnit: 0.1},
+	}
+	for _, f := range bad {
+		if _, err := FromConfig(config.EmissionsConfig{Enabled: true, Factors: []config.EmissionFactor{f}}); err == nil {
+			t.Errorf("factor %+v accepted", f)
+		}
+	}
+}
+
+func TestEstimate(t *testing.T) {
+	table, err := FromConfig(config.EmissionsConfig{Enabled: true, Factors: []config.EmissionFactor{
+		{PerDollar: 0.5},
+		{Provider: "aws", PerDollar: 0.2},
+		{Provider: "aws", Region: "us-east-1", Service: "EC2", UsageUnit: "Hrs", PerUnit: 0.04},
+		{Provider: "aws", Region: "eu-north-1", UsageUnit: "GB-Mo", PerUnit: 0.001},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name                            string
+		provider, region, service, unit string
+		usage, cost                     float64
+		want                            float64
+	}{
+		{"usage factor", "aws", "us-east-1", "EC2", "hrs", 100, 30, 4},
+		{"usage factor without usage falls back", "aws", "us-east-1", "EC2", "Hrs", 0, 30, 6},
+		{"other region uses provider factor", "aws", "us-west-2", "EC2", "Hrs", 100, 30, 6},
+		{"storage factor", "aws", "eu-north-1", "S3", "GB-Mo", 500, 10, 0.5},
+		{"catch-all", "gcp", "us-central1", "Compute Engine", "", 0, 10, 5},
+	}
+	for _, tt := range tests {
+		got, ok := table.Estimate(tt.provider, tt.region, tt.service, tt.unit, tt.usage, tt.cost)
+		if !ok || got != tt.want {
+			t.Errorf("%s: Estimate() = %v, %v, want %v", tt.name, got, ok, tt.want)
+		}
+	}
+}
+
+func TestEstimateNoFactor(t *testing.T) {
+	table, err := FromConfig(config.EmissionsConfig{Enabled: true, Factors: []config.EmissionFactor{
+		{Provider: "aws", UsageUnit: "Hrs", PerUnit: 0.04},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := table.Estimate("gcp", "", "Compute Engine", "Hrs", 10, 5); ok {
+		t.Error("estimated a provider with no factor")
+	}
+	if _, ok := table.Estimate("aws", "", "EC2", "Hrs", 0, 5); ok {
+		t.Error("usage-only factor estimated a line item without usage")
+	}
+
+	var disabled *Table
+	if _, ok := disabled.Estimate("aws", "", "EC2", "Hrs", 10, 5); ok {
+		t.Error("nil table estimated emissions")
+	}
+}
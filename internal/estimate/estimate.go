@@ -0,0 +1,241 @@
+// Package estimate checks the projected cost of an infrastructure change,
+// as priced by Infracost, against budget headroom so CI can gate it
+package estimate
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+	"github.com/lvonguyen/finops-platform/internal/calendar"
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+// Verdicts, from best to worst
+const (
+	Pass = "pass" // the change fits the budget
+	Warn = "warn" // the change takes the budget past an alert_at threshold
+	Fail = "fail" // the change takes the budget over its limit
+)
+
+// hoursPerMonth is the month Infracost prices monthly cost over
+const hoursPerMonth = 730
+
+// defaultWarnAt is the warning threshold of budgets without alert_at below
+// 100 percent
+const defaultWarnAt = 80
+
+// Estimate is the monthly cost of a change
+type Estimate struct {
+	Currency     string    `json:"currency"`
+	MonthlyCost  float64   `json:"monthly_cost"`  // after the change
+	MonthlyDelta float64   `json:"monthly_delta"` // added by the change, negative for savings
+	Projects     []Project `json:"projects"`
+}
+
+// Project is one Infracost project, such as a Terraform root module
+type Project struct {
+	Name         string  `json:"name"`
+	MonthlyCost  float64 `json:"monthly_cost"`
+	MonthlyDelta float64 `json:"monthly_delta"`
+}
+
+// infracostOutput is the part of `infracost breakdown --format json` and
+// `infracost diff --format json` read here; amounts are decimal strings
+type infracostOutput struct {
+	Currency             string  `json:"currency"`
+	TotalMonthlyCost     *string `json:"totalMonthlyCost"`
+	PastTotalMonthlyCost *string `json:"pastTotalMonthlyCost"`
+	DiffTotalMonthlyCost *string `json:"diffTotalMonthlyCost"`
+	Projects             []struct {
+		Name          string         `json:"name"`
+		Breakdown     *infracostCost `json:"breakdown"`
+		PastBreakdown *infracostCost `json:"pastBreakdown"`
+		Diff          *infracostCost `json:"diff"`
+	} `json:"projects"`
+}
+
+type infracostCost struct {
+	TotalMonthlyCost *string `json:"totalMonthlyCost"`
+}
+
+func (c *infracostCost) total() *string {
+	if c == nil {
+		return nil
+	}
+	return c.TotalMonthlyCost
+}
+
+// Load reads Infracost JSON output, from a breakdown of a Terraform plan or
+// a diff against the current state. Without a diff or past breakdown the
+// whole cost counts as new.
+func Load(path string) (*Estimate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read estimate: %w", err)
+	}
+	var out infracostOutput
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("failed to parse estimate %s: %w", path, err)
+	}
+	if out.TotalMonthlyCost == nil && len(out.Projects) == 0 {
+		return nil, fmt.Errorf("%s is not Infracost JSON output: no totalMonthlyCost or projects", path)
+	}
+
+	e := &Estimate{Currency: out.Currency}
+	if e.MonthlyCost, e.MonthlyDelta, err = costs(out.TotalMonthlyCost, out.PastTotalMonthlyCost, out.DiffTotalMonthlyCost); err != nil {
+		return nil, fmt.Errorf("failed to parse estimate %s: %w", path, err)
+	}
+	for _, p := range out.Projects {
+		cost, delta, err := costs(p.Breakdown.total(), p.PastBreakdown.total(), p.Diff.total())
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse estimate %s: project %s: %w", path, p.Name, err)
+		}
+		e.Projects = append(e.Projects, Project{Name: p.Name, MonthlyCost: cost, MonthlyDelta: delta})
+	}
+	return e, nil
+}
+
+// costs returns the monthly cost after a change and the change itself,
+// preferring an explicit diff over the difference from the past cost
+func costs(total, past, diff *string) (cost, delta float64, err error) {
+	if cost, err = amount(total); err != nil {
+		return 0, 0, err
+	}
+	if diff != nil {
+		delta, err = amount(diff)
+		return cost, delta, err
+	}
+	before, err := amount(past)
+	return cost, cost - before, err
+}
+
+// amount parses an Infracost decimal string, zero when absent
+func amount(s *string) (float64, error) {
+	if s == nil || *s == "" {
+		return 0, nil
+	}
+	v, err := strconv.ParseFloat(*s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", *s)
+	}
+	return v, nil
+}
+
+// Result is the change's effect on one budget's current period
+type Result struct {
+	Budget     string  `json:"budget"`
+	Period     string  `json:"period"`
+	Limit      float64 `json:"limit"`
+	Projected  float64 `json:"projected"`   // period-end spend without the change
+	Impact     float64 `json:"impact"`      // the change's cost over the rest of the period
+	WithChange float64 `json:"with_change"` // Projected plus Impact
+	Headroom   float64 `json:"headroom"`    // Limit minus Projected
+	WarnAt     int     `json:"warn_at"`     // percent of the limit that warns
+	Verdict    string  `json:"verdict"`
+}
+
+// Report is the outcome of checking an estimate, the worst verdict of its
+// budgets
+type Report struct {
+	Estimate *Estimate `json:"estimate"`
+	AsOf     time.Time `json:"as_of"`
+	Results  []Result  `json:"results"`
+	Verdict  string    `json:"verdict"`
+}
+
+// Check compares the estimate's monthly change, prorated over the rest of
+// each period, with the headroom of the team's budget, matched by name or
+// cost_center, and of every budget it rolls up into. Projections are by
+// budget name, as returned by Aggregator.ProjectBudgets, with periods of
+// the fiscal calendar fis. A change that does not add cost always passes.
+func Check(e *Estimate, budgets []config.Budget, team string, projections map[string]aggregator.BudgetStatus, asOf time.Time, fis *calendar.Fiscal) (*Report, error) {
+	byName := make(map[string]config.Budget, len(budgets))
+	for _, b := range budgets {
+		byName[b.Name] = b
+	}
+
+	var chain []config.Budget
+	seen := make(map[string]bool)
+	for _, b := range budgets {
+		if b.Name != team && (b.CostCenter == "" || b.CostCenter != team) {
+			continue
+		}
+		for ; !seen[b.Name]; b = byName[b.Parent] {
+			seen[b.Name] = true
+			chain = append(chain, b)
+			if b.Parent == "" {
+				break
+			}
+		}
+	}
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("no budget named %q or with that cost_center", team)
+	}
+
+	report := &Report{Estimate: e, AsOf: asOf, Verdict: Pass}
+	for _, b := range chain {
+		status, ok := projections[b.Name]
+		if !ok {
+			continue
+		}
+		start, end, _ := aggregator.BudgetPeriod(b, asOf, fis)
+		r := Result{
+			Budget:    b.Name,
+			Period:    start.Format("2006-01-02") + " to " + end.AddDate(0, 0, -1).Format("2006-01-02"),
+			Limit:     status.Limit,
+			Projected: status.ForecastSpend,
+			Headroom:  status.Limit - status.ForecastSpend,
+			WarnAt:    warnAt(b.AlertAt),
+			Verdict:   Pass,
+		}
+		if remaining := end.Sub(asOf).Hours(); remaining > 0 {
+			r.Impact = e.MonthlyDelta * remaining / hoursPerMonth
+		}
+		r.WithChange = r.Projected + r.Impact
+
+		if e.MonthlyDelta > 0 {
+			switch {
+			case r.WithChange >= r.Limit:
+				r.Verdict = Fail
+			case r.WithChange >= r.Limit*float64(r.WarnAt)/100:
+				r.Verdict = Warn
+			}
+		}
+		if rank(r.Verdict) > rank(report.Verdict) {
+			report.Verdict = r.Verdict
+		}
+		report.Results = append(report.Results, r)
+	}
+	if len(report.Results) == 0 {
+		return nil, fmt.Errorf("budget %q has no limit for the period containing %s", team, asOf.Format("2006-01-02"))
+	}
+	return report, nil
+}
+
+// warnAt returns the highest alert_at percentage below 100
+func warnAt(alertAt []int) int {
+	highest := 0
+	for _, t := range alertAt {
+		if t < 100 && t > highest {
+			highest = t
+		}
+	}
+	if highest == 0 {
+		return defaultWarnAt
+	}
+	return highest
+}
+
+func rank(verdict string) int {
+	switch verdict {
+	case Fail:
+		return 2
+	case Warn:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,136 @@
+package estimate
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+// writeEstimate writes Infracost output and returns its path
+func writeEstimate(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "infracost.json")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name, content string
+		want          Estimate
+	}{
+		{"breakdown", `{"currency": "USD", "totalMonthlyCost": "120.5", "projects": [
+			{"name": "web", "breakdown": {"totalMonthlyCost": "100"}},
+			{"name": "dns", "breakdown": {"totalMonthlyCost": "20.5"}}
+		]}`, Estimate{Currency: "USD", MonthlyCost: 120.5, MonthlyDelta: 120.5, Projects: []Project{
+			{Name: "web", MonthlyCost: 100, MonthlyDelta: 100},
+			{Name: "dns", MonthlyCost: 20.5, MonthlyDelta: 20.5},
+		}}},
+		{"diff", `{"currency": "USD", "totalMonthlyCost": "150", "pastTotalMonthlyCost": "100", "diffTotalMonthlyCost": "40", "projects": [
+			{"name": "web", "breakdown": {"totalMonthlyCost": "150"}, "pastBreakdown": {"totalMonthlyCost": "100"}, "diff": {"totalMonthlyCost": "40"}}
+		]}`, Estimate{Currency: "USD", MonthlyCost: 150, MonthlyDelta: 40, Projects: []Project{
+			{Name: "web", MonthlyCost: 150, MonthlyDelta: 40},
+		}}},
+		{"past only", `{"currency": "EUR", "totalMonthlyCost": "80", "pastTotalMonthlyCost": "100"}`,
+			Estimate{Currency: "EUR", MonthlyCost: 80, MonthlyDelta: -20}},
+	}
+	for _, tt := range tests {
+		got, err := Load(writeEstimate(t, tt.content))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(*got, tt.want) {
+			t.Errorf("%s: Load() = %+v, want %+v", tt.name, *got, tt.want)
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	for name, content := range map[string]string{
+		"not JSON":      "totalMonthlyCost: 1",
+		"not infracost": `{"resources": []}`,
+		"bad total":     `{"totalMonthlyCost": "lots"}`,
+		"bad project":   `{"projects": [{"name": "web", "diff": {"totalMonthlyCost": "1,000"}}]}`,
+	} {
+		if _, err := Load(writeEstimate(t, content)); err == nil {
+			t.Errorf("%s: Load() succeeded, want an error", name)
+		}
+	}
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("missing file: Load() succeeded, want an error")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	// 16 days of March left, 384 of the 730 hours Infracost prices a month over
+	asOf := time.Date(2024, 3, 16, 0, 0, 0, 0, time.UTC)
+	budgets := []config.Budget{
+		{Name: "org", MonthlyLimit: 2000},
+		{Name: "web", CostCenter: "CC-1", Parent: "org", MonthlyLimit: 1000, AlertAt: []int{50, 90, 100}},
+		{Name: "data", Parent: "org", MonthlyLimit: 5000},
+	}
+	projections := map[string]aggregator.BudgetStatus{
+		"org":  {BudgetName: "org", Limit: 2000, ForecastSpend: 1000},
+		"web":  {BudgetName: "web", Limit: 1000, ForecastSpend: 500},
+		"data": {BudgetName: "data", Limit: 5000, ForecastSpend: 100},
+	}
+	tests := []struct {
+		name    string
+		team    string
+		delta   float64
+		verdict string
+		results []string // budget/verdict
+	}{
+		{"fits", "web", 730, Pass, []string{"web/pass", "org/pass"}},
+		{"by cost center", "CC-1", 730, Pass, []string{"web/pass", "org/pass"}},
+		{"past alert_at", "web", 803, Warn, []string{"web/warn", "org/pass"}},
+		{"over the parent", "data", 2000, Fail, []string{"data/pass", "org/fail"}},
+		{"over", "web", 1000, Fail, []string{"web/fail", "org/pass"}},
+		{"savings", "web", -5000, Pass, []string{"web/pass", "org/pass"}},
+	}
+	for _, tt := range tests {
+		report, err := Check(&Estimate{MonthlyDelta: tt.delta}, budgets, tt.team, projections, asOf, nil)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		var results []string
+		for _, r := range report.Results {
+			results = append(results, r.Budget+"/"+r.Verdict)
+		}
+		if report.Verdict != tt.verdict || !reflect.DeepEqual(results, tt.results) {
+			t.Errorf("%s: verdict %s %v, want %s %v", tt.name, report.Verdict, results, tt.verdict, tt.results)
+		}
+	}
+
+	report, err := Check(&Estimate{MonthlyDelta: 730}, budgets, "web", projections, asOf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Result{Budget: "web", Period: "2024-03-01 to 2024-03-31", Limit: 1000, Projected: 500, Impact: 384, WithChange: 884, Headroom: 500, WarnAt: 90, Verdict: Pass}
+	if got := report.Results[0]; got != want {
+		t.Errorf("result = %+v, want %+v", got, want)
+	}
+	if w := report.Results[1].WarnAt; w != defaultWarnAt {
+		t.Errorf("org warns at %d%%, want the default %d%% without alert_at", w, defaultWarnAt)
+	}
+}
+
+func TestCheckErrors(t *testing.T) {
+	asOf := time.Date(2024, 3, 16, 0, 0, 0, 0, time.UTC)
+	budgets := []config.Budget{{Name: "web", MonthlyLimit: 1000}}
+	if _, err := Check(&Estimate{MonthlyDelta: 10}, budgets, "data", nil, asOf, nil); err == nil {
+		t.Error("unknown team: Check() succeeded, want an error")
+	}
+	if _, err := Check(&Estimate{MonthlyDelta: 10}, budgets, "web", map[string]aggregator.BudgetStatus{}, asOf, nil); err == nil {
+		t.Error("no projection: Check() succeeded, want an error")
+	}
+}
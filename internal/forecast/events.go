@@ -0,0 +1,126 @@
+package forecast
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+// Event is a known scheduled change to spend, such as a migration or a
+// planned shutdown
+type Event struct {
+	Name       string
+	Start      time.Time
+	End        time.Time // exclusive; zero runs to the end of the horizon
+	Delta      float64   // change in daily spend, e.g. -5000
+	Multiplier float64   // scales the scoped base forecast, e.g. 0.5; 0 for none
+	Cloud      string    // scope; empty fields match everything
+	Account    string
+	Service    string
+}
+
+// EventImpact is the change an event made to the forecast
+type EventImpact struct {
+	Name   string    `json:"name"`
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	Impact float64   `json:"impact"` // total change over the horizon
+}
+
+// EventsFromConfig parses the configured scheduled events
+func EventsFromConfig(cfgs []config.ForecastEvent) ([]Event, error) {
+	events := make([]Event, 0, len(cfgs))
+	for _, c := range cfgs {
+		e := Event{
+			Name:       c.Name,
+			Delta:      c.Delta,
+			Multiplier: c.Multiplier,
+			Cloud:      c.Provider,
+			Account:    c.Account,
+			Service:    c.Service,
+		}
+
+		var err error
+		if e.Start, err = time.Parse("2006-01-02", c.Date); err != nil {
+			return nil, fmt.Errorf("event %q: invalid date %q", c.Name, c.Date)
+		}
+		if c.EndDate != "" {
+			if e.End, err = time.Parse("2006-01-02", c.EndDate); err != nil {
+				return nil, fmt.Errorf("event %q: invalid end_date %q", c.Name, c.EndDate)
+			}
+			if !e.End.After(e.Start) {
+				return nil, fmt.Errorf("event %q: end_date must be after date", c.Name)
+			}
+		}
+		if e.Delta == 0 && e.Multiplier == 0 {
+			return nil, fmt.Errorf("event %q: set delta or multiplier", c.Name)
+		}
+		if e.Delta != 0 && e.Multiplier != 0 {
+			return nil, fmt.Errorf("event %q: set only one of delta or multiplier", c.Name)
+		}
+		if e.Multiplier < 0 {
+			return nil, fmt.Errorf("event %q: multiplier must not be negative", c.Name)
+		}
+
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+// Apply adds scheduled events to the adjusted projection, leaving the base
+// projection untouched. Every event must start within the horizon.
+func (f *Forecast) Apply(events []Event) error {
+	for _, e := range events {
+		if e.Start.Before(f.Start) || !e.Start.Before(f.End) {
+			return fmt.Errorf("event %q on %s is outside the forecast horizon %s to %s",
+				e.Name, e.Start.Format("2006-01-02"), f.Start.Format("2006-01-02"), f.End.Format("2006-01-02"))
+		}
+	}
+
+	for _, e := range events {
+		impact := EventImpact{Name: e.Name, Start: e.Start, End: e.End}
+
+		for i := range f.Points {
+			day := f.Points[i].Date
+			if day.Before(e.Start) || (!e.End.IsZero() && !day.Before(e.End)) {
+				continue
+			}
+
+			change := e.Delta
+			if e.Multiplier != 0 {
+				change = (e.Multiplier - 1) * f.scopedBase(e, i)
+			}
+			// Spend cannot be cut below zero
+			if f.Points[i].Adjusted+change < 0 {
+				change = -f.Points[i].Adjusted
+			}
+
+			f.Points[i].Adjusted += change
+			impact.Impact += change
+		}
+
+		f.AdjustedTotal += impact.Impact
+		f.Events = append(f.Events, impact)
+	}
+
+	return nil
+}
+
+// scopedBase sums the base projection of the series an event applies to
+func (f *Forecast) scopedBase(e Event, day int) float64 {
+	var total float64
+	for k, values := range f.series {
+		if e.Cloud != "" && e.Cloud != k.cloud {
+			continue
+		}
+		if e.Account != "" && e.Account != k.account {
+			continue
+		}
+		if e.Service != "" && e.Service != k.service {
+			continue
+		}
+		total += values[day]
+	}
+	return total
+}
@@ -0,0 +1,84 @@
+package forecast
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+var start = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+// flat returns days of constant daily spend on a service before start
+func flat(service string, days int, cost float64) []normalizer.CostRecord {
+	records := make([]normalizer.CostRecord, days)
+	for i := range records {
+		records[i] = normalizer.CostRecord{Cloud: "aws", Account: "111", Service: service, Date: start.AddDate(0, 0, -days+i), Cost: cost}
+	}
+	return records
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func TestApplyEvents(t *testing.T) {
+	f := Linear(append(flat("EC2", 30, 100), flat("S3", 30, 50)...), start, start.AddDate(0, 0, 10))
+	if !near(f.BaseTotal, 1500) {
+		t.Fatalf("BaseTotal = %v, want 1500", f.BaseTotal)
+	}
+
+	events := []Event{
+		{Name: "migration", Start: start.AddDate(0, 0, 3), End: start.AddDate(0, 0, 6), Delta: -30},
+		{Name: "s3 lifecycle", Start: start.AddDate(0, 0, 5), Multiplier: 0.5, Service: "S3"},
+		{Name: "shutdown", Start: start.AddDate(0, 0, 9), Delta: -1000},
+	}
+	if err := f.Apply(events); err != nil {
+		t.Fatal(err)
+	}
+
+	// The shutdown is floored at the day's remaining 125
+	want := map[string]float64{"migration": -90, "s3 lifecycle": -125, "shutdown": -125}
+	for _, e := range f.Events {
+		if !near(e.Impact, want[e.Name]) {
+			t.Errorf("%s impact = %v, want %v", e.Name, e.Impact, want[e.Name])
+		}
+	}
+	if !near(f.BaseTotal, 1500) || !near(f.AdjustedTotal, 1160) {
+		t.Errorf("totals = %v base, %v adjusted; want 1500 and 1160", f.BaseTotal, f.AdjustedTotal)
+	}
+	if p := f.Points[9]; !near(p.Base, 150) || p.Adjusted != 0 {
+		t.Errorf("last day = %+v, want base 150 adjusted to 0", p)
+	}
+
+	late := Event{Name: "too late", Start: start.AddDate(0, 0, 10), Delta: 1}
+	if err := f.Apply([]Event{late}); err == nil || !strings.Contains(err.Error(), "outside the forecast horizon") {
+		t.Errorf("err = %v, want an outside the horizon error", err)
+	}
+}
+
+func TestEventsFromConfig(t *testing.T) {
+	events, err := EventsFromConfig([]config.ForecastEvent{{Name: "cut", Date: "2024-03-05", EndDate: "2024-03-08", Delta: -10, Provider: "aws"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := events[0]; !e.Start.Equal(start.AddDate(0, 0, 4)) || !e.End.Equal(start.AddDate(0, 0, 7)) || e.Cloud != "aws" {
+		t.Errorf("event = %+v", e)
+	}
+
+	bad := []config.ForecastEvent{
+		{Name: "no date", Delta: 1},
+		{Name: "backwards", Date: "2024-03-05", EndDate: "2024-03-05", Delta: 1},
+		{Name: "no change", Date: "2024-03-05"},
+		{Name: "both", Date: "2024-03-05", Delta: 1, Multiplier: 2},
+		{Name: "negative", Date: "2024-03-05", Multiplier: -1},
+	}
+	for _, c := range bad {
+		if _, err := EventsFromConfig([]config.ForecastEvent{c}); err == nil {
+			t.Errorf("event %q accepted", c.Name)
+		}
+	}
+}
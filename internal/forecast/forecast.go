@@ -0,0 +1,444 @@
+// Package forecast projects future cloud spend from daily cost history
+package forecast
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/calendar"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+// Point is the projected spend for one day
+type Point struct {
+	Date     time.Time `json:"date"`
+	Base     float64   `json:"base"`     // projection from history alone
+	Adjusted float64   `json:"adjusted"` // projection including scheduled events
+}
+
+// Forecasting methods
+const (
+	MethodLinear      = "linear"       // least-squares trend
+	MethodHoltWinters = "holt-winters" // additive Holt-Winters with a weekly season
+)
+
+// Projection dimensions
+const (
+	DimensionProvider   = "provider"
+	DimensionService    = "service"
+	DimensionCostCenter = "cost_center"
+)
+
+// Forecast is a daily spend projection over [Start, End)
+type Forecast struct {
+	Method        string        `json:"method"`
+	Start         time.Time     `json:"start"`
+	End           time.Time     `json:"end"`
+	Points        []Point       `json:"points"`
+	BaseTotal     float64       `json:"base_total"`
+	AdjustedTotal float64       `json:"adjusted_total"`
+	Events        []EventImpact `json:"events"`
+	Projections   []Projection  `json:"projections"`
+
+	// Daily base projection per series from Start, through the end of
+	// Start's quarter
+	series map[seriesKey][]float64
+}
+
+// Projection is the expected total of the month or quarter containing the
+// forecast start: spend so far plus the base forecast for the remaining
+// days. Scheduled events are not included.
+type Projection struct {
+	Period    string    `json:"period"`     // month or quarter
+	PeriodEnd time.Time `json:"period_end"` // exclusive
+	Dimension string    `json:"dimension"`  // provider, service or cost_center
+	Key       string    `json:"key"`
+	Actual    float64   `json:"actual"`   // spend from the period start to the forecast start
+	Forecast  float64   `json:"forecast"` // projected spend for the rest of the period
+	Total     float64   `json:"total"`
+}
+
+// Options configures a forecast
+type Options struct {
+	Method   string    // linear (default) or holt-winters
+	FitStart time.Time // fit models to history from this day; earlier records only count as period actuals
+
+	// Fiscal sets the month and quarter projected; nil for calendar months
+	Fiscal *calendar.Fiscal
+
+	// CostCenter names a record's cost center, for cost center projections;
+	// nil for none
+	CostCenter func(normalizer.CostRecord) string
+}
+
+// seriesKey identifies one cloud/account/service/cost center cost series
+type seriesKey struct {
+	cloud, account, service, center string
+}
+
+// Linear projects each cloud/account/service series forward with a
+// least-squares trend over its daily history and sums the series. The
+// horizon is [start, end).
+func Linear(history []normalizer.CostRecord, start, end time.Time) *Forecast {
+	f, _ := New(history, start, end, Options{Method: MethodLinear})
+	return f
+}
+
+// New projects each cloud/account/service series forward with the chosen
+// method and sums the series over [start, end). It also projects the month
+// and quarter containing start by provider, service and, when opts has a
+// cost center function, cost center.
+func New(history []normalizer.CostRecord, start, end time.Time, opts Options) (*Forecast, error) {
+	method := opts.Method
+	if method == "" {
+		method = MethodLinear
+	}
+	var model func(map[time.Time]float64, time.Time, int) []float64
+	switch method {
+	case MethodLinear:
+		model = project
+	case MethodHoltWinters:
+		model = holtWinters
+	default:
+		return nil, fmt.Errorf("unknown forecast method %q", opts.Method)
+	}
+
+	days := int(end.Sub(start).Hours() / 24)
+	if days < 0 {
+		days = 0
+	}
+	// Series run at least to the end of the quarter for the projections
+	monthStart, monthEnd := opts.Fiscal.Month(opts.Fiscal.MonthOf(start))
+	quarterStart, quarterEnd := opts.Fiscal.Quarter(start)
+	seriesDays := days
+	if q := int(quarterEnd.Sub(start).Hours() / 24); q > seriesDays {
+		seriesDays = q
+	}
+
+	centerOf := func(r normalizer.CostRecord) string {
+		if opts.CostCenter == nil {
+			return ""
+		}
+		return opts.CostCenter(r)
+	}
+
+	// Daily totals per series
+	daily := make(map[seriesKey]map[time.Time]float64)
+	for _, r := range history {
+		if r.Date.Before(opts.FitStart) || !r.Date.Before(start) {
+			continue
+		}
+		k := seriesKey{r.Cloud, r.Account, r.Service, centerOf(r)}
+		if daily[k] == nil {
+			daily[k] = make(map[time.Time]float64)
+		}
+		daily[k][truncate(r.Date)] += r.Cost
+	}
+
+	f := &Forecast{
+		Method: method,
+		Start:  start,
+		End:    end,
+		Points: make([]Point, days),
+		series: make(map[seriesKey][]float64, len(daily)),
+	}
+	for i := range f.Points {
+		f.Points[i].Date = start.AddDate(0, 0, i)
+	}
+
+	for k, byDate := range daily {
+		projected := model(byDate, start, seriesDays)
+		f.series[k] = projected
+		for i := 0; i < days; i++ {
+			f.Points[i].Base += projected[i]
+		}
+	}
+
+	for i := range f.Points {
+		f.Points[i].Adjusted = f.Points[i].Base
+		f.BaseTotal += f.Points[i].Base
+	}
+	f.AdjustedTotal = f.BaseTotal
+
+	f.Projections = append(f.periodProjections("month", monthStart, monthEnd, history, centerOf, opts.CostCenter != nil),
+		f.periodProjections("quarter", quarterStart, quarterEnd, history, centerOf, opts.CostCenter != nil)...)
+	return f, nil
+}
+
+// periodProjections sums actual spend since the period start and the base
+// forecast to periodEnd by each dimension
+func (f *Forecast) periodProjections(period string, periodStart, periodEnd time.Time, history []normalizer.CostRecord, centerOf func(normalizer.CostRecord) string, centers bool) []Projection {
+	dims := []string{DimensionProvider, DimensionService}
+	if centers {
+		dims = append(dims, DimensionCostCenter)
+	}
+	keyOf := func(dim string, k seriesKey) string {
+		switch dim {
+		case DimensionProvider:
+			return k.cloud
+		case DimensionService:
+			return k.service
+		}
+		return k.center
+	}
+
+	byKey := make(map[[2]string]*Projection)
+	get := func(dim, key string) *Projection {
+		p, ok := byKey[[2]string{dim, key}]
+		if !ok {
+			p = &Projection{Period: period, PeriodEnd: periodEnd, Dimension: dim, Key: key}
+			byKey[[2]string{dim, key}] = p
+		}
+		return p
+	}
+
+	for _, r := range history {
+		if r.Date.Before(periodStart) || !r.Date.Before(f.Start) {
+			continue
+		}
+		k := seriesKey{r.Cloud, r.Account, r.Service, centerOf(r)}
+		for _, dim := range dims {
+			get(dim, keyOf(dim, k)).Actual += r.Cost
+		}
+	}
+
+	days := int(periodEnd.Sub(f.Start).Hours() / 24)
+	for k, values := range f.series {
+		var total float64
+		for i := 0; i < days && i < len(values); i++ {
+			total += values[i]
+		}
+		for _, dim := range dims {
+			get(dim, keyOf(dim, k)).Forecast += total
+		}
+	}
+
+	projections := make([]Projection, 0, len(byKey))
+	for _, p := range byKey {
+		p.Total = p.Actual + p.Forecast
+		projections = append(projections, *p)
+	}
+	order := map[string]int{DimensionProvider: 0, DimensionService: 1, DimensionCostCenter: 2}
+	sort.Slice(projections, func(i, j int) bool {
+		a, b := projections[i], projections[j]
+		if a.Dimension != b.Dimension {
+			return order[a.Dimension] < order[b.Dimension]
+		}
+		if a.Total != b.Total {
+			return a.Total > b.Total
+		}
+		return a.Key < b.Key
+	})
+	return projections
+}
+
+// project fits cost = a + b*day over a series' history and extends it for
+// days from start, never projecting negative spend
+func project(byDate map[time.Time]float64, start time.Time, days int) []float64 {
+	dates := make([]time.Time, 0, len(byDate))
+	for d := range byDate {
+		dates = append(dates, d)
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+
+	origin := dates[0]
+	var n, sumX, sumY, sumXY, sumXX float64
+	for _, d := range dates {
+		x := d.Sub(origin).Hours() / 24
+		y := byDate[d]
+		n++
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+
+	intercept, slope := sumY/n, 0.0
+	if denom := n*sumXX - sumX*sumX; n >= 2 && denom != 0 {
+		slope = (n*sumXY - sumX*sumY) / denom
+		intercept = (sumY - slope*sumX) / n
+	}
+
+	projected := make([]float64, days)
+	for i := range projected {
+		x := start.AddDate(0, 0, i).Sub(origin).Hours() / 24
+		if v := intercept + slope*x; v > 0 {
+			projected[i] = v
+		}
+	}
+	return projected
+}
+
+// seasonLength is the weekly cycle of daily spend
+const seasonLength = 7
+
+// damping flattens the Holt-Winters trend over long horizons
+const damping = 0.98
+
+// holtWinters fits additive Holt-Winters with a weekly season and damped
+// trend to a series' daily history, picking the smoothing parameters with
+// the least one-step-ahead error, and extends it for days from start. Days
+// without records count as zero spend; series shorter than two seasons fall
+// back to the linear trend.
+func holtWinters(byDate map[time.Time]float64, start time.Time, days int) []float64 {
+	origin := start
+	for d := range byDate {
+		if d.Before(origin) {
+			origin = d
+		}
+	}
+	n := int(start.Sub(origin).Hours() / 24)
+	if n < 2*seasonLength {
+		return project(byDate, start, days)
+	}
+
+	y := make([]float64, n)
+	for d, v := range byDate {
+		y[int(d.Sub(origin).Hours()/24)] += v
+	}
+
+	bestSSE := math.Inf(1)
+	var alpha, beta, gamma float64
+	for _, a := range []float64{0.1, 0.2, 0.3, 0.5, 0.7, 0.9} {
+		for _, b := range []float64{0.01, 0.05, 0.1, 0.2} {
+			for _, g := range []float64{0.05, 0.1, 0.3, 0.5, 0.7} {
+				if sse, _ := fitHoltWinters(y, a, b, g, 0); sse < bestSSE {
+					bestSSE, alpha, beta, gamma = sse, a, b, g
+				}
+			}
+		}
+	}
+
+	_, projected := fitHoltWinters(y, alpha, beta, gamma, days)
+	for i, v := range projected {
+		if v < 0 {
+			projected[i] = 0
+		}
+	}
+	return projected
+}
+
+// fitHoltWinters runs the smoothing over y, returning the sum of squared
+// one-step-ahead errors and a forecast of horizon days past the end of y
+func fitHoltWinters(y []float64, alpha, beta, gamma float64, horizon int) (float64, []float64) {
+	m := seasonLength
+	var first, second float64
+	for i := 0; i < m; i++ {
+		first += y[i]
+		second += y[m+i]
+	}
+	first /= float64(m)
+	second /= float64(m)
+
+	level, trend := first, (second-first)/float64(m)
+	season := make([]float64, m)
+	for i := range season {
+		season[i] = y[i] - first
+	}
+
+	var sse float64
+	for t := m; t < len(y); t++ {
+		s := season[t%m]
+		e := y[t] - (level + damping*trend + s)
+		sse += e * e
+
+		prev := level
+		level = alpha*(y[t]-s) + (1-alpha)*(level+damping*trend)
+		trend = beta*(level-prev) + (1-beta)*damping*trend
+		season[t%m] = gamma*(y[t]-level) + (1-gamma)*s
+	}
+
+	forecast := make([]float64, horizon)
+	damped, phi := 0.0, 1.0
+	for h := range forecast {
+		phi *= damping
+		damped += phi
+		forecast[h] = level + damped*trend + season[(len(y)+h)%m]
+	}
+	return sse, forecast
+}
+
+// SaveCSV writes the daily base and adjusted projections as a CSV file
+func (f *Forecast) SaveCSV(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	if err := writer.Write([]string{"Date", "Base Forecast", "Adjusted Forecast", "Difference"}); err != nil {
+		return err
+	}
+
+	for _, p := range f.Points {
+		row := []string{
+			p.Date.Format("2006-01-02"),
+			fmt.Sprintf("%.2f", p.Base),
+			fmt.Sprintf("%.2f", p.Adjusted),
+			fmt.Sprintf("%.2f", p.Adjusted-p.Base),
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	return writer.Write([]string{
+		"TOTAL",
+		fmt.Sprintf("%.2f", f.BaseTotal),
+		fmt.Sprintf("%.2f", f.AdjustedTotal),
+		fmt.Sprintf("%.2f", f.AdjustedTotal-f.BaseTotal),
+	})
+}
+
+// SaveProjectionsCSV writes the end-of-month and end-of-quarter projections
+// as a CSV file
+func (f *Forecast) SaveProjectionsCSV(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	if err := writer.Write([]string{"Period", "Period End", "Dimension", "Key", "Actual", "Forecast", "Projected Total"}); err != nil {
+		return err
+	}
+	for _, p := range f.Projections {
+		row := []string{
+			p.Period,
+			p.PeriodEnd.AddDate(0, 0, -1).Format("2006-01-02"),
+			p.Dimension,
+			p.Key,
+			fmt.Sprintf("%.2f", p.Actual),
+			fmt.Sprintf("%.2f", p.Forecast),
+			fmt.Sprintf("%.2f", p.Total),
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SaveJSON writes the forecast, with its projections, as JSON
+func (f *Forecast) SaveJSON(path string) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode forecast: %w", err)
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func truncate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,179 @@
+package forecast
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/calendar"
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+func TestLinearTrend(t *testing.T) {
+	// Spend grows by 2 a day: 10, 12, ... 68 over the 30 days before start
+	history := flat("EC2", 30, 0)
+	for i := range history {
+		history[i].Cost = 10 + 2*float64(i)
+	}
+	f := Linear(history, start, start.AddDate(0, 0, 3))
+	for i, want := range []float64{70, 72, 74} {
+		if !near(f.Points[i].Base, want) || f.Points[i].Adjusted != f.Points[i].Base {
+			t.Errorf("day %d = %+v, want %v", i, f.Points[i], want)
+		}
+	}
+	if !near(f.BaseTotal, 216) || f.Method != MethodLinear {
+		t.Errorf("BaseTotal = %v by %s, want 216 by linear", f.BaseTotal, f.Method)
+	}
+
+	// A falling trend never projects negative spend
+	for i := range history {
+		history[i].Cost = 60 - 2*float64(i)
+	}
+	f = Linear(history, start, start.AddDate(0, 0, 5))
+	for _, p := range f.Points {
+		if p.Base < 0 {
+			t.Errorf("%s projected %v", p.Date.Format("2006-01-02"), p.Base)
+		}
+	}
+}
+
+func TestHoltWintersWeeklySeason(t *testing.T) {
+	// Eight weeks of 100 on weekdays and 20 at weekends
+	var history []normalizer.CostRecord
+	for d := start.AddDate(0, 0, -56); d.Before(start); d = d.AddDate(0, 0, 1) {
+		cost := 100.0
+		if d.Weekday() == time.Saturday || d.Weekday() == time.Sunday {
+			cost = 20
+		}
+		history = append(history, normalizer.CostRecord{Cloud: "aws", Account: "111", Service: "EC2", Date: d, Cost: cost})
+	}
+
+	f, err := New(history, start, start.AddDate(0, 0, 14), Options{Method: MethodHoltWinters})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range f.Points {
+		want := 100.0
+		if p.Date.Weekday() == time.Saturday || p.Date.Weekday() == time.Sunday {
+			want = 20
+		}
+		if math.Abs(p.Base-want) > 1 {
+			t.Errorf("%s %s = %.2f, want about %v", p.Date.Format("2006-01-02"), p.Date.Weekday(), p.Base, want)
+		}
+	}
+
+	// The linear trend smooths the weekend dip away
+	linear := Linear(history, start, start.AddDate(0, 0, 14))
+	if weekend := linear.Points[1]; weekend.Date.Weekday() != time.Saturday || weekend.Base < 50 {
+		t.Errorf("linear Saturday = %+v, want the weekly mean", weekend)
+	}
+}
+
+func TestHoltWintersShortHistory(t *testing.T) {
+	// Under two weeks of history falls back to the linear trend
+	history := flat("EC2", 10, 40)
+	f, err := New(history, start, start.AddDate(0, 0, 7), Options{Method: MethodHoltWinters})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !near(f.BaseTotal, 280) {
+		t.Errorf("BaseTotal = %v, want 280", f.BaseTotal)
+	}
+
+	if _, err := New(history, start, start.AddDate(0, 0, 7), Options{Method: "arima"}); err == nil {
+		t.Error("unknown method accepted")
+	}
+}
+
+func TestProjections(t *testing.T) {
+	mid := time.Date(2024, 3, 16, 0, 0, 0, 0, time.UTC)
+	var history []normalizer.CostRecord
+	for d := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC); d.Before(mid); d = d.AddDate(0, 0, 1) {
+		history = append(history,
+			normalizer.CostRecord{Cloud: "aws", Account: "111", Service: "EC2", Date: d, Cost: 10, Tags: map[string]string{"cost_center": "web"}},
+			normalizer.CostRecord{Cloud: "gcp", Account: "proj", Service: "BigQuery", Date: d, Cost: 5, Tags: map[string]string{"cost_center": "data"}},
+		)
+	}
+
+	// Fitting to March only still counts January and February as actuals
+	f, err := New(history, mid, mid.AddDate(0, 0, 7), Options{
+		FitStart:   time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		CostCenter: func(r normalizer.CostRecord) string { return r.Tags["cost_center"] },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, p := range f.Projections {
+		got = append(got, fmt.Sprintf("%s/%s/%s/%s %.0f+%.0f+%.0f",
+			p.Period, p.Dimension, p.Key, p.PeriodEnd.Format("01-02"), p.Actual, p.Forecast, p.Total))
+	}
+	// March 1-15 are actuals and March 16-31 forecast; the quarter adds
+	// January and February, 60 days
+	want := []string{
+		"month/provider/aws/04-01 150+160+310",
+		"month/provider/gcp/04-01 75+80+155",
+		"month/service/EC2/04-01 150+160+310",
+		"month/service/BigQuery/04-01 75+80+155",
+		"month/cost_center/web/04-01 150+160+310",
+		"month/cost_center/data/04-01 75+80+155",
+		"quarter/provider/aws/04-01 750+160+910",
+		"quarter/provider/gcp/04-01 375+80+455",
+		"quarter/service/EC2/04-01 750+160+910",
+		"quarter/service/BigQuery/04-01 375+80+455",
+		"quarter/cost_center/web/04-01 750+160+910",
+		"quarter/cost_center/data/04-01 375+80+455",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	path := filepath.Join(t.TempDir(), "projections.csv")
+	if err := f.SaveProjectionsCSV(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(string(data), "\n")
+	if lines[0] != "Period,Period End,Dimension,Key,Actual,Forecast,Projected Total" ||
+		lines[1] != "month,2024-03-31,provider,aws,150.00,160.00,310.00" {
+		t.Errorf("CSV starts\n%s\n%s", lines[0], lines[1])
+	}
+}
+
+func TestFiscalProjections(t *testing.T) {
+	// With 4-4-5 periods fiscal March runs from Feb 26 to Mar 31
+	fis, err := calendar.FiscalFromConfig(config.CalendarConfig{Periods: "4-4-5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mid := time.Date(2024, 3, 16, 0, 0, 0, 0, time.UTC)
+	var history []normalizer.CostRecord
+	for d := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC); d.Before(mid); d = d.AddDate(0, 0, 1) {
+		history = append(history, normalizer.CostRecord{Cloud: "aws", Account: "111", Service: "EC2", Date: d, Cost: 10})
+	}
+	f, err := New(history, mid, mid.AddDate(0, 0, 7), Options{Fiscal: fis})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, p := range f.Projections {
+		if p.Dimension == DimensionProvider {
+			got = append(got, fmt.Sprintf("%s %s %.0f+%.0f+%.0f", p.Period, p.PeriodEnd.Format("01-02"), p.Actual, p.Forecast, p.Total))
+		}
+	}
+	// 19 days of actuals in the month and 75 in the quarter
+	want := []string{"month 04-01 190+160+350", "quarter 04-01 750+160+910"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,391 @@
+// Package grafana implements the Grafana JSON datasource (simple-json) API
+// over the cost history, so Grafana can chart daily cost and overlay
+// anomalies as annotations
+package grafana
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/anomaly"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+	"github.com/lvonguyen/finops-platform/internal/query"
+	"github.com/lvonguyen/finops-platform/internal/store"
+)
+
+// Total is the target charting all spend
+const Total = "total"
+
+// searchDays is how many days of history /search lists dimension values from
+const searchDays = 30
+
+const tagPrefix = "tag:"
+
+// wherePrefix starts a target charting the costs matching a filter expression
+const wherePrefix = "where:"
+
+// dimensions a target can select by, besides tag:<key>
+var dimensions = []string{anomaly.DimCloud, anomaly.DimAccount, anomaly.DimService, anomaly.DimRegion}
+
+// timeRange is the dashboard time range of a request
+type timeRange struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+type queryRequest struct {
+	Range   timeRange `json:"range"`
+	Targets []struct {
+		Target string `json:"target"`
+		Type   string `json:"type"` // timeserie (default) or table
+	} `json:"targets"`
+}
+
+type searchRequest struct {
+	Target string `json:"target"`
+}
+
+type annotationRequest struct {
+	Range      timeRange       `json:"range"`
+	Annotation json.RawMessage `json:"annotation"`
+}
+
+// series is a target's daily cost as [value, unix ms] pairs
+type series struct {
+	Target     string       `json:"target"`
+	Datapoints [][2]float64 `json:"datapoints"`
+}
+
+type table struct {
+	Type    string          `json:"type"`
+	Columns []tableColumn   `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+}
+
+type tableColumn struct {
+	Text string `json:"text"`
+	Type string `json:"type"`
+}
+
+type annotation struct {
+	Annotation json.RawMessage `json:"annotation,omitempty"`
+	Time       int64           `json:"time"`
+	Title      string          `json:"title"`
+	Text       string          `json:"text"`
+	Tags       []string        `json:"tags"`
+}
+
+// Handler returns the datasource API to mount under a prefix, e.g.
+// /grafana/ with the datasource URL http://host:8090/grafana. Targets are
+// total, <dimension>=<value> or <dimension>=* for one series per value, where
+// the dimension is cloud, account, service, region or tag:<key>, or
+// where:<expression> for the costs matching a filter expression (see package
+// query), e.g. where:cloud = "aws" AND tag.env != "prod". Annotations
+// are the tracked anomalies, optionally limited to the statuses listed in the
+// annotation query (e.g. "open,acknowledged"); suppressed ones are left out
+// by default.
+func Handler(history store.CostStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimSuffix(r.URL.Path, "/")
+		if path == "" {
+			// Grafana's connection test
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		body := io.LimitReader(r.Body, 1<<16)
+
+		var result interface{}
+		var err error
+		switch path {
+		case "/search":
+			var req searchRequest
+			if err := json.NewDecoder(body).Decode(&req); err != nil && err != io.EOF {
+				http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			result, err = search(r, history, req)
+		case "/query":
+			var req queryRequest
+			if err := json.NewDecoder(body).Decode(&req); err != nil {
+				http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			if req.Range.From.IsZero() || req.Range.To.IsZero() {
+				http.Error(w, "query needs a range", http.StatusBadRequest)
+				return
+			}
+			for _, t := range req.Targets {
+				if _, _, err := parseTarget(t.Target); err != nil && t.Target != "" {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+			}
+			result, err = queryTargets(r, history, req)
+		case "/annotations":
+			var req annotationRequest
+			if err := json.NewDecoder(body).Decode(&req); err != nil {
+				http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			result, err = annotations(r, history, req)
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	})
+}
+
+// search lists the targets containing the search text: total, each
+// dimension's wildcard and the values seen in recent history
+func search(r *http.Request, history store.CostStore, req searchRequest) ([]string, error) {
+	latest, err := history.LatestIngestDate(r.Context())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	var records []normalizer.CostRecord
+	if !latest.IsZero() {
+		end := day(latest).AddDate(0, 0, 1)
+		if records, err = history.QueryRange(r.Context(), end.AddDate(0, 0, -searchDays), end); err != nil {
+			return nil, fmt.Errorf("failed to query history: %w", err)
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, dim := range dimensions {
+		seen[dim+"=*"] = true
+	}
+	for _, rec := range records {
+		for _, dim := range dimensions {
+			if v, _ := value(rec, dim); v != "" {
+				seen[dim+"="+v] = true
+			}
+		}
+		for k, v := range rec.Tags {
+			seen[tagPrefix+k+"=*"] = true
+			if v != "" {
+				seen[tagPrefix+k+"="+v] = true
+			}
+		}
+	}
+
+	targets := []string{Total}
+	for t := range seen {
+		targets = append(targets, t)
+	}
+	sort.Strings(targets[1:])
+
+	text := strings.ToLower(req.Target)
+	matched := make([]string, 0, len(targets))
+	for _, t := range targets {
+		if strings.Contains(strings.ToLower(t), text) {
+			matched = append(matched, t)
+		}
+	}
+	return matched, nil
+}
+
+// queryTargets returns the daily cost of each target over the requested range
+func queryTargets(r *http.Request, history store.CostStore, req queryRequest) ([]interface{}, error) {
+	start, end := day(req.Range.From), day(req.Range.To).AddDate(0, 0, 1)
+	records, err := history.QueryRange(r.Context(), start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query history: %w", err)
+	}
+
+	result := make([]interface{}, 0, len(req.Targets))
+	for _, t := range req.Targets {
+		if t.Target == "" {
+			continue
+		}
+		all, err := targetSeries(t.Target, records, start, end)
+		if err != nil {
+			return nil, err
+		}
+		if t.Type != "table" {
+			for _, s := range all {
+				result = append(result, s)
+			}
+			continue
+		}
+		tbl := table{
+			Type:    "table",
+			Columns: []tableColumn{{"Time", "time"}, {"Target", "string"}, {"Cost", "number"}},
+			Rows:    make([][]interface{}, 0),
+		}
+		for _, s := range all {
+			for _, p := range s.Datapoints {
+				tbl.Rows = append(tbl.Rows, []interface{}{int64(p[1]), s.Target, p[0]})
+			}
+		}
+		result = append(result, tbl)
+	}
+	return result, nil
+}
+
+// targetSeries totals the records a target selects per day, zero on days
+// without spend. Wildcard targets give one series per value.
+func targetSeries(target string, records []normalizer.CostRecord, start, end time.Time) ([]series, error) {
+	dim, want, err := parseTarget(target)
+	if err != nil {
+		return nil, err
+	}
+	if dim == wherePrefix {
+		where, err := query.Parse(want)
+		if err != nil {
+			return nil, err
+		}
+		records, dim, want = where.Select(records), "", ""
+	}
+
+	byName := make(map[string]map[string]float64)
+	for _, rec := range records {
+		name := target
+		if dim != "" {
+			v, ok := value(rec, dim)
+			if !ok || (want != "*" && v != want) {
+				continue
+			}
+			name = dim + "=" + v
+		}
+		if byName[name] == nil {
+			byName[name] = make(map[string]float64)
+		}
+		byName[name][rec.Date.Format("2006-01-02")] += rec.Cost
+	}
+	if len(byName) == 0 && want != "*" {
+		byName[target] = nil
+	}
+
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	all := make([]series, 0, len(names))
+	for _, name := range names {
+		s := series{Target: name, Datapoints: make([][2]float64, 0)}
+		for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+			s.Datapoints = append(s.Datapoints, [2]float64{byName[name][d.Format("2006-01-02")], float64(d.UnixMilli())})
+		}
+		all = append(all, s)
+	}
+	return all, nil
+}
+
+// annotations returns the tracked anomalies dated within the range
+func annotations(r *http.Request, history store.CostStore, req annotationRequest) ([]annotation, error) {
+	var ann struct {
+		Query string `json:"query"`
+	}
+	if len(req.Annotation) > 0 {
+		if err := json.Unmarshal(req.Annotation, &ann); err != nil {
+			return nil, fmt.Errorf("invalid annotation: %w", err)
+		}
+	}
+	statuses := map[string]bool{anomaly.StatusOpen: true, anomaly.StatusAcknowledged: true}
+	if q := strings.TrimSpace(ann.Query); q != "" {
+		statuses = make(map[string]bool)
+		for _, status := range strings.Split(q, ",") {
+			status = strings.TrimSpace(status)
+			if err := anomaly.ValidateStatus(status); err != nil {
+				return nil, err
+			}
+			statuses[status] = true
+		}
+	}
+
+	states, err := history.LoadAnomalyStates(r.Context())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load anomaly states: %w", err)
+	}
+	start, end := day(req.Range.From), day(req.Range.To).AddDate(0, 0, 1)
+	result := make([]annotation, 0)
+	for _, st := range states {
+		if !statuses[st.Status] || st.Date.Before(start) || !st.Date.Before(end) {
+			continue
+		}
+		text := fmt.Sprintf("Anomaly %s in %s (%s)", st.ID, st.Scope, st.Status)
+		if st.Note != "" {
+			text += ": " + st.Note
+		}
+		result = append(result, annotation{
+			Annotation: req.Annotation,
+			Time:       st.Date.UnixMilli(),
+			Title:      "Cost anomaly: " + st.Scope,
+			Text:       text,
+			Tags:       []string{"anomaly", st.Status},
+		})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Time < result[j].Time })
+	return result, nil
+}
+
+// parseTarget splits a target into its dimension and value, both empty for
+// the total. A filter expression target is the dimension where: and the
+// expression.
+func parseTarget(target string) (dim, want string, err error) {
+	if target == Total {
+		return "", "", nil
+	}
+	if expr, ok := strings.CutPrefix(target, wherePrefix); ok {
+		if _, err := query.Parse(expr); err != nil {
+			return "", "", fmt.Errorf("invalid target %q: %w", target, err)
+		}
+		return wherePrefix, expr, nil
+	}
+	i := strings.Index(target, "=")
+	if i <= 0 {
+		return "", "", fmt.Errorf("unknown target %q (want total, <dimension>=<value> or where:<expression>)", target)
+	}
+	dim, want = target[:i], target[i+1:]
+	if !validDimension(dim) {
+		return "", "", fmt.Errorf("unknown dimension %q in target %q (want cloud, account, service, region or tag:<key>)", dim, target)
+	}
+	return dim, want, nil
+}
+
+func validDimension(dim string) bool {
+	for _, d := range dimensions {
+		if dim == d {
+			return true
+		}
+	}
+	return strings.HasPrefix(dim, tagPrefix) && len(dim) > len(tagPrefix)
+}
+
+// value returns a record's value of a dimension, false for a missing tag
+func value(r normalizer.CostRecord, dim string) (string, bool) {
+	switch dim {
+	case anomaly.DimCloud:
+		return r.Cloud, true
+	case anomaly.DimAccount:
+		return r.Account, true
+	case anomaly.DimService:
+		return r.Service, true
+	case anomaly.DimRegion:
+		return r.Region, true
+	}
+	v, ok := r.Tags[strings.TrimPrefix(dim, tagPrefix)]
+	return v, ok && v != ""
+}
+
+// day truncates t to its UTC day
+func day(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
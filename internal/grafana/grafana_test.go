@@ -0,0 +1,231 @@
+package grafana
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/anomaly"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+	"github.com/lvonguyen/finops-platform/internal/store"
+)
+
+var march1 = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+// ms returns a day's Grafana timestamp
+func ms(days int) float64 {
+	return float64(march1.AddDate(0, 0, days).UnixMilli())
+}
+
+// newHandler serves three days from March 1 of 10 a day on AWS EC2 in prod
+// and 5 on GCP BigQuery in dev, with an anomaly of each status
+func newHandler(t *testing.T) http.Handler {
+	t.Helper()
+	ctx := context.Background()
+	history, err := store.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var records []normalizer.CostRecord
+	for i := 0; i < 3; i++ {
+		d := march1.AddDate(0, 0, i)
+		records = append(records,
+			normalizer.CostRecord{Cloud: "aws", Account: "111", Service: "EC2", Region: "us-east-1", Date: d, Cost: 10, Tags: map[string]string{"env": "prod"}},
+			normalizer.CostRecord{Cloud: "gcp", Account: "p1", Service: "BigQuery", Date: d, Cost: 5, Tags: map[string]string{"env": "dev"}},
+		)
+	}
+	if err := history.SaveRecords(ctx, records); err != nil {
+		t.Fatal(err)
+	}
+	for _, st := range []store.AnomalyState{
+		{ID: "a1", Status: anomaly.StatusOpen, Scope: "aws/111/EC2", Date: march1.AddDate(0, 0, 1)},
+		{ID: "a2", Status: anomaly.StatusAcknowledged, Scope: "gcp/p1/BigQuery", Date: march1, Note: "INC-7"},
+		{ID: "a3", Status: anomaly.StatusSuppressed, Scope: "gcp/p1/BigQuery", Date: march1.AddDate(0, 0, 2)},
+	} {
+		if err := history.SaveAnomalyState(ctx, st); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return Handler(history)
+}
+
+// post sends a JSON body to the handler
+func post(h http.Handler, path, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+	return rec
+}
+
+func TestSearch(t *testing.T) {
+	h := newHandler(t)
+	tests := []struct {
+		body string
+		want []string
+	}{
+		{`{"target": "env"}`, []string{"tag:env=*", "tag:env=dev", "tag:env=prod"}},
+		{`{"target": "CLOUD"}`, []string{"cloud=*", "cloud=aws", "cloud=gcp"}},
+		{`{"target": "region="}`, []string{"region=*", "region=us-east-1"}},
+		{`{"target": "tot"}`, []string{"total"}},
+	}
+	for _, tt := range tests {
+		rec := post(h, "/search", tt.body)
+		var got []string
+		if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+			t.Fatalf("%s: %v", tt.body, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("search %s = %v, want %v", tt.body, got, tt.want)
+		}
+	}
+
+	// Without a body every target is listed, total first
+	var all []string
+	json.NewDecoder(post(h, "/search", "").Body).Decode(&all)
+	if len(all) != 15 || all[0] != Total {
+		t.Errorf("search = %v, want total and 14 dimension targets", all)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	body := `{
+		"range": {"from": "2024-03-01T10:00:00Z", "to": "2024-03-02T23:00:00Z"},
+		"targets": [
+			{"target": "total"},
+			{"target": "cloud=*"},
+			{"target": "service=S3"},
+			{"target": "tag:env=prod", "type": "table"},
+			{"target": ""}
+		]
+	}`
+	rec := post(newHandler(t), "/query", body)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var got []struct {
+		Target     string
+		Datapoints [][2]float64
+		Type       string
+		Rows       [][]interface{}
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 5 {
+		t.Fatalf("got %d results, want 4 series and a table", len(got))
+	}
+
+	// Days of the range, zero without spend
+	wantSeries := map[string][][2]float64{
+		"total":      {{15, ms(0)}, {15, ms(1)}},
+		"cloud=aws":  {{10, ms(0)}, {10, ms(1)}},
+		"cloud=gcp":  {{5, ms(0)}, {5, ms(1)}},
+		"service=S3": {{0, ms(0)}, {0, ms(1)}},
+	}
+	for i, name := range []string{"total", "cloud=aws", "cloud=gcp", "service=S3"} {
+		if got[i].Target != name || !reflect.DeepEqual(got[i].Datapoints, wantSeries[name]) {
+			t.Errorf("series %d = %s %v, want %s %v", i, got[i].Target, got[i].Datapoints, name, wantSeries[name])
+		}
+	}
+	wantRows := [][]interface{}{{ms(0), "tag:env=prod", 10.0}, {ms(1), "tag:env=prod", 10.0}}
+	if tbl := got[4]; tbl.Type != "table" || !reflect.DeepEqual(tbl.Rows, wantRows) {
+		t.Errorf("table = %+v, want rows %v", tbl, wantRows)
+	}
+}
+
+func TestQueryWhere(t *testing.T) {
+	body := `{
+		"range": {"from": "2024-03-01T00:00:00Z", "to": "2024-03-02T00:00:00Z"},
+		"targets": [
+			{"target": "where:cloud = \"aws\" AND tag.env != \"dev\""},
+			{"target": "where:cost > 100"}
+		]
+	}`
+	rec := post(newHandler(t), "/query", body)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var got []series
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	// A series named by its target, zero when nothing matches
+	want := []series{
+		{Target: `where:cloud = "aws" AND tag.env != "dev"`, Datapoints: [][2]float64{{10, ms(0)}, {10, ms(1)}}},
+		{Target: "where:cost > 100", Datapoints: [][2]float64{{0, ms(0)}, {0, ms(1)}}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("series = %+v, want %+v", got, want)
+	}
+}
+
+func TestHandlerErrors(t *testing.T) {
+	h := newHandler(t)
+	rng := `"range": {"from": "2024-03-01T00:00:00Z", "to": "2024-03-02T00:00:00Z"}`
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{"connection test", http.MethodGet, "/", "", http.StatusOK},
+		{"get query", http.MethodGet, "/query", "", http.StatusMethodNotAllowed},
+		{"unknown path", http.MethodPost, "/metrics", "{}", http.StatusNotFound},
+		{"invalid JSON", http.MethodPost, "/query", "{", http.StatusBadRequest},
+		{"no range", http.MethodPost, "/query", `{"targets": [{"target": "total"}]}`, http.StatusBadRequest},
+		{"unknown target", http.MethodPost, "/query", `{` + rng + `, "targets": [{"target": "spend"}]}`, http.StatusBadRequest},
+		{"unknown dimension", http.MethodPost, "/query", `{` + rng + `, "targets": [{"target": "owner=web"}]}`, http.StatusBadRequest},
+		{"empty tag", http.MethodPost, "/query", `{` + rng + `, "targets": [{"target": "tag:=web"}]}`, http.StatusBadRequest},
+		{"invalid expression", http.MethodPost, "/query", `{` + rng + `, "targets": [{"target": "where:cost > lots"}]}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestAnnotations(t *testing.T) {
+	h := newHandler(t)
+	tests := []struct {
+		name  string
+		query string
+		want  []string // title: text
+	}{
+		{"default", "", []string{
+			"Cost anomaly: gcp/p1/BigQuery: Anomaly a2 in gcp/p1/BigQuery (acknowledged): INC-7",
+			"Cost anomaly: aws/111/EC2: Anomaly a1 in aws/111/EC2 (open)",
+		}},
+		{"suppressed", "suppressed", []string{
+			"Cost anomaly: gcp/p1/BigQuery: Anomaly a3 in gcp/p1/BigQuery (suppressed)",
+		}},
+		{"listed", " open , suppressed", []string{
+			"Cost anomaly: aws/111/EC2: Anomaly a1 in aws/111/EC2 (open)",
+			"Cost anomaly: gcp/p1/BigQuery: Anomaly a3 in gcp/p1/BigQuery (suppressed)",
+		}},
+	}
+	for _, tt := range tests {
+		body := `{"range": {"from": "2024-03-01T00:00:00Z", "to": "2024-03-03T00:00:00Z"}, "annotation": {"name": "anomalies", "query": "` + tt.query + `"}}`
+		var got []annotation
+		if err := json.NewDecoder(post(h, "/annotations", body).Body).Decode(&got); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		var lines []string
+		for _, a := range got {
+			lines = append(lines, a.Title+": "+a.Text)
+			if !strings.Contains(string(a.Annotation), `"anomalies"`) || a.Tags[0] != "anomaly" {
+				t.Errorf("%s: annotation %+v does not echo its query and tags", tt.name, a)
+			}
+		}
+		if !reflect.DeepEqual(lines, tt.want) {
+			t.Errorf("%s: annotations = %q, want %q", tt.name, lines, tt.want)
+		}
+	}
+}
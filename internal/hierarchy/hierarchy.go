@@ -0,0 +1,124 @@
+// Package hierarchy maps cloud accounts to their place in the organization:
+// AWS Organizations OUs, Azure management groups or GCP folders
+package hierarchy
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Unassigned is the org unit of accounts missing from the hierarchy
+const Unassigned = "unassigned"
+
+// Hierarchy maps account IDs to org-unit paths, root first
+type Hierarchy struct {
+	paths map[string][]string
+}
+
+// New creates a hierarchy from account -> path segments
+func New(paths map[string][]string) *Hierarchy {
+	return &Hierarchy{paths: paths}
+}
+
+// file is the YAML layout of a hierarchy file
+type file struct {
+	Accounts map[string]string `yaml:"accounts"` // account -> "Root/Engineering/Platform"
+}
+
+// Load reads a hierarchy file. YAML files hold an accounts map of account ID
+// to slash-separated path; CSV files hold account,path rows.
+func Load(path string) (*Hierarchy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read hierarchy file: %w", err)
+	}
+
+	raw := make(map[string]string)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		rows, err := csv.NewReader(strings.NewReader(string(data))).ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse hierarchy file: %w", err)
+		}
+		for i, row := range rows {
+			if len(row) < 2 {
+				return nil, fmt.Errorf("hierarchy file line %d: want account,path", i+1)
+			}
+			if i == 0 && strings.EqualFold(strings.TrimSpace(row[0]), "account") {
+				continue // header
+			}
+			raw[strings.TrimSpace(row[0])] = row[1]
+		}
+	default:
+		var f file
+		if err := yaml.Unmarshal(data, &f); err != nil {
+			return nil, fmt.Errorf("failed to parse hierarchy file: %w", err)
+		}
+		raw = f.Accounts
+	}
+
+	paths := make(map[string][]string, len(raw))
+	for account, p := range raw {
+		paths[account] = Split(p)
+	}
+	return New(paths), nil
+}
+
+// Split turns "Root/Engineering/Platform" into its segments
+func Split(path string) []string {
+	var segments []string
+	for _, s := range strings.Split(path, "/") {
+		if s = strings.TrimSpace(s); s != "" {
+			segments = append(segments, s)
+		}
+	}
+	return segments
+}
+
+// Path returns the account's org-unit path, root first
+func (h *Hierarchy) Path(account string) ([]string, bool) {
+	if h == nil {
+		return nil, false
+	}
+	p, ok := h.paths[account]
+	return p, ok && len(p) > 0
+}
+
+// Level returns the org unit at a 1-based depth from the root; negative
+// levels count from the leaf (-1 is the account's own org unit)
+func (h *Hierarchy) Level(account string, level int) (string, bool) {
+	p, ok := h.Path(account)
+	if !ok || level == 0 {
+		return "", false
+	}
+	i := level - 1
+	if level < 0 {
+		i = len(p) + level
+	}
+	if i < 0 || i >= len(p) {
+		return "", false
+	}
+	return p[i], true
+}
+
+// Key returns the account's org-unit path joined with "/", or Unassigned
+func (h *Hierarchy) Key(account string) string {
+	p, ok := h.Path(account)
+	if !ok {
+		return Unassigned
+	}
+	return strings.Join(p, "/")
+}
+
+// Len returns the number of accounts mapped
+func (h *Hierarchy) Len() int {
+	if h == nil {
+		return 0
+	}
+	return len(h.paths)
+}
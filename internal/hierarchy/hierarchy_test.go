@@ -0,0 +1,73 @@
+package hierarchy
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"org.yaml": "accounts:\n  \"111\": Root/Engineering/Platform\n  \"222\": /Root/ Finance /\n",
+		"org.csv":  "account,path\n111,Root/Engineering/Platform\n222,Root/Finance\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		h, err := Load(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if h.Len() != 2 {
+			t.Errorf("%s: Len() = %d, want 2", name, h.Len())
+		}
+		if p, ok := h.Path("111"); !ok || !reflect.DeepEqual(p, []string{"Root", "Engineering", "Platform"}) {
+			t.Errorf("%s: Path(111) = %v, %v", name, p, ok)
+		}
+		if p, _ := h.Path("222"); !reflect.DeepEqual(p, []string{"Root", "Finance"}) {
+			t.Errorf("%s: Path(222) = %v, want [Root Finance]", name, p)
+		}
+	}
+
+	bad := filepath.Join(dir, "bad.csv")
+	if err := os.WriteFile(bad, []byte("111\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(bad); err == nil {
+		t.Error("Load accepted a row without a path")
+	}
+}
+
+func TestLevel(t *testing.T) {
+	h := New(map[string][]string{"111": {"Root", "Engineering", "Platform"}})
+	tests := []struct {
+		account string
+		level   int
+		want    string
+		ok      bool
+	}{
+		{"111", 1, "Root", true},
+		{"111", 2, "Engineering", true},
+		{"111", -1, "Platform", true},
+		{"111", -3, "Root", true},
+		{"111", 4, "", false},
+		{"111", -4, "", false},
+		{"111", 0, "", false},
+		{"999", 1, "", false},
+	}
+	for _, tt := range tests {
+		got, ok := h.Level(tt.account, tt.level)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("Level(%s, %d) = %q, %v, want %q, %v", tt.account, tt.level, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	var none *Hierarchy
+	if _, ok := none.Path("111"); ok || none.Len() != 0 {
+		t.Error("nil hierarchy has accounts")
+	}
+}
@@ -0,0 +1,89 @@
+package hierarchy
+
+import (
+	"sort"
+	"strings"
+)
+
+// Node is an org unit with its cost, including every unit beneath it
+type Node struct {
+	Name     string  `json:"name"`
+	Path     string  `json:"path"`
+	Cost     float64 `json:"cost"`
+	Children []*Node `json:"children,omitempty"`
+}
+
+// Row is one line of a flattened rollup
+type Row struct {
+	Name    string
+	Path    string
+	Depth   int // 0 for top-level units
+	Cost    float64
+	Percent float64 // share of the rollup total
+}
+
+// Rollup builds the org tree from costs keyed by org-unit path, as produced
+// by Key. The returned root is unnamed and holds the grand total.
+func Rollup(costs map[string]float64) *Node {
+	root := &Node{}
+	for path, cost := range costs {
+		root.Cost += cost
+		node := root
+		segments := Split(path)
+		for i, name := range segments {
+			node = node.child(name, strings.Join(segments[:i+1], "/"))
+			node.Cost += cost
+		}
+	}
+	root.sort()
+	return root
+}
+
+func (n *Node) child(name, path string) *Node {
+	for _, c := range n.Children {
+		if c.Name == name {
+			return c
+		}
+	}
+	c := &Node{Name: name, Path: path}
+	n.Children = append(n.Children, c)
+	return c
+}
+
+// sort orders children by cost descending, with Unassigned last
+func (n *Node) sort() {
+	sort.Slice(n.Children, func(i, j int) bool {
+		a, b := n.Children[i], n.Children[j]
+		if (a.Path == Unassigned) != (b.Path == Unassigned) {
+			return b.Path == Unassigned
+		}
+		if a.Cost != b.Cost {
+			return a.Cost > b.Cost
+		}
+		return a.Name < b.Name
+	})
+	for _, c := range n.Children {
+		c.sort()
+	}
+}
+
+// Rows flattens the tree depth-first, parents before their children, down
+// to maxDepth levels (0 for all)
+func (n *Node) Rows(maxDepth int) []Row {
+	var rows []Row
+	var walk func(node *Node, depth int)
+	walk = func(node *Node, depth int) {
+		for _, c := range node.Children {
+			row := Row{Name: c.Name, Path: c.Path, Depth: depth, Cost: c.Cost}
+			if n.Cost != 0 {
+				row.Percent = c.Cost / n.Cost * 100
+			}
+			rows = append(rows, row)
+			if maxDepth == 0 || depth+1 < maxDepth {
+				walk(c, depth+1)
+			}
+		}
+	}
+	walk(n, 0)
+	return rows
+}
@@ -0,0 +1,57 @@
+package hierarchy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRollup(t *testing.T) {
+	root := Rollup(map[string]float64{
+		"Root/Engineering/Platform": 60,
+		"Root/Engineering/Data":     30,
+		"Root/Finance":              50,
+		Unassigned:                  260,
+	})
+	if root.Cost != 400 {
+		t.Errorf("root cost = %v, want 400", root.Cost)
+	}
+
+	type row struct {
+		path  string
+		depth int
+		cost  float64
+	}
+	var got []row
+	for _, r := range root.Rows(0) {
+		got = append(got, row{r.Path, r.Depth, r.Cost})
+	}
+	want := []row{
+		{"Root", 0, 140},
+		{"Root/Engineering", 1, 90},
+		{"Root/Engineering/Platform", 2, 60},
+		{"Root/Engineering/Data", 2, 30},
+		{"Root/Finance", 1, 50},
+		{Unassigned, 0, 260},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Rows(0) = %v, want %v", got, want)
+	}
+
+	top := root.Rows(2)
+	if len(top) != 4 {
+		t.Fatalf("Rows(2) returned %d rows, want 4", len(top))
+	}
+	if top[1].Name != "Engineering" || top[1].Percent != 22.5 {
+		t.Errorf("Rows(2)[1] = %+v, want Engineering at 22.5%%", top[1])
+	}
+}
+
+func TestKey(t *testing.T) {
+	h := New(map[string][]string{"111": {"Root", "Engineering"}})
+	if got := h.Key("111"); got != "Root/Engineering" {
+		t.Errorf("Key(111) = %s, want Root/Engineering", got)
+	}
+	if got := h.Key("999"); got != Unassigned {
+		t.Errorf("Key(999) = %s, want %s", got, Unassigned)
+	}
+}
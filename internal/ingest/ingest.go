@@ -0,0 +1,151 @@
+// Package ingest keeps the history store current by fetching, per provider,
+// only the days since its last successful ingest plus a restatement window
+package ingest
+
+import (
+	"context"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+	"github.com/lvonguyen/finops-platform/internal/store"
+)
+
+// Source fetches one provider's aggregated costs for [start, end)
+type Source interface {
+	Providers() []string
+	AggregateProvider(ctx context.Context, name string, start, end time.Time) (*aggregator.AggregationResult, error)
+}
+
+// Config bounds what each run re-fetches
+type Config struct {
+	LookbackDays    int // days loaded for a provider without a watermark
+	RestatementDays int // trailing days before the watermark re-fetched for late billing corrections
+
+	// Reconcile's window and the smallest change it reports
+	ReconcileDays    int
+	Threshold        float64 // in the base currency
+	ThresholdPercent float64 // in percent of the stored cost, 0 ignores
+}
+
+// FromConfig reads the ingest configuration
+func FromConfig(cfg config.IngestConfig) Config {
+	return Config{
+		LookbackDays:     cfg.LookbackDays,
+		RestatementDays:  cfg.RestatementDays,
+		ReconcileDays:    cfg.Reconcile.WindowDays,
+		Threshold:        cfg.Reconcile.Threshold,
+		ThresholdPercent: cfg.Reconcile.ThresholdPercent,
+	}
+}
+
+// Result is the outcome of ingesting one provider
+type Result struct {
+	Provider  string
+	Start     time.Time
+	End       time.Time
+	Watermark time.Time // previous watermark, zero on the first ingest
+	Records   int
+	Err       error
+}
+
+// Ingester loads new and restated days into the history store. Each
+// provider's watermark, the day after its last ingested day, is kept as a
+// store checkpoint.
+type Ingester struct {
+	source Source
+	store  store.CostStore
+	cfg    Config
+}
+
+// New creates an ingester
+func New(source Source, st store.CostStore, cfg Config) *Ingester {
+	return &Ingester{source: source, store: st, cfg: cfg}
+}
+
+// JobName identifies a provider's watermark checkpoint
+func JobName(provider string) string {
+	return "ingest-" + provider
+}
+
+// Run ingests every provider up to today, which is excluded as its costs are
+// still accruing. A failed provider keeps its watermark, so the next run
+// fetches its missed days; the others are unaffected.
+func (i *Ingester) Run(ctx context.Context, today time.Time) []Result {
+	var results []Result
+	for _, name := range i.source.Providers() {
+		if ctx.Err() != nil {
+			break
+		}
+		results = append(results, i.ingest(ctx, name, today))
+	}
+	return results
+}
+
+func (i *Ingester) ingest(ctx context.Context, name string, today time.Time) Result {
+	res := Result{Provider: name, End: today}
+	job := JobName(name)
+	cp, ok, err := i.store.LoadCheckpoint(ctx, job)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+
+	res.Start = today.AddDate(0, 0, -i.cfg.LookbackDays)
+	if ok {
+		res.Watermark = cp.Completed
+		if from := cp.Completed.AddDate(0, 0, -i.cfg.RestatementDays); from.After(res.Start) {
+			res.Start = from
+		}
+	}
+	if !res.Start.Before(today) {
+		return res
+	}
+
+	results, err := i.source.AggregateProvider(ctx, name, res.Start, today)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+
+	fetched := results.Records()
+	if err := i.save(ctx, name, fetched, res.Start, today); err != nil {
+		res.Err = err
+		return res
+	}
+	res.Records = len(fetched)
+
+	from := res.Start
+	if ok && cp.From.Before(from) {
+		from = cp.From // first day ever ingested
+	}
+	res.Err = i.store.SaveCheckpoint(ctx, store.Checkpoint{Job: job, From: from, To: today, Completed: today, UpdatedAt: time.Now().UTC()})
+	return res
+}
+
+// save stores a provider's records for [start, end). The store replaces
+// whole days, so each day's records from other providers are read back and
+// saved alongside. Records belong to a provider by its name or the clouds it
+// just returned, which covers imports that report the original cloud.
+func (i *Ingester) save(ctx context.Context, name string, fetched []normalizer.CostRecord, start, end time.Time) error {
+	stored, err := i.store.QueryRange(ctx, start, end)
+	if err != nil {
+		return err
+	}
+
+	clouds := map[string]bool{name: true}
+	days := make(map[string]bool)
+	for _, r := range fetched {
+		clouds[r.Cloud] = true
+		days[r.Date.Format("2006-01-02")] = true
+	}
+
+	records := fetched
+	for _, r := range stored {
+		if !clouds[r.Cloud] && days[r.Date.Format("2006-01-02")] {
+			records = append(records, r)
+		}
+	}
+	return i.store.SaveRecords(ctx, records)
+}
@@ -0,0 +1,150 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+	"github.com/lvonguyen/finops-platform/internal/store"
+)
+
+var today = time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)
+
+// fetch is a range a source was asked for
+type fetch struct {
+	provider   string
+	start, end time.Time
+}
+
+// fakeSource bills each provider a fixed daily cost
+type fakeSource struct {
+	daily   map[string]float64
+	errs    map[string]error
+	fetches []fetch
+}
+
+func (s *fakeSource) Providers() []string {
+	return []string{"aws", "gcp"}
+}
+
+func (s *fakeSource) AggregateProvider(ctx context.Context, name string, start, end time.Time) (*aggregator.AggregationResult, error) {
+	s.fetches = append(s.fetches, fetch{name, start, end})
+	if err := s.errs[name]; err != nil {
+		return nil, err
+	}
+	result := &aggregator.AggregationResult{}
+	for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+		result.Entries = append(result.Entries, aggregator.CostEntry{Provider: name, AccountID: "111", Service: "Compute", Date: d, Cost: s.daily[name]})
+	}
+	return result, nil
+}
+
+// newStore opens a file store in a temporary directory
+func newStore(t *testing.T) *store.FileStore {
+	t.Helper()
+	st, err := store.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return st
+}
+
+// byCloud totals stored records per cloud
+func byCloud(records []normalizer.CostRecord) map[string]float64 {
+	totals := make(map[string]float64)
+	for _, r := range records {
+		totals[r.Cloud] += r.Cost
+	}
+	return totals
+}
+
+func TestRunAdvancesWatermarks(t *testing.T) {
+	ctx := context.Background()
+	st := newStore(t)
+	src := &fakeSource{daily: map[string]float64{"aws": 10, "gcp": 1}}
+	ing := New(src, st, Config{LookbackDays: 10, RestatementDays: 3})
+
+	// The first run loads the lookback window
+	results := ing.Run(ctx, today)
+	for _, r := range results {
+		if r.Err != nil || !r.Start.Equal(today.AddDate(0, 0, -10)) || !r.Watermark.IsZero() || r.Records != 10 {
+			t.Errorf("first run %s = %+v, want 10 records from Mar 10 without a watermark", r.Provider, r)
+		}
+	}
+
+	// The next day re-fetches the restatement window and the new day
+	src.fetches = nil
+	src.daily["aws"] = 12 // restated
+	next := today.AddDate(0, 0, 1)
+	results = ing.Run(ctx, next)
+	want := []fetch{{"aws", today.AddDate(0, 0, -3), next}, {"gcp", today.AddDate(0, 0, -3), next}}
+	if len(src.fetches) != 2 || src.fetches[0] != want[0] || src.fetches[1] != want[1] {
+		t.Errorf("fetches = %+v, want %+v", src.fetches, want)
+	}
+	for _, r := range results {
+		if r.Err != nil || !r.Watermark.Equal(today) || r.Records != 4 {
+			t.Errorf("second run %s = %+v, want 4 records after the Mar 20 watermark", r.Provider, r)
+		}
+	}
+
+	cp, ok, err := st.LoadCheckpoint(ctx, JobName("aws"))
+	if err != nil || !ok {
+		t.Fatalf("checkpoint = %v, %v", ok, err)
+	}
+	if !cp.From.Equal(today.AddDate(0, 0, -10)) || !cp.Completed.Equal(next) {
+		t.Errorf("checkpoint covers %s to %s, want Mar 10 to Mar 21", cp.From, cp.Completed)
+	}
+
+	// Restated days replace aws's records and keep gcp's
+	records, err := st.QueryRange(ctx, today.AddDate(0, 0, -10), next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := byCloud(records); got["aws"] != 7*10+4*12 || got["gcp"] != 11 {
+		t.Errorf("stored totals = %v, want aws 118 and gcp 11", got)
+	}
+}
+
+func TestRunKeepsFailedWatermark(t *testing.T) {
+	ctx := context.Background()
+	st := newStore(t)
+	src := &fakeSource{daily: map[string]float64{"aws": 10, "gcp": 1}}
+	ing := New(src, st, Config{LookbackDays: 5, RestatementDays: 1})
+	ing.Run(ctx, today)
+
+	src.errs = map[string]error{"aws": errors.New("throttled")}
+	results := ing.Run(ctx, today.AddDate(0, 0, 2))
+	if results[0].Err == nil || results[1].Err != nil {
+		t.Fatalf("results = %+v, want only aws to fail", results)
+	}
+	if cp, _, _ := st.LoadCheckpoint(ctx, JobName("aws")); !cp.Completed.Equal(today) {
+		t.Errorf("aws watermark = %s, want it left at Mar 20", cp.Completed)
+	}
+
+	// The next run picks up the days aws missed
+	src.errs, src.fetches = nil, nil
+	ing.Run(ctx, today.AddDate(0, 0, 3))
+	if len(src.fetches) != 2 || !src.fetches[0].start.Equal(today.AddDate(0, 0, -1)) || !src.fetches[1].start.Equal(today.AddDate(0, 0, 1)) {
+		t.Errorf("fetches = %+v, want aws from Mar 19 and gcp from Mar 21", src.fetches)
+	}
+}
+
+func TestRunUpToDate(t *testing.T) {
+	ctx := context.Background()
+	src := &fakeSource{daily: map[string]float64{"aws": 10, "gcp": 1}}
+	ing := New(src, newStore(t), Config{LookbackDays: 5})
+	ing.Run(ctx, today)
+
+	src.fetches = nil
+	for _, r := range ing.Run(ctx, today) {
+		if r.Err != nil || r.Records != 0 {
+			t.Errorf("%s = %+v, want nothing to ingest", r.Provider, r)
+		}
+	}
+	if len(src.fetches) != 0 {
+		t.Errorf("fetches = %+v, want none without a restatement window", src.fetches)
+	}
+}
@@ -0,0 +1,224 @@
+package ingest
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+	"github.com/lvonguyen/finops-platform/internal/store"
+)
+
+// Change is a day's service cost that a provider restated
+type Change struct {
+	Date         time.Time `json:"date"`
+	Provider     string    `json:"provider"`
+	Cloud        string    `json:"cloud"`
+	Service      string    `json:"service"`
+	Stored       float64   `json:"stored"`
+	Restated     float64   `json:"restated"`
+	Delta        float64   `json:"delta"`
+	DeltaPercent float64   `json:"delta_percent"` // of the stored cost, 0 when nothing was stored
+}
+
+// Restatement is the outcome of reconciling one provider
+type Restatement struct {
+	Provider string    `json:"provider"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Stored   float64   `json:"stored"`   // stored total of the window
+	Restated float64   `json:"restated"` // re-fetched total of the window
+	Records  int       `json:"records"`
+	Changes  []Change  `json:"changes"`       // above the threshold, largest first
+	Minor    int       `json:"minor_changes"` // changes below the threshold
+	Err      error     `json:"-"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// Reconcile re-fetches the trailing window of each provider's ingested
+// days, compares it per day and service with the store, stores the restated
+// records and reports the changes over the threshold. Providers never
+// ingested are skipped, and neither the window nor the watermarks reach
+// past what ingest has stored. Months already rolled up are left as they
+// are, and a day the provider no longer reports at all is reported but
+// keeps its stored records, as the store replaces only days saved.
+func (i *Ingester) Reconcile(ctx context.Context, today time.Time) []Restatement {
+	var results []Restatement
+	for _, name := range i.source.Providers() {
+		if ctx.Err() != nil {
+			break
+		}
+		res := i.reconcile(ctx, name, today)
+		if res.Err != nil {
+			res.Error = res.Err.Error()
+		}
+		results = append(results, res)
+	}
+	return results
+}
+
+func (i *Ingester) reconcile(ctx context.Context, name string, today time.Time) Restatement {
+	res := Restatement{Provider: name}
+	cp, ok, err := i.store.LoadCheckpoint(ctx, JobName(name))
+	if err != nil || !ok {
+		res.Err = err
+		return res
+	}
+
+	res.Start, res.End = today.AddDate(0, 0, -i.cfg.ReconcileDays), cp.Completed
+	if cp.From.After(res.Start) {
+		res.Start = cp.From
+	}
+
+	// Days of a rolled-up month are not stored again, so start after them
+	monthStart := time.Date(res.Start.Year(), res.Start.Month(), 1, 0, 0, 0, 0, time.UTC)
+	stored, err := i.store.QueryRange(ctx, monthStart, res.End)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	for _, r := range stored {
+		if store.IsRollup(r) && r.EndTime.After(res.Start) {
+			res.Start = r.EndTime
+		}
+	}
+	if !res.Start.Before(res.End) {
+		return res
+	}
+
+	results, err := i.source.AggregateProvider(ctx, name, res.Start, res.End)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	fetched := results.Records()
+
+	clouds := map[string]bool{name: true}
+	for _, r := range fetched {
+		clouds[r.Cloud] = true
+	}
+	var before []normalizer.CostRecord
+	for _, r := range stored {
+		if clouds[r.Cloud] && !store.IsRollup(r) && !r.Date.Before(res.Start) && r.Date.Before(res.End) {
+			before = append(before, r)
+		}
+	}
+
+	if err := i.save(ctx, name, fetched, res.Start, res.End); err != nil {
+		res.Err = err
+		return res
+	}
+	res.Records = len(fetched)
+	i.diff(&res, before, fetched)
+	return res
+}
+
+// diff compares the stored and restated costs of each day and service
+func (i *Ingester) diff(res *Restatement, before, after []normalizer.CostRecord) {
+	type key struct {
+		day            time.Time
+		cloud, service string
+	}
+	stored := make(map[key]float64)
+	restated := make(map[key]float64)
+	for _, r := range before {
+		stored[key{r.Date.UTC(), r.Cloud, r.Service}] += r.Cost
+		res.Stored += r.Cost
+	}
+	for _, r := range after {
+		restated[key{r.Date.UTC(), r.Cloud, r.Service}] += r.Cost
+		res.Restated += r.Cost
+	}
+	for k := range stored {
+		if _, ok := restated[k]; !ok {
+			restated[k] = 0
+		}
+	}
+
+	for k, cost := range restated {
+		c := Change{
+			Date:     k.day,
+			Provider: res.Provider,
+			Cloud:    k.cloud,
+			Service:  k.service,
+			Stored:   stored[k],
+			Restated: cost,
+			Delta:    cost - stored[k],
+		}
+		if math.Abs(c.Delta) < 0.005 {
+			continue // unchanged but for rounding
+		}
+		if c.Stored != 0 {
+			c.DeltaPercent = c.Delta / math.Abs(c.Stored) * 100
+		}
+		if math.Abs(c.Delta) < i.cfg.Threshold || c.Stored != 0 && math.Abs(c.DeltaPercent) < i.cfg.ThresholdPercent {
+			res.Minor++
+			continue
+		}
+		res.Changes = append(res.Changes, c)
+	}
+	sort.Slice(res.Changes, func(a, b int) bool {
+		ca, cb := res.Changes[a], res.Changes[b]
+		if da, db := math.Abs(ca.Delta), math.Abs(cb.Delta); da != db {
+			return da > db
+		}
+		if !ca.Date.Equal(cb.Date) {
+			return ca.Date.Before(cb.Date)
+		}
+		return ca.Cloud+"/"+ca.Service < cb.Cloud+"/"+cb.Service
+	})
+}
+
+// SaveCSV writes the changes of each provider as CSV, largest first
+func SaveCSV(path string, results []Restatement) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	header := []string{"Date", "Provider", "Cloud", "Service", "Stored", "Restated", "Delta", "Delta %"}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, res := range results {
+		for _, c := range res.Changes {
+			row := []string{
+				c.Date.Format("2006-01-02"),
+				c.Provider,
+				c.Cloud,
+				c.Service,
+				fmt.Sprintf("%.2f", c.Stored),
+				fmt.Sprintf("%.2f", c.Restated),
+				fmt.Sprintf("%.2f", c.Delta),
+				fmt.Sprintf("%.1f", c.DeltaPercent),
+			}
+			if err := writer.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// SaveJSON writes the reconciliation of each provider as JSON
+func SaveJSON(path string, results []Restatement) error {
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	return nil
+}
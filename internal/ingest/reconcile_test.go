@@ -0,0 +1,181 @@
+package ingest
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/store"
+)
+
+func TestFromConfigReconcile(t *testing.T) {
+	cfg := config.IngestConfig{LookbackDays: 30, RestatementDays: 3, Reconcile: config.ReconcileConfig{WindowDays: 14, Threshold: 5, ThresholdPercent: 2}}
+	want := Config{LookbackDays: 30, RestatementDays: 3, ReconcileDays: 14, Threshold: 5, ThresholdPercent: 2}
+	if got := FromConfig(cfg); got != want {
+		t.Errorf("FromConfig() = %+v, want %+v", got, want)
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	ctx := context.Background()
+	st := newStore(t)
+	src := &fakeSource{daily: map[string]float64{"aws": 10, "gcp": 1}}
+	ing := New(src, st, Config{LookbackDays: 10, ReconcileDays: 5, Threshold: 1})
+	ing.Run(ctx, today)
+
+	// aws restates every day by 2, gcp by a fraction of a cent
+	src.daily = map[string]float64{"aws": 12, "gcp": 1.004}
+	src.fetches = nil
+	results := ing.Reconcile(ctx, today)
+	want := []fetch{{"aws", today.AddDate(0, 0, -5), today}, {"gcp", today.AddDate(0, 0, -5), today}}
+	if !reflect.DeepEqual(src.fetches, want) {
+		t.Errorf("fetches = %+v, want %+v", src.fetches, want)
+	}
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+
+	aws := results[0]
+	if aws.Err != nil || aws.Stored != 50 || aws.Restated != 60 || aws.Records != 5 || aws.Minor != 0 {
+		t.Errorf("aws = %+v, want 50 restated to 60 in 5 records", aws)
+	}
+	if len(aws.Changes) != 5 {
+		t.Fatalf("aws has %d changes, want one per day", len(aws.Changes))
+	}
+	first := Change{Date: today.AddDate(0, 0, -5), Provider: "aws", Cloud: "aws", Service: "Compute", Stored: 10, Restated: 12, Delta: 2, DeltaPercent: 20}
+	if aws.Changes[0] != first || !aws.Changes[4].Date.Equal(today.AddDate(0, 0, -1)) {
+		t.Errorf("aws changes = %+v, want Mar 15 to Mar 19 up 2 (20%%) each", aws.Changes)
+	}
+
+	// Changes under half a cent are rounding, not restatements
+	if gcp := results[1]; gcp.Err != nil || len(gcp.Changes) != 0 || gcp.Minor != 0 {
+		t.Errorf("gcp = %+v, want no changes", gcp)
+	}
+
+	// The restated costs are stored
+	records, err := st.QueryRange(ctx, today.AddDate(0, 0, -10), today)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := byCloud(records); got["aws"] != 5*10+5*12 {
+		t.Errorf("stored aws total = %v, want 110", got["aws"])
+	}
+}
+
+func TestReconcileThresholds(t *testing.T) {
+	ctx := context.Background()
+	src := &fakeSource{daily: map[string]float64{"aws": 100, "gcp": 1}}
+	ing := New(src, newStore(t), Config{LookbackDays: 3, ReconcileDays: 3, Threshold: 1, ThresholdPercent: 5})
+	ing.Run(ctx, today)
+
+	// aws's 3% and gcp's 50 cents are both under a threshold
+	src.daily = map[string]float64{"aws": 103, "gcp": 1.5}
+	for _, res := range ing.Reconcile(ctx, today) {
+		if res.Err != nil || len(res.Changes) != 0 || res.Minor != 3 {
+			t.Errorf("%s = %+v, want 3 minor changes", res.Provider, res)
+		}
+	}
+}
+
+func TestReconcileSkips(t *testing.T) {
+	ctx := context.Background()
+	st := newStore(t)
+	src := &fakeSource{daily: map[string]float64{"aws": 10, "gcp": 1}}
+	ing := New(src, st, Config{LookbackDays: 3, ReconcileDays: 30})
+
+	// Providers never ingested are not fetched
+	for _, res := range ing.Reconcile(ctx, today) {
+		if res.Err != nil || !res.Start.IsZero() || res.Records != 0 {
+			t.Errorf("%s = %+v, want it skipped", res.Provider, res)
+		}
+	}
+	if len(src.fetches) != 0 {
+		t.Errorf("fetches = %+v, want none", src.fetches)
+	}
+
+	// The window starts no earlier than the first ingested day, and a
+	// failing provider does not stop the others
+	ing.Run(ctx, today)
+	src.fetches = nil
+	src.errs = map[string]error{"aws": errors.New("throttled")}
+	results := ing.Reconcile(ctx, today)
+	if results[0].Err == nil || results[0].Error != "throttled" || results[1].Err != nil {
+		t.Errorf("results = %+v, want only aws to fail", results)
+	}
+	if len(src.fetches) != 2 || !src.fetches[1].start.Equal(today.AddDate(0, 0, -3)) {
+		t.Errorf("fetches = %+v, want gcp from Mar 17", src.fetches)
+	}
+}
+
+func TestReconcileAfterRollup(t *testing.T) {
+	ctx := context.Background()
+	st := newStore(t)
+	src := &fakeSource{daily: map[string]float64{"aws": 10, "gcp": 1}}
+	ing := New(src, st, Config{LookbackDays: 30, ReconcileDays: 30})
+	ing.Run(ctx, today)
+	if _, err := st.Prune(ctx, store.RetentionPolicy{DailyDays: 10}, today); err != nil {
+		t.Fatal(err)
+	}
+
+	// February is rolled up, so only March is re-fetched
+	src.fetches = nil
+	for _, res := range ing.Reconcile(ctx, today) {
+		if res.Err != nil || !res.Start.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)) || res.Records != 19 {
+			t.Errorf("%s = %+v, want 19 records from Mar 1", res.Provider, res)
+		}
+	}
+}
+
+func TestSaveRestatements(t *testing.T) {
+	results := []Restatement{
+		{Provider: "aws", Changes: []Change{
+			{Date: today, Provider: "aws", Cloud: "aws", Service: "EC2", Stored: 10, Restated: 12.5, Delta: 2.5, DeltaPercent: 25},
+		}},
+		{Provider: "gcp", Err: errors.New("throttled"), Error: "throttled"},
+	}
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "restatements.csv")
+	if err := SaveCSV(path, results); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"Date", "Provider", "Cloud", "Service", "Stored", "Restated", "Delta", "Delta %"},
+		{"2024-03-20", "aws", "aws", "EC2", "10.00", "12.50", "2.50", "25.0"},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("CSV = %q, want %q", rows, want)
+	}
+
+	path = filepath.Join(dir, "restatements.json")
+	if err := SaveJSON(path, results); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded []Restatement
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 2 || !reflect.DeepEqual(decoded[0].Changes, results[0].Changes) || decoded[1].Error != "throttled" {
+		t.Errorf("JSON = %+v, want %+v", decoded, results)
+	}
+}
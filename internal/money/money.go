@@ -0,0 +1,97 @@
+// Package money does cost arithmetic in fixed point, so that sums and splits
+// of many charges come out to the amounts finance reconciles against cloud
+// invoices instead of drifting by the rounding error of each float64 step.
+package money
+
+import "math"
+
+// Micros is an amount of money in millionths of a currency unit, exact for
+// amounts up to a few billion
+type Micros int64
+
+// Scale is the number of Micros in one currency unit
+const Scale = 1_000_000
+
+// FromFloat returns the amount nearest to f
+func FromFloat(f float64) Micros {
+	return Micros(math.Round(f * Scale))
+}
+
+// Float returns the amount as a float64, the nearest to its exact value
+func (a Micros) Float() float64 {
+	return float64(a) / Scale
+}
+
+// Mul returns the amount times f, rounded to the nearest unit
+func (a Micros) Mul(f float64) Micros {
+	return Micros(math.Round(float64(a) * f))
+}
+
+// Add returns a + b. Costs kept as float64 are summed with Add so that each
+// running total is the nearest float64 to an exact decimal amount, however
+// many charges it adds up.
+func Add(a, b float64) float64 {
+	return (FromFloat(a) + FromFloat(b)).Float()
+}
+
+// Sub returns a - b, as Add
+func Sub(a, b float64) float64 {
+	return (FromFloat(a) - FromFloat(b)).Float()
+}
+
+// Mul returns amount times f, rounded like Micros
+func Mul(amount, f float64) float64 {
+	return FromFloat(amount).Mul(f).Float()
+}
+
+// Round returns the float64 nearest to f's exact Micros
+func Round(f float64) float64 {
+	return FromFloat(f).Float()
+}
+
+// Split divides total in proportion to weights. The parts add up to total
+// exactly: each is rounded, and the rounding remainder goes to the part of
+// the largest weight (the first of equals). Weights summing to zero leave
+// every part zero.
+func Split(total float64, weights []float64) []float64 {
+	parts := make([]float64, len(weights))
+	var sum float64
+	largest := -1
+	for i, w := range weights {
+		sum += w
+		if largest < 0 || math.Abs(w) > math.Abs(weights[largest]) {
+			largest = i
+		}
+	}
+	if sum == 0 {
+		return parts
+	}
+
+	t := FromFloat(total)
+	var allocated Micros
+	amounts := make([]Micros, len(weights))
+	for i, w := range weights {
+		amounts[i] = t.Mul(w / sum)
+		allocated += amounts[i]
+	}
+	amounts[largest] += t - allocated
+	for i, a := range amounts {
+		parts[i] = a.Float()
+	}
+	return parts
+}
+
+// Totals sums amounts by key in fixed point
+type Totals map[string]Micros
+
+// Add adds cost to the total of key
+func (t Totals) Add(key string, cost float64) {
+	t[key] += FromFloat(cost)
+}
+
+// Floats writes the totals into dst as float64
+func (t Totals) Floats(dst map[string]float64) {
+	for key, total := range t {
+		dst[key] = total.Float()
+	}
+}
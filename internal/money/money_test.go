@@ -0,0 +1,49 @@
+package money
+
+import "testing"
+
+func TestTotalsAddExactly(t *testing.T) {
+	totals := make(Totals)
+	var float float64
+	for i := 0; i < 10000; i++ {
+		totals.Add("a", 0.001)
+		float += 0.001
+	}
+	if float == 10 {
+		t.Fatal("float64 sum is exact; the test no longer shows drift")
+	}
+	got := make(map[string]float64)
+	totals.Floats(got)
+	if got["a"] != 10 {
+		t.Errorf("total = %v, want 10", got["a"])
+	}
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name    string
+		total   float64
+		weights []float64
+		want    []float64
+	}{
+		{"thirds", 100, []float64{1, 1, 1}, []float64{33.333334, 33.333333, 33.333333}},
+		{"weighted", 10, []float64{3, 1}, []float64{7.5, 2.5}},
+		{"remainder to largest", 1, []float64{1, 2}, []float64{0.333333, 0.666667}},
+		{"zero weights", 5, []float64{0, 0}, []float64{0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Split(tt.total, tt.weights)
+			var sum Micros
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("part %d = %v, want %v", i, got[i], tt.want[i])
+				}
+				sum += FromFloat(got[i])
+			}
+			if tt.name != "zero weights" && sum != FromFloat(tt.total) {
+				t.Errorf("parts sum to %v, want %v", sum.Float(), tt.total)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package normalizer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Adjustment scales cost to reflect a negotiated discount or known billing
+// adjustment that the provider's raw data does not show yet
+type Adjustment struct {
+	Name       string  `json:"name"`
+	Cloud      string  `json:"cloud"`   // empty applies to every cloud
+	Service    string  `json:"service"` // raw or normalized service name, empty for all services
+	Multiplier float64 `json:"multiplier"`
+}
+
+// Validate reports multipliers outside (0, 2), which are almost always a
+// configuration mistake such as a percentage entered as a multiplier
+func (a Adjustment) Validate() error {
+	if a.Multiplier <= 0 || a.Multiplier >= 2 {
+		return fmt.Errorf("adjustment %q has unusual multiplier %.4f (expected 0 < x < 2)", a.Name, a.Multiplier)
+	}
+	return nil
+}
+
+func (a Adjustment) matches(cloud, service string) bool {
+	if a.Cloud != "" && !strings.EqualFold(a.Cloud, cloud) {
+		return false
+	}
+	if a.Service == "" {
+		return true
+	}
+	return strings.EqualFold(a.Service, service) || strings.EqualFold(a.Service, NormalizeService(cloud, service))
+}
+
+// FindAdjustment returns the adjustment for a cloud and raw service name.
+// A service-specific adjustment takes precedence over a cloud-wide one.
+func FindAdjustment(adjustments []Adjustment, cloud, service string) (Adjustment, bool) {
+	var fallback Adjustment
+	found := false
+	for _, a := range adjustments {
+		if !a.matches(cloud, service) {
+			continue
+		}
+		if a.Service != "" {
+			return a, true
+		}
+		if !found {
+			fallback = a
+			found = true
+		}
+	}
+	return fallback, found
+}
@@ -0,0 +1,42 @@
+package normalizer
+
+import "testing"
+
+func TestFindAdjustment(t *testing.T) {
+	adjustments := []Adjustment{
+		{Name: "aws-edp", Cloud: "aws", Multiplier: 0.9},
+		{Name: "compute-discount", Cloud: "AWS", Service: "Compute", Multiplier: 0.8},
+		{Name: "everything", Multiplier: 0.95},
+	}
+	tests := []struct {
+		cloud, service string
+		want           string
+	}{
+		{"aws", "Amazon Elastic Compute Cloud - Compute", "compute-discount"},
+		{"aws", "compute", "compute-discount"},
+		{"aws", "Amazon Simple Storage Service", "aws-edp"},
+		{"gcp", "Compute Engine", "everything"},
+	}
+	for _, tt := range tests {
+		adj, ok := FindAdjustment(adjustments, tt.cloud, tt.service)
+		if !ok || adj.Name != tt.want {
+			t.Errorf("FindAdjustment(%s, %s) = %q, %v; want %q", tt.cloud, tt.service, adj.Name, ok, tt.want)
+		}
+	}
+	if adj, ok := FindAdjustment(adjustments[:2], "azure", "Storage"); ok {
+		t.Errorf("FindAdjustment(azure, Storage) = %q, want none", adj.Name)
+	}
+}
+
+func TestAdjustmentValidate(t *testing.T) {
+	for _, m := range []float64{0.85, 1.1} {
+		if err := (Adjustment{Name: "ok", Multiplier: m}).Validate(); err != nil {
+			t.Errorf("multiplier %v: %v", m, err)
+		}
+	}
+	for _, m := range []float64{0, -0.1, 2, 85} {
+		if err := (Adjustment{Name: "bad", Multiplier: m}).Validate(); err == nil {
+			t.Errorf("multiplier %v accepted", m)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package normalizer_test
+
+import (
+	"testing"
+
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+	"github.com/lvonguyen/finops-platform/internal/synthetic"
+)
+
+func BenchmarkSummarize(b *testing.B) {
+	for _, scale := range synthetic.Scales {
+		b.Run(scale.Name, func(b *testing.B) {
+			records := synthetic.Records(synthetic.Cached(scale.Records))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				normalizer.Summarize(records)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package normalizer
+
+import "strings"
+
+// ChargeType classifies what a cost line represents
+type ChargeType string
+
+const (
+	ChargeUsage  ChargeType = "usage"
+	ChargeCredit ChargeType = "credit" // promotional credits and negotiated discounts
+	ChargeRefund ChargeType = "refund"
+	ChargeTax    ChargeType = "tax"
+	ChargeFee    ChargeType = "fee" // purchases, commitment and support fees
+)
+
+// ChargeTypes lists the charge types in display order
+var ChargeTypes = []ChargeType{ChargeUsage, ChargeCredit, ChargeRefund, ChargeTax, ChargeFee}
+
+// chargeTypes maps provider line item types, lower-cased, to charge types:
+// AWS CUR line item types, Azure charge types, GCP cost types and FOCUS
+// charge categories
+var chargeTypes = map[string]ChargeType{
+	"usage":                   ChargeUsage,
+	"discountedusage":         ChargeUsage,
+	"savingsplancoveredusage": ChargeUsage,
+	"savingsplannegation":     ChargeUsage,
+	"regular":                 ChargeUsage,
+	"rounding error":          ChargeUsage,
+	"roundingadjustment":      ChargeUsage,
+	"credit":                  ChargeCredit,
+	"edpdiscount":             ChargeCredit,
+	"bundleddiscount":         ChargeCredit,
+	"privateratediscount":     ChargeCredit,
+	"discount":                ChargeCredit,
+	"refund":                  ChargeRefund,
+	"adjustment":              ChargeRefund,
+	"tax":                     ChargeTax,
+	"fee":                     ChargeFee,
+	"rifee":                   ChargeFee,
+	"savingsplanrecurringfee": ChargeFee,
+	"savingsplanupfrontfee":   ChargeFee,
+	"purchase":                ChargeFee,
+	"unusedreservation":       ChargeFee,
+	"unusedsavingsplan":       ChargeFee,
+}
+
+// ParseChargeType maps a provider's line item type to a charge type. Empty
+// types stay empty; types it does not know are usage.
+func ParseChargeType(lineItemType string) ChargeType {
+	t := strings.ToLower(strings.TrimSpace(lineItemType))
+	if t == "" {
+		return ""
+	}
+	if ct, ok := chargeTypes[t]; ok {
+		return ct
+	}
+	return ChargeUsage
+}
+
+// Valid reports whether t is one of ChargeTypes
+func (t ChargeType) Valid() bool {
+	for _, ct := range ChargeTypes {
+		if t == ct {
+			return true
+		}
+	}
+	return false
+}
+
+// Charge returns the record's charge type. Records without one are usage,
+// or credits when their cost is negative.
+func (r CostRecord) Charge() ChargeType {
+	if r.ChargeType != "" {
+		return r.ChargeType
+	}
+	if r.Cost < 0 {
+		return ChargeCredit
+	}
+	return ChargeUsage
+}
+
+// IsCredit reports whether the record is a credit. Records without an
+// explicit charge type are treated as credits when their cost is negative.
+func (r CostRecord) IsCredit() bool {
+	return r.Charge() == ChargeCredit
+}
+
+// focusChargeCategories are the FOCUS ChargeCategory values of charge types
+var focusChargeCategories = map[ChargeType]string{
+	ChargeUsage:  "Usage",
+	ChargeCredit: "Credit",
+	ChargeRefund: "Adjustment",
+	ChargeTax:    "Tax",
+	ChargeFee:    "Purchase",
+}
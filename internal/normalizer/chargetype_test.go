@@ -0,0 +1,42 @@
+package normalizer
+
+import "testing"
+
+func TestParseChargeType(t *testing.T) {
+	tests := []struct {
+		lineItemType string
+		want         ChargeType
+	}{
+		{"", ""},
+		{"Usage", ChargeUsage},
+		{"SavingsPlanCoveredUsage", ChargeUsage},
+		{" EdpDiscount ", ChargeCredit},
+		{"Credit", ChargeCredit},
+		{"Refund", ChargeRefund},
+		{"Tax", ChargeTax},
+		{"RIFee", ChargeFee},
+		{"SomethingNew", ChargeUsage},
+	}
+	for _, tt := range tests {
+		if got := ParseChargeType(tt.lineItemType); got != tt.want {
+			t.Errorf("ParseChargeType(%q) = %q, want %q", tt.lineItemType, got, tt.want)
+		}
+	}
+}
+
+func TestIsCredit(t *testing.T) {
+	tests := []struct {
+		record CostRecord
+		want   bool
+	}{
+		{CostRecord{Cost: -5}, true},
+		{CostRecord{Cost: 5}, false},
+		{CostRecord{Cost: -5, ChargeType: ChargeRefund}, false},
+		{CostRecord{Cost: 5, ChargeType: ChargeCredit}, true},
+	}
+	for _, tt := range tests {
+		if got := tt.record.IsCredit(); got != tt.want {
+			t.Errorf("%+v IsCredit() = %v, want %v", tt.record, got, tt.want)
+		}
+	}
+}
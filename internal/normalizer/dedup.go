@@ -0,0 +1,53 @@
+package normalizer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// Fingerprint identifies the charge a record reports, regardless of its
+// amount: the cloud, account, region, service, resource, day, usage type,
+// charge type and commitment. Two sources reporting the same fingerprint
+// report the same spend, e.g. a FOCUS export overlapping a billing API.
+func Fingerprint(r CostRecord) string {
+	h := sha256.Sum256([]byte(strings.Join([]string{
+		r.Cloud,
+		r.Account,
+		r.Region,
+		r.Service,
+		r.Resource,
+		r.Date.UTC().Format("2006-01-02"),
+		r.CloudServiceType,
+		string(r.ChargeType),
+		r.LineItemType,
+		r.PricingModel,
+		r.CommitmentDiscountID,
+	}, "\x00")))
+	return hex.EncodeToString(h[:16])
+}
+
+// Deduper drops records that an earlier source already reported. Records
+// sharing a fingerprint within one source are distinct line items and are
+// all kept; sources are expected to be added one after another, the
+// preferred first.
+type Deduper struct {
+	seen map[string]string // fingerprint -> first source
+}
+
+// NewDeduper creates an empty deduper
+func NewDeduper() *Deduper {
+	return &Deduper{seen: make(map[string]string)}
+}
+
+// Duplicate reports whether another source already reported the record,
+// remembering it for later sources otherwise
+func (d *Deduper) Duplicate(source string, r CostRecord) bool {
+	fp := Fingerprint(r)
+	first, ok := d.seen[fp]
+	if !ok {
+		d.seen[fp] = source
+		return false
+	}
+	return first != source
+}
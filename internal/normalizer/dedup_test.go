@@ -0,0 +1,56 @@
+package normalizer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFingerprint(t *testing.T) {
+	base := CostRecord{
+		Cloud: "aws", Account: "111", Region: "us-east-1", Service: "EC2", Resource: "i-1",
+		Date: time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC), Cost: 10, LineItemType: "Usage",
+	}
+	same := base
+	same.ID = "other-source"
+	same.Cost = 12
+	same.Date = base.Date.Add(6 * time.Hour).In(time.FixedZone("EST", -5*3600))
+	same.Tags = map[string]string{"team": "web"}
+	if Fingerprint(same) != Fingerprint(base) {
+		t.Error("records of the same charge with different amounts, IDs and tags have different fingerprints")
+	}
+
+	for name, change := range map[string]func(*CostRecord){
+		"account":    func(r *CostRecord) { r.Account = "222" },
+		"resource":   func(r *CostRecord) { r.Resource = "i-2" },
+		"day":        func(r *CostRecord) { r.Date = r.Date.AddDate(0, 0, 1) },
+		"usage type": func(r *CostRecord) { r.CloudServiceType = "BoxUsage" },
+		"charge":     func(r *CostRecord) { r.ChargeType = ChargeCredit },
+		"commitment": func(r *CostRecord) { r.CommitmentDiscountID = "sp-1" },
+	} {
+		r := base
+		change(&r)
+		if Fingerprint(r) == Fingerprint(base) {
+			t.Errorf("records differing by %s have the same fingerprint", name)
+		}
+	}
+}
+
+func TestDeduper(t *testing.T) {
+	r := CostRecord{Cloud: "aws", Account: "111", Service: "EC2", Date: time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)}
+	d := NewDeduper()
+	tests := []struct {
+		source string
+		want   bool
+	}{
+		{"aws-cur", false},
+		{"aws-cur", false}, // a second line item of the same source
+		{"aws", true},
+		{"aws", true},
+		{"aws-cur", false},
+	}
+	for i, tt := range tests {
+		if got := d.Duplicate(tt.source, r); got != tt.want {
+			t.Errorf("record %d from %s Duplicate() = %v, want %v", i, tt.source, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,539 @@
+package normalizer
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apache/arrow-go/v18/parquet"
+
+	"github.com/lvonguyen/finops-platform/internal/columnar"
+)
+
+// FOCUS 1.0 column names (FinOps Open Cost and Usage Specification)
+const (
+	FOCUSBilledCost                 = "BilledCost"
+	FOCUSBillingAccountID           = "BillingAccountId"
+	FOCUSBillingAccountName         = "BillingAccountName"
+	FOCUSBillingCurrency            = "BillingCurrency"
+	FOCUSBillingPeriodEnd           = "BillingPeriodEnd"
+	FOCUSBillingPeriodStart         = "BillingPeriodStart"
+	FOCUSChargeCategory             = "ChargeCategory"
+	FOCUSChargeClass                = "ChargeClass"
+	FOCUSChargeDescription          = "ChargeDescription"
+	FOCUSChargeFrequency            = "ChargeFrequency"
+	FOCUSChargePeriodEnd            = "ChargePeriodEnd"
+	FOCUSChargePeriodStart          = "ChargePeriodStart"
+	FOCUSCommitmentDiscountCategory = "CommitmentDiscountCategory"
+	FOCUSCommitmentDiscountID       = "CommitmentDiscountId"
+	FOCUSCommitmentDiscountName     = "CommitmentDiscountName"
+	FOCUSCommitmentDiscountStatus   = "CommitmentDiscountStatus"
+	FOCUSCommitmentDiscountType     = "CommitmentDiscountType"
+	FOCUSConsumedQuantity           = "ConsumedQuantity"
+	FOCUSConsumedUnit               = "ConsumedUnit"
+	FOCUSContractedCost             = "ContractedCost"
+	FOCUSContractedUnitPrice        = "ContractedUnitPrice"
+	FOCUSEffectiveCost              = "EffectiveCost"
+	FOCUSInvoiceIssuerName          = "InvoiceIssuerName"
+	FOCUSListCost                   = "ListCost"
+	FOCUSListUnitPrice              = "ListUnitPrice"
+	FOCUSPricingCategory            = "PricingCategory"
+	FOCUSPricingQuantity            = "PricingQuantity"
+	FOCUSPricingUnit                = "PricingUnit"
+	FOCUSProviderName               = "ProviderName"
+	FOCUSPublisherName              = "PublisherName"
+	FOCUSRegionID                   = "RegionId"
+	FOCUSRegionName                 = "RegionName"
+	FOCUSResourceID                 = "ResourceId"
+	FOCUSResourceName               = "ResourceName"
+	FOCUSResourceType               = "ResourceType"
+	FOCUSServiceCategory            = "ServiceCategory"
+	FOCUSServiceName                = "ServiceName"
+	FOCUSSkuID                      = "SkuId"
+	FOCUSSkuPriceID                 = "SkuPriceId"
+	FOCUSSubAccountID               = "SubAccountId"
+	FOCUSSubAccountName             = "SubAccountName"
+	FOCUSTags                       = "Tags"
+	FOCUSAvailabilityZone           = "AvailabilityZone"
+)
+
+// focusRequiredColumns must be present for a file to be imported
+var focusRequiredColumns = []string{
+	FOCUSBilledCost,
+	FOCUSBillingCurrency,
+	FOCUSChargePeriodStart,
+	FOCUSChargePeriodEnd,
+	FOCUSProviderName,
+	FOCUSServiceName,
+}
+
+// focusMappedColumns are the columns that populate CostRecord fields
+var focusMappedColumns = map[string]bool{
+	FOCUSBilledCost:                 true,
+	FOCUSBillingAccountID:           true,
+	FOCUSBillingCurrency:            true,
+	FOCUSChargeCategory:             true,
+	FOCUSChargePeriodEnd:            true,
+	FOCUSChargePeriodStart:          true,
+	FOCUSCommitmentDiscountCategory: true,
+	FOCUSCommitmentDiscountID:       true,
+	FOCUSCommitmentDiscountName:     true,
+	FOCUSCommitmentDiscountType:     true,
+	FOCUSConsumedQuantity:           true,
+	FOCUSConsumedUnit:               true,
+	FOCUSEffectiveCost:              true,
+	FOCUSPricingCategory:            true,
+	FOCUSProviderName:               true,
+	FOCUSRegionID:                   true,
+	FOCUSRegionName:                 true,
+	FOCUSResourceID:                 true,
+	FOCUSResourceType:               true,
+	FOCUSServiceName:                true,
+	FOCUSSubAccountID:               true,
+	FOCUSTags:                       true,
+}
+
+// FOCUSImport holds the result of reading a FOCUS file
+type FOCUSImport struct {
+	Records         []CostRecord `json:"records"`
+	UnmappedColumns []string     `json:"unmapped_columns"` // present in the file but not carried into CostRecord
+}
+
+// ValidateFOCUSHeader checks a header row against the FOCUS schema and
+// returns the columns that will not be mapped into CostRecord.
+func ValidateFOCUSHeader(header []string) ([]string, error) {
+	present := make(map[string]bool, len(header))
+	for _, col := range header {
+		present[strings.TrimSpace(col)] = true
+	}
+
+	var missing []string
+	for _, col := range focusRequiredColumns {
+		if !present[col] {
+			missing = append(missing, col)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("not a FOCUS 1.0 file: missing required columns %s", strings.Join(missing, ", "))
+	}
+
+	var unmapped []string
+	for col := range present {
+		if !focusMappedColumns[col] {
+			unmapped = append(unmapped, col)
+		}
+	}
+	sort.Strings(unmapped)
+
+	return unmapped, nil
+}
+
+// ReadFOCUSCSV parses a FOCUS 1.0 CSV export into normalized cost records
+func ReadFOCUSCSV(r io.Reader) (*FOCUSImport, error) {
+	reader := csv.NewReader(r)
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read FOCUS header: %w", err)
+	}
+	header = append([]string(nil), header...)
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+
+	fr, err := newFOCUSReader(header)
+	if err != nil {
+		return nil, err
+	}
+	line := 1
+
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if err := fr.add(row); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+
+	return fr.result, nil
+}
+
+// ReadFOCUSParquet parses a FOCUS 1.0 Parquet export into normalized cost
+// records
+func ReadFOCUSParquet(ctx context.Context, r parquet.ReaderAtSeeker) (*FOCUSImport, error) {
+	var fr *focusReader
+	n := 0
+	err := columnar.ReadRows(ctx, r, func(header, row []string) error {
+		if fr == nil {
+			var err error
+			if fr, err = newFOCUSReader(header); err != nil {
+				return err
+			}
+		}
+		n++
+		if err := fr.add(row); err != nil {
+			return fmt.Errorf("row %d: %w", n, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if fr == nil {
+		// No rows: still check the file is FOCUS
+		header, err := columnar.Header(r)
+		if err != nil {
+			return nil, err
+		}
+		if fr, err = newFOCUSReader(header); err != nil {
+			return nil, err
+		}
+	}
+	return fr.result, nil
+}
+
+// focusReader maps the rows of a FOCUS file by column name
+type focusReader struct {
+	index  map[string]int
+	result *FOCUSImport
+}
+
+// newFOCUSReader validates a header and indexes its columns
+func newFOCUSReader(header []string) (*focusReader, error) {
+	unmapped, err := ValidateFOCUSHeader(header)
+	if err != nil {
+		return nil, err
+	}
+
+	index := make(map[string]int, len(header))
+	for i, col := range header {
+		index[strings.TrimSpace(col)] = i
+	}
+	return &focusReader{index: index, result: &FOCUSImport{UnmappedColumns: unmapped}}, nil
+}
+
+// add maps a row and appends its record
+func (fr *focusReader) add(row []string) error {
+	get := func(col string) string {
+		if i, ok := fr.index[col]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	record, err := focusRowToRecord(get)
+	if err != nil {
+		return err
+	}
+	fr.result.Records = append(fr.result.Records, record)
+	return nil
+}
+
+// focusRowToRecord maps a single FOCUS row into a CostRecord
+func focusRowToRecord(get func(string) string) (CostRecord, error) {
+	var record CostRecord
+
+	cost, err := parseFOCUSNumber(get(FOCUSBilledCost))
+	if err != nil {
+		return record, fmt.Errorf("invalid %s: %w", FOCUSBilledCost, err)
+	}
+
+	currency := strings.ToUpper(get(FOCUSBillingCurrency))
+	if currency == "" {
+		return record, fmt.Errorf("missing %s", FOCUSBillingCurrency)
+	}
+
+	start, err := parseFOCUSTime(get(FOCUSChargePeriodStart))
+	if err != nil {
+		return record, fmt.Errorf("invalid %s: %w", FOCUSChargePeriodStart, err)
+	}
+	end, err := parseFOCUSTime(get(FOCUSChargePeriodEnd))
+	if err != nil {
+		return record, fmt.Errorf("invalid %s: %w", FOCUSChargePeriodEnd, err)
+	}
+
+	effective, err := parseFOCUSNumber(get(FOCUSEffectiveCost))
+	if err != nil {
+		return record, fmt.Errorf("invalid %s: %w", FOCUSEffectiveCost, err)
+	}
+
+	quantity, err := parseFOCUSNumber(get(FOCUSConsumedQuantity))
+	if err != nil {
+		return record, fmt.Errorf("invalid %s: %w", FOCUSConsumedQuantity, err)
+	}
+
+	tags := make(map[string]string)
+	if raw := get(FOCUSTags); raw != "" {
+		var parsed map[string]interface{}
+		if err := json.Unmarshal([]byte(raw), &parsed); err != nil {
+			return record, fmt.Errorf("invalid %s: %w", FOCUSTags, err)
+		}
+		for k, v := range parsed {
+			tags[k] = fmt.Sprint(v)
+		}
+	}
+
+	cloud := focusCloud(get(FOCUSProviderName))
+
+	account := get(FOCUSSubAccountID)
+	if account == "" {
+		account = get(FOCUSBillingAccountID)
+	}
+
+	region := get(FOCUSRegionID)
+	if region == "" {
+		region = get(FOCUSRegionName)
+	}
+
+	serviceName := get(FOCUSServiceName)
+
+	return CostRecord{
+		Cloud:            cloud,
+		Account:          account,
+		Region:           region,
+		Service:          NormalizeService(cloud, serviceName),
+		Resource:         get(FOCUSResourceID),
+		Cost:             cost,
+		Currency:         currency,
+		UsageQuantity:    quantity,
+		UsageUnit:        get(FOCUSConsumedUnit),
+		PricingModel:     focusPricingModel(get(FOCUSPricingCategory), get(FOCUSCommitmentDiscountType)),
+		ChargeType:       ParseChargeType(get(FOCUSChargeCategory)),
+		LineItemType:     get(FOCUSChargeCategory),
+		EffectiveCost:    effective,
+		Date:             time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC),
+		StartTime:        start,
+		EndTime:          end,
+		Tags:             tags,
+		CloudService:     serviceName,
+		CloudServiceType: get(FOCUSResourceType),
+
+		CommitmentDiscountID:       get(FOCUSCommitmentDiscountID),
+		CommitmentDiscountCategory: get(FOCUSCommitmentDiscountCategory),
+		CommitmentDiscountType:     get(FOCUSCommitmentDiscountType),
+		CommitmentDiscountName:     get(FOCUSCommitmentDiscountName),
+	}, nil
+}
+
+// focusCloud maps a FOCUS ProviderName to the short cloud identifier
+func focusCloud(provider string) string {
+	switch strings.ToLower(provider) {
+	case "aws", "amazon web services":
+		return "aws"
+	case "azure", "microsoft", "microsoft azure":
+		return "azure"
+	case "gcp", "google", "google cloud":
+		return "gcp"
+	case "oci", "oracle", "oracle cloud infrastructure":
+		return "oci"
+	}
+	return strings.ToLower(provider)
+}
+
+// focusPricingModel maps FOCUS pricing columns to CostRecord.PricingModel
+func focusPricingModel(category, commitmentType string) string {
+	switch category {
+	case "Dynamic":
+		return "spot"
+	case "Committed":
+		if strings.Contains(strings.ToLower(commitmentType), "savings") {
+			return "savings_plan"
+		}
+		return "reserved"
+	case "Standard", "":
+		return "on_demand"
+	}
+	return strings.ToLower(category)
+}
+
+func parseFOCUSNumber(s string) (float64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+func parseFOCUSTime(s string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized timestamp %q", s)
+}
+
+// Effective returns the record's amortized cost. Records with a commitment
+// discount always use EffectiveCost, since covered usage and commitment
+// purchases can legitimately amortize to zero; otherwise an unset
+// EffectiveCost means no discount and the billed cost applies.
+func (r CostRecord) Effective() float64 {
+	if r.EffectiveCost != 0 || r.CommitmentDiscountID != "" {
+		return r.EffectiveCost
+	}
+	return r.Cost
+}
+
+// FOCUSExportColumns are the columns WriteFOCUSCSV emits, in order
+var FOCUSExportColumns = []string{
+	FOCUSBilledCost,
+	FOCUSEffectiveCost,
+	FOCUSBillingCurrency,
+	FOCUSBillingPeriodStart,
+	FOCUSBillingPeriodEnd,
+	FOCUSChargeCategory,
+	FOCUSChargePeriodStart,
+	FOCUSChargePeriodEnd,
+	FOCUSProviderName,
+	FOCUSInvoiceIssuerName,
+	FOCUSSubAccountID,
+	FOCUSRegionID,
+	FOCUSServiceName,
+	FOCUSServiceCategory,
+	FOCUSResourceID,
+	FOCUSResourceType,
+	FOCUSConsumedQuantity,
+	FOCUSConsumedUnit,
+	FOCUSPricingCategory,
+	FOCUSCommitmentDiscountID,
+	FOCUSCommitmentDiscountCategory,
+	FOCUSCommitmentDiscountType,
+	FOCUSCommitmentDiscountName,
+	FOCUSTags,
+}
+
+// focusProviderNames are the FOCUS ProviderName values of known clouds
+var focusProviderNames = map[string]string{
+	"aws":   "AWS",
+	"azure": "Microsoft",
+	"gcp":   "Google Cloud",
+	"oci":   "Oracle",
+}
+
+// focusServiceCategories maps normalized service names to FOCUS service
+// categories; anything else is Other
+var focusServiceCategories = map[string]string{
+	"Compute":    "Compute",
+	"Serverless": "Compute",
+	"Database":   "Databases",
+	"Storage":    "Storage",
+	"Networking": "Networking",
+	"Monitoring": "Management and Governance",
+}
+
+// WriteFOCUSCSV writes cost records as a FOCUS 1.0 CSV that ReadFOCUSCSV,
+// and other FOCUS tooling, can read back
+func WriteFOCUSCSV(w io.Writer, records []CostRecord) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(FOCUSExportColumns); err != nil {
+		return err
+	}
+
+	for _, r := range records {
+		row, err := focusRecordToRow(r)
+		if err != nil {
+			return err
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// focusRecordToRow maps a CostRecord into a row of FOCUSExportColumns
+func focusRecordToRow(r CostRecord) ([]string, error) {
+	tags := ""
+	if len(r.Tags) > 0 {
+		data, err := json.Marshal(r.Tags)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode tags: %w", err)
+		}
+		tags = string(data)
+	}
+
+	start, end := r.StartTime, r.EndTime
+	if start.IsZero() {
+		start = r.Date
+	}
+	if end.IsZero() {
+		end = r.Date.AddDate(0, 0, 1)
+	}
+	period := time.Date(r.Date.Year(), r.Date.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	provider := focusProviderNames[r.Cloud]
+	if provider == "" {
+		provider = r.Cloud
+	}
+	service := r.CloudService
+	if service == "" {
+		service = r.Service
+	}
+	category := focusServiceCategories[r.Service]
+	if category == "" {
+		category = "Other"
+	}
+	charge := focusChargeCategories[r.Charge()]
+
+	return []string{
+		formatFOCUSNumber(r.Cost),
+		formatFOCUSNumber(r.Effective()),
+		r.Currency,
+		formatFOCUSTime(period),
+		formatFOCUSTime(period.AddDate(0, 1, 0)),
+		charge,
+		formatFOCUSTime(start),
+		formatFOCUSTime(end),
+		provider,
+		provider,
+		r.Account,
+		r.Region,
+		service,
+		category,
+		r.Resource,
+		r.CloudServiceType,
+		formatFOCUSNumber(r.UsageQuantity),
+		r.UsageUnit,
+		focusPricingCategory(r.PricingModel),
+		r.CommitmentDiscountID,
+		r.CommitmentDiscountCategory,
+		r.CommitmentDiscountType,
+		r.CommitmentDiscountName,
+		tags,
+	}, nil
+}
+
+// focusPricingCategory maps CostRecord.PricingModel to a FOCUS PricingCategory
+func focusPricingCategory(model string) string {
+	switch model {
+	case "on_demand":
+		return "Standard"
+	case "spot":
+		return "Dynamic"
+	case "reserved", "savings_plan":
+		return "Committed"
+	}
+	return ""
+}
+
+func formatFOCUSNumber(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func formatFOCUSTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,256 @@
+package normalizer
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+
+	"github.com/lvonguyen/finops-platform/internal/columnar"
+)
+
+// focusFixtureRecord is the record the row of testdata/focus.csv maps to
+var focusFixtureRecord = CostRecord{
+	Cloud:            "aws",
+	Account:          "111111111111",
+	Region:           "us-east-1",
+	Service:          "Compute",
+	Resource:         "i-0abc",
+	Cost:             12.5,
+	Currency:         "USD",
+	UsageQuantity:    24,
+	UsageUnit:        "Hours",
+	PricingModel:     "savings_plan",
+	ChargeType:       ChargeUsage,
+	LineItemType:     "Usage",
+	EffectiveCost:    8.75,
+	Date:             time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC),
+	StartTime:        time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC),
+	EndTime:          time.Date(2024, 1, 16, 0, 0, 0, 0, time.UTC),
+	Tags:             map[string]string{"team": "web", "cost_center": "42"},
+	CloudService:     "Amazon Elastic Compute Cloud - Compute",
+	CloudServiceType: "Instance",
+
+	CommitmentDiscountID:       "arn:aws:savingsplans::999999999999:savingsplan/sp-1",
+	CommitmentDiscountCategory: "Spend",
+	CommitmentDiscountType:     "Compute Savings Plan",
+	CommitmentDiscountName:     "compute-sp",
+}
+
+func readFOCUSFixture(t *testing.T) (header, row []string) {
+	t.Helper()
+	f, err := os.Open("testdata/focus.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rows[0], rows[1]
+}
+
+func TestReadFOCUSCSV(t *testing.T) {
+	f, err := os.Open("testdata/focus.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	result, err := ReadFOCUSCSV(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Records) != 1 {
+		t.Fatalf("got %d records, want 1", len(result.Records))
+	}
+	if !reflect.DeepEqual(result.Records[0], focusFixtureRecord) {
+		t.Errorf("got\n%+v\nwant\n%+v", result.Records[0], focusFixtureRecord)
+	}
+	if !reflect.DeepEqual(result.UnmappedColumns, []string{FOCUSSkuID}) {
+		t.Errorf("unmapped columns = %v, want [%s]", result.UnmappedColumns, FOCUSSkuID)
+	}
+}
+
+// TestReadFOCUSParquet reads the fixture row written as Parquet, with typed
+// numbers, timestamps and a tag map, and expects the same record
+func TestReadFOCUSParquet(t *testing.T) {
+	header, row := readFOCUSFixture(t)
+
+	fields := make([]arrow.Field, len(header))
+	for i, col := range header {
+		typ := arrow.DataType(arrow.BinaryTypes.String)
+		switch col {
+		case FOCUSBilledCost, FOCUSEffectiveCost, FOCUSConsumedQuantity:
+			typ = arrow.PrimitiveTypes.Float64
+		case FOCUSChargePeriodStart, FOCUSChargePeriodEnd:
+			typ = arrow.FixedWidthTypes.Timestamp_us
+		case FOCUSTags:
+			typ = arrow.MapOf(arrow.BinaryTypes.String, arrow.BinaryTypes.String)
+		}
+		fields[i] = arrow.Field{Name: col, Type: typ}
+	}
+	b := array.NewRecordBuilder(memory.DefaultAllocator, arrow.NewSchema(fields, nil))
+	defer b.Release()
+	for i, value := range row {
+		switch fb := b.Field(i).(type) {
+		case *array.StringBuilder:
+			fb.Append(value)
+		case *array.Float64Builder:
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fb.Append(f)
+		case *array.TimestampBuilder:
+			ts, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fb.Append(arrow.Timestamp(ts.UnixMicro()))
+		case *array.MapBuilder:
+			var tags map[string]any
+			if err := json.Unmarshal([]byte(value), &tags); err != nil {
+				t.Fatal(err)
+			}
+			fb.Append(true)
+			for k, v := range tags {
+				fb.KeyBuilder().(*array.StringBuilder).Append(k)
+				fb.ItemBuilder().(*array.StringBuilder).Append(fmt.Sprint(v))
+			}
+		}
+	}
+	rec := b.NewRecord()
+	defer rec.Release()
+	var buf bytes.Buffer
+	if err := columnar.WriteParquet(&buf, rec); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := ReadFOCUSParquet(context.Background(), bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Records) != 1 {
+		t.Fatalf("got %d records, want 1", len(result.Records))
+	}
+	if !reflect.DeepEqual(result.Records[0], focusFixtureRecord) {
+		t.Errorf("got\n%+v\nwant\n%+v", result.Records[0], focusFixtureRecord)
+	}
+}
+
+func TestReadFOCUSParquetRejectsOtherFiles(t *testing.T) {
+	b := array.NewRecordBuilder(memory.DefaultAllocator, arrow.NewSchema([]arrow.Field{{Name: "cost", Type: arrow.PrimitiveTypes.Float64}}, nil))
+	defer b.Release()
+	b.Field(0).(*array.Float64Builder).Append(1)
+	rec := b.NewRecord()
+	defer rec.Release()
+	var buf bytes.Buffer
+	if err := columnar.WriteParquet(&buf, rec); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := ReadFOCUSParquet(context.Background(), bytes.NewReader(buf.Bytes()))
+	if err == nil || !strings.Contains(err.Error(), "not a FOCUS 1.0 file") {
+		t.Fatalf("got %v, want a missing columns error", err)
+	}
+}
+
+// TestWriteFOCUSCSVRoundTrip writes the fixture record as FOCUS and expects
+// ReadFOCUSCSV to read it back unchanged
+func TestWriteFOCUSCSVRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteFOCUSCSV(&buf, []CostRecord{focusFixtureRecord}); err != nil {
+		t.Fatal(err)
+	}
+	result, err := ReadFOCUSCSV(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Records) != 1 {
+		t.Fatalf("got %d records, want 1", len(result.Records))
+	}
+	if !reflect.DeepEqual(result.Records[0], focusFixtureRecord) {
+		t.Errorf("got\n%+v\nwant\n%+v", result.Records[0], focusFixtureRecord)
+	}
+}
+
+func TestWriteFOCUSCSVDefaults(t *testing.T) {
+	r := CostRecord{
+		Cloud:   "gcp",
+		Service: "Database",
+		Cost:    4,
+		Date:    time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC),
+	}
+	var buf bytes.Buffer
+	if err := WriteFOCUSCSV(&buf, []CostRecord{r}); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]string)
+	for i, col := range rows[0] {
+		got[col] = rows[1][i]
+	}
+	want := map[string]string{
+		FOCUSBilledCost:         "4",
+		FOCUSEffectiveCost:      "4",
+		FOCUSBillingPeriodStart: "2024-02-01T00:00:00Z",
+		FOCUSBillingPeriodEnd:   "2024-03-01T00:00:00Z",
+		FOCUSChargePeriodStart:  "2024-02-29T00:00:00Z",
+		FOCUSChargePeriodEnd:    "2024-03-01T00:00:00Z",
+		FOCUSProviderName:       "Google Cloud",
+		FOCUSServiceName:        "Database",
+		FOCUSServiceCategory:    "Databases",
+		FOCUSPricingCategory:    "",
+		FOCUSTags:               "",
+	}
+	for col, v := range want {
+		if got[col] != v {
+			t.Errorf("%s = %q, want %q", col, got[col], v)
+		}
+	}
+}
+
+func TestEffective(t *testing.T) {
+	tests := []struct {
+		name string
+		r    CostRecord
+		want float64
+	}{
+		{"no discount", CostRecord{Cost: 10}, 10},
+		{"amortized", CostRecord{Cost: 10, EffectiveCost: 6}, 6},
+		{"fully covered", CostRecord{Cost: 10, CommitmentDiscountID: "sp-1"}, 0},
+	}
+	for _, tt := range tests {
+		if got := tt.r.Effective(); got != tt.want {
+			t.Errorf("%s: Effective() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFOCUSPricingCategory(t *testing.T) {
+	for _, model := range []string{"on_demand", "spot", "reserved"} {
+		category := focusPricingCategory(model)
+		if got := focusPricingModel(category, ""); got != model {
+			t.Errorf("%s -> %q -> %s, want %s", model, category, got, model)
+		}
+	}
+	if got := focusPricingModel(focusPricingCategory("savings_plan"), "Compute Savings Plan"); got != "savings_plan" {
+		t.Errorf("savings_plan round trip = %s", got)
+	}
+}
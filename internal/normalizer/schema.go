@@ -3,10 +3,14 @@ package normalizer
 
 import (
 	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/money"
 )
 
 // CostRecord represents a normalized cost record from any cloud provider
 type CostRecord struct {
+	SchemaVersion int `json:"schema_version"` // see SchemaVersion; 0 is read as 1
+
 	// Identification
 	ID       string `json:"id"`
 	Cloud    string `json:"cloud"`     // aws, azure, gcp
@@ -17,10 +21,26 @@ type CostRecord struct {
 
 	// Cost
 	Cost          float64 `json:"cost"`
+	RawCost       float64 `json:"raw_cost,omitempty"`   // provider-reported cost before adjustments
+	Adjustment    string  `json:"adjustment,omitempty"` // name of the adjustment applied, if any
 	Currency      string  `json:"currency"`       // USD
 	UsageQuantity float64 `json:"usage_quantity"`
 	UsageUnit     string  `json:"usage_unit"`
 	PricingModel  string  `json:"pricing_model"`  // on_demand, reserved, spot, savings_plan
+	ChargeType    ChargeType `json:"charge_type,omitempty"` // usage, credit, refund, tax, fee; empty when the provider does not say
+	LineItemType  string  `json:"line_item_type,omitempty"` // provider's own charge type, e.g. RIFee
+	EmissionsKg   float64 `json:"emissions_kg,omitempty"` // estimated kg CO2e, 0 when not estimated
+	EffectiveCost float64 `json:"effective_cost,omitempty"` // amortized cost after commitment discounts, see Effective
+
+	// Commitment discount (reservation, savings plan) applied to the charge
+	CommitmentDiscountID       string `json:"commitment_discount_id,omitempty"`
+	CommitmentDiscountCategory string `json:"commitment_discount_category,omitempty"` // Spend or Usage
+	CommitmentDiscountType     string `json:"commitment_discount_type,omitempty"`     // provider label, e.g. Savings Plan
+	CommitmentDiscountName     string `json:"commitment_discount_name,omitempty"`     // display name, e.g. the reservation's
+
+	// Billed amount, set when Cost was converted into the base currency
+	OriginalCost     float64 `json:"original_cost,omitempty"`
+	OriginalCurrency string  `json:"original_currency,omitempty"`
 
 	// Time
 	Date       time.Time `json:"date"`
@@ -71,6 +91,11 @@ func Summarize(records []CostRecord) CostSummary {
 		return summary
 	}
 
+	// Records are converted into one base currency before summarizing
+	if records[0].Currency != "" {
+		summary.Currency = records[0].Currency
+	}
+
 	// Track date range
 	summary.StartDate = records[0].Date
 	summary.EndDate = records[0].Date
@@ -79,17 +104,17 @@ func Summarize(records []CostRecord) CostSummary {
 	dailyMap := make(map[string]*DailyCost)
 
 	for _, r := range records {
-		summary.TotalCost += r.Cost
-		summary.ByCloud[r.Cloud] += r.Cost
-		summary.ByService[r.Service] += r.Cost
-		summary.ByAccount[r.Account] += r.Cost
-		summary.ByRegion[r.Region] += r.Cost
+		summary.TotalCost = money.Add(summary.TotalCost, r.Cost)
+		summary.ByCloud[r.Cloud] = money.Add(summary.ByCloud[r.Cloud], r.Cost)
+		summary.ByService[r.Service] = money.Add(summary.ByService[r.Service], r.Cost)
+		summary.ByAccount[r.Account] = money.Add(summary.ByAccount[r.Account], r.Cost)
+		summary.ByRegion[r.Region] = money.Add(summary.ByRegion[r.Region], r.Cost)
 
 		// Cost center from tags
 		if cc, ok := r.Tags["cost_center"]; ok {
-			summary.ByCostCenter[cc] += r.Cost
+			summary.ByCostCenter[cc] = money.Add(summary.ByCostCenter[cc], r.Cost)
 		} else {
-			summary.ByCostCenter["UNTAGGED"] += r.Cost
+			summary.ByCostCenter["UNTAGGED"] = money.Add(summary.ByCostCenter["UNTAGGED"], r.Cost)
 		}
 
 		// Track date range
@@ -108,8 +133,8 @@ func Summarize(records []CostRecord) CostSummary {
 				ByCloud: make(map[string]float64),
 			}
 		}
-		dailyMap[dateKey].Total += r.Cost
-		dailyMap[dateKey].ByCloud[r.Cloud] += r.Cost
+		dailyMap[dateKey].Total = money.Add(dailyMap[dateKey].Total, r.Cost)
+		dailyMap[dateKey].ByCloud[r.Cloud] = money.Add(dailyMap[dateKey].ByCloud[r.Cloud], r.Cost)
 	}
 
 	// Convert daily map to slice
@@ -146,6 +171,15 @@ var ServiceMapping = map[string]map[string]string{
 		"Virtual Private Cloud":     "Networking",
 		"Cloud Monitoring":          "Monitoring",
 	},
+	"oci": {
+		"Compute":                   "Compute",
+		"Database":                  "Database",
+		"Block Storage":             "Storage",
+		"Object Storage":            "Storage",
+		"Functions":                 "Serverless",
+		"Networking":                "Networking",
+		"Telemetry":                 "Monitoring",
+	},
 }
 
 // NormalizeService converts cloud-specific service names to normalized names
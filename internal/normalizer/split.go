@@ -0,0 +1,64 @@
+package normalizer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Split is one share of a cost divided across several owners
+type Split struct {
+	Key    string  `json:"key"`
+	Weight float64 `json:"weight"` // fraction of the cost, weights sum to 1
+}
+
+// ParseSplit parses a multi-valued tag such as "team-a:60,team-b:40" or
+// "team-a,team-b" into weighted shares. Values without explicit weights are
+// split evenly; explicit weights are normalized so they always sum to 1.
+func ParseSplit(value string) ([]Split, error) {
+	parts := strings.Split(value, ",")
+	splits := make([]Split, 0, len(parts))
+	weighted := 0
+
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		key, weightStr, hasWeight := strings.Cut(part, ":")
+		key = strings.TrimSpace(key)
+		if key == "" {
+			return nil, fmt.Errorf("empty key in split %q", value)
+		}
+
+		weight := 1.0
+		if hasWeight {
+			w, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(weightStr), "%"), 64)
+			if err != nil || w <= 0 {
+				return nil, fmt.Errorf("invalid weight %q in split %q", weightStr, value)
+			}
+			weight = w
+			weighted++
+		}
+
+		splits = append(splits, Split{Key: key, Weight: weight})
+	}
+
+	if len(splits) == 0 {
+		return nil, nil
+	}
+	if weighted > 0 && weighted != len(splits) {
+		return nil, fmt.Errorf("split %q mixes weighted and unweighted values", value)
+	}
+
+	var total float64
+	for _, s := range splits {
+		total += s.Weight
+	}
+	for i := range splits {
+		splits[i].Weight /= total
+	}
+
+	return splits, nil
+}
@@ -0,0 +1,34 @@
+package normalizer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSplit(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []Split
+		wantErr bool
+	}{
+		{"web", []Split{{"web", 1}}, false},
+		{"web, data", []Split{{"web", 0.5}, {"data", 0.5}}, false},
+		{"web:60,data:40", []Split{{"web", 0.6}, {"data", 0.4}}, false},
+		{"web:3%,data:1%", []Split{{"web", 0.75}, {"data", 0.25}}, false},
+		{" , ", nil, false},
+		{"web:60,data", nil, true},
+		{"web:0,data:1", nil, true},
+		{"web:x", nil, true},
+		{":50", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSplit(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseSplit(%q) error = %v, want error %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseSplit(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
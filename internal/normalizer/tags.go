@@ -0,0 +1,257 @@
+package normalizer
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lvonguyen/finops-platform/internal/money"
+)
+
+// TagOverflow replaces the values of a tag key past its distinct-value limit
+const TagOverflow = "OTHER"
+
+// Reasons a tag was capped
+const (
+	TagIgnored   = "ignored"   // key is on the ignore list and was dropped
+	TagTruncated = "truncated" // values longer than the length limit were cut
+	TagOverflows = "overflow"  // values past the distinct-value limit became TagOverflow
+)
+
+// TagLimits guard against free-form tags (timestamps, UUIDs) exploding cost
+// centers and breakdowns. Zero limits are unlimited.
+type TagLimits struct {
+	MaxValues int      // distinct values kept per key, largest by cost; the rest become TagOverflow
+	MaxLength int      // longer values are truncated to this many characters
+	Ignore    []string // keys dropped entirely; a trailing * matches a prefix
+}
+
+// TagCap reports a tag key the limits changed, so its tagging can be fixed
+type TagCap struct {
+	Key    string  `json:"key"`
+	Reason string  `json:"reason"` // ignored, truncated, or overflow
+	Values int     `json:"values"` // distinct original values affected
+	Cost   float64 `json:"cost"`   // cost of the records whose tag changed
+}
+
+// IsZero reports whether the limits change nothing
+func (l TagLimits) IsZero() bool {
+	return l.MaxValues <= 0 && l.MaxLength <= 0 && len(l.Ignore) == 0
+}
+
+// ignored reports whether a key is on the ignore list
+func (l TagLimits) ignored(key string) bool {
+	for _, pattern := range l.Ignore {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(key, prefix) {
+				return true
+			}
+		} else if key == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// truncate cuts a value to MaxLength characters
+func (l TagLimits) truncate(value string) (string, bool) {
+	if l.MaxLength <= 0 || utf8.RuneCountInString(value) <= l.MaxLength {
+		return value, false
+	}
+	return string([]rune(value)[:l.MaxLength]), true
+}
+
+// Apply bounds the tags of a set of charges, tags[i] costing costs[i]. Maps
+// that change are replaced rather than modified, since they may be shared.
+// Costs are untouched, so totals are the same before and after.
+func (l TagLimits) Apply(tags []map[string]string, costs []float64) []TagCap {
+	if l.IsZero() {
+		return nil
+	}
+
+	caps := newCapTracker()
+	record := caps.record
+	set := func(i int, key, value string, drop bool) {
+		copied := make(map[string]string, len(tags[i]))
+		for k, v := range tags[i] {
+			copied[k] = v
+		}
+		if drop {
+			delete(copied, key)
+		} else {
+			copied[key] = value
+		}
+		tags[i] = copied
+	}
+
+	// Drop ignored keys and truncate long values
+	valueCost := make(map[string]map[string]float64)
+	for i, t := range tags {
+		for key, value := range t {
+			if l.ignored(key) {
+				record(key, TagIgnored, value, costs[i])
+				set(i, key, "", true)
+				continue
+			}
+			if short, cut := l.truncate(value); cut {
+				record(key, TagTruncated, value, costs[i])
+				set(i, key, short, false)
+				value = short
+			}
+			if valueCost[key] == nil {
+				valueCost[key] = make(map[string]float64)
+			}
+			valueCost[key][value] += costs[i]
+		}
+	}
+
+	// Keep each key's largest values by cost and fold the rest
+	if l.MaxValues > 0 {
+		kept := make(map[string]map[string]bool)
+		for key, values := range valueCost {
+			if len(values) <= l.MaxValues {
+				continue
+			}
+			ranked := make([]string, 0, len(values))
+			for v := range values {
+				ranked = append(ranked, v)
+			}
+			sort.Slice(ranked, func(i, j int) bool {
+				a, b := values[ranked[i]], values[ranked[j]]
+				if a != b {
+					return a > b
+				}
+				return ranked[i] < ranked[j]
+			})
+			kept[key] = make(map[string]bool, l.MaxValues)
+			for _, v := range ranked[:l.MaxValues] {
+				kept[key][v] = true
+			}
+		}
+		for i, t := range tags {
+			for key, value := range t {
+				if keep, capped := kept[key]; capped && !keep[value] {
+					record(key, TagOverflows, value, costs[i])
+					set(i, key, TagOverflow, false)
+				}
+			}
+		}
+	}
+
+	return caps.list()
+}
+
+// capTracker totals the changes the limits make, per key and reason
+type capTracker struct {
+	caps     map[capKey]*TagCap
+	affected map[capKey]map[string]bool
+}
+
+type capKey struct{ key, reason string }
+
+func newCapTracker() *capTracker {
+	return &capTracker{caps: make(map[capKey]*TagCap), affected: make(map[capKey]map[string]bool)}
+}
+
+// record counts a changed value and the cost of its charge
+func (t *capTracker) record(key, reason, value string, cost float64) {
+	k := capKey{key, reason}
+	if t.caps[k] == nil {
+		t.caps[k] = &TagCap{Key: key, Reason: reason}
+		t.affected[k] = make(map[string]bool)
+	}
+	t.caps[k].Cost = money.Add(t.caps[k].Cost, cost)
+	if !t.affected[k][value] {
+		t.affected[k][value] = true
+		t.caps[k].Values++
+	}
+}
+
+// list returns the caps by key and reason
+func (t *capTracker) list() []TagCap {
+	result := make([]TagCap, 0, len(t.caps))
+	for _, c := range t.caps {
+		result = append(result, *c)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Key != result[j].Key {
+			return result[i].Key < result[j].Key
+		}
+		return result[i].Reason < result[j].Reason
+	})
+	return result
+}
+
+// TagStream applies limits to charges one at a time, for data too large to
+// hold at once. Without every charge to rank, MaxValues keeps the first
+// values seen of each key rather than the largest by cost.
+type TagStream struct {
+	limits TagLimits
+	seen   map[string]map[string]bool // key -> values kept
+	caps   *capTracker
+}
+
+// Stream returns a TagStream applying the limits
+func (l TagLimits) Stream() *TagStream {
+	return &TagStream{limits: l, seen: make(map[string]map[string]bool), caps: newCapTracker()}
+}
+
+// Apply bounds the tags of a charge costing cost. A map that changes is
+// replaced rather than modified, since it may be shared.
+func (s *TagStream) Apply(tags map[string]string, cost float64) map[string]string {
+	if s.limits.IsZero() || len(tags) == 0 {
+		return tags
+	}
+	var copied map[string]string
+	set := func(key, value string, drop bool) {
+		if copied == nil {
+			copied = make(map[string]string, len(tags))
+			for k, v := range tags {
+				copied[k] = v
+			}
+		}
+		if drop {
+			delete(copied, key)
+		} else {
+			copied[key] = value
+		}
+	}
+
+	for key, value := range tags {
+		if s.limits.ignored(key) {
+			s.caps.record(key, TagIgnored, value, cost)
+			set(key, "", true)
+			continue
+		}
+		if short, cut := s.limits.truncate(value); cut {
+			s.caps.record(key, TagTruncated, value, cost)
+			set(key, short, false)
+			value = short
+		}
+		if s.limits.MaxValues <= 0 {
+			continue
+		}
+		kept := s.seen[key]
+		if kept == nil {
+			kept = make(map[string]bool)
+			s.seen[key] = kept
+		}
+		if !kept[value] {
+			if len(kept) >= s.limits.MaxValues {
+				s.caps.record(key, TagOverflows, value, cost)
+				set(key, TagOverflow, false)
+				continue
+			}
+			kept[value] = true
+		}
+	}
+	if copied == nil {
+		return tags
+	}
+	return copied
+}
+
+// Caps returns the tag keys the limits changed so far
+func (s *TagStream) Caps() []TagCap {
+	return s.caps.list()
+}
@@ -0,0 +1,69 @@
+package normalizer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTagLimitsApply(t *testing.T) {
+	shared := map[string]string{"team": "web", "aws:createdBy": "role/x"}
+	tags := []map[string]string{
+		shared,
+		{"team": "data", "build": "2024-03-01T12:00:00Z"},
+		{"team": "ops", "note": "résumé-pipeline"},
+		{"team": "web"},
+	}
+	costs := []float64{10, 50, 5, 30}
+	limits := TagLimits{MaxValues: 2, MaxLength: 6, Ignore: []string{"aws:*", "build"}}
+
+	caps := limits.Apply(tags, costs)
+
+	want := []map[string]string{
+		{"team": "web"},
+		{"team": "data"},
+		{"team": TagOverflow, "note": "résumé"},
+		{"team": "web"},
+	}
+	if !reflect.DeepEqual(tags, want) {
+		t.Errorf("tags = %v, want %v", tags, want)
+	}
+	if shared["aws:createdBy"] != "role/x" {
+		t.Error("Apply modified a shared tag map")
+	}
+	wantCaps := []TagCap{
+		{Key: "aws:createdBy", Reason: TagIgnored, Values: 1, Cost: 10},
+		{Key: "build", Reason: TagIgnored, Values: 1, Cost: 50},
+		{Key: "note", Reason: TagTruncated, Values: 1, Cost: 5},
+		{Key: "team", Reason: TagOverflows, Values: 1, Cost: 5},
+	}
+	if !reflect.DeepEqual(caps, wantCaps) {
+		t.Errorf("caps = %+v, want %+v", caps, wantCaps)
+	}
+
+	if caps := (TagLimits{}).Apply(tags, costs); caps != nil {
+		t.Errorf("zero limits capped %+v", caps)
+	}
+}
+
+func TestTagStream(t *testing.T) {
+	s := TagLimits{MaxValues: 2, Ignore: []string{"build"}}.Stream()
+	var got []string
+	for _, tags := range []map[string]string{
+		{"team": "ops"},
+		{"team": "web", "build": "1"},
+		{"team": "data"},
+		{"team": "ops"},
+	} {
+		got = append(got, s.Apply(tags, 10)["team"])
+	}
+	if want := []string{"ops", "web", TagOverflow, "ops"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("team values = %v, want %v, keeping the first seen", got, want)
+	}
+	want := []TagCap{
+		{Key: "build", Reason: TagIgnored, Values: 1, Cost: 10},
+		{Key: "team", Reason: TagOverflows, Values: 1, Cost: 10},
+	}
+	if caps := s.Caps(); !reflect.DeepEqual(caps, want) {
+		t.Errorf("Caps() = %+v, want %+v", caps, want)
+	}
+}
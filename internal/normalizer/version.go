@@ -0,0 +1,93 @@
+package normalizer
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// SchemaVersion is the version of CostRecord written by this build.
+//
+//	1: original schema, written without a schema_version field
+//	2: adds raw_cost, adjustment and charge_type
+//	3: adds emissions_kg
+//	4: adds effective_cost and commitment discount fields
+//	5: adds commitment_discount_name
+//	6: adds original_cost and original_currency
+//	7: adds line_item_type; charge_type holds only the normalized types
+const SchemaVersion = 7
+
+// migrations[v] upgrades a record from version v to v+1
+var migrations = map[int]func(*CostRecord){
+	1: migrateV1,
+	2: func(*CostRecord) {}, // emissions were not estimated before version 3
+	3: func(*CostRecord) {}, // effective cost defaults to cost, see Effective
+	4: func(*CostRecord) {}, // names were not recorded before version 5
+	5: func(*CostRecord) {}, // costs were not converted before version 6
+	6: migrateV6,
+}
+
+// MarshalJSON stamps the record with the current schema version
+func (r CostRecord) MarshalJSON() ([]byte, error) {
+	type plain CostRecord
+	p := plain(r)
+	p.SchemaVersion = SchemaVersion
+	return json.Marshal(p)
+}
+
+// UnmarshalJSON decodes a record of any known schema version and upgrades it
+// to the current one. Records from a newer build are rejected rather than
+// silently mis-read.
+func (r *CostRecord) UnmarshalJSON(data []byte) error {
+	type plain CostRecord
+	var p plain
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+	*r = CostRecord(p)
+	return r.Migrate()
+}
+
+// Migrate upgrades the record in place to SchemaVersion
+func (r *CostRecord) Migrate() error {
+	if r.SchemaVersion == 0 {
+		r.SchemaVersion = 1
+	}
+	if r.SchemaVersion > SchemaVersion {
+		return fmt.Errorf("cost record %q has schema version %d, newer than supported version %d", r.ID, r.SchemaVersion, SchemaVersion)
+	}
+
+	for r.SchemaVersion < SchemaVersion {
+		migrations[r.SchemaVersion](r)
+		r.SchemaVersion++
+	}
+	return nil
+}
+
+// migrateV1 fills the fields added in version 2. Version 1 had no
+// adjustments, and credits were only recognizable as negative costs.
+func migrateV1(r *CostRecord) {
+	if r.ChargeType == "" {
+		r.ChargeType = ChargeUsage
+		if r.Cost < 0 {
+			r.ChargeType = ChargeCredit
+		}
+	}
+	r.RawCost = 0
+	r.Adjustment = ""
+	if r.Currency == "" {
+		r.Currency = "USD"
+	}
+	if r.Tags == nil {
+		r.Tags = map[string]string{}
+	}
+}
+
+// migrateV6 moves provider line item types, which version 6 kept as the
+// charge type, to line_item_type and normalizes the charge type
+func migrateV6(r *CostRecord) {
+	if r.ChargeType == "" || r.ChargeType.Valid() {
+		return
+	}
+	r.LineItemType = string(r.ChargeType)
+	r.ChargeType = ParseChargeType(r.LineItemType)
+}
@@ -0,0 +1,169 @@
+package normalizer
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func loadFixture(t *testing.T, name string) map[string]CostRecord {
+	t.Helper()
+	data, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var records []CostRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		t.Fatal(err)
+	}
+	byID := make(map[string]CostRecord, len(records))
+	for _, r := range records {
+		if r.SchemaVersion != SchemaVersion {
+			t.Errorf("%s: schema version %d, want %d", r.ID, r.SchemaVersion, SchemaVersion)
+		}
+		byID[r.ID] = r
+	}
+	return byID
+}
+
+func TestMigrateV1Fixtures(t *testing.T) {
+	records := loadFixture(t, "records-v1.json")
+	tests := []struct {
+		id       string
+		charge   ChargeType
+		currency string
+		tags     int
+	}{
+		{"v1-usage", ChargeUsage, "USD", 1},
+		{"v1-credit", ChargeCredit, "USD", 0},
+		{"v1-eur", ChargeUsage, "EUR", 0},
+	}
+	for _, tt := range tests {
+		r, ok := records[tt.id]
+		if !ok {
+			t.Fatalf("fixture %s missing", tt.id)
+		}
+		if r.ChargeType != tt.charge || r.Currency != tt.currency {
+			t.Errorf("%s: charge type %q, currency %q, want %q, %q", tt.id, r.ChargeType, r.Currency, tt.charge, tt.currency)
+		}
+		if r.Tags == nil || len(r.Tags) != tt.tags {
+			t.Errorf("%s: tags %v, want %d", tt.id, r.Tags, tt.tags)
+		}
+		if r.LineItemType != "" || r.RawCost != 0 || r.Adjustment != "" {
+			t.Errorf("%s: line item type %q, raw cost %v, adjustment %q, want none", tt.id, r.LineItemType, r.RawCost, r.Adjustment)
+		}
+	}
+	if r := records["v1-usage"]; r.Cost != 12.5 || r.UsageQuantity != 24 || r.Tags["team"] != "web" {
+		t.Errorf("v1-usage lost fields: %+v", r)
+	}
+}
+
+func TestMigrateV6Fixtures(t *testing.T) {
+	records := loadFixture(t, "records-v6.json")
+	tests := []struct {
+		id       string
+		charge   ChargeType
+		lineItem string
+	}{
+		{"v6-rifee", ChargeFee, "RIFee"},
+		{"v6-edp", ChargeCredit, "EdpDiscount"},
+		{"v6-usage", ChargeUsage, ""},
+	}
+	for _, tt := range tests {
+		r := records[tt.id]
+		if r.ChargeType != tt.charge || r.LineItemType != tt.lineItem {
+			t.Errorf("%s: charge type %q, line item type %q, want %q, %q", tt.id, r.ChargeType, r.LineItemType, tt.charge, tt.lineItem)
+		}
+	}
+}
+
+func TestMigrationSteps(t *testing.T) {
+	for v := 1; v < SchemaVersion; v++ {
+		if migrations[v] == nil {
+			t.Fatalf("no migration from version %d", v)
+		}
+	}
+
+	tests := []struct {
+		name string
+		from int
+		in   CostRecord
+		want func(CostRecord) bool
+	}{
+		{"v1 negative cost is a credit", 1, CostRecord{Cost: -1}, func(r CostRecord) bool {
+			return r.ChargeType == ChargeCredit && r.Currency == "USD" && r.Tags != nil
+		}},
+		{"v1 keeps a charge type", 1, CostRecord{Cost: -1, ChargeType: ChargeRefund}, func(r CostRecord) bool {
+			return r.ChargeType == ChargeRefund
+		}},
+		{"v1 clears adjustments", 1, CostRecord{Cost: 5, RawCost: 4, Adjustment: "markup"}, func(r CostRecord) bool {
+			return r.RawCost == 0 && r.Adjustment == ""
+		}},
+		{"v2 to v6 change nothing", 2, CostRecord{Cost: 5, ChargeType: ChargeUsage, Currency: "USD"}, func(r CostRecord) bool {
+			return r.Cost == 5 && r.ChargeType == ChargeUsage && r.EmissionsKg == 0 && r.EffectiveCost == 0 && r.OriginalCurrency == ""
+		}},
+		{"v6 moves a line item type", 6, CostRecord{ChargeType: "SavingsPlanRecurringFee"}, func(r CostRecord) bool {
+			return r.ChargeType == ChargeFee && r.LineItemType == "SavingsPlanRecurringFee"
+		}},
+		{"v6 keeps a normalized type", 6, CostRecord{ChargeType: ChargeTax}, func(r CostRecord) bool {
+			return r.ChargeType == ChargeTax && r.LineItemType == ""
+		}},
+		{"v6 unknown type is usage", 6, CostRecord{ChargeType: "Mystery"}, func(r CostRecord) bool {
+			return r.ChargeType == ChargeUsage && r.LineItemType == "Mystery"
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := tt.in
+			r.SchemaVersion = tt.from
+			if err := r.Migrate(); err != nil {
+				t.Fatal(err)
+			}
+			if r.SchemaVersion != SchemaVersion || !tt.want(r) {
+				t.Errorf("migrated from v%d to %+v", tt.from, r)
+			}
+		})
+	}
+}
+
+func TestMigrationStepByStep(t *testing.T) {
+	r := CostRecord{ID: "r", SchemaVersion: 1, Cost: -2, ChargeType: "", Currency: ""}
+	for v := 1; v < SchemaVersion; v++ {
+		before := r
+		migrations[v](&r)
+		switch v {
+		case 1:
+			if r.ChargeType != ChargeCredit || r.Currency != "USD" || r.Tags == nil {
+				t.Fatalf("after v1: %+v", r)
+			}
+		default:
+			// Later steps only fill fields a version 1 record cannot have
+			if !reflect.DeepEqual(before, r) {
+				t.Fatalf("v%d changed %+v to %+v", v, before, r)
+			}
+		}
+	}
+}
+
+func TestMigrateRejectsNewerVersion(t *testing.T) {
+	r := CostRecord{ID: "future", SchemaVersion: SchemaVersion + 1}
+	if err := r.Migrate(); err == nil || !strings.Contains(err.Error(), "newer than supported") {
+		t.Fatalf("err = %v, want a newer-version error", err)
+	}
+}
+
+func TestMarshalStampsVersion(t *testing.T) {
+	data, err := json.Marshal(CostRecord{ID: "r", SchemaVersion: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r CostRecord
+	if err := json.Unmarshal(data, &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.SchemaVersion != SchemaVersion || r.ChargeType != "" {
+		t.Errorf("round trip gave version %d, charge type %q; want %d and no migration", r.SchemaVersion, r.ChargeType, SchemaVersion)
+	}
+}
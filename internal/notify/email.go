@@ -0,0 +1,65 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+// EmailDigest sends a plain-text digest of a run over SMTP
+type EmailDigest struct {
+	addr string
+	from string
+	to   []string
+	auth smtp.Auth
+}
+
+// NewEmailDigest creates a digest sender from the email alerting config
+func NewEmailDigest(cfg config.EmailConfig) (*EmailDigest, error) {
+	if cfg.UseMSGraph {
+		return nil, fmt.Errorf("digest email requires SMTP; Microsoft Graph delivery is not supported")
+	}
+	if cfg.SMTPHost == "" || cfg.FromAddr == "" || len(cfg.Recipients) == 0 {
+		return nil, fmt.Errorf("digest email requires smtp_host, from_addr and recipients")
+	}
+
+	port := cfg.SMTPPort
+	if port == 0 {
+		port = 587
+	}
+
+	d := &EmailDigest{
+		addr: net.JoinHostPort(cfg.SMTPHost, strconv.Itoa(port)),
+		from: cfg.FromAddr,
+		to:   cfg.Recipients,
+	}
+	if cfg.Username != "" {
+		d.auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.SMTPHost)
+	}
+	return d, nil
+}
+
+// Send delivers one digest message
+func (d *EmailDigest) Send(ctx context.Context, subject, body string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", d.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(d.to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	if err := smtp.SendMail(d.addr, d.auth, d.from, d.to, []byte(msg.String())); err != nil {
+		return fmt.Errorf("failed to send digest email: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package notify
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+func TestNewEmailDigest(t *testing.T) {
+	valid := config.EmailConfig{SMTPHost: "smtp.example.com", FromAddr: "finops@example.com", Recipients: []string{"a@example.com"}}
+
+	d, err := NewEmailDigest(valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.addr != "smtp.example.com:587" || d.auth != nil {
+		t.Errorf("addr = %s, auth = %v, want smtp.example.com:587 without auth", d.addr, d.auth)
+	}
+
+	withAuth := valid
+	withAuth.SMTPPort = 25
+	withAuth.Username = "user"
+	if d, err = NewEmailDigest(withAuth); err != nil {
+		t.Fatal(err)
+	}
+	if d.addr != "smtp.example.com:25" || d.auth == nil {
+		t.Errorf("addr = %s, auth = %v, want smtp.example.com:25 with auth", d.addr, d.auth)
+	}
+
+	graph := valid
+	graph.UseMSGraph = true
+	noRecipients := valid
+	noRecipients.Recipients = nil
+	for name, cfg := range map[string]config.EmailConfig{"graph": graph, "no recipients": noRecipients, "empty": {}} {
+		if _, err := NewEmailDigest(cfg); err == nil {
+			t.Errorf("%s: NewEmailDigest() succeeded, want an error", name)
+		}
+	}
+}
+
+func TestEmailDigestSendCancelled(t *testing.T) {
+	d, err := NewEmailDigest(config.EmailConfig{SMTPHost: "smtp.example.com", FromAddr: "finops@example.com", Recipients: []string{"a@example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := d.Send(ctx, "subject", "body"); err != context.Canceled {
+		t.Errorf("Send() = %v, want %v", err, context.Canceled)
+	}
+}
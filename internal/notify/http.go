@@ -0,0 +1,76 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxRetries is how many times webhook deliveries are retried
+const maxRetries = 3
+
+// postJSON posts a JSON body. On failure it returns how long to wait before
+// retrying: negative when the error is permanent, zero for the usual
+// backoff, or the server's Retry-After when rate limited.
+func postJSON(ctx context.Context, client *http.Client, url string, header map[string]string, body []byte) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return -1, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return 0, nil
+	case resp.StatusCode == http.StatusTooManyRequests:
+		wait := time.Second
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs > 0 {
+			wait = time.Duration(secs) * time.Second
+		}
+		return wait, fmt.Errorf("rate limited")
+	case resp.StatusCode >= 500:
+		return 0, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	}
+	return -1, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(detail)))
+}
+
+// retry calls attempt until it succeeds, fails permanently or has been
+// retried maxRetries times, doubling the wait between failures that give
+// no Retry-After
+func retry(ctx context.Context, attempt func() (time.Duration, error)) error {
+	backoff := time.Second
+	for n := 0; ; n++ {
+		wait, err := attempt()
+		if err == nil {
+			return nil
+		}
+		if wait < 0 || n == maxRetries {
+			return err
+		}
+		if wait == 0 {
+			wait = backoff
+			backoff *= 2
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+// kafkaPublisher writes messages keyed by the event key, so the hash
+// balancer keeps each service on one partition
+type kafkaPublisher struct {
+	writer *kafka.Writer
+}
+
+func newKafkaPublisher(cfg config.QueueConfig) (*kafkaPublisher, error) {
+	if len(cfg.Brokers) == 0 {
+		return nil, fmt.Errorf("kafka brokers are not set")
+	}
+	return &kafkaPublisher{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(cfg.Brokers...),
+			Topic:        cfg.Topic,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireAll,
+		},
+	}, nil
+}
+
+func (p *kafkaPublisher) Publish(ctx context.Context, key string, body []byte) error {
+	return p.writer.WriteMessages(ctx, kafka.Message{Key: []byte(key), Value: body})
+}
+
+func (p *kafkaPublisher) Close() error {
+	return p.writer.Close()
+}
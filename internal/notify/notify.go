@@ -0,0 +1,41 @@
+// Package notify delivers alert events to external systems
+package notify
+
+import (
+	"context"
+	"time"
+)
+
+// SchemaVersion is the version of the Event message schema. Bump it on any
+// incompatible change so consumers can route or reject old messages.
+const SchemaVersion = "1"
+
+// Event types
+const (
+	EventAnomaly = "anomaly"
+	EventBudget  = "budget"
+)
+
+// Event is a single alert in a backend-neutral form
+type Event struct {
+	SchemaVersion string    `json:"schema_version"`
+	Type          string    `json:"type"`
+	Key           string    `json:"key"` // partitioning key: the service for anomalies, the budget for budget alerts
+	Severity      string    `json:"severity"`
+	Provider      string    `json:"provider"`
+	Service       string    `json:"service,omitempty"`
+	Account       string    `json:"account,omitempty"`
+	Budget        string    `json:"budget,omitempty"`
+	Kind          string    `json:"kind,omitempty"` // budget alerts: actual or forecast
+	Summary       string    `json:"summary"`
+	Amount        float64   `json:"amount"`    // actual cost or current spend
+	Reference     float64   `json:"reference"` // expected cost or budget limit
+	Percent       float64   `json:"percent"`   // deviation or percent of budget used
+	OccurredAt    time.Time `json:"occurred_at"`
+}
+
+// Sink delivers events to one destination
+type Sink interface {
+	Name() string
+	Send(ctx context.Context, events []Event) error
+}
@@ -0,0 +1,199 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+// Paging endpoints
+const (
+	pagerDutyURL  = "https://events.pagerduty.com/v2/enqueue"
+	opsgenieURL   = "https://api.opsgenie.com"
+	opsgenieTitle = 130 // Opsgenie's message length limit
+)
+
+// PagerSink opens PagerDuty or Opsgenie incidents for anomalies of the
+// configured severities and budgets past their paging threshold. Each
+// incident carries a dedup key, so repeated runs update the open incident
+// instead of paging again.
+type PagerSink struct {
+	backend    string
+	routingKey string
+	apiKey     string
+	apiURL     string
+	severities map[string]bool
+	budgetPct  int
+	pageAt     map[string]int // budget name -> paging percent override
+	client     *http.Client
+}
+
+// NewPagerSink creates a paging sink for the configured backend. pageAt
+// holds per-budget overrides of the budget percent that pages.
+func NewPagerSink(cfg config.PagingConfig, pageAt map[string]int) (*PagerSink, error) {
+	s := &PagerSink{
+		backend:    cfg.Backend,
+		routingKey: cfg.RoutingKey,
+		apiKey:     cfg.APIKey,
+		apiURL:     strings.TrimSuffix(cfg.APIURL, "/"),
+		severities: make(map[string]bool),
+		budgetPct:  cfg.BudgetPercent,
+		pageAt:     pageAt,
+		client:     &http.Client{Timeout: 30 * time.Second},
+	}
+	for _, sev := range cfg.Severities {
+		s.severities[strings.ToLower(sev)] = true
+	}
+
+	switch cfg.Backend {
+	case "pagerduty":
+		if cfg.RoutingKey == "" {
+			return nil, fmt.Errorf("pagerduty routing key is not set")
+		}
+	case "opsgenie":
+		if cfg.APIKey == "" {
+			return nil, fmt.Errorf("opsgenie API key is not set")
+		}
+		if s.apiURL == "" {
+			s.apiURL = opsgenieURL
+		}
+	default:
+		return nil, fmt.Errorf("unknown paging backend %q (want pagerduty or opsgenie)", cfg.Backend)
+	}
+	return s, nil
+}
+
+// Name returns the sink name
+func (s *PagerSink) Name() string {
+	return "paging:" + s.backend
+}
+
+// Send opens an incident for each event that pages, continuing past failures
+func (s *PagerSink) Send(ctx context.Context, events []Event) error {
+	var errs []error
+	for _, e := range events {
+		if !s.pages(e) {
+			continue
+		}
+		if err := s.trigger(ctx, e); err != nil {
+			errs = append(errs, fmt.Errorf("failed to page for %s: %w", e.Key, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// pages reports whether an event is severe enough to open an incident
+func (s *PagerSink) pages(e Event) bool {
+	if e.Type != EventBudget {
+		return s.severities[e.Severity]
+	}
+	threshold := s.budgetPct
+	if t := s.pageAt[e.Budget]; t != 0 {
+		threshold = t
+	}
+	return threshold > 0 && e.Percent >= float64(threshold)
+}
+
+func (s *PagerSink) trigger(ctx context.Context, e Event) error {
+	var (
+		url    string
+		header map[string]string
+		msg    any
+	)
+	switch s.backend {
+	case "pagerduty":
+		url = pagerDutyURL
+		msg = pagerDutyEvent(s.routingKey, e)
+	case "opsgenie":
+		url = s.apiURL + "/v2/alerts"
+		header = map[string]string{"Authorization": "GenieKey " + s.apiKey}
+		msg = opsgenieAlert(e)
+	}
+
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to encode incident: %w", err)
+	}
+	return retry(ctx, func() (time.Duration, error) {
+		return postJSON(ctx, s.client, url, header, body)
+	})
+}
+
+// dedupKey identifies the incident an event belongs to: an anomaly by its
+// service and day, a budget alert by its budget and month
+func dedupKey(e Event) string {
+	if e.Type == EventBudget {
+		return fmt.Sprintf("finops:budget:%s:%s", e.Key, e.OccurredAt.UTC().Format("2006-01"))
+	}
+	return fmt.Sprintf("finops:anomaly:%s:%s:%s:%s", e.Provider, e.Account, e.Service, e.OccurredAt.UTC().Format("2006-01-02"))
+}
+
+// pagerDutyEvent builds an Events API v2 trigger
+func pagerDutyEvent(routingKey string, e Event) map[string]any {
+	severity := "info"
+	switch e.Severity {
+	case "critical":
+		severity = "critical"
+	case "high":
+		severity = "error"
+	case "medium":
+		severity = "warning"
+	}
+	return map[string]any{
+		"routing_key":  routingKey,
+		"event_action": "trigger",
+		"dedup_key":    dedupKey(e),
+		"payload": map[string]any{
+			"summary":        e.Summary,
+			"source":         "finops-platform",
+			"severity":       severity,
+			"timestamp":      e.OccurredAt.UTC().Format(time.RFC3339),
+			"component":      e.Service,
+			"group":          e.Provider,
+			"class":          e.Type,
+			"custom_details": e,
+		},
+	}
+}
+
+// opsgenieAlert builds an Opsgenie alert; the alias deduplicates it
+func opsgenieAlert(e Event) map[string]any {
+	priority := "P5"
+	switch e.Severity {
+	case "critical":
+		priority = "P1"
+	case "high":
+		priority = "P2"
+	case "medium":
+		priority = "P3"
+	case "low":
+		priority = "P4"
+	}
+	message := e.Summary
+	if r := []rune(message); len(r) > opsgenieTitle {
+		message = string(r[:opsgenieTitle-3]) + "..."
+	}
+	return map[string]any{
+		"message":     message,
+		"alias":       dedupKey(e),
+		"description": e.Summary,
+		"priority":    priority,
+		"source":      "finops-platform",
+		"tags":        []string{"finops", e.Type},
+		"details": map[string]string{
+			"provider":  e.Provider,
+			"service":   e.Service,
+			"account":   e.Account,
+			"budget":    e.Budget,
+			"amount":    fmt.Sprintf("%.2f", e.Amount),
+			"reference": fmt.Sprintf("%.2f", e.Reference),
+			"percent":   fmt.Sprintf("%.1f", e.Percent),
+		},
+	}
+}
@@ -0,0 +1,110 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+func TestPagerSinkPages(t *testing.T) {
+	s, err := NewPagerSink(config.PagingConfig{Backend: "pagerduty", RoutingKey: "rk", Severities: []string{"Critical"}, BudgetPercent: 100},
+		map[string]int{"sandbox": 150, "shared": -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		e    Event
+		want bool
+	}{
+		{"critical anomaly", Event{Type: EventAnomaly, Severity: "critical"}, true},
+		{"high anomaly", Event{Type: EventAnomaly, Severity: "high"}, false},
+		{"exhausted budget", Event{Type: EventBudget, Budget: "platform", Percent: 100}, true},
+		{"budget under limit", Event{Type: EventBudget, Budget: "platform", Percent: 99.9}, false},
+		{"override not reached", Event{Type: EventBudget, Budget: "sandbox", Percent: 120}, false},
+		{"override reached", Event{Type: EventBudget, Budget: "sandbox", Percent: 150}, true},
+		{"never pages", Event{Type: EventBudget, Budget: "shared", Percent: 500}, false},
+	}
+	for _, tt := range tests {
+		if got := s.pages(tt.e); got != tt.want {
+			t.Errorf("%s: pages() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNewPagerSinkErrors(t *testing.T) {
+	for name, cfg := range map[string]config.PagingConfig{
+		"no routing key": {Backend: "pagerduty"},
+		"no api key":     {Backend: "opsgenie"},
+		"unknown":        {Backend: "victorops", APIKey: "k"},
+	} {
+		if _, err := NewPagerSink(cfg, nil); err == nil {
+			t.Errorf("%s: NewPagerSink() succeeded, want an error", name)
+		}
+	}
+}
+
+func TestDedupKey(t *testing.T) {
+	anomaly := Event{Type: EventAnomaly, Provider: "aws", Account: "111", Service: "EC2", OccurredAt: occurred}
+	if got := dedupKey(anomaly); got != "finops:anomaly:aws:111:EC2:2024-03-05" {
+		t.Errorf("anomaly key = %s", got)
+	}
+	// A budget pages once a month however often it is re-evaluated
+	budget := Event{Type: EventBudget, Key: "platform", OccurredAt: occurred}
+	later := budget
+	later.OccurredAt = occurred.AddDate(0, 0, 20)
+	if dedupKey(budget) != "finops:budget:platform:2024-03" || dedupKey(later) != dedupKey(budget) {
+		t.Errorf("budget keys = %s, %s", dedupKey(budget), dedupKey(later))
+	}
+}
+
+func TestPagerDutyEvent(t *testing.T) {
+	msg := pagerDutyEvent("rk", Event{Type: EventAnomaly, Severity: "high", Provider: "aws", Service: "EC2", Summary: "EC2 spike", OccurredAt: occurred})
+	payload := msg["payload"].(map[string]any)
+	if msg["routing_key"] != "rk" || msg["event_action"] != "trigger" || payload["severity"] != "error" ||
+		payload["timestamp"] != "2024-03-05T09:30:00Z" || payload["component"] != "EC2" {
+		t.Errorf("event = %v", msg)
+	}
+}
+
+func TestPagerSinkSendOpsgenie(t *testing.T) {
+	var alerts []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/alerts" || r.Header.Get("Authorization") != "GenieKey k" {
+			t.Errorf("request to %s with %q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		body, _ := io.ReadAll(r.Body)
+		var alert map[string]any
+		json.Unmarshal(body, &alert)
+		alerts = append(alerts, alert)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	s, err := NewPagerSink(config.PagingConfig{Backend: "opsgenie", APIKey: "k", APIURL: srv.URL + "/", Severities: []string{"critical"}, BudgetPercent: 100}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Name() != "paging:opsgenie" {
+		t.Errorf("Name() = %s", s.Name())
+	}
+	err = s.Send(context.Background(), []Event{
+		{Type: EventAnomaly, Severity: "critical", Service: "EC2", Summary: strings.Repeat("x", 200), OccurredAt: occurred},
+		{Type: EventAnomaly, Severity: "medium", Service: "S3", OccurredAt: occurred},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 1 {
+		t.Fatalf("opened %d alerts, want only the critical one", len(alerts))
+	}
+	if msg := alerts[0]["message"].(string); len(msg) != opsgenieTitle || !strings.HasSuffix(msg, "...") || alerts[0]["priority"] != "P1" {
+		t.Errorf("alert = %v, want a truncated P1", alerts[0])
+	}
+}
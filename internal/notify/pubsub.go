@@ -0,0 +1,48 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+
+	"cloud.google.com/go/pubsub"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+// pubsubPublisher publishes to a Pub/Sub topic with the event key as the
+// ordering key
+type pubsubPublisher struct {
+	client *pubsub.Client
+	topic  *pubsub.Topic
+}
+
+func newPubSubPublisher(ctx context.Context, cfg config.QueueConfig) (*pubsubPublisher, error) {
+	if cfg.ProjectID == "" {
+		return nil, fmt.Errorf("pubsub project_id is not set")
+	}
+
+	client, err := pubsub.NewClient(ctx, cfg.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+
+	topic := client.Topic(cfg.Topic)
+	topic.EnableMessageOrdering = true
+
+	return &pubsubPublisher{client: client, topic: topic}, nil
+}
+
+func (p *pubsubPublisher) Publish(ctx context.Context, key string, body []byte) error {
+	result := p.topic.Publish(ctx, &pubsub.Message{Data: body, OrderingKey: key})
+	if _, err := result.Get(ctx); err != nil {
+		// Ordered publishing pauses the key after a failure; resume for the next attempt
+		p.topic.ResumePublish(key)
+		return err
+	}
+	return nil
+}
+
+func (p *pubsubPublisher) Close() error {
+	p.topic.Stop()
+	return p.client.Close()
+}
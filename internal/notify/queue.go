@@ -0,0 +1,84 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+// Publisher is a message queue backend
+type Publisher interface {
+	// Publish sends one message; key orders and partitions related messages
+	Publish(ctx context.Context, key string, body []byte) error
+	Close() error
+}
+
+// QueueSink publishes each event as a JSON message to a queue topic
+type QueueSink struct {
+	backend   string
+	publisher Publisher
+}
+
+// NewQueueSink creates a queue sink for the configured backend
+func NewQueueSink(ctx context.Context, cfg config.QueueConfig) (*QueueSink, error) {
+	if cfg.Topic == "" {
+		return nil, fmt.Errorf("queue topic is not set")
+	}
+
+	var (
+		p   Publisher
+		err error
+	)
+	switch cfg.Backend {
+	case "kafka":
+		p, err = newKafkaPublisher(cfg)
+	case "sns":
+		p, err = newSNSPublisher(ctx, cfg)
+	case "pubsub":
+		p, err = newPubSubPublisher(ctx, cfg)
+	default:
+		return nil, fmt.Errorf("unknown queue backend %q (want kafka, sns, or pubsub)", cfg.Backend)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s publisher: %w", cfg.Backend, err)
+	}
+
+	return NewQueueSinkWithPublisher(cfg.Backend, p), nil
+}
+
+// NewQueueSinkWithPublisher creates a queue sink for a custom backend
+func NewQueueSinkWithPublisher(backend string, p Publisher) *QueueSink {
+	return &QueueSink{backend: backend, publisher: p}
+}
+
+// Name returns the sink name
+func (s *QueueSink) Name() string {
+	return "queue:" + s.backend
+}
+
+// Send publishes every event, continuing past individual failures
+func (s *QueueSink) Send(ctx context.Context, events []Event) error {
+	var errs []error
+	for _, e := range events {
+		if e.SchemaVersion == "" {
+			e.SchemaVersion = SchemaVersion
+		}
+		body, err := json.Marshal(e)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to encode %s event: %w", e.Type, err))
+			continue
+		}
+		if err := s.publisher.Publish(ctx, e.Key, body); err != nil {
+			errs = append(errs, fmt.Errorf("failed to publish %s event %q: %w", e.Type, e.Key, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close releases the backend connection
+func (s *QueueSink) Close() error {
+	return s.publisher.Close()
+}
@@ -0,0 +1,93 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+// message is one publish seen by fakePublisher
+type message struct {
+	key  string
+	body []byte
+}
+
+// fakePublisher records messages, failing those keyed failKey
+type fakePublisher struct {
+	failKey  string
+	messages []message
+	closed   bool
+}
+
+func (p *fakePublisher) Publish(ctx context.Context, key string, body []byte) error {
+	if key == p.failKey {
+		return errors.New("broker unavailable")
+	}
+	p.messages = append(p.messages, message{key, body})
+	return nil
+}
+
+func (p *fakePublisher) Close() error {
+	p.closed = true
+	return nil
+}
+
+func TestQueueSinkSend(t *testing.T) {
+	p := &fakePublisher{failKey: "EC2"}
+	s := NewQueueSinkWithPublisher("test", p)
+	if s.Name() != "queue:test" {
+		t.Errorf("Name() = %q, want queue:test", s.Name())
+	}
+
+	events := []Event{
+		{Type: EventAnomaly, Key: "EC2", Service: "EC2"},
+		{Type: EventBudget, Key: "platform", Budget: "platform", Amount: 900, Reference: 1000},
+		{SchemaVersion: "0", Type: EventAnomaly, Key: "S3", Service: "S3"},
+	}
+	err := s.Send(context.Background(), events)
+	if err == nil || !strings.Contains(err.Error(), `anomaly event "EC2": broker unavailable`) {
+		t.Errorf("err = %v, want the EC2 publish failure", err)
+	}
+
+	// The failure does not stop later events
+	if len(p.messages) != 2 || p.messages[0].key != "platform" || p.messages[1].key != "S3" {
+		t.Fatalf("messages = %v, want platform and S3", p.messages)
+	}
+	var e Event
+	if err := json.Unmarshal(p.messages[0].body, &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.SchemaVersion != SchemaVersion || e.Budget != "platform" || e.Amount != 900 {
+		t.Errorf("budget event = %+v, want schema %s for platform at 900", e, SchemaVersion)
+	}
+	if err := json.Unmarshal(p.messages[1].body, &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.SchemaVersion != "0" {
+		t.Errorf("schema version = %q, want the event's own 0", e.SchemaVersion)
+	}
+
+	if err := s.Close(); err != nil || !p.closed {
+		t.Errorf("Close() = %v, closed %v", err, p.closed)
+	}
+}
+
+func TestNewQueueSinkErrors(t *testing.T) {
+	tests := []struct {
+		cfg  config.QueueConfig
+		want string
+	}{
+		{config.QueueConfig{Backend: "kafka"}, "queue topic is not set"},
+		{config.QueueConfig{Backend: "rabbitmq", Topic: "alerts"}, `unknown queue backend "rabbitmq"`},
+	}
+	for _, tt := range tests {
+		_, err := NewQueueSink(context.Background(), tt.cfg)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("NewQueueSink(%+v) = %v, want %q", tt.cfg, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+// Slack delivery limits
+const (
+	slackEventsPerMessage = 20          // two blocks each, under Block Kit's 50-block limit
+	slackMinInterval      = time.Second // incoming webhooks accept about one message per second
+)
+
+// SlackSink posts events to a Slack incoming webhook as Block Kit messages,
+// one message per channel and batch of events
+type SlackSink struct {
+	webhookURL string
+	channel    string
+	budgets    map[string]string // budget name -> channel override
+	client     *http.Client
+
+	mu       sync.Mutex
+	lastPost time.Time
+}
+
+// NewSlackSink creates a Slack sink. budgetChannels routes budget alerts to
+// their budget's notify_slack channel; everything else goes to the
+// configured channel.
+func NewSlackSink(cfg config.SlackConfig, budgetChannels map[string]string) (*SlackSink, error) {
+	if cfg.WebhookURL == "" {
+		return nil, fmt.Errorf("slack webhook URL is not set")
+	}
+	return &SlackSink{
+		webhookURL: cfg.WebhookURL,
+		channel:    cfg.Channel,
+		budgets:    budgetChannels,
+		client:     &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// Name returns the sink name
+func (s *SlackSink) Name() string {
+	return "slack"
+}
+
+// Send posts the events, grouped by channel, continuing past failed messages
+func (s *SlackSink) Send(ctx context.Context, events []Event) error {
+	byChannel := make(map[string][]Event)
+	for _, e := range events {
+		channel := s.channel
+		if c := s.budgets[e.Budget]; e.Type == EventBudget && c != "" {
+			channel = c
+		}
+		byChannel[channel] = append(byChannel[channel], e)
+	}
+	channels := make([]string, 0, len(byChannel))
+	for c := range byChannel {
+		channels = append(channels, c)
+	}
+	sort.Strings(channels)
+
+	var errs []error
+	for _, channel := range channels {
+		batch := byChannel[channel]
+		for start := 0; start < len(batch); start += slackEventsPerMessage {
+			end := min(start+slackEventsPerMessage, len(batch))
+			if err := s.post(ctx, slackMessage(channel, batch[start:end])); err != nil {
+				errs = append(errs, fmt.Errorf("failed to post %d events to slack %s: %w", end-start, channel, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// post sends one message, spacing posts out and retrying rate-limited and
+// failed requests
+func (s *SlackSink) post(ctx context.Context, msg map[string]any) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+	return retry(ctx, func() (time.Duration, error) {
+		if err := s.wait(ctx); err != nil {
+			return -1, err
+		}
+		return postJSON(ctx, s.client, s.webhookURL, nil, body)
+	})
+}
+
+// wait holds a post until slackMinInterval after the previous one
+func (s *SlackSink) wait(ctx context.Context) error {
+	s.mu.Lock()
+	next := s.lastPost.Add(slackMinInterval)
+	now := time.Now()
+	if next.Before(now) {
+		next = now
+	}
+	s.lastPost = next
+	s.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(time.Until(next)):
+		return nil
+	}
+}
+
+// slackMessage renders events as a Block Kit message
+func slackMessage(channel string, events []Event) map[string]any {
+	var anomalies, budgets int
+	for _, e := range events {
+		if e.Type == EventBudget {
+			budgets++
+		} else {
+			anomalies++
+		}
+	}
+	var parts []string
+	if anomalies > 0 {
+		parts = append(parts, plural(anomalies, "cost anomaly", "cost anomalies"))
+	}
+	if budgets > 0 {
+		parts = append(parts, plural(budgets, "budget alert", "budget alerts"))
+	}
+	title := "FinOps: " + strings.Join(parts, ", ")
+
+	blocks := []map[string]any{{
+		"type": "header",
+		"text": map[string]any{"type": "plain_text", "text": title},
+	}}
+	for _, e := range events {
+		blocks = append(blocks, map[string]any{
+			"type":   "section",
+			"text":   mrkdwn(fmt.Sprintf("%s *%s*", severityEmoji(e.Severity), escapeSlack(e.Summary))),
+			"fields": slackFields(e),
+		}, map[string]any{
+			"type":     "context",
+			"elements": []map[string]any{mrkdwn(fmt.Sprintf("%s · %s severity · %s", e.Type, e.Severity, e.OccurredAt.UTC().Format("2006-01-02 15:04 MST")))},
+		})
+	}
+
+	msg := map[string]any{"text": title, "blocks": blocks}
+	if channel != "" {
+		msg["channel"] = channel
+	}
+	return msg
+}
+
+func slackFields(e Event) []map[string]any {
+	var fields []map[string]any
+	add := func(label, value string) {
+		if value != "" {
+			fields = append(fields, mrkdwn(fmt.Sprintf("*%s*\n%s", label, escapeSlack(value))))
+		}
+	}
+	add("Provider", e.Provider)
+	add("Service", e.Service)
+	add("Account", e.Account)
+	if e.Type == EventBudget {
+		add("Spend", fmt.Sprintf("$%.2f of $%.2f", e.Amount, e.Reference))
+		add("Used", fmt.Sprintf("%.1f%%", e.Percent))
+	} else {
+		add("Cost", fmt.Sprintf("$%.2f (expected $%.2f)", e.Amount, e.Reference))
+		add("Deviation", fmt.Sprintf("%+.1f%%", e.Percent))
+	}
+	return fields
+}
+
+func mrkdwn(text string) map[string]any {
+	return map[string]any{"type": "mrkdwn", "text": text}
+}
+
+func severityEmoji(severity string) string {
+	switch severity {
+	case "critical", "high":
+		return ":red_circle:"
+	case "medium":
+		return ":large_orange_circle:"
+	case "low":
+		return ":large_yellow_circle:"
+	}
+	return ":large_blue_circle:"
+}
+
+// escapeSlack escapes the characters Slack treats as markup
+func escapeSlack(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return "1 " + one
+	}
+	return fmt.Sprintf("%d %s", n, many)
+}
@@ -0,0 +1,125 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+var occurred = time.Date(2024, 3, 5, 9, 30, 0, 0, time.UTC)
+
+func TestSlackMessage(t *testing.T) {
+	msg := slackMessage("#finops", []Event{
+		{Type: EventAnomaly, Severity: "high", Provider: "aws", Service: "EC2", Summary: "EC2 <spike> & more",
+			Amount: 300, Reference: 100, Percent: 200, OccurredAt: occurred},
+		{Type: EventBudget, Severity: "medium", Provider: "all", Budget: "platform", Summary: "platform at 80%",
+			Amount: 800, Reference: 1000, Percent: 80, OccurredAt: occurred},
+	})
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		Channel string `json:"channel"`
+		Text    string `json:"text"`
+		Blocks  []struct {
+			Type   string `json:"type"`
+			Text   struct{ Text string }
+			Fields []struct{ Text string }
+		} `json:"blocks"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.Channel != "#finops" || decoded.Text != "FinOps: 1 cost anomaly, 1 budget alert" {
+		t.Errorf("channel %q, text %q", decoded.Channel, decoded.Text)
+	}
+	// A header, then a section and a context block per event
+	if len(decoded.Blocks) != 5 || decoded.Blocks[0].Type != "header" || decoded.Blocks[1].Type != "section" || decoded.Blocks[2].Type != "context" {
+		t.Fatalf("blocks = %+v", decoded.Blocks)
+	}
+	if got := decoded.Blocks[1].Text.Text; got != ":red_circle: *EC2 &lt;spike&gt; &amp; more*" {
+		t.Errorf("anomaly section = %q", got)
+	}
+	var fields []string
+	for _, f := range decoded.Blocks[3].Fields {
+		fields = append(fields, f.Text)
+	}
+	if got := strings.Join(fields, "|"); got != "*Provider*\nall|*Spend*\n$800.00 of $1000.00|*Used*\n80.0%" {
+		t.Errorf("budget fields = %q", got)
+	}
+
+	if msg := slackMessage("", []Event{{Type: EventAnomaly}, {Type: EventAnomaly}}); msg["channel"] != nil || msg["text"] != "FinOps: 2 cost anomalies" {
+		t.Errorf("default channel message = %v", msg)
+	}
+}
+
+func TestSlackSinkSend(t *testing.T) {
+	var mu sync.Mutex
+	var channels []string
+	failures := 1
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if failures > 0 {
+			failures--
+			http.Error(w, "try later", http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		var msg struct{ Channel string }
+		json.Unmarshal(body, &msg)
+		channels = append(channels, msg.Channel)
+	}))
+	defer srv.Close()
+
+	s, err := NewSlackSink(config.SlackConfig{WebhookURL: srv.URL, Channel: "#finops"}, map[string]string{"web": "#team-web"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Send(context.Background(), []Event{
+		{Type: EventAnomaly, Severity: "low", Service: "S3"},
+		{Type: EventBudget, Severity: "high", Budget: "web"},
+		{Type: EventBudget, Severity: "high", Budget: "data"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The first post is retried after the server error; the web budget
+	// goes to its own channel
+	if got := strings.Join(channels, ","); got != "#finops,#team-web" {
+		t.Errorf("posted to %s, want #finops,#team-web", got)
+	}
+
+	if _, err := NewSlackSink(config.SlackConfig{}, nil); err == nil {
+		t.Error("NewSlackSink accepted no webhook URL")
+	}
+}
+
+func TestSlackSinkPermanentFailure(t *testing.T) {
+	var posts int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posts++
+		http.Error(w, "invalid_payload", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	s, err := NewSlackSink(config.SlackConfig{WebhookURL: srv.URL}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Send(context.Background(), []Event{{Type: EventAnomaly}})
+	if err == nil || !strings.Contains(err.Error(), "invalid_payload") || posts != 1 {
+		t.Errorf("Send() = %v after %d posts, want one failed post", err, posts)
+	}
+}
@@ -0,0 +1,58 @@
+package notify
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sns/types"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+// snsPublisher publishes to an SNS topic. FIFO topics use the event key as
+// the message group so each service's messages stay ordered.
+type snsPublisher struct {
+	client   *sns.Client
+	topicARN string
+	fifo     bool
+}
+
+func newSNSPublisher(ctx context.Context, cfg config.QueueConfig) (*snsPublisher, error) {
+	var opts []func(*awsconfig.LoadOptions) error
+	if cfg.Region != "" {
+		opts = append(opts, awsconfig.WithRegion(cfg.Region))
+	}
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &snsPublisher{
+		client:   sns.NewFromConfig(awsCfg),
+		topicARN: cfg.Topic,
+		fifo:     strings.HasSuffix(cfg.Topic, ".fifo"),
+	}, nil
+}
+
+func (p *snsPublisher) Publish(ctx context.Context, key string, body []byte) error {
+	input := &sns.PublishInput{
+		TopicArn: aws.String(p.topicARN),
+		Message:  aws.String(string(body)),
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			"key": {DataType: aws.String("String"), StringValue: aws.String(key)},
+		},
+	}
+	if p.fifo {
+		input.MessageGroupId = aws.String(key)
+	}
+
+	_, err := p.client.Publish(ctx, input)
+	return err
+}
+
+func (p *snsPublisher) Close() error {
+	return nil
+}
@@ -0,0 +1,129 @@
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+// Webhook request headers. The signature is the hex HMAC-SHA256 of
+// "<timestamp>.<body>" keyed by the webhook secret, so receivers can reject
+// forged and replayed deliveries.
+const (
+	WebhookEventHeader     = "X-FinOps-Event"
+	WebhookDeliveryHeader  = "X-FinOps-Delivery" // stable per incident, for deduplication
+	WebhookTimestampHeader = "X-FinOps-Timestamp"
+	WebhookSignatureHeader = "X-FinOps-Signature"
+)
+
+// webhookForecast is the webhook event type of budget forecast alerts
+const webhookForecast = "forecast"
+
+// WebhookSink posts each event as JSON to an HTTP endpoint
+type WebhookSink struct {
+	name    string
+	url     string
+	secret  []byte
+	headers map[string]string
+	types   map[string]bool
+	client  *http.Client
+	now     func() time.Time
+}
+
+// NewWebhookSink creates a webhook sink
+func NewWebhookSink(cfg config.WebhookConfig) (*WebhookSink, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("webhook %s: url is not set", cfg.Name)
+	}
+	s := &WebhookSink{
+		name:    cfg.Name,
+		url:     cfg.URL,
+		secret:  []byte(cfg.Secret),
+		headers: cfg.Headers,
+		client:  &http.Client{Timeout: 30 * time.Second},
+		now:     time.Now,
+	}
+	if s.name == "" {
+		s.name = cfg.URL
+	}
+	if len(cfg.Types) > 0 {
+		s.types = make(map[string]bool)
+		for _, t := range cfg.Types {
+			switch t {
+			case EventAnomaly, EventBudget, webhookForecast:
+				s.types[t] = true
+			default:
+				return nil, fmt.Errorf("webhook %s: unknown event type %q (want anomaly, budget, or forecast)", s.name, t)
+			}
+		}
+	}
+	return s, nil
+}
+
+// Name returns the sink name
+func (s *WebhookSink) Name() string {
+	return "webhook:" + s.name
+}
+
+// Send posts the events one at a time, continuing past failures
+func (s *WebhookSink) Send(ctx context.Context, events []Event) error {
+	var errs []error
+	for _, e := range events {
+		eventType := webhookType(e)
+		if s.types != nil && !s.types[eventType] {
+			continue
+		}
+		if err := s.post(ctx, eventType, e); err != nil {
+			errs = append(errs, fmt.Errorf("failed to post %s: %w", e.Key, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *WebhookSink) post(ctx context.Context, eventType string, e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	return retry(ctx, func() (time.Duration, error) {
+		header := make(map[string]string, len(s.headers)+4)
+		for k, v := range s.headers {
+			header[k] = v
+		}
+		header[WebhookEventHeader] = eventType
+		header[WebhookDeliveryHeader] = dedupKey(e)
+		if len(s.secret) > 0 {
+			ts := strconv.FormatInt(s.now().Unix(), 10)
+			header[WebhookTimestampHeader] = ts
+			header[WebhookSignatureHeader] = "sha256=" + Sign(s.secret, ts, body)
+		}
+		return postJSON(ctx, s.client, s.url, header, body)
+	})
+}
+
+// Sign returns the hex signature of a webhook delivery
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookType separates budget forecasts from threshold alerts
+func webhookType(e Event) string {
+	if e.Type == EventBudget && e.Kind == webhookForecast {
+		return webhookForecast
+	}
+	return e.Type
+}
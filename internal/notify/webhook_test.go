@@ -0,0 +1,169 @@
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+// delivery is one request a test webhook endpoint received
+type delivery struct {
+	header http.Header
+	body   []byte
+}
+
+// webhookServer records deliveries and answers them with status
+func webhookServer(t *testing.T, status int) (*httptest.Server, *[]delivery) {
+	t.Helper()
+	var got []delivery
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got = append(got, delivery{header: r.Header.Clone(), body: body})
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &got
+}
+
+func TestWebhookSinkSend(t *testing.T) {
+	srv, got := webhookServer(t, http.StatusNoContent)
+	s, err := NewWebhookSink(config.WebhookConfig{
+		Name:    "siem",
+		URL:     srv.URL,
+		Secret:  "s3cret",
+		Headers: map[string]string{"Authorization": "Bearer t"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.now = func() time.Time { return time.Unix(1709631000, 0) }
+
+	anomaly := Event{Type: EventAnomaly, Key: "EC2", Provider: "aws", Account: "111", Service: "EC2", Amount: 300, OccurredAt: occurred}
+	forecast := Event{Type: EventBudget, Key: "platform", Budget: "platform", Kind: "forecast", Percent: 120, OccurredAt: occurred}
+	if err := s.Send(context.Background(), []Event{anomaly, forecast}); err != nil {
+		t.Fatal(err)
+	}
+	if len(*got) != 2 {
+		t.Fatalf("got %d deliveries, want 2", len(*got))
+	}
+
+	for i, want := range []struct {
+		event, delivery string
+		e               Event
+	}{
+		{"anomaly", "finops:anomaly:aws:111:EC2:2024-03-05", anomaly},
+		{"forecast", "finops:budget:platform:2024-03", forecast},
+	} {
+		d := (*got)[i]
+		if h := d.header.Get(WebhookEventHeader); h != want.event {
+			t.Errorf("delivery %d: %s = %s, want %s", i, WebhookEventHeader, h, want.event)
+		}
+		if h := d.header.Get(WebhookDeliveryHeader); h != want.delivery {
+			t.Errorf("delivery %d: %s = %s, want %s", i, WebhookDeliveryHeader, h, want.delivery)
+		}
+		if h := d.header.Get("Authorization"); h != "Bearer t" {
+			t.Errorf("delivery %d: Authorization = %q, want the configured header", i, h)
+		}
+		if h := d.header.Get(WebhookTimestampHeader); h != "1709631000" {
+			t.Errorf("delivery %d: %s = %s, want 1709631000", i, WebhookTimestampHeader, h)
+		}
+
+		// Verify the signature the way a receiver would
+		mac := hmac.New(sha256.New, []byte("s3cret"))
+		mac.Write([]byte("1709631000." + string(d.body)))
+		if sig := "sha256=" + hex.EncodeToString(mac.Sum(nil)); d.header.Get(WebhookSignatureHeader) != sig {
+			t.Errorf("delivery %d: %s = %s, want %s", i, WebhookSignatureHeader, d.header.Get(WebhookSignatureHeader), sig)
+		}
+
+		var e Event
+		if err := json.Unmarshal(d.body, &e); err != nil {
+			t.Fatal(err)
+		}
+		if e.Key != want.e.Key || e.Type != want.e.Type || e.Amount != want.e.Amount || !e.OccurredAt.Equal(want.e.OccurredAt) {
+			t.Errorf("delivery %d: body = %+v, want %+v", i, e, want.e)
+		}
+	}
+}
+
+func TestWebhookSinkUnsigned(t *testing.T) {
+	srv, got := webhookServer(t, http.StatusOK)
+	s, err := NewWebhookSink(config.WebhookConfig{URL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Name() != "webhook:"+srv.URL {
+		t.Errorf("Name() = %s, want the url", s.Name())
+	}
+	if err := s.Send(context.Background(), []Event{{Type: EventAnomaly, Key: "EC2"}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(*got) != 1 {
+		t.Fatalf("got %d deliveries, want 1", len(*got))
+	}
+	h := (*got)[0].header
+	if h.Get(WebhookSignatureHeader) != "" || h.Get(WebhookTimestampHeader) != "" {
+		t.Errorf("unsigned delivery has signature %q at %q", h.Get(WebhookSignatureHeader), h.Get(WebhookTimestampHeader))
+	}
+}
+
+func TestWebhookSinkTypes(t *testing.T) {
+	srv, got := webhookServer(t, http.StatusOK)
+	s, err := NewWebhookSink(config.WebhookConfig{URL: srv.URL, Types: []string{"forecast"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := []Event{
+		{Type: EventAnomaly, Key: "EC2"},
+		{Type: EventBudget, Key: "platform", Kind: "actual"},
+		{Type: EventBudget, Key: "platform", Kind: "forecast"},
+	}
+	if err := s.Send(context.Background(), events); err != nil {
+		t.Fatal(err)
+	}
+	if len(*got) != 1 || (*got)[0].header.Get(WebhookEventHeader) != "forecast" {
+		t.Errorf("got %d deliveries, want only the forecast", len(*got))
+	}
+}
+
+func TestWebhookSinkPermanentFailure(t *testing.T) {
+	srv, got := webhookServer(t, http.StatusForbidden)
+	s, err := NewWebhookSink(config.WebhookConfig{URL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Send(context.Background(), []Event{{Type: EventAnomaly, Key: "EC2"}, {Type: EventAnomaly, Key: "S3"}})
+	if err == nil || !strings.Contains(err.Error(), "EC2") || !strings.Contains(err.Error(), "S3") {
+		t.Errorf("Send() = %v, want failures for both events", err)
+	}
+	if len(*got) != 2 {
+		t.Errorf("got %d deliveries, want 2 with no retries", len(*got))
+	}
+}
+
+func TestNewWebhookSinkErrors(t *testing.T) {
+	for name, cfg := range map[string]config.WebhookConfig{
+		"no url":       {Name: "siem"},
+		"unknown type": {URL: "http://example.com", Types: []string{"anomaly", "invoice"}},
+	} {
+		if _, err := NewWebhookSink(cfg); err == nil {
+			t.Errorf("%s: NewWebhookSink() succeeded, want an error", name)
+		}
+	}
+}
+
+func TestSign(t *testing.T) {
+	// Reference value from: printf '1.{}' | openssl dgst -sha256 -hmac key
+	if got := Sign([]byte("key"), "1", []byte("{}")); got != "1ba6b8171186efc613e8bcc0cbdab2748f24984d7c5a84faa2637afa0e40d224" {
+		t.Errorf("Sign() = %s", got)
+	}
+}
@@ -0,0 +1,101 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestEscape(t *testing.T) {
+	tests := []struct {
+		text, want string
+	}{
+		{"Total (USD)", `Total \(USD\)`},
+		{`a\b`, `a\\b`},
+		{"€5", `\2005`},
+		{"café", `caf\351`},
+		{"tab\tand ✓", "tab?and ?"},
+	}
+	for _, tt := range tests {
+		if got := escape(tt.text); got != tt.want {
+			t.Errorf("escape(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestPage(t *testing.T) {
+	p := &Page{}
+	p.Text(10, 20, HelveticaBold, 12, "Hi")
+	p.TextRight(100, 40, 10, "1.00")
+	p.Rule(10, 100, 50)
+	want := "BT /F2 12.0 Tf 10.00 772.00 Td (Hi) Tj ET\n" +
+		"BT /F3 10.0 Tf 76.00 752.00 Td (1.00) Tj ET\n" +
+		"0.5 w 10.00 742.00 m 100.00 742.00 l S\n"
+	if got := p.content.String(); got != want {
+		t.Errorf("content = %q, want %q", got, want)
+	}
+}
+
+func TestWriteTo(t *testing.T) {
+	d := New()
+	d.AddPage().Text(72, 72, Helvetica, 10, "first")
+	d.AddPage().Text(72, 72, Courier, 10, "second")
+	var buf bytes.Buffer
+	n, err := d.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if n != int64(len(out)) {
+		t.Errorf("WriteTo() = %d, wrote %d bytes", n, len(out))
+	}
+	if !strings.HasPrefix(out, "%PDF-1.4\n") || !strings.HasSuffix(out, "%%EOF\n") {
+		t.Error("document lacks its header or end marker")
+	}
+	if !strings.Contains(out, "/Kids [6 0 R 8 0 R] /Count 2") {
+		t.Error("page tree does not list both pages")
+	}
+
+	// Every cross-reference entry points at its object
+	m := regexp.MustCompile(`startxref\n(\d+)\n`).FindStringSubmatch(out)
+	if m == nil {
+		t.Fatal("no startxref")
+	}
+	xref, _ := strconv.Atoi(m[1])
+	if !strings.HasPrefix(out[xref:], "xref\n0 10\n") {
+		t.Fatalf("startxref %d does not point at a table of 10 entries", xref)
+	}
+	entries := strings.Split(out[xref:], "\n")[3:12]
+	for i, entry := range entries {
+		offset, _ := strconv.Atoi(entry[:10])
+		if want := fmt.Sprintf("%d 0 obj\n", i+1); !strings.HasPrefix(out[offset:], want) {
+			t.Errorf("entry %d points at %q, want %q", i+1, out[offset:offset+10], want)
+		}
+	}
+}
+
+func TestSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "doc.pdf")
+	d := New()
+	d.AddPage()
+	if err := d.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	d.WriteTo(&buf)
+	if !bytes.Equal(data, buf.Bytes()) {
+		t.Error("saved file differs from the written document")
+	}
+	if err := d.Save(filepath.Join(path, "nested.pdf")); err == nil {
+		t.Error("Save() under a file succeeded, want an error")
+	}
+}
@@ -0,0 +1,244 @@
+// Package portal serves the showback portal, a self-service web view of the
+// cost history for teams that would otherwise wait for emailed reports
+package portal
+
+import (
+	_ "embed"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/anomaly"
+	"github.com/lvonguyen/finops-platform/internal/chargeback"
+	"github.com/lvonguyen/finops-platform/internal/query"
+	"github.com/lvonguyen/finops-platform/internal/reporter"
+	"github.com/lvonguyen/finops-platform/internal/store"
+)
+
+// DefaultDays is how many days of history the portal shows without a range
+const DefaultDays = 30
+
+// topServices is how many services the portal lists
+const topServices = 10
+
+//go:embed static/portal.html
+var pageTemplate string
+
+var tmpl = reporter.Template("portal", pageTemplate)
+
+// Portal renders the showback page from the history store
+type Portal struct {
+	history    store.CostStore
+	allocation chargeback.AllocatorConfig
+}
+
+// New creates a portal over the history store; cost centers follow the
+// chargeback allocation
+func New(history store.CostStore, allocation chargeback.AllocatorConfig) *Portal {
+	return &Portal{history: history, allocation: allocation}
+}
+
+// page is the data rendered by the portal template
+type page struct {
+	Start, End  string // inclusive, as YYYY-MM-DD
+	Where       string // filter expression, empty for all costs
+	Total       float64
+	Average     float64 // per day
+	Days        []line
+	Trend       string // SVG polyline points of the daily cost
+	Services    []line
+	CostCenters []line
+	Anomalies   []anomalyRow
+}
+
+// line is a labelled cost and its share of the range's total
+type line struct {
+	Name  string
+	Cost  float64
+	Share float64
+}
+
+type anomalyRow struct {
+	store.AnomalyState
+	Badge string
+}
+
+// ServeHTTP renders the page for ?start= and ?end= (YYYY-MM-DD, inclusive),
+// defaulting to the last DefaultDays days of history, and the costs matching
+// ?where= (a filter expression, see package query), defaulting to all
+func (p *Portal) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	start, end, err := p.dateRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var where *query.Filter
+	if v := r.URL.Query().Get("where"); v != "" {
+		if where, err = query.Parse(v); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	data, err := p.build(r, start, end, where)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := tmpl.Execute(w, data); err != nil {
+		http.Error(w, fmt.Sprintf("failed to execute template: %v", err), http.StatusInternalServerError)
+	}
+}
+
+// dateRange returns the requested range as [start, end)
+func (p *Portal) dateRange(r *http.Request) (start, end time.Time, err error) {
+	q := r.URL.Query()
+	if v := q.Get("end"); v != "" {
+		last, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid end date %q: want YYYY-MM-DD", v)
+		}
+		end = last.AddDate(0, 0, 1)
+	} else {
+		latest, err := p.history.LatestIngestDate(r.Context())
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("failed to read history: %w", err)
+		}
+		if latest.IsZero() {
+			latest = time.Now().UTC()
+		}
+		end = time.Date(latest.Year(), latest.Month(), latest.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, 1)
+	}
+
+	if v := q.Get("start"); v != "" {
+		if start, err = time.Parse("2006-01-02", v); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid start date %q: want YYYY-MM-DD", v)
+		}
+	} else {
+		start = end.AddDate(0, 0, -DefaultDays)
+	}
+	if !start.Before(end) {
+		return time.Time{}, time.Time{}, fmt.Errorf("start date must not be after end date")
+	}
+	return start, end, nil
+}
+
+// build totals the range's history matching where for the page
+func (p *Portal) build(r *http.Request, start, end time.Time, where *query.Filter) (*page, error) {
+	records, err := p.history.QueryRange(r.Context(), start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query history: %w", err)
+	}
+	records = where.Select(records)
+	data := &page{Start: start.Format("2006-01-02"), End: end.AddDate(0, 0, -1).Format("2006-01-02")}
+	if where != nil {
+		data.Where = where.String()
+	}
+
+	byDay := make(map[string]float64)
+	byService := make(map[string]float64)
+	for _, rec := range records {
+		data.Total += rec.Cost
+		byDay[rec.Date.Format("2006-01-02")] += rec.Cost
+		byService[rec.Cloud+":"+rec.Service] += rec.Cost
+	}
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		date := day.Format("2006-01-02")
+		data.Days = append(data.Days, line{Name: date, Cost: byDay[date]})
+	}
+	data.Average = data.Total / float64(len(data.Days))
+	data.Trend = sparkline(data.Days, 1000, 120)
+	data.Services = ranked(byService, data.Total)
+	if len(data.Services) > topServices {
+		data.Services = data.Services[:topServices]
+	}
+
+	byCenter := make(map[string]float64)
+	for center, alloc := range chargeback.NewAllocator(p.allocation).Allocate(records) {
+		byCenter[center] = alloc.TotalCost
+	}
+	data.CostCenters = ranked(byCenter, data.Total)
+
+	states, err := p.history.LoadAnomalyStates(r.Context())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load anomaly states: %w", err)
+	}
+	for _, st := range states {
+		if st.Date.Before(start) || !st.Date.Before(end) {
+			continue
+		}
+		data.Anomalies = append(data.Anomalies, anomalyRow{AnomalyState: st, Badge: badge(st.Status)})
+	}
+	sort.Slice(data.Anomalies, func(i, j int) bool {
+		if !data.Anomalies[i].Date.Equal(data.Anomalies[j].Date) {
+			return data.Anomalies[i].Date.After(data.Anomalies[j].Date)
+		}
+		return data.Anomalies[i].ID < data.Anomalies[j].ID
+	})
+	return data, nil
+}
+
+// ranked returns a cost map as lines, largest first
+func ranked(costs map[string]float64, total float64) []line {
+	lines := make([]line, 0, len(costs))
+	for name, cost := range costs {
+		l := line{Name: name, Cost: cost}
+		if total != 0 {
+			l.Share = cost / total * 100
+		}
+		lines = append(lines, l)
+	}
+	sort.Slice(lines, func(i, j int) bool {
+		if lines[i].Cost != lines[j].Cost {
+			return lines[i].Cost > lines[j].Cost
+		}
+		return lines[i].Name < lines[j].Name
+	})
+	return lines
+}
+
+// sparkline returns SVG polyline points plotting daily cost in a width x
+// height box, scaled to the peak day
+func sparkline(days []line, width, height int) string {
+	var peak float64
+	for _, d := range days {
+		peak = math.Max(peak, d.Cost)
+	}
+	step := 0.0
+	if len(days) > 1 {
+		step = float64(width) / float64(len(days)-1)
+	}
+	coords := make([]string, len(days))
+	for i, d := range days {
+		y := float64(height)
+		if peak > 0 {
+			y -= d.Cost / peak * float64(height)
+		}
+		coords[i] = fmt.Sprintf("%.1f,%.1f", float64(i)*step, y)
+	}
+	return strings.Join(coords, " ")
+}
+
+// badge returns the badge class of an anomaly status
+func badge(status string) string {
+	switch status {
+	case anomaly.StatusAcknowledged:
+		return "medium"
+	case anomaly.StatusSuppressed:
+		return "low"
+	default:
+		return "high"
+	}
+}
@@ -0,0 +1,140 @@
+package portal
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/anomaly"
+	"github.com/lvonguyen/finops-platform/internal/chargeback"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+	"github.com/lvonguyen/finops-platform/internal/store"
+)
+
+var day = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+// charge returns a record tagged with a cost center
+func charge(cloud, service, costCenter string, date time.Time, cost float64) normalizer.CostRecord {
+	return normalizer.CostRecord{Cloud: cloud, Account: "111", Service: service, Date: date, Cost: cost, Tags: map[string]string{"cost_center": costCenter}}
+}
+
+// newPortal serves ten days of history from March 1: 10 a day of EC2 for
+// CC-1 and 5 of BigQuery for CC-2, with another 15 of EC2 on the last day
+func newPortal(t *testing.T) *Portal {
+	t.Helper()
+	ctx := context.Background()
+	history, err := store.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var records []normalizer.CostRecord
+	for i := 0; i < 10; i++ {
+		d := day.AddDate(0, 0, i)
+		records = append(records, charge("aws", "EC2", "CC-1", d, 10), charge("gcp", "BigQuery", "CC-2", d, 5))
+	}
+	records = append(records, charge("aws", "EC2", "CC-1", day.AddDate(0, 0, 9), 15))
+	if err := history.SaveRecords(ctx, records); err != nil {
+		t.Fatal(err)
+	}
+	for _, st := range []store.AnomalyState{
+		{ID: "a1", Status: anomaly.StatusOpen, Scope: "aws/111/EC2", Date: day},
+		{ID: "a2", Status: anomaly.StatusAcknowledged, Scope: "gcp/111/BigQuery", Date: day.AddDate(0, 0, 8)},
+		{ID: "a3", Status: anomaly.StatusOpen, Scope: "aws/111/EC2", Date: day.AddDate(0, 0, 9)},
+	} {
+		if err := history.SaveAnomalyState(ctx, st); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return New(history, chargeback.AllocatorConfig{PrimaryTag: "cost_center"})
+}
+
+func TestBuild(t *testing.T) {
+	p := newPortal(t)
+	data, err := p.build(httptest.NewRequest(http.MethodGet, "/", nil), day.AddDate(0, 0, 8), day.AddDate(0, 0, 10), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if data.Start != "2024-03-09" || data.End != "2024-03-10" || data.Total != 45 || data.Average != 22.5 {
+		t.Errorf("page = %s to %s, total %v, average %v; want 2024-03-09 to 2024-03-10, 45 and 22.5", data.Start, data.End, data.Total, data.Average)
+	}
+	wantDays := []line{{Name: "2024-03-09", Cost: 15}, {Name: "2024-03-10", Cost: 30}}
+	if !reflect.DeepEqual(data.Days, wantDays) {
+		t.Errorf("Days = %+v, want %+v", data.Days, wantDays)
+	}
+	if data.Trend != "0.0,60.0 1000.0,0.0" {
+		t.Errorf("Trend = %q, want the second day at the peak", data.Trend)
+	}
+	share := func(cost float64) float64 { return cost / data.Total * 100 }
+	wantServices := []line{{Name: "aws:EC2", Cost: 35, Share: share(35)}, {Name: "gcp:BigQuery", Cost: 10, Share: share(10)}}
+	if !reflect.DeepEqual(data.Services, wantServices) {
+		t.Errorf("Services = %+v, want %+v", data.Services, wantServices)
+	}
+	wantCenters := []line{{Name: "CC-1", Cost: 35, Share: share(35)}, {Name: "CC-2", Cost: 10, Share: share(10)}}
+	if !reflect.DeepEqual(data.CostCenters, wantCenters) {
+		t.Errorf("CostCenters = %+v, want %+v", data.CostCenters, wantCenters)
+	}
+
+	// Anomalies in range, newest first
+	var anomalies []string
+	for _, a := range data.Anomalies {
+		anomalies = append(anomalies, a.ID+"/"+a.Badge)
+	}
+	if want := []string{"a3/high", "a2/medium"}; !reflect.DeepEqual(anomalies, want) {
+		t.Errorf("Anomalies = %v, want %v", anomalies, want)
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	p := newPortal(t)
+	tests := []struct {
+		name   string
+		method string
+		target string
+		want   int
+		body   string
+	}{
+		// The default range is the 30 days to the latest ingested day
+		{"default range", http.MethodGet, "/", http.StatusOK, "2024-02-10 to 2024-03-10"},
+		{"date range", http.MethodGet, "/?start=2024-03-09&end=2024-03-10", http.StatusOK, "$45.00"},
+		{"start only", http.MethodGet, "/?start=2024-03-05", http.StatusOK, "2024-03-05 to 2024-03-10"},
+		{"bad start", http.MethodGet, "/?start=March", http.StatusBadRequest, "invalid start date"},
+		{"bad end", http.MethodGet, "/?end=2024-3-1", http.StatusBadRequest, "invalid end date"},
+		{"backwards", http.MethodGet, "/?start=2024-03-09&end=2024-03-01", http.StatusBadRequest, "must not be after"},
+		{"where", http.MethodGet, "/?start=2024-03-09&end=2024-03-10&where=cloud+%3D+aws", http.StatusOK, "$35.00"},
+		{"where shown", http.MethodGet, "/?start=2024-03-09&end=2024-03-10&where=cloud+%3D+aws", http.StatusOK, "2024-03-09 to 2024-03-10, where cloud = aws"},
+		{"bad where", http.MethodGet, "/?where=owner+%3D+web", http.StatusBadRequest, `unknown field "owner"`},
+		{"other path", http.MethodGet, "/favicon.ico", http.StatusNotFound, ""},
+		{"post", http.MethodPost, "/", http.StatusMethodNotAllowed, ""},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, nil))
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, rec.Code, tt.want)
+		}
+		if !strings.Contains(rec.Body.String(), tt.body) {
+			t.Errorf("%s: body does not contain %q", tt.name, tt.body)
+		}
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	tests := []struct {
+		days []line
+		want string
+	}{
+		{[]line{{Cost: 0}, {Cost: 5}, {Cost: 10}}, "0.0,100.0 50.0,50.0 100.0,0.0"},
+		{[]line{{Cost: 0}, {Cost: 0}}, "0.0,100.0 100.0,100.0"},
+		{[]line{{Cost: 3}}, "0.0,0.0"},
+	}
+	for _, tt := range tests {
+		if got := sparkline(tt.days, 100, 100); got != tt.want {
+			t.Errorf("sparkline(%+v) = %q, want %q", tt.days, got, tt.want)
+		}
+	}
+}
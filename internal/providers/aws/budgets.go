@@ -0,0 +1,232 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+	internalConfig "github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/telemetry"
+)
+
+// budgetsAPI is a global service answering in us-east-1
+var budgetsAPI = jsonAPI{
+	endpoint: "https://budgets.amazonaws.com/",
+	service:  "budgets",
+	region:   "us-east-1",
+	target:   "AWSBudgetServiceGateway",
+}
+
+// spend is an AWS Budgets amount, a decimal string
+type spend struct {
+	Amount string
+	Unit   string
+}
+
+func (s *spend) value() float64 {
+	if s == nil {
+		return 0
+	}
+	v, _ := strconv.ParseFloat(s.Amount, 64)
+	return v
+}
+
+// budget is the part of an AWS budget read and written here
+type budget struct {
+	BudgetName      string
+	BudgetLimit     *spend
+	TimeUnit        string
+	BudgetType      string
+	CostFilters     map[string][]string `json:",omitempty"`
+	CalculatedSpend *struct {
+		ActualSpend     *spend
+		ForecastedSpend *spend
+	} `json:",omitempty"`
+}
+
+// callerAccount returns the ID of the account the credentials belong to,
+// which owns the budgets
+func callerAccount(ctx context.Context, awsCfg aws.Config) (string, error) {
+	out, err := sts.NewFromConfig(awsCfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", fmt.Errorf("failed to get caller identity: %w", err)
+	}
+	return aws.ToString(out.Account), nil
+}
+
+// listBudgets returns the account's monthly cost budgets. A budget filtered
+// to one linked account is scoped to it.
+func listBudgets(ctx context.Context, awsCfg aws.Config) ([]aggregator.BudgetStatus, error) {
+	account, err := callerAccount(ctx, awsCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]aggregator.BudgetStatus, 0)
+	token := ""
+	for page := 1; ; page++ {
+		body, err := json.Marshal(struct {
+			AccountId string
+			NextToken string `json:",omitempty"`
+		}{account, token})
+		if err != nil {
+			return nil, err
+		}
+
+		callCtx, span := telemetry.StartCall(ctx, "aws.budgets.DescribeBudgets", page)
+		var result struct {
+			Budgets   []budget
+			NextToken string
+		}
+		err = budgetsAPI.call(callCtx, awsCfg, "DescribeBudgets", body, &result)
+		telemetry.End(span, len(result.Budgets), err)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list budgets: %w", classifyError(err))
+		}
+
+		for _, b := range result.Budgets {
+			if b.BudgetType != "COST" || b.TimeUnit != "MONTHLY" {
+				continue
+			}
+			status := aggregator.BudgetStatus{
+				BudgetName: b.BudgetName,
+				Provider:   "aws",
+				Limit:      b.BudgetLimit.value(),
+			}
+			if linked := b.CostFilters["LinkedAccount"]; len(linked) == 1 {
+				status.Scope = linked[0]
+			}
+			if b.CalculatedSpend != nil {
+				status.CurrentSpend = b.CalculatedSpend.ActualSpend.value()
+				status.ForecastSpend = b.CalculatedSpend.ForecastedSpend.value()
+			}
+			statuses = append(statuses, status)
+		}
+
+		if result.NextToken == "" {
+			return statuses, nil
+		}
+		token = result.NextToken
+	}
+}
+
+// createBudget creates a monthly cost budget in USD, filtered to the
+// budget's scope account, emailing notify_emails at each alert_at
+// percentage of actual spend
+func createBudget(ctx context.Context, awsCfg aws.Config, b internalConfig.Budget) error {
+	account, err := callerAccount(ctx, awsCfg)
+	if err != nil {
+		return err
+	}
+
+	type subscriber struct {
+		SubscriptionType string
+		Address          string
+	}
+	type notification struct {
+		NotificationType   string
+		ComparisonOperator string
+		Threshold          float64
+		ThresholdType      string
+	}
+	type notificationWithSubscribers struct {
+		Notification notification
+		Subscribers  []subscriber
+	}
+
+	created := budget{
+		BudgetName:  b.Name,
+		BudgetLimit: &spend{Amount: strconv.FormatFloat(b.MonthlyLimit, 'f', 2, 64), Unit: "USD"},
+		TimeUnit:    "MONTHLY",
+		BudgetType:  "COST",
+	}
+	if b.Scope != "" {
+		created.CostFilters = map[string][]string{"LinkedAccount": {b.Scope}}
+	}
+
+	// Every notification needs a subscriber
+	var notifications []notificationWithSubscribers
+	if len(b.NotifyEmails) > 0 {
+		subscribers := make([]subscriber, len(b.NotifyEmails))
+		for i, email := range b.NotifyEmails {
+			subscribers[i] = subscriber{SubscriptionType: "EMAIL", Address: email}
+		}
+		for _, pct := range b.AlertAt {
+			notifications = append(notifications, notificationWithSubscribers{
+				Notification: notification{
+					NotificationType:   "ACTUAL",
+					ComparisonOperator: "GREATER_THAN",
+					Threshold:          float64(pct),
+					ThresholdType:      "PERCENTAGE",
+				},
+				Subscribers: subscribers,
+			})
+		}
+	}
+
+	body, err := json.Marshal(struct {
+		AccountId                    string
+		Budget                       budget
+		NotificationsWithSubscribers []notificationWithSubscribers `json:",omitempty"`
+	}{account, created, notifications})
+	if err != nil {
+		return err
+	}
+
+	callCtx, span := telemetry.StartCall(ctx, "aws.budgets.CreateBudget", 1)
+	err = budgetsAPI.call(callCtx, awsCfg, "CreateBudget", body, nil)
+	telemetry.End(span, 1, err)
+	if err != nil {
+		return fmt.Errorf("failed to create budget %s: %w", b.Name, classifyError(err))
+	}
+	return nil
+}
+
+// GetBudgets retrieves the monthly cost budgets from AWS Budgets
+func (p *CostProvider) GetBudgets(ctx context.Context) ([]aggregator.BudgetStatus, error) {
+	var statuses []aggregator.BudgetStatus
+	err := p.calls.Do(ctx, func(ctx context.Context) error {
+		var err error
+		statuses, err = listBudgets(ctx, p.credentials())
+		return err
+	})
+	return statuses, err
+}
+
+// CreateBudget creates a declared budget in AWS Budgets
+func (p *CostProvider) CreateBudget(ctx context.Context, b internalConfig.Budget) error {
+	awsCfg := p.credentials()
+	return p.calls.Do(ctx, func(ctx context.Context) error {
+		return createBudget(ctx, awsCfg, b)
+	})
+}
+
+// credentials returns the configured account's credentials
+func (p *CostProvider) credentials() aws.Config {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.awsCfg
+}
+
+// GetBudgets retrieves the monthly cost budgets from AWS Budgets
+func (p *CURProvider) GetBudgets(ctx context.Context) ([]aggregator.BudgetStatus, error) {
+	awsCfg, err := loadConfig(ctx, p.config, p.config.Region)
+	if err != nil {
+		return nil, err
+	}
+	return listBudgets(ctx, awsCfg)
+}
+
+// CreateBudget creates a declared budget in AWS Budgets
+func (p *CURProvider) CreateBudget(ctx context.Context, b internalConfig.Budget) error {
+	awsCfg, err := loadConfig(ctx, p.config, p.config.Region)
+	if err != nil {
+		return err
+	}
+	return createBudget(ctx, awsCfg, b)
+}
@@ -0,0 +1,151 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+	internalConfig "github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/resilience"
+)
+
+// callerIdentity is STS's answer to GetCallerIdentity for account 123456789012
+const callerIdentity = `<GetCallerIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+<GetCallerIdentityResult><Arn>arn:aws:iam::123456789012:user/finops</Arn><UserId>AIDA</UserId><Account>123456789012</Account></GetCallerIdentityResult>
+<ResponseMetadata><RequestId>1</RequestId></ResponseMetadata></GetCallerIdentityResponse>`
+
+// withBudgets serves STS and the Budgets API, passing each Budgets call's
+// action and body to handler, and returns a config calling them
+func withBudgets(t *testing.T, handler func(action string, body map[string]any) any) aws.Config {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		target := r.Header.Get("X-Amz-Target")
+		if target == "" {
+			w.Header().Set("Content-Type", "text/xml")
+			w.Write([]byte(callerIdentity))
+			return
+		}
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		json.NewEncoder(w).Encode(handler(strings.TrimPrefix(target, "AWSBudgetServiceGateway."), body))
+	}))
+	t.Cleanup(srv.Close)
+	saved := budgetsAPI
+	budgetsAPI.endpoint = srv.URL
+	t.Cleanup(func() { budgetsAPI = saved })
+
+	return aws.Config{
+		Region:           "us-east-1",
+		Credentials:      testCredentials,
+		BaseEndpoint:     aws.String(srv.URL),
+		RetryMaxAttempts: 1,
+	}
+}
+
+// budgetsProvider returns a provider with awsCfg's credentials
+func budgetsProvider(t *testing.T, awsCfg aws.Config) *CostProvider {
+	t.Helper()
+	calls, err := resilience.New("aws-test", internalConfig.CallConfig{}, retryable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &CostProvider{awsCfg: awsCfg, calls: calls}
+}
+
+func TestGetBudgets(t *testing.T) {
+	awsCfg := withBudgets(t, func(action string, body map[string]any) any {
+		if action != "DescribeBudgets" || body["AccountId"] != "123456789012" {
+			t.Errorf("%s %v, want DescribeBudgets of the caller's account", action, body)
+		}
+		if body["NextToken"] == nil {
+			return map[string]any{"NextToken": "page-2", "Budgets": []map[string]any{
+				{"BudgetName": "prod", "BudgetType": "COST", "TimeUnit": "MONTHLY",
+					"BudgetLimit":     map[string]string{"Amount": "1000.0", "Unit": "USD"},
+					"CostFilters":     map[string][]string{"LinkedAccount": {"111"}},
+					"CalculatedSpend": map[string]any{"ActualSpend": map[string]string{"Amount": "420.5"}, "ForecastedSpend": map[string]string{"Amount": "910"}}},
+				{"BudgetName": "usage", "BudgetType": "USAGE", "TimeUnit": "MONTHLY"},
+				{"BudgetName": "yearly", "BudgetType": "COST", "TimeUnit": "ANNUALLY"},
+			}}
+		}
+		return map[string]any{"Budgets": []map[string]any{
+			{"BudgetName": "shared", "BudgetType": "COST", "TimeUnit": "MONTHLY",
+				"BudgetLimit": map[string]string{"Amount": "300", "Unit": "USD"},
+				"CostFilters": map[string][]string{"LinkedAccount": {"111", "222"}}},
+		}}
+	})
+
+	got, err := budgetsProvider(t, awsCfg).GetBudgets(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []aggregator.BudgetStatus{
+		{BudgetName: "prod", Provider: "aws", Scope: "111", Limit: 1000, CurrentSpend: 420.5, ForecastSpend: 910},
+		{BudgetName: "shared", Provider: "aws", Limit: 300}, // several accounts, so no single scope
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetBudgets() = %+v, want %+v", got, want)
+	}
+}
+
+func TestCreateBudget(t *testing.T) {
+	var bodies []map[string]any
+	awsCfg := withBudgets(t, func(action string, body map[string]any) any {
+		if action != "CreateBudget" {
+			t.Errorf("unexpected action %s", action)
+		}
+		bodies = append(bodies, body)
+		return map[string]any{}
+	})
+	p := budgetsProvider(t, awsCfg)
+
+	err := p.CreateBudget(context.Background(), internalConfig.Budget{
+		Name: "dev", Scope: "222", MonthlyLimit: 250, AlertAt: []int{80, 100}, NotifyEmails: []string{"team@example.com"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.CreateBudget(context.Background(), internalConfig.Budget{Name: "quiet", MonthlyLimit: 10, AlertAt: []int{50}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var created struct {
+		AccountId                    string
+		Budget                       budget
+		NotificationsWithSubscribers []struct {
+			Notification struct {
+				NotificationType string
+				Threshold        float64
+			}
+			Subscribers []struct{ Address string }
+		}
+	}
+	data, _ := json.Marshal(bodies[0])
+	if err := json.Unmarshal(data, &created); err != nil {
+		t.Fatal(err)
+	}
+	b := created.Budget
+	if created.AccountId != "123456789012" || b.BudgetName != "dev" || b.BudgetType != "COST" || b.TimeUnit != "MONTHLY" ||
+		b.BudgetLimit.Amount != "250.00" || !reflect.DeepEqual(b.CostFilters, map[string][]string{"LinkedAccount": {"222"}}) {
+		t.Errorf("created %+v in %s, want a monthly 250.00 budget on account 222", b, created.AccountId)
+	}
+	n := created.NotificationsWithSubscribers
+	if len(n) != 2 || n[0].Notification.Threshold != 80 || n[1].Notification.Threshold != 100 ||
+		n[0].Notification.NotificationType != "ACTUAL" || n[1].Subscribers[0].Address != "team@example.com" {
+		t.Errorf("notifications = %+v, want 80%% and 100%% to team@example.com", n)
+	}
+
+	// Without recipients there are no notifications, nor a scope filter
+	if _, ok := bodies[1]["NotificationsWithSubscribers"]; ok {
+		t.Errorf("notifications sent without recipients: %v", bodies[1])
+	}
+	if _, ok := bodies[1]["Budget"].(map[string]any)["CostFilters"]; ok {
+		t.Errorf("unscoped budget filtered: %v", bodies[1]["Budget"])
+	}
+}
@@ -3,24 +3,66 @@ package aws
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
 	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
 	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
 
-	internalConfig "github.com/lvonguyen/finops-platform/internal/config"
 	"github.com/lvonguyen/finops-platform/internal/aggregator"
+	internalConfig "github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+	"github.com/lvonguyen/finops-platform/internal/providers"
+	"github.com/lvonguyen/finops-platform/internal/resilience"
+	"github.com/lvonguyen/finops-platform/internal/telemetry"
 )
 
+// AccountNameTag is the tag carrying an account's name from Organizations
+const AccountNameTag = "aws:account-name"
+
 // CostProvider implements aggregator.CostProvider for AWS
 type CostProvider struct {
+	awsCfg aws.Config // credentials of the configured (management) account
 	client *costexplorer.Client
 	config internalConfig.AWSConfig
+	calls  *resilience.Caller
+
+	mu      sync.Mutex
+	members map[string]*costexplorer.Client // account -> client assuming member_role there
+	org     []orgAccount                    // organization accounts, listed once
+	listed  bool
+}
+
+func init() {
+	providers.MustRegister(providers.Provider{
+		Name: "aws",
+		// CUR exports are read in place of Cost Explorer when enabled; being
+		// files, they are not cached
+		New: func(ctx context.Context, cfg *internalConfig.Config) (aggregator.CostProvider, any, error) {
+			if cfg.AWS.CUR.Enabled {
+				p, err := NewCURProvider(ctx, cfg.AWS)
+				if err != nil {
+					return nil, nil, fmt.Errorf("CUR: %w", err)
+				}
+				return p, nil, nil
+			}
+			p, err := NewCostProvider(ctx, cfg.AWS)
+			if err != nil {
+				return nil, nil, err
+			}
+			return p, cfg.AWS, nil
+		},
+	})
 }
 
 // NewCostProvider creates a new AWS cost provider
@@ -28,13 +70,36 @@ func NewCostProvider(ctx context.Context, cfg internalConfig.AWSConfig) (*CostPr
 	if !cfg.Enabled {
 		return nil, fmt.Errorf("AWS provider is disabled")
 	}
+	if cfg.ResourceLevel && cfg.Granularity == "MONTHLY" {
+		return nil, fmt.Errorf("AWS resource_level requires DAILY granularity")
+	}
+
+	awsCfg, err := loadConfig(ctx, cfg, cfg.Region)
+	if err != nil {
+		return nil, err
+	}
+	calls, err := resilience.New("aws", cfg.Calls, retryable)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CostProvider{
+		awsCfg:  awsCfg,
+		client:  costexplorer.NewFromConfig(awsCfg),
+		config:  cfg,
+		calls:   calls,
+		members: make(map[string]*costexplorer.Client),
+	}, nil
+}
 
+// loadConfig loads credentials for a region, assuming the configured role
+func loadConfig(ctx context.Context, cfg internalConfig.AWSConfig, region string) (aws.Config, error) {
 	// Load AWS configuration
 	awsCfg, err := config.LoadDefaultConfig(ctx,
-		config.WithRegion(cfg.Region),
+		config.WithRegion(region),
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+		return aws.Config{}, fmt.Errorf("failed to load AWS config: %w", err)
 	}
 
 	// If role ARN specified, assume role
@@ -44,12 +109,95 @@ func NewCostProvider(ctx context.Context, cfg internalConfig.AWSConfig) (*CostPr
 		awsCfg.Credentials = aws.NewCredentialsCache(creds)
 	}
 
-	client := costexplorer.NewFromConfig(awsCfg)
+	return awsCfg, nil
+}
 
-	return &CostProvider{
-		client: client,
-		config: cfg,
-	}, nil
+// CheckCredentials resolves credentials as the providers do, assuming the
+// configured role, and returns the ARN they authenticate as
+func CheckCredentials(ctx context.Context, cfg internalConfig.AWSConfig) (string, error) {
+	awsCfg, err := loadConfig(ctx, cfg, cfg.Region)
+	if err != nil {
+		return "", err
+	}
+	out, err := sts.NewFromConfig(awsCfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", fmt.Errorf("failed to get caller identity: %w", err)
+	}
+	return aws.ToString(out.Arn), nil
+}
+
+// RefreshCredentials reloads the credential chain and re-assumes the roles,
+// picking up rotated keys or a new session after expiry
+func (p *CostProvider) RefreshCredentials(ctx context.Context) error {
+	awsCfg, err := loadConfig(ctx, p.config, p.config.Region)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.awsCfg = awsCfg
+	p.client = costexplorer.NewFromConfig(awsCfg)
+	p.members = make(map[string]*costexplorer.Client)
+	return nil
+}
+
+// member returns a Cost Explorer client in an account, assuming member_role
+// there with the configured credentials
+func (p *CostProvider) member(account string) *costexplorer.Client {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if client, ok := p.members[account]; ok {
+		return client
+	}
+	memberCfg := p.awsCfg.Copy()
+	role := fmt.Sprintf("arn:aws:iam::%s:role/%s", account, p.config.MemberRole)
+	memberCfg.Credentials = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(sts.NewFromConfig(p.awsCfg), role))
+	client := costexplorer.NewFromConfig(memberCfg)
+	p.members[account] = client
+	return client
+}
+
+// organization lists the organization's accounts on first use. Without
+// Organizations access it returns nil, logging why once.
+func (p *CostProvider) organization(ctx context.Context) []orgAccount {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.listed {
+		accounts, err := listAccounts(ctx, p.awsCfg)
+		if err != nil {
+			log.Printf("Warning: AWS: %v", err)
+		}
+		p.org, p.listed = accounts, true
+	}
+	return p.org
+}
+
+// authErrorCodes are AWS error codes for expired or rejected credentials
+var authErrorCodes = map[string]bool{
+	"ExpiredToken":                true,
+	"ExpiredTokenException":       true,
+	"InvalidClientTokenId":        true,
+	"UnrecognizedClientException": true,
+	"InvalidSignatureException":   true,
+	"SignatureDoesNotMatch":       true,
+	"AccessDeniedException":       true,
+	"AccessDenied":                true,
+}
+
+// classifyError marks credential failures with aggregator.ErrAuthExpired
+func classifyError(err error) error {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && authErrorCodes[apiErr.ErrorCode()] {
+		return fmt.Errorf("%w: %w", aggregator.ErrAuthExpired, err)
+	}
+	return err
+}
+
+// retryable reports errors worth retrying after a backoff: throttling,
+// server errors and dropped connections, even once the SDK's own retries
+// are exhausted
+func retryable(err error) bool {
+	return retry.IsErrorRetryables(retry.DefaultRetryables).IsErrorRetryable(err) == aws.TrueTernary
 }
 
 // Name returns the provider name
@@ -59,6 +207,178 @@ func (p *CostProvider) Name() string {
 
 // GetCosts retrieves costs from AWS Cost Explorer
 func (p *CostProvider) GetCosts(ctx context.Context, start, end time.Time) ([]aggregator.CostEntry, error) {
+	return p.fetch(ctx, start, end, aggregator.CostFilter{})
+}
+
+// GetFilteredCosts retrieves costs matching filter, applied as a Cost
+// Explorer filter expression
+func (p *CostProvider) GetFilteredCosts(ctx context.Context, start, end time.Time, filter aggregator.CostFilter) ([]aggregator.CostEntry, error) {
+	return p.fetch(ctx, start, end, filter)
+}
+
+// fetch queries the configured accounts: each through member_role when it
+// is set, otherwise the management account's data filtered to them by
+// LINKED_ACCOUNT. Entries are tagged with account names when enabled.
+func (p *CostProvider) fetch(ctx context.Context, start, end time.Time, filter aggregator.CostFilter) ([]aggregator.CostEntry, error) {
+	var entries []aggregator.CostEntry
+	var err error
+	if p.config.MemberRole != "" {
+		entries, err = p.fanOut(ctx, start, end, filter)
+	} else {
+		if len(p.config.AccountIDs) > 0 {
+			var accounts []string
+			for _, id := range p.config.AccountIDs {
+				if filter.MatchesAccount(id) {
+					accounts = append(accounts, id)
+				}
+			}
+			if len(accounts) == 0 {
+				return nil, nil
+			}
+			filter.Accounts = accounts
+		}
+		p.mu.Lock()
+		client := p.client
+		p.mu.Unlock()
+		entries, err = p.queryCosts(ctx, client, start, end, filterExpression(filter))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if p.config.AccountNames {
+		p.nameAccounts(ctx, entries)
+	}
+	return entries, nil
+}
+
+// fanOut queries each account through member_role, at most concurrency at
+// a time. Without account_ids every active organization account is queried.
+// Any failed account fails the fetch, so partial totals are never reported.
+func (p *CostProvider) fanOut(ctx context.Context, start, end time.Time, filter aggregator.CostFilter) ([]aggregator.CostEntry, error) {
+	accounts := p.config.AccountIDs
+	if len(accounts) == 0 {
+		for _, a := range p.organization(ctx) {
+			if a.Status == "ACTIVE" {
+				accounts = append(accounts, a.ID)
+			}
+		}
+		if len(accounts) == 0 {
+			return nil, fmt.Errorf("member_role needs account_ids or Organizations access to list accounts")
+		}
+	}
+
+	expr := filterExpression(filter)
+	results := make([][]aggregator.CostEntry, len(accounts))
+	errs := make([]error, len(accounts))
+	sem := make(chan struct{}, p.config.Concurrency)
+	var wg sync.WaitGroup
+	for i, account := range accounts {
+		if !filter.MatchesAccount(account) {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, account string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			entries, err := p.queryCosts(ctx, p.member(account), start, end, expr)
+			if err != nil {
+				errs[i] = fmt.Errorf("account %s: %w", account, err)
+				return
+			}
+			results[i] = entries
+		}(i, account)
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	var entries []aggregator.CostEntry
+	for _, r := range results {
+		entries = append(entries, r...)
+	}
+	return entries, nil
+}
+
+// nameAccounts tags entries with their account's name from Organizations
+func (p *CostProvider) nameAccounts(ctx context.Context, entries []aggregator.CostEntry) {
+	names := make(map[string]string)
+	for _, a := range p.organization(ctx) {
+		names[a.ID] = a.Name
+	}
+	if len(names) == 0 {
+		return
+	}
+	for i := range entries {
+		name, ok := names[entries[i].AccountID]
+		if !ok {
+			continue
+		}
+		tags := make(map[string]string, len(entries[i].Tags)+1)
+		for k, v := range entries[i].Tags {
+			tags[k] = v
+		}
+		tags[AccountNameTag] = name
+		entries[i].Tags = tags
+	}
+}
+
+// filterExpression converts a cost filter to a Cost Explorer expression,
+// nil when it matches everything
+func filterExpression(f aggregator.CostFilter) *types.Expression {
+	var exprs []types.Expression
+	dimension := func(key types.Dimension, values []string) {
+		if len(values) > 0 {
+			exprs = append(exprs, types.Expression{Dimensions: &types.DimensionValues{Key: key, Values: values}})
+		}
+	}
+	dimension(types.DimensionLinkedAccount, f.Accounts)
+	dimension(types.DimensionService, f.Services)
+	dimension(types.DimensionRegion, f.Regions)
+	for _, key := range f.TagKeys() {
+		exprs = append(exprs, types.Expression{Tags: &types.TagValues{Key: aws.String(key), Values: f.Tags[key]}})
+	}
+
+	switch len(exprs) {
+	case 0:
+		return nil
+	case 1:
+		return &exprs[0]
+	}
+	// Cost Explorer requires at least two operands for And
+	return &types.Expression{And: exprs}
+}
+
+// recordTypes are the Cost Explorer record types queried apart from usage,
+// by account, so that they do not net against each service's spend
+var recordTypes = map[string]normalizer.ChargeType{
+	"Credit": normalizer.ChargeCredit,
+	"Refund": normalizer.ChargeRefund,
+	"Tax":    normalizer.ChargeTax,
+}
+
+// recordTypeFilter restricts filter to the recordTypes, or excludes them
+func recordTypeFilter(filter *types.Expression, include bool) *types.Expression {
+	names := make([]string, 0, len(recordTypes))
+	for name := range recordTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	expr := types.Expression{Dimensions: &types.DimensionValues{Key: types.DimensionRecordType, Values: names}}
+	if !include {
+		match := expr
+		expr = types.Expression{Not: &match}
+	}
+	if filter == nil {
+		return &expr
+	}
+	return &types.Expression{And: []types.Expression{*filter, expr}}
+}
+
+func (p *CostProvider) queryCosts(ctx context.Context, client *costexplorer.Client, start, end time.Time, filter *types.Expression) ([]aggregator.CostEntry, error) {
 	entries := make([]aggregator.CostEntry, 0)
 
 	granularity := types.GranularityDaily
@@ -81,6 +401,69 @@ func (p *CostProvider) GetCosts(ctx context.Context, start, end time.Time) ([]ag
 		}
 	}
 
+	usageFilter := recordTypeFilter(filter, false)
+	err := p.costAndUsage(ctx, client, start, end, granularity, groupBy, usageFilter, func(date time.Time, group types.Group) {
+		cost, usage := groupMetrics(group)
+
+		// Parse group keys
+		var service, accountID string
+		for i, key := range group.Keys {
+			if i == 0 {
+				service = key
+			} else if i == 1 {
+				accountID = key
+			}
+		}
+
+		entries = append(entries, aggregator.CostEntry{
+			Provider:    "aws",
+			AccountID:   accountID,
+			Service:     service,
+			Date:        date,
+			Cost:        cost,
+			ChargeType:  string(normalizer.ChargeUsage),
+			Currency:    "USD",
+			UsageAmount: usage,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Credits, refunds and taxes, each reported as its own service
+	byType := []types.GroupDefinition{
+		{Type: types.GroupDefinitionTypeDimension, Key: aws.String("RECORD_TYPE")},
+		{Type: types.GroupDefinitionTypeDimension, Key: aws.String("LINKED_ACCOUNT")},
+	}
+	err = p.costAndUsage(ctx, client, start, end, granularity, byType, recordTypeFilter(filter, true), func(date time.Time, group types.Group) {
+		cost, _ := groupMetrics(group)
+		if len(group.Keys) < 2 || cost == 0 {
+			return
+		}
+		entries = append(entries, aggregator.CostEntry{
+			Provider:     "aws",
+			AccountID:    group.Keys[1],
+			Service:      group.Keys[0],
+			Date:         date,
+			Cost:         cost,
+			ChargeType:   string(recordTypes[group.Keys[0]]),
+			Currency:     "USD",
+			LineItemType: group.Keys[0],
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if p.config.ResourceLevel {
+		return p.withResources(ctx, client, entries, start, end, granularity, usageFilter)
+	}
+	return entries, nil
+}
+
+// costAndUsage pages through a GetCostAndUsage query, passing each group to
+// add with its period's start date
+func (p *CostProvider) costAndUsage(ctx context.Context, client *costexplorer.Client, start, end time.Time, granularity types.Granularity, groupBy []types.GroupDefinition, filter *types.Expression, add func(time.Time, types.Group)) error {
 	input := &costexplorer.GetCostAndUsageInput{
 		TimePeriod: &types.DateInterval{
 			Start: aws.String(start.Format("2006-01-02")),
@@ -89,70 +472,160 @@ func (p *CostProvider) GetCosts(ctx context.Context, start, end time.Time) ([]ag
 		Granularity: granularity,
 		Metrics:     []string{"UnblendedCost", "UsageQuantity"},
 		GroupBy:     groupBy,
+		Filter:      filter,
 	}
 
 	// Handle pagination manually
-	for {
-		output, err := p.client.GetCostAndUsage(ctx, input)
+	for page := 1; ; page++ {
+		callCtx, span := telemetry.StartCall(ctx, "aws.GetCostAndUsage", page)
+		var output *costexplorer.GetCostAndUsageOutput
+		err := p.calls.Do(callCtx, func(ctx context.Context) error {
+			var err error
+			output, err = client.GetCostAndUsage(ctx, input)
+			return err
+		})
 		if err != nil {
-			return nil, fmt.Errorf("failed to get cost data: %w", err)
+			telemetry.End(span, 0, err)
+			return fmt.Errorf("failed to get cost data: %w", classifyError(err))
+		}
+		if attempts, ok := retry.GetAttemptResults(output.ResultMetadata); ok && len(attempts.Results) > 1 {
+			span.SetAttributes(telemetry.AttrRetries.Int(len(attempts.Results) - 1))
+		}
+		records := 0
+		for _, result := range output.ResultsByTime {
+			records += len(result.Groups)
 		}
+		telemetry.End(span, records, nil)
 
 		for _, result := range output.ResultsByTime {
 			date, _ := time.Parse("2006-01-02", *result.TimePeriod.Start)
-
 			for _, group := range result.Groups {
-				cost := 0.0
-				usage := 0.0
+				add(date, group)
+			}
+		}
 
-				if unblended, ok := group.Metrics["UnblendedCost"]; ok {
-					if unblended.Amount != nil {
-						fmt.Sscanf(*unblended.Amount, "%f", &cost)
-					}
-				}
+		// Check for more pages
+		if output.NextPageToken == nil {
+			return nil
+		}
+		input.NextPageToken = output.NextPageToken
+	}
+}
 
-				if usageQty, ok := group.Metrics["UsageQuantity"]; ok {
-					if usageQty.Amount != nil {
-						fmt.Sscanf(*usageQty.Amount, "%f", &usage)
-					}
-				}
+// resourceDays is how far back Cost Explorer keeps resource-level data
+const resourceDays = 14
+
+// withResources replaces the entries of the resource services within the
+// resource-level window with per-resource entries. Resource IDs Cost
+// Explorer cannot attribute come back as NoResourceId and are left empty.
+func (p *CostProvider) withResources(ctx context.Context, client *costexplorer.Client, entries []aggregator.CostEntry, start, end time.Time, granularity types.Granularity, filter *types.Expression) ([]aggregator.CostEntry, error) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	// The window's first day must not be more than resourceDays ago
+	from := today.AddDate(0, 0, 1-resourceDays)
+	if start.After(from) {
+		from = start
+	}
+	if !from.Before(end) {
+		return entries, nil
+	}
+
+	services := make(map[string]bool, len(p.config.ResourceServices))
+	for _, s := range p.config.ResourceServices {
+		services[s] = true
+	}
+	kept := entries[:0]
+	for _, e := range entries {
+		if !services[e.Service] || e.Date.Before(from) {
+			kept = append(kept, e)
+		}
+	}
+	entries = kept
 
-				// Parse group keys
-				var service, accountID string
-				for i, key := range group.Keys {
-					if i == 0 {
-						service = key
-					} else if i == 1 {
-						accountID = key
+	for _, service := range p.config.ResourceServices {
+		// Resource-level queries must filter on a single service
+		expr := &types.Expression{Dimensions: &types.DimensionValues{Key: types.DimensionService, Values: []string{service}}}
+		if filter != nil {
+			expr = &types.Expression{And: []types.Expression{*expr, *filter}}
+		}
+		input := &costexplorer.GetCostAndUsageWithResourcesInput{
+			TimePeriod: &types.DateInterval{
+				Start: aws.String(from.Format("2006-01-02")),
+				End:   aws.String(end.Format("2006-01-02")),
+			},
+			Granularity: granularity,
+			Metrics:     []string{"UnblendedCost", "UsageQuantity"},
+			GroupBy: []types.GroupDefinition{
+				{Type: types.GroupDefinitionTypeDimension, Key: aws.String("LINKED_ACCOUNT")},
+				{Type: types.GroupDefinitionTypeDimension, Key: aws.String("RESOURCE_ID")},
+			},
+			Filter: expr,
+		}
+
+		for page := 1; ; page++ {
+			callCtx, span := telemetry.StartCall(ctx, "aws.GetCostAndUsageWithResources", page)
+			var output *costexplorer.GetCostAndUsageWithResourcesOutput
+			err := p.calls.Do(callCtx, func(ctx context.Context) error {
+				var err error
+				output, err = client.GetCostAndUsageWithResources(ctx, input)
+				return err
+			})
+			if err != nil {
+				telemetry.End(span, 0, err)
+				return nil, fmt.Errorf("failed to get resource cost data for %s: %w", service, classifyError(err))
+			}
+			records := 0
+			for _, result := range output.ResultsByTime {
+				records += len(result.Groups)
+			}
+			telemetry.End(span, records, nil)
+
+			for _, result := range output.ResultsByTime {
+				date, _ := time.Parse("2006-01-02", *result.TimePeriod.Start)
+				for _, group := range result.Groups {
+					if len(group.Keys) < 2 {
+						continue
+					}
+					resource := group.Keys[1]
+					if resource == "NoResourceId" {
+						resource = ""
 					}
+					cost, usage := groupMetrics(group)
+					entries = append(entries, aggregator.CostEntry{
+						Provider:    "aws",
+						AccountID:   group.Keys[0],
+						Service:     service,
+						ResourceID:  resource,
+						Date:        date,
+						Cost:        cost,
+						ChargeType:  string(normalizer.ChargeUsage),
+						Currency:    "USD",
+						UsageAmount: usage,
+					})
 				}
-
-				entries = append(entries, aggregator.CostEntry{
-					Provider:    "aws",
-					AccountID:   accountID,
-					Service:     service,
-					Date:        date,
-					Cost:        cost,
-					Currency:    "USD",
-					UsageAmount: usage,
-				})
 			}
-		}
 
-		// Check for more pages
-		if output.NextPageToken == nil {
-			break
+			if output.NextPageToken == nil {
+				break
+			}
+			input.NextPageToken = output.NextPageToken
 		}
-		input.NextPageToken = output.NextPageToken
 	}
 
 	return entries, nil
 }
 
-// GetBudgets retrieves budget status from AWS
-func (p *CostProvider) GetBudgets(ctx context.Context) ([]aggregator.BudgetStatus, error) {
-	// AWS Budgets API would be used here
-	// For now, return empty
-	return nil, nil
-}
+// groupMetrics returns a result group's unblended cost and usage quantity
+func groupMetrics(group types.Group) (cost, usage float64) {
+	if unblended, ok := group.Metrics["UnblendedCost"]; ok {
+		if unblended.Amount != nil {
+			fmt.Sscanf(*unblended.Amount, "%f", &cost)
+		}
+	}
 
+	if usageQty, ok := group.Metrics["UsageQuantity"]; ok {
+		if usageQty.Amount != nil {
+			fmt.Sscanf(*usageQty.Amount, "%f", &usage)
+		}
+	}
+	return cost, usage
+}
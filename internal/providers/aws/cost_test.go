@@ -0,0 +1,387 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+	internalConfig "github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/resilience"
+)
+
+// recordTypeExpr is the expression matching the recordTypes
+var recordTypeExpr = types.Expression{Dimensions: &types.DimensionValues{
+	Key:    types.DimensionRecordType,
+	Values: []string{"Credit", "Refund", "Tax"},
+}}
+
+func TestRecordTypeFilter(t *testing.T) {
+	services := filterExpression(aggregator.CostFilter{Services: []string{"Amazon EC2"}})
+	tests := []struct {
+		name    string
+		filter  *types.Expression
+		include bool
+		want    types.Expression
+	}{
+		{"include", nil, true, recordTypeExpr},
+		{"exclude", nil, false, types.Expression{Not: &recordTypeExpr}},
+		{"include with filter", services, true, types.Expression{And: []types.Expression{*services, recordTypeExpr}}},
+		{"exclude with filter", services, false, types.Expression{And: []types.Expression{*services, {Not: &recordTypeExpr}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := recordTypeFilter(tt.filter, tt.include)
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("recordTypeFilter = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecordTypeFilterNegationIsNotSelfReferential(t *testing.T) {
+	got := recordTypeFilter(nil, false)
+	if got.Not == nil || got.Not == got {
+		t.Fatalf("Not = %p, want a separate expression", got.Not)
+	}
+	if got.Not.Not != nil || got.Not.Dimensions == nil || got.Not.Dimensions.Key != types.DimensionRecordType {
+		t.Errorf("negated expression = %+v, want the record type match", *got.Not)
+	}
+}
+
+func TestFilterExpression(t *testing.T) {
+	if got := filterExpression(aggregator.CostFilter{}); got != nil {
+		t.Errorf("empty filter = %+v, want nil", *got)
+	}
+	one := filterExpression(aggregator.CostFilter{Accounts: []string{"111"}})
+	if one == nil || one.Dimensions == nil || one.Dimensions.Key != types.DimensionLinkedAccount || len(one.And) != 0 {
+		t.Errorf("one dimension = %+v, want a bare dimension expression", one)
+	}
+	two := filterExpression(aggregator.CostFilter{Accounts: []string{"111"}, Regions: []string{"us-east-1"}})
+	if two == nil || len(two.And) != 2 {
+		t.Errorf("two dimensions = %+v, want an And of two", two)
+	}
+}
+
+// ec2 is the default resource-level service
+const ec2 = "Amazon Elastic Compute Cloud - Compute"
+
+// ceGroup returns a Cost Explorer result group in its JSON form
+func ceGroup(cost, usage string, keys ...string) map[string]any {
+	return map[string]any{
+		"Keys": keys,
+		"Metrics": map[string]any{
+			"UnblendedCost": map[string]string{"Amount": cost, "Unit": "USD"},
+			"UsageQuantity": map[string]string{"Amount": usage, "Unit": "N/A"},
+		},
+	}
+}
+
+// ceResult returns a Cost Explorer response of one day's groups
+func ceResult(day time.Time, next string, groups ...map[string]any) map[string]any {
+	out := map[string]any{"ResultsByTime": []map[string]any{{
+		"TimePeriod": map[string]string{"Start": day.Format("2006-01-02"), "End": day.AddDate(0, 0, 1).Format("2006-01-02")},
+		"Groups":     groups,
+	}}}
+	if next != "" {
+		out["NextPageToken"] = next
+	}
+	return out
+}
+
+// newTestClient returns a Cost Explorer client calling handler, which gets
+// each call's target operation and decoded input
+func newTestClient(t *testing.T, handler func(op string, input map[string]any) any) *costexplorer.Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var input map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		op := strings.TrimPrefix(r.Header.Get("X-Amz-Target"), "AWSInsightsIndexService.")
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		json.NewEncoder(w).Encode(handler(op, input))
+	}))
+	t.Cleanup(srv.Close)
+
+	return costexplorer.New(costexplorer.Options{
+		Region:           "us-east-1",
+		BaseEndpoint:     aws.String(srv.URL),
+		Credentials:      aws.AnonymousCredentials{},
+		RetryMaxAttempts: 1,
+	})
+}
+
+// newTestProvider returns a provider whose Cost Explorer client calls
+// handler, as newTestClient does
+func newTestProvider(t *testing.T, cfg internalConfig.AWSConfig, handler func(op string, input map[string]any) any) (*CostProvider, *costexplorer.Client) {
+	t.Helper()
+	calls, err := resilience.New("aws-test", internalConfig.CallConfig{}, retryable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := newTestClient(t, handler)
+	return &CostProvider{client: client, config: cfg, calls: calls, members: make(map[string]*costexplorer.Client)}, client
+}
+
+func TestQueryCostsResourceLevel(t *testing.T) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	old, recent := today.AddDate(0, 0, -20), today.AddDate(0, 0, -2)
+
+	var resourceCalls []map[string]any
+	p, client := newTestProvider(t, internalConfig.AWSConfig{ResourceLevel: true, ResourceServices: []string{ec2}}, func(op string, input map[string]any) any {
+		switch op {
+		case "GetCostAndUsage":
+			if _, usage := input["Filter"].(map[string]any)["Not"]; !usage {
+				return ceResult(recent, "") // no credits, refunds or taxes
+			}
+			out := ceResult(old, "", ceGroup("100", "720", ec2, "111"))
+			out["ResultsByTime"] = append(out["ResultsByTime"].([]map[string]any),
+				ceResult(recent, "", ceGroup("90", "700", ec2, "111"), ceGroup("7", "3", "Amazon Simple Storage Service", "111"))["ResultsByTime"].([]map[string]any)...)
+			return out
+		case "GetCostAndUsageWithResources":
+			resourceCalls = append(resourceCalls, input)
+			if input["NextPageToken"] == nil {
+				return ceResult(recent, "page-2", ceGroup("60", "480", "111", "i-0abc"))
+			}
+			return ceResult(recent, "", ceGroup("30", "220", "111", "NoResourceId"))
+		}
+		t.Errorf("unexpected call to %s", op)
+		return map[string]any{}
+	})
+
+	entries, err := p.queryCosts(context.Background(), client, today.AddDate(0, 0, -30), today, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type entry struct {
+		service, resource string
+		date              time.Time
+		cost, usage       float64
+	}
+	var got []entry
+	for _, e := range entries {
+		got = append(got, entry{e.Service, e.ResourceID, e.Date, e.Cost, e.UsageAmount})
+	}
+	// The EC2 total of the last 14 days gives way to its resources
+	want := []entry{
+		{ec2, "", old, 100, 720},
+		{"Amazon Simple Storage Service", "", recent, 7, 3},
+		{ec2, "i-0abc", recent, 60, 480},
+		{ec2, "", recent, 30, 220},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("entries = %+v, want %+v", got, want)
+	}
+
+	if len(resourceCalls) != 2 {
+		t.Fatalf("got %d resource-level calls, want 2 pages", len(resourceCalls))
+	}
+	period := resourceCalls[0]["TimePeriod"].(map[string]any)
+	if from := today.AddDate(0, 0, 1-resourceDays).Format("2006-01-02"); period["Start"] != from {
+		t.Errorf("resource-level start = %v, want %s", period["Start"], from)
+	}
+	// The single-service filter is combined with the usage filter
+	and, _ := resourceCalls[0]["Filter"].(map[string]any)["And"].([]any)
+	if len(and) != 2 || !strings.Contains(fmt.Sprint(and[0]), ec2) {
+		t.Errorf("resource-level filter = %v, want the service and the usage filter", resourceCalls[0]["Filter"])
+	}
+}
+
+func TestWithResourcesOutsideWindow(t *testing.T) {
+	p, client := newTestProvider(t, internalConfig.AWSConfig{ResourceLevel: true, ResourceServices: []string{ec2}}, func(op string, input map[string]any) any {
+		t.Errorf("unexpected call to %s", op)
+		return map[string]any{}
+	})
+	start := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -60)
+	entries := []aggregator.CostEntry{{Service: ec2, Date: start, Cost: 5}}
+	got, err := p.withResources(context.Background(), client, entries, start, start.AddDate(0, 0, 30), types.GranularityDaily, nil)
+	if err != nil || len(got) != 1 || got[0].Cost != 5 {
+		t.Errorf("withResources() = %+v, %v; want the entries unchanged", got, err)
+	}
+}
+
+func TestNewCostProviderResourceLevelNeedsDaily(t *testing.T) {
+	_, err := NewCostProvider(context.Background(), internalConfig.AWSConfig{Enabled: true, ResourceLevel: true, Granularity: "MONTHLY"})
+	if err == nil || !strings.Contains(err.Error(), "DAILY") {
+		t.Errorf("err = %v, want resource_level to require DAILY granularity", err)
+	}
+}
+
+// accountCosts answers usage queries with one EC2 group per account, and
+// record type queries with nothing
+func accountCosts(day time.Time, costs map[string]string) func(op string, input map[string]any) any {
+	return func(op string, input map[string]any) any {
+		if filter, _ := input["Filter"].(map[string]any); !strings.Contains(fmt.Sprint(filter), "Not") {
+			return ceResult(day, "")
+		}
+		var groups []map[string]any
+		for account, cost := range costs {
+			groups = append(groups, ceGroup(cost, "1", ec2, account))
+		}
+		return ceResult(day, "", groups...)
+	}
+}
+
+// costsByAccount totals entries per account, with each account's name tag
+func costsByAccount(entries []aggregator.CostEntry) map[string]string {
+	got := make(map[string]string)
+	for _, e := range entries {
+		got[e.AccountID] = fmt.Sprintf("%v %s", e.Cost, e.Tags[AccountNameTag])
+	}
+	return got
+}
+
+func TestFetchFanOut(t *testing.T) {
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	p, _ := newTestProvider(t, internalConfig.AWSConfig{MemberRole: "finops-read", Concurrency: 2, AccountNames: true}, func(op string, input map[string]any) any {
+		t.Errorf("management account queried for %s", op)
+		return map[string]any{}
+	})
+	p.org = []orgAccount{{"111", "prod", "ACTIVE"}, {"222", "dev", "ACTIVE"}, {"333", "closed", "SUSPENDED"}, {"444", "sandbox", "ACTIVE"}}
+	p.listed = true
+	for account, cost := range map[string]string{"111": "10", "222": "20", "444": "40"} {
+		p.members[account] = newTestClient(t, accountCosts(day, map[string]string{account: cost}))
+	}
+
+	// Active accounts are queried through their member role
+	entries, err := p.GetCosts(context.Background(), day, day.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"111": "10 prod", "222": "20 dev", "444": "40 sandbox"}
+	if got := costsByAccount(entries); !reflect.DeepEqual(got, want) {
+		t.Errorf("costs = %v, want %v", got, want)
+	}
+
+	// Account filters skip the others
+	entries, err = p.GetFilteredCosts(context.Background(), day, day.AddDate(0, 0, 1), aggregator.CostFilter{Accounts: []string{"222"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := costsByAccount(entries); !reflect.DeepEqual(got, map[string]string{"222": "20 dev"}) {
+		t.Errorf("filtered costs = %v, want only 222", got)
+	}
+
+	// One failed account fails the fetch
+	p.members["444"] = newTestClient(t, func(op string, input map[string]any) any {
+		return map[string]any{"ResultsByTime": "unreadable"}
+	})
+	if _, err := p.GetCosts(context.Background(), day, day.AddDate(0, 0, 1)); err == nil || !strings.Contains(err.Error(), "account 444") {
+		t.Errorf("err = %v, want account 444's error", err)
+	}
+}
+
+func TestFetchFanOutNeedsAccounts(t *testing.T) {
+	p, _ := newTestProvider(t, internalConfig.AWSConfig{MemberRole: "finops-read", Concurrency: 1}, nil)
+	p.listed = true // without Organizations access
+	if _, err := p.GetCosts(context.Background(), time.Now(), time.Now()); err == nil {
+		t.Error("GetCosts() succeeded, want an error without accounts to query")
+	}
+}
+
+func TestFetchLinkedAccounts(t *testing.T) {
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	var filters []string
+	p, _ := newTestProvider(t, internalConfig.AWSConfig{AccountIDs: []string{"111", "222"}}, func(op string, input map[string]any) any {
+		filters = append(filters, fmt.Sprint(input["Filter"]))
+		return accountCosts(day, map[string]string{"222": "20"})(op, input)
+	})
+
+	entries, err := p.GetFilteredCosts(context.Background(), day, day.AddDate(0, 0, 1), aggregator.CostFilter{Accounts: []string{"222", "333"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].AccountID != "222" {
+		t.Errorf("entries = %+v, want account 222's", entries)
+	}
+	// Both queries are limited to the configured accounts in the filter
+	for _, f := range filters {
+		if !strings.Contains(f, "LINKED_ACCOUNT") || !strings.Contains(f, "[222]") {
+			t.Errorf("filter = %s, want LINKED_ACCOUNT 222", f)
+		}
+	}
+
+	// No configured account matches, so nothing is queried
+	filters = nil
+	entries, err = p.GetFilteredCosts(context.Background(), day, day.AddDate(0, 0, 1), aggregator.CostFilter{Accounts: []string{"333"}})
+	if err != nil || len(entries) != 0 || len(filters) != 0 {
+		t.Errorf("GetFilteredCosts() = %+v, %v after %d calls; want nothing", entries, err, len(filters))
+	}
+}
+
+// withSTS points the environment's credential chain at static keys and the
+// STS API at a server that lets roles named "denied" not be assumed
+func withSTS(t *testing.T) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.Form.Get("Action") {
+		case "AssumeRole":
+			if strings.HasSuffix(r.Form.Get("RoleArn"), "/denied") {
+				w.WriteHeader(http.StatusForbidden)
+				fmt.Fprint(w, `<ErrorResponse><Error><Type>Sender</Type><Code>AccessDenied</Code><Message>not authorized to assume the role</Message></Error></ErrorResponse>`)
+				return
+			}
+			fmt.Fprint(w, `<AssumeRoleResponse><AssumeRoleResult><Credentials><AccessKeyId>ASIAROLE</AccessKeyId><SecretAccessKey>secret</SecretAccessKey>`+
+				`<SessionToken>token</SessionToken><Expiration>2099-01-01T00:00:00Z</Expiration></Credentials></AssumeRoleResult></AssumeRoleResponse>`)
+		case "GetCallerIdentity":
+			arn := "arn:aws:iam::111111111111:user/finops"
+			if strings.Contains(r.Header.Get("Authorization"), "Credential=ASIAROLE/") {
+				arn = "arn:aws:sts::222222222222:assumed-role/finops/session"
+			}
+			fmt.Fprintf(w, `<GetCallerIdentityResponse><GetCallerIdentityResult><Arn>%s</Arn></GetCallerIdentityResult></GetCallerIdentityResponse>`, arn)
+		default:
+			t.Errorf("unexpected STS action %q", r.Form.Get("Action"))
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	dir := t.TempDir()
+	for key, value := range map[string]string{
+		"AWS_ACCESS_KEY_ID":           "AKID",
+		"AWS_SECRET_ACCESS_KEY":       "secret",
+		"AWS_SESSION_TOKEN":           "",
+		"AWS_PROFILE":                 "",
+		"AWS_CONFIG_FILE":             dir + "/config",
+		"AWS_SHARED_CREDENTIALS_FILE": dir + "/credentials",
+		"AWS_ENDPOINT_URL_STS":        srv.URL,
+		"AWS_MAX_ATTEMPTS":            "1",
+	} {
+		t.Setenv(key, value)
+	}
+}
+
+func TestCheckCredentials(t *testing.T) {
+	withSTS(t)
+	tests := []struct {
+		roleARN, want, wantErr string
+	}{
+		{"", "arn:aws:iam::111111111111:user/finops", ""},
+		{"arn:aws:iam::222222222222:role/finops", "arn:aws:sts::222222222222:assumed-role/finops/session", ""},
+		{"arn:aws:iam::222222222222:role/denied", "", "AccessDenied"},
+	}
+	for _, tt := range tests {
+		got, err := CheckCredentials(context.Background(), internalConfig.AWSConfig{Region: "us-east-1", RoleARN: tt.roleARN})
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("CheckCredentials(%q) = %q, %v; want %s", tt.roleARN, got, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("CheckCredentials(%q) = %q, %v; want %s", tt.roleARN, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,613 @@
+package aws
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+	"github.com/lvonguyen/finops-platform/internal/columnar"
+	internalConfig "github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+	"github.com/lvonguyen/finops-platform/internal/telemetry"
+)
+
+// CUR export versions
+const (
+	CURVersion2      = "2.0"
+	CURVersionLegacy = "legacy"
+)
+
+// CURProvider implements aggregator.CostProvider for Cost and Usage Report
+// exports in S3. Line items keep their resource and every tag, rolled up
+// from hourly to daily.
+type CURProvider struct {
+	client s3Getter
+	config internalConfig.AWSConfig
+
+	// Billing periods already read, by month. AWS re-delivers a month whenever
+	// it restates it, replacing the manifest, so a period is only read again
+	// when its manifest changed.
+	mu      sync.Mutex
+	periods map[string]curPeriod
+}
+
+// s3Getter is the part of the S3 API the CUR reader uses
+type s3Getter interface {
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+}
+
+type curPeriod struct {
+	version string // manifest executionId (CUR 2.0) or assemblyId (legacy)
+	entries []aggregator.CostEntry
+}
+
+// NewCURProvider creates a CUR reader for the export configured under aws.cur
+func NewCURProvider(ctx context.Context, cfg internalConfig.AWSConfig) (*CURProvider, error) {
+	if !cfg.Enabled || !cfg.CUR.Enabled {
+		return nil, fmt.Errorf("AWS CUR provider is disabled")
+	}
+	if cfg.CUR.Bucket == "" || cfg.CUR.Name == "" {
+		return nil, fmt.Errorf("AWS CUR bucket and name must be set")
+	}
+	if cfg.CUR.Version != CURVersion2 && cfg.CUR.Version != CURVersionLegacy {
+		return nil, fmt.Errorf("unknown AWS CUR version %q (want %s or %s)", cfg.CUR.Version, CURVersion2, CURVersionLegacy)
+	}
+
+	client, err := newS3Client(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CURProvider{
+		client:  client,
+		config:  cfg,
+		periods: make(map[string]curPeriod),
+	}, nil
+}
+
+// newS3Client loads credentials and builds a client for the export bucket
+func newS3Client(ctx context.Context, cfg internalConfig.AWSConfig) (*s3.Client, error) {
+	region := cfg.CUR.Region
+	if region == "" {
+		region = cfg.Region
+	}
+	awsCfg, err := loadConfig(ctx, cfg, region)
+	if err != nil {
+		return nil, err
+	}
+	return s3.NewFromConfig(awsCfg), nil
+}
+
+// RefreshCredentials reloads the credential chain and re-assumes the role
+func (p *CURProvider) RefreshCredentials(ctx context.Context) error {
+	client, err := newS3Client(ctx, p.config)
+	if err != nil {
+		return err
+	}
+	p.client = client
+	return nil
+}
+
+// Name returns the provider name; CUR data stands in for Cost Explorer
+func (p *CURProvider) Name() string {
+	return "aws"
+}
+
+// GetCosts reads the billing periods overlapping [start, end) from their
+// current manifests and returns the line items within the range
+func (p *CURProvider) GetCosts(ctx context.Context, start, end time.Time) ([]aggregator.CostEntry, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	entries := make([]aggregator.CostEntry, 0)
+	month := time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for ; month.Before(end); month = month.AddDate(0, 1, 0) {
+		period, err := p.period(ctx, month)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range period {
+			if !e.Date.Before(start) && e.Date.Before(end) {
+				entries = append(entries, e)
+			}
+		}
+	}
+	return entries, nil
+}
+
+// StreamCosts passes the line items of the billing periods overlapping
+// [start, end) to fn as each report part is read, without rolling them up
+// to daily totals or keeping them, so memory stays flat however large the
+// export. Entries are dated by day as in GetCosts, but a day's hourly line
+// items arrive separately.
+func (p *CURProvider) StreamCosts(ctx context.Context, start, end time.Time, fn func(aggregator.CostEntry) error) error {
+	month := time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for ; month.Before(end); month = month.AddDate(0, 1, 0) {
+		m, found, err := p.readManifest(ctx, p.manifestKey(month))
+		if err != nil {
+			return err
+		}
+		if !found {
+			continue // not delivered yet
+		}
+
+		name := month.Format("2006-01")
+		keys := m.dataKeys(p.config.CUR.Bucket)
+		for i, dataKey := range keys {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			partCtx, span := telemetry.StartCall(ctx, "aws.cur.ReadPart", i+1)
+			rows := 0
+			err := p.readDataFile(partCtx, dataKey, func(row, tags map[string]string) error {
+				entry, err := curEntry(row, tags)
+				if err != nil {
+					return err
+				}
+				if entry.Date.Before(start) || !entry.Date.Before(end) {
+					return nil
+				}
+				rows++
+				return fn(entry)
+			})
+			telemetry.End(span, rows, err)
+			if err != nil {
+				return fmt.Errorf("CUR %s part %d/%d: %w", name, i+1, len(keys), err)
+			}
+		}
+	}
+	return nil
+}
+
+// period returns a billing month's line items, re-reading every part of the
+// report when the month has been delivered or restated since the last read
+func (p *CURProvider) period(ctx context.Context, month time.Time) ([]aggregator.CostEntry, error) {
+	key := p.manifestKey(month)
+	m, found, err := p.readManifest(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, nil // not delivered yet
+	}
+
+	name := month.Format("2006-01")
+	version := m.version()
+	if cached, ok := p.periods[name]; ok {
+		if cached.version == version {
+			return cached.entries, nil
+		}
+		log.Printf("AWS CUR: billing period %s was restated, re-reading", name)
+	}
+
+	keys := m.dataKeys(p.config.CUR.Bucket)
+	rollup := newCURRollup()
+	for i, dataKey := range keys {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		partCtx, span := telemetry.StartCall(ctx, "aws.cur.ReadPart", i+1)
+		rows := 0
+		err := p.readDataFile(partCtx, dataKey, func(row, tags map[string]string) error {
+			rows++
+			return rollup.add(row, tags)
+		})
+		telemetry.End(span, rows, err)
+		if err != nil {
+			return nil, fmt.Errorf("CUR %s part %d/%d: %w", name, i+1, len(keys), err)
+		}
+	}
+
+	entries := rollup.entries()
+	p.periods[name] = curPeriod{version: version, entries: entries}
+	return entries, nil
+}
+
+// manifestKey returns the S3 key of a billing month's manifest
+func (p *CURProvider) manifestKey(month time.Time) string {
+	cur := p.config.CUR
+	if cur.Version == CURVersionLegacy {
+		period := month.Format("20060102") + "-" + month.AddDate(0, 1, 0).Format("20060102")
+		return path.Join(cur.Prefix, cur.Name, period, cur.Name+"-Manifest.json")
+	}
+	return path.Join(cur.Prefix, cur.Name, "metadata", "BILLING_PERIOD="+month.Format("2006-01"), cur.Name+"-Manifest.json")
+}
+
+// curManifest lists the data files of one delivery of a billing period
+type curManifest struct {
+	// CUR 2.0 (Data Exports)
+	ExecutionID string   `json:"executionId"`
+	DataFiles   []string `json:"dataFiles"` // s3:// URIs
+
+	// Legacy CUR
+	AssemblyID string   `json:"assemblyId"`
+	ReportKeys []string `json:"reportKeys"` // keys in the bucket
+}
+
+func (m curManifest) version() string {
+	if m.ExecutionID != "" {
+		return m.ExecutionID
+	}
+	return m.AssemblyID
+}
+
+// dataKeys returns the keys of the delivery's report parts
+func (m curManifest) dataKeys(bucket string) []string {
+	keys := append([]string(nil), m.ReportKeys...)
+	for _, uri := range m.DataFiles {
+		keys = append(keys, strings.TrimPrefix(uri, "s3://"+bucket+"/"))
+	}
+	return keys
+}
+
+// readManifest fetches a manifest, reporting whether it exists
+func (p *CURProvider) readManifest(ctx context.Context, key string) (curManifest, bool, error) {
+	out, err := p.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(p.config.CUR.Bucket),
+		Key:    aws.String(key),
+	})
+	var noKey *types.NoSuchKey
+	if errors.As(err, &noKey) {
+		return curManifest{}, false, nil
+	}
+	if err != nil {
+		return curManifest{}, false, fmt.Errorf("failed to get CUR manifest %s: %w", key, classifyError(err))
+	}
+	defer out.Body.Close()
+
+	var m curManifest
+	if err := json.NewDecoder(out.Body).Decode(&m); err != nil {
+		return curManifest{}, false, fmt.Errorf("failed to parse CUR manifest %s: %w", key, err)
+	}
+	return m, true, nil
+}
+
+// readDataFile streams one report part, gzipped CSV, CSV or Parquet,
+// passing each line item's columns and tags to fn
+func (p *CURProvider) readDataFile(ctx context.Context, key string, fn func(row, tags map[string]string) error) error {
+	gzipped := strings.HasSuffix(key, ".csv.gz")
+	parquet := strings.HasSuffix(key, ".parquet")
+	if !gzipped && !parquet && !strings.HasSuffix(key, ".csv") {
+		return fmt.Errorf("%s: unsupported CUR file type", key)
+	}
+
+	out, err := p.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(p.config.CUR.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get %s: %w", key, classifyError(err))
+	}
+	defer out.Body.Close()
+
+	switch {
+	case parquet:
+		return readCURParquet(ctx, out.Body, fn)
+	case !gzipped:
+		return readCURCSV(out.Body, fn)
+	}
+	gz, err := gzip.NewReader(out.Body)
+	if err != nil {
+		return fmt.Errorf("failed to decompress %s: %w", key, err)
+	}
+	defer gz.Close()
+	return readCURCSV(gz, fn)
+}
+
+// readCURCSV reads a CSV report part with a header row
+func readCURCSV(r io.Reader, fn func(row, tags map[string]string) error) error {
+	reader := csv.NewReader(r)
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("failed to read header: %w", err)
+	}
+	columns := curColumns(header)
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read line item: %w", err)
+		}
+		if err := curLineItem(columns, record, fn); err != nil {
+			return err
+		}
+	}
+}
+
+// readCURParquet reads a Parquet report part. Parquet needs random access,
+// so the part is spooled to a temporary file rather than held in memory.
+func readCURParquet(ctx context.Context, r io.Reader, fn func(row, tags map[string]string) error) error {
+	f, err := os.CreateTemp("", "cur-*.parquet")
+	if err != nil {
+		return fmt.Errorf("failed to spool Parquet part: %w", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if _, err := io.Copy(f, r); err != nil {
+		return fmt.Errorf("failed to spool Parquet part: %w", err)
+	}
+
+	var columns []string
+	return columnar.ReadRows(ctx, f, func(header, record []string) error {
+		if columns == nil {
+			columns = curColumns(header)
+		}
+		return curLineItem(columns, record, fn)
+	})
+}
+
+func curColumns(header []string) []string {
+	columns := make([]string, len(header))
+	for i, h := range header {
+		columns[i] = curColumn(h)
+	}
+	return columns
+}
+
+// curLineItem splits one line item into its columns and tags for fn
+func curLineItem(columns, record []string, fn func(row, tags map[string]string) error) error {
+	row := make(map[string]string, len(columns))
+	tags := make(map[string]string)
+	for i, value := range record {
+		if columns[i] == "resource_tags" {
+			// CUR 2.0 writes all tags as one JSON object, or a map in Parquet
+			if err := curTags(value, tags); err != nil {
+				return err
+			}
+			continue
+		}
+		if key, ok := curTagKey(columns[i]); ok {
+			if value != "" {
+				tags[key] = value
+			}
+			continue
+		}
+		row[columns[i]] = value
+	}
+	return fn(row, tags)
+}
+
+// curColumn normalizes a column name to the snake_case CUR 2.0 uses, so
+// legacy headers ("lineItem/UsageStartDate") read the same as
+// "line_item_usage_start_date". Tag columns keep their key as-is.
+func curColumn(name string) string {
+	category, field, ok := strings.Cut(name, "/")
+	if !ok {
+		return strings.ToLower(name)
+	}
+	if category == "resourceTags" {
+		return "resource_tags/" + field
+	}
+	return snakeCase(category) + "_" + snakeCase(field)
+}
+
+// snakeCase lowercases a camelCase name, starting a word at every capital
+// ("ReservationARN" becomes "reservation_a_r_n", as in CUR 2.0)
+func snakeCase(s string) string {
+	var b strings.Builder
+	for i, c := range s {
+		if c >= 'A' && c <= 'Z' {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			c += 'a' - 'A'
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// curTags adds the tags of a CUR 2.0 resource_tags value
+func curTags(value string, tags map[string]string) error {
+	if value == "" {
+		return nil
+	}
+	var raw map[string]string
+	if err := json.Unmarshal([]byte(value), &raw); err != nil {
+		return fmt.Errorf("invalid resource_tags %q: %w", value, err)
+	}
+	for k, v := range raw {
+		if v != "" {
+			tags[userTagKey(k)] = v
+		}
+	}
+	return nil
+}
+
+// curTagKey returns the tag key of a legacy tag column, such as
+// "resource_tags/user:team" or "resource_tags_user_team" in Athena-ready
+// exports
+func curTagKey(column string) (string, bool) {
+	key, ok := strings.CutPrefix(column, "resource_tags/")
+	if !ok {
+		key, ok = strings.CutPrefix(column, "resource_tags_")
+	}
+	if !ok {
+		return "", false
+	}
+	return userTagKey(key), true
+}
+
+// userTagKey drops the prefix of user-defined tags to match Cost Explorer
+// tag keys; AWS-generated tags keep theirs
+func userTagKey(key string) string {
+	if k, ok := strings.CutPrefix(key, "user:"); ok {
+		return k
+	}
+	if k, ok := strings.CutPrefix(key, "user_"); ok {
+		return k
+	}
+	return key
+}
+
+// curRollup totals line items by day and every other dimension, turning
+// hourly line items into daily ones without losing resources or tags
+type curRollup struct {
+	totals map[string]*aggregator.CostEntry
+	order  []string
+}
+
+func newCURRollup() *curRollup {
+	return &curRollup{totals: make(map[string]*aggregator.CostEntry)}
+}
+
+// add parses a line item and adds it to its daily total
+func (r *curRollup) add(row, tags map[string]string) error {
+	entry, err := curEntry(row, tags)
+	if err != nil {
+		return err
+	}
+
+	tagKeys := make([]string, 0, len(entry.Tags))
+	for k := range entry.Tags {
+		tagKeys = append(tagKeys, k)
+	}
+	sort.Strings(tagKeys)
+	parts := []string{entry.Date.Format("2006-01-02"), entry.AccountID, entry.Service, entry.Region, entry.ResourceID,
+		entry.LineItemType, entry.UsageType, entry.UsageUnit, entry.Currency, entry.CommitmentDiscountID}
+	for _, k := range tagKeys {
+		parts = append(parts, k+"="+entry.Tags[k])
+	}
+	key := strings.Join(parts, "\x00")
+
+	total, ok := r.totals[key]
+	if !ok {
+		r.totals[key] = &entry
+		r.order = append(r.order, key)
+		return nil
+	}
+	total.Cost += entry.Cost
+	total.EffectiveCost += entry.EffectiveCost
+	total.UsageAmount += entry.UsageAmount
+	return nil
+}
+
+func (r *curRollup) entries() []aggregator.CostEntry {
+	entries := make([]aggregator.CostEntry, 0, len(r.order))
+	for _, key := range r.order {
+		entries = append(entries, *r.totals[key])
+	}
+	return entries
+}
+
+// Timestamp layouts seen in CUR exports
+var curTimeLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"}
+
+// curEntry converts a line item to a cost entry. The effective cost follows
+// AWS amortization: covered usage costs its commitment rate, fees for used
+// commitment and Savings Plan negations net to zero.
+func curEntry(row, tags map[string]string) (aggregator.CostEntry, error) {
+	start := row["line_item_usage_start_date"]
+	var date time.Time
+	var err error
+	for _, layout := range curTimeLayouts {
+		if date, err = time.Parse(layout, start); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return aggregator.CostEntry{}, fmt.Errorf("invalid usage start date %q", start)
+	}
+
+	number := func(col string) (float64, error) {
+		v := row[col]
+		if v == "" {
+			return 0, nil
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s %q", col, v)
+		}
+		return f, nil
+	}
+	var numErr error
+	num := func(col string) float64 {
+		f, err := number(col)
+		if err != nil && numErr == nil {
+			numErr = err
+		}
+		return f
+	}
+
+	region := row["product_region_code"]
+	if region == "" {
+		region = row["product_region"]
+	}
+	currency := row["line_item_currency_code"]
+	if currency == "" {
+		currency = "USD"
+	}
+	if len(tags) == 0 {
+		tags = nil
+	}
+
+	entry := aggregator.CostEntry{
+		Provider:    "aws",
+		AccountID:   row["line_item_usage_account_id"],
+		Service:     row["line_item_product_code"],
+		Region:      region,
+		ResourceID:  row["line_item_resource_id"],
+		ChargeType:  string(normalizer.ParseChargeType(row["line_item_line_item_type"])),
+		Date:        time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC),
+		Cost:        num("line_item_unblended_cost"),
+		Currency:    currency,
+		Tags:        tags,
+		UsageType:   row["line_item_usage_type"],
+		UsageAmount: num("line_item_usage_amount"),
+		UsageUnit:   row["pricing_unit"],
+
+		LineItemType: row["line_item_line_item_type"],
+	}
+
+	savingsPlan := func() {
+		entry.CommitmentDiscountID = row["savings_plan_savings_plan_a_r_n"]
+		entry.CommitmentDiscountCategory = "Spend"
+		entry.CommitmentDiscountType = "Savings Plan"
+	}
+	reservation := func() {
+		entry.CommitmentDiscountID = row["reservation_reservation_a_r_n"]
+		entry.CommitmentDiscountCategory = "Usage"
+		entry.CommitmentDiscountType = "Reserved Instance"
+	}
+	switch entry.LineItemType {
+	case "SavingsPlanCoveredUsage":
+		savingsPlan()
+		entry.EffectiveCost = num("savings_plan_savings_plan_effective_cost")
+	case "SavingsPlanRecurringFee":
+		savingsPlan()
+		entry.EffectiveCost = num("savings_plan_total_commitment_to_date") - num("savings_plan_used_commitment")
+	case "SavingsPlanNegation", "SavingsPlanUpfrontFee":
+		savingsPlan()
+	case "DiscountedUsage":
+		reservation()
+		entry.EffectiveCost = num("reservation_effective_cost")
+	case "RIFee":
+		reservation()
+		entry.EffectiveCost = num("reservation_unused_amortized_upfront_fee_for_billing_period") + num("reservation_unused_recurring_fee")
+	}
+	if numErr != nil {
+		return aggregator.CostEntry{}, numErr
+	}
+	return entry, nil
+}
@@ -0,0 +1,334 @@
+package aws
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+	"github.com/lvonguyen/finops-platform/internal/columnar"
+	internalConfig "github.com/lvonguyen/finops-platform/internal/config"
+)
+
+// fakeS3 serves objects from memory and records the keys fetched
+type fakeS3 struct {
+	objects map[string][]byte
+	gets    []string
+}
+
+func (f *fakeS3) GetObject(ctx context.Context, in *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	key := aws.ToString(in.Key)
+	f.gets = append(f.gets, key)
+	b, ok := f.objects[key]
+	if !ok {
+		return nil, &types.NoSuchKey{}
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(b))}, nil
+}
+
+func newTestCUR(version string, objects map[string][]byte) (*CURProvider, *fakeS3) {
+	fake := &fakeS3{objects: objects}
+	return &CURProvider{
+		client: fake,
+		config: internalConfig.AWSConfig{CUR: internalConfig.CURConfig{
+			Bucket: "bills", Prefix: "cur", Name: "daily", Version: version,
+		}},
+		periods: make(map[string]curPeriod),
+	}, fake
+}
+
+var curHeader = []string{"line_item_usage_start_date", "line_item_usage_account_id", "line_item_product_code",
+	"product_region_code", "line_item_unblended_cost", "line_item_line_item_type", "resource_tags"}
+
+// curCSV writes line items as a gzipped CUR 2.0 CSV part
+func curCSV(t *testing.T, rows ...[]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte(strings.Join(curHeader, ",") + "\n"))
+	for _, row := range rows {
+		gz.Write([]byte(strings.Join(row, ",") + "\n"))
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// curParquet writes line items as a CUR 2.0 Parquet part, with
+// resource_tags as a map column the way Data Exports writes it
+func curParquet(t *testing.T, rows ...[]string) []byte {
+	t.Helper()
+	fields := []arrow.Field{
+		{Name: "line_item_usage_start_date", Type: arrow.FixedWidthTypes.Timestamp_ms},
+		{Name: "line_item_usage_account_id", Type: arrow.BinaryTypes.String},
+		{Name: "line_item_product_code", Type: arrow.BinaryTypes.String},
+		{Name: "product_region_code", Type: arrow.BinaryTypes.String},
+		{Name: "line_item_unblended_cost", Type: arrow.PrimitiveTypes.Float64},
+		{Name: "line_item_line_item_type", Type: arrow.BinaryTypes.String},
+		{Name: "resource_tags", Type: arrow.MapOf(arrow.BinaryTypes.String, arrow.BinaryTypes.String)},
+	}
+	b := array.NewRecordBuilder(memory.DefaultAllocator, arrow.NewSchema(fields, nil))
+	defer b.Release()
+	for _, row := range rows {
+		start, err := time.Parse(time.RFC3339, row[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.Field(0).(*array.TimestampBuilder).Append(arrow.Timestamp(start.UnixMilli()))
+		for i := 1; i <= 5; i++ {
+			if i == 4 {
+				cost, err := strconv.ParseFloat(row[i], 64)
+				if err != nil {
+					t.Fatal(err)
+				}
+				b.Field(i).(*array.Float64Builder).Append(cost)
+				continue
+			}
+			b.Field(i).(*array.StringBuilder).Append(row[i])
+		}
+		var tags map[string]string
+		if err := json.Unmarshal([]byte(row[6]), &tags); err != nil {
+			t.Fatal(err)
+		}
+		mb := b.Field(6).(*array.MapBuilder)
+		mb.Append(true)
+		for k, v := range tags {
+			mb.KeyBuilder().(*array.StringBuilder).Append(k)
+			mb.ItemBuilder().(*array.StringBuilder).Append(v)
+		}
+	}
+	rec := b.NewRecord()
+	defer rec.Release()
+
+	var buf bytes.Buffer
+	if err := columnar.WriteParquet(&buf, rec); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func manifest(t *testing.T, m curManifest) []byte {
+	t.Helper()
+	b, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// summary lists entries as day|account|service|region|cost|tags, sorted
+func summary(entries []aggregator.CostEntry) string {
+	lines := make([]string, len(entries))
+	for i, e := range entries {
+		tags, _ := json.Marshal(e.Tags)
+		lines[i] = strings.Join([]string{e.Date.Format("2006-01-02"), e.AccountID, e.Service, e.Region,
+			strconv.FormatFloat(e.Cost, 'f', -1, 64), string(tags)}, "|")
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+const (
+	manifest2024Jan = "cur/daily/metadata/BILLING_PERIOD=2024-01/daily-Manifest.json"
+	partCSV         = "cur/daily/data/BILLING_PERIOD=2024-01/daily-00001.csv.gz"
+	partParquet     = "cur/daily/data/BILLING_PERIOD=2024-01/daily-00002.parquet"
+)
+
+var (
+	jan     = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	feb     = time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	ctxTest = context.Background()
+)
+
+func TestCURManifestKey(t *testing.T) {
+	tests := []struct {
+		version string
+		want    string
+	}{
+		{CURVersion2, manifest2024Jan},
+		{CURVersionLegacy, "cur/daily/20240101-20240201/daily-Manifest.json"},
+	}
+	for _, tt := range tests {
+		p, _ := newTestCUR(tt.version, nil)
+		if got := p.manifestKey(jan); got != tt.want {
+			t.Errorf("%s: manifestKey = %s, want %s", tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestCURManifestDataKeys(t *testing.T) {
+	m := curManifest{ExecutionID: "exec-1", DataFiles: []string{"s3://bills/" + partCSV, "s3://bills/" + partParquet}}
+	if got := strings.Join(m.dataKeys("bills"), " "); got != partCSV+" "+partParquet {
+		t.Errorf("CUR 2.0 dataKeys = %s", got)
+	}
+	if m.version() != "exec-1" {
+		t.Errorf("CUR 2.0 version = %s, want exec-1", m.version())
+	}
+
+	legacy := curManifest{AssemblyID: "asm-1", ReportKeys: []string{"cur/daily/20240101-20240201/asm-1/daily-1.csv.gz"}}
+	if got := strings.Join(legacy.dataKeys("bills"), " "); got != "cur/daily/20240101-20240201/asm-1/daily-1.csv.gz" {
+		t.Errorf("legacy dataKeys = %s", got)
+	}
+	if legacy.version() != "asm-1" {
+		t.Errorf("legacy version = %s, want asm-1", legacy.version())
+	}
+}
+
+func TestCURMissingManifest(t *testing.T) {
+	p, _ := newTestCUR(CURVersion2, map[string][]byte{})
+	entries, err := p.GetCosts(ctxTest, jan, feb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("got %d entries for an undelivered month, want none", len(entries))
+	}
+}
+
+func TestCURMultiPart(t *testing.T) {
+	p, _ := newTestCUR(CURVersion2, map[string][]byte{
+		manifest2024Jan: manifest(t, curManifest{ExecutionID: "exec-1", DataFiles: []string{"s3://bills/" + partCSV, "s3://bills/" + partParquet}}),
+		partCSV: curCSV(t,
+			[]string{"2024-01-05T00:00:00Z", "111", "AmazonEC2", "us-east-1", "1.5", "Usage", `"{""user:team"":""web""}"`},
+			[]string{"2024-01-05T01:00:00Z", "111", "AmazonEC2", "us-east-1", "2.5", "Usage", `"{""user:team"":""web""}"`},
+		),
+		partParquet: curParquet(t,
+			[]string{"2024-01-05T02:00:00Z", "111", "AmazonEC2", "us-east-1", "1", "Usage", `{"user:team":"web"}`},
+			[]string{"2024-01-06T00:00:00Z", "222", "AmazonS3", "us-west-2", "0.25", "Usage", `{}`},
+		),
+	})
+
+	entries, err := p.GetCosts(ctxTest, jan, feb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The hourly EC2 line items of both parts roll up into one day
+	want := strings.Join([]string{
+		`2024-01-05|111|AmazonEC2|us-east-1|5|{"team":"web"}`,
+		`2024-01-06|222|AmazonS3|us-west-2|0.25|null`,
+	}, "\n")
+	if got := summary(entries); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	var streamed []aggregator.CostEntry
+	err = p.StreamCosts(ctxTest, jan, feb, func(e aggregator.CostEntry) error {
+		streamed = append(streamed, e)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(streamed) != 4 {
+		t.Errorf("streamed %d line items, want all 4 from both parts", len(streamed))
+	}
+}
+
+func TestCURStreamCosts(t *testing.T) {
+	p, _ := newTestCUR(CURVersion2, map[string][]byte{
+		manifest2024Jan: manifest(t, curManifest{ExecutionID: "exec-1", DataFiles: []string{"s3://bills/" + partCSV}}),
+		partCSV: curCSV(t,
+			[]string{"2024-01-05T00:00:00Z", "111", "AmazonEC2", "us-east-1", "1.5", "Usage", `"{}"`},
+			[]string{"2024-01-20T00:00:00Z", "111", "AmazonEC2", "us-east-1", "2.5", "Usage", `"{}"`},
+			[]string{"2024-01-21T00:00:00Z", "222", "AmazonS3", "us-east-1", "0.5", "Usage", `"{}"`},
+		),
+	})
+
+	// Line items outside the window are skipped; February is not delivered
+	var streamed []aggregator.CostEntry
+	err := p.StreamCosts(ctxTest, jan.AddDate(0, 0, 10), feb.AddDate(0, 1, 0), func(e aggregator.CostEntry) error {
+		streamed = append(streamed, e)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "2024-01-20|111|AmazonEC2|us-east-1|2.5|null\n2024-01-21|222|AmazonS3|us-east-1|0.5|null"
+	if got := summary(streamed); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	// An error from fn stops the stream, naming the part
+	err = p.StreamCosts(ctxTest, jan, feb, func(e aggregator.CostEntry) error {
+		return errors.New("disk full")
+	})
+	if err == nil || err.Error() != "CUR 2024-01 part 1/1: disk full" {
+		t.Errorf("StreamCosts() = %v, want the part and fn's error", err)
+	}
+}
+
+func TestCURRestatement(t *testing.T) {
+	p, fake := newTestCUR(CURVersion2, map[string][]byte{
+		manifest2024Jan: manifest(t, curManifest{ExecutionID: "exec-1", DataFiles: []string{"s3://bills/" + partCSV}}),
+		partCSV: curCSV(t,
+			[]string{"2024-01-05T00:00:00Z", "111", "AmazonEC2", "us-east-1", "1.5", "Usage", ""},
+		),
+	})
+
+	read := func() string {
+		t.Helper()
+		entries, err := p.GetCosts(ctxTest, jan, feb)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return summary(entries)
+	}
+	parts := func() int {
+		n := 0
+		for _, key := range fake.gets {
+			if key != manifest2024Jan {
+				n++
+			}
+		}
+		return n
+	}
+
+	first := read()
+	if first != "2024-01-05|111|AmazonEC2|us-east-1|1.5|null" {
+		t.Fatalf("first read = %s", first)
+	}
+
+	// An unchanged manifest serves the month from the cache
+	if again := read(); again != first || parts() != 1 {
+		t.Fatalf("second read = %s after %d part reads, want the cached month and 1 read", again, parts())
+	}
+
+	// AWS restates the month: a new execution with a new part
+	fake.objects[manifest2024Jan] = manifest(t, curManifest{ExecutionID: "exec-2", DataFiles: []string{"s3://bills/" + partParquet}})
+	fake.objects[partParquet] = curParquet(t,
+		[]string{"2024-01-05T00:00:00Z", "111", "AmazonEC2", "us-east-1", "1.25", "Usage", `{}`},
+		[]string{"2024-01-05T00:00:00Z", "111", "AmazonEC2", "us-east-1", "-0.25", "Credit", `{}`},
+	)
+	restated := read()
+	want := strings.Join([]string{
+		"2024-01-05|111|AmazonEC2|us-east-1|-0.25|null",
+		"2024-01-05|111|AmazonEC2|us-east-1|1.25|null",
+	}, "\n")
+	if restated != want || parts() != 2 {
+		t.Fatalf("restated read = %s after %d part reads, want\n%s\nafter 2", restated, parts(), want)
+	}
+}
+
+func TestCURUnsupportedFile(t *testing.T) {
+	p, _ := newTestCUR(CURVersion2, nil)
+	err := p.readDataFile(ctxTest, "cur/daily/data/part.json", func(row, tags map[string]string) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "unsupported CUR file type") {
+		t.Fatalf("got %v, want an unsupported file type error", err)
+	}
+}
@@ -0,0 +1,104 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/smithy-go"
+)
+
+// jsonAPI is an AWS JSON API called by signing requests directly, for
+// services needing only a few calls (Organizations, Budgets, Compute
+// Optimizer)
+type jsonAPI struct {
+	endpoint string
+	service  string // signing name
+	region   string // signing region
+	target   string // X-Amz-Target prefix
+	version  string // JSON protocol version, 1.1 when empty
+}
+
+// jsonError is a failed JSON API call. It satisfies smithy.APIError, so
+// classifyError and retryable treat it like an SDK error.
+type jsonError struct {
+	status  int
+	code    string
+	message string
+}
+
+func (e *jsonError) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.status, e.code, e.message)
+}
+
+func (e *jsonError) ErrorCode() string    { return e.code }
+func (e *jsonError) ErrorMessage() string { return e.message }
+func (e *jsonError) HTTPStatusCode() int  { return e.status }
+
+func (e *jsonError) ErrorFault() smithy.ErrorFault {
+	if e.status >= 500 {
+		return smithy.FaultServer
+	}
+	return smithy.FaultClient
+}
+
+// call sends one signed request and decodes the response into out
+func (api jsonAPI) call(ctx context.Context, awsCfg aws.Config, action string, body []byte, out any) error {
+	creds, err := awsCfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve credentials: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, api.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	version := api.version
+	if version == "" {
+		version = "1.1"
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-"+version)
+	req.Header.Set("X-Amz-Target", api.target+"."+action)
+	sum := sha256.Sum256(body)
+	if err := v4.NewSigner().SignHTTP(ctx, creds, req, hex.EncodeToString(sum[:]), api.service, api.region, time.Now()); err != nil {
+		return fmt.Errorf("failed to sign request: %w", err)
+	}
+
+	client := awsCfg.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		json.Unmarshal(data, &apiErr)
+		// __type may be prefixed with the service namespace
+		code := apiErr.Type[strings.LastIndex(apiErr.Type, "#")+1:]
+		return &jsonError{status: resp.StatusCode, code: code, message: apiErr.Message}
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
@@ -0,0 +1,58 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"github.com/lvonguyen/finops-platform/internal/telemetry"
+)
+
+// organizationsAPI is a global service answering in us-east-1
+var organizationsAPI = jsonAPI{
+	endpoint: "https://organizations.us-east-1.amazonaws.com/",
+	service:  "organizations",
+	region:   "us-east-1",
+	target:   "AWSOrganizationsV20161128",
+}
+
+// orgAccount is a member account of the organization
+type orgAccount struct {
+	ID     string `json:"Id"`
+	Name   string `json:"Name"`
+	Status string `json:"Status"` // ACTIVE, SUSPENDED or PENDING_CLOSURE
+}
+
+// listAccounts returns every account of the organization, called with the
+// management (or a delegated administrator) account's credentials
+func listAccounts(ctx context.Context, awsCfg aws.Config) ([]orgAccount, error) {
+	var accounts []orgAccount
+	token := ""
+	for page := 1; ; page++ {
+		body, err := json.Marshal(struct {
+			NextToken string `json:",omitempty"`
+		}{token})
+		if err != nil {
+			return nil, err
+		}
+
+		callCtx, span := telemetry.StartCall(ctx, "aws.organizations.ListAccounts", page)
+		var result struct {
+			Accounts  []orgAccount
+			NextToken string
+		}
+		err = organizationsAPI.call(callCtx, awsCfg, "ListAccounts", body, &result)
+		telemetry.End(span, len(result.Accounts), err)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list organization accounts: %w", err)
+		}
+
+		accounts = append(accounts, result.Accounts...)
+		if result.NextToken == "" {
+			return accounts, nil
+		}
+		token = result.NextToken
+	}
+}
@@ -0,0 +1,80 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+)
+
+// testCredentials are static keys for signing test requests
+var testCredentials = aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+	return aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}, nil
+})
+
+// withOrganizations points the Organizations API at handler for the test
+func withOrganizations(t *testing.T, handler http.HandlerFunc) {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	saved := organizationsAPI
+	organizationsAPI.endpoint = srv.URL
+	t.Cleanup(func() { organizationsAPI = saved })
+}
+
+func TestListAccounts(t *testing.T) {
+	var tokens []string
+	withOrganizations(t, func(w http.ResponseWriter, r *http.Request) {
+		if target := r.Header.Get("X-Amz-Target"); target != "AWSOrganizationsV20161128.ListAccounts" {
+			t.Errorf("X-Amz-Target = %s", target)
+		}
+		if r.Header.Get("Authorization") == "" {
+			t.Error("request is not signed")
+		}
+		var input struct{ NextToken string }
+		json.NewDecoder(r.Body).Decode(&input)
+		tokens = append(tokens, input.NextToken)
+		if input.NextToken == "" {
+			w.Write([]byte(`{"Accounts":[{"Id":"111","Name":"prod","Status":"ACTIVE"}],"NextToken":"page-2"}`))
+			return
+		}
+		w.Write([]byte(`{"Accounts":[{"Id":"222","Name":"dev","Status":"SUSPENDED"}]}`))
+	})
+
+	got, err := listAccounts(context.Background(), aws.Config{Credentials: testCredentials})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []orgAccount{{"111", "prod", "ACTIVE"}, {"222", "dev", "SUSPENDED"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("listAccounts() = %+v, want %+v", got, want)
+	}
+	if !reflect.DeepEqual(tokens, []string{"", "page-2"}) {
+		t.Errorf("tokens = %q, want the first page, then page-2", tokens)
+	}
+}
+
+func TestListAccountsDenied(t *testing.T) {
+	withOrganizations(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"__type":"com.amazonaws.organizations#AccessDeniedException","message":"not the management account"}`))
+	})
+
+	_, err := listAccounts(context.Background(), aws.Config{Credentials: testCredentials})
+	if !errors.Is(classifyError(err), aggregator.ErrAuthExpired) {
+		t.Errorf("err = %v, want an access denied error", err)
+	}
+
+	// Without Organizations access the provider names no accounts
+	p := &CostProvider{awsCfg: aws.Config{Credentials: testCredentials}}
+	if org := p.organization(context.Background()); org != nil || !p.listed {
+		t.Errorf("organization() = %+v, want nil, listed once", org)
+	}
+}
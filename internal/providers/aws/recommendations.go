@@ -0,0 +1,411 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+	internalConfig "github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/recommendations"
+	"github.com/lvonguyen/finops-platform/internal/resilience"
+	"github.com/lvonguyen/finops-platform/internal/telemetry"
+)
+
+// Recommender collects rightsizing recommendations from AWS Compute
+// Optimizer and Cost Explorer
+type Recommender struct {
+	awsCfg   aws.Config
+	config   internalConfig.AWSConfig
+	calls    *resilience.Caller
+	options  recommendations.Options
+	regions  []string
+	families bool // Cost Explorer may cross instance families
+}
+
+// NewRecommender creates a recommender with the AWS provider's credentials
+func NewRecommender(ctx context.Context, cfg internalConfig.AWSConfig, recCfg internalConfig.RecommendationsConfig) (*Recommender, error) {
+	if !cfg.Enabled {
+		return nil, fmt.Errorf("AWS provider is disabled")
+	}
+	opts, err := recommendations.OptionsFrom(recCfg)
+	if err != nil {
+		return nil, err
+	}
+	awsCfg, err := loadConfig(ctx, cfg, cfg.Region)
+	if err != nil {
+		return nil, err
+	}
+	calls, err := resilience.New("aws", cfg.Calls, retryable)
+	if err != nil {
+		return nil, err
+	}
+	regions := recCfg.Regions
+	if len(regions) == 0 {
+		regions = []string{cfg.Region}
+	}
+	return &Recommender{awsCfg: awsCfg, config: cfg, calls: calls, options: opts, regions: regions, families: recCfg.CrossFamily}, nil
+}
+
+// GetRecommendations returns the selected sources' recommendations; Compute
+// Optimizer is queried in every configured region
+func (r *Recommender) GetRecommendations(ctx context.Context) ([]recommendations.Recommendation, error) {
+	var recs []recommendations.Recommendation
+	if r.options.Uses(recommendations.SourceComputeOptimizer) {
+		for _, region := range r.regions {
+			found, err := r.computeOptimizer(ctx, region)
+			if err != nil {
+				return recs, err
+			}
+			recs = append(recs, found...)
+		}
+	}
+	if r.options.Uses(recommendations.SourceCostExplorer) {
+		found, err := r.costExplorer(ctx)
+		if err != nil {
+			return recs, err
+		}
+		recs = append(recs, found...)
+	}
+	return recs, nil
+}
+
+// computeOptimizerEndpoint formats Compute Optimizer's endpoint in a region
+var computeOptimizerEndpoint = "https://compute-optimizer.%s.amazonaws.com/"
+
+// computeOptimizerAPI is Compute Optimizer's regional endpoint
+func computeOptimizerAPI(region string) jsonAPI {
+	return jsonAPI{
+		endpoint: fmt.Sprintf(computeOptimizerEndpoint, region),
+		service:  "compute-optimizer",
+		region:   region,
+		target:   "ComputeOptimizerService",
+		version:  "1.0",
+	}
+}
+
+// savingsOpportunity is Compute Optimizer's estimate for an option
+type savingsOpportunity struct {
+	SavingsOpportunityPercentage float64 `json:"savingsOpportunityPercentage"`
+	EstimatedMonthlySavings      struct {
+		Currency string  `json:"currency"`
+		Value    float64 `json:"value"`
+	} `json:"estimatedMonthlySavings"`
+}
+
+type instanceRecommendation struct {
+	InstanceArn           string `json:"instanceArn"`
+	AccountID             string `json:"accountId"`
+	CurrentInstanceType   string `json:"currentInstanceType"`
+	Finding               string `json:"finding"`
+	RecommendationOptions []struct {
+		InstanceType       string              `json:"instanceType"`
+		Rank               int                 `json:"rank"`
+		SavingsOpportunity *savingsOpportunity `json:"savingsOpportunity"`
+	} `json:"recommendationOptions"`
+}
+
+type volumeConfiguration struct {
+	VolumeType string `json:"volumeType"`
+	VolumeSize int    `json:"volumeSize"`
+}
+
+func (c volumeConfiguration) String() string {
+	return fmt.Sprintf("%s %d GiB", c.VolumeType, c.VolumeSize)
+}
+
+type volumeRecommendation struct {
+	VolumeArn                   string              `json:"volumeArn"`
+	AccountID                   string              `json:"accountId"`
+	CurrentConfiguration        volumeConfiguration `json:"currentConfiguration"`
+	Finding                     string              `json:"finding"`
+	VolumeRecommendationOptions []struct {
+		Configuration      volumeConfiguration `json:"configuration"`
+		Rank               int                 `json:"rank"`
+		SavingsOpportunity *savingsOpportunity `json:"savingsOpportunity"`
+	} `json:"volumeRecommendationOptions"`
+}
+
+type functionRecommendation struct {
+	FunctionArn                     string `json:"functionArn"`
+	AccountID                       string `json:"accountId"`
+	CurrentMemorySize               int    `json:"currentMemorySize"`
+	Finding                         string `json:"finding"`
+	MemorySizeRecommendationOptions []struct {
+		MemorySize         int                 `json:"memorySize"`
+		Rank               int                 `json:"rank"`
+		SavingsOpportunity *savingsOpportunity `json:"savingsOpportunity"`
+	} `json:"memorySizeRecommendationOptions"`
+}
+
+// computeOptimizer lists a region's EC2 instance, EBS volume and Lambda
+// function recommendations, taking each resource's top-ranked option
+func (r *Recommender) computeOptimizer(ctx context.Context, region string) ([]recommendations.Recommendation, error) {
+	api := computeOptimizerAPI(region)
+	var recs []recommendations.Recommendation
+
+	instances, err := listComputeOptimizer[instanceRecommendation](ctx, r, api, "GetEC2InstanceRecommendations", "instanceRecommendations")
+	if err != nil {
+		return nil, err
+	}
+	for _, in := range instances {
+		best := -1
+		for i, o := range in.RecommendationOptions {
+			if best < 0 || o.Rank < in.RecommendationOptions[best].Rank {
+				best = i
+			}
+		}
+		if best < 0 {
+			continue
+		}
+		o := in.RecommendationOptions[best]
+		rec := fromSavings(o.SavingsOpportunity)
+		rec.Account, rec.Region, rec.ResourceID = in.AccountID, arnRegion(in.InstanceArn), arnResource(in.InstanceArn)
+		rec.ResourceType, rec.Finding = "EC2 instance", in.Finding
+		rec.Current, rec.Recommended = in.CurrentInstanceType, o.InstanceType
+		recs = append(recs, rec)
+	}
+
+	volumes, err := listComputeOptimizer[volumeRecommendation](ctx, r, api, "GetEBSVolumeRecommendations", "volumeRecommendations")
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range volumes {
+		best := -1
+		for i, o := range v.VolumeRecommendationOptions {
+			if best < 0 || o.Rank < v.VolumeRecommendationOptions[best].Rank {
+				best = i
+			}
+		}
+		if best < 0 {
+			continue
+		}
+		o := v.VolumeRecommendationOptions[best]
+		rec := fromSavings(o.SavingsOpportunity)
+		rec.Account, rec.Region, rec.ResourceID = v.AccountID, arnRegion(v.VolumeArn), arnResource(v.VolumeArn)
+		rec.ResourceType, rec.Finding = "EBS volume", v.Finding
+		rec.Current, rec.Recommended = v.CurrentConfiguration.String(), o.Configuration.String()
+		recs = append(recs, rec)
+	}
+
+	functions, err := listComputeOptimizer[functionRecommendation](ctx, r, api, "GetLambdaFunctionRecommendations", "lambdaFunctionRecommendations")
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range functions {
+		best := -1
+		for i, o := range f.MemorySizeRecommendationOptions {
+			if best < 0 || o.Rank < f.MemorySizeRecommendationOptions[best].Rank {
+				best = i
+			}
+		}
+		if best < 0 {
+			continue
+		}
+		o := f.MemorySizeRecommendationOptions[best]
+		rec := fromSavings(o.SavingsOpportunity)
+		rec.Account, rec.Region, rec.ResourceID = f.AccountID, arnRegion(f.FunctionArn), arnResource(f.FunctionArn)
+		rec.ResourceType, rec.Finding = "Lambda function", f.Finding
+		rec.Current, rec.Recommended = fmt.Sprintf("%d MB", f.CurrentMemorySize), fmt.Sprintf("%d MB", o.MemorySize)
+		recs = append(recs, rec)
+	}
+	return recs, nil
+}
+
+// listComputeOptimizer pages through one Compute Optimizer list action,
+// limited to the configured accounts
+func listComputeOptimizer[T any](ctx context.Context, r *Recommender, api jsonAPI, action, field string) ([]T, error) {
+	var items []T
+	token := ""
+	for page := 1; ; page++ {
+		body, err := json.Marshal(struct {
+			AccountIDs []string `json:"accountIds,omitempty"`
+			NextToken  string   `json:"nextToken,omitempty"`
+		}{r.config.AccountIDs, token})
+		if err != nil {
+			return nil, err
+		}
+
+		callCtx, span := telemetry.StartCall(ctx, "aws.ComputeOptimizer."+action, page)
+		var result map[string]json.RawMessage
+		err = r.calls.Do(callCtx, func(ctx context.Context) error {
+			return api.call(ctx, r.awsCfg, action, body, &result)
+		})
+		var found []T
+		if err == nil && result[field] != nil {
+			err = json.Unmarshal(result[field], &found)
+		}
+		telemetry.End(span, len(found), err)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list Compute Optimizer recommendations in %s: %w", api.region, classifyError(err))
+		}
+		items = append(items, found...)
+
+		token = ""
+		if result["nextToken"] != nil {
+			json.Unmarshal(result["nextToken"], &token)
+		}
+		if token == "" {
+			return items, nil
+		}
+	}
+}
+
+// fromSavings starts a Compute Optimizer recommendation from its option's
+// savings; the current cost is implied by the savings percentage
+func fromSavings(s *savingsOpportunity) recommendations.Recommendation {
+	rec := recommendations.Recommendation{
+		Provider: "aws",
+		Source:   recommendations.SourceComputeOptimizer,
+		Action:   recommendations.ActionModify,
+	}
+	if s == nil {
+		return rec
+	}
+	rec.Savings = s.EstimatedMonthlySavings.Value
+	rec.Currency = s.EstimatedMonthlySavings.Currency
+	rec.SavingsPercent = s.SavingsOpportunityPercentage
+	if s.SavingsOpportunityPercentage > 0 {
+		rec.CurrentCost = rec.Savings * 100 / s.SavingsOpportunityPercentage
+		rec.ProjectedCost = rec.CurrentCost - rec.Savings
+	}
+	return rec
+}
+
+// costExplorer lists Cost Explorer's EC2 rightsizing recommendations
+func (r *Recommender) costExplorer(ctx context.Context) ([]recommendations.Recommendation, error) {
+	client := costexplorer.NewFromConfig(r.awsCfg)
+	target := types.RecommendationTargetSameInstanceFamily
+	if r.families {
+		target = types.RecommendationTargetCrossInstanceFamily
+	}
+	input := &costexplorer.GetRightsizingRecommendationInput{
+		Service: aws.String("AmazonEC2"),
+		Configuration: &types.RightsizingRecommendationConfiguration{
+			RecommendationTarget: target,
+			BenefitsConsidered:   true,
+		},
+		Filter: filterExpression(aggregator.CostFilter{Accounts: r.config.AccountIDs}),
+	}
+
+	var recs []recommendations.Recommendation
+	for page := 1; ; page++ {
+		callCtx, span := telemetry.StartCall(ctx, "aws.CostExplorer.GetRightsizingRecommendation", page)
+		var out *costexplorer.GetRightsizingRecommendationOutput
+		err := r.calls.Do(callCtx, func(ctx context.Context) error {
+			var err error
+			out, err = client.GetRightsizingRecommendation(ctx, input)
+			return err
+		})
+		if err != nil {
+			telemetry.End(span, 0, err)
+			return nil, fmt.Errorf("failed to get rightsizing recommendations: %w", classifyError(err))
+		}
+		telemetry.End(span, len(out.RightsizingRecommendations), nil)
+
+		for _, rr := range out.RightsizingRecommendations {
+			if rec, ok := fromRightsizing(rr); ok {
+				recs = append(recs, rec)
+			}
+		}
+		if aws.ToString(out.NextPageToken) == "" {
+			return recs, nil
+		}
+		input.NextPageToken = out.NextPageToken
+	}
+}
+
+// fromRightsizing normalizes a Cost Explorer recommendation, using the
+// default target of a modification
+func fromRightsizing(rr types.RightsizingRecommendation) (recommendations.Recommendation, bool) {
+	if rr.CurrentInstance == nil {
+		return recommendations.Recommendation{}, false
+	}
+	cur := rr.CurrentInstance
+	rec := recommendations.Recommendation{
+		Provider:     "aws",
+		Source:       recommendations.SourceCostExplorer,
+		Account:      aws.ToString(rr.AccountId),
+		ResourceID:   aws.ToString(cur.ResourceId),
+		ResourceType: "EC2 instance",
+		CurrentCost:  decimal(cur.MonthlyCost),
+		Currency:     aws.ToString(cur.CurrencyCode),
+	}
+	if d := cur.ResourceDetails; d != nil && d.EC2ResourceDetails != nil {
+		rec.Current = aws.ToString(d.EC2ResourceDetails.InstanceType)
+		rec.Region = aws.ToString(d.EC2ResourceDetails.Region)
+	}
+
+	switch rr.RightsizingType {
+	case types.RightsizingTypeTerminate:
+		if rr.TerminateRecommendationDetail == nil {
+			return recommendations.Recommendation{}, false
+		}
+		rec.Action, rec.Finding = recommendations.ActionTerminate, "Idle"
+		rec.Savings = decimal(rr.TerminateRecommendationDetail.EstimatedMonthlySavings)
+		rec.ProjectedCost = rec.CurrentCost - rec.Savings
+	case types.RightsizingTypeModify:
+		if rr.ModifyRecommendationDetail == nil || len(rr.ModifyRecommendationDetail.TargetInstances) == 0 {
+			return recommendations.Recommendation{}, false
+		}
+		target := rr.ModifyRecommendationDetail.TargetInstances[0]
+		for _, t := range rr.ModifyRecommendationDetail.TargetInstances {
+			if t.DefaultTargetInstance {
+				target = t
+				break
+			}
+		}
+		rec.Action, rec.Finding = recommendations.ActionModify, "Underutilized"
+		rec.Savings = decimal(target.EstimatedMonthlySavings)
+		rec.ProjectedCost = decimal(target.EstimatedMonthlyCost)
+		if d := target.ResourceDetails; d != nil && d.EC2ResourceDetails != nil {
+			rec.Recommended = aws.ToString(d.EC2ResourceDetails.InstanceType)
+		}
+	default:
+		return recommendations.Recommendation{}, false
+	}
+	if rec.CurrentCost > 0 {
+		rec.SavingsPercent = rec.Savings / rec.CurrentCost * 100
+	}
+	return rec, true
+}
+
+// decimal parses a Cost Explorer amount, zero when absent
+func decimal(s *string) float64 {
+	v, _ := strconv.ParseFloat(aws.ToString(s), 64)
+	return v
+}
+
+// arnRegion returns the region field of an ARN
+func arnRegion(arn string) string {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) < 6 {
+		return ""
+	}
+	return parts[3]
+}
+
+// arnResource returns the resource ID of an ARN, such as i-0abc for
+// arn:aws:ec2:us-east-1:123456789012:instance/i-0abc or the function name
+// of a Lambda function ARN
+func arnResource(arn string) string {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) < 6 {
+		return arn
+	}
+	resource := parts[5]
+	if i := strings.LastIndex(resource, "/"); i >= 0 {
+		return resource[i+1:]
+	}
+	if name, ok := strings.CutPrefix(resource, "function:"); ok {
+		name, _, _ = strings.Cut(name, ":")
+		return name
+	}
+	return resource
+}
@@ -0,0 +1,207 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	internalConfig "github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/recommendations"
+	"github.com/lvonguyen/finops-platform/internal/resilience"
+)
+
+// newTestRecommender serves Compute Optimizer and Cost Explorer, passing
+// each call's region (empty for Cost Explorer), action and body to handler
+func newTestRecommender(t *testing.T, recCfg internalConfig.RecommendationsConfig, handler func(region, action string, body map[string]any) (int, any)) *Recommender {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		_, action, _ := strings.Cut(r.Header.Get("X-Amz-Target"), ".")
+		status, out := handler(strings.Trim(r.URL.Path, "/"), action, body)
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(out)
+	}))
+	t.Cleanup(srv.Close)
+	saved := computeOptimizerEndpoint
+	computeOptimizerEndpoint = srv.URL + "/%s"
+	t.Cleanup(func() { computeOptimizerEndpoint = saved })
+
+	opts, err := recommendations.OptionsFrom(recCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls, err := resilience.New("aws-test", internalConfig.CallConfig{}, retryable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Recommender{
+		awsCfg:  aws.Config{Region: "us-east-1", Credentials: testCredentials, BaseEndpoint: aws.String(srv.URL), RetryMaxAttempts: 1},
+		config:  internalConfig.AWSConfig{AccountIDs: []string{"111"}},
+		calls:   calls,
+		options: opts,
+		regions: recCfg.Regions,
+	}
+}
+
+// savings returns a Compute Optimizer savings opportunity
+func savings(value, percent float64) map[string]any {
+	return map[string]any{
+		"savingsOpportunityPercentage": percent,
+		"estimatedMonthlySavings":      map[string]any{"currency": "USD", "value": value},
+	}
+}
+
+// recommendationSummary describes a recommendation on one line
+func recommendationSummary(r recommendations.Recommendation) string {
+	return fmt.Sprintf("%s %s/%s/%s %s %s %s %s->%s %.2f-%.2f=%.2f (%.0f%%)", r.Source, r.Account, r.Region, r.ResourceID,
+		r.ResourceType, r.Finding, r.Action, r.Current, r.Recommended, r.CurrentCost, r.ProjectedCost, r.Savings, r.SavingsPercent)
+}
+
+// computeOptimizer answers Compute Optimizer in us-east-1 with an instance,
+// a volume and a function over two pages, and nothing in other regions
+func computeOptimizer(t *testing.T, region, action string, body map[string]any) any {
+	if accounts, _ := body["accountIds"].([]any); len(accounts) != 1 || accounts[0] != "111" {
+		t.Errorf("%s accountIds = %v, want the configured accounts", action, body["accountIds"])
+	}
+	if region != "us-east-1" {
+		return map[string]any{}
+	}
+	switch action {
+	case "GetEC2InstanceRecommendations":
+		if body["nextToken"] == nil {
+			return map[string]any{"nextToken": "p2", "instanceRecommendations": []map[string]any{{
+				"instanceArn": "arn:aws:ec2:us-east-1:111:instance/i-1", "accountId": "111",
+				"currentInstanceType": "m5.xlarge", "finding": "Overprovisioned",
+				"recommendationOptions": []map[string]any{
+					{"instanceType": "m5.large", "rank": 2, "savingsOpportunity": savings(10, 8)},
+					{"instanceType": "t3.large", "rank": 1, "savingsOpportunity": savings(30, 25)},
+				},
+			}}}
+		}
+		return map[string]any{"instanceRecommendations": []map[string]any{{
+			"instanceArn": "arn:aws:ec2:us-east-1:111:instance/i-2", "accountId": "111", "finding": "Optimized",
+		}}}
+	case "GetEBSVolumeRecommendations":
+		return map[string]any{"volumeRecommendations": []map[string]any{{
+			"volumeArn": "arn:aws:ec2:us-east-1:111:volume/vol-1", "accountId": "111", "finding": "NotOptimized",
+			"currentConfiguration": map[string]any{"volumeType": "gp2", "volumeSize": 100},
+			"volumeRecommendationOptions": []map[string]any{
+				{"configuration": map[string]any{"volumeType": "gp3", "volumeSize": 100}, "rank": 1, "savingsOpportunity": savings(2, 20)},
+			},
+		}}}
+	case "GetLambdaFunctionRecommendations":
+		return map[string]any{"lambdaFunctionRecommendations": []map[string]any{{
+			"functionArn": "arn:aws:lambda:us-east-1:111:function:resize:$LATEST", "accountId": "111",
+			"currentMemorySize": 1024, "finding": "Overprovisioned",
+			"memorySizeRecommendationOptions": []map[string]any{{"memorySize": 512, "rank": 1, "savingsOpportunity": savings(1, 50)}},
+		}}}
+	}
+	t.Errorf("unexpected Compute Optimizer action %s", action)
+	return map[string]any{}
+}
+
+// rightsizing answers Cost Explorer with a termination, a modification and
+// a modification without targets
+func rightsizing(t *testing.T, body map[string]any) any {
+	if target := body["Configuration"].(map[string]any)["RecommendationTarget"]; target != "SAME_INSTANCE_FAMILY" {
+		t.Errorf("RecommendationTarget = %v, want SAME_INSTANCE_FAMILY", target)
+	}
+	current := func(id string) map[string]any {
+		return map[string]any{
+			"ResourceId": id, "MonthlyCost": "50", "CurrencyCode": "USD",
+			"ResourceDetails": map[string]any{"EC2ResourceDetails": map[string]any{"InstanceType": "m5.large", "Region": "us-east-1"}},
+		}
+	}
+	return map[string]any{"RightsizingRecommendations": []map[string]any{
+		{"AccountId": "111", "RightsizingType": "TERMINATE", "CurrentInstance": current("i-3"),
+			"TerminateRecommendationDetail": map[string]any{"EstimatedMonthlySavings": "50"}},
+		{"AccountId": "111", "RightsizingType": "MODIFY", "CurrentInstance": current("i-4"),
+			"ModifyRecommendationDetail": map[string]any{"TargetInstances": []map[string]any{
+				{"EstimatedMonthlyCost": "40", "EstimatedMonthlySavings": "10",
+					"ResourceDetails": map[string]any{"EC2ResourceDetails": map[string]any{"InstanceType": "m5.medium"}}},
+				{"EstimatedMonthlyCost": "20", "EstimatedMonthlySavings": "30", "DefaultTargetInstance": true,
+					"ResourceDetails": map[string]any{"EC2ResourceDetails": map[string]any{"InstanceType": "t3.medium"}}},
+			}}},
+		{"AccountId": "111", "RightsizingType": "MODIFY", "CurrentInstance": current("i-5"),
+			"ModifyRecommendationDetail": map[string]any{}},
+	}}
+}
+
+func TestGetRecommendations(t *testing.T) {
+	r := newTestRecommender(t, internalConfig.RecommendationsConfig{Regions: []string{"us-east-1", "us-west-2"}}, func(region, action string, body map[string]any) (int, any) {
+		if action == "GetRightsizingRecommendation" {
+			return http.StatusOK, rightsizing(t, body)
+		}
+		return http.StatusOK, computeOptimizer(t, region, action, body)
+	})
+	recs, err := r.GetRecommendations(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, rec := range recs {
+		got = append(got, recommendationSummary(rec))
+	}
+	want := []string{
+		"compute_optimizer 111/us-east-1/i-1 EC2 instance Overprovisioned modify m5.xlarge->t3.large 120.00-90.00=30.00 (25%)",
+		"compute_optimizer 111/us-east-1/vol-1 EBS volume NotOptimized modify gp2 100 GiB->gp3 100 GiB 10.00-8.00=2.00 (20%)",
+		"compute_optimizer 111/us-east-1/resize Lambda function Overprovisioned modify 1024 MB->512 MB 2.00-1.00=1.00 (50%)",
+		"cost_explorer 111/us-east-1/i-3 EC2 instance Idle terminate m5.large-> 50.00-0.00=50.00 (100%)",
+		"cost_explorer 111/us-east-1/i-4 EC2 instance Underutilized modify m5.large->t3.medium 50.00-20.00=30.00 (60%)",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestGetRecommendationsSources(t *testing.T) {
+	var actions []string
+	r := newTestRecommender(t, internalConfig.RecommendationsConfig{Sources: []string{recommendations.SourceCostExplorer}, Regions: []string{"us-east-1"}}, func(region, action string, body map[string]any) (int, any) {
+		actions = append(actions, action)
+		return http.StatusOK, map[string]any{}
+	})
+	if _, err := r.GetRecommendations(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(actions, []string{"GetRightsizingRecommendation"}) {
+		t.Errorf("called %v, want only Cost Explorer", actions)
+	}
+}
+
+func TestGetRecommendationsDenied(t *testing.T) {
+	r := newTestRecommender(t, internalConfig.RecommendationsConfig{Regions: []string{"us-east-1"}}, func(region, action string, body map[string]any) (int, any) {
+		return http.StatusBadRequest, map[string]string{"__type": "AccessDeniedException", "message": "not opted in"}
+	})
+	if _, err := r.GetRecommendations(context.Background()); err == nil || !strings.Contains(err.Error(), "us-east-1") {
+		t.Errorf("GetRecommendations() = %v, want Compute Optimizer's error in us-east-1", err)
+	}
+}
+
+func TestARNParts(t *testing.T) {
+	tests := []struct {
+		arn, region, resource string
+	}{
+		{"arn:aws:ec2:us-east-1:111:instance/i-0abc", "us-east-1", "i-0abc"},
+		{"arn:aws:lambda:eu-west-1:111:function:resize:$LATEST", "eu-west-1", "resize"},
+		{"arn:aws:lambda:eu-west-1:111:function:resize", "eu-west-1", "resize"},
+		{"i-0abc", "", "i-0abc"},
+	}
+	for _, tt := range tests {
+		if got := arnRegion(tt.arn); got != tt.region {
+			t.Errorf("arnRegion(%q) = %q, want %q", tt.arn, got, tt.region)
+		}
+		if got := arnResource(tt.arn); got != tt.resource {
+			t.Errorf("arnResource(%q) = %q, want %q", tt.arn, got, tt.resource)
+		}
+	}
+}
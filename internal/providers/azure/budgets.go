@@ -0,0 +1,180 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/telemetry"
+)
+
+const consumptionAPIVersion = "2023-05-01"
+
+// consumptionBudget is the part of a Consumption budget read and written here
+type consumptionBudget struct {
+	Name       string `json:"name,omitempty"`
+	Properties struct {
+		Category      string                        `json:"category"`
+		Amount        float64                       `json:"amount"`
+		TimeGrain     string                        `json:"timeGrain"`
+		TimePeriod    *budgetPeriod                 `json:"timePeriod,omitempty"`
+		CurrentSpend  *budgetSpend                  `json:"currentSpend,omitempty"`
+		ForecastSpend *budgetSpend                  `json:"forecastSpend,omitempty"`
+		Notifications map[string]budgetNotification `json:"notifications,omitempty"`
+	} `json:"properties"`
+}
+
+type budgetPeriod struct {
+	StartDate string `json:"startDate"`
+}
+
+type budgetSpend struct {
+	Amount float64 `json:"amount"`
+	Unit   string  `json:"unit"`
+}
+
+func (s *budgetSpend) value() float64 {
+	if s == nil {
+		return 0
+	}
+	return s.Amount
+}
+
+type budgetNotification struct {
+	Enabled       bool     `json:"enabled"`
+	Operator      string   `json:"operator"`
+	Threshold     float64  `json:"threshold"`
+	ThresholdType string   `json:"thresholdType"`
+	ContactEmails []string `json:"contactEmails"`
+}
+
+// GetBudgets retrieves the monthly cost budgets of every configured scope.
+// Budgets are reported with the subscription they belong to, or the
+// management group or billing account scope path.
+func (p *CostProvider) GetBudgets(ctx context.Context) ([]aggregator.BudgetStatus, error) {
+	statuses := make([]aggregator.BudgetStatus, 0)
+	for _, t := range p.tenants {
+		scopes, err := t.queryScopes(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, scope := range scopes {
+			budgets, err := p.listBudgets(ctx, t, scope.path)
+			if err != nil {
+				return nil, err
+			}
+			for _, b := range budgets {
+				if b.Properties.Category != "Cost" || b.Properties.TimeGrain != "Monthly" {
+					continue
+				}
+				status := aggregator.BudgetStatus{
+					BudgetName:    b.Name,
+					Provider:      "azure",
+					Scope:         scope.subscription,
+					Limit:         b.Properties.Amount,
+					CurrentSpend:  b.Properties.CurrentSpend.value(),
+					ForecastSpend: b.Properties.ForecastSpend.value(),
+				}
+				if status.Scope == "" {
+					status.Scope = scope.path
+				}
+				statuses = append(statuses, status)
+			}
+		}
+	}
+	return statuses, nil
+}
+
+// listBudgets lists the budgets defined at a scope
+func (p *CostProvider) listBudgets(ctx context.Context, t *tenant, scope string) ([]consumptionBudget, error) {
+	next := fmt.Sprintf("%s%s/providers/Microsoft.Consumption/budgets?api-version=%s",
+		strings.TrimSuffix(t.arm.Endpoint(), "/"), scope, consumptionAPIVersion)
+
+	var budgets []consumptionBudget
+	for page := 1; next != ""; page++ {
+		callCtx, span := telemetry.StartCall(ctx, "azure.Budgets.List", page)
+		var result struct {
+			Value    []consumptionBudget `json:"value"`
+			NextLink string              `json:"nextLink"`
+		}
+		err := p.calls.Do(callCtx, func(ctx context.Context) error {
+			return t.get(ctx, next, &result)
+		})
+		telemetry.End(span, len(result.Value), err)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list budgets of %s: %w", scope, classifyError(err))
+		}
+		budgets = append(budgets, result.Value...)
+		next = result.NextLink
+	}
+	return budgets, nil
+}
+
+// CreateBudget creates a declared budget at its scope: a subscription ID, or
+// a scope path such as /providers/Microsoft.Management/managementGroups/ID.
+// It starts this month and emails notify_emails at each alert_at percentage
+// of actual spend.
+func (p *CostProvider) CreateBudget(ctx context.Context, b config.Budget) error {
+	if b.Scope == "" {
+		return fmt.Errorf("azure budget %s needs a scope: a subscription ID or scope path", b.Name)
+	}
+	scope, t := b.Scope, p.tenantOf(b.Scope)
+	if !strings.HasPrefix(scope, "/") {
+		scope = "/subscriptions/" + scope
+	}
+
+	var created consumptionBudget
+	created.Properties.Category = "Cost"
+	created.Properties.Amount = b.MonthlyLimit
+	created.Properties.TimeGrain = "Monthly"
+	now := time.Now().UTC()
+	created.Properties.TimePeriod = &budgetPeriod{
+		StartDate: time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).Format(time.RFC3339),
+	}
+	// Every notification needs a contact
+	if len(b.NotifyEmails) > 0 {
+		created.Properties.Notifications = make(map[string]budgetNotification)
+		for _, pct := range b.AlertAt {
+			created.Properties.Notifications[fmt.Sprintf("Actual_GreaterThan_%d_Percent", pct)] = budgetNotification{
+				Enabled:       true,
+				Operator:      "GreaterThan",
+				Threshold:     float64(pct),
+				ThresholdType: "Actual",
+				ContactEmails: b.NotifyEmails,
+			}
+		}
+	}
+
+	endpoint := fmt.Sprintf("%s%s/providers/Microsoft.Consumption/budgets/%s?api-version=%s",
+		strings.TrimSuffix(t.arm.Endpoint(), "/"), scope, url.PathEscape(b.Name), consumptionAPIVersion)
+	callCtx, span := telemetry.StartCall(ctx, "azure.Budgets.CreateOrUpdate", 1)
+	err := p.calls.Do(callCtx, func(ctx context.Context) error {
+		return t.send(ctx, http.MethodPut, endpoint, created, nil)
+	})
+	telemetry.End(span, 1, err)
+	if err != nil {
+		return fmt.Errorf("failed to create budget %s: %w", b.Name, classifyError(err))
+	}
+	return nil
+}
+
+// tenantOf returns the tenant configured with a subscription, management
+// group or billing account, or the primary tenant
+func (p *CostProvider) tenantOf(scope string) *tenant {
+	id := scope[strings.LastIndex(scope, "/")+1:]
+	for _, t := range p.tenants {
+		for _, ids := range [][]string{t.scopes.SubscriptionIDs, t.scopes.ManagementGroups, t.scopes.BillingAccounts} {
+			for _, configured := range ids {
+				if strings.EqualFold(configured, id) {
+					return t
+				}
+			}
+		}
+	}
+	return p.tenants[0]
+}
@@ -0,0 +1,129 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/resilience"
+)
+
+// budgetsProvider returns a provider of the given tenants
+func budgetsProvider(t *testing.T, tenants ...*tenant) *CostProvider {
+	t.Helper()
+	calls, err := resilience.New("azure-test", config.CallConfig{}, retryable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &CostProvider{tenants: tenants, calls: calls}
+}
+
+// budgetJSON is a Consumption budget in its JSON form
+func budgetJSON(name, category, grain string, amount, spent float64) map[string]any {
+	return map[string]any{"name": name, "properties": map[string]any{
+		"category": category, "amount": amount, "timeGrain": grain,
+		"currentSpend": map[string]any{"amount": spent, "unit": "USD"},
+	}}
+}
+
+func TestGetBudgets(t *testing.T) {
+	tn := newTestTenant(t, config.AzureTenantConfig{
+		SubscriptionIDs:  []string{"sub-1"},
+		ManagementGroups: []string{"mg-1"},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/providers/Microsoft.Consumption/budgets") || r.URL.Query().Get("api-version") != consumptionAPIVersion {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		var out map[string]any
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/subscriptions/sub-1/") && r.URL.Query().Get("page") == "":
+			out = map[string]any{
+				"value":    []any{budgetJSON("prod", "Cost", "Monthly", 1000, 400), budgetJSON("yearly", "Cost", "Annually", 9000, 0)},
+				"nextLink": "https://" + r.Host + r.URL.Path + "?api-version=" + consumptionAPIVersion + "&page=2",
+			}
+		case strings.HasPrefix(r.URL.Path, "/subscriptions/sub-1/"):
+			out = map[string]any{"value": []any{budgetJSON("usage", "Usage", "Monthly", 5, 0)}}
+		default:
+			out = map[string]any{"value": []any{budgetJSON("group", "Cost", "Monthly", 5000, 1200)}}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(out)
+	})
+
+	got, err := budgetsProvider(t, tn).GetBudgets(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []aggregator.BudgetStatus{
+		{BudgetName: "prod", Provider: "azure", Scope: "sub-1", Limit: 1000, CurrentSpend: 400},
+		{BudgetName: "group", Provider: "azure", Scope: "/providers/Microsoft.Management/managementGroups/mg-1", Limit: 5000, CurrentSpend: 1200},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetBudgets() = %+v, want %+v", got, want)
+	}
+}
+
+func TestCreateBudget(t *testing.T) {
+	type put struct {
+		tenant, path string
+		budget       consumptionBudget
+	}
+	var puts []put
+	handler := func(name string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPut {
+				t.Errorf("%s %s, want PUT", r.Method, r.URL)
+			}
+			var b consumptionBudget
+			json.NewDecoder(r.Body).Decode(&b)
+			puts = append(puts, put{name, r.URL.Path, b})
+			w.WriteHeader(http.StatusCreated)
+		}
+	}
+	primary := newTestTenant(t, config.AzureTenantConfig{SubscriptionIDs: []string{"sub-1"}}, handler("primary"))
+	other := newTestTenant(t, config.AzureTenantConfig{TenantID: "other", ManagementGroups: []string{"mg-2"}}, handler("other"))
+	p := budgetsProvider(t, primary, other)
+
+	budgets := []config.Budget{
+		{Name: "dev team", Scope: "sub-1", MonthlyLimit: 250, AlertAt: []int{80}, NotifyEmails: []string{"team@example.com"}},
+		{Name: "group", Scope: "/providers/Microsoft.Management/managementGroups/mg-2", MonthlyLimit: 900, AlertAt: []int{50}},
+	}
+	for _, b := range budgets {
+		if err := p.CreateBudget(context.Background(), b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := p.CreateBudget(context.Background(), config.Budget{Name: "nowhere", MonthlyLimit: 10}); err == nil {
+		t.Error("CreateBudget() without a scope succeeded, want an error")
+	}
+
+	if len(puts) != 2 {
+		t.Fatalf("got %d requests, want 2", len(puts))
+	}
+	dev, group := puts[0], puts[1]
+	if dev.tenant != "primary" || dev.path != "/subscriptions/sub-1/providers/Microsoft.Consumption/budgets/dev team" {
+		t.Errorf("dev team created in %s at %s", dev.tenant, dev.path)
+	}
+	props := dev.budget.Properties
+	if props.Category != "Cost" || props.Amount != 250 || props.TimeGrain != "Monthly" || props.TimePeriod == nil || !strings.HasSuffix(props.TimePeriod.StartDate, "-01T00:00:00Z") {
+		t.Errorf("dev team budget = %+v, want a monthly 250 cost budget from the 1st", props)
+	}
+	wantNotification := budgetNotification{Enabled: true, Operator: "GreaterThan", Threshold: 80, ThresholdType: "Actual", ContactEmails: []string{"team@example.com"}}
+	if n := props.Notifications["Actual_GreaterThan_80_Percent"]; len(props.Notifications) != 1 || !reflect.DeepEqual(n, wantNotification) {
+		t.Errorf("notifications = %+v, want one at 80%%", props.Notifications)
+	}
+
+	// The management group's tenant creates its budget, without
+	// notifications as no one is to be emailed
+	if group.tenant != "other" || !strings.HasPrefix(group.path, "/providers/Microsoft.Management/managementGroups/mg-2/") {
+		t.Errorf("group created in %s at %s", group.tenant, group.path)
+	}
+	if group.budget.Properties.Notifications != nil {
+		t.Errorf("notifications = %+v, want none", group.budget.Properties.Notifications)
+	}
+}
@@ -3,20 +3,43 @@ package azure
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/costmanagement/armcostmanagement"
 
 	"github.com/lvonguyen/finops-platform/internal/aggregator"
 	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+	"github.com/lvonguyen/finops-platform/internal/providers"
+	"github.com/lvonguyen/finops-platform/internal/resilience"
+	"github.com/lvonguyen/finops-platform/internal/telemetry"
 )
 
 // CostProvider implements aggregator.CostProvider for Azure
 type CostProvider struct {
-	client *armcostmanagement.QueryClient
-	config config.AzureConfig
+	tenants []*tenant
+	config  config.AzureConfig
+	calls   *resilience.Caller
+}
+
+func init() {
+	providers.MustRegister(providers.Provider{
+		Name: "azure",
+		New: func(ctx context.Context, cfg *config.Config) (aggregator.CostProvider, any, error) {
+			p, err := NewCostProvider(ctx, cfg.Azure)
+			if err != nil {
+				return nil, nil, err
+			}
+			return p, cfg.Azure, nil
+		},
+	})
 }
 
 // NewCostProvider creates a new Azure cost provider
@@ -25,32 +48,55 @@ func NewCostProvider(ctx context.Context, cfg config.AzureConfig) (*CostProvider
 		return nil, fmt.Errorf("Azure provider is disabled")
 	}
 
-	var cred *azidentity.DefaultAzureCredential
-	var err error
-
-	if cfg.UseMSI {
-		cred, err = azidentity.NewDefaultAzureCredential(nil)
-	} else {
-		cred, err = azidentity.NewDefaultAzureCredential(&azidentity.DefaultAzureCredentialOptions{
-			TenantID: cfg.TenantID,
-		})
-	}
-
+	tenants, err := newTenants(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create credential: %w", err)
+		return nil, err
 	}
-
-	client, err := armcostmanagement.NewQueryClient(cred, nil)
+	calls, err := resilience.New("azure", cfg.Calls, retryable)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create cost management client: %w", err)
+		return nil, err
 	}
 
 	return &CostProvider{
-		client: client,
-		config: cfg,
+		tenants: tenants,
+		config:  cfg,
+		calls:   calls,
 	}, nil
 }
 
+// RefreshCredentials re-acquires the credentials, discarding any cached
+// tokens
+func (p *CostProvider) RefreshCredentials(ctx context.Context) error {
+	tenants, err := newTenants(p.config)
+	if err != nil {
+		return err
+	}
+	p.tenants = tenants
+	return nil
+}
+
+// classifyError marks credential failures with aggregator.ErrAuthExpired
+func classifyError(err error) error {
+	var authErr *azidentity.AuthenticationFailedError
+	if errors.As(err, &authErr) {
+		return fmt.Errorf("%w: %w", aggregator.ErrAuthExpired, err)
+	}
+
+	var respErr *azcore.ResponseError
+	if errors.As(err, &respErr) && (respErr.StatusCode == http.StatusUnauthorized || respErr.StatusCode == http.StatusForbidden) {
+		return fmt.Errorf("%w: %w", aggregator.ErrAuthExpired, err)
+	}
+
+	return err
+}
+
+// retryable reports throttling and server errors, which Cost Management
+// returns when a query exceeds its rate limits
+func retryable(err error) bool {
+	var respErr *azcore.ResponseError
+	return errors.As(err, &respErr) && (respErr.StatusCode == http.StatusTooManyRequests || respErr.StatusCode >= 500)
+}
+
 // Name returns the provider name
 func (p *CostProvider) Name() string {
 	return "azure"
@@ -58,87 +104,295 @@ func (p *CostProvider) Name() string {
 
 // GetCosts retrieves costs from Azure Cost Management
 func (p *CostProvider) GetCosts(ctx context.Context, start, end time.Time) ([]aggregator.CostEntry, error) {
+	return p.queryCosts(ctx, start, end, aggregator.CostFilter{})
+}
+
+// GetFilteredCosts retrieves costs matching filter. Accounts select the
+// subscriptions queried, or the subscriptions kept from management group and
+// billing account scopes; the rest is sent as the query's filter.
+func (p *CostProvider) GetFilteredCosts(ctx context.Context, start, end time.Time, filter aggregator.CostFilter) ([]aggregator.CostEntry, error) {
+	return p.queryCosts(ctx, start, end, filter)
+}
+
+// queryFilter converts a cost filter, minus accounts, to a Cost Management
+// query filter, nil when it matches everything
+func queryFilter(f aggregator.CostFilter) *armcostmanagement.QueryFilter {
+	in := func(name string, values []string) *armcostmanagement.QueryComparisonExpression {
+		ptrs := make([]*string, len(values))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		return &armcostmanagement.QueryComparisonExpression{
+			Name:     toPtr(name),
+			Operator: toPtr(armcostmanagement.QueryOperatorTypeIn),
+			Values:   ptrs,
+		}
+	}
+
+	var filters []*armcostmanagement.QueryFilter
+	if len(f.Services) > 0 {
+		filters = append(filters, &armcostmanagement.QueryFilter{Dimensions: in("ServiceName", f.Services)})
+	}
+	if len(f.Regions) > 0 {
+		filters = append(filters, &armcostmanagement.QueryFilter{Dimensions: in("ResourceLocation", f.Regions)})
+	}
+	for _, key := range f.TagKeys() {
+		filters = append(filters, &armcostmanagement.QueryFilter{Tags: in(key, f.Tags[key])})
+	}
+
+	switch len(filters) {
+	case 0:
+		return nil
+	case 1:
+		return filters[0]
+	}
+	// And requires at least two operands
+	return &armcostmanagement.QueryFilter{And: filters}
+}
+
+// pricingModels maps Azure PricingModel values to CostRecord pricing models
+var pricingModels = map[string]string{
+	"OnDemand":    "on_demand",
+	"Reservation": "reserved",
+	"SavingsPlan": "savings_plan",
+	"Spot":        "spot",
+}
+
+// costRow is one row of a query result
+type costRow struct {
+	date     time.Time
+	cost     float64
+	currency string
+	dims     map[string]string
+}
+
+func (p *CostProvider) queryCosts(ctx context.Context, start, end time.Time, filter aggregator.CostFilter) ([]aggregator.CostEntry, error) {
 	entries := make([]aggregator.CostEntry, 0)
 
+	dims := []string{"ServiceName", "ResourceLocation", "ChargeType"}
+	if p.config.ReservationDetail {
+		dims = append(dims, "PricingModel", "ReservationId", "ReservationName")
+	}
+
+	for _, t := range p.tenants {
+		scopes, err := t.queryScopes(ctx)
+		if err != nil {
+			return nil, err
+		}
+		// Subscriptions queried on their own are left out of wider scopes
+		direct := make(map[string]bool)
+		for _, scope := range scopes {
+			if scope.subscription != "" {
+				direct[scope.subscription] = true
+			}
+		}
+
+		for _, scope := range scopes {
+			name := scope.subscription
+			scopeDims := dims
+			if name == "" {
+				name = scope.path
+				scopeDims = append([]string{"SubscriptionId"}, dims...)
+			} else if !filter.MatchesAccount(name) {
+				continue
+			}
+
+			actual, err := p.query(ctx, t.client, scope.path, armcostmanagement.ExportTypeActualCost, start, end, scopeDims, filter)
+			if err != nil {
+				return nil, fmt.Errorf("failed to query costs for %s: %w", name, classifyError(err))
+			}
+			var amortized []costRow
+			if p.config.Amortized {
+				amortized, err = p.query(ctx, t.client, scope.path, armcostmanagement.ExportTypeAmortizedCost, start, end, scopeDims, filter)
+				if err != nil {
+					return nil, fmt.Errorf("failed to query amortized costs for %s: %w", name, classifyError(err))
+				}
+			}
+
+			for _, e := range mergeRows(scope.subscription, scopeDims, actual, amortized, p.config.Amortized) {
+				if scope.subscription == "" && (direct[e.AccountID] || !filter.MatchesAccount(e.AccountID)) {
+					continue
+				}
+				entries = append(entries, e)
+			}
+		}
+	}
+
+	return entries, nil
+}
+
+// query runs one cost query grouped by dims
+func (p *CostProvider) query(ctx context.Context, client *armcostmanagement.QueryClient, scope string, costType armcostmanagement.ExportType, start, end time.Time, dims []string, filter aggregator.CostFilter) ([]costRow, error) {
 	granularity := armcostmanagement.GranularityType("Daily")
 	if p.config.Granularity == "MONTHLY" {
 		granularity = armcostmanagement.GranularityType("Monthly")
 	}
 
-	for _, subscriptionID := range p.config.SubscriptionIDs {
-		scope := fmt.Sprintf("/subscriptions/%s", subscriptionID)
+	grouping := make([]*armcostmanagement.QueryGrouping, len(dims))
+	for i, dim := range dims {
+		grouping[i] = &armcostmanagement.QueryGrouping{
+			Type: toPtr(armcostmanagement.QueryColumnTypeDimension),
+			Name: toPtr(dim),
+		}
+	}
 
-		// Build query
-		query := armcostmanagement.QueryDefinition{
-			Type:      toPtr(armcostmanagement.ExportTypeActualCost),
-			Timeframe: toPtr(armcostmanagement.TimeframeTypeCustom),
-			TimePeriod: &armcostmanagement.QueryTimePeriod{
-				From: &start,
-				To:   &end,
-			},
-			Dataset: &armcostmanagement.QueryDataset{
-				Granularity: &granularity,
-				Grouping: []*armcostmanagement.QueryGrouping{
-					{
-						Type: toPtr(armcostmanagement.QueryColumnTypeDimension),
-						Name: toPtr("ServiceName"),
-					},
-					{
-						Type: toPtr(armcostmanagement.QueryColumnTypeDimension),
-						Name: toPtr("ResourceLocation"),
-					},
-				},
-				Aggregation: map[string]*armcostmanagement.QueryAggregation{
-					"totalCost": {
-						Name:     toPtr("Cost"),
-						Function: toPtr(armcostmanagement.FunctionTypeSum),
-					},
+	// Build query
+	query := armcostmanagement.QueryDefinition{
+		Type:      toPtr(costType),
+		Timeframe: toPtr(armcostmanagement.TimeframeTypeCustom),
+		TimePeriod: &armcostmanagement.QueryTimePeriod{
+			From: &start,
+			To:   &end,
+		},
+		Dataset: &armcostmanagement.QueryDataset{
+			Granularity: &granularity,
+			Grouping:    grouping,
+			Aggregation: map[string]*armcostmanagement.QueryAggregation{
+				"totalCost": {
+					Name:     toPtr("Cost"),
+					Function: toPtr(armcostmanagement.FunctionTypeSum),
 				},
 			},
+			Filter: queryFilter(filter),
+		},
+	}
+
+	callCtx, span := telemetry.StartCall(ctx, "azure.Usage", 1)
+	var result armcostmanagement.QueryClientUsageResponse
+	err := p.calls.Do(callCtx, func(ctx context.Context) error {
+		var err error
+		result, err = client.Usage(ctx, scope, query, nil)
+		return err
+	})
+	returned := 0
+	if err == nil && result.Properties != nil {
+		returned = len(result.Properties.Rows)
+	}
+	telemetry.End(span, returned, err)
+	if err != nil {
+		return nil, err
+	}
+	if result.Properties == nil {
+		return nil, nil
+	}
+
+	// Columns are located by name: the cost, the usage date, the
+	// grouped dimensions and the currency, in the order Azure chooses
+	index := make(map[string]int)
+	for i, col := range result.Properties.Columns {
+		if col != nil && col.Name != nil {
+			index[*col.Name] = i
 		}
+	}
+	costCol, ok := index["Cost"]
+	if !ok {
+		costCol, ok = index["totalCost"]
+	}
+	if !ok {
+		return nil, fmt.Errorf("cost query returned no cost column")
+	}
+	text := func(row []any, name string) string {
+		if i, ok := index[name]; ok && i < len(row) {
+			switch v := row[i].(type) {
+			case string:
+				return v
+			case float64:
+				return strconv.FormatFloat(v, 'f', -1, 64)
+			}
+		}
+		return ""
+	}
 
-		result, err := p.client.Usage(ctx, scope, query, nil)
-		if err != nil {
-			return nil, fmt.Errorf("failed to query costs for %s: %w", subscriptionID, err)
+	rows := make([]costRow, 0, len(result.Properties.Rows))
+	for _, row := range result.Properties.Rows {
+		if costCol >= len(row) {
+			continue
 		}
+		cost, _ := row[costCol].(float64)
+		r := costRow{cost: cost, currency: text(row, "Currency"), dims: make(map[string]string, len(dims))}
+		// UsageDate is a number such as 20240115; monthly results carry BillingMonth
+		if date := text(row, "UsageDate"); date != "" {
+			r.date, _ = time.Parse("20060102", date)
+		} else if month := text(row, "BillingMonth"); month != "" {
+			r.date, _ = time.Parse(time.RFC3339, month)
+		}
+		for _, dim := range dims {
+			r.dims[dim] = text(row, dim)
+		}
+		rows = append(rows, r)
+	}
+	return rows, nil
+}
 
-		// Parse results
-		if result.Properties != nil && result.Properties.Rows != nil {
-			for _, row := range result.Properties.Rows {
-				if len(row) < 4 {
-					continue
-				}
+// mergeRows joins actual and amortized rows on date and dimensions into
+// entries, the amortized cost becoming the effective cost. Purchases only
+// appear in actual cost and amortized usage only in amortized cost, so
+// either side may be missing. Without amortized rows, reservation charges
+// keep their actual cost as effective cost rather than reading as zero.
+// Rows grouped by SubscriptionId belong to that subscription.
+func mergeRows(subscriptionID string, dims []string, actual, amortized []costRow, withAmortized bool) []aggregator.CostEntry {
+	byKey := make(map[string]*aggregator.CostEntry)
+	var order []string
+	entry := func(r costRow) *aggregator.CostEntry {
+		parts := []string{r.date.Format("2006-01-02")}
+		for _, dim := range dims {
+			parts = append(parts, r.dims[dim])
+		}
+		key := strings.Join(parts, "|")
+		if e, ok := byKey[key]; ok {
+			return e
+		}
 
-				// Row format: [cost, date, serviceName, region]
-				cost, _ := row[0].(float64)
-				dateStr, _ := row[1].(string)
-				service, _ := row[2].(string)
-				region, _ := row[3].(string)
-
-				date, _ := time.Parse("20060102", dateStr)
-
-				entries = append(entries, aggregator.CostEntry{
-					Provider:  "azure",
-					AccountID: subscriptionID,
-					Service:   service,
-					Region:    region,
-					Date:      date,
-					Cost:      cost,
-					Currency:  "USD",
-				})
-			}
+		currency := r.currency
+		if currency == "" {
+			currency = "USD"
 		}
+		account := subscriptionID
+		if id := r.dims["SubscriptionId"]; id != "" {
+			account = id
+		}
+		e := &aggregator.CostEntry{
+			Provider:  "azure",
+			AccountID: account,
+			Service:   r.dims["ServiceName"],
+			Region:    r.dims["ResourceLocation"],
+			Date:      r.date,
+			Currency:  currency,
+
+			ChargeType:   string(normalizer.ParseChargeType(r.dims["ChargeType"])),
+			LineItemType: r.dims["ChargeType"],
+
+			PricingModel:           pricingModels[r.dims["PricingModel"]],
+			CommitmentDiscountID:   r.dims["ReservationId"],
+			CommitmentDiscountName: r.dims["ReservationName"],
+		}
+		if e.CommitmentDiscountID != "" {
+			e.CommitmentDiscountCategory = "Usage"
+			e.CommitmentDiscountType = "Reservation"
+		}
+		byKey[key] = e
+		order = append(order, key)
+		return e
 	}
 
-	return entries, nil
-}
+	for _, r := range actual {
+		entry(r).Cost += r.cost
+	}
+	for _, r := range amortized {
+		entry(r).EffectiveCost += r.cost
+	}
 
-// GetBudgets retrieves budget status from Azure
-func (p *CostProvider) GetBudgets(ctx context.Context) ([]aggregator.BudgetStatus, error) {
-	return nil, nil
+	entries := make([]aggregator.CostEntry, 0, len(order))
+	for _, key := range order {
+		e := *byKey[key]
+		if !withAmortized && e.CommitmentDiscountID != "" {
+			e.EffectiveCost = e.Cost
+		}
+		entries = append(entries, e)
+	}
+	return entries
 }
 
 func toPtr[T any](v T) *T {
 	return &v
 }
-
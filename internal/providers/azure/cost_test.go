@@ -0,0 +1,90 @@
+package azure
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/costmanagement/armcostmanagement"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+)
+
+// values returns the values of a comparison expression
+func values(e *armcostmanagement.QueryComparisonExpression) []string {
+	var v []string
+	for _, s := range e.Values {
+		v = append(v, *s)
+	}
+	return v
+}
+
+func TestQueryFilter(t *testing.T) {
+	if f := queryFilter(aggregator.CostFilter{Accounts: []string{"sub-1"}}); f != nil {
+		t.Errorf("accounts-only filter = %+v, want nil; accounts select subscriptions", f)
+	}
+
+	one := queryFilter(aggregator.CostFilter{Regions: []string{"eastus", "westus"}})
+	if one == nil || one.Dimensions == nil || *one.Dimensions.Name != "ResourceLocation" || len(one.And) != 0 {
+		t.Fatalf("one dimension = %+v, want a bare ResourceLocation comparison", one)
+	}
+	if got := values(one.Dimensions); len(got) != 2 || got[0] != "eastus" || got[1] != "westus" {
+		t.Errorf("regions = %v, want [eastus westus]", got)
+	}
+
+	all := queryFilter(aggregator.CostFilter{
+		Services: []string{"Virtual Machines"},
+		Tags:     map[string][]string{"env": {"prod"}},
+	})
+	if all == nil || len(all.And) != 2 {
+		t.Fatalf("services and tags = %+v, want an And of two", all)
+	}
+	if d := all.And[0].Dimensions; d == nil || *d.Name != "ServiceName" || *d.Operator != armcostmanagement.QueryOperatorTypeIn {
+		t.Errorf("first operand = %+v, want ServiceName In", all.And[0])
+	}
+	if tag := all.And[1].Tags; tag == nil || *tag.Name != "env" || values(tag)[0] != "prod" {
+		t.Errorf("second operand = %+v, want tag env In [prod]", all.And[1])
+	}
+}
+
+func TestMergeRows(t *testing.T) {
+	day := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	dims := []string{"ServiceName", "ResourceLocation", "ChargeType", "PricingModel", "ReservationId", "ReservationName"}
+	row := func(cost float64, service, pricing, reservation string) costRow {
+		return costRow{date: day, cost: cost, dims: map[string]string{
+			"ServiceName": service, "ResourceLocation": "eastus", "ChargeType": "Usage",
+			"PricingModel": pricing, "ReservationId": reservation, "ReservationName": reservation + "-name",
+		}}
+	}
+	actual := []costRow{
+		row(10, "Virtual Machines", "OnDemand", ""),
+		row(300, "Virtual Machines", "Reservation", "ri-1"), // the purchase
+	}
+	amortized := []costRow{
+		row(10, "Virtual Machines", "OnDemand", ""),
+		row(120, "Virtual Machines", "Reservation", "ri-1"),
+		row(5, "Storage", "OnDemand", ""), // only in amortized cost
+	}
+
+	entries := mergeRows("sub-1", dims, actual, amortized, true)
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries, want 3", len(entries))
+	}
+	onDemand, reserved, storage := entries[0], entries[1], entries[2]
+	if onDemand.Cost != 10 || onDemand.EffectiveCost != 10 || onDemand.PricingModel != "on_demand" || onDemand.CommitmentDiscountID != "" {
+		t.Errorf("on-demand entry = %+v", onDemand)
+	}
+	if reserved.Cost != 300 || reserved.EffectiveCost != 120 || reserved.PricingModel != "reserved" ||
+		reserved.CommitmentDiscountID != "ri-1" || reserved.CommitmentDiscountName != "ri-1-name" ||
+		reserved.CommitmentDiscountType != "Reservation" || reserved.CommitmentDiscountCategory != "Usage" {
+		t.Errorf("reserved entry = %+v", reserved)
+	}
+	if storage.Cost != 0 || storage.EffectiveCost != 5 || storage.Currency != "USD" || storage.AccountID != "sub-1" {
+		t.Errorf("amortized-only entry = %+v", storage)
+	}
+
+	// Without amortized cost a reservation keeps its actual cost as effective
+	entries = mergeRows("sub-1", dims, actual, nil, false)
+	if len(entries) != 2 || entries[0].EffectiveCost != 0 || entries[1].EffectiveCost != 300 {
+		t.Errorf("actual-only entries = %+v, want effective cost only on the reservation", entries)
+	}
+}
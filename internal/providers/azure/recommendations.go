@@ -0,0 +1,162 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/recommendations"
+	"github.com/lvonguyen/finops-platform/internal/resilience"
+	"github.com/lvonguyen/finops-platform/internal/telemetry"
+)
+
+const advisorAPIVersion = "2023-01-01"
+
+// Recommender collects Azure Advisor cost recommendations
+type Recommender struct {
+	tenants []*tenant
+	calls   *resilience.Caller
+	options recommendations.Options
+}
+
+// NewRecommender creates a recommender with the Azure provider's credentials
+func NewRecommender(ctx context.Context, cfg config.AzureConfig, recCfg config.RecommendationsConfig) (*Recommender, error) {
+	if !cfg.Enabled {
+		return nil, fmt.Errorf("Azure provider is disabled")
+	}
+	opts, err := recommendations.OptionsFrom(recCfg)
+	if err != nil {
+		return nil, err
+	}
+	tenants, err := newTenants(cfg)
+	if err != nil {
+		return nil, err
+	}
+	calls, err := resilience.New("azure", cfg.Calls, retryable)
+	if err != nil {
+		return nil, err
+	}
+	return &Recommender{tenants: tenants, calls: calls, options: opts}, nil
+}
+
+// advisorRecommendation is the part of an Advisor recommendation read here;
+// extended properties are strings
+type advisorRecommendation struct {
+	Properties struct {
+		Category         string `json:"category"`
+		ImpactedField    string `json:"impactedField"`
+		ImpactedValue    string `json:"impactedValue"`
+		ShortDescription struct {
+			Problem string `json:"problem"`
+		} `json:"shortDescription"`
+		ExtendedProperties map[string]string `json:"extendedProperties"`
+	} `json:"properties"`
+}
+
+// GetRecommendations returns the Advisor cost recommendations of every
+// subscription configured or discovered. Management groups and billing
+// accounts queried whole have no subscriptions to ask.
+func (r *Recommender) GetRecommendations(ctx context.Context) ([]recommendations.Recommendation, error) {
+	if !r.options.Uses(recommendations.SourceAdvisor) {
+		return nil, nil
+	}
+	var recs []recommendations.Recommendation
+	for _, t := range r.tenants {
+		scopes, err := t.queryScopes(ctx)
+		if err != nil {
+			return recs, err
+		}
+		for _, scope := range scopes {
+			if scope.subscription == "" {
+				continue
+			}
+			found, err := r.listAdvisor(ctx, t, scope.subscription)
+			if err != nil {
+				return recs, err
+			}
+			recs = append(recs, found...)
+		}
+	}
+	return recs, nil
+}
+
+// listAdvisor lists a subscription's cost recommendations
+func (r *Recommender) listAdvisor(ctx context.Context, t *tenant, subscription string) ([]recommendations.Recommendation, error) {
+	next := fmt.Sprintf("%s/subscriptions/%s/providers/Microsoft.Advisor/recommendations?api-version=%s&$filter=%s",
+		strings.TrimSuffix(t.arm.Endpoint(), "/"), subscription, advisorAPIVersion, url.QueryEscape("Category eq 'Cost'"))
+
+	var recs []recommendations.Recommendation
+	for page := 1; next != ""; page++ {
+		callCtx, span := telemetry.StartCall(ctx, "azure.Advisor.List", page)
+		var result struct {
+			Value    []advisorRecommendation `json:"value"`
+			NextLink string                  `json:"nextLink"`
+		}
+		err := r.calls.Do(callCtx, func(ctx context.Context) error {
+			return t.get(ctx, next, &result)
+		})
+		telemetry.End(span, len(result.Value), err)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list Advisor recommendations of subscription %s: %w", subscription, classifyError(err))
+		}
+		for _, a := range result.Value {
+			if a.Properties.Category == "Cost" {
+				recs = append(recs, fromAdvisor(a, subscription))
+			}
+		}
+		next = result.NextLink
+	}
+	return recs, nil
+}
+
+// fromAdvisor normalizes an Advisor recommendation. Advisor estimates only
+// the savings; reservation and savings plan purchases carry a term.
+func fromAdvisor(a advisorRecommendation, subscription string) recommendations.Recommendation {
+	ext := a.Properties.ExtendedProperties
+	rec := recommendations.Recommendation{
+		Provider:     "azure",
+		Source:       recommendations.SourceAdvisor,
+		Account:      subscription,
+		Region:       firstOf(ext["regionId"], ext["region"], ext["location"]),
+		ResourceID:   a.Properties.ImpactedValue,
+		ResourceType: a.Properties.ImpactedField,
+		Finding:      a.Properties.ShortDescription.Problem,
+		Action:       recommendations.ActionModify,
+		Current:      ext["currentSku"],
+		Recommended:  ext["targetSku"],
+		Currency:     ext["savingsCurrency"],
+	}
+	if id := ext["subscriptionId"]; id != "" {
+		rec.Account = id
+	}
+
+	rec.Savings, _ = strconv.ParseFloat(ext["savingsAmount"], 64)
+	if rec.Savings == 0 {
+		annual, _ := strconv.ParseFloat(ext["annualSavingsAmount"], 64)
+		rec.Savings = annual / 12
+	}
+
+	switch {
+	case strings.EqualFold(ext["recommendationType"], "Shutdown"):
+		rec.Action, rec.Recommended = recommendations.ActionTerminate, ""
+	case ext["term"] != "":
+		rec.Action = recommendations.ActionPurchase
+		rec.Current = "on-demand"
+		rec.Recommended = strings.TrimSpace(fmt.Sprintf("%s %s %s commitment",
+			ext["displayQty"], firstOf(ext["displaySKU"], ext["vmSize"], ext["sku"]), ext["term"]))
+	}
+	return rec
+}
+
+// firstOf returns the first non-empty value
+func firstOf(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,107 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/recommendations"
+	"github.com/lvonguyen/finops-platform/internal/resilience"
+)
+
+// advisorJSON is an Advisor recommendation in its JSON form
+func advisorJSON(category, resource, problem string, ext map[string]string) map[string]any {
+	return map[string]any{"properties": map[string]any{
+		"category": category, "impactedField": "Microsoft.Compute/virtualMachines", "impactedValue": resource,
+		"shortDescription": map[string]any{"problem": problem}, "extendedProperties": ext,
+	}}
+}
+
+func TestGetAdvisorRecommendations(t *testing.T) {
+	tn := newTestTenant(t, config.AzureTenantConfig{
+		SubscriptionIDs:  []string{"sub-1"},
+		ManagementGroups: []string{"mg-1"},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/subscriptions/sub-1/providers/Microsoft.Advisor/recommendations" ||
+			q.Get("api-version") != advisorAPIVersion || q.Get("$filter") != "Category eq 'Cost'" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		var out map[string]any
+		if q.Get("page") == "" {
+			out = map[string]any{
+				"value": []any{
+					advisorJSON("Cost", "vm-1", "Right-size or shutdown underutilized virtual machines",
+						map[string]string{"currentSku": "Standard_D8s_v3", "targetSku": "Standard_D4s_v3", "savingsAmount": "150", "savingsCurrency": "USD", "regionId": "eastus"}),
+					advisorJSON("HighAvailability", "vm-1", "Use availability zones", nil),
+				},
+				"nextLink": "https://" + r.Host + r.URL.Path + "?api-version=" + advisorAPIVersion + "&$filter=" + url.QueryEscape(q.Get("$filter")) + "&page=2",
+			}
+		} else {
+			out = map[string]any{"value": []any{
+				advisorJSON("Cost", "vm-2", "Shut down idle virtual machines",
+					map[string]string{"recommendationType": "Shutdown", "currentSku": "Standard_B2s", "targetSku": "Standard_B1s", "savingsAmount": "30", "savingsCurrency": "USD"}),
+			}}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(out)
+	})
+	calls, err := resilience.New("azure-test", config.CallConfig{}, retryable)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := &Recommender{tenants: []*tenant{tn}, calls: calls}
+	got, err := r.GetRecommendations(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []recommendations.Recommendation{
+		{Provider: "azure", Source: recommendations.SourceAdvisor, Account: "sub-1", Region: "eastus", ResourceID: "vm-1",
+			ResourceType: "Microsoft.Compute/virtualMachines", Finding: "Right-size or shutdown underutilized virtual machines",
+			Action: recommendations.ActionModify, Current: "Standard_D8s_v3", Recommended: "Standard_D4s_v3", Savings: 150, Currency: "USD"},
+		{Provider: "azure", Source: recommendations.SourceAdvisor, Account: "sub-1", ResourceID: "vm-2",
+			ResourceType: "Microsoft.Compute/virtualMachines", Finding: "Shut down idle virtual machines",
+			Action: recommendations.ActionTerminate, Current: "Standard_B2s", Savings: 30, Currency: "USD"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetRecommendations() = %+v, want %+v", got, want)
+	}
+
+	r.options = recommendations.Options{Sources: map[string]bool{recommendations.SourceRecommender: true}}
+	if got, err := r.GetRecommendations(context.Background()); got != nil || err != nil {
+		t.Errorf("GetRecommendations() = %v, %v without advisor among the sources, want nothing", got, err)
+	}
+}
+
+func TestFromAdvisor(t *testing.T) {
+	tests := []struct {
+		name string
+		ext  map[string]string
+		want string // account action current->recommended savings
+	}{
+		{"annual savings", map[string]string{"currentSku": "P2v3", "targetSku": "P1v3", "annualSavingsAmount": "1200"},
+			"sub-1 modify P2v3->P1v3 100"},
+		{"other subscription", map[string]string{"subscriptionId": "sub-2", "savingsAmount": "5"},
+			"sub-2 modify -> 5"},
+		{"reservation", map[string]string{"term": "P3Y", "displayQty": "4", "displaySKU": "Standard_D2s_v3", "savingsAmount": "80"},
+			"sub-1 purchase on-demand->4 Standard_D2s_v3 P3Y commitment 80"},
+		{"savings plan", map[string]string{"term": "P1Y", "savingsAmount": "20"},
+			"sub-1 purchase on-demand->P1Y commitment 20"},
+	}
+	for _, tt := range tests {
+		var a advisorRecommendation
+		a.Properties.ExtendedProperties = tt.ext
+		r := fromAdvisor(a, "sub-1")
+		got := strings.Join([]string{r.Account, r.Action, r.Current + "->" + r.Recommended, strconv.FormatFloat(r.Savings, 'f', -1, 64)}, " ")
+		if got != tt.want {
+			t.Errorf("%s: fromAdvisor() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/costmanagement/armcostmanagement"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/telemetry"
+)
+
+// managementScope is the OAuth scope for Azure Resource Manager
+const managementScope = "https://management.azure.com/.default"
+
+// tenant is one tenant's credentials, clients and configured scopes
+type tenant struct {
+	client *armcostmanagement.QueryClient
+	arm    *arm.Client // for management group discovery
+	scopes config.AzureTenantConfig
+
+	cred azcore.TokenCredential
+}
+
+// queryScope is a scope to query and the subscription its costs belong to,
+// empty for scopes whose costs are broken down by subscription
+type queryScope struct {
+	path         string
+	subscription string
+}
+
+// newTenants builds the configured tenant and each further tenant
+func newTenants(cfg config.AzureConfig) ([]*tenant, error) {
+	var cred azcore.TokenCredential
+	var err error
+	if cfg.UseMSI {
+		cred, err = azidentity.NewDefaultAzureCredential(nil)
+	} else {
+		cred, err = azidentity.NewDefaultAzureCredential(&azidentity.DefaultAzureCredentialOptions{
+			TenantID: cfg.TenantID,
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create credential: %w", err)
+	}
+
+	primary, err := newTenant(cred, config.AzureTenantConfig{
+		TenantID:              cfg.TenantID,
+		SubscriptionIDs:       cfg.SubscriptionIDs,
+		ManagementGroups:      cfg.ManagementGroups,
+		BillingAccounts:       cfg.BillingAccounts,
+		DiscoverSubscriptions: cfg.DiscoverSubscriptions,
+	})
+	if err != nil {
+		return nil, err
+	}
+	tenants := []*tenant{primary}
+
+	for _, tc := range cfg.Tenants {
+		if tc.ClientID != "" {
+			cred, err = azidentity.NewClientSecretCredential(tc.TenantID, tc.ClientID, tc.ClientSecret, nil)
+		} else {
+			cred, err = azidentity.NewDefaultAzureCredential(&azidentity.DefaultAzureCredentialOptions{
+				TenantID: tc.TenantID,
+			})
+		}
+		if err != nil {
+			return nil, fmt.Errorf("tenant %s: failed to create credential: %w", tc.TenantID, err)
+		}
+		t, err := newTenant(cred, tc)
+		if err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", tc.TenantID, err)
+		}
+		tenants = append(tenants, t)
+	}
+	return tenants, nil
+}
+
+// CheckCredentials obtains a Resource Manager token for the configured
+// tenant and each further tenant
+func CheckCredentials(ctx context.Context, cfg config.AzureConfig) error {
+	tenants, err := newTenants(cfg)
+	if err != nil {
+		return err
+	}
+	for _, t := range tenants {
+		_, err := t.cred.GetToken(ctx, policy.TokenRequestOptions{Scopes: []string{managementScope}})
+		if err != nil && t.scopes.TenantID != "" {
+			return fmt.Errorf("tenant %s: failed to obtain token: %w", t.scopes.TenantID, err)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to obtain token: %w", err)
+		}
+	}
+	return nil
+}
+
+func newTenant(cred azcore.TokenCredential, scopes config.AzureTenantConfig) (*tenant, error) {
+	client, err := armcostmanagement.NewQueryClient(cred, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cost management client: %w", err)
+	}
+	armClient, err := arm.NewClient("finops.ManagementGroups", "v1.0.0", cred, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create resource manager client: %w", err)
+	}
+	return &tenant{client: client, arm: armClient, scopes: scopes, cred: cred}, nil
+}
+
+// queryScopes lists the tenant's scopes: its subscriptions, then its
+// management groups (or the subscriptions discovered under them), then its
+// billing accounts. Subscriptions are listed once.
+func (t *tenant) queryScopes(ctx context.Context) ([]queryScope, error) {
+	var scopes []queryScope
+	seen := make(map[string]bool)
+	subscription := func(id string) {
+		if !seen[id] {
+			seen[id] = true
+			scopes = append(scopes, queryScope{path: "/subscriptions/" + id, subscription: id})
+		}
+	}
+
+	for _, id := range t.scopes.SubscriptionIDs {
+		subscription(id)
+	}
+	for _, group := range t.scopes.ManagementGroups {
+		if !t.scopes.DiscoverSubscriptions {
+			scopes = append(scopes, queryScope{path: "/providers/Microsoft.Management/managementGroups/" + group})
+			continue
+		}
+		ids, err := t.discoverSubscriptions(ctx, group)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			subscription(id)
+		}
+	}
+	for _, account := range t.scopes.BillingAccounts {
+		scopes = append(scopes, queryScope{path: "/providers/Microsoft.Billing/billingAccounts/" + account})
+	}
+	return scopes, nil
+}
+
+// discoverSubscriptions lists the subscriptions anywhere under a
+// management group
+func (t *tenant) discoverSubscriptions(ctx context.Context, group string) ([]string, error) {
+	next := fmt.Sprintf("%s/providers/Microsoft.Management/managementGroups/%s/descendants?api-version=2020-05-01",
+		strings.TrimSuffix(t.arm.Endpoint(), "/"), url.PathEscape(group))
+
+	var ids []string
+	for page := 1; next != ""; page++ {
+		callCtx, span := telemetry.StartCall(ctx, "azure.ManagementGroupDescendants", page)
+		var result struct {
+			Value []struct {
+				Name string `json:"name"`
+				Type string `json:"type"`
+			} `json:"value"`
+			NextLink string `json:"nextLink"`
+		}
+		err := t.get(callCtx, next, &result)
+		telemetry.End(span, len(result.Value), err)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list subscriptions under management group %s: %w", group, classifyError(err))
+		}
+
+		for _, d := range result.Value {
+			// Descendant groups have type Microsoft.Management/managementGroups
+			if strings.HasSuffix(d.Type, "/subscriptions") {
+				ids = append(ids, d.Name)
+			}
+		}
+		next = result.NextLink
+	}
+	return ids, nil
+}
+
+// get sends a resource manager GET request and decodes the JSON response
+func (t *tenant) get(ctx context.Context, endpoint string, out any) error {
+	return t.send(ctx, http.MethodGet, endpoint, nil, out)
+}
+
+// send sends a resource manager request with an optional JSON body and
+// decodes the JSON response into out, if set
+func (t *tenant) send(ctx context.Context, method, endpoint string, body, out any) error {
+	req, err := runtime.NewRequest(ctx, method, endpoint)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		if err := runtime.MarshalAsJSON(req, body); err != nil {
+			return err
+		}
+	}
+	resp, err := t.arm.Pipeline().Do(req)
+	if err != nil {
+		return err
+	}
+	if !runtime.HasStatusCode(resp, http.StatusOK, http.StatusCreated) {
+		return runtime.NewResponseError(resp)
+	}
+	if out == nil {
+		return nil
+	}
+	return runtime.UnmarshalAsJSON(resp, out)
+}
@@ -0,0 +1,229 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/costmanagement/armcostmanagement"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/resilience"
+)
+
+// staticCredential returns a fixed token
+type staticCredential struct{}
+
+func (staticCredential) GetToken(ctx context.Context, opts policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return azcore.AccessToken{Token: "token", ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
+
+// newTestTenant returns a tenant whose resource manager requests are served
+// by handler
+func newTestTenant(t *testing.T, scopes config.AzureTenantConfig, handler http.HandlerFunc) *tenant {
+	t.Helper()
+	// Bearer tokens are only sent over TLS
+	srv := httptest.NewTLSServer(handler)
+	t.Cleanup(srv.Close)
+
+	opts := &arm.ClientOptions{ClientOptions: policy.ClientOptions{
+		Cloud: cloud.Configuration{
+			ActiveDirectoryAuthorityHost: srv.URL,
+			Services: map[cloud.ServiceName]cloud.ServiceConfiguration{
+				cloud.ResourceManager: {Endpoint: srv.URL, Audience: "https://management.azure.com"},
+			},
+		},
+		Transport: srv.Client(),
+		Retry:     policy.RetryOptions{MaxRetries: -1},
+	}, DisableRPRegistration: true}
+	client, err := armcostmanagement.NewQueryClient(staticCredential{}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	armClient, err := arm.NewClient("finops.ManagementGroups", "v1.0.0", staticCredential{}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &tenant{client: client, arm: armClient, scopes: scopes, cred: staticCredential{}}
+}
+
+// descendants serves a management group's descendants over two pages
+func descendants(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/managementGroups/mg-1/descendants") {
+			t.Errorf("unexpected request %s", r.URL)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("page") == "" {
+			json.NewEncoder(w).Encode(map[string]any{
+				"value": []map[string]string{
+					{"name": "sub-1", "type": "Microsoft.Management/managementGroups/subscriptions"},
+					{"name": "mg-child", "type": "Microsoft.Management/managementGroups"},
+				},
+				"nextLink": "https://" + r.Host + r.URL.Path + "?api-version=2020-05-01&page=2",
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"value": []map[string]string{{"name": "sub-3", "type": "Microsoft.Management/managementGroups/subscriptions"}},
+		})
+	}
+}
+
+func TestQueryScopes(t *testing.T) {
+	tests := []struct {
+		name   string
+		scopes config.AzureTenantConfig
+		want   []queryScope
+	}{
+		{"scopes", config.AzureTenantConfig{
+			SubscriptionIDs:  []string{"sub-1", "sub-2", "sub-1"},
+			ManagementGroups: []string{"mg-1"},
+			BillingAccounts:  []string{"1234"},
+		}, []queryScope{
+			{"/subscriptions/sub-1", "sub-1"},
+			{"/subscriptions/sub-2", "sub-2"},
+			{"/providers/Microsoft.Management/managementGroups/mg-1", ""},
+			{"/providers/Microsoft.Billing/billingAccounts/1234", ""},
+		}},
+		{"discovered", config.AzureTenantConfig{
+			SubscriptionIDs:       []string{"sub-1"},
+			ManagementGroups:      []string{"mg-1"},
+			DiscoverSubscriptions: true,
+		}, []queryScope{
+			{"/subscriptions/sub-1", "sub-1"},
+			{"/subscriptions/sub-3", "sub-3"},
+		}},
+	}
+	for _, tt := range tests {
+		got, err := newTestTenant(t, tt.scopes, descendants(t)).queryScopes(context.Background())
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: queryScopes() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDiscoverSubscriptionsDenied(t *testing.T) {
+	tn := newTestTenant(t, config.AzureTenantConfig{ManagementGroups: []string{"mg-1"}, DiscoverSubscriptions: true}, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	})
+	if _, err := tn.queryScopes(context.Background()); err == nil || !strings.Contains(err.Error(), aggregator.ErrAuthExpired.Error()) {
+		t.Errorf("err = %v, want an auth error", err)
+	}
+}
+
+// usageRows answers cost queries with rows of subscription, service and
+// cost, grouped by subscription for scopes wider than one
+func usageRows(rows map[string][][]any, queried *[]string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		scope := strings.TrimSuffix(strings.TrimLeft(r.URL.Path, "/"), "/providers/Microsoft.CostManagement/query")
+		*queried = append(*queried, scope)
+		columns := []map[string]string{{"name": "Cost"}, {"name": "UsageDate"}, {"name": "SubscriptionId"}, {"name": "ServiceName"}}
+		var out [][]any
+		for _, row := range rows[scope] {
+			out = append(out, append([]any{row[2], 20240301.0}, row[:2]...))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"properties": map[string]any{"columns": columns, "rows": out}})
+	}
+}
+
+func TestQueryCostsScopes(t *testing.T) {
+	var queried []string
+	first := newTestTenant(t, config.AzureTenantConfig{
+		SubscriptionIDs:  []string{"sub-1"},
+		ManagementGroups: []string{"mg-1"},
+	}, usageRows(map[string][][]any{
+		"subscriptions/sub-1": {{"", "Storage", 10.0}},
+		// sub-1 was queried on its own, so its share here is left out
+		"providers/Microsoft.Management/managementGroups/mg-1": {{"sub-1", "Storage", 10.0}, {"sub-2", "Virtual Machines", 20.0}},
+	}, &queried))
+	second := newTestTenant(t, config.AzureTenantConfig{TenantID: "other", BillingAccounts: []string{"1234"}}, usageRows(map[string][][]any{
+		"providers/Microsoft.Billing/billingAccounts/1234": {{"sub-9", "SQL Database", 30.0}},
+	}, &queried))
+	calls, err := resilience.New("azure-test", config.CallConfig{}, retryable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &CostProvider{tenants: []*tenant{first, second}, calls: calls}
+
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	entries, err := p.GetCosts(context.Background(), day, day.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]float64)
+	for _, e := range entries {
+		got[e.AccountID+" "+e.Service] += e.Cost
+		if !e.Date.Equal(day) {
+			t.Errorf("%s date = %s, want %s", e.AccountID, e.Date, day)
+		}
+	}
+	want := map[string]float64{"sub-1 Storage": 10, "sub-2 Virtual Machines": 20, "sub-9 SQL Database": 30}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("costs = %v, want %v", got, want)
+	}
+
+	// Account filters skip subscriptions and drop other subscriptions' rows
+	// from wider scopes
+	queried = nil
+	entries, err = p.GetFilteredCosts(context.Background(), day, day.AddDate(0, 0, 1), aggregator.CostFilter{Accounts: []string{"sub-2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].AccountID != "sub-2" {
+		t.Errorf("filtered entries = %+v, want sub-2's", entries)
+	}
+	for _, scope := range queried {
+		if scope == "subscriptions/sub-1" {
+			t.Error("filtered out subscription sub-1 was queried")
+		}
+	}
+}
+
+func TestCheckCredentials(t *testing.T) {
+	for _, key := range []string{"AZURE_TENANT_ID", "AZURE_CLIENT_ID", "AZURE_CLIENT_SECRET", "AZURE_FEDERATED_TOKEN_FILE"} {
+		t.Setenv(key, "")
+	}
+	tenants, err := newTenants(config.AzureConfig{TenantID: "t1", Tenants: []config.AzureTenantConfig{
+		{TenantID: "t2", ClientID: "client", ClientSecret: "secret"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tenants) != 2 || tenants[0].cred == nil || tenants[1].cred == nil {
+		t.Fatalf("newTenants() = %+v, want two tenants with credentials", tenants)
+	}
+
+	// Without a token the failing tenant is named, when configured
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		cfg  config.AzureConfig
+		want string
+	}{
+		{config.AzureConfig{TenantID: "t1"}, "tenant t1: failed to obtain token"},
+		{config.AzureConfig{}, "failed to obtain token"},
+	}
+	for _, tt := range tests {
+		if err := CheckCredentials(ctx, tt.cfg); err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+			t.Errorf("CheckCredentials(%+v) = %v, want %s", tt.cfg, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,314 @@
+// Package csvimport imports SaaS vendor costs from CSV invoice exports
+package csvimport
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+	"github.com/lvonguyen/finops-platform/internal/providers"
+)
+
+// CostProvider implements aggregator.CostProvider for CSV invoices of
+// vendors such as Datadog, GitHub or PagerDuty
+type CostProvider struct {
+	sources []source
+}
+
+// source is a configured vendor and its resolved files
+type source struct {
+	config.CSVSource
+	files []string
+}
+
+func init() {
+	providers.MustRegister(providers.Provider{
+		Name:    "csv-import",
+		Enabled: func(cfg *config.Config) bool { return cfg.CSVImport.Enabled },
+		New: func(ctx context.Context, cfg *config.Config) (aggregator.CostProvider, any, error) {
+			p, err := NewCostProvider(ctx, cfg.CSVImport)
+			if err != nil {
+				return nil, nil, err
+			}
+			return p, nil, nil
+		},
+	})
+}
+
+// NewCostProvider creates a new CSV invoice importer, checking every file's
+// header against its source's column mapping
+func NewCostProvider(ctx context.Context, cfg config.CSVImportConfig) (*CostProvider, error) {
+	if !cfg.Enabled {
+		return nil, fmt.Errorf("CSV import is disabled")
+	}
+	if len(cfg.Sources) == 0 {
+		return nil, fmt.Errorf("no CSV import sources configured")
+	}
+
+	p := &CostProvider{}
+	seen := make(map[string]bool)
+	for _, src := range cfg.Sources {
+		switch {
+		case src.Name == "":
+			return nil, fmt.Errorf("CSV import source %q has no name", src.Path)
+		case seen[src.Name]:
+			return nil, fmt.Errorf("duplicate CSV import source %q", src.Name)
+		case src.Path == "":
+			return nil, fmt.Errorf("CSV import source %s: path is not set", src.Name)
+		case src.Columns.Date == "" || src.Columns.Cost == "":
+			return nil, fmt.Errorf("CSV import source %s: date and cost columns are required", src.Name)
+		}
+		seen[src.Name] = true
+
+		if src.DateFormat == "" {
+			src.DateFormat = "2006-01-02"
+		}
+		if src.Account == "" {
+			src.Account = src.Name
+		}
+		if src.Service == "" {
+			src.Service = src.Name
+		}
+		if src.Currency == "" {
+			src.Currency = "USD"
+		}
+
+		files, err := listFiles(src.Path)
+		if err != nil {
+			return nil, fmt.Errorf("CSV import source %s: %w", src.Name, err)
+		}
+		for _, path := range files {
+			if err := checkHeader(path, src.Columns); err != nil {
+				return nil, fmt.Errorf("CSV import source %s: %w", src.Name, err)
+			}
+		}
+		p.sources = append(p.sources, source{CSVSource: src, files: files})
+	}
+	return p, nil
+}
+
+// Name returns the provider name
+func (p *CostProvider) Name() string {
+	return "csv-import"
+}
+
+// GetCosts reads every source's files and returns the charges within
+// [start, end). Entries carry the source name as their provider.
+func (p *CostProvider) GetCosts(ctx context.Context, start, end time.Time) ([]aggregator.CostEntry, error) {
+	entries := make([]aggregator.CostEntry, 0)
+
+	for _, src := range p.sources {
+		for _, path := range src.files {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+
+			fileEntries, err := readFile(path, src.CSVSource)
+			if err != nil {
+				return nil, err
+			}
+			for _, e := range fileEntries {
+				if e.Date.Before(start) || !e.Date.Before(end) {
+					continue
+				}
+				entries = append(entries, e)
+			}
+		}
+	}
+
+	return entries, nil
+}
+
+// GetBudgets returns nothing; invoices carry no budget data
+func (p *CostProvider) GetBudgets(ctx context.Context) ([]aggregator.BudgetStatus, error) {
+	return nil, nil
+}
+
+// readFile parses one invoice file with its source's mapping
+func readFile(path string, src config.CSVSource) ([]aggregator.CostEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to read header: %w", path, err)
+	}
+	index := headerIndex(header)
+	get := func(row []string, col string) string {
+		i, ok := index[col]
+		if col == "" || !ok || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+	orDefault := func(value, fallback string) string {
+		if value == "" {
+			return fallback
+		}
+		return value
+	}
+
+	var entries []aggregator.CostEntry
+	line := 1
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			return nil, fmt.Errorf("%s: line %d: %w", path, line, err)
+		}
+		if get(row, src.Columns.Date) == "" && get(row, src.Columns.Cost) == "" {
+			continue // blank or totals-only line
+		}
+
+		date, err := time.Parse(src.DateFormat, get(row, src.Columns.Date))
+		if err != nil {
+			return nil, fmt.Errorf("%s: line %d: invalid date: %w", path, line, err)
+		}
+		cost, err := parseAmount(get(row, src.Columns.Cost))
+		if err != nil {
+			return nil, fmt.Errorf("%s: line %d: invalid cost: %w", path, line, err)
+		}
+		var usage float64
+		if v := get(row, src.Columns.Usage); v != "" {
+			if usage, err = parseAmount(v); err != nil {
+				return nil, fmt.Errorf("%s: line %d: invalid usage: %w", path, line, err)
+			}
+		}
+
+		var tags map[string]string
+		for key, col := range src.Columns.Tags {
+			if v := get(row, col); v != "" {
+				if tags == nil {
+					tags = make(map[string]string)
+				}
+				tags[key] = v
+			}
+		}
+
+		entries = append(entries, aggregator.CostEntry{
+			Provider:    src.Name,
+			AccountID:   orDefault(get(row, src.Columns.Account), src.Account),
+			Service:     orDefault(get(row, src.Columns.Service), src.Service),
+			Region:      get(row, src.Columns.Region),
+			ResourceID:  get(row, src.Columns.Resource),
+			Date:        time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC),
+			Cost:        cost,
+			Currency:    strings.ToUpper(orDefault(get(row, src.Columns.Currency), src.Currency)),
+			Tags:        tags,
+			UsageAmount: usage,
+			UsageUnit:   get(row, src.Columns.Unit),
+
+			ChargeType:   string(normalizer.ParseChargeType(get(row, src.Columns.ChargeType))),
+			LineItemType: get(row, src.Columns.ChargeType),
+		})
+	}
+	return entries, nil
+}
+
+// parseAmount parses invoice amounts such as "1,234.50", "$12" or "(3.00)"
+func parseAmount(s string) (float64, error) {
+	negative := strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")")
+	s = strings.Trim(s, "()")
+	s = strings.NewReplacer(",", "", "$", "", "€", "", "£", "", " ", "").Replace(s)
+	if s == "" {
+		return 0, nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	if negative {
+		v = -v
+	}
+	return v, nil
+}
+
+// checkHeader verifies a file has every mapped column
+func checkHeader(path string, cols config.CSVColumns) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	header, err := csv.NewReader(f).Read()
+	if err != nil {
+		return fmt.Errorf("%s: failed to read header: %w", path, err)
+	}
+	index := headerIndex(header)
+
+	mapped := []string{cols.Date, cols.Cost, cols.Service, cols.Account, cols.Region,
+		cols.Resource, cols.Currency, cols.Usage, cols.Unit}
+	for _, col := range cols.Tags {
+		mapped = append(mapped, col)
+	}
+	var missing []string
+	for _, col := range mapped {
+		if _, ok := index[col]; col != "" && !ok {
+			missing = append(missing, col)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("%s: missing columns: %s", path, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func headerIndex(header []string) map[string]int {
+	index := make(map[string]int, len(header))
+	for i, col := range header {
+		if i == 0 {
+			col = strings.TrimPrefix(col, "\ufeff")
+		}
+		index[strings.TrimSpace(col)] = i
+	}
+	return index
+}
+
+// listFiles resolves a source path to the CSV files to import
+func listFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	dirEntries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var files []string
+	for _, e := range dirEntries {
+		if !e.IsDir() && strings.EqualFold(filepath.Ext(e.Name()), ".csv") {
+			files = append(files, filepath.Join(path, e.Name()))
+		}
+	}
+	sort.Strings(files)
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no CSV files found in %s", path)
+	}
+	return files, nil
+}
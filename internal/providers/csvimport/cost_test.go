@@ -0,0 +1,135 @@
+package csvimport
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+// writeFile writes a file under dir and returns its path
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestGetCosts(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "2024-01.csv", "\ufeffInvoice Date,Product,Amount,Team,Type\n"+
+		"01/15/2024,Infrastructure,\"1,234.50\",web,Usage\n"+
+		"01/20/2024,Logs,(10.00),,Credit\n"+
+		",,,,\n")
+	writeFile(t, dir, "2024-02.csv", "Invoice Date,Product,Amount,Team,Type\n"+
+		"02/01/2024,APM,$99,data,\n")
+	writeFile(t, dir, "README.txt", "not an invoice")
+
+	p, err := NewCostProvider(context.Background(), config.CSVImportConfig{Enabled: true, Sources: []config.CSVSource{{
+		Name:       "datadog",
+		Path:       dir,
+		DateFormat: "01/02/2006",
+		Account:    "acme-org",
+		Columns: config.CSVColumns{
+			Date: "Invoice Date", Cost: "Amount", Service: "Product", ChargeType: "Type",
+			Tags: map[string]string{"team": "Team"},
+		},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jan := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	entries, err := p.GetCosts(context.Background(), jan, jan.AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []aggregator.CostEntry{
+		{Provider: "datadog", AccountID: "acme-org", Service: "Infrastructure", Date: jan.AddDate(0, 0, 14), Cost: 1234.5,
+			Currency: "USD", Tags: map[string]string{"team": "web"}, ChargeType: "usage", LineItemType: "Usage"},
+		{Provider: "datadog", AccountID: "acme-org", Service: "Logs", Date: jan.AddDate(0, 0, 19), Cost: -10,
+			Currency: "USD", ChargeType: "credit", LineItemType: "Credit"},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("got\n%+v\nwant\n%+v", entries, want)
+	}
+
+	// February's invoice is outside January
+	entries, err = p.GetCosts(context.Background(), jan, jan.AddDate(0, 2, 0))
+	if err != nil || len(entries) != 3 || entries[2].Service != "APM" || entries[2].Cost != 99 {
+		t.Errorf("two months = %+v, %v; want February's APM charge last", entries, err)
+	}
+}
+
+func TestNewCostProviderValidates(t *testing.T) {
+	dir := t.TempDir()
+	invoice := writeFile(t, dir, "github.csv", "date,cost,sku\n2024-01-01,4,actions\n")
+	cols := config.CSVColumns{Date: "date", Cost: "cost"}
+
+	tests := []struct {
+		name    string
+		sources []config.CSVSource
+		wantErr string
+	}{
+		{"no sources", nil, "no CSV import sources configured"},
+		{"no name", []config.CSVSource{{Path: invoice, Columns: cols}}, "has no name"},
+		{"duplicate", []config.CSVSource{{Name: "gh", Path: invoice, Columns: cols}, {Name: "gh", Path: invoice, Columns: cols}}, `duplicate CSV import source "gh"`},
+		{"no path", []config.CSVSource{{Name: "gh", Columns: cols}}, "path is not set"},
+		{"no cost column", []config.CSVSource{{Name: "gh", Path: invoice, Columns: config.CSVColumns{Date: "date"}}}, "date and cost columns are required"},
+		{"missing columns", []config.CSVSource{{Name: "gh", Path: invoice, Columns: config.CSVColumns{
+			Date: "date", Cost: "cost", Service: "product", Tags: map[string]string{"team": "owner"}}}}, "missing columns: owner, product"},
+		{"empty directory", []config.CSVSource{{Name: "gh", Path: t.TempDir(), Columns: cols}}, "no CSV files found"},
+	}
+	for _, tt := range tests {
+		_, err := NewCostProvider(context.Background(), config.CSVImportConfig{Enabled: true, Sources: tt.sources})
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: err = %v, want %s", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestGetCostsBadRow(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "pd.csv", "date,cost\n2024-01-01,4\n2024-01-02,four\n")
+	p, err := NewCostProvider(context.Background(), config.CSVImportConfig{Enabled: true, Sources: []config.CSVSource{{
+		Name: "pagerduty", Path: dir, Columns: config.CSVColumns{Date: "date", Cost: "cost"},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = p.GetCosts(context.Background(), time.Time{}, time.Now())
+	if err == nil || !strings.Contains(err.Error(), "line 3: invalid cost") {
+		t.Errorf("err = %v, want an invalid cost on line 3", err)
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		in   string
+		want float64
+	}{
+		{"", 0},
+		{"12", 12},
+		{"1,234.50", 1234.5},
+		{"$12.25", 12.25},
+		{"€ 3", 3},
+		{"(3.00)", -3},
+		{"-4.5", -4.5},
+	}
+	for _, tt := range tests {
+		if got, err := parseAmount(tt.in); err != nil || got != tt.want {
+			t.Errorf("parseAmount(%q) = %v, %v; want %v", tt.in, got, err, tt.want)
+		}
+	}
+	if _, err := parseAmount("n/a"); err == nil {
+		t.Error("parseAmount(n/a) accepted")
+	}
+}
@@ -0,0 +1,245 @@
+// Package focus imports cost data from FOCUS-formatted files
+package focus
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+	"github.com/lvonguyen/finops-platform/internal/columnar"
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+	"github.com/lvonguyen/finops-platform/internal/providers"
+)
+
+// CostProvider implements aggregator.CostProvider for FOCUS 1.0 exports
+// produced by other tools
+type CostProvider struct {
+	config   config.FOCUSConfig
+	files    []string
+	unmapped []string
+}
+
+func init() {
+	providers.MustRegister(providers.Provider{
+		Name:    "focus",
+		Enabled: func(cfg *config.Config) bool { return cfg.FOCUS.Enabled },
+		New: func(ctx context.Context, cfg *config.Config) (aggregator.CostProvider, any, error) {
+			p, err := NewCostProvider(ctx, cfg.FOCUS)
+			if err != nil {
+				return nil, nil, err
+			}
+			if unmapped := p.UnmappedColumns(); len(unmapped) > 0 {
+				log.Printf("FOCUS import: %d columns not mapped: %s", len(unmapped), strings.Join(unmapped, ", "))
+			}
+			return p, nil, nil
+		},
+	})
+}
+
+// NewCostProvider creates a new FOCUS file importer
+func NewCostProvider(ctx context.Context, cfg config.FOCUSConfig) (*CostProvider, error) {
+	if !cfg.Enabled {
+		return nil, fmt.Errorf("FOCUS import is disabled")
+	}
+	if cfg.Path == "" {
+		return nil, fmt.Errorf("FOCUS import path is not set")
+	}
+
+	files, err := listFiles(cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate every file against the FOCUS schema up front
+	unmapped := make(map[string]bool)
+	for _, path := range files {
+		cols, err := readHeader(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, col := range cols {
+			unmapped[col] = true
+		}
+	}
+
+	p := &CostProvider{
+		config: cfg,
+		files:  files,
+	}
+	for col := range unmapped {
+		p.unmapped = append(p.unmapped, col)
+	}
+	sort.Strings(p.unmapped)
+
+	return p, nil
+}
+
+// Name returns the provider name
+func (p *CostProvider) Name() string {
+	return "focus"
+}
+
+// GetCosts reads every FOCUS file and returns the charges within [start, end)
+func (p *CostProvider) GetCosts(ctx context.Context, start, end time.Time) ([]aggregator.CostEntry, error) {
+	entries := make([]aggregator.CostEntry, 0)
+
+	for _, path := range p.files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		result, err := readFile(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, r := range result.Records {
+			if r.Date.Before(start) || !r.Date.Before(end) {
+				continue
+			}
+
+			entries = append(entries, aggregator.CostEntry{
+				Provider:    r.Cloud,
+				AccountID:   r.Account,
+				Service:     r.CloudService,
+				Region:      r.Region,
+				ResourceID:  r.Resource,
+				ChargeType:  string(r.ChargeType),
+				Date:        r.Date,
+				Cost:        r.Cost,
+				Currency:    r.Currency,
+				Tags:        r.Tags,
+				UsageType:   r.CloudServiceType,
+				UsageAmount: r.UsageQuantity,
+				UsageUnit:   r.UsageUnit,
+
+				EffectiveCost:              r.EffectiveCost,
+				CommitmentDiscountID:       r.CommitmentDiscountID,
+				CommitmentDiscountCategory: r.CommitmentDiscountCategory,
+				CommitmentDiscountType:     r.CommitmentDiscountType,
+				CommitmentDiscountName:     r.CommitmentDiscountName,
+				PricingModel:               r.PricingModel,
+
+				LineItemType: r.LineItemType,
+			})
+		}
+	}
+
+	return entries, nil
+}
+
+// UnmappedColumns returns the columns found in the import files that have
+// no CostRecord equivalent
+func (p *CostProvider) UnmappedColumns() []string {
+	return p.unmapped
+}
+
+// GetBudgets returns nothing; FOCUS files carry no budget data
+func (p *CostProvider) GetBudgets(ctx context.Context) ([]aggregator.BudgetStatus, error) {
+	return nil, nil
+}
+
+func readFile(ctx context.Context, path string) (*normalizer.FOCUSImport, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	var result *normalizer.FOCUSImport
+	if isParquet(path) {
+		result, err = normalizer.ReadFOCUSParquet(ctx, f)
+	} else {
+		result, err = normalizer.ReadFOCUSCSV(f)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return result, nil
+}
+
+// readHeader validates a file's header and returns its unmapped columns
+func readHeader(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	var header []string
+	if isParquet(path) {
+		header, err = columnar.Header(f)
+	} else {
+		header, err = csv.NewReader(f).Read()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to read header: %w", path, err)
+	}
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+
+	unmapped, err := normalizer.ValidateFOCUSHeader(header)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return unmapped, nil
+}
+
+// listFiles resolves the configured path to the FOCUS CSV and Parquet files
+// to import
+func listFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+
+	if !info.IsDir() {
+		if err := checkFormat(path); err != nil {
+			return nil, err
+		}
+		return []string{path}, nil
+	}
+
+	dirEntries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var files []string
+	for _, e := range dirEntries {
+		if e.IsDir() {
+			continue
+		}
+		name := filepath.Join(path, e.Name())
+		if strings.EqualFold(filepath.Ext(name), ".csv") || isParquet(name) {
+			files = append(files, name)
+		}
+	}
+	sort.Strings(files)
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no FOCUS CSV or Parquet files found in %s", path)
+	}
+	return files, nil
+}
+
+func checkFormat(path string) error {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv", ".parquet":
+		return nil
+	}
+	return fmt.Errorf("%s: unsupported FOCUS file type", path)
+}
+
+func isParquet(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".parquet")
+}
@@ -0,0 +1,38 @@
+package focus
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestListFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"b.parquet", "a.csv", "c.PARQUET", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "old.csv"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := listFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range files {
+		files[i] = filepath.Base(files[i])
+	}
+	if got := strings.Join(files, " "); got != "a.csv b.parquet c.PARQUET" {
+		t.Errorf("listFiles = %s, want a.csv b.parquet c.PARQUET", got)
+	}
+
+	if _, err := listFiles(filepath.Join(dir, "b.parquet")); err != nil {
+		t.Errorf("a single Parquet file: %v", err)
+	}
+	if _, err := listFiles(filepath.Join(dir, "notes.txt")); err == nil {
+		t.Error("a text file was accepted")
+	}
+}
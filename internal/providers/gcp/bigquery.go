@@ -0,0 +1,280 @@
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	bigquery "google.golang.org/api/bigquery/v2"
+	"google.golang.org/api/option"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+	"github.com/lvonguyen/finops-platform/internal/telemetry"
+)
+
+// Defaults for the billing export partitioning
+const (
+	defaultPartitionColumn = "_PARTITIONTIME"
+	defaultPartitionLag    = 3
+)
+
+var (
+	identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	tablePattern      = regexp.MustCompile("^[A-Za-z0-9_.:-]+$")
+)
+
+// newBigQueryService builds a BigQuery client from ADC or WIF config
+func newBigQueryService(ctx context.Context, cfg config.GCPConfig) (*bigquery.Service, error) {
+	var opts []option.ClientOption
+	if cfg.WIFConfigPath != "" {
+		opts = append(opts, option.WithCredentialsFile(cfg.WIFConfigPath))
+	}
+
+	svc, err := bigquery.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create BigQuery client: %w", err)
+	}
+	return svc, nil
+}
+
+// billingQuery builds the cost query for [start, end). The usage_start_time
+// filter defines the result exactly; the partition predicate only bounds
+// the scan. Partitions are UTC days of export time, and rows are exported
+// after usage, so the scan runs from the day before start (for exports
+// partitioned on a non-UTC day boundary) to PartitionLagDays after end to
+// pick up late-arriving rows. filter holds extra conditions from filterClause.
+// Rows are totaled by cost type, with credits as rows of their own.
+func billingQuery(cfg config.GCPConfig, filter string) (string, error) {
+	if !tablePattern.MatchString(cfg.BillingTable) {
+		return "", fmt.Errorf("invalid billing_table %q", cfg.BillingTable)
+	}
+
+	column := cfg.PartitionColumn
+	if column == "" {
+		column = defaultPartitionColumn
+	}
+	if !identifierPattern.MatchString(column) {
+		return "", fmt.Errorf("invalid partition_column %q", column)
+	}
+
+	// _PARTITIONDATE is a DATE; _PARTITIONTIME and export_time are TIMESTAMPs
+	partition := fmt.Sprintf("%[1]s >= TIMESTAMP_SUB(@start, INTERVAL 1 DAY) AND %[1]s < TIMESTAMP_ADD(@end, INTERVAL @lag DAY)", column)
+	if column == "_PARTITIONDATE" {
+		partition = fmt.Sprintf("%[1]s >= DATE_SUB(DATE(@start), INTERVAL 1 DAY) AND %[1]s < DATE_ADD(DATE(@end), INTERVAL @lag DAY)", column)
+	}
+
+	// Credits are nested in each row; they are unnested into rows of their
+	// own so that they are reported apart from the charges they offset
+	where := fmt.Sprintf(`WHERE %s
+    AND usage_start_time >= @start
+    AND usage_start_time < @end%s`, partition, strings.ReplaceAll(filter, "\n  ", "\n    "))
+	return fmt.Sprintf(`SELECT service, project_id, region, usage_date, charge_type, line_item_type,
+  SUM(cost) AS cost,
+  ANY_VALUE(currency) AS currency
+FROM (
+  SELECT
+    service.description AS service,
+    IFNULL(project.id, '') AS project_id,
+    IFNULL(location.region, '') AS region,
+    FORMAT_DATE('%%Y-%%m-%%d', DATE(usage_start_time)) AS usage_date,
+    IFNULL(cost_type, '') AS charge_type,
+    IFNULL(cost_type, '') AS line_item_type,
+    cost,
+    currency
+  FROM `+"`%[1]s`"+`
+  %[2]s
+  UNION ALL
+  SELECT
+    service.description,
+    IFNULL(project.id, ''),
+    IFNULL(location.region, ''),
+    FORMAT_DATE('%%Y-%%m-%%d', DATE(usage_start_time)),
+    'credit',
+    IFNULL(c.type, ''),
+    c.amount,
+    currency
+  FROM `+"`%[1]s`"+`, UNNEST(credits) AS c
+  %[2]s
+)
+GROUP BY 1, 2, 3, 4, 5, 6`, cfg.BillingTable, where), nil
+}
+
+// filterClause converts a cost filter to extra WHERE conditions and their
+// parameters. Values are always passed as parameters, never inlined.
+func filterClause(f aggregator.CostFilter) (string, []*bigquery.QueryParameter) {
+	var clause strings.Builder
+	var params []*bigquery.QueryParameter
+
+	add := func(column, param string, values []string) {
+		if len(values) == 0 {
+			return
+		}
+		fmt.Fprintf(&clause, "\n  AND %s IN UNNEST(@%s)", column, param)
+		params = append(params, stringArrayParam(param, values))
+	}
+	add("IFNULL(project.id, '')", "accounts", f.Accounts)
+	add("service.description", "services", f.Services)
+	add("IFNULL(location.region, '')", "regions", f.Regions)
+
+	for i, key := range f.TagKeys() {
+		keyParam, valuesParam := fmt.Sprintf("tag_key_%d", i), fmt.Sprintf("tag_values_%d", i)
+		fmt.Fprintf(&clause, "\n  AND EXISTS (SELECT 1 FROM UNNEST(labels) AS l WHERE l.key = @%s AND l.value IN UNNEST(@%s))", keyParam, valuesParam)
+		params = append(params,
+			&bigquery.QueryParameter{
+				Name:           keyParam,
+				ParameterType:  &bigquery.QueryParameterType{Type: "STRING"},
+				ParameterValue: &bigquery.QueryParameterValue{Value: key},
+			},
+			stringArrayParam(valuesParam, f.Tags[key]),
+		)
+	}
+	return clause.String(), params
+}
+
+func stringArrayParam(name string, values []string) *bigquery.QueryParameter {
+	items := make([]*bigquery.QueryParameterValue, len(values))
+	for i, v := range values {
+		items[i] = &bigquery.QueryParameterValue{Value: v}
+	}
+	return &bigquery.QueryParameter{
+		Name: name,
+		ParameterType: &bigquery.QueryParameterType{
+			Type:      "ARRAY",
+			ArrayType: &bigquery.QueryParameterType{Type: "STRING"},
+		},
+		ParameterValue: &bigquery.QueryParameterValue{ArrayValues: items},
+	}
+}
+
+// queryBillingExport runs the billing query and converts the rows
+func (p *CostProvider) queryBillingExport(ctx context.Context, start, end time.Time, filter aggregator.CostFilter) ([]aggregator.CostEntry, error) {
+	where, filterParams := filterClause(filter)
+	query, err := billingQuery(p.config, where)
+	if err != nil {
+		return nil, err
+	}
+
+	lag := p.config.PartitionLagDays
+	if lag == 0 {
+		lag = defaultPartitionLag
+	}
+
+	useLegacySQL := false
+	req := &bigquery.QueryRequest{
+		Query:         query,
+		UseLegacySql:  &useLegacySQL,
+		ParameterMode: "NAMED",
+		QueryParameters: []*bigquery.QueryParameter{
+			timestampParam("start", start),
+			timestampParam("end", end),
+			{
+				Name:           "lag",
+				ParameterType:  &bigquery.QueryParameterType{Type: "INT64"},
+				ParameterValue: &bigquery.QueryParameterValue{Value: strconv.Itoa(lag)},
+			},
+		},
+	}
+	req.QueryParameters = append(req.QueryParameters, filterParams...)
+
+	callCtx, span := telemetry.StartCall(ctx, "gcp.bigquery.Query", 1)
+	var resp *bigquery.QueryResponse
+	err = p.calls.Do(callCtx, func(ctx context.Context) error {
+		var err error
+		resp, err = p.bigquery.Jobs.Query(p.config.ProjectID, req).Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		telemetry.End(span, 0, err)
+		return nil, fmt.Errorf("failed to query billing export: %w", classifyError(err))
+	}
+	telemetry.End(span, len(resp.Rows), nil)
+
+	entries := make([]aggregator.CostEntry, 0)
+	rows, complete, pageToken := resp.Rows, resp.JobComplete, resp.PageToken
+	jobID, location := resp.JobReference.JobId, resp.JobReference.Location
+
+	for pageNum := 1; ; pageNum++ {
+		for _, row := range rows {
+			entry, err := rowToEntry(row)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry)
+		}
+
+		if complete && pageToken == "" {
+			break
+		}
+
+		callCtx, span := telemetry.StartCall(ctx, "gcp.bigquery.GetQueryResults", pageNum+1)
+		var page *bigquery.GetQueryResultsResponse
+		err := p.calls.Do(callCtx, func(ctx context.Context) error {
+			call := p.bigquery.Jobs.GetQueryResults(p.config.ProjectID, jobID).Location(location).Context(ctx)
+			if pageToken != "" {
+				call = call.PageToken(pageToken)
+			}
+			var err error
+			page, err = call.Do()
+			return err
+		})
+		if err != nil {
+			telemetry.End(span, 0, err)
+			return nil, fmt.Errorf("failed to read billing export results: %w", classifyError(err))
+		}
+		telemetry.End(span, len(page.Rows), nil)
+		rows, complete, pageToken = page.Rows, page.JobComplete, page.PageToken
+	}
+
+	if p.config.ResolveHierarchy {
+		p.tagHierarchy(ctx, entries)
+	}
+	return entries, nil
+}
+
+func timestampParam(name string, t time.Time) *bigquery.QueryParameter {
+	return &bigquery.QueryParameter{
+		Name:           name,
+		ParameterType:  &bigquery.QueryParameterType{Type: "TIMESTAMP"},
+		ParameterValue: &bigquery.QueryParameterValue{Value: t.UTC().Format("2006-01-02 15:04:05")},
+	}
+}
+
+// rowToEntry converts a result row in billingQuery's column order
+func rowToEntry(row *bigquery.TableRow) (aggregator.CostEntry, error) {
+	if len(row.F) != 8 {
+		return aggregator.CostEntry{}, fmt.Errorf("unexpected billing export row with %d columns", len(row.F))
+	}
+	cell := func(i int) string {
+		if s, ok := row.F[i].V.(string); ok {
+			return s
+		}
+		return ""
+	}
+
+	date, err := time.Parse("2006-01-02", cell(3))
+	if err != nil {
+		return aggregator.CostEntry{}, fmt.Errorf("invalid usage date %q: %w", cell(3), err)
+	}
+	cost, err := strconv.ParseFloat(cell(6), 64)
+	if err != nil {
+		return aggregator.CostEntry{}, fmt.Errorf("invalid cost %q: %w", cell(6), err)
+	}
+
+	return aggregator.CostEntry{
+		Provider:   "gcp",
+		AccountID:  cell(1),
+		Service:    cell(0),
+		Region:     cell(2),
+		Date:       date,
+		Cost:       cost,
+		ChargeType: string(normalizer.ParseChargeType(cell(4))),
+		Currency:   cell(7),
+
+		LineItemType: cell(5),
+	}, nil
+}
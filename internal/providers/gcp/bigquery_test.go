@@ -0,0 +1,211 @@
+package gcp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	bigquery "google.golang.org/api/bigquery/v2"
+	"google.golang.org/api/option"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/resilience"
+)
+
+func TestBillingQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.GCPConfig
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "partition time",
+			cfg:  config.GCPConfig{BillingTable: "proj.billing.gcp_billing_export_v1"},
+			want: []string{
+				"FROM `proj.billing.gcp_billing_export_v1`",
+				"_PARTITIONTIME >= TIMESTAMP_SUB(@start, INTERVAL 1 DAY) AND _PARTITIONTIME < TIMESTAMP_ADD(@end, INTERVAL @lag DAY)",
+				"usage_start_time >= @start",
+				"UNNEST(credits) AS c",
+			},
+		},
+		{
+			name: "partition date",
+			cfg:  config.GCPConfig{BillingTable: "proj.billing.export", PartitionColumn: "_PARTITIONDATE"},
+			want: []string{"_PARTITIONDATE >= DATE_SUB(DATE(@start), INTERVAL 1 DAY) AND _PARTITIONDATE < DATE_ADD(DATE(@end), INTERVAL @lag DAY)"},
+		},
+		{
+			name: "export time",
+			cfg:  config.GCPConfig{BillingTable: "proj.billing.export", PartitionColumn: "export_time"},
+			want: []string{"export_time >= TIMESTAMP_SUB(@start, INTERVAL 1 DAY)"},
+		},
+		{name: "bad table", cfg: config.GCPConfig{BillingTable: "proj.billing.export`; DROP"}, wantErr: "invalid billing_table"},
+		{name: "bad column", cfg: config.GCPConfig{BillingTable: "proj.billing.export", PartitionColumn: "1=1 OR x"}, wantErr: "invalid partition_column"},
+	}
+	for _, tt := range tests {
+		query, err := billingQuery(tt.cfg, "")
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: err = %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(query, want) {
+				t.Errorf("%s: query lacks %q:\n%s", tt.name, want, query)
+			}
+		}
+	}
+}
+
+// row builds a result row in billingQuery's column order
+func row(cells ...string) *bigquery.TableRow {
+	r := &bigquery.TableRow{}
+	for _, c := range cells {
+		r.F = append(r.F, &bigquery.TableCell{V: c})
+	}
+	return r
+}
+
+// TestQueryBillingExportPages runs the query against a fake BigQuery API
+// whose results arrive over two pages
+func TestQueryBillingExportPages(t *testing.T) {
+	var query *bigquery.QueryRequest
+	var pageTokens []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var resp any
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/projects/billing-proj/queries":
+			if err := json.NewDecoder(r.Body).Decode(&query); err != nil {
+				t.Error(err)
+			}
+			resp = &bigquery.QueryResponse{
+				JobComplete:  true,
+				JobReference: &bigquery.JobReference{JobId: "job-1", Location: "US"},
+				PageToken:    "page-2",
+				Rows:         []*bigquery.TableRow{row("Compute Engine", "proj-a", "us-central1", "2024-03-01", "regular", "regular", "12.5", "USD")},
+			}
+		case r.Method == http.MethodGet && r.URL.Path == "/projects/billing-proj/queries/job-1":
+			pageTokens = append(pageTokens, r.URL.Query().Get("pageToken"))
+			resp = &bigquery.GetQueryResultsResponse{
+				JobComplete: true,
+				Rows:        []*bigquery.TableRow{row("Compute Engine", "proj-a", "us-central1", "2024-03-01", "credit", "SUSTAINED_USAGE_DISCOUNT", "-2.5", "USD")},
+			}
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer srv.Close()
+
+	svc, err := bigquery.NewService(context.Background(), option.WithEndpoint(srv.URL+"/"), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls, err := resilience.New("gcp-test", config.CallConfig{}, retryable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &CostProvider{
+		bigquery: svc,
+		config:   config.GCPConfig{ProjectID: "billing-proj", BillingTable: "proj.billing.export"},
+		calls:    calls,
+	}
+
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	entries, err := p.GetFilteredCosts(context.Background(), start, start.AddDate(0, 1, 0), aggregator.CostFilter{Accounts: []string{"proj-a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pageTokens) != 1 || pageTokens[0] != "page-2" {
+		t.Errorf("page tokens = %v, want [page-2]", pageTokens)
+	}
+	params := map[string]*bigquery.QueryParameter{}
+	for _, param := range query.QueryParameters {
+		params[param.Name] = param
+	}
+	if params["start"].ParameterValue.Value != "2024-03-01 00:00:00" || params["lag"].ParameterValue.Value != "3" {
+		t.Errorf("start = %s, lag = %s; want 2024-03-01 00:00:00 and 3", params["start"].ParameterValue.Value, params["lag"].ParameterValue.Value)
+	}
+	if a := params["accounts"]; a == nil || len(a.ParameterValue.ArrayValues) != 1 || !strings.Contains(query.Query, "IN UNNEST(@accounts)") {
+		t.Errorf("accounts filter not passed as a parameter: %+v", a)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	if e := entries[0]; e.AccountID != "proj-a" || e.Service != "Compute Engine" || e.Cost != 12.5 || e.ChargeType != "usage" || !e.Date.Equal(start) {
+		t.Errorf("first entry = %+v", e)
+	}
+	if e := entries[1]; e.Cost != -2.5 || e.ChargeType != "credit" || e.LineItemType != "SUSTAINED_USAGE_DISCOUNT" {
+		t.Errorf("credit entry = %+v", e)
+	}
+}
+
+func TestRowToEntryErrors(t *testing.T) {
+	for _, r := range []*bigquery.TableRow{
+		row("Compute Engine", "proj-a"),
+		row("Compute Engine", "proj-a", "us-central1", "03/01/2024", "regular", "regular", "1", "USD"),
+		row("Compute Engine", "proj-a", "us-central1", "2024-03-01", "regular", "regular", "n/a", "USD"),
+	} {
+		if _, err := rowToEntry(r); err == nil {
+			t.Errorf("row %v accepted", r.F)
+		}
+	}
+}
+
+func TestFilterClause(t *testing.T) {
+	if clause, params := filterClause(aggregator.CostFilter{}); clause != "" || params != nil {
+		t.Errorf("empty filter = %q, %v, want nothing", clause, params)
+	}
+
+	clause, params := filterClause(aggregator.CostFilter{
+		Services: []string{"Compute Engine", "BigQuery"},
+		Tags:     map[string][]string{"team": {"web"}, "env": {"prod'; --"}},
+	})
+	for _, want := range []string{
+		"AND service.description IN UNNEST(@services)",
+		"l.key = @tag_key_0 AND l.value IN UNNEST(@tag_values_0)",
+		"l.key = @tag_key_1 AND l.value IN UNNEST(@tag_values_1)",
+	} {
+		if !strings.Contains(clause, want) {
+			t.Errorf("clause lacks %q:\n%s", want, clause)
+		}
+	}
+	if strings.Contains(clause, "prod") || strings.Contains(clause, "Compute") {
+		t.Errorf("clause inlines filter values:\n%s", clause)
+	}
+
+	values := map[string][]string{}
+	for _, p := range params {
+		if p.ParameterValue.Value != "" {
+			values[p.Name] = []string{p.ParameterValue.Value}
+			continue
+		}
+		for _, v := range p.ParameterValue.ArrayValues {
+			values[p.Name] = append(values[p.Name], v.Value)
+		}
+	}
+	want := map[string][]string{
+		"services":     {"Compute Engine", "BigQuery"},
+		"tag_key_0":    {"env"},
+		"tag_values_0": {"prod'; --"},
+		"tag_key_1":    {"team"},
+		"tag_values_1": {"web"},
+	}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("parameters = %v, want %v", values, want)
+	}
+}
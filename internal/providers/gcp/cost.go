@@ -3,22 +3,56 @@ package gcp
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"sync"
 	"time"
 
 	billing "cloud.google.com/go/billing/budgets/apiv1"
 	"cloud.google.com/go/billing/budgets/apiv1/budgetspb"
+	bigquery "google.golang.org/api/bigquery/v2"
+	cloudresourcemanager "google.golang.org/api/cloudresourcemanager/v3"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
+	"google.golang.org/api/transport"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"github.com/lvonguyen/finops-platform/internal/aggregator"
 	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/providers"
+	"github.com/lvonguyen/finops-platform/internal/resilience"
 )
 
+// cloudPlatformScope is the OAuth scope the Cloud Billing and BigQuery APIs accept
+const cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+
 // CostProvider implements aggregator.CostProvider for GCP
 type CostProvider struct {
 	budgetClient *billing.BudgetClient
+	bigquery     *bigquery.Service
 	config       config.GCPConfig
+	calls        *resilience.Caller
+
+	resourceManager *cloudresourcemanager.Service // nil unless resolve_hierarchy
+	mu              sync.Mutex
+	projects        map[string]ancestry // project ID -> ancestry, looked up once
+	nodes           map[string]node     // folder or organization resource name -> node
+}
+
+func init() {
+	providers.MustRegister(providers.Provider{
+		Name: "gcp",
+		New: func(ctx context.Context, cfg *config.Config) (aggregator.CostProvider, any, error) {
+			p, err := NewCostProvider(ctx, cfg.GCP)
+			if err != nil {
+				return nil, nil, err
+			}
+			return p, cfg.GCP, nil
+		},
+	})
 }
 
 // NewCostProvider creates a new GCP cost provider
@@ -27,6 +61,42 @@ func NewCostProvider(ctx context.Context, cfg config.GCPConfig) (*CostProvider,
 		return nil, fmt.Errorf("GCP provider is disabled")
 	}
 
+	budgetClient, err := newBudgetClient(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	bq, err := newBigQueryService(ctx, cfg)
+	if err != nil {
+		budgetClient.Close()
+		return nil, err
+	}
+	calls, err := resilience.New("gcp", cfg.Calls, retryable)
+	if err != nil {
+		budgetClient.Close()
+		return nil, err
+	}
+	var rm *cloudresourcemanager.Service
+	if cfg.ResolveHierarchy {
+		if rm, err = newResourceManager(ctx, cfg); err != nil {
+			budgetClient.Close()
+			return nil, err
+		}
+	}
+
+	return &CostProvider{
+		budgetClient:    budgetClient,
+		bigquery:        bq,
+		config:          cfg,
+		calls:           calls,
+		resourceManager: rm,
+		projects:        make(map[string]ancestry),
+		nodes:           make(map[string]node),
+	}, nil
+}
+
+// newBudgetClient builds a Billing Budgets client from ADC or WIF config
+func newBudgetClient(ctx context.Context, cfg config.GCPConfig) (*billing.BudgetClient, error) {
 	var opts []option.ClientOption
 
 	// Use Workload Identity Federation if configured
@@ -39,10 +109,83 @@ func NewCostProvider(ctx context.Context, cfg config.GCPConfig) (*CostProvider,
 		return nil, fmt.Errorf("failed to create budget client: %w", err)
 	}
 
-	return &CostProvider{
-		budgetClient: budgetClient,
-		config:       cfg,
-	}, nil
+	return budgetClient, nil
+}
+
+// CheckCredentials finds Application Default Credentials, or the WIF
+// config when set, and obtains a token from them. It returns the project
+// the credentials belong to, when known.
+func CheckCredentials(ctx context.Context, cfg config.GCPConfig) (string, error) {
+	opts := []option.ClientOption{option.WithScopes(cloudPlatformScope)}
+	if cfg.WIFConfigPath != "" {
+		opts = append(opts, option.WithCredentialsFile(cfg.WIFConfigPath))
+	}
+	creds, err := transport.Creds(ctx, opts...)
+	if err != nil {
+		return "", fmt.Errorf("failed to find credentials: %w", err)
+	}
+	if _, err := creds.TokenSource.Token(); err != nil {
+		return "", fmt.Errorf("failed to obtain token: %w", err)
+	}
+	return creds.ProjectID, nil
+}
+
+// RefreshCredentials recreates the clients so a fresh token is obtained
+func (p *CostProvider) RefreshCredentials(ctx context.Context) error {
+	budgetClient, err := newBudgetClient(ctx, p.config)
+	if err != nil {
+		return err
+	}
+	bq, err := newBigQueryService(ctx, p.config)
+	if err != nil {
+		budgetClient.Close()
+		return err
+	}
+	if p.config.ResolveHierarchy {
+		rm, err := newResourceManager(ctx, p.config)
+		if err != nil {
+			budgetClient.Close()
+			return err
+		}
+		p.resourceManager = rm
+	}
+	p.budgetClient.Close()
+	p.budgetClient = budgetClient
+	p.bigquery = bq
+	return nil
+}
+
+// classifyError marks credential failures with aggregator.ErrAuthExpired
+func classifyError(err error) error {
+	switch status.Code(err) {
+	case codes.Unauthenticated, codes.PermissionDenied:
+		return fmt.Errorf("%w: %w", aggregator.ErrAuthExpired, err)
+	}
+
+	// BigQuery is called over REST rather than gRPC
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && (apiErr.Code == http.StatusUnauthorized || apiErr.Code == http.StatusForbidden) {
+		return fmt.Errorf("%w: %w", aggregator.ErrAuthExpired, err)
+	}
+	return err
+}
+
+// retryable reports BigQuery rate limiting and backend errors. Rate limits
+// come back as 403 with reason rateLimitExceeded, unlike permission errors.
+func retryable(err error) bool {
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	if apiErr.Code == http.StatusTooManyRequests || apiErr.Code >= 500 {
+		return true
+	}
+	for _, item := range apiErr.Errors {
+		if item.Reason == "rateLimitExceeded" || item.Reason == "backendError" {
+			return true
+		}
+	}
+	return false
 }
 
 // Name returns the provider name
@@ -50,35 +193,21 @@ func (p *CostProvider) Name() string {
 	return "gcp"
 }
 
-// GetCosts retrieves costs from GCP
-// Note: GCP doesn't have a direct cost API like AWS/Azure.
-// Typically you export billing to BigQuery and query that.
-// This implementation uses the Billing Budgets API for budget info.
+// GetCosts retrieves costs from the BigQuery billing export
 func (p *CostProvider) GetCosts(ctx context.Context, start, end time.Time) ([]aggregator.CostEntry, error) {
-	// GCP cost data is typically accessed via:
-	// 1. BigQuery billing export (recommended)
-	// 2. Cloud Billing API (limited)
-	//
-	// For a complete implementation, you would:
-	// 1. Set up billing export to BigQuery
-	// 2. Query BigQuery for cost data
-	//
-	// This is a stub that would be replaced with BigQuery queries
-
-	entries := make([]aggregator.CostEntry, 0)
-
-	// Example BigQuery query that would be used:
-	// SELECT
-	//   service.description as service,
-	//   project.id as project_id,
-	//   location.region as region,
-	//   DATE(usage_start_time) as date,
-	//   SUM(cost) as cost
-	// FROM `project.dataset.gcp_billing_export_v1_*`
-	// WHERE DATE(usage_start_time) BETWEEN @start AND @end
-	// GROUP BY 1, 2, 3, 4
-
-	return entries, nil
+	if p.config.BillingTable == "" {
+		return nil, fmt.Errorf("GCP billing_table is not set; costs are read from the BigQuery billing export")
+	}
+	return p.queryBillingExport(ctx, start, end, aggregator.CostFilter{})
+}
+
+// GetFilteredCosts retrieves costs matching filter, applied in the billing
+// export query's WHERE clause
+func (p *CostProvider) GetFilteredCosts(ctx context.Context, start, end time.Time, filter aggregator.CostFilter) ([]aggregator.CostEntry, error) {
+	if p.config.BillingTable == "" {
+		return nil, fmt.Errorf("GCP billing_table is not set; costs are read from the BigQuery billing export")
+	}
+	return p.queryBillingExport(ctx, start, end, filter)
 }
 
 // GetBudgets retrieves budget status from GCP
@@ -98,7 +227,7 @@ func (p *CostProvider) GetBudgets(ctx context.Context) ([]aggregator.BudgetStatu
 			break
 		}
 		if err != nil {
-			return nil, fmt.Errorf("failed to list budgets: %w", err)
+			return nil, fmt.Errorf("failed to list budgets: %w", classifyError(err))
 		}
 
 		var limit float64
@@ -120,4 +249,3 @@ func (p *CostProvider) GetBudgets(ctx context.Context) ([]aggregator.BudgetStatu
 func (p *CostProvider) Close() error {
 	return p.budgetClient.Close()
 }
-
@@ -0,0 +1,80 @@
+package gcp
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+// writeServiceAccount writes a service account key file whose tokens are
+// issued by tokenURL
+func writeServiceAccount(t *testing.T, tokenURL string) string {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"project_id":     "billing-proj",
+		"private_key_id": "key-1",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"client_email":   "finops@billing-proj.iam.gserviceaccount.com",
+		"token_uri":      tokenURL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCheckCredentials(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" || r.Form.Get("assertion") == "" {
+			t.Errorf("token request = %v, want a signed JWT grant", r.Form)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"token","token_type":"Bearer","expires_in":3600}`))
+	}))
+	defer srv.Close()
+
+	project, err := CheckCredentials(context.Background(), config.GCPConfig{WIFConfigPath: writeServiceAccount(t, srv.URL)})
+	if err != nil || project != "billing-proj" {
+		t.Errorf("CheckCredentials() = %q, %v; want billing-proj", project, err)
+	}
+
+	// A token endpoint refusing the key
+	refused := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"invalid_grant","error_description":"Invalid JWT Signature."}`))
+	}))
+	defer refused.Close()
+	if _, err := CheckCredentials(context.Background(), config.GCPConfig{WIFConfigPath: writeServiceAccount(t, refused.URL)}); err == nil || !strings.Contains(err.Error(), "failed to obtain token") {
+		t.Errorf("CheckCredentials(refused) = %v, want a token error", err)
+	}
+
+	if _, err := CheckCredentials(context.Background(), config.GCPConfig{WIFConfigPath: filepath.Join(t.TempDir(), "missing.json")}); err == nil || !strings.Contains(err.Error(), "failed to find credentials") {
+		t.Errorf("CheckCredentials(missing) = %v, want a credentials error", err)
+	}
+}
@@ -0,0 +1,163 @@
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	cloudresourcemanager "google.golang.org/api/cloudresourcemanager/v3"
+	"google.golang.org/api/option"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/telemetry"
+)
+
+// Tags carrying a project's place in the resource hierarchy, set with
+// resolve_hierarchy
+const (
+	FolderTag       = "gcp:folder"       // the folder directly above the project
+	FolderPathTag   = "gcp:folder-path"  // folders from the top down, joined with "/"
+	OrganizationTag = "gcp:organization" // the organization's display name
+)
+
+// ancestry is a project's folders, top first, and organization
+type ancestry struct {
+	folders      []string
+	organization string
+	resolved     bool
+}
+
+// node is a folder or organization
+type node struct {
+	displayName string
+	parent      string // resource name, empty for an organization
+}
+
+// newResourceManager builds a Cloud Resource Manager client from ADC or WIF config
+func newResourceManager(ctx context.Context, cfg config.GCPConfig) (*cloudresourcemanager.Service, error) {
+	var opts []option.ClientOption
+	if cfg.WIFConfigPath != "" {
+		opts = append(opts, option.WithCredentialsFile(cfg.WIFConfigPath))
+	}
+
+	svc, err := cloudresourcemanager.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create resource manager client: %w", err)
+	}
+	return svc, nil
+}
+
+// tagHierarchy tags entries with their project's folders and organization.
+// Each project and folder is looked up once per provider. Projects that
+// cannot be resolved, such as deleted ones, are logged once and left untagged.
+func (p *CostProvider) tagHierarchy(ctx context.Context, entries []aggregator.CostEntry) {
+	for i := range entries {
+		a := p.ancestry(ctx, entries[i].AccountID)
+		if !a.resolved {
+			continue
+		}
+		tags := make(map[string]string, len(entries[i].Tags)+3)
+		for k, v := range entries[i].Tags {
+			tags[k] = v
+		}
+		if len(a.folders) > 0 {
+			tags[FolderTag] = a.folders[len(a.folders)-1]
+			tags[FolderPathTag] = strings.Join(a.folders, "/")
+		}
+		if a.organization != "" {
+			tags[OrganizationTag] = a.organization
+		}
+		entries[i].Tags = tags
+	}
+}
+
+// ancestry returns a project's ancestry, resolving it on first use
+func (p *CostProvider) ancestry(ctx context.Context, project string) ancestry {
+	if project == "" {
+		return ancestry{}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if a, ok := p.projects[project]; ok {
+		return a
+	}
+
+	a, err := p.resolve(ctx, project)
+	if err != nil {
+		log.Printf("Warning: Failed to resolve GCP hierarchy of project %s: %v", project, err)
+	}
+	p.projects[project] = a
+	return a
+}
+
+// resolve walks up from a project through its folders to the organization
+func (p *CostProvider) resolve(ctx context.Context, project string) (ancestry, error) {
+	callCtx, span := telemetry.StartCall(ctx, "gcp.resourcemanager.GetProject", 1)
+	var proj *cloudresourcemanager.Project
+	err := p.calls.Do(callCtx, func(ctx context.Context) error {
+		var err error
+		proj, err = p.resourceManager.Projects.Get("projects/" + project).Context(ctx).Do()
+		return err
+	})
+	telemetry.End(span, 1, err)
+	if err != nil {
+		return ancestry{}, classifyError(err)
+	}
+
+	var a ancestry
+	for parent := proj.Parent; parent != ""; {
+		n, err := p.node(ctx, parent)
+		if err != nil {
+			return ancestry{}, err
+		}
+		if strings.HasPrefix(parent, "organizations/") {
+			a.organization = n.displayName
+		} else {
+			a.folders = append([]string{n.displayName}, a.folders...)
+		}
+		parent = n.parent
+	}
+	a.resolved = true
+	return a, nil
+}
+
+// node looks up a folder or organization by resource name, once
+func (p *CostProvider) node(ctx context.Context, name string) (node, error) {
+	if n, ok := p.nodes[name]; ok {
+		return n, nil
+	}
+
+	var n node
+	var err error
+	if strings.HasPrefix(name, "organizations/") {
+		callCtx, span := telemetry.StartCall(ctx, "gcp.resourcemanager.GetOrganization", 1)
+		err = p.calls.Do(callCtx, func(ctx context.Context) error {
+			org, err := p.resourceManager.Organizations.Get(name).Context(ctx).Do()
+			if err == nil {
+				n = node{displayName: org.DisplayName}
+			}
+			return err
+		})
+		telemetry.End(span, 1, err)
+	} else {
+		callCtx, span := telemetry.StartCall(ctx, "gcp.resourcemanager.GetFolder", 1)
+		err = p.calls.Do(callCtx, func(ctx context.Context) error {
+			folder, err := p.resourceManager.Folders.Get(name).Context(ctx).Do()
+			if err == nil {
+				n = node{displayName: folder.DisplayName, parent: folder.Parent}
+			}
+			return err
+		})
+		telemetry.End(span, 1, err)
+	}
+	if err != nil {
+		return node{}, fmt.Errorf("failed to get %s: %w", name, classifyError(err))
+	}
+	if n.displayName == "" {
+		n.displayName = name
+	}
+	p.nodes[name] = n
+	return n, nil
+}
@@ -0,0 +1,92 @@
+package gcp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	cloudresourcemanager "google.golang.org/api/cloudresourcemanager/v3"
+	"google.golang.org/api/option"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/resilience"
+)
+
+// hierarchy is a fake resource hierarchy by resource name: each entry's
+// display name and parent
+var hierarchy = map[string][2]string{
+	"projects/proj-a": {"", "folders/2"},
+	"projects/proj-b": {"", "folders/2"},
+	"projects/proj-c": {"", "organizations/9"},
+	"projects/proj-d": {"", ""}, // outside any organization
+	"folders/2":       {"payments", "folders/1"},
+	"folders/1":       {"finance", "organizations/9"},
+	"organizations/9": {"Example Org", ""},
+}
+
+func TestTagHierarchy(t *testing.T) {
+	requests := make(map[string]int)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, "/v3/")
+		requests[name]++
+		n, ok := hierarchy[name]
+		if !ok {
+			http.Error(w, `{"error":{"code":403,"message":"permission denied"}}`, http.StatusForbidden)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"name": name, "displayName": n[0], "parent": n[1]})
+	}))
+	defer srv.Close()
+
+	rm, err := cloudresourcemanager.NewService(context.Background(), option.WithEndpoint(srv.URL+"/"), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls, err := resilience.New("gcp-test", config.CallConfig{}, retryable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &CostProvider{resourceManager: rm, calls: calls, projects: make(map[string]ancestry), nodes: make(map[string]node)}
+
+	entries := []aggregator.CostEntry{
+		{AccountID: "proj-a", Tags: map[string]string{"team": "checkout"}},
+		{AccountID: "proj-b"},
+		{AccountID: "proj-c"},
+		{AccountID: "proj-d"},
+		{AccountID: "proj-deleted"},
+		{AccountID: "proj-a"},
+		{AccountID: "proj-deleted"},
+	}
+	p.tagHierarchy(context.Background(), entries)
+
+	payments := map[string]string{FolderTag: "payments", FolderPathTag: "finance/payments", OrganizationTag: "Example Org"}
+	want := []map[string]string{
+		{"team": "checkout", FolderTag: "payments", FolderPathTag: "finance/payments", OrganizationTag: "Example Org"},
+		payments,
+		{OrganizationTag: "Example Org"},
+		{},
+		nil, // unresolved
+		payments,
+		nil,
+	}
+	for i, e := range entries {
+		if !reflect.DeepEqual(e.Tags, want[i]) {
+			t.Errorf("%s tags = %v, want %v", e.AccountID, e.Tags, want[i])
+		}
+	}
+
+	// Each project, folder and organization is looked up once
+	for name, n := range requests {
+		if n != 1 {
+			t.Errorf("%s requested %d times, want once", name, n)
+		}
+	}
+	if len(requests) != 8 {
+		t.Errorf("got %d lookups, want 8: %v", len(requests), requests)
+	}
+}
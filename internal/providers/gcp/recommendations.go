@@ -0,0 +1,217 @@
+package gcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/api/option"
+	recommender "google.golang.org/api/recommender/v1"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/recommendations"
+	"github.com/lvonguyen/finops-platform/internal/resilience"
+	"github.com/lvonguyen/finops-platform/internal/telemetry"
+)
+
+// recommenderMonth is the month savings are normalized to; Recommender
+// projects cost over 30 days
+const recommenderMonth = 30 * 24 * time.Hour
+
+// recommenderKind is a Recommender recommender and how its recommendations
+// are reported
+type recommenderKind struct {
+	id           string
+	zonal        bool // else regional
+	resourceType string
+	finding      string
+	action       string
+}
+
+var recommenderKinds = []recommenderKind{
+	{"google.compute.instance.IdleResourceRecommender", true, "Compute Engine instance", "Idle", recommendations.ActionTerminate},
+	{"google.compute.instance.MachineTypeRecommender", true, "Compute Engine instance", "Overprovisioned", recommendations.ActionModify},
+	{"google.compute.commitment.UsageCommitmentRecommender", false, "Committed use discount", "Uncommitted usage", recommendations.ActionPurchase},
+}
+
+// Recommender collects GCP Recommender idle VM, machine type and committed
+// use discount recommendations
+type Recommender struct {
+	service  *recommender.Service
+	calls    *resilience.Caller
+	options  recommendations.Options
+	projects []string
+	zones    []string
+}
+
+// NewRecommender creates a recommender with the GCP provider's credentials
+func NewRecommender(ctx context.Context, cfg config.GCPConfig, recCfg config.RecommendationsConfig) (*Recommender, error) {
+	if !cfg.Enabled {
+		return nil, fmt.Errorf("GCP provider is disabled")
+	}
+	opts, err := recommendations.OptionsFrom(recCfg)
+	if err != nil {
+		return nil, err
+	}
+	projects := recCfg.Projects
+	if len(projects) == 0 && cfg.ProjectID != "" {
+		projects = []string{cfg.ProjectID}
+	}
+	if len(projects) == 0 || len(recCfg.Zones) == 0 {
+		return nil, fmt.Errorf("GCP recommendations need recommendations.zones and recommendations.projects or gcp.project_id")
+	}
+
+	var clientOpts []option.ClientOption
+	if cfg.WIFConfigPath != "" {
+		clientOpts = append(clientOpts, option.WithCredentialsFile(cfg.WIFConfigPath))
+	}
+	svc, err := recommender.NewService(ctx, clientOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recommender client: %w", err)
+	}
+	calls, err := resilience.New("gcp", cfg.Calls, retryable)
+	if err != nil {
+		return nil, err
+	}
+	return &Recommender{service: svc, calls: calls, options: opts, projects: projects, zones: recCfg.Zones}, nil
+}
+
+// GetRecommendations returns the active recommendations of each project:
+// VM recommendations in every configured zone, commitments in their regions
+func (r *Recommender) GetRecommendations(ctx context.Context) ([]recommendations.Recommendation, error) {
+	if !r.options.Uses(recommendations.SourceRecommender) {
+		return nil, nil
+	}
+	var regions []string
+	seen := make(map[string]bool)
+	for _, zone := range r.zones {
+		region := zone
+		if i := strings.LastIndex(zone, "-"); i > 0 && strings.Count(zone, "-") > 1 {
+			region = zone[:i]
+		}
+		if !seen[region] {
+			seen[region] = true
+			regions = append(regions, region)
+		}
+	}
+
+	var recs []recommendations.Recommendation
+	for _, project := range r.projects {
+		for _, kind := range recommenderKinds {
+			locations := regions
+			if kind.zonal {
+				locations = r.zones
+			}
+			for _, location := range locations {
+				found, err := r.list(ctx, project, location, kind)
+				if err != nil {
+					return recs, err
+				}
+				recs = append(recs, found...)
+			}
+		}
+	}
+	return recs, nil
+}
+
+// list pages through one recommender's active recommendations at a location
+func (r *Recommender) list(ctx context.Context, project, location string, kind recommenderKind) ([]recommendations.Recommendation, error) {
+	parent := fmt.Sprintf("projects/%s/locations/%s/recommenders/%s", project, location, kind.id)
+	call := r.service.Projects.Locations.Recommenders.Recommendations.List(parent).Filter("stateInfo.state = ACTIVE")
+
+	var recs []recommendations.Recommendation
+	token := ""
+	for page := 1; ; page++ {
+		callCtx, span := telemetry.StartCall(ctx, "gcp.recommender.ListRecommendations", page)
+		var resp *recommender.GoogleCloudRecommenderV1ListRecommendationsResponse
+		err := r.calls.Do(callCtx, func(ctx context.Context) error {
+			var err error
+			resp, err = call.PageToken(token).Context(ctx).Do()
+			return err
+		})
+		if err != nil {
+			telemetry.End(span, 0, err)
+			return nil, fmt.Errorf("failed to list %s recommendations of %s in %s: %w", kind.id, project, location, classifyError(err))
+		}
+		telemetry.End(span, len(resp.Recommendations), nil)
+
+		for _, rec := range resp.Recommendations {
+			if found, ok := fromRecommender(rec, project, location, kind); ok {
+				recs = append(recs, found)
+			}
+		}
+		if resp.NextPageToken == "" {
+			return recs, nil
+		}
+		token = resp.NextPageToken
+	}
+}
+
+// recommenderOverview is the part of a recommendation's content overview
+// read here
+type recommenderOverview struct {
+	Resource           string `json:"resource"`     // full resource name
+	ResourceName       string `json:"resourceName"` // short name
+	CurrentMachineType struct {
+		Name string `json:"name"`
+	} `json:"currentMachineType"`
+	RecommendedMachineType struct {
+		Name string `json:"name"`
+	} `json:"recommendedMachineType"`
+}
+
+// fromRecommender normalizes a recommendation that saves cost; Recommender
+// estimates only the savings, as a negative cost over its projection
+func fromRecommender(rec *recommender.GoogleCloudRecommenderV1Recommendation, project, location string, kind recommenderKind) (recommendations.Recommendation, bool) {
+	if rec.PrimaryImpact == nil || rec.PrimaryImpact.CostProjection == nil || rec.PrimaryImpact.CostProjection.Cost == nil {
+		return recommendations.Recommendation{}, false
+	}
+	projection := rec.PrimaryImpact.CostProjection
+	cost := float64(projection.Cost.Units) + float64(projection.Cost.Nanos)/1e9
+	if cost >= 0 {
+		return recommendations.Recommendation{}, false
+	}
+	savings := -cost
+	if d, err := time.ParseDuration(projection.Duration); err == nil && d > 0 {
+		savings = savings * float64(recommenderMonth) / float64(d)
+	}
+
+	var overview recommenderOverview
+	if rec.Content != nil && len(rec.Content.Overview) > 0 {
+		json.Unmarshal(rec.Content.Overview, &overview)
+	}
+	out := recommendations.Recommendation{
+		Provider:     "gcp",
+		Source:       recommendations.SourceRecommender,
+		Account:      project,
+		Region:       location,
+		ResourceID:   firstOf(overview.ResourceName, overview.Resource[strings.LastIndex(overview.Resource, "/")+1:]),
+		ResourceType: kind.resourceType,
+		Finding:      kind.finding,
+		Action:       kind.action,
+		Current:      overview.CurrentMachineType.Name,
+		Recommended:  overview.RecommendedMachineType.Name,
+		Savings:      savings,
+		Currency:     projection.Cost.CurrencyCode,
+	}
+	if kind.action == recommendations.ActionPurchase {
+		out.Current, out.Recommended = "on-demand", rec.Description
+	}
+	if out.ResourceID == "" {
+		// Commitments apply to a region's usage rather than one resource
+		out.ResourceID = rec.Name[strings.LastIndex(rec.Name, "/")+1:]
+	}
+	return out, true
+}
+
+// firstOf returns the first non-empty value
+func firstOf(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
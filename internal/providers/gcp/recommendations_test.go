@@ -0,0 +1,100 @@
+package gcp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"google.golang.org/api/option"
+	recommender "google.golang.org/api/recommender/v1"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/recommendations"
+	"github.com/lvonguyen/finops-platform/internal/resilience"
+)
+
+// recommendationJSON is a Recommender recommendation in its JSON form
+// saving the given cost over the duration
+func recommendationJSON(name, description string, units, nanos int64, duration string, overview map[string]any) map[string]any {
+	return map[string]any{
+		"name": name, "description": description,
+		"primaryImpact": map[string]any{"category": "COST", "costProjection": map[string]any{
+			"cost":     map[string]any{"currencyCode": "USD", "units": strconv.FormatInt(units, 10), "nanos": nanos},
+			"duration": duration,
+		}},
+		"content": map[string]any{"overview": overview},
+	}
+}
+
+func TestGetRecommenderRecommendations(t *testing.T) {
+	var parents []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parent := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/v1/"), "/recommendations")
+		parents = append(parents, parent)
+		if f := r.URL.Query().Get("filter"); f != "stateInfo.state = ACTIVE" {
+			t.Errorf("filter = %q, want active recommendations", f)
+		}
+		var recs []any
+		token := ""
+		switch parent {
+		case "projects/p1/locations/us-central1-a/recommenders/google.compute.instance.IdleResourceRecommender":
+			if r.URL.Query().Get("pageToken") == "" {
+				token = "page-2"
+				recs = append(recs, recommendationJSON("r1", "Stop idle VM", -10, 0, "2592000s", map[string]any{"resourceName": "vm-1"}))
+			} else {
+				recs = append(recs,
+					recommendationJSON("r2", "Costs more", 5, 0, "2592000s", map[string]any{"resourceName": "vm-3"}),
+					map[string]any{"name": "r3", "description": "No cost impact"})
+			}
+		case "projects/p1/locations/us-central1-b/recommenders/google.compute.instance.MachineTypeRecommender":
+			recs = append(recs, recommendationJSON("r4", "Resize VM", -61, -500000000, "5184000s", map[string]any{
+				"resource":               "//compute.googleapis.com/projects/p1/zones/us-central1-b/instances/vm-2",
+				"currentMachineType":     map[string]any{"name": "n2-standard-8"},
+				"recommendedMachineType": map[string]any{"name": "n2-standard-4"},
+			}))
+		case "projects/p1/locations/us-central1/recommenders/google.compute.commitment.UsageCommitmentRecommender":
+			recs = append(recs, recommendationJSON("projects/p1/locations/us-central1/recommenders/x/recommendations/r5",
+				"Purchase a 3 year commitment", -100, 0, "2592000s", nil))
+		}
+		json.NewEncoder(w).Encode(map[string]any{"recommendations": recs, "nextPageToken": token})
+	}))
+	defer srv.Close()
+
+	svc, err := recommender.NewService(context.Background(), option.WithEndpoint(srv.URL+"/"), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls, err := resilience.New("gcp-test", config.CallConfig{}, retryable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &Recommender{service: svc, calls: calls, projects: []string{"p1"}, zones: []string{"us-central1-a", "us-central1-b"}}
+	got, err := r.GetRecommendations(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []recommendations.Recommendation{
+		{Provider: "gcp", Source: recommendations.SourceRecommender, Account: "p1", Region: "us-central1-a", ResourceID: "vm-1",
+			ResourceType: "Compute Engine instance", Finding: "Idle", Action: recommendations.ActionTerminate, Savings: 10, Currency: "USD"},
+		{Provider: "gcp", Source: recommendations.SourceRecommender, Account: "p1", Region: "us-central1-b", ResourceID: "vm-2",
+			ResourceType: "Compute Engine instance", Finding: "Overprovisioned", Action: recommendations.ActionModify,
+			Current: "n2-standard-8", Recommended: "n2-standard-4", Savings: 30.75, Currency: "USD"},
+		{Provider: "gcp", Source: recommendations.SourceRecommender, Account: "p1", Region: "us-central1", ResourceID: "r5",
+			ResourceType: "Committed use discount", Finding: "Uncommitted usage", Action: recommendations.ActionPurchase,
+			Current: "on-demand", Recommended: "Purchase a 3 year commitment", Savings: 100, Currency: "USD"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetRecommendations() = %+v, want %+v", got, want)
+	}
+	// Two zones of each VM recommender, over two pages for the idle one,
+	// and commitments once in their region
+	if len(parents) != 6 {
+		t.Errorf("listed %v, want 6 requests", parents)
+	}
+}
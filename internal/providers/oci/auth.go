@@ -0,0 +1,194 @@
+package oci
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+// credentials identify an API signing key
+type credentials struct {
+	tenancy     string
+	user        string
+	fingerprint string
+	region      string
+	key         *rsa.PrivateKey
+}
+
+// loadCredentials resolves the API key from the configuration, filling
+// unset fields from the OCI CLI config file profile when one is given
+func loadCredentials(cfg config.OCIConfig) (*credentials, error) {
+	creds := &credentials{
+		tenancy:     cfg.TenancyID,
+		user:        cfg.UserID,
+		fingerprint: cfg.Fingerprint,
+		region:      cfg.Region,
+	}
+	keyPath := cfg.PrivateKeyPath
+
+	if cfg.ConfigFile != "" {
+		profile, err := readProfile(expandHome(cfg.ConfigFile), cfg.Profile)
+		if err != nil {
+			return nil, err
+		}
+		fill := func(field *string, key string) {
+			if *field == "" {
+				*field = profile[key]
+			}
+		}
+		fill(&creds.tenancy, "tenancy")
+		fill(&creds.user, "user")
+		fill(&creds.fingerprint, "fingerprint")
+		fill(&creds.region, "region")
+		fill(&keyPath, "key_file")
+	}
+
+	switch {
+	case creds.tenancy == "", creds.user == "", creds.fingerprint == "", keyPath == "":
+		return nil, fmt.Errorf("OCI tenancy, user, fingerprint and private key must be set")
+	case creds.region == "":
+		return nil, fmt.Errorf("OCI region is not set")
+	}
+
+	key, err := readKey(expandHome(keyPath))
+	if err != nil {
+		return nil, err
+	}
+	creds.key = key
+	return creds, nil
+}
+
+// CheckCredentials resolves the API signing key as the provider does,
+// returning the tenancy it belongs to. No request is made, so a key the
+// tenancy does not recognise is only reported on the first call.
+func CheckCredentials(cfg config.OCIConfig) (string, error) {
+	creds, err := loadCredentials(cfg)
+	if err != nil {
+		return "", err
+	}
+	return creds.tenancy, nil
+}
+
+// readProfile reads one profile of an INI-style OCI CLI config file
+func readProfile(path, name string) (map[string]string, error) {
+	if name == "" {
+		name = "DEFAULT"
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open OCI config: %w", err)
+	}
+	defer f.Close()
+
+	values := make(map[string]string)
+	var section string
+	found := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			found = found || section == name
+			continue
+		}
+		if key, value, ok := strings.Cut(line, "="); ok && section == name {
+			values[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read OCI config: %w", err)
+	}
+	if !found {
+		return nil, fmt.Errorf("profile %q not found in %s", name, path)
+	}
+	return values, nil
+}
+
+// readKey parses an unencrypted PEM RSA private key, PKCS#1 or PKCS#8
+func readKey(path string) (*rsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read OCI private key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM private key found", path)
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to parse private key (encrypted keys are not supported): %w", path, err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: OCI API keys must be RSA", path)
+	}
+	return key, nil
+}
+
+func expandHome(path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	return path
+}
+
+// sign adds OCI request signature headers (draft-cavage HTTP signatures
+// over the date, target, host and, for bodies, their length, type and hash)
+func (c *credentials) sign(req *http.Request, body []byte) error {
+	req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	headers := []string{"date", "(request-target)", "host"}
+	if req.Method == http.MethodPost || req.Method == http.MethodPut {
+		sum := sha256.Sum256(body)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Content-Length", strconv.Itoa(len(body)))
+		req.Header.Set("X-Content-Sha256", base64.StdEncoding.EncodeToString(sum[:]))
+		headers = append(headers, "content-length", "content-type", "x-content-sha256")
+	}
+
+	var signing bytes.Buffer
+	for i, h := range headers {
+		if i > 0 {
+			signing.WriteByte('\n')
+		}
+		switch h {
+		case "(request-target)":
+			fmt.Fprintf(&signing, "%s: %s %s", h, strings.ToLower(req.Method), req.URL.RequestURI())
+		case "host":
+			fmt.Fprintf(&signing, "%s: %s", h, req.URL.Host)
+		default:
+			fmt.Fprintf(&signing, "%s: %s", h, req.Header.Get(h))
+		}
+	}
+
+	digest := sha256.Sum256(signing.Bytes())
+	signature, err := rsa.SignPKCS1v15(rand.Reader, c.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return fmt.Errorf("failed to sign request: %w", err)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf(`Signature version="1",keyId="%s/%s/%s",algorithm="rsa-sha256",headers="%s",signature="%s"`,
+		c.tenancy, c.user, c.fingerprint, strings.Join(headers, " "), base64.StdEncoding.EncodeToString(signature)))
+	return nil
+}
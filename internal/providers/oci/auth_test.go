@@ -0,0 +1,118 @@
+package oci
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+// writeKey writes testKey as a PKCS#8 PEM file and returns its path
+func writeKey(t *testing.T, dir string) string {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadCredentialsFromProfile(t *testing.T) {
+	dir := t.TempDir()
+	keyPath := writeKey(t, dir)
+	profile := "[DEFAULT]\ntenancy = ocid1.tenancy.default\n\n# the reporting user\n[REPORTS]\n" +
+		"user=ocid1.user.reports\nfingerprint=aa:bb\ntenancy=ocid1.tenancy.reports\nregion=us-ashburn-1\nkey_file=" + keyPath + "\n"
+	configFile := filepath.Join(dir, "config")
+	if err := os.WriteFile(configFile, []byte(profile), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Fields set in the configuration take precedence over the profile
+	creds, err := loadCredentials(config.OCIConfig{ConfigFile: configFile, Profile: "REPORTS", Region: "eu-frankfurt-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creds.tenancy != "ocid1.tenancy.reports" || creds.user != "ocid1.user.reports" || creds.region != "eu-frankfurt-1" || !creds.key.Equal(testKey) {
+		t.Errorf("credentials = %+v", creds)
+	}
+
+	tests := []struct {
+		cfg     config.OCIConfig
+		wantErr string
+	}{
+		{config.OCIConfig{ConfigFile: configFile, Profile: "MISSING"}, `profile "MISSING" not found`},
+		{config.OCIConfig{ConfigFile: configFile}, "tenancy, user, fingerprint and private key must be set"},
+		{config.OCIConfig{TenancyID: "t", UserID: "u", Fingerprint: "f", PrivateKeyPath: keyPath}, "region is not set"},
+		{config.OCIConfig{TenancyID: "t", UserID: "u", Fingerprint: "f", PrivateKeyPath: configFile, Region: "r"}, "no PEM private key found"},
+	}
+	for _, tt := range tests {
+		if _, err := loadCredentials(tt.cfg); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("loadCredentials(%+v) = %v, want %s", tt.cfg, err, tt.wantErr)
+		}
+	}
+}
+
+// TestSign verifies the signature over the signing string the Usage API
+// rebuilds from the request
+func TestSign(t *testing.T) {
+	creds := &credentials{tenancy: "t", user: "u", fingerprint: "f", key: testKey}
+	body := []byte(`{"tenantId":"t"}`)
+	req, err := http.NewRequest(http.MethodPost, "https://usageapi.us-ashburn-1.oci.oraclecloud.com/20200107/usage?page=p2", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := creds.sign(req, body); err != nil {
+		t.Fatal(err)
+	}
+
+	m := regexp.MustCompile(`headers="([^"]+)",signature="([^"]+)"`).FindStringSubmatch(req.Header.Get("Authorization"))
+	if m == nil || m[1] != "date (request-target) host content-length content-type x-content-sha256" {
+		t.Fatalf("Authorization = %s", req.Header.Get("Authorization"))
+	}
+	sum := sha256.Sum256(body)
+	if req.Header.Get("X-Content-Sha256") != base64.StdEncoding.EncodeToString(sum[:]) {
+		t.Errorf("X-Content-Sha256 = %s", req.Header.Get("X-Content-Sha256"))
+	}
+
+	signing := strings.Join([]string{
+		"date: " + req.Header.Get("Date"),
+		"(request-target): post /20200107/usage?page=p2",
+		"host: usageapi.us-ashburn-1.oci.oraclecloud.com",
+		"content-length: 16",
+		"content-type: application/json",
+		"x-content-sha256: " + req.Header.Get("X-Content-Sha256"),
+	}, "\n")
+	digest := sha256.Sum256([]byte(signing))
+	signature, err := base64.StdEncoding.DecodeString(m[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rsa.VerifyPKCS1v15(&testKey.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+		t.Errorf("signature does not verify: %v", err)
+	}
+}
+
+func TestCheckCredentials(t *testing.T) {
+	keyPath := writeKey(t, t.TempDir())
+	tenancy, err := CheckCredentials(config.OCIConfig{TenancyID: "ocid1.tenancy.t", UserID: "u", Fingerprint: "f", PrivateKeyPath: keyPath, Region: "us-ashburn-1"})
+	if err != nil || tenancy != "ocid1.tenancy.t" {
+		t.Errorf("CheckCredentials() = %q, %v; want ocid1.tenancy.t", tenancy, err)
+	}
+	if _, err := CheckCredentials(config.OCIConfig{TenancyID: "ocid1.tenancy.t", Region: "us-ashburn-1"}); err == nil {
+		t.Error("CheckCredentials() without a user or key succeeded")
+	}
+}
@@ -0,0 +1,133 @@
+package oci
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/resilience"
+)
+
+// testKey is an API signing key shared by the tests
+var testKey = func() *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}()
+
+// newTestProvider returns a provider calling handler instead of the Usage API
+func newTestProvider(t *testing.T, cfg config.OCIConfig, handler http.HandlerFunc) *CostProvider {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	calls, err := resilience.New("oci-test", config.CallConfig{}, retryable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &CostProvider{
+		client:   srv.Client(),
+		creds:    &credentials{tenancy: "ocid1.tenancy", user: "ocid1.user", fingerprint: "aa:bb", region: "us-ashburn-1", key: testKey},
+		config:   cfg,
+		endpoint: srv.URL + "/20200107/usage",
+		calls:    calls,
+	}
+}
+
+func TestGetCosts(t *testing.T) {
+	var requests []usageRequest
+	p := newTestProvider(t, config.OCIConfig{CompartmentDepth: 2}, func(w http.ResponseWriter, r *http.Request) {
+		if auth := r.Header.Get("Authorization"); !strings.Contains(auth, `keyId="ocid1.tenancy/ocid1.user/aa:bb"`) ||
+			r.Header.Get("X-Content-Sha256") == "" {
+			t.Errorf("unsigned request: Authorization %q", auth)
+		}
+		body, _ := io.ReadAll(r.Body)
+		var req usageRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			t.Fatal(err)
+		}
+		requests = append(requests, req)
+
+		if r.URL.Query().Get("page") == "" {
+			w.Header().Set("opc-next-page", "p2")
+			io.WriteString(w, `{"items": [
+				{"compartmentPath": "prod/web", "service": "Compute", "region": "us-ashburn-1",
+				 "timeUsageStarted": "2024-01-05T00:00:00Z", "computedAmount": 12.5, "computedQuantity": 24, "unit": "OCPU Hours", "currency": "EUR"},
+				{"compartmentPath": "prod/web", "service": "Compute", "timeUsageStarted": "2024-01-05T00:00:00Z"}
+			]}`)
+			return
+		}
+		io.WriteString(w, `{"items": [
+			{"compartmentName": "sandbox", "service": "Object Storage", "region": "us-phoenix-1",
+			 "timeUsageStarted": "2024-01-06T00:00:00Z", "computedAmount": 0.5}
+		]}`)
+	})
+
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	entries, err := p.GetCosts(context.Background(), start, start.AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != 2 {
+		t.Fatalf("made %d requests, want 2 pages", len(requests))
+	}
+	if req := requests[0]; req.TimeUsageStarted != "2024-01-01T00:00:00Z" || req.Granularity != "DAILY" ||
+		req.CompartmentDepth != 2 || req.TenantID != "ocid1.tenancy" {
+		t.Errorf("request = %+v", req)
+	}
+
+	want := []aggregator.CostEntry{
+		{Provider: "oci", AccountID: "prod/web", Service: "Compute", Region: "us-ashburn-1", Date: time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC),
+			Cost: 12.5, ChargeType: "usage", Currency: "EUR", UsageAmount: 24, UsageUnit: "OCPU Hours"},
+		{Provider: "oci", AccountID: "sandbox", Service: "Object Storage", Region: "us-phoenix-1", Date: time.Date(2024, 1, 6, 0, 0, 0, 0, time.UTC),
+			Cost: 0.5, ChargeType: "usage", Currency: "USD"},
+	}
+	// The row without an amount is skipped
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("got\n%+v\nwant\n%+v", entries, want)
+	}
+}
+
+func TestGetCostsErrors(t *testing.T) {
+	tests := []struct {
+		status    int
+		body      string
+		authErr   bool
+		retryable bool
+		message   string
+	}{
+		{http.StatusUnauthorized, `{"code": "NotAuthenticated", "message": "bad key"}`, true, false, "401 NotAuthenticated: bad key"},
+		{http.StatusTooManyRequests, `{"code": "TooManyRequests", "message": "slow down"}`, false, true, "429 TooManyRequests"},
+		{http.StatusBadGateway, `upstream down`, false, true, "502 Bad Gateway: upstream down"},
+		{http.StatusBadRequest, `{"code": "InvalidParameter", "message": "bad depth"}`, false, false, "400 InvalidParameter"},
+	}
+	for _, tt := range tests {
+		p := newTestProvider(t, config.OCIConfig{CompartmentDepth: 1}, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status)
+			io.WriteString(w, tt.body)
+		})
+		_, err := p.GetCosts(context.Background(), time.Now().AddDate(0, 0, -1), time.Now())
+		if err == nil || !strings.Contains(err.Error(), tt.message) {
+			t.Errorf("%d: err = %v, want %s", tt.status, err, tt.message)
+			continue
+		}
+		if got := errors.Is(err, aggregator.ErrAuthExpired); got != tt.authErr {
+			t.Errorf("%d: auth expired = %v, want %v", tt.status, got, tt.authErr)
+		}
+		if got := retryable(classifyError(tt.status, []byte(tt.body))); got != tt.retryable {
+			t.Errorf("%d: retryable = %v, want %v", tt.status, got, tt.retryable)
+		}
+	}
+}
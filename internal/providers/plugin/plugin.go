@@ -0,0 +1,199 @@
+// Package plugin runs out-of-tree cost providers as external programs, so
+// niche billing sources can be added without changing this repository.
+//
+// Each call runs the plugin's command once, writes a request to its stdin
+// and reads the response from its stdout, both single JSON objects:
+//
+//	{"version": 1, "method": "get_costs", "start": "2024-01-01", "end": "2024-02-01", "settings": {...}}
+//	{"entries": [{"date": "2024-01-01T00:00:00Z", "account_id": "acme", "service": "Snowflake",
+//	  "region": "us-east-1", "cost": 120.5, "currency": "USD", "tags": {"team": "data"}}]}
+//
+// Entries take the JSON form of aggregator.CostEntry and cover [start, end);
+// entries without a provider are attributed to the plugin. The get_budgets
+// method answers {"budgets": [...]} in the form of aggregator.BudgetStatus,
+// or {"error": "unsupported"} from plugins without budgets. Any other error
+// in the response, or a non-zero exit, fails the call. Whatever the plugin
+// writes to stderr is logged.
+package plugin
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/providers"
+)
+
+// ProtocolVersion is the version of the request and response format
+const ProtocolVersion = 1
+
+// Methods
+const (
+	MethodGetCosts   = "get_costs"
+	MethodGetBudgets = "get_budgets"
+)
+
+// errUnsupported is the error a plugin answers for methods it lacks
+const errUnsupported = "unsupported"
+
+// request is sent to the plugin on stdin
+type request struct {
+	Version  int               `json:"version"`
+	Method   string            `json:"method"`
+	Start    string            `json:"start,omitempty"` // YYYY-MM-DD
+	End      string            `json:"end,omitempty"`   // YYYY-MM-DD, exclusive
+	Settings map[string]string `json:"settings,omitempty"`
+}
+
+// response is read from the plugin's stdout
+type response struct {
+	Entries []aggregator.CostEntry    `json:"entries"`
+	Budgets []aggregator.BudgetStatus `json:"budgets"`
+	Error   string                    `json:"error"`
+}
+
+// Provider implements aggregator.CostProvider by running a plugin
+type Provider struct {
+	config  config.PluginConfig
+	timeout time.Duration
+}
+
+// New creates a provider running the configured plugin
+func New(cfg config.PluginConfig) (*Provider, error) {
+	if cfg.Name == "" {
+		return nil, fmt.Errorf("plugin has no name")
+	}
+	if cfg.Command == "" {
+		return nil, fmt.Errorf("plugin %q has no command", cfg.Name)
+	}
+	timeout, err := time.ParseDuration(cfg.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("plugin %q: invalid timeout %q: %w", cfg.Name, cfg.Timeout, err)
+	}
+	return &Provider{config: cfg, timeout: timeout}, nil
+}
+
+// Register adds each configured plugin to the provider registry, where it
+// is built like the built-in providers and selected by --cloud all or its
+// name
+func Register(plugins []config.PluginConfig) error {
+	for _, pc := range plugins {
+		p, err := New(pc)
+		if err != nil {
+			return err
+		}
+		var query any
+		if pc.Cache {
+			query = pc
+		}
+		err = providers.Register(providers.Provider{
+			Name: pc.Name,
+			New: func(ctx context.Context, cfg *config.Config) (aggregator.CostProvider, any, error) {
+				return p, query, nil
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("plugin %q: %w", pc.Name, err)
+		}
+	}
+	return nil
+}
+
+// Name returns the plugin's provider name
+func (p *Provider) Name() string {
+	return p.config.Name
+}
+
+// GetCosts asks the plugin for the costs of [start, end)
+func (p *Provider) GetCosts(ctx context.Context, start, end time.Time) ([]aggregator.CostEntry, error) {
+	resp, err := p.call(ctx, request{
+		Method: MethodGetCosts,
+		Start:  start.Format("2006-01-02"),
+		End:    end.Format("2006-01-02"),
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range resp.Entries {
+		if resp.Entries[i].Provider == "" {
+			resp.Entries[i].Provider = p.config.Name
+		}
+	}
+	return resp.Entries, nil
+}
+
+// GetBudgets asks the plugin for its budgets; plugins without budgets
+// answer unsupported, which is no budgets
+func (p *Provider) GetBudgets(ctx context.Context) ([]aggregator.BudgetStatus, error) {
+	resp, err := p.call(ctx, request{Method: MethodGetBudgets})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Budgets, nil
+}
+
+// call runs the plugin once for a request
+func (p *Provider) call(ctx context.Context, req request) (*response, error) {
+	req.Version = ProtocolVersion
+	req.Settings = p.config.Settings
+	in, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: failed to encode request: %w", p.config.Name, err)
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+	cmd := exec.CommandContext(callCtx, p.config.Command, p.config.Args...)
+	cmd.Env = os.Environ()
+	for k, v := range p.config.Env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	cmd.Stdin = bytes.NewReader(in)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+	p.logStderr(stderr.Bytes())
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("plugin %s: %s: %w", p.config.Name, req.Method, ctx.Err())
+	}
+	if callCtx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("plugin %s: %s timed out after %s", p.config.Name, req.Method, p.timeout)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: %s failed: %w", p.config.Name, req.Method, err)
+	}
+
+	var resp response
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return nil, fmt.Errorf("plugin %s: invalid %s response: %w", p.config.Name, req.Method, err)
+	}
+	switch resp.Error {
+	case "":
+	case errUnsupported:
+		return &response{}, nil
+	default:
+		return nil, fmt.Errorf("plugin %s: %s", p.config.Name, resp.Error)
+	}
+	return &resp, nil
+}
+
+// logStderr logs the plugin's diagnostics line by line
+func (p *Provider) logStderr(out []byte) {
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		if line := strings.TrimSpace(sc.Text()); line != "" {
+			log.Printf("plugin %s: %s", p.config.Name, line)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/providers"
+)
+
+// TestMain runs the test binary as a plugin when PLUGIN_TEST_MODE is set
+func TestMain(m *testing.M) {
+	if mode := os.Getenv("PLUGIN_TEST_MODE"); mode != "" {
+		os.Exit(fakePlugin(mode))
+	}
+	os.Exit(m.Run())
+}
+
+// fakePlugin answers one request the way mode says, returning its exit code
+func fakePlugin(mode string) int {
+	var req request
+	if err := json.NewDecoder(os.Stdin).Decode(&req); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	fmt.Fprintf(os.Stderr, "handling %s\n\n", req.Method)
+	switch mode {
+	case "echo":
+		if req.Method == MethodGetBudgets {
+			fmt.Print(`{"error": "unsupported"}`)
+			return 0
+		}
+		// One entry of the plugin's and one of another provider's, both
+		// describing the request
+		json.NewEncoder(os.Stdout).Encode(map[string]any{"entries": []map[string]any{
+			{"date": req.Start + "T00:00:00Z", "account_id": req.Settings["account"], "service": req.Method, "cost": float64(req.Version), "currency": "USD"},
+			{"provider": "snowflake", "date": req.End + "T00:00:00Z", "service": os.Getenv("VENDOR_TOKEN"), "cost": 2},
+		}})
+	case "budgets":
+		fmt.Print(`{"budgets": [{"budget_name": "data", "limit": 500, "current_spend": 120}]}`)
+	case "error":
+		fmt.Print(`{"error": "invalid API token"}`)
+	case "garbage":
+		fmt.Print("not json")
+	case "exit":
+		return 3
+	case "sleep":
+		time.Sleep(10 * time.Second)
+	}
+	return 0
+}
+
+// testPlugin returns a provider running the test binary in mode
+func testPlugin(t *testing.T, mode, timeout string) *Provider {
+	t.Helper()
+	p, err := New(config.PluginConfig{
+		Name:     "acme",
+		Command:  os.Args[0],
+		Env:      map[string]string{"PLUGIN_TEST_MODE": mode, "VENDOR_TOKEN": "secret"},
+		Timeout:  timeout,
+		Settings: map[string]string{"account": "acct-1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestNew(t *testing.T) {
+	for name, cfg := range map[string]config.PluginConfig{
+		"no name":     {Command: "acme-costs", Timeout: "1m"},
+		"no command":  {Name: "acme", Timeout: "1m"},
+		"bad timeout": {Name: "acme", Command: "acme-costs", Timeout: "soon"},
+	} {
+		if _, err := New(cfg); err == nil {
+			t.Errorf("%s: New() succeeded, want an error", name)
+		}
+	}
+}
+
+func TestGetCosts(t *testing.T) {
+	p := testPlugin(t, "echo", "1m")
+	start, end := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+	entries, err := p.GetCosts(context.Background(), start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []aggregator.CostEntry{
+		{Provider: "acme", Date: start, AccountID: "acct-1", Service: MethodGetCosts, Cost: ProtocolVersion, Currency: "USD"},
+		{Provider: "snowflake", Date: end, Service: "secret", Cost: 2},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("GetCosts() = %+v, want %+v", entries, want)
+	}
+	for i := range want {
+		if !reflect.DeepEqual(entries[i], want[i]) {
+			t.Errorf("entry %d = %+v, want %+v", i, entries[i], want[i])
+		}
+	}
+
+	// Unsupported methods answer nothing
+	budgets, err := p.GetBudgets(context.Background())
+	if err != nil || budgets != nil {
+		t.Errorf("GetBudgets() = %v, %v; want none", budgets, err)
+	}
+}
+
+func TestGetBudgets(t *testing.T) {
+	budgets, err := testPlugin(t, "budgets", "1m").GetBudgets(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(budgets) != 1 || budgets[0].BudgetName != "data" || budgets[0].Limit != 500 || budgets[0].CurrentSpend != 120 {
+		t.Errorf("GetBudgets() = %+v, want the data budget", budgets)
+	}
+}
+
+func TestCallErrors(t *testing.T) {
+	tests := []struct {
+		mode, timeout, want string
+	}{
+		{"error", "1m", "plugin acme: invalid API token"},
+		{"garbage", "1m", "plugin acme: invalid get_costs response"},
+		{"exit", "1m", "plugin acme: get_costs failed: exit status 3"},
+		{"sleep", "100ms", "plugin acme: get_costs timed out after 100ms"},
+	}
+	for _, tt := range tests {
+		_, err := testPlugin(t, tt.mode, tt.timeout).GetCosts(context.Background(), time.Now(), time.Now())
+		if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+			t.Errorf("%s: GetCosts() = %v, want %s", tt.mode, err, tt.want)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := testPlugin(t, "echo", "1m").GetCosts(ctx, time.Now(), time.Now()); err == nil || !strings.Contains(err.Error(), "context canceled") {
+		t.Errorf("GetCosts(canceled) = %v, want the context's error", err)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	cfg := config.PluginConfig{Name: "plugin-test", Command: os.Args[0], Timeout: "1m", Cache: true}
+	if err := Register([]config.PluginConfig{cfg}); err != nil {
+		t.Fatal(err)
+	}
+	registered, ok := providers.Lookup("plugin-test")
+	if !ok {
+		t.Fatal("plugin is not registered")
+	}
+	p, query, err := registered.New(context.Background(), &config.Config{})
+	if err != nil || p.Name() != "plugin-test" || !reflect.DeepEqual(query, cfg) {
+		t.Errorf("New() = %v, %v, %v; want the plugin, cached by its config", p, query, err)
+	}
+
+	if err := Register([]config.PluginConfig{cfg}); err == nil || !strings.Contains(err.Error(), "already registered") {
+		t.Errorf("Register() of a taken name = %v, want an error", err)
+	}
+	if err := Register([]config.PluginConfig{{Name: "no-command"}}); err == nil {
+		t.Error("Register() of an invalid plugin succeeded")
+	}
+}
@@ -0,0 +1,96 @@
+// Package providers is the registry of cost providers. Each provider package
+// registers itself by name when imported; external plugins are registered
+// from the configuration at startup.
+package providers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+// Factory builds a provider from the configuration. The query it returns is
+// the configuration the provider's results depend on, part of the result
+// cache key; nil leaves the results uncached, as for file imports.
+type Factory func(ctx context.Context, cfg *config.Config) (p aggregator.CostProvider, query any, err error)
+
+// Provider is a registered cost provider
+type Provider struct {
+	Name string
+	New  Factory
+
+	// Enabled reports whether --cloud all includes the provider; nil
+	// always includes it, leaving a disabled provider to say so
+	Enabled func(cfg *config.Config) bool
+}
+
+var (
+	mu       sync.Mutex
+	registry = make(map[string]Provider)
+)
+
+// Register adds a provider, failing if the name is taken
+func Register(p Provider) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if p.Name == "" || p.New == nil {
+		return fmt.Errorf("provider needs a name and a factory")
+	}
+	if _, dup := registry[p.Name]; dup {
+		return fmt.Errorf("provider %q is already registered", p.Name)
+	}
+	registry[p.Name] = p
+	return nil
+}
+
+// MustRegister adds a provider from a package's init, panicking if the name
+// is taken
+func MustRegister(p Provider) {
+	if err := Register(p); err != nil {
+		panic(err)
+	}
+}
+
+// Lookup returns the provider registered under name
+func Lookup(name string) (Provider, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	p, ok := registry[name]
+	return p, ok
+}
+
+// Names lists the registered providers in order
+func Names() []string {
+	mu.Lock()
+	defer mu.Unlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Selected lists the providers a --cloud value selects: the one named, or
+// for "all" each provider the configuration enables
+func Selected(cloud string, cfg *config.Config) ([]Provider, error) {
+	if cloud != "all" {
+		p, ok := Lookup(cloud)
+		if !ok {
+			return nil, fmt.Errorf("unknown provider %q", cloud)
+		}
+		return []Provider{p}, nil
+	}
+	var selected []Provider
+	for _, name := range Names() {
+		p, _ := Lookup(name)
+		if p.Enabled == nil || p.Enabled(cfg) {
+			selected = append(selected, p)
+		}
+	}
+	return selected, nil
+}
@@ -0,0 +1,82 @@
+package providers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+// factory builds no provider
+func factory(ctx context.Context, cfg *config.Config) (aggregator.CostProvider, any, error) {
+	return nil, nil, nil
+}
+
+// names lists the names of selected providers
+func names(selected []Provider) []string {
+	var out []string
+	for _, p := range selected {
+		out = append(out, p.Name)
+	}
+	return out
+}
+
+func TestRegistry(t *testing.T) {
+	saved := registry
+	registry = make(map[string]Provider)
+	t.Cleanup(func() { registry = saved })
+
+	MustRegister(Provider{Name: "zcloud", New: factory})
+	MustRegister(Provider{Name: "acloud", New: factory, Enabled: func(cfg *config.Config) bool { return cfg.AWS.Enabled }})
+	MustRegister(Provider{Name: "mcloud", New: factory, Enabled: func(cfg *config.Config) bool { return true }})
+
+	for name, p := range map[string]Provider{
+		"duplicate":  {Name: "zcloud", New: factory},
+		"no name":    {New: factory},
+		"no factory": {Name: "other"},
+	} {
+		if err := Register(p); err == nil {
+			t.Errorf("%s: Register() succeeded, want an error", name)
+		}
+	}
+	if got, want := Names(), []string{"acloud", "mcloud", "zcloud"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Names() = %v, want %v", got, want)
+	}
+	if p, ok := Lookup("mcloud"); !ok || p.Name != "mcloud" {
+		t.Errorf("Lookup(mcloud) = %+v, %v", p, ok)
+	}
+	if _, ok := Lookup("missing"); ok {
+		t.Error("Lookup(missing) found a provider")
+	}
+
+	tests := []struct {
+		cloud string
+		cfg   config.Config
+		want  []string
+	}{
+		{"all", config.Config{}, []string{"mcloud", "zcloud"}},
+		{"all", config.Config{AWS: config.AWSConfig{Enabled: true}}, []string{"acloud", "mcloud", "zcloud"}},
+		// A provider named is selected, enabled or not
+		{"acloud", config.Config{}, []string{"acloud"}},
+	}
+	for _, tt := range tests {
+		selected, err := Selected(tt.cloud, &tt.cfg)
+		if err != nil || !reflect.DeepEqual(names(selected), tt.want) {
+			t.Errorf("Selected(%s) = %v, %v; want %v", tt.cloud, names(selected), err, tt.want)
+		}
+	}
+	if _, err := Selected("missing", &config.Config{}); err == nil {
+		t.Error("Selected(missing) succeeded, want an error")
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("MustRegister() of a taken name did not panic")
+			}
+		}()
+		MustRegister(Provider{Name: "acloud", New: factory})
+	}()
+}
@@ -0,0 +1,128 @@
+package publish
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+)
+
+// blobAPIVersion is the Blob service REST API version requests are made with
+const blobAPIVersion = "2021-08-06"
+
+// azureContainer stores reports as block blobs in an Azure Storage
+// container, authenticating with the default Azure credential chain
+type azureContainer struct {
+	cred     azcore.TokenCredential
+	endpoint string // https://account.blob.core.windows.net/container
+	client   *http.Client
+}
+
+func newAzureContainer(account, container string) (*azureContainer, error) {
+	cred, err := azidentity.NewDefaultAzureCredential(nil)
+	if err != nil {
+		return nil, err
+	}
+	return &azureContainer{
+		cred:     cred,
+		endpoint: fmt.Sprintf("https://%s.blob.core.windows.net/%s", account, container),
+		client:   &http.Client{Timeout: 5 * time.Minute},
+	}, nil
+}
+
+// do sends an authenticated request and fails on a non-2xx status
+func (c *azureContainer) do(ctx context.Context, method, target string, body io.Reader, size int64, header http.Header) (*http.Response, error) {
+	token, err := c.cred.GetToken(ctx, policy.TokenRequestOptions{Scopes: []string{"https://storage.azure.com/.default"}})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	req.Header.Set("Authorization", "Bearer "+token.Token)
+	req.Header.Set("x-ms-version", blobAPIVersion)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("blob service returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+func (c *azureContainer) blobURL(key string) string {
+	return c.endpoint + "/" + (&url.URL{Path: key}).EscapedPath()
+}
+
+func (c *azureContainer) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	header := http.Header{}
+	header.Set("x-ms-blob-type", "BlockBlob")
+	header.Set("Content-Type", contentType)
+	resp, err := c.do(ctx, http.MethodPut, c.blobURL(key), body, size, header)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (c *azureContainer) List(ctx context.Context, prefix string) ([]Object, error) {
+	var objects []Object
+	marker := ""
+	for {
+		params := url.Values{"restype": {"container"}, "comp": {"list"}, "prefix": {prefix}}
+		if marker != "" {
+			params.Set("marker", marker)
+		}
+		resp, err := c.do(ctx, http.MethodGet, c.endpoint+"?"+params.Encode(), nil, 0, nil)
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			Blobs []struct {
+				Name         string `xml:"Name"`
+				LastModified string `xml:"Properties>Last-Modified"`
+			} `xml:"Blobs>Blob"`
+			NextMarker string `xml:"NextMarker"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse blob list: %w", err)
+		}
+		for _, b := range result.Blobs {
+			modified, _ := time.Parse(time.RFC1123, b.LastModified)
+			objects = append(objects, Object{Key: b.Name, Modified: modified})
+		}
+		if result.NextMarker == "" {
+			return objects, nil
+		}
+		marker = result.NextMarker
+	}
+}
+
+func (c *azureContainer) Delete(ctx context.Context, key string) error {
+	resp, err := c.do(ctx, http.MethodDelete, c.blobURL(key), nil, 0, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
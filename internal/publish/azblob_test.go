@@ -0,0 +1,100 @@
+package publish
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+// staticCredential returns a fixed token
+type staticCredential struct{}
+
+func (staticCredential) GetToken(ctx context.Context, opts policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return azcore.AccessToken{Token: "token", ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
+
+// newTestContainer serves a container's blob API with handler
+func newTestContainer(t *testing.T, handler http.HandlerFunc) *azureContainer {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return &azureContainer{cred: staticCredential{}, endpoint: srv.URL + "/reports", client: srv.Client()}
+}
+
+func TestAzureContainer(t *testing.T) {
+	var puts, deletes []string
+	c := newTestContainer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" || r.Header.Get("x-ms-version") != blobAPIVersion {
+			t.Errorf("%s %s is missing its token or version", r.Method, r.URL)
+		}
+		switch r.Method {
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			if r.Header.Get("x-ms-blob-type") != "BlockBlob" || r.Header.Get("Content-Type") != "text/csv" || r.ContentLength != int64(len(body)) {
+				t.Errorf("put %s with headers %v", r.URL.Path, r.Header)
+			}
+			puts = append(puts, r.URL.EscapedPath()+" "+string(body))
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodDelete:
+			if strings.HasSuffix(r.URL.Path, "/locked.html") {
+				http.Error(w, "lease is active", http.StatusPreconditionFailed)
+				return
+			}
+			deletes = append(deletes, r.URL.Path)
+			w.WriteHeader(http.StatusAccepted)
+		case http.MethodGet:
+			q := r.URL.Query()
+			if r.URL.Path != "/reports" || q.Get("restype") != "container" || q.Get("comp") != "list" || q.Get("prefix") != "daily/" {
+				t.Errorf("unexpected list %s", r.URL)
+			}
+			if q.Get("marker") == "" {
+				io.WriteString(w, `<EnumerationResults><Blobs>
+					<Blob><Name>daily/a.html</Name><Properties><Last-Modified>Sun, 31 Mar 2024 10:00:00 GMT</Last-Modified></Properties></Blob>
+				</Blobs><NextMarker>m2</NextMarker></EnumerationResults>`)
+				return
+			}
+			io.WriteString(w, `<EnumerationResults><Blobs>
+				<Blob><Name>daily/b.csv</Name><Properties><Last-Modified>Sat, 30 Mar 2024 10:00:00 GMT</Last-Modified></Properties></Blob>
+			</Blobs><NextMarker/></EnumerationResults>`)
+		}
+	})
+	ctx := context.Background()
+
+	if err := c.Put(ctx, "daily/cost report.csv", strings.NewReader("a,b\n"), 4, "text/csv"); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"/reports/daily/cost%20report.csv a,b\n"}; !reflect.DeepEqual(puts, want) {
+		t.Errorf("puts = %q, want %q", puts, want)
+	}
+
+	objects, err := c.List(ctx, "daily/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Object{
+		{Key: "daily/a.html", Modified: time.Date(2024, 3, 31, 10, 0, 0, 0, time.UTC)},
+		{Key: "daily/b.csv", Modified: time.Date(2024, 3, 30, 10, 0, 0, 0, time.UTC)},
+	}
+	if len(objects) != 2 || objects[0].Key != want[0].Key || !objects[0].Modified.Equal(want[0].Modified) ||
+		objects[1].Key != want[1].Key || !objects[1].Modified.Equal(want[1].Modified) {
+		t.Errorf("List() = %+v, want %+v", objects, want)
+	}
+
+	if err := c.Delete(ctx, "daily/a.html"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Delete(ctx, "daily/locked.html"); err == nil || !strings.Contains(err.Error(), "412") || !strings.Contains(err.Error(), "lease is active") {
+		t.Errorf("Delete(locked) = %v, want the blob service's status and message", err)
+	}
+	if want := []string{"/reports/daily/a.html"}; !reflect.DeepEqual(deletes, want) {
+		t.Errorf("deletes = %v, want %v", deletes, want)
+	}
+}
@@ -0,0 +1,46 @@
+package publish
+
+import (
+	"context"
+	"io"
+	"time"
+
+	storage "google.golang.org/api/storage/v1"
+)
+
+// gcsBucket stores reports in a Cloud Storage bucket, with application
+// default credentials
+type gcsBucket struct {
+	service *storage.Service
+	bucket  string
+}
+
+func newGCSBucket(ctx context.Context, bucket string) (*gcsBucket, error) {
+	svc, err := storage.NewService(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &gcsBucket{service: svc, bucket: bucket}, nil
+}
+
+func (b *gcsBucket) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	_, err := b.service.Objects.Insert(b.bucket, &storage.Object{Name: key, ContentType: contentType}).
+		Media(body).Context(ctx).Do()
+	return err
+}
+
+func (b *gcsBucket) List(ctx context.Context, prefix string) ([]Object, error) {
+	var objects []Object
+	err := b.service.Objects.List(b.bucket).Prefix(prefix).Pages(ctx, func(page *storage.Objects) error {
+		for _, o := range page.Items {
+			updated, _ := time.Parse(time.RFC3339, o.Updated)
+			objects = append(objects, Object{Key: o.Name, Modified: updated})
+		}
+		return nil
+	})
+	return objects, err
+}
+
+func (b *gcsBucket) Delete(ctx context.Context, key string) error {
+	return b.service.Objects.Delete(b.bucket, key).Context(ctx).Do()
+}
@@ -0,0 +1,165 @@
+// Package publish uploads generated reports to object storage, so reports
+// written by runs in short-lived containers outlive them
+package publish
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+// Bucket is an object storage backend
+type Bucket interface {
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	// List returns the objects whose keys start with prefix
+	List(ctx context.Context, prefix string) ([]Object, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Object is a stored object
+type Object struct {
+	Key      string
+	Modified time.Time
+}
+
+// Publisher uploads reports under a key prefix of a bucket
+type Publisher struct {
+	bucket    Bucket
+	location  string // scheme://bucket, for messages
+	prefix    string // ends in "/" unless empty
+	retention time.Duration
+}
+
+// New creates a publisher for the configured URL: s3://bucket/prefix,
+// gs://bucket/prefix or az://account/container/prefix
+func New(ctx context.Context, cfg config.PublishConfig) (*Publisher, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid publish url %q: want s3://bucket/prefix, gs://bucket/prefix or az://account/container/prefix", cfg.URL)
+	}
+	if cfg.RetentionDays < 0 {
+		return nil, fmt.Errorf("publish retention_days must not be negative")
+	}
+	prefix := strings.Trim(u.Path, "/")
+
+	var bucket Bucket
+	location := u.Scheme + "://" + u.Host
+	switch u.Scheme {
+	case "s3":
+		bucket, err = newS3Bucket(ctx, u.Host, cfg.Region)
+	case "gs":
+		bucket, err = newGCSBucket(ctx, u.Host)
+	case "az":
+		container, rest, _ := strings.Cut(prefix, "/")
+		if container == "" {
+			return nil, fmt.Errorf("invalid publish url %q: az:// needs an account and a container", cfg.URL)
+		}
+		location += "/" + container
+		prefix = rest
+		bucket, err = newAzureContainer(u.Host, container)
+	default:
+		return nil, fmt.Errorf("unknown publish scheme %q (want s3, gs or az)", u.Scheme)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s publisher: %w", u.Scheme, err)
+	}
+	return NewWithBucket(bucket, location, prefix, time.Duration(cfg.RetentionDays)*24*time.Hour), nil
+}
+
+// NewWithBucket creates a publisher for a custom backend; a zero retention
+// keeps every report
+func NewWithBucket(bucket Bucket, location, prefix string, retention time.Duration) *Publisher {
+	if prefix = strings.Trim(prefix, "/"); prefix != "" {
+		prefix += "/"
+	}
+	return &Publisher{bucket: bucket, location: location, prefix: prefix, retention: retention}
+}
+
+// PublishDir uploads the files under dir modified at or after since, keyed
+// by their path relative to dir, and returns their URLs. It continues past
+// individual failures.
+func (p *Publisher) PublishDir(ctx context.Context, dir string, since time.Time) ([]string, error) {
+	var published []string
+	var errs []error
+	err := filepath.WalkDir(dir, func(file string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.ModTime().Before(since) {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		key := p.prefix + filepath.ToSlash(rel)
+		if err := p.put(ctx, file, key, info.Size()); err != nil {
+			errs = append(errs, fmt.Errorf("failed to publish %s: %w", rel, err))
+			return nil
+		}
+		published = append(published, p.location+"/"+key)
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		err = nil // nothing was written
+	}
+	if err != nil {
+		errs = append(errs, fmt.Errorf("failed to list reports: %w", err))
+	}
+	return published, errors.Join(errs...)
+}
+
+func (p *Publisher) put(ctx context.Context, file, key string, size int64) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return p.bucket.Put(ctx, key, f, size, contentType)
+}
+
+// Prune deletes published reports under the prefix older than the
+// retention, returning how many were deleted
+func (p *Publisher) Prune(ctx context.Context, now time.Time) (int, error) {
+	if p.retention == 0 {
+		return 0, nil
+	}
+	objects, err := p.bucket.List(ctx, p.prefix)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list published reports: %w", err)
+	}
+	cutoff := now.Add(-p.retention)
+	deleted := 0
+	var errs []error
+	for _, o := range objects {
+		if o.Modified.IsZero() || !o.Modified.Before(cutoff) {
+			continue // unknown age or still retained
+		}
+		if err := p.bucket.Delete(ctx, o.Key); err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete %s: %w", o.Key, err))
+			continue
+		}
+		deleted++
+	}
+	return deleted, errors.Join(errs...)
+}
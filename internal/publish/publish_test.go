@@ -0,0 +1,183 @@
+package publish
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+var now = time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+
+// memBucket is an in-memory bucket; keys in fail are refused
+type memBucket struct {
+	objects map[string]Object
+	bodies  map[string]string
+	types   map[string]string
+	fail    map[string]bool
+}
+
+func newMemBucket() *memBucket {
+	return &memBucket{objects: map[string]Object{}, bodies: map[string]string{}, types: map[string]string{}, fail: map[string]bool{}}
+}
+
+func (b *memBucket) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	if b.fail[key] {
+		return errors.New("access denied")
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) != size {
+		return errors.New("size does not match the body")
+	}
+	b.objects[key] = Object{Key: key, Modified: now}
+	b.bodies[key], b.types[key] = string(data), contentType
+	return nil
+}
+
+func (b *memBucket) List(ctx context.Context, prefix string) ([]Object, error) {
+	var objects []Object
+	for key, o := range b.objects {
+		if strings.HasPrefix(key, prefix) {
+			objects = append(objects, o)
+		}
+	}
+	return objects, nil
+}
+
+func (b *memBucket) Delete(ctx context.Context, key string) error {
+	if b.fail[key] {
+		return errors.New("access denied")
+	}
+	delete(b.objects, key)
+	return nil
+}
+
+// keys lists a bucket's keys in order
+func (b *memBucket) keys() []string {
+	var keys []string
+	for key := range b.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// writeReport writes a report file modified at the given time
+func writeReport(t *testing.T, dir, name, content string, modified time.Time) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	for name, cfg := range map[string]config.PublishConfig{
+		"no bucket":          {URL: "s3:///reports"},
+		"not a URL":          {URL: "::"},
+		"unknown scheme":     {URL: "ftp://host/reports"},
+		"no container":       {URL: "az://account"},
+		"negative retention": {URL: "s3://bucket/reports", RetentionDays: -1},
+	} {
+		if _, err := New(context.Background(), cfg); err == nil {
+			t.Errorf("%s: New() succeeded, want an error", name)
+		}
+	}
+}
+
+func TestPublishDir(t *testing.T) {
+	dir := t.TempDir()
+	since := now.Add(-time.Hour)
+	writeReport(t, dir, "cost-report-1.html", "<html></html>", now)
+	writeReport(t, dir, "cost-report-1.csv", "a,b\n", now)
+	writeReport(t, dir, "parquet/costs.parquet", "PAR1", now)
+	writeReport(t, dir, "cost-report-0.html", "old", since.Add(-time.Minute))
+
+	bucket := newMemBucket()
+	p := NewWithBucket(bucket, "s3://reports", "/finops/daily/", 0)
+	published, err := p.PublishDir(context.Background(), dir, since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(published)
+	want := []string{
+		"s3://reports/finops/daily/cost-report-1.csv",
+		"s3://reports/finops/daily/cost-report-1.html",
+		"s3://reports/finops/daily/parquet/costs.parquet",
+	}
+	if !reflect.DeepEqual(published, want) {
+		t.Errorf("PublishDir() = %v, want %v", published, want)
+	}
+	if got := bucket.bodies["finops/daily/cost-report-1.html"]; got != "<html></html>" {
+		t.Errorf("html body = %q", got)
+	}
+	if got := bucket.types["finops/daily/cost-report-1.html"]; got != "text/html; charset=utf-8" {
+		t.Errorf("html content type = %q, want text/html", got)
+	}
+	if got := bucket.types["finops/daily/parquet/costs.parquet"]; got != "application/octet-stream" {
+		t.Errorf("parquet content type = %q, want application/octet-stream", got)
+	}
+
+	// A failed upload is reported and the rest still published
+	bucket = newMemBucket()
+	bucket.fail["cost-report-1.csv"] = true
+	published, err = NewWithBucket(bucket, "gs://reports", "", 0).PublishDir(context.Background(), dir, since)
+	if err == nil || !strings.Contains(err.Error(), "cost-report-1.csv") || len(published) != 2 {
+		t.Errorf("PublishDir() = %v, %v; want two published and the CSV's error", published, err)
+	}
+
+	// Without an output directory nothing was written
+	if published, err := p.PublishDir(context.Background(), filepath.Join(dir, "missing"), since); published != nil || err != nil {
+		t.Errorf("PublishDir(missing) = %v, %v; want nothing", published, err)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	bucket := newMemBucket()
+	for key, age := range map[string]time.Duration{
+		"reports/new.html":      time.Hour,
+		"reports/edge.html":     30 * 24 * time.Hour,
+		"reports/old.html":      31 * 24 * time.Hour,
+		"reports/locked.html":   40 * 24 * time.Hour,
+		"reports/unknown.html":  -1,
+		"elsewhere/old.html":    90 * 24 * time.Hour,
+		"reports/archive/x.csv": 60 * 24 * time.Hour,
+	} {
+		o := Object{Key: key}
+		if age >= 0 {
+			o.Modified = now.Add(-age)
+		}
+		bucket.objects[key] = o
+	}
+	bucket.fail["reports/locked.html"] = true
+
+	if n, err := NewWithBucket(bucket, "s3://b", "reports", 0).Prune(context.Background(), now); n != 0 || err != nil {
+		t.Errorf("Prune() = %d, %v without retention, want nothing deleted", n, err)
+	}
+
+	n, err := NewWithBucket(bucket, "s3://b", "reports", 30*24*time.Hour).Prune(context.Background(), now)
+	if n != 2 || err == nil || !strings.Contains(err.Error(), "locked.html") {
+		t.Errorf("Prune() = %d, %v; want 2 deleted and locked.html's error", n, err)
+	}
+	want := []string{"elsewhere/old.html", "reports/edge.html", "reports/locked.html", "reports/new.html", "reports/unknown.html"}
+	if got := bucket.keys(); !reflect.DeepEqual(got, want) {
+		t.Errorf("kept %v, want %v", got, want)
+	}
+}
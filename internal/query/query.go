@@ -0,0 +1,427 @@
+// Package query parses filter expressions that scope cost records, e.g.
+//
+//	cloud = "aws" AND tag.env != "prod" AND cost > 100
+//
+// Comparisons are joined with AND, OR and NOT (AND binds tighter than OR)
+// and grouped with parentheses. Fields:
+//
+//	cloud (or provider), account, region, service, cloud_service, resource,
+//	charge_type, pricing_model, currency, date (YYYY-MM-DD), tag.KEY
+//	cost, usage (numbers)
+//
+// Operators are = != < <= > >= on any field, =~ and !~ matching a regular
+// expression on text fields, and IN / NOT IN a parenthesized list. Text
+// compares exactly and orders lexically, so date ranges work as text; a
+// missing tag is "". Values are quoted with " or ', or bare words and
+// numbers. Keywords are case-insensitive.
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+// Filter is a compiled filter expression
+type Filter struct {
+	src  string
+	root node
+}
+
+// Parse compiles a filter expression
+func Parse(s string) (*Filter, error) {
+	tokens, err := lex(s)
+	if err != nil {
+		return nil, fmt.Errorf("filter %q: %w", s, err)
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.or()
+	if err == nil && p.peek().kind != tokEOF {
+		err = p.errorf("unexpected %s", p.peek())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("filter %q: %w", s, err)
+	}
+	return &Filter{src: s, root: root}, nil
+}
+
+// String returns the expression source
+func (f *Filter) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.src
+}
+
+// Match reports whether a record passes the filter; a nil filter passes
+// everything
+func (f *Filter) Match(r normalizer.CostRecord) bool {
+	return f == nil || f.root.match(&r)
+}
+
+// Select returns the records that pass the filter, all of them for a nil
+// filter
+func (f *Filter) Select(records []normalizer.CostRecord) []normalizer.CostRecord {
+	if f == nil {
+		return records
+	}
+	kept := make([]normalizer.CostRecord, 0, len(records))
+	for i := range records {
+		if f.root.match(&records[i]) {
+			kept = append(kept, records[i])
+		}
+	}
+	return kept
+}
+
+type node interface {
+	match(r *normalizer.CostRecord) bool
+}
+
+type andNode struct{ left, right node }
+type orNode struct{ left, right node }
+type notNode struct{ inner node }
+
+func (n andNode) match(r *normalizer.CostRecord) bool { return n.left.match(r) && n.right.match(r) }
+func (n orNode) match(r *normalizer.CostRecord) bool  { return n.left.match(r) || n.right.match(r) }
+func (n notNode) match(r *normalizer.CostRecord) bool { return !n.inner.match(r) }
+
+// field reads one value of a record, as text or as a number
+type field struct {
+	text   func(r *normalizer.CostRecord) string
+	number func(r *normalizer.CostRecord) float64
+}
+
+var fields = map[string]field{
+	"cloud":         {text: func(r *normalizer.CostRecord) string { return r.Cloud }},
+	"provider":      {text: func(r *normalizer.CostRecord) string { return r.Cloud }},
+	"account":       {text: func(r *normalizer.CostRecord) string { return r.Account }},
+	"region":        {text: func(r *normalizer.CostRecord) string { return r.Region }},
+	"service":       {text: func(r *normalizer.CostRecord) string { return r.Service }},
+	"cloud_service": {text: func(r *normalizer.CostRecord) string { return r.CloudService }},
+	"resource":      {text: func(r *normalizer.CostRecord) string { return r.Resource }},
+	"charge_type":   {text: func(r *normalizer.CostRecord) string { return string(r.Charge()) }},
+	"pricing_model": {text: func(r *normalizer.CostRecord) string { return r.PricingModel }},
+	"currency":      {text: func(r *normalizer.CostRecord) string { return r.Currency }},
+	"date":          {text: func(r *normalizer.CostRecord) string { return r.Date.UTC().Format("2006-01-02") }},
+	"cost":          {number: func(r *normalizer.CostRecord) float64 { return r.Cost }},
+	"usage":         {number: func(r *normalizer.CostRecord) float64 { return r.UsageQuantity }},
+}
+
+// lookup resolves a field name, including tag.KEY
+func lookup(name string) (field, bool) {
+	if key, ok := strings.CutPrefix(name, "tag."); ok && key != "" {
+		return field{text: func(r *normalizer.CostRecord) string { return r.Tags[key] }}, true
+	}
+	f, ok := fields[strings.ToLower(name)]
+	return f, ok
+}
+
+// compare is one comparison of a field with a value or a list of values
+type compare struct {
+	field   field
+	op      string // = != < <= > >= =~ !~ in
+	texts   []string
+	numbers []float64
+	re      *regexp.Regexp
+}
+
+func (c compare) match(r *normalizer.CostRecord) bool {
+	if c.field.number != nil {
+		v := c.field.number(r)
+		switch c.op {
+		case "in":
+			for _, n := range c.numbers {
+				if v == n {
+					return true
+				}
+			}
+			return false
+		}
+		return ordered(c.op, v, c.numbers[0])
+	}
+
+	v := c.field.text(r)
+	switch c.op {
+	case "=~":
+		return c.re.MatchString(v)
+	case "!~":
+		return !c.re.MatchString(v)
+	case "in":
+		for _, t := range c.texts {
+			if v == t {
+				return true
+			}
+		}
+		return false
+	}
+	return ordered(c.op, v, c.texts[0])
+}
+
+func ordered[T float64 | string](op string, a, b T) bool {
+	switch op {
+	case "=":
+		return a == b
+	case "!=":
+		return a != b
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	default: // >=
+		return a >= b
+	}
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token { return p.tokens[p.pos] }
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// keyword consumes the next token if it is the given keyword
+func (p *parser) keyword(kw string) bool {
+	if t := p.peek(); t.kind == tokWord && !t.quoted && strings.EqualFold(t.text, kw) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("at offset %d: %s", p.peek().pos, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) or() (node, error) {
+	left, err := p.and()
+	for err == nil && p.keyword("or") {
+		var right node
+		if right, err = p.and(); err == nil {
+			left = orNode{left, right}
+		}
+	}
+	return left, err
+}
+
+func (p *parser) and() (node, error) {
+	left, err := p.unary()
+	for err == nil && p.keyword("and") {
+		var right node
+		if right, err = p.unary(); err == nil {
+			left = andNode{left, right}
+		}
+	}
+	return left, err
+}
+
+func (p *parser) unary() (node, error) {
+	if p.keyword("not") {
+		inner, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{inner}, nil
+	}
+	if p.peek().text == "(" && p.peek().kind == tokPunct {
+		p.next()
+		inner, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.peek(); t.kind != tokPunct || t.text != ")" {
+			return nil, p.errorf("missing ')'")
+		}
+		p.next()
+		return inner, nil
+	}
+	return p.comparison()
+}
+
+func (p *parser) comparison() (node, error) {
+	name := p.peek()
+	if name.kind != tokWord || name.quoted {
+		return nil, p.errorf("expected a field, got %s", name)
+	}
+	p.next()
+	f, ok := lookup(name.text)
+	if !ok {
+		return nil, fmt.Errorf("at offset %d: unknown field %q", name.pos, name.text)
+	}
+
+	c := compare{field: f}
+	negate := false
+	switch t := p.peek(); {
+	case t.kind == tokOp:
+		p.next()
+		c.op = t.text
+		if c.op == "==" {
+			c.op = "="
+		}
+	case p.keyword("in"):
+		c.op = "in"
+	case p.keyword("not"):
+		if !p.keyword("in") {
+			return nil, p.errorf("expected IN after NOT")
+		}
+		c.op, negate = "in", true
+	default:
+		return nil, p.errorf("expected an operator after %s, got %s", name.text, t)
+	}
+	if f.number != nil && (c.op == "=~" || c.op == "!~") {
+		return nil, fmt.Errorf("at offset %d: %s is a number and cannot match a pattern", name.pos, name.text)
+	}
+
+	var values []token
+	if c.op == "in" {
+		if t := p.peek(); t.kind != tokPunct || t.text != "(" {
+			return nil, p.errorf("expected '(' after IN")
+		}
+		p.next()
+		for {
+			v := p.peek()
+			if v.kind != tokWord {
+				return nil, p.errorf("expected a value in the IN list, got %s", v)
+			}
+			values = append(values, p.next())
+			if t := p.peek(); t.kind != tokPunct || t.text != "," && t.text != ")" {
+				return nil, p.errorf("expected ',' or ')' in the IN list")
+			}
+			if p.next().text == ")" {
+				break
+			}
+		}
+	} else {
+		v := p.peek()
+		if v.kind != tokWord {
+			return nil, p.errorf("expected a value after %s %s, got %s", name.text, c.op, v)
+		}
+		values = append(values, p.next())
+	}
+
+	for _, v := range values {
+		if f.number == nil {
+			c.texts = append(c.texts, v.text)
+			continue
+		}
+		n, err := strconv.ParseFloat(v.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("at offset %d: %s compares with a number, not %q", v.pos, name.text, v.text)
+		}
+		c.numbers = append(c.numbers, n)
+	}
+	if c.op == "=~" || c.op == "!~" {
+		re, err := regexp.Compile(c.texts[0])
+		if err != nil {
+			return nil, fmt.Errorf("at offset %d: invalid pattern: %w", values[0].pos, err)
+		}
+		c.re = re
+	}
+
+	if negate {
+		return notNode{c}, nil
+	}
+	return c, nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF   tokenKind = iota
+	tokWord            // field, keyword or value
+	tokOp              // comparison operator
+	tokPunct           // ( ) ,
+)
+
+type token struct {
+	kind   tokenKind
+	text   string
+	quoted bool // a quoted value, never a field or keyword
+	pos    int
+}
+
+func (t token) String() string {
+	switch {
+	case t.kind == tokEOF:
+		return "end of filter"
+	case t.quoted:
+		return strconv.Quote(t.text)
+	}
+	return "'" + t.text + "'"
+}
+
+// lex splits an expression into tokens
+func lex(s string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(' || c == ')' || c == ',':
+			tokens = append(tokens, token{kind: tokPunct, text: string(c), pos: i})
+			i++
+		case c == '"' || c == '\'':
+			end := i + 1
+			for end < len(s) && s[end] != c {
+				if s[end] == '\\' && c == '"' {
+					end++
+				}
+				end++
+			}
+			if end >= len(s) {
+				return nil, fmt.Errorf("at offset %d: unterminated string", i)
+			}
+			text := s[i+1 : end]
+			if c == '"' {
+				var err error
+				if text, err = strconv.Unquote(s[i : end+1]); err != nil {
+					return nil, fmt.Errorf("at offset %d: invalid string: %w", i, err)
+				}
+			}
+			tokens = append(tokens, token{kind: tokWord, text: text, quoted: true, pos: i})
+			i = end + 1
+		case strings.ContainsRune("=!<>", rune(c)):
+			op := string(c)
+			if i+1 < len(s) && (s[i+1] == '=' || s[i+1] == '~' && (c == '=' || c == '!')) {
+				op += string(s[i+1])
+			}
+			if op == "!" {
+				return nil, fmt.Errorf("at offset %d: unknown operator '!' (want != or !~)", i)
+			}
+			tokens = append(tokens, token{kind: tokOp, text: op, pos: i})
+			i += len(op)
+		default:
+			end := i
+			for end < len(s) && isWordByte(s[end]) {
+				end++
+			}
+			if end == i {
+				return nil, fmt.Errorf("at offset %d: unexpected %q", i, c)
+			}
+			tokens = append(tokens, token{kind: tokWord, text: s[i:end], pos: i})
+			i = end
+		}
+	}
+	return append(tokens, token{kind: tokEOF, pos: len(s)}), nil
+}
+
+// isWordByte reports whether c can appear in a bare word: field names,
+// tag keys, numbers, dates and identifiers such as account IDs or ARNs
+func isWordByte(c byte) bool {
+	return c >= 0x80 || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c)) || strings.IndexByte("_.-:/+@*", c) >= 0
+}
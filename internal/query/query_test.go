@@ -0,0 +1,116 @@
+package query
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+// records are named by their resource
+var records = []normalizer.CostRecord{
+	{Cloud: "aws", Account: "111", Region: "us-east-1", Service: "EC2", Resource: "web-1", Cost: 150, UsageQuantity: 24,
+		Date: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), Tags: map[string]string{"env": "prod", "team": "web"}},
+	{Cloud: "aws", Account: "222", Region: "eu-west-1", Service: "S3", Resource: "logs", Cost: 40,
+		Date: time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC), Tags: map[string]string{"env": "dev"}},
+	{Cloud: "gcp", Account: "proj-1", Region: "us-central1", Service: "BigQuery", Resource: "warehouse", Cost: 250,
+		Date: time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC), Tags: map[string]string{"env": "staging", "team": "data"}},
+	{Cloud: "aws", Account: "111", Service: "EC2", Resource: "credit", Cost: -20, LineItemType: "Credit",
+		Date: time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)},
+}
+
+// matching lists the resources of the records matching a filter
+func matching(f *Filter) []string {
+	var names []string
+	for _, r := range f.Select(records) {
+		names = append(names, r.Resource)
+	}
+	return names
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{`cloud = "aws" AND tag.env != "prod" AND cost > 10`, []string{"logs"}},
+		{`provider == gcp`, []string{"warehouse"}},
+		{`cost >= 150 OR account = 222`, []string{"web-1", "logs", "warehouse"}},
+		{`cost < 0`, []string{"credit"}},
+		{`usage <= 24 and usage > 0`, []string{"web-1"}},
+		{`cloud = aws AND service = EC2 OR cloud = gcp`, []string{"web-1", "warehouse", "credit"}},
+		{`cloud = aws AND (service = S3 OR cost < 0)`, []string{"logs", "credit"}},
+		{`NOT cloud = aws`, []string{"warehouse"}},
+		{`not (tag.team = web or tag.team = data)`, []string{"logs", "credit"}},
+		{`tag.team = ""`, []string{"logs", "credit"}},
+		{`region =~ "^us-"`, []string{"web-1", "warehouse"}},
+		{`resource !~ '^w'`, []string{"logs", "credit"}},
+		{`service IN (EC2, "BigQuery")`, []string{"web-1", "warehouse", "credit"}},
+		{`tag.env NOT IN ('prod', 'dev')`, []string{"warehouse", "credit"}},
+		{`cost in (40, 250)`, []string{"logs", "warehouse"}},
+		{`date >= 2024-03-02 AND date < 2024-03-31`, []string{"logs", "warehouse"}},
+		{`charge_type = credit`, []string{"credit"}},
+		{`account = "111" AND region = ""`, []string{"credit"}},
+	}
+	for _, tt := range tests {
+		f, err := Parse(tt.expr)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := matching(f); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s matches %v, want %v", tt.expr, got, tt.want)
+		}
+		if f.String() != tt.expr {
+			t.Errorf("String() = %q, want %q", f.String(), tt.expr)
+		}
+	}
+}
+
+func TestNilFilter(t *testing.T) {
+	var f *Filter
+	if !f.Match(records[0]) || len(f.Select(records)) != len(records) || f.String() != "" {
+		t.Error("a nil filter does not pass everything")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		expr, want string
+	}{
+		{``, "at offset 0: expected a field, got end of filter"},
+		{`cloud`, "at offset 5: expected an operator after cloud, got end of filter"},
+		{`owner = web`, `at offset 0: unknown field "owner"`},
+		{`tag. = web`, `at offset 0: unknown field "tag."`},
+		{`cloud = `, "at offset 8: expected a value after cloud =, got end of filter"},
+		{`cloud = aws AND`, "at offset 15: expected a field, got end of filter"},
+		{`cloud = aws aws`, "at offset 12: unexpected 'aws'"},
+		{`(cloud = aws`, "at offset 12: missing ')'"},
+		{`(cloud = aws aws)`, "at offset 13: missing ')'"},
+		{`cloud = )`, "at offset 8: expected a value after cloud =, got ')'"},
+		{`cost > lots`, `at offset 7: cost compares with a number, not "lots"`},
+		{`cost =~ 1`, "at offset 0: cost is a number and cannot match a pattern"},
+		{`region =~ "(us"`, "at offset 10: invalid pattern"},
+		{`cloud NOT aws`, "at offset 10: expected IN after NOT"},
+		{`cloud IN aws`, "at offset 9: expected '(' after IN"},
+		{`cloud IN (aws gcp)`, "at offset 14: expected ',' or ')' in the IN list"},
+		{`cloud IN (aws, )`, "at offset 15: expected a value in the IN list, got ')'"},
+		{`"cloud" = aws`, `at offset 0: expected a field, got "cloud"`},
+		{`cloud = "aws`, "at offset 8: unterminated string"},
+		{`cloud ! aws`, "at offset 6: unknown operator '!'"},
+		{`cloud = aws; drop`, "at offset 11: unexpected ';'"},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.expr)
+		if err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", tt.expr)
+			continue
+		}
+		if prefix := fmt.Sprintf("filter %q: ", tt.expr); !strings.HasPrefix(err.Error(), prefix) || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Parse(%q) = %v, want %s", tt.expr, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+// Package recommendations collects rightsizing recommendations from the
+// clouds' advisors and ranks them by the savings they would bring
+package recommendations
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+// Recommendation sources
+const (
+	SourceComputeOptimizer = "compute_optimizer" // AWS Compute Optimizer
+	SourceCostExplorer     = "cost_explorer"     // AWS Cost Explorer rightsizing
+	SourceAdvisor          = "advisor"           // Azure Advisor cost recommendations
+	SourceRecommender      = "recommender"       // GCP Recommender
+)
+
+// Recommended actions
+const (
+	ActionModify    = "modify"    // change the type, size or configuration
+	ActionTerminate = "terminate" // idle, remove it
+	ActionPurchase  = "purchase"  // buy a reservation, savings plan or committed use discount
+)
+
+// Recommendation is one resource's best rightsizing option, in monthly cost.
+// CurrentCost and ProjectedCost are zero when the source only estimates the
+// savings.
+type Recommendation struct {
+	Provider          string  `json:"provider"`
+	Source            string  `json:"source"`
+	Account           string  `json:"account"`
+	Region            string  `json:"region"`
+	ResourceID        string  `json:"resource_id"`
+	ResourceType      string  `json:"resource_type"` // e.g. EC2 instance, EBS volume, Lambda function
+	Finding           string  `json:"finding"`       // e.g. Overprovisioned, Idle
+	Action            string  `json:"action"`
+	Current           string  `json:"current"`     // current instance type or configuration
+	Recommended       string  `json:"recommended"` // empty for terminations
+	CurrentCost       float64 `json:"current_cost"`
+	ProjectedCost     float64 `json:"projected_cost"`
+	Savings           float64 `json:"savings"`
+	SavingsPercent    float64 `json:"savings_percent"`
+	Currency          string  `json:"currency"`
+	AlsoRecommendedBy string  `json:"also_recommended_by,omitempty"` // another source with the same resource
+}
+
+// Source is implemented by providers that offer rightsizing recommendations
+type Source interface {
+	GetRecommendations(ctx context.Context) ([]Recommendation, error)
+}
+
+// Options filters collected recommendations
+type Options struct {
+	Sources    map[string]bool // empty for every source
+	MinSavings float64         // monthly savings below this are left out
+}
+
+// OptionsFrom builds the recommendations configuration
+func OptionsFrom(cfg config.RecommendationsConfig) (Options, error) {
+	opts := Options{MinSavings: cfg.MinSavings}
+	if cfg.MinSavings < 0 {
+		return Options{}, fmt.Errorf("min_savings must not be negative")
+	}
+	for _, s := range cfg.Sources {
+		switch s {
+		case SourceComputeOptimizer, SourceCostExplorer, SourceAdvisor, SourceRecommender:
+		default:
+			return Options{}, fmt.Errorf("unknown source %q (want compute_optimizer, cost_explorer, advisor or recommender)", s)
+		}
+		if opts.Sources == nil {
+			opts.Sources = make(map[string]bool)
+		}
+		opts.Sources[s] = true
+	}
+	return opts, nil
+}
+
+// Uses reports whether a source is selected
+func (o Options) Uses(source string) bool {
+	return len(o.Sources) == 0 || o.Sources[source]
+}
+
+// Collect gathers the recommendations of every source, keyed by provider
+// name. A failing source is reported in errs and the others still count.
+func Collect(ctx context.Context, sources map[string]Source, opts Options) (recs []Recommendation, errs map[string]error) {
+	errs = make(map[string]error)
+	for name, src := range sources {
+		found, err := src.GetRecommendations(ctx)
+		if err != nil {
+			errs[name] = err
+		}
+		recs = append(recs, found...)
+	}
+	return Rank(recs, opts), errs
+}
+
+// Rank merges recommendations for the same resource from several sources,
+// keeping the larger savings, drops those under the minimum savings and
+// sorts the rest by savings, largest first
+func Rank(recs []Recommendation, opts Options) []Recommendation {
+	byResource := make(map[string]int)
+	merged := make([]Recommendation, 0, len(recs))
+	for _, r := range recs {
+		if !opts.Uses(r.Source) {
+			continue
+		}
+		key := r.Provider + "|" + r.Account + "|" + strings.ToLower(r.ResourceID)
+		i, ok := byResource[key]
+		if !ok {
+			byResource[key] = len(merged)
+			merged = append(merged, r)
+			continue
+		}
+		kept := merged[i]
+		if r.Savings > kept.Savings {
+			r, kept = kept, r
+		}
+		if r.Source != kept.Source {
+			kept.AlsoRecommendedBy = r.Source
+		}
+		merged[i] = kept
+	}
+
+	ranked := make([]Recommendation, 0, len(merged))
+	for _, r := range merged {
+		if r.Savings > 0 && r.Savings >= opts.MinSavings {
+			ranked = append(ranked, r)
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].Savings != ranked[j].Savings {
+			return ranked[i].Savings > ranked[j].Savings
+		}
+		return ranked[i].ResourceID < ranked[j].ResourceID
+	})
+	return ranked
+}
+
+// TotalSavings sums the monthly savings of recommendations
+func TotalSavings(recs []Recommendation) float64 {
+	var total float64
+	for _, r := range recs {
+		total += r.Savings
+	}
+	return total
+}
+
+// SaveCSV writes one row per recommendation
+func SaveCSV(path string, recs []Recommendation) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	header := []string{"Provider", "Source", "Account", "Region", "Resource", "Resource Type", "Finding", "Action",
+		"Current", "Recommended", "Current Monthly Cost", "Projected Monthly Cost", "Monthly Savings", "Savings %", "Currency"}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, r := range recs {
+		row := []string{
+			r.Provider,
+			r.Source,
+			r.Account,
+			r.Region,
+			r.ResourceID,
+			r.ResourceType,
+			r.Finding,
+			r.Action,
+			r.Current,
+			r.Recommended,
+			fmt.Sprintf("%.2f", r.CurrentCost),
+			fmt.Sprintf("%.2f", r.ProjectedCost),
+			fmt.Sprintf("%.2f", r.Savings),
+			fmt.Sprintf("%.1f", r.SavingsPercent),
+			r.Currency,
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SaveJSON writes the recommendations as a JSON array
+func SaveJSON(path string, recs []Recommendation) error {
+	data, err := json.MarshalIndent(recs, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,137 @@
+package recommendations
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+// rec returns an AWS recommendation for a resource
+func rec(source, resourceID string, savings float64) Recommendation {
+	return Recommendation{Provider: "aws", Source: source, Account: "111", ResourceID: resourceID, Savings: savings}
+}
+
+// ranked lists recommendations as source/resource/also
+func ranked(recs []Recommendation) []string {
+	var out []string
+	for _, r := range recs {
+		out = append(out, r.Source+"/"+r.ResourceID+"/"+r.AlsoRecommendedBy)
+	}
+	return out
+}
+
+// fakeSource returns fixed recommendations and error
+type fakeSource struct {
+	recs []Recommendation
+	err  error
+}
+
+func (s fakeSource) GetRecommendations(ctx context.Context) ([]Recommendation, error) {
+	return s.recs, s.err
+}
+
+func TestOptionsFrom(t *testing.T) {
+	opts, err := OptionsFrom(config.RecommendationsConfig{Sources: []string{SourceCostExplorer}, MinSavings: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.MinSavings != 5 || !opts.Uses(SourceCostExplorer) || opts.Uses(SourceComputeOptimizer) {
+		t.Errorf("OptionsFrom() = %+v, want only cost_explorer from 5", opts)
+	}
+	if opts, _ := OptionsFrom(config.RecommendationsConfig{}); !opts.Uses(SourceComputeOptimizer) || !opts.Uses(SourceCostExplorer) {
+		t.Errorf("OptionsFrom() = %+v, want every source by default", opts)
+	}
+
+	for name, cfg := range map[string]config.RecommendationsConfig{
+		"negative min_savings": {MinSavings: -1},
+		"unknown source":       {Sources: []string{"trusted_advisor"}},
+	} {
+		if _, err := OptionsFrom(cfg); err == nil {
+			t.Errorf("%s: OptionsFrom() succeeded, want an error", name)
+		}
+	}
+}
+
+func TestRank(t *testing.T) {
+	recs := []Recommendation{
+		rec(SourceComputeOptimizer, "i-1", 30),
+		rec(SourceCostExplorer, "I-1", 40),
+		rec(SourceComputeOptimizer, "i-2", 40),
+		rec(SourceComputeOptimizer, "vol-1", 2),
+		rec(SourceCostExplorer, "i-3", 0),
+		rec(SourceCostExplorer, "i-4", 10),
+		rec(SourceCostExplorer, "i-4", 5),
+	}
+	tests := []struct {
+		name string
+		opts Options
+		want []string
+	}{
+		{"all", Options{}, []string{
+			"cost_explorer/I-1/compute_optimizer", "compute_optimizer/i-2/", "cost_explorer/i-4/", "compute_optimizer/vol-1/",
+		}},
+		{"min savings", Options{MinSavings: 10}, []string{
+			"cost_explorer/I-1/compute_optimizer", "compute_optimizer/i-2/", "cost_explorer/i-4/",
+		}},
+		{"one source", Options{Sources: map[string]bool{SourceComputeOptimizer: true}}, []string{
+			"compute_optimizer/i-2/", "compute_optimizer/i-1/", "compute_optimizer/vol-1/",
+		}},
+	}
+	for _, tt := range tests {
+		if got := ranked(Rank(recs, tt.opts)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Rank() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCollect(t *testing.T) {
+	denied := errors.New("access denied")
+	recs, errs := Collect(context.Background(), map[string]Source{
+		"aws":   fakeSource{recs: []Recommendation{rec(SourceComputeOptimizer, "i-1", 10), rec(SourceCostExplorer, "i-2", 20)}},
+		"other": fakeSource{err: denied},
+	}, Options{})
+
+	if want := []string{"cost_explorer/i-2/", "compute_optimizer/i-1/"}; !reflect.DeepEqual(ranked(recs), want) {
+		t.Errorf("Collect() = %v, want %v", ranked(recs), want)
+	}
+	if len(errs) != 1 || errs["other"] != denied {
+		t.Errorf("errs = %v, want other's error only", errs)
+	}
+	if got := TotalSavings(recs); got != 30 {
+		t.Errorf("TotalSavings() = %v, want 30", got)
+	}
+}
+
+func TestSaveCSV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recommendations.csv")
+	r := Recommendation{Provider: "aws", Source: SourceComputeOptimizer, Account: "111", Region: "us-east-1", ResourceID: "i-1",
+		ResourceType: "EC2 instance", Finding: "Overprovisioned", Action: ActionModify, Current: "m5.xlarge", Recommended: "t3.large",
+		CurrentCost: 120, ProjectedCost: 90, Savings: 30, SavingsPercent: 25, Currency: "USD"}
+	if err := SaveCSV(path, []Recommendation{r}); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || len(rows[0]) != 15 {
+		t.Fatalf("got %d rows of %d columns, want a header and one row of 15", len(rows), len(rows[0]))
+	}
+	want := []string{"aws", "compute_optimizer", "111", "us-east-1", "i-1", "EC2 instance", "Overprovisioned", "modify",
+		"m5.xlarge", "t3.large", "120.00", "90.00", "30.00", "25.0", "USD"}
+	if !reflect.DeepEqual(rows[1], want) {
+		t.Errorf("row = %v, want %v", rows[1], want)
+	}
+}
@@ -0,0 +1,410 @@
+// Package release relates deployment and release markers to cost changes.
+package release
+
+import (
+	"crypto/hmac"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/anomaly"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+	"github.com/lvonguyen/finops-platform/internal/notify"
+)
+
+// Marker is a deployment or release affecting a scope. Empty scope fields
+// match anything.
+type Marker struct {
+	Label   string    `json:"label"`
+	Cloud   string    `json:"cloud,omitempty"`
+	Account string    `json:"account,omitempty"`
+	Service string    `json:"service,omitempty"`
+	At      time.Time `json:"at"`
+}
+
+// NewMarker builds a marker for a "cloud/account/service" scope
+func NewMarker(label, scope string, at time.Time) (Marker, error) {
+	if label == "" {
+		return Marker{}, fmt.Errorf("release marker has no label")
+	}
+	if scope == "" {
+		scope = "*"
+	}
+	s, err := anomaly.ParseScope(scope)
+	if err != nil {
+		return Marker{}, err
+	}
+	return Marker{Label: label, Cloud: s.Cloud, Account: s.Account, Service: s.Service, At: at}, nil
+}
+
+// Scope returns the marker's scope as cloud/account/service
+func (m Marker) Scope() string {
+	return anomaly.Change{Cloud: m.Cloud, Account: m.Account, Service: m.Service}.Scope()
+}
+
+// Day returns the start of the marker's day in UTC, matching daily cost data
+func (m Marker) Day() time.Time {
+	return day(m.At.UTC())
+}
+
+// Matches reports whether a cost record is in the marker's scope
+func (m Marker) Matches(r normalizer.CostRecord) bool {
+	return field(m.Cloud, r.Cloud) && field(m.Account, r.Account) && field(m.Service, r.Service)
+}
+
+// Overlaps reports whether two markers' scopes share any costs
+func (m Marker) Overlaps(o Marker) bool {
+	return overlap(m.Cloud, o.Cloud) && overlap(m.Account, o.Account) && overlap(m.Service, o.Service)
+}
+
+func overlap(a, b string) bool {
+	return a == "" || b == "" || a == b
+}
+
+func field(scope, value string) bool {
+	return scope == "" || scope == value
+}
+
+// Load reads recorded markers, oldest first. A missing file means none.
+func Load(path string) ([]Marker, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read release markers: %w", err)
+	}
+
+	var markers []Marker
+	if err := json.Unmarshal(data, &markers); err != nil {
+		return nil, fmt.Errorf("failed to parse release markers: %w", err)
+	}
+	sort.SliceStable(markers, func(i, j int) bool {
+		return markers[i].At.Before(markers[j].At)
+	})
+	return markers, nil
+}
+
+// Record appends a marker to the file. Re-sending a marker with the same
+// label, scope and time is a no-op, so webhook retries are safe.
+func Record(path string, m Marker) error {
+	markers, err := Load(path)
+	if err != nil {
+		return err
+	}
+	for _, existing := range markers {
+		if existing.Label == m.Label && existing.Scope() == m.Scope() && existing.At.Equal(m.At) {
+			return nil
+		}
+	}
+	markers = append(markers, m)
+
+	data, err := json.MarshalIndent(markers, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode release markers: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create release markers directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write release markers: %w", err)
+	}
+	return nil
+}
+
+// webhookRequest is the body accepted by Handler
+type webhookRequest struct {
+	Label string    `json:"label"`
+	Scope string    `json:"scope"` // cloud/account/service, empty for everything
+	At    time.Time `json:"at"`    // defaults to the time received
+}
+
+// maxWebhookBody is the largest request body Handler reads
+const maxWebhookBody = 64 << 10
+
+// signatureMaxAge is how far a signed request's timestamp may be from the
+// time it is received, limiting replays
+const signatureMaxAge = 5 * time.Minute
+
+// Handler returns an HTTP handler that records markers POSTed as JSON
+// ({"label": "v1.4.2", "scope": "aws/123456789012/AmazonEC2", "at": "..."})
+// to the file at path. Requests are signed with secret the way notify signs
+// webhook deliveries: X-FinOps-Timestamp holds the Unix time, and
+// X-FinOps-Signature "sha256=" and the hex HMAC-SHA256 of the timestamp, a
+// dot and the body. Without a secret every request is refused.
+func Handler(path string, secret []byte) http.Handler {
+	var mu sync.Mutex
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if len(secret) == 0 {
+			http.Error(w, "release webhook has no secret configured", http.StatusForbidden)
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, fmt.Sprintf("body exceeds %d bytes", maxWebhookBody), http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, "failed to read body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := verify(secret, r.Header, body, time.Now()); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		var req webhookRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.At.IsZero() {
+			req.At = time.Now().UTC()
+		}
+		m, err := NewMarker(req.Label, req.Scope, req.At)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		mu.Lock()
+		err = Record(path, m)
+		mu.Unlock()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	})
+}
+
+// verify checks a request's signature, and that it was signed within
+// signatureMaxAge of now
+func verify(secret []byte, header http.Header, body []byte, now time.Time) error {
+	ts := header.Get(notify.WebhookTimestampHeader)
+	sig, ok := strings.CutPrefix(header.Get(notify.WebhookSignatureHeader), "sha256=")
+	if ts == "" || !ok {
+		return errors.New("request is not signed")
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid signature timestamp %q", ts)
+	}
+	if age := now.Sub(time.Unix(unix, 0)); age > signatureMaxAge || age < -signatureMaxAge {
+		return fmt.Errorf("signature timestamp is more than %s from now", signatureMaxAge)
+	}
+	if !hmac.Equal([]byte(sig), []byte(notify.Sign(secret, ts, body))) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+// Impact is the change in daily cost around a release in its scope
+type Impact struct {
+	Marker
+	PreDays      int      `json:"pre_days"`  // days averaged before the release day
+	PostDays     int      `json:"post_days"` // days averaged after it
+	PreDaily     float64  `json:"pre_daily"`
+	PostDaily    float64  `json:"post_daily"`
+	Delta        float64  `json:"delta"`                   // post minus pre daily cost
+	DeltaPercent float64  `json:"delta_percent,omitempty"` // relative to pre, 0 when pre is 0
+	Overlaps     []string `json:"overlaps,omitempty"`      // overlapping releases that clipped the windows
+	Anomalies    int      `json:"anomalies"`               // anomalies in scope from the release day to the end of the post window
+	Note         string   `json:"note,omitempty"`          // why the delta is inconclusive
+}
+
+// Correlated reports whether anomalies followed the release
+func (i Impact) Correlated() bool {
+	return i.Anomalies > 0
+}
+
+// Impacts compares the scope of each marker made since over window days
+// before and after its day, using records up to asOf. The release day itself
+// is left out since it mixes both. Windows stop short of other releases in
+// overlapping scopes so their effects are not attributed to each other; the
+// clipping releases are listed in Overlaps. Newest releases come first.
+func Impacts(markers []Marker, since time.Time, records []normalizer.CostRecord, anomalies []anomaly.Anomaly, window int, asOf time.Time) []Impact {
+	if len(records) == 0 {
+		return nil
+	}
+	first := records[0].Date
+	for _, r := range records {
+		if r.Date.Before(first) {
+			first = r.Date
+		}
+	}
+	first = day(first)
+	asOf = day(asOf)
+
+	impacts := make([]Impact, 0, len(markers))
+	for i, m := range markers {
+		d := m.Day()
+		if m.At.Before(since) || d.After(asOf) {
+			continue
+		}
+		preStart, postEnd := d.AddDate(0, 0, -window), d.AddDate(0, 0, window)
+		if preStart.Before(first) {
+			preStart = first
+		}
+		if postEnd.After(asOf) {
+			postEnd = asOf
+		}
+
+		impact := Impact{Marker: m}
+		var sameDay []string
+		for j, o := range markers {
+			if i == j || !m.Overlaps(o) {
+				continue
+			}
+			od := o.Day()
+			switch {
+			case od.Equal(d):
+				impact.Overlaps = append(impact.Overlaps, o.Label)
+				sameDay = append(sameDay, o.Label)
+			case od.Before(d) && !od.Before(preStart):
+				preStart = od.AddDate(0, 0, 1)
+				impact.Overlaps = append(impact.Overlaps, o.Label)
+			case od.After(d) && !od.After(postEnd):
+				postEnd = od.AddDate(0, 0, -1)
+				impact.Overlaps = append(impact.Overlaps, o.Label)
+			}
+		}
+
+		var pre, post float64
+		for _, r := range records {
+			if !m.Matches(r) {
+				continue
+			}
+			rd := day(r.Date)
+			switch {
+			case !rd.Before(preStart) && rd.Before(d):
+				pre += r.Cost
+			case rd.After(d) && !rd.After(postEnd):
+				post += r.Cost
+			}
+		}
+		impact.PreDays = days(preStart, d)
+		impact.PostDays = days(d.AddDate(0, 0, 1), postEnd.AddDate(0, 0, 1))
+		if impact.PreDays > 0 {
+			impact.PreDaily = pre / float64(impact.PreDays)
+		}
+		if impact.PostDays > 0 {
+			impact.PostDaily = post / float64(impact.PostDays)
+		}
+
+		var notes []string
+		if impact.PreDays == 0 {
+			notes = append(notes, "no days before the release")
+		}
+		if impact.PostDays == 0 {
+			notes = append(notes, "no days after the release yet")
+		}
+		if len(sameDay) > 0 {
+			notes = append(notes, "released the same day as "+strings.Join(sameDay, ", "))
+		}
+		impact.Note = strings.Join(notes, "; ")
+		if impact.PreDays > 0 && impact.PostDays > 0 {
+			impact.Delta = impact.PostDaily - impact.PreDaily
+			if impact.PreDaily != 0 {
+				impact.DeltaPercent = impact.Delta / impact.PreDaily * 100
+			}
+		}
+
+		// Anomalies from the release day through the (clipped) post window
+		last := postEnd
+		if last.Before(d) {
+			last = d
+		}
+		for _, a := range anomalies {
+			ad := day(a.Date)
+			if field(m.Cloud, a.Cloud) && field(m.Account, a.Account) && field(m.Service, a.Service) &&
+				!ad.Before(d) && !ad.After(last) {
+				impact.Anomalies++
+			}
+		}
+		impacts = append(impacts, impact)
+	}
+
+	sort.SliceStable(impacts, func(i, j int) bool {
+		return impacts[i].At.After(impacts[j].At)
+	})
+	return impacts
+}
+
+func day(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// days counts the whole days from start up to end
+func days(start, end time.Time) int {
+	if !end.After(start) {
+		return 0
+	}
+	return int(end.Sub(start).Hours() / 24)
+}
+
+// SaveCSV writes release impacts as a CSV file
+func SaveCSV(path string, impacts []Impact) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	header := []string{"Release", "At", "Scope", "Pre Days", "Pre Daily", "Post Days", "Post Daily", "Delta", "Delta %", "Anomalies", "Overlaps", "Note"}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, i := range impacts {
+		row := []string{
+			i.Label,
+			i.At.Format(time.RFC3339),
+			i.Scope(),
+			fmt.Sprintf("%d", i.PreDays),
+			fmt.Sprintf("%.2f", i.PreDaily),
+			fmt.Sprintf("%d", i.PostDays),
+			fmt.Sprintf("%.2f", i.PostDaily),
+			fmt.Sprintf("%.2f", i.Delta),
+			fmt.Sprintf("%.1f", i.DeltaPercent),
+			fmt.Sprintf("%d", i.Anomalies),
+			strings.Join(i.Overlaps, ";"),
+			i.Note,
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SaveJSON writes release impacts as a JSON file
+func SaveJSON(path string, impacts []Impact) error {
+	data, err := json.MarshalIndent(impacts, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	return nil
+}
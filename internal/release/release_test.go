@@ -0,0 +1,71 @@
+package release
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/notify"
+)
+
+var secret = []byte("webhook-secret")
+
+// signedRequest returns a release webhook request signed at a time
+func signedRequest(body string, key []byte, at time.Time) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/releases", strings.NewReader(body))
+	ts := strconv.FormatInt(at.Unix(), 10)
+	req.Header.Set(notify.WebhookTimestampHeader, ts)
+	req.Header.Set(notify.WebhookSignatureHeader, "sha256="+notify.Sign(key, ts, []byte(body)))
+	return req
+}
+
+func TestHandlerChecksSignature(t *testing.T) {
+	body := `{"label": "v1.4.2", "scope": "aws/123456789012/AmazonEC2", "at": "2024-03-01T12:00:00Z"}`
+	now := time.Now()
+	unsigned := httptest.NewRequest(http.MethodPost, "/releases", strings.NewReader(body))
+	// The signature of body on a different body
+	tampered := signedRequest(strings.Replace(body, "v1.4.2", "v9", 1), secret, now)
+	tampered.Header.Set(notify.WebhookSignatureHeader, signedRequest(body, secret, now).Header.Get(notify.WebhookSignatureHeader))
+
+	tests := []struct {
+		name    string
+		secret  []byte
+		req     *http.Request
+		want    int
+		markers int
+	}{
+		{"signed", secret, signedRequest(body, secret, now), http.StatusCreated, 1},
+		{"unsigned", secret, unsigned, http.StatusUnauthorized, 0},
+		{"wrong key", secret, signedRequest(body, []byte("other"), now), http.StatusUnauthorized, 0},
+		{"tampered body", secret, tampered, http.StatusUnauthorized, 0},
+		{"stale", secret, signedRequest(body, secret, now.Add(-time.Hour)), http.StatusUnauthorized, 0},
+		{"future", secret, signedRequest(body, secret, now.Add(time.Hour)), http.StatusUnauthorized, 0},
+		{"no secret configured", nil, signedRequest(body, nil, now), http.StatusForbidden, 0},
+		{"too large", secret, signedRequest(`{"label": "`+strings.Repeat("x", maxWebhookBody)+`"}`, secret, now), http.StatusRequestEntityTooLarge, 0},
+		{"signed but invalid", secret, signedRequest(`{"scope": "aws"}`, secret, now), http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "releases.json")
+			rec := httptest.NewRecorder()
+			Handler(path, tt.secret).ServeHTTP(rec, tt.req)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d (%s), want %d", rec.Code, strings.TrimSpace(rec.Body.String()), tt.want)
+			}
+			markers, err := Load(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(markers) != tt.markers {
+				t.Fatalf("recorded %d markers, want %d", len(markers), tt.markers)
+			}
+			if tt.markers > 0 && (markers[0].Label != "v1.4.2" || markers[0].Service != "AmazonEC2") {
+				t.Errorf("marker = %+v", markers[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,255 @@
+// Package reportdef turns the named report definitions of the config into
+// the command lines that write them, so the team's standard weekly and
+// monthly reports come out the same on every run
+package reportdef
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+	"github.com/lvonguyen/finops-platform/internal/calendar"
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/query"
+)
+
+// Period kinds of a command
+const (
+	byRange = iota // --start and --end
+	byMonth        // --month
+	byNone         // runs as of today
+)
+
+// command is a report-writing command a definition can run
+type command struct {
+	formats []string // the first is the command's default
+	period  int
+}
+
+var commands = map[string]command{
+	"aggregate":   {[]string{"html", "csv", "json", "markdown", "focus", "parquet", "arrow"}, byRange},
+	"chargeback":  {[]string{"csv", "json", "xlsx"}, byMonth},
+	"simulate":    {[]string{"csv", "json"}, byMonth},
+	"trend":       {[]string{"csv", "json"}, byMonth},
+	"tags":        {[]string{"csv", "json"}, byMonth},
+	"diff":        {[]string{"csv", "json"}, byRange},
+	"commitments": {[]string{"csv", "json"}, byRange},
+	"forecast":    {[]string{"csv", "json"}, byNone},
+	"recommend":   {[]string{"html", "csv", "json"}, byNone},
+}
+
+// Run is one run of a definition: the command line writing one format
+type Run struct {
+	Format string
+	Args   []string // the command and its flags
+}
+
+// Find returns the definition of a name
+func Find(defs []config.ReportDefinition, name string) (config.ReportDefinition, error) {
+	for _, def := range defs {
+		if def.Name == name {
+			return def, nil
+		}
+	}
+	names := make([]string, len(defs))
+	for i, def := range defs {
+		names[i] = def.Name
+	}
+	if len(names) == 0 {
+		return config.ReportDefinition{}, fmt.Errorf("unknown report %q: no reports are defined", name)
+	}
+	return config.ReportDefinition{}, fmt.Errorf("unknown report %q (have %s)", name, strings.Join(names, ", "))
+}
+
+// Validate checks every definition, reporting each problem
+func Validate(defs []config.ReportDefinition) error {
+	var errs []error
+	seen := make(map[string]bool)
+	for i, def := range defs {
+		if def.Name == "" {
+			errs = append(errs, fmt.Errorf("report %d has no name", i+1))
+			continue
+		}
+		if seen[def.Name] {
+			errs = append(errs, fmt.Errorf("report %q is defined twice", def.Name))
+		}
+		seen[def.Name] = true
+		if err := check(def); err != nil {
+			errs = append(errs, fmt.Errorf("report %q: %w", def.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// check validates a definition against its command
+func check(def config.ReportDefinition) error {
+	cmd, ok := commands[def.Command]
+	if !ok {
+		return fmt.Errorf("command %q cannot be run as a report (want %s)", def.Command, strings.Join(commandNames(), ", "))
+	}
+	for _, format := range def.Formats {
+		if !contains(cmd.formats, format) {
+			return fmt.Errorf("%s cannot write format %q (want %s)", def.Command, format, strings.Join(cmd.formats, ", "))
+		}
+	}
+	if def.Period != "" {
+		if _, _, err := Period(def.Period, nil, time.Now()); err != nil {
+			return err
+		}
+		switch {
+		case cmd.period == byMonth && def.Period != "last-month" && def.Period != "month-to-date":
+			return fmt.Errorf("%s reports a month: period must be last-month or month-to-date", def.Command)
+		case cmd.period == byNone:
+			return fmt.Errorf("%s runs as of today and takes no period", def.Command)
+		}
+	}
+	if def.Where != "" {
+		if _, err := query.Parse(def.Where); err != nil {
+			return err
+		}
+	}
+	if len(def.GroupBy) > 0 && def.Command != "aggregate" {
+		return fmt.Errorf("group_by needs the aggregate command")
+	}
+	for _, field := range def.GroupBy {
+		if _, err := aggregator.GroupBy(field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Runs returns the command lines writing a definition's reports as of
+// today, one per format
+func Runs(def config.ReportDefinition, fis *calendar.Fiscal, today time.Time) ([]Run, error) {
+	if err := check(def); err != nil {
+		return nil, fmt.Errorf("report %q: %w", def.Name, err)
+	}
+	cmd := commands[def.Command]
+
+	args := []string{def.Command}
+	switch {
+	case def.Period == "":
+	case cmd.period == byMonth:
+		// Fiscal months are named after the calendar month they stand for
+		month := fis.MonthOf(today)
+		if def.Period == "last-month" {
+			month = month.AddDate(0, -1, 0)
+		}
+		args = append(args, "--month", month.Format("2006-01"))
+	default:
+		start, end, err := Period(def.Period, fis, today)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, "--start", start.Format("2006-01-02"), "--end", end.Format("2006-01-02"))
+	}
+	if def.Cloud != "" {
+		args = append(args, "--cloud", def.Cloud)
+	}
+	lists := []struct {
+		flag   string
+		values []string
+	}{
+		{"--accounts", def.Accounts},
+		{"--services", def.Services},
+		{"--regions", def.Regions},
+		{"--tags", def.Tags},
+		{"--group-by", def.GroupBy},
+	}
+	for _, l := range lists {
+		if len(l.values) > 0 {
+			args = append(args, l.flag, strings.Join(l.values, ","))
+		}
+	}
+	if def.Where != "" {
+		args = append(args, "--where", def.Where)
+	}
+	if def.Command == "aggregate" {
+		// Alerts and the digest are left to the regular aggregate runs
+		args = append(args, "--dry-run")
+	}
+	args = append(args, def.Args...)
+
+	formats := def.Formats
+	if len(formats) == 0 {
+		formats = cmd.formats[:1]
+	}
+	runs := make([]Run, len(formats))
+	for i, format := range formats {
+		runs[i] = Run{Format: format, Args: append(append([]string(nil), args...), "--format", format)}
+	}
+	return runs, nil
+}
+
+// Period returns the days [start, end) a period names as of today, in the
+// fiscal calendar: month-to-date, last-month, quarter-to-date, last-quarter
+// or last-N-days, the N days before today
+func Period(period string, fis *calendar.Fiscal, today time.Time) (start, end time.Time, err error) {
+	switch period {
+	case "month-to-date":
+		start, _ = fis.Month(fis.MonthOf(today))
+		return start, today, nil
+	case "last-month":
+		start, end = fis.Month(fis.MonthOf(today).AddDate(0, -1, 0))
+		return start, end, nil
+	case "quarter-to-date":
+		start, _ = fis.Quarter(today)
+		return start, today, nil
+	case "last-quarter":
+		current, _ := fis.Quarter(today)
+		start, end = fis.Quarter(current.AddDate(0, 0, -1))
+		return start, end, nil
+	}
+	var days int
+	if n, _ := fmt.Sscanf(period, "last-%d-days", &days); n == 1 && days > 0 && period == fmt.Sprintf("last-%d-days", days) {
+		return today.AddDate(0, 0, -days), today, nil
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("unknown period %q (want month-to-date, last-month, quarter-to-date, last-quarter or last-N-days)", period)
+}
+
+// Written returns the files under dir modified since a time, sorted
+func Written(dir string, since time.Time) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(file string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if !info.ModTime().Before(since) {
+			files = append(files, file)
+		}
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		err = nil // nothing was written
+	}
+	sort.Strings(files)
+	return files, err
+}
+
+func commandNames() []string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
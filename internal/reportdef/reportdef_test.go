@@ -0,0 +1,196 @@
+package reportdef
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/calendar"
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+// today is a Wednesday in the middle of a month
+var today = time.Date(2024, 5, 15, 0, 0, 0, 0, time.UTC)
+
+// date parses a YYYY-MM-DD day
+func date(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestFind(t *testing.T) {
+	defs := []config.ReportDefinition{{Name: "weekly"}, {Name: "monthly"}}
+	if def, err := Find(defs, "monthly"); err != nil || def.Name != "monthly" {
+		t.Errorf("Find(monthly) = %+v, %v", def, err)
+	}
+	if _, err := Find(defs, "daily"); err == nil || err.Error() != `unknown report "daily" (have weekly, monthly)` {
+		t.Errorf("Find(daily) = %v, want the defined names", err)
+	}
+	if _, err := Find(nil, "daily"); err == nil || !strings.Contains(err.Error(), "no reports are defined") {
+		t.Errorf("Find() without reports = %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := []config.ReportDefinition{
+		{Name: "weekly", Command: "aggregate", Period: "last-7-days", Where: `cloud = "aws"`, GroupBy: []string{"service", "tag:team"}, Formats: []string{"html", "csv"}},
+		{Name: "monthly", Command: "chargeback", Period: "last-month", Formats: []string{"xlsx"}},
+		{Name: "outlook", Command: "forecast"},
+	}
+	if err := Validate(valid); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+
+	for name, def := range map[string]config.ReportDefinition{
+		"unknown command":     {Name: "r", Command: "prune"},
+		"unknown format":      {Name: "r", Command: "chargeback", Formats: []string{"html"}},
+		"unknown period":      {Name: "r", Command: "aggregate", Period: "last-fortnight"},
+		"zero days":           {Name: "r", Command: "aggregate", Period: "last-0-days"},
+		"month of a quarter":  {Name: "r", Command: "chargeback", Period: "last-quarter"},
+		"period of today":     {Name: "r", Command: "forecast", Period: "last-month"},
+		"invalid expression":  {Name: "r", Command: "aggregate", Where: "cost >"},
+		"group by chargeback": {Name: "r", Command: "chargeback", GroupBy: []string{"service"}},
+		"unknown group by":    {Name: "r", Command: "aggregate", GroupBy: []string{"owner"}},
+	} {
+		if err := Validate([]config.ReportDefinition{def}); err == nil || !strings.HasPrefix(err.Error(), `report "r": `) {
+			t.Errorf("%s: Validate() = %v, want an error naming the report", name, err)
+		}
+	}
+
+	// Every problem is reported
+	err := Validate([]config.ReportDefinition{{Command: "aggregate"}, valid[0], valid[0], {Name: "bad", Command: "prune"}})
+	if err == nil {
+		t.Fatal("Validate() succeeded, want errors")
+	}
+	for _, want := range []string{"report 1 has no name", `report "weekly" is defined twice`, `report "bad": command "prune"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate() = %v, want it to report %s", err, want)
+		}
+	}
+}
+
+func TestRuns(t *testing.T) {
+	weekly := config.ReportDefinition{
+		Name: "weekly", Command: "aggregate", Period: "last-7-days", Cloud: "aws",
+		Accounts: []string{"111", "222"}, Tags: []string{"env=prod"}, Where: "cost > 1", GroupBy: []string{"service"},
+		Formats: []string{"html", "csv"}, Args: []string{"--months", "12"},
+	}
+	runs, err := Runs(weekly, nil, today)
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := []string{"aggregate", "--start", "2024-05-08", "--end", "2024-05-15", "--cloud", "aws",
+		"--accounts", "111,222", "--tags", "env=prod", "--group-by", "service", "--where", "cost > 1", "--dry-run", "--months", "12"}
+	want := []Run{
+		{Format: "html", Args: append(append([]string(nil), args...), "--format", "html")},
+		{Format: "csv", Args: append(append([]string(nil), args...), "--format", "csv")},
+	}
+	if !reflect.DeepEqual(runs, want) {
+		t.Errorf("Runs(weekly) = %q, want %q", runs, want)
+	}
+
+	// Monthly commands take the fiscal month's name; 4-4-5 June starts May 27
+	fis, err := calendar.FiscalFromConfig(config.CalendarConfig{Periods: "4-4-5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		def  config.ReportDefinition
+		fis  *calendar.Fiscal
+		day  time.Time
+		want []string
+	}{
+		{config.ReportDefinition{Name: "m", Command: "chargeback", Period: "last-month"}, nil, today,
+			[]string{"chargeback", "--month", "2024-04", "--format", "csv"}},
+		{config.ReportDefinition{Name: "m", Command: "chargeback", Period: "month-to-date"}, fis, date("2024-05-28"),
+			[]string{"chargeback", "--month", "2024-06", "--format", "csv"}},
+		{config.ReportDefinition{Name: "f", Command: "forecast"}, nil, today,
+			[]string{"forecast", "--format", "csv"}},
+		{config.ReportDefinition{Name: "q", Command: "diff", Period: "quarter-to-date"}, nil, today,
+			[]string{"diff", "--start", "2024-04-01", "--end", "2024-05-15", "--format", "csv"}},
+	}
+	for _, tt := range tests {
+		runs, err := Runs(tt.def, tt.fis, tt.day)
+		if err != nil {
+			t.Errorf("Runs(%+v): %v", tt.def, err)
+			continue
+		}
+		if len(runs) != 1 || !reflect.DeepEqual(runs[0].Args, tt.want) {
+			t.Errorf("Runs(%+v) = %q, want %q", tt.def, runs, tt.want)
+		}
+	}
+
+	if _, err := Runs(config.ReportDefinition{Name: "bad", Command: "prune"}, nil, today); err == nil || !strings.HasPrefix(err.Error(), `report "bad": `) {
+		t.Errorf("Runs(bad) = %v, want an error naming the report", err)
+	}
+}
+
+func TestPeriod(t *testing.T) {
+	fis, err := calendar.FiscalFromConfig(config.CalendarConfig{Periods: "4-4-5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		period     string
+		fis        *calendar.Fiscal
+		start, end string
+	}{
+		{"month-to-date", nil, "2024-05-01", "2024-05-15"},
+		{"last-month", nil, "2024-04-01", "2024-05-01"},
+		{"quarter-to-date", nil, "2024-04-01", "2024-05-15"},
+		{"last-quarter", nil, "2024-01-01", "2024-04-01"},
+		{"last-30-days", nil, "2024-04-15", "2024-05-15"},
+		// 4-4-5 May runs from Apr 29 and April from Apr 1
+		{"month-to-date", fis, "2024-04-29", "2024-05-15"},
+		{"last-month", fis, "2024-04-01", "2024-04-29"},
+		{"last-quarter", fis, "2024-01-01", "2024-04-01"},
+	}
+	for _, tt := range tests {
+		start, end, err := Period(tt.period, tt.fis, today)
+		if err != nil || !start.Equal(date(tt.start)) || !end.Equal(date(tt.end)) {
+			t.Errorf("Period(%s) = %s, %s, %v; want %s to %s", tt.period, start, end, err, tt.start, tt.end)
+		}
+	}
+
+	for _, period := range []string{"", "yesterday", "last-month-days", "last--1-days", "last-7-days-ago", "last-07-days"} {
+		if _, _, err := Period(period, nil, today); err == nil {
+			t.Errorf("Period(%q) succeeded, want an error", period)
+		}
+	}
+}
+
+func TestWritten(t *testing.T) {
+	dir := t.TempDir()
+	since := time.Now().Add(-time.Minute)
+	for _, name := range []string{"old.csv", "b.html", filepath.Join("nested", "a.csv")} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := since.Add(-time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "old.csv"), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := Written(dir, since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{filepath.Join(dir, "b.html"), filepath.Join(dir, "nested", "a.csv")}; !reflect.DeepEqual(files, want) {
+		t.Errorf("Written() = %v, want %v", files, want)
+	}
+
+	if files, err := Written(filepath.Join(dir, "missing"), since); err != nil || len(files) != 0 {
+		t.Errorf("Written(missing) = %v, %v; want nothing", files, err)
+	}
+}
@@ -0,0 +1,321 @@
+package reporter
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+)
+
+var csvHeader = []string{"Provider", "AccountID", "Service", "Region", "Date", "Cost", "RawCost", "Adjustment", "Currency", "AsOf"}
+
+// writeEntriesCSV writes entries to path in sorted order. Output goes to a
+// temporary file that is renamed into place only after every row has been
+// written, so an error or cancellation never leaves a partial report behind.
+func (r *Reporter) writeEntriesCSV(ctx context.Context, path string, entries []aggregator.CostEntry, asOf time.Time) error {
+	sorted := sortEntries(entries, r.config.CSV.SortBy)
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".csv-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	// Temp files are private; the report is published readable, like
+	// the other reports
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to set report permissions: %w", err)
+	}
+
+	workers := r.config.CSV.Workers
+	if workers > 1 && len(sorted) > workers*r.flushRows() {
+		err = r.writeSharded(ctx, tmp, sorted, workers, asOf)
+	} else {
+		err = r.writeBatched(ctx, tmp, sorted, true, asOf)
+	}
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close temp file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to move report into place: %w", err)
+	}
+	return nil
+}
+
+// writeBatched streams rows through a buffered writer, flushing every
+// FlushRows rows and checking for cancellation between batches.
+func (r *Reporter) writeBatched(ctx context.Context, w io.Writer, entries []aggregator.CostEntry, header bool, asOf time.Time) error {
+	buf := bufio.NewWriterSize(w, 256*1024)
+	writer := csv.NewWriter(buf)
+
+	if header {
+		if err := writer.Write(csvHeader); err != nil {
+			return fmt.Errorf("failed to write header: %w", err)
+		}
+	}
+
+	flushRows := r.flushRows()
+	for i, entry := range entries {
+		if err := writer.Write(csvRow(entry, asOf)); err != nil {
+			return fmt.Errorf("failed to write row %d: %w", i, err)
+		}
+
+		if (i+1)%flushRows == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			writer.Flush()
+			if err := writer.Error(); err != nil {
+				return fmt.Errorf("failed to flush rows: %w", err)
+			}
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to flush rows: %w", err)
+	}
+	return buf.Flush()
+}
+
+// writeSharded splits the sorted entries into contiguous shards, formats each
+// shard into its own temporary file in parallel, then concatenates the shards
+// in order so the merged output keeps the sort order.
+func (r *Reporter) writeSharded(ctx context.Context, w io.Writer, entries []aggregator.CostEntry, workers int, asOf time.Time) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	shardSize := (len(entries) + workers - 1) / workers
+	shards := make([]*os.File, 0, workers)
+	defer func() {
+		for _, f := range shards {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	for start := 0; start < len(entries); start += shardSize {
+		f, err := os.CreateTemp("", "finops-shard-*")
+		if err != nil {
+			return fmt.Errorf("failed to create shard file: %w", err)
+		}
+		shards = append(shards, f)
+	}
+
+	var wg sync.WaitGroup
+	errCh := make(chan error, len(shards))
+
+	for i, f := range shards {
+		start := i * shardSize
+		end := start + shardSize
+		if end > len(entries) {
+			end = len(entries)
+		}
+
+		wg.Add(1)
+		go func(f *os.File, part []aggregator.CostEntry) {
+			defer wg.Done()
+			if err := r.writeBatched(ctx, f, part, false, asOf); err != nil {
+				errCh <- err
+				cancel()
+			}
+		}(f, entries[start:end])
+	}
+
+	wg.Wait()
+	close(errCh)
+
+	if err := <-errCh; err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// Merge shards in order
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return fmt.Errorf("failed to write header: %w", err)
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write header: %w", err)
+	}
+
+	for _, f := range shards {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("failed to rewind shard: %w", err)
+		}
+		if _, err := io.Copy(w, f); err != nil {
+			return fmt.Errorf("failed to merge shard: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// CSVStream writes a CSV report row by row, for entries streamed by
+// Aggregator.AggregateStream. Rows keep their arrival order and leave AsOf
+// empty, since neither is known until the last entry. Like other CSV
+// reports, the file only appears once Close succeeds.
+type CSVStream struct {
+	path      string
+	tmp       *os.File
+	buf       *bufio.Writer
+	writer    *csv.Writer
+	rows      int
+	flushRows int
+}
+
+// NewCSVStream starts a streamed CSV report
+func (r *Reporter) NewCSVStream() (*CSVStream, error) {
+	if err := os.MkdirAll(r.config.OutputDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+	filename := fmt.Sprintf("cost-report-%s.csv", time.Now().Format("20060102-150405"))
+	tmp, err := os.CreateTemp(r.config.OutputDir, ".csv-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, fmt.Errorf("failed to set report permissions: %w", err)
+	}
+	buf := bufio.NewWriterSize(tmp, 256*1024)
+	s := &CSVStream{
+		path:      filepath.Join(r.config.OutputDir, filename),
+		tmp:       tmp,
+		buf:       buf,
+		writer:    csv.NewWriter(buf),
+		flushRows: r.flushRows(),
+	}
+	if err := s.writer.Write(csvHeader); err != nil {
+		s.Abort()
+		return nil, fmt.Errorf("failed to write header: %w", err)
+	}
+	return s, nil
+}
+
+// Write adds an entry's row
+func (s *CSVStream) Write(entry aggregator.CostEntry) error {
+	row := csvRow(entry, time.Time{})
+	row[len(row)-1] = "" // AsOf
+	if err := s.writer.Write(row); err != nil {
+		return fmt.Errorf("failed to write row %d: %w", s.rows, err)
+	}
+	s.rows++
+	if s.rows%s.flushRows == 0 {
+		s.writer.Flush()
+		if err := s.writer.Error(); err != nil {
+			return fmt.Errorf("failed to flush rows: %w", err)
+		}
+	}
+	return nil
+}
+
+// Rows returns the number of rows written
+func (s *CSVStream) Rows() int {
+	return s.rows
+}
+
+// Close finishes the report, moving it into place, and returns its path
+func (s *CSVStream) Close() (string, error) {
+	s.writer.Flush()
+	if err := s.writer.Error(); err != nil {
+		s.Abort()
+		return "", fmt.Errorf("failed to flush rows: %w", err)
+	}
+	if err := s.buf.Flush(); err != nil {
+		s.Abort()
+		return "", fmt.Errorf("failed to flush rows: %w", err)
+	}
+	if err := s.tmp.Close(); err != nil {
+		os.Remove(s.tmp.Name())
+		return "", fmt.Errorf("failed to close temp file: %w", err)
+	}
+	if err := os.Rename(s.tmp.Name(), s.path); err != nil {
+		os.Remove(s.tmp.Name())
+		return "", fmt.Errorf("failed to move report into place: %w", err)
+	}
+	return s.path, nil
+}
+
+// Abort discards the report
+func (s *CSVStream) Abort() {
+	s.tmp.Close()
+	os.Remove(s.tmp.Name())
+}
+
+func (r *Reporter) flushRows() int {
+	if r.config.CSV.FlushRows > 0 {
+		return r.config.CSV.FlushRows
+	}
+	return 5000
+}
+
+// sortEntries returns a copy of entries ordered by cost (descending) or by
+// date (ascending, ties broken by provider, account and service).
+func sortEntries(entries []aggregator.CostEntry, sortBy string) []aggregator.CostEntry {
+	// Entries are large, so their indices are sorted rather than the
+	// entries themselves
+	order := make([]int, len(entries))
+	for i := range order {
+		order[i] = i
+	}
+
+	switch sortBy {
+	case "cost":
+		sort.SliceStable(order, func(i, j int) bool {
+			return entries[order[i]].Cost > entries[order[j]].Cost
+		})
+	default:
+		sort.SliceStable(order, func(i, j int) bool {
+			a, b := &entries[order[i]], &entries[order[j]]
+			if !a.Date.Equal(b.Date) {
+				return a.Date.Before(b.Date)
+			}
+			if a.Provider != b.Provider {
+				return a.Provider < b.Provider
+			}
+			if a.AccountID != b.AccountID {
+				return a.AccountID < b.AccountID
+			}
+			return a.Service < b.Service
+		})
+	}
+
+	sorted := make([]aggregator.CostEntry, len(entries))
+	for i, j := range order {
+		sorted[i] = entries[j]
+	}
+	return sorted
+}
+
+func csvRow(entry aggregator.CostEntry, asOf time.Time) []string {
+	return []string{
+		entry.Provider,
+		entry.AccountID,
+		entry.Service,
+		entry.Region,
+		entry.Date.Format("2006-01-02"),
+		fmt.Sprintf("%.2f", entry.Cost),
+		fmt.Sprintf("%.2f", entry.Raw()),
+		entry.Adjustment,
+		entry.Currency,
+		asOf.Format("2006-01-02"),
+	}
+}
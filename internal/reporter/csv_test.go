@@ -0,0 +1,246 @@
+package reporter
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/synthetic"
+)
+
+var asOf = time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+
+func csvEntries(n int) []aggregator.CostEntry {
+	entries := make([]aggregator.CostEntry, n)
+	for i := range entries {
+		entries[i] = aggregator.CostEntry{
+			Provider:  "aws",
+			AccountID: "111",
+			Service:   "EC2",
+			Region:    "us-east-1",
+			Date:      asOf.AddDate(0, 0, -(i % 30)),
+			Cost:      float64(i),
+			Currency:  "USD",
+		}
+	}
+	return entries
+}
+
+func TestWriteEntriesCSV(t *testing.T) {
+	for _, workers := range []int{1, 4} {
+		dir := t.TempDir()
+		r := New(config.ReporterConfig{OutputDir: dir, CSV: config.CSVConfig{FlushRows: 10, Workers: workers}})
+		path := filepath.Join(dir, "report.csv")
+		if err := r.writeEntriesCSV(context.Background(), path, csvEntries(100), asOf); err != nil {
+			t.Fatalf("workers=%d: %v", workers, err)
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows, err := csv.NewReader(f).ReadAll()
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) != 101 || rows[0][0] != "Provider" {
+			t.Fatalf("workers=%d: got %d rows starting %v, want a header and 100 rows", workers, len(rows), rows[0])
+		}
+		for i := 2; i < len(rows); i++ {
+			if rows[i][4] < rows[i-1][4] {
+				t.Fatalf("workers=%d: row %d dated %s after %s", workers, i, rows[i][4], rows[i-1][4])
+			}
+		}
+		for i, row := range rows[1:] {
+			if last := row[len(row)-1]; last != "2024-03-31" {
+				t.Fatalf("workers=%d: row %d as of %s, want 2024-03-31", workers, i+1, last)
+			}
+		}
+		assertFiles(t, dir, "report.csv")
+		assertMode(t, path)
+	}
+}
+
+func TestCSVStream(t *testing.T) {
+	dir := t.TempDir()
+	r := New(config.ReporterConfig{OutputDir: dir, CSV: config.CSVConfig{FlushRows: 10}})
+	s, err := r.NewCSVStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := csvEntries(25)
+	for _, e := range entries {
+		if err := s.Write(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Nothing appears until the report is complete
+	if files, _ := filepath.Glob(filepath.Join(dir, "cost-report-*.csv")); len(files) != 0 {
+		t.Errorf("report %v visible before Close", files)
+	}
+	if s.Rows() != 25 {
+		t.Errorf("Rows() = %d, want 25", s.Rows())
+	}
+	path, err := s.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 26 || rows[0][0] != "Provider" {
+		t.Fatalf("got %d rows starting %v, want a header and 25 rows", len(rows), rows[0])
+	}
+	// Rows keep their arrival order, without an as-of date
+	for i, row := range rows[1:] {
+		if date := entries[i].Date.Format("2006-01-02"); row[4] != date || row[len(row)-1] != "" {
+			t.Fatalf("row %d = %v, want dated %s and no as-of date", i+1, row, date)
+		}
+	}
+	assertFiles(t, dir, filepath.Base(path))
+	assertMode(t, path)
+}
+
+func TestWriteEntriesCSVFailureLeavesNoFile(t *testing.T) {
+	t.Run("cancelled", func(t *testing.T) {
+		for _, workers := range []int{1, 4} {
+			dir := t.TempDir()
+			r := New(config.ReporterConfig{OutputDir: dir, CSV: config.CSVConfig{FlushRows: 10, Workers: workers}})
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			err := r.writeEntriesCSV(ctx, filepath.Join(dir, "report.csv"), csvEntries(100), asOf)
+			if err == nil {
+				t.Fatalf("workers=%d: cancelled write succeeded", workers)
+			}
+			assertFiles(t, dir)
+		}
+	})
+
+	t.Run("rename", func(t *testing.T) {
+		dir := t.TempDir()
+		// A non-empty directory in the way makes the final rename fail
+		path := filepath.Join(dir, "report.csv")
+		if err := os.MkdirAll(filepath.Join(path, "busy"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		r := New(config.ReporterConfig{OutputDir: dir})
+		if err := r.writeEntriesCSV(context.Background(), path, csvEntries(10), asOf); err == nil {
+			t.Fatal("write over a directory succeeded")
+		}
+		assertFiles(t, dir, "report.csv")
+	})
+
+	t.Run("stream aborted", func(t *testing.T) {
+		dir := t.TempDir()
+		r := New(config.ReporterConfig{OutputDir: dir})
+		s, err := r.NewCSVStream()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range csvEntries(10) {
+			if err := s.Write(e); err != nil {
+				t.Fatal(err)
+			}
+		}
+		s.Abort()
+		assertFiles(t, dir)
+	})
+}
+
+// assertFiles checks that dir holds exactly the named entries
+func assertFiles(t *testing.T, dir string, want ...string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.Name())
+	}
+	if len(got) != len(want) {
+		t.Fatalf("%s holds %v, want %v", dir, got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("%s holds %v, want %v", dir, got, want)
+		}
+	}
+}
+
+// assertMode checks that a report is readable by others, not private like
+// the temp file it was written to
+func assertMode(t *testing.T, path string) {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0644 {
+		t.Errorf("%s has mode %v, want -rw-r--r--", path, mode)
+	}
+}
+
+// writePlainCSV writes entries the way GenerateCSV did before batching: one
+// csv.Writer straight on the file, unsorted, flushed only at the end
+func writePlainCSV(path string, entries []aggregator.CostEntry) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	writer := csv.NewWriter(f)
+	writer.Write(csvHeader)
+	for _, entry := range entries {
+		writer.Write(csvRow(entry, asOf))
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func BenchmarkGenerateCSV(b *testing.B) {
+	for _, scale := range synthetic.Scales[:2] {
+		b.Run(scale.Name, func(b *testing.B) {
+			entries := synthetic.Cached(scale.Records)
+			b.Run("baseline", func(b *testing.B) {
+				dir := b.TempDir()
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					path := filepath.Join(dir, "report.csv")
+					if err := writePlainCSV(path, entries); err != nil {
+						b.Fatal(err)
+					}
+					os.Remove(path)
+				}
+			})
+			for _, workers := range []int{1, 4} {
+				r := New(config.ReporterConfig{OutputDir: b.TempDir(), CSV: config.CSVConfig{Workers: workers}})
+				data := ReportData{Results: &aggregator.AggregationResult{Entries: entries, AsOf: asOf}}
+				b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+					b.ReportAllocs()
+					for i := 0; i < b.N; i++ {
+						path, err := r.GenerateCSV(data)
+						if err != nil {
+							b.Fatal(err)
+						}
+						os.Remove(path)
+					}
+				})
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package reporter
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+	"github.com/lvonguyen/finops-platform/internal/anomaly"
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+	"github.com/lvonguyen/finops-platform/internal/unitcost"
+)
+
+// renderHTML generates the HTML report and returns its text
+func renderHTML(t *testing.T, data ReportData) string {
+	t.Helper()
+	if data.Results == nil {
+		data.Results = &aggregator.AggregationResult{}
+	}
+	path, err := New(config.ReporterConfig{OutputDir: t.TempDir()}).GenerateHTML(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestHTMLOrgUnits(t *testing.T) {
+	if html := renderHTML(t, ReportData{}); strings.Contains(html, "Cost by Org Unit") {
+		t.Error("report without a hierarchy has an org unit section")
+	}
+
+	results := &aggregator.AggregationResult{ByOrgUnit: map[string]float64{"Root/Engineering": 75, "Root/Finance": 25}}
+	html := renderHTML(t, ReportData{Results: results})
+	for _, want := range []string{
+		"Cost by Org Unit",
+		`<td class="depth-0">Root</td>`,
+		`<td class="depth-1">Engineering</td>`,
+		"<td>$75.00</td>",
+		"<td>75.0%</td>",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("report is missing %q", want)
+		}
+	}
+}
+
+func TestHTMLCooldowns(t *testing.T) {
+	if html := renderHTML(t, ReportData{}); strings.Contains(html, "Active Anomaly Cooldowns") {
+		t.Error("report without cooldowns has a cooldown section")
+	}
+
+	at := time.Date(2024, 3, 31, 9, 30, 0, 0, time.UTC)
+	html := renderHTML(t, ReportData{Cooldowns: []anomaly.Cooldown{
+		{Change: anomaly.Change{Cloud: "aws", Service: "EC2", Note: "fleet resize", At: at}, Until: at.Add(24 * time.Hour)},
+	}})
+	for _, want := range []string{
+		"Active Anomaly Cooldowns",
+		"<td>aws/*/EC2</td>",
+		"<td>fleet resize</td>",
+		"<td>2024-03-31 09:30 UTC</td>",
+		"<td>2024-04-01 09:30 UTC</td>",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("report is missing %q", want)
+		}
+	}
+}
+
+func TestHTMLNoCostServices(t *testing.T) {
+	if html := renderHTML(t, ReportData{}); strings.Contains(html, "No-cost Services") {
+		t.Error("report without rolled-up records has a no-cost card")
+	}
+
+	results := &aggregator.AggregationResult{ZeroCost: 7, NoCost: map[string]int{"Lambda": 5, "IAM": 2}}
+	html := renderHTML(t, ReportData{Results: results})
+	for _, want := range []string{"No-cost Services", `<div class="stat-value">2</div>`, "7 zero-cost records rolled up"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("report is missing %q", want)
+		}
+	}
+}
+
+func TestHTMLRamping(t *testing.T) {
+	if html := renderHTML(t, ReportData{}); strings.Contains(html, "Accounts Ramping Up") {
+		t.Error("report without ramping accounts has a ramp section")
+	}
+
+	first := time.Date(2024, 3, 28, 0, 0, 0, 0, time.UTC)
+	html := renderHTML(t, ReportData{Ramping: []anomaly.RampAccount{
+		{Cloud: "aws", Account: "222", FirstSeen: first, Days: 4, FirstCost: 1, LatestCost: 500, GraceEnds: first.AddDate(0, 0, 14)},
+	}})
+	for _, want := range []string{"Accounts Ramping Up", "<td>aws/222</td>", "<td>2024-03-28</td>", "<td>$500.00</td>", "<td>2024-04-11</td>"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("report is missing %q", want)
+		}
+	}
+}
+
+func TestHTMLCustomDimensions(t *testing.T) {
+	results := &aggregator.AggregationResult{ByCustom: map[string]map[string]float64{
+		"env": {"prod": 150, "(other)": 25},
+	}}
+	html := renderHTML(t, ReportData{Results: results})
+	for _, want := range []string{"Cost by env", "<th>env</th>", "<td>prod</td>", "<td>$150.00</td>", "<td>(other)</td>"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("report is missing %q", want)
+		}
+	}
+	if strings.Index(html, "<td>prod</td>") > strings.Index(html, "<td>(other)</td>") {
+		t.Error("dimension values are not ordered by cost")
+	}
+}
+
+func TestHTMLTagCaps(t *testing.T) {
+	results := &aggregator.AggregationResult{TagCaps: []normalizer.TagCap{
+		{Key: "build", Reason: normalizer.TagIgnored, Values: 12, Cost: 340.5},
+	}}
+	html := renderHTML(t, ReportData{Results: results})
+	for _, want := range []string{"Capped Tags", "<td>build</td>", `<span class="badge low">ignored</span>`, "<td>12</td>", "<td>$340.50</td>"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("report is missing %q", want)
+		}
+	}
+}
+
+func TestHTMLUnitCosts(t *testing.T) {
+	if html := renderHTML(t, ReportData{}); strings.Contains(html, "Unit Economics") {
+		t.Error("report without unit costs has a unit economics section")
+	}
+
+	html := renderHTML(t, ReportData{UnitCosts: []unitcost.Series{
+		{Metric: "orders", Unit: "order", Scope: "cost center CC-1", Cost: 40, Units: 30, CostPerUnit: 40.0 / 30, TrendPercent: 100},
+		{Metric: "users", Scope: "all spend", Cost: 60},
+	}})
+	for _, want := range []string{
+		"Unit Economics",
+		`<td>orders<br><span class="stat-label">cost center CC-1</span></td>`,
+		"<td>$1.3333 per order</td>",
+		"<td>&#43;100.0%</td>",
+		"<td>-</td>",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("report is missing %q", want)
+		}
+	}
+}
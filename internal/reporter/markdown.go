@@ -0,0 +1,98 @@
+package reporter
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// markdownTopServices is how many services the Markdown report lists
+const markdownTopServices = 10
+
+// WriteMarkdown writes a GitHub/GitLab flavored Markdown summary of the
+// report, short enough to post as a pull request comment or wiki page
+func WriteMarkdown(w io.Writer, data ReportData) error {
+	funcs := templateFuncs(false)
+	funcs["cell"] = func(s string) string {
+		return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+	}
+	funcs["topServices"] = func() int { return markdownTopServices }
+	tmpl, err := template.New("markdown").Funcs(funcs).Parse(markdownTemplate)
+	if err != nil {
+		return err
+	}
+	if err := tmpl.Execute(w, data); err != nil {
+		return fmt.Errorf("failed to execute template: %w", err)
+	}
+	return nil
+}
+
+// GenerateMarkdown generates a Markdown report
+func (r *Reporter) GenerateMarkdown(data ReportData) (string, error) {
+	if err := os.MkdirAll(r.config.OutputDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	filename := fmt.Sprintf("cost-report-%s.md", time.Now().Format("20060102-150405"))
+	outputPath := filepath.Join(r.config.OutputDir, filename)
+
+	f, err := os.Create(outputPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to create file: %w", err)
+	}
+	defer f.Close()
+
+	if err := WriteMarkdown(f, data); err != nil {
+		return "", err
+	}
+	return outputPath, nil
+}
+
+const markdownTemplate = `## Cloud Cost Report: {{.Period}}
+{{if .Headline}}
+**{{.Headline}}**
+{{end}}{{if .Summary}}
+{{.Summary}}
+{{end}}
+| Total cost | Providers | Anomalies | Budget alerts | Data as of |
+|---:|---:|---:|---:|---|
+| {{currency .Results.TotalCost}} | {{len .Results.ByProvider}} | {{len .Anomalies}} | {{len .BudgetAlerts}} | {{if .Results.AsOf.IsZero}}no data{{else}}{{.Results.AsOf.Format "2006-01-02"}}{{end}} |
+{{if .Results.Errors}}
+### Provider errors
+
+| Provider | Type | Detail |
+|---|---|---|
+{{range .Results.Errors}}| {{.Provider}} | {{.Kind}} | {{cell .Message}} |
+{{end}}{{end}}
+### Cost by provider
+
+| Provider | Cost | Share |
+|---|---:|---:|
+{{range top .Results.ByProvider 0}}| {{.Name}} | {{currency .Cost}} | {{share .Cost $.Results.TotalCost}} |
+{{end}}
+### Top services
+
+| Service | Cost | Share |
+|---|---:|---:|
+{{range top .Results.ByService topServices}}| {{cell .Name}} | {{currency .Cost}} | {{share .Cost $.Results.TotalCost}} |
+{{end}}{{if gt (len .Results.ByService) topServices}}
+_{{len .Results.ByService}} services in total._
+{{end}}{{if .Anomalies}}
+### Anomalies
+
+| Severity | Date | Provider | Account | Service | Actual | Expected | Deviation |
+|---|---|---|---|---|---:|---:|---:|
+{{range .Anomalies}}| {{.Severity}} | {{.Date.Format "2006-01-02"}} | {{.Provider}} | {{cell .AccountID}} | {{cell .Service}} | {{currency .ActualCost}} | {{currency .ExpectedCost}} | {{printf "%+.1f%%" .PercentageDeviation}} |
+{{end}}{{end}}{{if .BudgetAlerts}}
+### Budget status
+
+| Budget | Scope | Kind | Spend | Limit | Used | Severity |
+|---|---|---|---:|---:|---:|---|
+{{range .BudgetAlerts}}| {{cell .BudgetName}} | {{cell .Scope}} | {{.Kind}} | {{if .ProjectedSpend}}{{currency .ProjectedSpend}} projected{{else}}{{currency .CurrentSpend}}{{end}} | {{currency .BudgetLimit}} | {{percent .PercentUsed}} | {{.Severity}} |
+{{end}}{{end}}
+<sub>Generated {{.GeneratedAt.Format "2006-01-02 15:04 MST"}}</sub>
+`
@@ -0,0 +1,100 @@
+package reporter
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+// markdown renders the Markdown report and returns its text
+func markdown(t *testing.T, data ReportData) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := WriteMarkdown(&buf, data); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	day := time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+	md := markdown(t, ReportData{
+		Period:   "2024-03-01 to 2024-03-31",
+		Headline: "Spend is up 12% on last month",
+		Results: &aggregator.AggregationResult{
+			AsOf:       day,
+			TotalCost:  1500,
+			ByProvider: map[string]float64{"aws": 1200, "gcp": 300},
+			ByService:  map[string]float64{"EC2": 1000, "Data|Transfer": 200, "BigQuery": 300},
+			Errors:     []aggregator.ProviderError{{Provider: "azure", Kind: "auth", Message: "token\nexpired"}},
+		},
+		Anomalies: []aggregator.Anomaly{
+			{Provider: "aws", AccountID: "111", Service: "EC2", Date: day, ActualCost: 300, ExpectedCost: 100, PercentageDeviation: 200, Severity: "high"},
+		},
+		BudgetAlerts: []aggregator.BudgetAlert{
+			{BudgetName: "prod", Scope: "aws", Kind: aggregator.BudgetActual, CurrentSpend: 900, BudgetLimit: 1000, PercentUsed: 90, Severity: "medium"},
+			{BudgetName: "prod", Scope: "aws", Kind: aggregator.BudgetForecast, ProjectedSpend: 1100, BudgetLimit: 1000, PercentUsed: 110, Severity: "medium"},
+		},
+		GeneratedAt: day,
+	})
+	for _, want := range []string{
+		"## Cloud Cost Report: 2024-03-01 to 2024-03-31\n\n**Spend is up 12% on last month**\n",
+		"| $1,500.00 | 2 | 1 | 2 | 2024-03-31 |",
+		"| azure | auth | token expired |",
+		"| aws | $1,200.00 | 80.0% |\n| gcp | $300.00 | 20.0% |",
+		"| EC2 | $1,000.00 | 66.7% |\n| BigQuery | $300.00 | 20.0% |\n| Data\\|Transfer | $200.00 | 13.3% |",
+		"| high | 2024-03-31 | aws | 111 | EC2 | $300.00 | $100.00 | +200.0% |",
+		"| prod | aws | actual | $900.00 | $1,000.00 | 90.0% | medium |",
+		"| prod | aws | forecast | $1,100.00 projected | $1,000.00 | 110.0% | medium |",
+		"<sub>Generated 2024-03-31 00:00 UTC</sub>",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("Markdown is missing %q", want)
+		}
+	}
+	if strings.Contains(md, "services in total") {
+		t.Error("Markdown notes the service count with every service listed")
+	}
+}
+
+func TestWriteMarkdownEmpty(t *testing.T) {
+	byService := make(map[string]float64)
+	for i := 0; i < 12; i++ {
+		byService[fmt.Sprintf("svc-%02d", i)] = float64(i + 1)
+	}
+	md := markdown(t, ReportData{Results: &aggregator.AggregationResult{ByService: byService}})
+	if !strings.Contains(md, "| Total cost |") || !strings.Contains(md, "| no data |") {
+		t.Error("Markdown without data is missing its summary table")
+	}
+	for _, section := range []string{"### Provider errors", "### Anomalies", "### Budget status", "**"} {
+		if strings.Contains(md, section) {
+			t.Errorf("Markdown without data has %q", section)
+		}
+	}
+	if !strings.Contains(md, "| svc-11 |") || strings.Contains(md, "| svc-01 |") || !strings.Contains(md, "_12 services in total._") {
+		t.Error("Markdown does not list the top 10 of 12 services and their count")
+	}
+}
+
+func TestGenerateMarkdown(t *testing.T) {
+	path, err := New(config.ReporterConfig{OutputDir: t.TempDir()}).GenerateMarkdown(ReportData{Period: "March", Results: &aggregator.AggregationResult{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(path, ".md") {
+		t.Errorf("GenerateMarkdown() = %s, want a .md file", path)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(b), "## Cloud Cost Report: March\n") {
+		t.Errorf("report starts %q, want the title", string(b)[:40])
+	}
+}
@@ -0,0 +1,204 @@
+package reporter
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+
+	"github.com/lvonguyen/finops-platform/internal/columnar"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+// GenerateParquet writes the report's normalized records as a Parquet
+// dataset partitioned Hive-style by day and provider, e.g.
+// date=2024-01-15/provider=aws/part-0.parquet. The partition values live in
+// the paths, so the files omit the date and cloud columns.
+func (r *Reporter) GenerateParquet(ctx context.Context, data ReportData) (string, error) {
+	dir := filepath.Join(r.config.OutputDir, fmt.Sprintf("cost-report-%s.parquet", time.Now().Format("20060102-150405")))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	partitions := make(map[string][]normalizer.CostRecord)
+	for _, rec := range data.Results.Records() {
+		key := filepath.Join("date="+rec.Date.UTC().Format("2006-01-02"), "provider="+url.PathEscape(rec.Cloud))
+		partitions[key] = append(partitions[key], rec)
+	}
+	keys := make([]string, 0, len(partitions))
+	for k := range partitions {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		if err := writeParquetPartition(filepath.Join(dir, key), partitions[key]); err != nil {
+			return "", fmt.Errorf("failed to write partition %s: %w", key, err)
+		}
+	}
+
+	return dir, nil
+}
+
+// GenerateArrow writes the report's normalized records, date and cloud
+// included, as a single Arrow IPC file for in-memory analytics tools
+func (r *Reporter) GenerateArrow(data ReportData) (string, error) {
+	if err := os.MkdirAll(r.config.OutputDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	filename := fmt.Sprintf("cost-report-%s.arrow", time.Now().Format("20060102-150405"))
+	outputPath := filepath.Join(r.config.OutputDir, filename)
+
+	f, err := os.Create(outputPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to create file: %w", err)
+	}
+	defer f.Close()
+
+	rec := recordBatch(data.Results.Records(), true)
+	defer rec.Release()
+	if err := columnar.WriteArrow(f, rec); err != nil {
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("failed to write file: %w", err)
+	}
+
+	return outputPath, nil
+}
+
+func writeParquetPartition(dir string, records []normalizer.CostRecord) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(filepath.Join(dir, "part-0.parquet"))
+	if err != nil {
+		return err
+	}
+	rec := recordBatch(records, false)
+	defer rec.Release()
+	if err := columnar.WriteParquet(f, rec); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// recordColumn is one column of the record batch, named after the JSON
+// field of normalizer.CostRecord
+type recordColumn struct {
+	name   string
+	typ    arrow.DataType
+	append func(b array.Builder, r normalizer.CostRecord)
+}
+
+func stringColumn(name string, v func(normalizer.CostRecord) string) recordColumn {
+	return recordColumn{name, arrow.BinaryTypes.String, func(b array.Builder, r normalizer.CostRecord) {
+		b.(*array.StringBuilder).Append(v(r))
+	}}
+}
+
+func doubleColumn(name string, v func(normalizer.CostRecord) float64) recordColumn {
+	return recordColumn{name, arrow.PrimitiveTypes.Float64, func(b array.Builder, r normalizer.CostRecord) {
+		b.(*array.Float64Builder).Append(v(r))
+	}}
+}
+
+func timestampColumn(name string, v func(normalizer.CostRecord) time.Time) recordColumn {
+	return recordColumn{name, arrow.FixedWidthTypes.Timestamp_us, func(b array.Builder, r normalizer.CostRecord) {
+		b.(*array.TimestampBuilder).Append(arrow.Timestamp(v(r).UnixMicro()))
+	}}
+}
+
+// recordColumns lists the columns of the record batch; the date and cloud
+// columns are left to the partition paths unless withPartition is set
+func recordColumns(withPartition bool) []recordColumn {
+	var cols []recordColumn
+	if withPartition {
+		cols = append(cols,
+			recordColumn{"date", arrow.FixedWidthTypes.Date32, func(b array.Builder, r normalizer.CostRecord) {
+				b.(*array.Date32Builder).Append(arrow.Date32FromTime(r.Date.UTC()))
+			}},
+			stringColumn("cloud", func(r normalizer.CostRecord) string { return r.Cloud }),
+		)
+	}
+	return append(cols,
+		recordColumn{"schema_version", arrow.PrimitiveTypes.Int32, func(b array.Builder, r normalizer.CostRecord) {
+			b.(*array.Int32Builder).Append(int32(r.SchemaVersion))
+		}},
+		stringColumn("id", func(r normalizer.CostRecord) string { return r.ID }),
+		stringColumn("account", func(r normalizer.CostRecord) string { return r.Account }),
+		stringColumn("region", func(r normalizer.CostRecord) string { return r.Region }),
+		stringColumn("service", func(r normalizer.CostRecord) string { return r.Service }),
+		stringColumn("resource", func(r normalizer.CostRecord) string { return r.Resource }),
+		doubleColumn("cost", func(r normalizer.CostRecord) float64 { return r.Cost }),
+		doubleColumn("raw_cost", func(r normalizer.CostRecord) float64 { return r.RawCost }),
+		stringColumn("adjustment", func(r normalizer.CostRecord) string { return r.Adjustment }),
+		stringColumn("currency", func(r normalizer.CostRecord) string { return r.Currency }),
+		doubleColumn("usage_quantity", func(r normalizer.CostRecord) float64 { return r.UsageQuantity }),
+		stringColumn("usage_unit", func(r normalizer.CostRecord) string { return r.UsageUnit }),
+		stringColumn("pricing_model", func(r normalizer.CostRecord) string { return r.PricingModel }),
+		stringColumn("charge_type", func(r normalizer.CostRecord) string { return string(r.ChargeType) }),
+		stringColumn("line_item_type", func(r normalizer.CostRecord) string { return r.LineItemType }),
+		doubleColumn("emissions_kg", func(r normalizer.CostRecord) float64 { return r.EmissionsKg }),
+		doubleColumn("effective_cost", func(r normalizer.CostRecord) float64 { return r.EffectiveCost }),
+		stringColumn("commitment_discount_id", func(r normalizer.CostRecord) string { return r.CommitmentDiscountID }),
+		stringColumn("commitment_discount_category", func(r normalizer.CostRecord) string { return r.CommitmentDiscountCategory }),
+		stringColumn("commitment_discount_type", func(r normalizer.CostRecord) string { return r.CommitmentDiscountType }),
+		stringColumn("commitment_discount_name", func(r normalizer.CostRecord) string { return r.CommitmentDiscountName }),
+		doubleColumn("original_cost", func(r normalizer.CostRecord) float64 { return r.OriginalCost }),
+		stringColumn("original_currency", func(r normalizer.CostRecord) string { return r.OriginalCurrency }),
+		timestampColumn("start_time", func(r normalizer.CostRecord) time.Time { return r.StartTime }),
+		timestampColumn("end_time", func(r normalizer.CostRecord) time.Time { return r.EndTime }),
+		recordColumn{"tags", arrow.MapOf(arrow.BinaryTypes.String, arrow.BinaryTypes.String), appendTags},
+		stringColumn("cloud_service", func(r normalizer.CostRecord) string { return r.CloudService }),
+		stringColumn("cloud_service_type", func(r normalizer.CostRecord) string { return r.CloudServiceType }),
+	)
+}
+
+// appendTags writes tags as a map, sorted by key so files are reproducible
+func appendTags(b array.Builder, r normalizer.CostRecord) {
+	mb := b.(*array.MapBuilder)
+	mb.Append(true)
+	keys := make([]string, 0, len(r.Tags))
+	for k := range r.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	kb, ib := mb.KeyBuilder().(*array.StringBuilder), mb.ItemBuilder().(*array.StringBuilder)
+	for _, k := range keys {
+		kb.Append(k)
+		ib.Append(r.Tags[k])
+	}
+}
+
+// recordBatch lays out records as an Arrow record batch; the caller
+// releases it
+func recordBatch(records []normalizer.CostRecord, withPartition bool) arrow.Record {
+	cols := recordColumns(withPartition)
+	fields := make([]arrow.Field, len(cols))
+	for i, c := range cols {
+		fields[i] = arrow.Field{Name: c.name, Type: c.typ}
+	}
+
+	b := array.NewRecordBuilder(memory.DefaultAllocator, arrow.NewSchema(fields, nil))
+	defer b.Release()
+	b.Reserve(len(records))
+	for _, r := range records {
+		for i, c := range cols {
+			c.append(b.Field(i), r)
+		}
+	}
+	return b.NewRecord()
+}
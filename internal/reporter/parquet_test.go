@@ -0,0 +1,135 @@
+package reporter
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+	"github.com/lvonguyen/finops-platform/internal/columnar"
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+func datasetEntries() []aggregator.CostEntry {
+	return []aggregator.CostEntry{
+		{Provider: "aws", AccountID: "111", Service: "AmazonEC2", Region: "us-east-1", Date: asOf, Cost: 12.5, Currency: "USD", Tags: map[string]string{"team": "web", "env": "prod"}},
+		{Provider: "aws", AccountID: "222", Service: "AmazonS3", Region: "us-west-2", Date: asOf.AddDate(0, 0, -1), Cost: 0.25, Currency: "USD"},
+		{Provider: "gcp", AccountID: "proj", Service: "Compute Engine", Region: "europe-west1", Date: asOf, Cost: 3, Currency: "USD", Tags: map[string]string{"team": "data"}},
+	}
+}
+
+func TestGenerateParquetRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	r := New(config.ReporterConfig{OutputDir: dir})
+	data := ReportData{Results: &aggregator.AggregationResult{Entries: datasetEntries()}}
+
+	out, err := r.GenerateParquet(context.Background(), data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var files []string
+	err = filepath.WalkDir(out, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			rel, _ := filepath.Rel(out, path)
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"date=2024-03-30/provider=aws/part-0.parquet",
+		"date=2024-03-31/provider=aws/part-0.parquet",
+		"date=2024-03-31/provider=gcp/part-0.parquet",
+	}
+	if strings.Join(files, " ") != strings.Join(want, " ") {
+		t.Fatalf("got files %v, want %v", files, want)
+	}
+
+	// Each row read back as partition|account|service|cost|start_time|tags
+	var rows []string
+	for _, file := range files {
+		f, err := os.Open(filepath.Join(out, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = columnar.ReadRows(context.Background(), f, func(header, row []string) error {
+			cell := make(map[string]string, len(header))
+			for i, col := range header {
+				cell[col] = row[i]
+			}
+			if _, ok := cell["date"]; ok {
+				t.Errorf("%s has a date column", file)
+			}
+			if _, ok := cell["cloud"]; ok {
+				t.Errorf("%s has a cloud column", file)
+			}
+			rows = append(rows, strings.Join([]string{filepath.Dir(file), cell["account"], cell["service"], cell["cost"], cell["start_time"], cell["tags"]}, "|"))
+			return nil
+		})
+		f.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+	}
+	sort.Strings(rows)
+	wantRows := []string{
+		"date=2024-03-30/provider=aws|222|AmazonS3|0.25|2024-03-30T00:00:00Z|{}",
+		"date=2024-03-31/provider=aws|111|AmazonEC2|12.5|2024-03-31T00:00:00Z|{\"env\":\"prod\",\"team\":\"web\"}",
+		"date=2024-03-31/provider=gcp|proj|Compute|3|2024-03-31T00:00:00Z|{\"team\":\"data\"}",
+	}
+	if strings.Join(rows, "\n") != strings.Join(wantRows, "\n") {
+		t.Fatalf("got rows\n%s\nwant\n%s", strings.Join(rows, "\n"), strings.Join(wantRows, "\n"))
+	}
+}
+
+func TestGenerateArrow(t *testing.T) {
+	dir := t.TempDir()
+	r := New(config.ReporterConfig{OutputDir: dir})
+	data := ReportData{Results: &aggregator.AggregationResult{Entries: datasetEntries()}}
+
+	path, err := r.GenerateArrow(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	fr, err := ipc.NewFileReader(f, ipc.WithAllocator(memory.DefaultAllocator))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fr.Close()
+
+	if fr.NumRecords() != 1 {
+		t.Fatalf("got %d record batches, want 1", fr.NumRecords())
+	}
+	rec, err := fr.Record(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.NumRows() != 3 {
+		t.Fatalf("got %d rows, want 3", rec.NumRows())
+	}
+	schema := rec.Schema()
+	if schema.Field(0).Name != "date" || schema.Field(1).Name != "cloud" {
+		t.Fatalf("got leading columns %s, %s, want date, cloud", schema.Field(0).Name, schema.Field(1).Name)
+	}
+	if got := columnar.Cell(rec.Column(0), 1); got != "2024-03-30" {
+		t.Errorf("second row date = %s, want 2024-03-30", got)
+	}
+	if got := columnar.Cell(rec.Column(1), 2); got != "gcp" {
+		t.Errorf("third row cloud = %s, want gcp", got)
+	}
+}
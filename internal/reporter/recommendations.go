@@ -0,0 +1,45 @@
+package reporter
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/recommendations"
+)
+
+//go:embed static/recommendations.html
+var recommendationsTemplate string
+
+// RecommendationsData contains the data of a rightsizing report
+type RecommendationsData struct {
+	Recommendations []recommendations.Recommendation // ranked by savings
+	TotalSavings    float64
+	Errors          map[string]string // sources that could not be queried, by provider
+	GeneratedAt     time.Time
+}
+
+// GenerateRecommendationsHTML writes a rightsizing report ranked by savings
+func (r *Reporter) GenerateRecommendationsHTML(data RecommendationsData) (string, error) {
+	if err := os.MkdirAll(r.config.OutputDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	filename := fmt.Sprintf("recommendations-%s.html", time.Now().Format("20060102-150405"))
+	outputPath := filepath.Join(r.config.OutputDir, filename)
+
+	f, err := os.Create(outputPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to create file: %w", err)
+	}
+	defer f.Close()
+
+	tmpl := Template("recommendations", recommendationsTemplate)
+	if err := tmpl.Execute(f, data); err != nil {
+		return "", fmt.Errorf("failed to execute template: %w", err)
+	}
+
+	return outputPath, nil
+}
@@ -2,7 +2,8 @@
 package reporter
 
 import (
-	"encoding/csv"
+	"context"
+	_ "embed"
 	"encoding/json"
 	"fmt"
 	"html/template"
@@ -11,15 +12,27 @@ import (
 	"time"
 
 	"github.com/lvonguyen/finops-platform/internal/aggregator"
+	"github.com/lvonguyen/finops-platform/internal/anomaly"
+	"github.com/lvonguyen/finops-platform/internal/chargeback"
 	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+	"github.com/lvonguyen/finops-platform/internal/release"
+	"github.com/lvonguyen/finops-platform/internal/unitcost"
 )
 
 // ReportData contains all data for report generation
 type ReportData struct {
 	Period       string
+	Headline     string // period-over-period summary, empty when no prior data
+	Summary      string // plain-English account of what drove the bill
 	Results      *aggregator.AggregationResult
 	Anomalies    []aggregator.Anomaly
 	BudgetAlerts []aggregator.BudgetAlert
+	Trend        *chargeback.Trend     // cost-center trend from the history store, nil when unavailable
+	Cooldowns    []anomaly.Cooldown    // scopes whose anomalies are suppressed after a marked change
+	Ramping      []anomaly.RampAccount // newly onboarded accounts still ramping up
+	Releases     []release.Impact      // cost before and after recent releases
+	UnitCosts    []unitcost.Series     // cost per business metric unit
 	GeneratedAt  time.Time
 }
 
@@ -49,7 +62,10 @@ func (r *Reporter) GenerateHTML(data ReportData) (string, error) {
 	}
 	defer f.Close()
 
-	tmpl := template.Must(template.New("report").Parse(htmlTemplate))
+	tmpl, err := r.htmlReportTemplate()
+	if err != nil {
+		return "", err
+	}
 	if err := tmpl.Execute(f, data); err != nil {
 		return "", fmt.Errorf("failed to execute template: %w", err)
 	}
@@ -59,6 +75,11 @@ func (r *Reporter) GenerateHTML(data ReportData) (string, error) {
 
 // GenerateCSV generates a CSV report
 func (r *Reporter) GenerateCSV(data ReportData) (string, error) {
+	return r.GenerateCSVContext(context.Background(), data)
+}
+
+// GenerateCSVContext generates a CSV report, stopping early if ctx is cancelled
+func (r *Reporter) GenerateCSVContext(ctx context.Context, data ReportData) (string, error) {
 	if err := os.MkdirAll(r.config.OutputDir, 0755); err != nil {
 		return "", fmt.Errorf("failed to create output directory: %w", err)
 	}
@@ -66,29 +87,31 @@ func (r *Reporter) GenerateCSV(data ReportData) (string, error) {
 	filename := fmt.Sprintf("cost-report-%s.csv", time.Now().Format("20060102-150405"))
 	outputPath := filepath.Join(r.config.OutputDir, filename)
 
+	if err := r.writeEntriesCSV(ctx, outputPath, data.Results.Entries, data.Results.AsOf); err != nil {
+		return "", err
+	}
+
+	return outputPath, nil
+}
+
+// GenerateFOCUS writes the report's cost entries as a FOCUS 1.0 CSV for
+// other FOCUS-compliant tools
+func (r *Reporter) GenerateFOCUS(data ReportData) (string, error) {
+	if err := os.MkdirAll(r.config.OutputDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	filename := fmt.Sprintf("cost-report-%s.focus.csv", time.Now().Format("20060102-150405"))
+	outputPath := filepath.Join(r.config.OutputDir, filename)
+
 	f, err := os.Create(outputPath)
 	if err != nil {
 		return "", fmt.Errorf("failed to create file: %w", err)
 	}
 	defer f.Close()
 
-	writer := csv.NewWriter(f)
-	defer writer.Flush()
-
-	// Header
-	writer.Write([]string{"Provider", "AccountID", "Service", "Region", "Date", "Cost", "Currency"})
-
-	// Data rows
-	for _, entry := range data.Results.Entries {
-		writer.Write([]string{
-			entry.Provider,
-			entry.AccountID,
-			entry.Service,
-			entry.Region,
-			entry.Date.Format("2006-01-02"),
-			fmt.Sprintf("%.2f", entry.Cost),
-			entry.Currency,
-		})
+	if err := normalizer.WriteFOCUSCSV(f, data.Results.Records()); err != nil {
+		return "", fmt.Errorf("failed to write FOCUS export: %w", err)
 	}
 
 	return outputPath, nil
@@ -115,6 +138,18 @@ func (r *Reporter) GenerateJSON(data ReportData) (string, error) {
 	return outputPath, nil
 }
 
+// styles is the stylesheet of HTML reports
+//
+//go:embed static/styles.css
+var styles string
+
+// Template parses an HTML page that can include the report stylesheet with
+// {{template "styles"}}, so other pages such as the showback portal match
+// the reports
+func Template(name, text string) *template.Template {
+	return template.Must(parseHTML(name, text))
+}
+
 const htmlTemplate = `<!DOCTYPE html>
 <html lang="en">
 <head>
@@ -122,114 +157,72 @@ const htmlTemplate = `<!DOCTYPE html>
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
     <title>Cloud Cost Report - {{.Period}}</title>
     <style>
-        :root {
-            --bg-dark: #0f172a;
-            --bg-card: #1e293b;
-            --text-primary: #f1f5f9;
-            --text-secondary: #94a3b8;
-            --accent-blue: #3b82f6;
-            --accent-green: #22c55e;
-            --accent-yellow: #eab308;
-            --accent-red: #ef4444;
-            --border: #334155;
-        }
-        * { box-sizing: border-box; margin: 0; padding: 0; }
-        body {
-            font-family: 'Inter', -apple-system, BlinkMacSystemFont, sans-serif;
-            background: var(--bg-dark);
-            color: var(--text-primary);
-            line-height: 1.6;
-            padding: 2rem;
-        }
-        .container { max-width: 1400px; margin: 0 auto; }
-        h1 {
-            font-size: 2rem;
-            margin-bottom: 0.5rem;
-            background: linear-gradient(135deg, var(--accent-blue), #8b5cf6);
-            -webkit-background-clip: text;
-            -webkit-text-fill-color: transparent;
-        }
-        .subtitle { color: var(--text-secondary); margin-bottom: 2rem; }
-        .stats-grid {
-            display: grid;
-            grid-template-columns: repeat(auto-fit, minmax(200px, 1fr));
-            gap: 1rem;
-            margin-bottom: 2rem;
-        }
-        .stat-card {
-            background: var(--bg-card);
-            border: 1px solid var(--border);
-            border-radius: 12px;
-            padding: 1.5rem;
-        }
-        .stat-label { color: var(--text-secondary); font-size: 0.875rem; }
-        .stat-value { font-size: 2rem; font-weight: 700; }
-        .stat-value.green { color: var(--accent-green); }
-        .stat-value.yellow { color: var(--accent-yellow); }
-        .stat-value.red { color: var(--accent-red); }
-        .section { margin-bottom: 2rem; }
-        .section-title {
-            font-size: 1.25rem;
-            margin-bottom: 1rem;
-            padding-bottom: 0.5rem;
-            border-bottom: 1px solid var(--border);
-        }
-        table {
-            width: 100%;
-            border-collapse: collapse;
-            background: var(--bg-card);
-            border-radius: 12px;
-            overflow: hidden;
-        }
-        th, td { padding: 1rem; text-align: left; }
-        th {
-            background: rgba(59, 130, 246, 0.1);
-            font-weight: 600;
-            color: var(--accent-blue);
-        }
-        tr:not(:last-child) { border-bottom: 1px solid var(--border); }
-        .badge {
-            display: inline-block;
-            padding: 0.25rem 0.75rem;
-            border-radius: 9999px;
-            font-size: 0.75rem;
-            font-weight: 600;
-        }
-        .badge.low { background: rgba(34, 197, 94, 0.2); color: var(--accent-green); }
-        .badge.medium { background: rgba(234, 179, 8, 0.2); color: var(--accent-yellow); }
-        .badge.high { background: rgba(239, 68, 68, 0.2); color: var(--accent-red); }
-        .provider-breakdown {
-            display: flex;
-            gap: 1rem;
-            flex-wrap: wrap;
-        }
-        .provider-item {
-            flex: 1;
-            min-width: 200px;
-            background: var(--bg-card);
-            border: 1px solid var(--border);
-            border-radius: 8px;
-            padding: 1rem;
-        }
-        .footer {
-            margin-top: 3rem;
-            padding-top: 1rem;
-            border-top: 1px solid var(--border);
-            color: var(--text-secondary);
-            font-size: 0.875rem;
-        }
+{{template "styles"}}
     </style>
 </head>
 <body>
     <div class="container">
         <h1>Multi-Cloud Cost Report</h1>
-        <p class="subtitle">{{.Period}} | Generated: {{.GeneratedAt.Format "2006-01-02 15:04:05 MST"}}</p>
+        <p class="subtitle">{{.Period}} | Data as of: <strong>{{if .Results.AsOf.IsZero}}no data{{else}}{{.Results.AsOf.Format "2006-01-02"}}{{end}}</strong> | Generated: {{.GeneratedAt.Format "2006-01-02 15:04:05 MST"}}</p>
+        {{if .Headline}}<p class="headline">{{.Headline}}</p>{{end}}
+        {{if .Summary}}<p class="summary">{{.Summary}}</p>{{end}}
+
+        {{if .Results.Errors}}
+        <div class="section">
+            <h2 class="section-title">Provider Errors</h2>
+            <table>
+                <thead>
+                    <tr>
+                        <th>Provider</th>
+                        <th>Type</th>
+                        <th>Detail</th>
+                    </tr>
+                </thead>
+                <tbody>
+                    {{range .Results.Errors}}
+                    <tr>
+                        <td>{{.Provider}}</td>
+                        <td><span class="badge high">{{if eq .Kind "auth"}}auth expired{{else if eq .Kind "currency"}}no exchange rate{{else}}api error{{end}}</span></td>
+                        <td>{{.Message}}</td>
+                    </tr>
+                    {{end}}
+                </tbody>
+            </table>
+        </div>
+        {{end}}
 
         <div class="stats-grid">
             <div class="stat-card">
-                <div class="stat-label">Total Cost</div>
+                <div class="stat-label">Total Cost{{if .Results.Adjustments}} (adjusted){{end}}</div>
                 <div class="stat-value">${{printf "%.2f" .Results.TotalCost}}</div>
+                {{if .Results.Adjustments}}<div class="stat-label">Raw: ${{printf "%.2f" .Results.RawTotalCost}}</div>{{end}}
             </div>
+            {{if .Results.Emissions}}
+            <div class="stat-card">
+                <div class="stat-label">Est. Emissions</div>
+                <div class="stat-value">{{printf "%.1f" .Results.Emissions}} kg</div>
+                <div class="stat-label">CO2e, estimated from emission factors</div>
+            </div>
+            {{end}}
+            {{if .Results.InternalCost}}
+            <div class="stat-card">
+                <div class="stat-label">External Spend</div>
+                <div class="stat-value">${{printf "%.2f" .Results.ExternalCost}}</div>
+                <div class="stat-label">All charges: ${{printf "%.2f" .Results.CombinedCost}}</div>
+            </div>
+            <div class="stat-card">
+                <div class="stat-label">Internal Charges</div>
+                <div class="stat-value">${{printf "%.2f" .Results.InternalCost}}</div>
+                <div class="stat-label">{{range $name, $cost := .Results.InternalRules}}{{$name}}: ${{printf "%.2f" $cost}}<br>{{end}}</div>
+            </div>
+            {{end}}
+            {{if .Results.NoCost}}
+            <div class="stat-card">
+                <div class="stat-label">No-cost Services</div>
+                <div class="stat-value">{{len .Results.NoCost}}</div>
+                <div class="stat-label">{{.Results.ZeroCost}} zero-cost records rolled up</div>
+            </div>
+            {{end}}
             <div class="stat-card">
                 <div class="stat-label">Providers</div>
                 <div class="stat-value">{{len .Results.ByProvider}}</div>
@@ -256,6 +249,179 @@ const htmlTemplate = `<!DOCTYPE html>
             </div>
         </div>
 
+        {{if .Results.Adjustments}}
+        <div class="section">
+            <h2 class="section-title">Cost Adjustments</h2>
+            <table>
+                <thead>
+                    <tr>
+                        <th>Adjustment</th>
+                        <th>Change</th>
+                    </tr>
+                </thead>
+                <tbody>
+                    {{range $name, $change := .Results.Adjustments}}
+                    <tr>
+                        <td>{{$name}}</td>
+                        <td>${{printf "%.2f" $change}}</td>
+                    </tr>
+                    {{end}}
+                </tbody>
+            </table>
+        </div>
+        {{end}}
+
+        {{if .Results.Emissions}}
+        <div class="section">
+            <h2 class="section-title">Estimated Emissions by Service</h2>
+            <table>
+                <thead>
+                    <tr>
+                        <th>Service</th>
+                        <th>Est. kg CO2e</th>
+                        <th>Cost</th>
+                    </tr>
+                </thead>
+                <tbody>
+                    {{$byService := .Results.ByService}}
+                    {{range $service, $kg := .Results.ByEmissions}}
+                    <tr>
+                        <td>{{$service}}</td>
+                        <td>{{printf "%.1f" $kg}}</td>
+                        <td>${{printf "%.2f" (index $byService $service)}}</td>
+                    </tr>
+                    {{end}}
+                </tbody>
+            </table>
+        </div>
+        {{end}}
+
+        {{if .Results.Applications}}
+        <div class="section">
+            <h2 class="section-title">Cost by Application</h2>
+            <table>
+                <thead>
+                    <tr>
+                        <th>Application</th>
+                        <th>Total</th>
+                        <th>By Cloud</th>
+                        <th>Top Services</th>
+                    </tr>
+                </thead>
+                <tbody>
+                    {{range $name, $app := .Results.Applications}}
+                    <tr>
+                        <td>{{$name}}</td>
+                        <td>${{printf "%.2f" $app.TotalCost}}</td>
+                        <td>{{range $provider, $cost := $app.ByProvider}}{{$provider}}: ${{printf "%.2f" $cost}}<br>{{end}}</td>
+                        <td>{{range $app.TopServices 5}}{{.Service}}: ${{printf "%.2f" .Cost}}<br>{{end}}</td>
+                    </tr>
+                    {{end}}
+                </tbody>
+            </table>
+        </div>
+        {{end}}
+
+        {{range $name := .Results.CustomNames}}
+        <div class="section">
+            <h2 class="section-title">Cost by {{$name}}</h2>
+            <table>
+                <thead>
+                    <tr>
+                        <th>{{$name}}</th>
+                        <th>Cost</th>
+                    </tr>
+                </thead>
+                <tbody>
+                    {{range $.Results.TopCustom $name 20}}
+                    <tr>
+                        <td>{{.Service}}</td>
+                        <td>${{printf "%.2f" .Cost}}</td>
+                    </tr>
+                    {{end}}
+                </tbody>
+            </table>
+        </div>
+        {{end}}
+
+        {{with .Results.TagCaps}}
+        <div class="section">
+            <h2 class="section-title">Capped Tags</h2>
+            <table>
+                <thead>
+                    <tr>
+                        <th>Tag Key</th>
+                        <th>Reason</th>
+                        <th>Values</th>
+                        <th>Cost</th>
+                    </tr>
+                </thead>
+                <tbody>
+                    {{range .}}
+                    <tr>
+                        <td>{{.Key}}</td>
+                        <td><span class="badge low">{{.Reason}}</span></td>
+                        <td>{{.Values}}</td>
+                        <td>${{printf "%.2f" .Cost}}</td>
+                    </tr>
+                    {{end}}
+                </tbody>
+            </table>
+        </div>
+        {{end}}
+
+        {{with .Results.OrgUnits}}
+        <div class="section">
+            <h2 class="section-title">Cost by Org Unit</h2>
+            <table>
+                <thead>
+                    <tr>
+                        <th>Org Unit</th>
+                        <th>Cost</th>
+                        <th>Share</th>
+                    </tr>
+                </thead>
+                <tbody>
+                    {{range .}}
+                    <tr>
+                        <td class="depth-{{.Depth}}">{{.Name}}</td>
+                        <td>${{printf "%.2f" .Cost}}</td>
+                        <td>{{printf "%.1f" .Percent}}%</td>
+                    </tr>
+                    {{end}}
+                </tbody>
+            </table>
+        </div>
+        {{end}}
+
+        {{if .Trend}}{{if .Trend.Centers}}
+        <div class="section">
+            <h2 class="section-title">Cost Center Trend ({{.Trend.Period}})</h2>
+            <table>
+                <thead>
+                    <tr>
+                        <th>Cost Center</th>
+                        <th>Trend</th>
+                        {{range .Trend.Months}}<th>{{.}}</th>{{end}}
+                        <th>Monthly Growth</th>
+                        <th>Status</th>
+                    </tr>
+                </thead>
+                <tbody>
+                    {{range .Trend.Centers}}
+                    <tr{{if .Fastest}} class="fastest"{{end}}>
+                        <td>{{.CostCenter}}</td>
+                        <td><svg width="120" height="30" viewBox="-2 -2 124 34"><polyline class="sparkline" points="{{.Sparkline 120 30}}"/></svg></td>
+                        {{range .Points}}<td>${{printf "%.2f" .Cost}}<br>{{printf "%.1f" .Share}}%</td>{{end}}
+                        <td>{{printf "%+.1f" .Growth}}%</td>
+                        <td>{{if .Fastest}}<span class="badge medium">fastest growing</span> {{end}}{{if .Status}}<span class="badge low">{{.Status}}</span>{{end}}</td>
+                    </tr>
+                    {{end}}
+                </tbody>
+            </table>
+        </div>
+        {{end}}{{end}}
+
         {{if .Anomalies}}
         <div class="section">
             <h2 class="section-title">Cost Anomalies</h2>
@@ -284,6 +450,120 @@ const htmlTemplate = `<!DOCTYPE html>
         </div>
         {{end}}
 
+        {{if .Cooldowns}}
+        <div class="section">
+            <h2 class="section-title">Active Anomaly Cooldowns</h2>
+            <table>
+                <thead>
+                    <tr>
+                        <th>Scope</th>
+                        <th>Change</th>
+                        <th>Marked</th>
+                        <th>Suppressed Until</th>
+                    </tr>
+                </thead>
+                <tbody>
+                    {{range .Cooldowns}}
+                    <tr>
+                        <td>{{.Scope}}</td>
+                        <td>{{.Note}}</td>
+                        <td>{{.At.Format "2006-01-02 15:04 MST"}}</td>
+                        <td>{{.Until.Format "2006-01-02 15:04 MST"}}</td>
+                    </tr>
+                    {{end}}
+                </tbody>
+            </table>
+        </div>
+        {{end}}
+
+        {{if .Ramping}}
+        <div class="section">
+            <h2 class="section-title">Accounts Ramping Up</h2>
+            <table>
+                <thead>
+                    <tr>
+                        <th>Account</th>
+                        <th>First Spend</th>
+                        <th>Days</th>
+                        <th>First Day</th>
+                        <th>Latest Day</th>
+                        <th>Grace Ends</th>
+                    </tr>
+                </thead>
+                <tbody>
+                    {{range .Ramping}}
+                    <tr>
+                        <td>{{.Scope}}</td>
+                        <td>{{.FirstSeen.Format "2006-01-02"}}</td>
+                        <td>{{.Days}}</td>
+                        <td>${{printf "%.2f" .FirstCost}}</td>
+                        <td>${{printf "%.2f" .LatestCost}}</td>
+                        <td>{{.GraceEnds.Format "2006-01-02"}}</td>
+                    </tr>
+                    {{end}}
+                </tbody>
+            </table>
+        </div>
+        {{end}}
+
+        {{if .Releases}}
+        <div class="section">
+            <h2 class="section-title">Cost Impact of Recent Releases</h2>
+            <table>
+                <thead>
+                    <tr>
+                        <th>Release</th>
+                        <th>Scope</th>
+                        <th>Before (per day)</th>
+                        <th>After (per day)</th>
+                        <th>Change</th>
+                        <th>Notes</th>
+                    </tr>
+                </thead>
+                <tbody>
+                    {{range .Releases}}
+                    <tr>
+                        <td>{{.Label}}<br>{{.At.Format "2006-01-02 15:04"}}</td>
+                        <td>{{.Scope}}</td>
+                        <td>${{printf "%.2f" .PreDaily}} ({{.PreDays}}d)</td>
+                        <td>${{printf "%.2f" .PostDaily}} ({{.PostDays}}d)</td>
+                        <td>{{printf "%+.2f" .Delta}} ({{printf "%+.1f" .DeltaPercent}}%)</td>
+                        <td>{{if .Correlated}}<span class="badge high">{{.Anomalies}} anomalies after release</span><br>{{end}}{{range .Overlaps}}overlaps {{.}}<br>{{end}}{{.Note}}</td>
+                    </tr>
+                    {{end}}
+                </tbody>
+            </table>
+        </div>
+        {{end}}
+
+        {{if .UnitCosts}}
+        <div class="section">
+            <h2 class="section-title">Unit Economics</h2>
+            <table>
+                <thead>
+                    <tr>
+                        <th>Metric</th>
+                        <th>Cost</th>
+                        <th>Units</th>
+                        <th>Cost per Unit</th>
+                        <th>Trend</th>
+                    </tr>
+                </thead>
+                <tbody>
+                    {{range .UnitCosts}}
+                    <tr>
+                        <td>{{.Metric}}<br><span class="stat-label">{{.Scope}}</span></td>
+                        <td>${{printf "%.2f" .Cost}}</td>
+                        <td>{{printf "%.0f" .Units}}</td>
+                        <td>{{if .Units}}${{printf "%.4f" .CostPerUnit}}{{if .Unit}} per {{.Unit}}{{end}}{{else}}-{{end}}</td>
+                        <td>{{if .TrendPercent}}{{printf "%+.1f" .TrendPercent}}%{{else}}-{{end}}</td>
+                    </tr>
+                    {{end}}
+                </tbody>
+            </table>
+        </div>
+        {{end}}
+
         {{if .BudgetAlerts}}
         <div class="section">
             <h2 class="section-title">Budget Alerts</h2>
@@ -340,4 +620,3 @@ const htmlTemplate = `<!DOCTYPE html>
     </div>
 </body>
 </html>`
-
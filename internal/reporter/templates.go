@@ -0,0 +1,258 @@
+package reporter
+
+import (
+	"fmt"
+	"html/template"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// Line is a named cost, as returned by the top template helper
+type Line struct {
+	Name string
+	Cost float64
+}
+
+// templateFuncs are the helpers of report templates. HTML templates get an
+// SVG sparkline, text and Markdown templates a line of block characters.
+//
+//	currency 1234.5                  $1,234.50
+//	percent 12.345                   12.3%
+//	share 25 200                     12.5%
+//	top .Results.ByService 5         the 5 largest entries, as Lines
+//	sparkline .Results.ByDate        daily cost, by date order
+//	sparkline .Results.ByDate 300 60 in a 300x60 box (HTML only)
+func templateFuncs(html bool) map[string]any {
+	return map[string]any{
+		"currency": currency,
+		"percent":  func(v float64) string { return fmt.Sprintf("%.1f%%", v) },
+		"share": func(part, total float64) string {
+			if total == 0 {
+				return "0.0%"
+			}
+			return fmt.Sprintf("%.1f%%", part/total*100)
+		},
+		"top": top,
+		"sparkline": func(values any, size ...int) (any, error) {
+			series, err := sparkValues(values)
+			if err != nil {
+				return nil, err
+			}
+			if !html {
+				return blocks(series), nil
+			}
+			width, height := 120, 30
+			if len(size) == 2 {
+				width, height = size[0], size[1]
+			}
+			return svgSparkline(series, width, height), nil
+		},
+	}
+}
+
+// currency formats an amount in dollars with thousands separators
+func currency(v float64) string {
+	sign := ""
+	if v < 0 {
+		sign, v = "-", -v
+	}
+	s := fmt.Sprintf("%.2f", v)
+	whole, cents := s[:len(s)-3], s[len(s)-3:]
+	var b strings.Builder
+	for i, d := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(d)
+	}
+	return sign + "$" + b.String() + cents
+}
+
+// top returns a map's n largest entries, largest first
+func top(m map[string]float64, n int) []Line {
+	lines := make([]Line, 0, len(m))
+	for name, cost := range m {
+		lines = append(lines, Line{Name: name, Cost: cost})
+	}
+	sort.Slice(lines, func(i, j int) bool {
+		if lines[i].Cost != lines[j].Cost {
+			return lines[i].Cost > lines[j].Cost
+		}
+		return lines[i].Name < lines[j].Name
+	})
+	if n > 0 && len(lines) > n {
+		lines = lines[:n]
+	}
+	return lines
+}
+
+// sparkValues accepts a slice of values, or a map such as ByDate whose keys
+// sort into order
+func sparkValues(values any) ([]float64, error) {
+	switch v := values.(type) {
+	case []float64:
+		return v, nil
+	case map[string]float64:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		series := make([]float64, len(keys))
+		for i, k := range keys {
+			series[i] = v[k]
+		}
+		return series, nil
+	}
+	return nil, fmt.Errorf("sparkline: want []float64 or map[string]float64, got %T", values)
+}
+
+// svgSparkline draws values in a width x height box, scaled to their peak
+func svgSparkline(values []float64, width, height int) template.HTML {
+	var peak float64
+	for _, v := range values {
+		peak = math.Max(peak, v)
+	}
+	step := 0.0
+	if len(values) > 1 {
+		step = float64(width) / float64(len(values)-1)
+	}
+	coords := make([]string, len(values))
+	for i, v := range values {
+		y := float64(height)
+		if peak > 0 {
+			y -= v / peak * float64(height)
+		}
+		coords[i] = fmt.Sprintf("%.1f,%.1f", float64(i)*step, y)
+	}
+	return template.HTML(fmt.Sprintf(`<svg width="%d" height="%d" viewBox="-2 -2 %d %d"><polyline class="sparkline" points="%s"/></svg>`,
+		width, height, width+4, height+4, strings.Join(coords, " ")))
+}
+
+// blocks draws values as block characters, scaled to their peak
+func blocks(values []float64) string {
+	const levels = "▁▂▃▄▅▆▇█"
+	runes := []rune(levels)
+	var peak float64
+	for _, v := range values {
+		peak = math.Max(peak, v)
+	}
+	var b strings.Builder
+	for _, v := range values {
+		level := 0
+		if peak > 0 && v > 0 {
+			level = int(math.Round(v / peak * float64(len(runes)-1)))
+		}
+		b.WriteRune(runes[level])
+	}
+	return b.String()
+}
+
+// parseHTML parses an HTML report template with the helpers and the report
+// stylesheet as {{template "styles"}}
+func parseHTML(name, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs(true)).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tmpl.New("styles").Parse(styles); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// htmlReportTemplate returns the HTML report template: html_template when
+// set, else the built-in one
+func (r *Reporter) htmlReportTemplate() (*template.Template, error) {
+	if r.config.HTMLTemplate == "" {
+		return Template("report", htmlTemplate), nil
+	}
+	text, err := os.ReadFile(r.config.HTMLTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read HTML template: %w", err)
+	}
+	tmpl, err := parseHTML(filepath.Base(r.config.HTMLTemplate), string(text))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse HTML template %s: %w", r.config.HTMLTemplate, err)
+	}
+	return tmpl, nil
+}
+
+// GenerateCustom renders every template in the templates directory with the
+// report data. A template named summary.md.tmpl is written as
+// summary-<timestamp>.md; .html and .htm templates are HTML-escaped and can
+// include the report stylesheet, others are rendered as plain text. Files
+// starting with "_" are partials that every template can include by name.
+func (r *Reporter) GenerateCustom(data ReportData) ([]string, error) {
+	if r.config.TemplatesDir == "" {
+		return nil, nil
+	}
+	files, err := filepath.Glob(filepath.Join(r.config.TemplatesDir, "*.tmpl"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list templates: %w", err)
+	}
+	var partials, pages []string
+	for _, f := range files {
+		if strings.HasPrefix(filepath.Base(f), "_") {
+			partials = append(partials, f)
+		} else {
+			pages = append(pages, f)
+		}
+	}
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("no *.tmpl templates in %s", r.config.TemplatesDir)
+	}
+
+	if err := os.MkdirAll(r.config.OutputDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+	stamp := time.Now().Format("20060102-150405")
+	var written []string
+	for _, page := range pages {
+		name := strings.TrimSuffix(filepath.Base(page), ".tmpl")
+		ext := filepath.Ext(name)
+		outputPath := filepath.Join(r.config.OutputDir, strings.TrimSuffix(name, ext)+"-"+stamp+ext)
+		if err := renderCustom(page, partials, ext == ".html" || ext == ".htm", outputPath, data); err != nil {
+			return written, fmt.Errorf("template %s: %w", filepath.Base(page), err)
+		}
+		written = append(written, outputPath)
+	}
+	return written, nil
+}
+
+// renderCustom parses a template with the partials and writes it
+func renderCustom(page string, partials []string, html bool, outputPath string, data ReportData) error {
+	files := append([]string{page}, partials...)
+	var exec func(*os.File) error
+	if html {
+		tmpl, err := template.New(filepath.Base(page)).Funcs(templateFuncs(true)).ParseFiles(files...)
+		if err != nil {
+			return err
+		}
+		if _, err := tmpl.New("styles").Parse(styles); err != nil {
+			return err
+		}
+		exec = func(f *os.File) error { return tmpl.Execute(f, data) }
+	} else {
+		tmpl, err := texttemplate.New(filepath.Base(page)).Funcs(templateFuncs(false)).ParseFiles(files...)
+		if err != nil {
+			return err
+		}
+		exec = func(f *os.File) error { return tmpl.Execute(f, data) }
+	}
+
+	f, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer f.Close()
+	if err := exec(f); err != nil {
+		return fmt.Errorf("failed to execute template: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package reporter
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+// writeTemplates writes template files into a new directory and returns it
+func writeTemplates(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, text := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// templateData is a report of three services over three days
+func templateData() ReportData {
+	return ReportData{Period: "March 2024", Results: &aggregator.AggregationResult{
+		TotalCost: 1234.5,
+		ByService: map[string]float64{"EC2": 1000, "S3": 200, "<Lambda>": 34.5},
+		ByDate:    map[string]float64{"2024-03-03": 0, "2024-03-01": 700, "2024-03-02": 534.5},
+	}}
+}
+
+func TestCurrency(t *testing.T) {
+	tests := []struct {
+		v    float64
+		want string
+	}{
+		{0, "$0.00"},
+		{999.999, "$1,000.00"},
+		{1234.5, "$1,234.50"},
+		{1234567.891, "$1,234,567.89"},
+		{-98765.4, "-$98,765.40"},
+	}
+	for _, tt := range tests {
+		if got := currency(tt.v); got != tt.want {
+			t.Errorf("currency(%v) = %q, want %q", tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestTop(t *testing.T) {
+	m := map[string]float64{"b": 10, "a": 10, "c": 30, "d": 5}
+	if got, want := top(m, 3), []Line{{"c", 30}, {"a", 10}, {"b", 10}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("top(3) = %v, want %v", got, want)
+	}
+	if got := top(m, 0); len(got) != 4 {
+		t.Errorf("top(0) = %v, want every entry", got)
+	}
+}
+
+func TestSparklines(t *testing.T) {
+	if got := blocks([]float64{0, 7, 14, 3.5}); got != "▁▅█▃" {
+		t.Errorf("blocks() = %q, want ▁▅█▃", got)
+	}
+	if got := blocks([]float64{0, 0}); got != "▁▁" {
+		t.Errorf("blocks() = %q, want ▁▁ without spend", got)
+	}
+	svg := string(svgSparkline([]float64{0, 5, 10}, 100, 20))
+	if !strings.Contains(svg, `width="100" height="20"`) || !strings.Contains(svg, `points="0.0,20.0 50.0,10.0 100.0,0.0"`) {
+		t.Errorf("svgSparkline() = %s, want three points rising to the peak", svg)
+	}
+	if _, err := sparkValues("not a series"); err == nil {
+		t.Error("sparkValues(string) succeeded, want an error")
+	}
+}
+
+func TestGenerateCustom(t *testing.T) {
+	dir := writeTemplates(t, map[string]string{
+		"_header.tmpl": `{{define "header"}}Cloud costs, {{.Period}}{{end}}`,
+		"summary.md.tmpl": `# {{template "header" .}}
+Total {{currency .Results.TotalCost}}
+{{range top .Results.ByService 2}}- {{.Name}}: {{currency .Cost}} ({{share .Cost $.Results.TotalCost}})
+{{end}}{{sparkline .Results.ByDate}}`,
+		"brand.html.tmpl": `<style>{{template "styles"}}</style><h1>{{template "header" .}}</h1>{{range top .Results.ByService 0}}<p>{{.Name}}</p>{{end}}{{sparkline .Results.ByDate 60 10}}`,
+		"notes.txt":       "not a template",
+	})
+	out := t.TempDir()
+	written, err := New(config.ReporterConfig{OutputDir: out, TemplatesDir: dir}).GenerateCustom(templateData())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(written) != 2 {
+		t.Fatalf("wrote %v, want brand and summary", written)
+	}
+
+	read := func(prefix, ext string) string {
+		for _, path := range written {
+			if name := filepath.Base(path); strings.HasPrefix(name, prefix+"-") && strings.HasSuffix(name, ext) && filepath.Dir(path) == out {
+				b, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return string(b)
+			}
+		}
+		t.Fatalf("no %s-<timestamp>%s in %v", prefix, ext, written)
+		return ""
+	}
+
+	// Text templates are not escaped
+	want := "# Cloud costs, March 2024\nTotal $1,234.50\n- EC2: $1,000.00 (81.0%)\n- S3: $200.00 (16.2%)\n█▆▁"
+	if got := read("summary", ".md"); got != want {
+		t.Errorf("summary = %q, want %q", got, want)
+	}
+	brand := read("brand", ".html")
+	for _, s := range []string{"<h1>Cloud costs, March 2024</h1>", "<p>&lt;Lambda&gt;</p>", `<svg width="60" height="10"`, ".section"} {
+		if !strings.Contains(brand, s) {
+			t.Errorf("brand page is missing %q", s)
+		}
+	}
+}
+
+func TestGenerateCustomErrors(t *testing.T) {
+	if written, err := New(config.ReporterConfig{OutputDir: t.TempDir()}).GenerateCustom(templateData()); written != nil || err != nil {
+		t.Errorf("GenerateCustom() = %v, %v without templates_dir, want nothing", written, err)
+	}
+	for name, files := range map[string]map[string]string{
+		"partials only":  {"_header.tmpl": `{{define "header"}}{{end}}`},
+		"parse error":    {"report.txt.tmpl": "{{range}}"},
+		"unknown helper": {"report.txt.tmpl": "{{money 1}}"},
+		"bad sparkline":  {"report.txt.tmpl": "{{sparkline .Period}}"},
+	} {
+		dir := writeTemplates(t, files)
+		if _, err := New(config.ReporterConfig{OutputDir: t.TempDir(), TemplatesDir: dir}).GenerateCustom(templateData()); err == nil {
+			t.Errorf("%s: GenerateCustom() succeeded, want an error", name)
+		}
+	}
+}
+
+func TestHTMLTemplate(t *testing.T) {
+	dir := writeTemplates(t, map[string]string{"report.html": `<title>Acme</title>{{currency .Results.TotalCost}}`})
+	path, err := New(config.ReporterConfig{OutputDir: t.TempDir(), HTMLTemplate: filepath.Join(dir, "report.html")}).GenerateHTML(templateData())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(b); got != "<title>Acme</title>$1,234.50" {
+		t.Errorf("report = %q, want the custom template", got)
+	}
+
+	missing := config.ReporterConfig{OutputDir: t.TempDir(), HTMLTemplate: filepath.Join(dir, "missing.html")}
+	if _, err := New(missing).GenerateHTML(templateData()); err == nil {
+		t.Error("missing html_template: GenerateHTML() succeeded, want an error")
+	}
+}
@@ -0,0 +1,259 @@
+// Package resilience paces, retries and circuit-breaks provider API calls
+package resilience
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/telemetry"
+)
+
+// ErrCircuitOpen is returned without calling the API while a provider's
+// circuit is open after repeated failures
+var ErrCircuitOpen = errors.New("circuit open after repeated failures")
+
+// Stats counts a provider's API calls
+type Stats struct {
+	Provider     string
+	Calls        int           // attempts made, retries included
+	Retries      int           // attempts repeated after a retryable error
+	Failures     int           // calls that failed after their retries
+	Rejected     int           // calls failed fast by an open circuit
+	BreakerOpens int           // times the circuit opened
+	Waited       time.Duration // time spent waiting for the rate limit
+}
+
+// Caller runs one provider's API calls under its rate limit, retry policy
+// and circuit breaker
+type Caller struct {
+	provider  string
+	retryable func(error) bool
+
+	interval         time.Duration // minimum spacing between calls, 0 for none
+	maxRetries       int
+	baseDelay        time.Duration
+	maxDelay         time.Duration
+	breakerThreshold int
+	breakerCooldown  time.Duration
+
+	mu        sync.Mutex
+	next      time.Time // earliest start of the next call
+	failures  int       // consecutive failed calls
+	openUntil time.Time
+	stats     Stats
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []*Caller
+)
+
+// New builds a provider's caller. retryable reports whether an error is
+// transient, such as throttling or a server error; other errors fail the
+// call at once. Callers are registered for AllStats.
+func New(provider string, cfg config.CallConfig, retryable func(error) bool) (*Caller, error) {
+	c := &Caller{
+		provider:         provider,
+		retryable:        retryable,
+		maxRetries:       cfg.MaxRetries,
+		breakerThreshold: cfg.BreakerThreshold,
+		stats:            Stats{Provider: provider},
+	}
+	if cfg.RateLimit < 0 {
+		return nil, fmt.Errorf("%s calls: rate_limit must not be negative", provider)
+	}
+	if cfg.RateLimit > 0 {
+		c.interval = time.Duration(float64(time.Second) / cfg.RateLimit)
+	}
+
+	for _, d := range []struct {
+		name  string
+		value string
+		dst   *time.Duration
+	}{
+		{"base_delay", cfg.BaseDelay, &c.baseDelay},
+		{"max_delay", cfg.MaxDelay, &c.maxDelay},
+		{"breaker_cooldown", cfg.BreakerCooldown, &c.breakerCooldown},
+	} {
+		if d.value == "" {
+			continue
+		}
+		v, err := time.ParseDuration(d.value)
+		if err != nil {
+			return nil, fmt.Errorf("%s calls: invalid %s %q: %w", provider, d.name, d.value, err)
+		}
+		*d.dst = v
+	}
+
+	registryMu.Lock()
+	registry = append(registry, c)
+	registryMu.Unlock()
+	return c, nil
+}
+
+// Do runs call, waiting for the rate limit before each attempt and retrying
+// retryable errors with exponential backoff and jitter. Retries are recorded
+// as events on the span in ctx.
+func (c *Caller) Do(ctx context.Context, call func(context.Context) error) error {
+	if err := c.admit(); err != nil {
+		return err
+	}
+
+	span := trace.SpanFromContext(ctx)
+	for attempt := 0; ; attempt++ {
+		if err := c.wait(ctx); err != nil {
+			return err
+		}
+		err := call(ctx)
+		c.mu.Lock()
+		c.stats.Calls++
+		c.mu.Unlock()
+		if err == nil {
+			c.record(nil)
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		if attempt >= c.maxRetries || !c.retryable(err) {
+			c.record(err)
+			return err
+		}
+
+		delay := c.backoff(attempt)
+		c.mu.Lock()
+		c.stats.Retries++
+		c.mu.Unlock()
+		span.SetAttributes(telemetry.AttrBackoffs.Int(attempt + 1))
+		span.AddEvent("retry", trace.WithAttributes(
+			attribute.String("error", err.Error()),
+			attribute.String("delay", delay.String())))
+		if err := sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// Stats returns the caller's counts so far
+func (c *Caller) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// AllStats returns the counts of every caller built in this process, by
+// provider
+func AllStats() []Stats {
+	registryMu.Lock()
+	callers := append([]*Caller(nil), registry...)
+	registryMu.Unlock()
+
+	byProvider := make(map[string]*Stats)
+	for _, c := range callers {
+		s := c.Stats()
+		total, ok := byProvider[s.Provider]
+		if !ok {
+			total = &Stats{Provider: s.Provider}
+			byProvider[s.Provider] = total
+		}
+		total.Calls += s.Calls
+		total.Retries += s.Retries
+		total.Failures += s.Failures
+		total.Rejected += s.Rejected
+		total.BreakerOpens += s.BreakerOpens
+		total.Waited += s.Waited
+	}
+
+	stats := make([]Stats, 0, len(byProvider))
+	for _, s := range byProvider {
+		stats = append(stats, *s)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Provider < stats[j].Provider })
+	return stats
+}
+
+// admit fails fast while the circuit is open. Once the cooldown passes the
+// next call is let through; if it fails the circuit opens again.
+func (c *Caller) admit() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now := time.Now(); now.Before(c.openUntil) {
+		c.stats.Rejected++
+		return fmt.Errorf("%s: %w, retrying after %s", c.provider, ErrCircuitOpen, c.openUntil.Sub(now).Round(time.Second))
+	}
+	return nil
+}
+
+// record tracks consecutive failures, opening the circuit at the threshold
+func (c *Caller) record(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err == nil {
+		c.failures = 0
+		return
+	}
+	c.stats.Failures++
+	c.failures++
+	if c.breakerThreshold > 0 && c.failures >= c.breakerThreshold {
+		c.openUntil = time.Now().Add(c.breakerCooldown)
+		c.stats.BreakerOpens++
+	}
+}
+
+// wait reserves the next call slot under the rate limit and sleeps until it
+func (c *Caller) wait(ctx context.Context) error {
+	if c.interval == 0 {
+		return nil
+	}
+	c.mu.Lock()
+	now := time.Now()
+	at := c.next
+	if at.Before(now) {
+		at = now
+	}
+	c.next = at.Add(c.interval)
+	delay := at.Sub(now)
+	c.stats.Waited += delay
+	c.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	return sleep(ctx, delay)
+}
+
+// backoff doubles the base delay per attempt up to the maximum, then picks
+// a random delay in its upper half so parallel callers spread out
+func (c *Caller) backoff(attempt int) time.Duration {
+	delay := c.baseDelay
+	for i := 0; i < attempt && delay < c.maxDelay; i++ {
+		delay *= 2
+	}
+	if c.maxDelay > 0 && delay > c.maxDelay {
+		delay = c.maxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,235 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/telemetry"
+)
+
+var (
+	errThrottled = errors.New("throttled")
+	errDenied    = errors.New("access denied")
+)
+
+// transient retries only throttling
+func transient(err error) bool {
+	return errors.Is(err, errThrottled)
+}
+
+// newCaller builds a caller with millisecond delays, failing the test on error
+func newCaller(t *testing.T, provider string, cfg config.CallConfig) *Caller {
+	t.Helper()
+	if cfg.BaseDelay == "" {
+		cfg.BaseDelay = "1ms"
+	}
+	c, err := New(provider, cfg, transient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// failing returns a call that fails with errs in turn, then succeeds
+func failing(calls *int, errs ...error) func(context.Context) error {
+	return func(context.Context) error {
+		*calls++
+		if *calls <= len(errs) {
+			return errs[*calls-1]
+		}
+		return nil
+	}
+}
+
+func TestDoRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		errs      []error
+		wantErr   error
+		wantCalls int
+		want      Stats
+	}{
+		{"success", nil, nil, 1, Stats{Calls: 1}},
+		{"recovers", []error{errThrottled, errThrottled}, nil, 3, Stats{Calls: 3, Retries: 2}},
+		{"gives up", []error{errThrottled, errThrottled, errThrottled, errThrottled}, errThrottled, 3, Stats{Calls: 3, Retries: 2, Failures: 1}},
+		{"not retryable", []error{errDenied}, errDenied, 1, Stats{Calls: 1, Failures: 1}},
+	}
+	for _, tt := range tests {
+		c := newCaller(t, "retries-"+tt.name, config.CallConfig{MaxRetries: 2})
+		var calls int
+		err := c.Do(context.Background(), failing(&calls, tt.errs...))
+		if !errors.Is(err, tt.wantErr) || err == nil && tt.wantErr != nil {
+			t.Errorf("%s: Do() = %v, want %v", tt.name, err, tt.wantErr)
+		}
+		if calls != tt.wantCalls {
+			t.Errorf("%s: called %d times, want %d", tt.name, calls, tt.wantCalls)
+		}
+		tt.want.Provider = "retries-" + tt.name
+		if got := c.Stats(); got != tt.want {
+			t.Errorf("%s: Stats() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDoRecordsRetries(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	ctx, span := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)).Tracer("test").Start(context.Background(), "GetCosts")
+	c := newCaller(t, "traced", config.CallConfig{MaxRetries: 3})
+	var calls int
+	if err := c.Do(ctx, failing(&calls, errThrottled, errThrottled)); err != nil {
+		t.Fatal(err)
+	}
+	span.End()
+
+	s := rec.Ended()[0]
+	if n := len(s.Events()); n != 2 || s.Events()[0].Name != "retry" {
+		t.Errorf("events = %+v, want 2 retries", s.Events())
+	}
+	var backoffs int64
+	for _, kv := range s.Attributes() {
+		if kv.Key == telemetry.AttrBackoffs {
+			backoffs = kv.Value.AsInt64()
+		}
+	}
+	if backoffs != 2 {
+		t.Errorf("%s = %d, want 2", telemetry.AttrBackoffs, backoffs)
+	}
+}
+
+func TestDoStopsOnCancel(t *testing.T) {
+	c := newCaller(t, "cancelled", config.CallConfig{MaxRetries: 3, BaseDelay: "1h", MaxDelay: "1h"})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	var calls int
+	if err := c.Do(ctx, failing(&calls, errThrottled)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Do() = %v, want the context's error", err)
+	}
+	if calls != 1 {
+		t.Errorf("called %d times, want 1", calls)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	c := newCaller(t, "breaker", config.CallConfig{BreakerThreshold: 2, BreakerCooldown: "50ms"})
+	ctx := context.Background()
+	var calls int
+	call := failing(&calls, errDenied, errDenied, errDenied)
+
+	for i := 0; i < 2; i++ {
+		if err := c.Do(ctx, call); !errors.Is(err, errDenied) {
+			t.Fatalf("call %d: Do() = %v, want %v", i, err, errDenied)
+		}
+	}
+	if err := c.Do(ctx, call); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("open circuit: Do() = %v, want %v", err, ErrCircuitOpen)
+	}
+	if calls != 2 {
+		t.Errorf("called %d times, want 2 with the circuit open", calls)
+	}
+
+	// After the cooldown one call is let through and its failure reopens
+	// the circuit
+	time.Sleep(60 * time.Millisecond)
+	if err := c.Do(ctx, call); !errors.Is(err, errDenied) {
+		t.Errorf("half-open: Do() = %v, want %v", err, errDenied)
+	}
+	if err := c.Do(ctx, call); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("reopened: Do() = %v, want %v", err, ErrCircuitOpen)
+	}
+
+	// A success closes it
+	time.Sleep(60 * time.Millisecond)
+	for i := 0; i < 2; i++ {
+		if err := c.Do(ctx, call); err != nil {
+			t.Errorf("closed: Do() = %v, want nil", err)
+		}
+	}
+
+	want := Stats{Provider: "breaker", Calls: 5, Failures: 3, Rejected: 2, BreakerOpens: 2}
+	if got := c.Stats(); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	c := newCaller(t, "paced", config.CallConfig{RateLimit: 50}) // one call per 20ms
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		if err := c.Do(context.Background(), func(context.Context) error { return nil }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+		t.Errorf("4 calls took %s, want at least 60ms at 50 a second", elapsed)
+	}
+	if w := c.Stats().Waited; w < 50*time.Millisecond {
+		t.Errorf("Waited = %s, want about 60ms", w)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	c := &Caller{baseDelay: time.Second, maxDelay: 5 * time.Second}
+	tests := []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{0, time.Second},
+		{1, 2 * time.Second},
+		{2, 4 * time.Second},
+		{3, 5 * time.Second},
+		{10, 5 * time.Second},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			if d := c.backoff(tt.attempt); d < tt.max/2 || d > tt.max {
+				t.Errorf("backoff(%d) = %s, want between %s and %s", tt.attempt, d, tt.max/2, tt.max)
+			}
+		}
+	}
+	if d := (&Caller{}).backoff(3); d != 0 {
+		t.Errorf("backoff without a base delay = %s, want 0", d)
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	for name, cfg := range map[string]config.CallConfig{
+		"negative rate":  {RateLimit: -1},
+		"bad base delay": {BaseDelay: "soon"},
+		"bad max delay":  {MaxDelay: "30"},
+		"bad cooldown":   {BreakerCooldown: "1 minute"},
+	} {
+		if _, err := New("invalid", cfg, transient); err == nil {
+			t.Errorf("%s: New() succeeded, want an error", name)
+		}
+	}
+}
+
+func TestAllStats(t *testing.T) {
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		c := newCaller(t, "totals", config.CallConfig{})
+		var calls int
+		c.Do(ctx, failing(&calls, errDenied))
+	}
+
+	var got *Stats
+	stats := AllStats()
+	for i := range stats {
+		if i > 0 && stats[i-1].Provider >= stats[i].Provider {
+			t.Errorf("AllStats() not sorted by provider: %s before %s", stats[i-1].Provider, stats[i].Provider)
+		}
+		if stats[i].Provider == "totals" {
+			got = &stats[i]
+		}
+	}
+	want := Stats{Provider: "totals", Calls: 2, Failures: 2}
+	if got == nil || *got != want {
+		t.Errorf("totals = %+v, want %+v", got, want)
+	}
+}
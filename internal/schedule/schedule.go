@@ -0,0 +1,186 @@
+// Package schedule runs jobs on cron schedules for the long-lived serve mode.
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Job is a run mode executed on a schedule
+type Job struct {
+	Name     string
+	Mode     string
+	Schedule string        // standard 5-field cron spec or a descriptor such as @daily or @every 6h
+	Timeout  time.Duration // 0 means no limit
+	Args     []string      // extra command-line flags for the mode
+}
+
+// RunFunc executes one run of a job. It must return once ctx is done.
+type RunFunc func(ctx context.Context, job Job) error
+
+// Status is the state of a scheduled job
+type Status struct {
+	Name      string    `json:"name"`
+	Mode      string    `json:"mode"`
+	Schedule  string    `json:"schedule"`
+	Running   bool      `json:"running"`
+	Next      time.Time `json:"next"`
+	LastStart time.Time `json:"last_start,omitempty"`
+	LastEnd   time.Time `json:"last_end,omitempty"`
+	LastError string    `json:"last_error,omitempty"`
+	Runs      int       `json:"runs"`
+	Failures  int       `json:"failures"`
+	Skipped   int       `json:"skipped"` // runs skipped because the previous one was still going
+}
+
+// Scheduler runs jobs on their schedules, never overlapping runs of the
+// same job
+type Scheduler struct {
+	cron    *cron.Cron
+	run     RunFunc
+	ctx     context.Context
+	cancel  context.CancelFunc
+	mu      sync.Mutex
+	status  map[string]*Status
+	entries map[string]cron.EntryID
+}
+
+// New validates the jobs and prepares a scheduler; call Start to begin
+func New(jobs []Job, run RunFunc) (*Scheduler, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Scheduler{
+		cron:    cron.New(),
+		run:     run,
+		ctx:     ctx,
+		cancel:  cancel,
+		status:  make(map[string]*Status),
+		entries: make(map[string]cron.EntryID),
+	}
+
+	for _, job := range jobs {
+		if job.Name == "" {
+			cancel()
+			return nil, fmt.Errorf("scheduled job for mode %q has no name", job.Mode)
+		}
+		if _, dup := s.status[job.Name]; dup {
+			cancel()
+			return nil, fmt.Errorf("duplicate scheduled job %q", job.Name)
+		}
+		if job.Timeout < 0 {
+			cancel()
+			return nil, fmt.Errorf("job %q: timeout must not be negative", job.Name)
+		}
+		job := job
+		id, err := s.cron.AddFunc(job.Schedule, func() { s.execute(job) })
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("job %q: invalid schedule %q: %w", job.Name, job.Schedule, err)
+		}
+		s.status[job.Name] = &Status{Name: job.Name, Mode: job.Mode, Schedule: job.Schedule}
+		s.entries[job.Name] = id
+	}
+	return s, nil
+}
+
+// Start begins running jobs on their schedules
+func (s *Scheduler) Start() {
+	s.cron.Start()
+}
+
+// Stop stops scheduling new runs and waits for running jobs to finish. When
+// ctx is done first, running jobs are cancelled and waited for.
+func (s *Scheduler) Stop(ctx context.Context) error {
+	stopped := s.cron.Stop()
+	select {
+	case <-stopped.Done():
+		s.cancel()
+		return nil
+	case <-ctx.Done():
+		s.cancel()
+		<-stopped.Done()
+		return fmt.Errorf("running jobs cancelled at shutdown: %w", ctx.Err())
+	}
+}
+
+// execute runs a job once unless its previous run is still going
+func (s *Scheduler) execute(job Job) {
+	s.mu.Lock()
+	st := s.status[job.Name]
+	if st.Running {
+		st.Skipped++
+		s.mu.Unlock()
+		log.Printf("Job %s: previous run still in progress, skipping", job.Name)
+		return
+	}
+	if s.ctx.Err() != nil {
+		s.mu.Unlock()
+		return
+	}
+	st.Running = true
+	st.LastStart = time.Now()
+	s.mu.Unlock()
+
+	ctx := s.ctx
+	if job.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, job.Timeout)
+		defer cancel()
+	}
+
+	log.Printf("Job %s: starting %s", job.Name, job.Mode)
+	err := s.run(ctx, job)
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("timed out after %s: %w", job.Timeout, err)
+	}
+
+	s.mu.Lock()
+	st.Running = false
+	st.LastEnd = time.Now()
+	st.Runs++
+	st.LastError = ""
+	if err != nil {
+		st.Failures++
+		st.LastError = err.Error()
+	}
+	elapsed := st.LastEnd.Sub(st.LastStart).Round(time.Second)
+	s.mu.Unlock()
+
+	if err != nil {
+		log.Printf("Job %s: failed after %s: %v", job.Name, elapsed, err)
+		return
+	}
+	log.Printf("Job %s: completed in %s", job.Name, elapsed)
+}
+
+// Statuses returns the state of every job, by name
+func (s *Scheduler) Statuses() []Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]Status, 0, len(s.status))
+	for name, st := range s.status {
+		status := *st
+		status.Next = s.cron.Entry(s.entries[name]).Next
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
+
+// Handler serves the job statuses as JSON, for health checks
+func (s *Scheduler) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.Statuses())
+	})
+}
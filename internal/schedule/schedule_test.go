@@ -0,0 +1,172 @@
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// noop is a RunFunc that succeeds at once
+func noop(ctx context.Context, job Job) error { return nil }
+
+// status returns the named job's status
+func status(t *testing.T, s *Scheduler, name string) Status {
+	t.Helper()
+	for _, st := range s.Statuses() {
+		if st.Name == name {
+			return st
+		}
+	}
+	t.Fatalf("no status for %s", name)
+	return Status{}
+}
+
+func TestNewValidates(t *testing.T) {
+	tests := []struct {
+		name    string
+		jobs    []Job
+		wantErr string
+	}{
+		{"valid", []Job{{Name: "daily", Mode: "aggregate", Schedule: "0 6 * * *"}, {Name: "often", Mode: "anomaly", Schedule: "@every 6h"}}, ""},
+		{"no name", []Job{{Mode: "aggregate", Schedule: "@daily"}}, "has no name"},
+		{"duplicate", []Job{{Name: "a", Schedule: "@daily"}, {Name: "a", Schedule: "@hourly"}}, `duplicate scheduled job "a"`},
+		{"negative timeout", []Job{{Name: "a", Schedule: "@daily", Timeout: -time.Second}}, "timeout must not be negative"},
+		{"bad schedule", []Job{{Name: "a", Schedule: "every day"}}, `invalid schedule "every day"`},
+	}
+	for _, tt := range tests {
+		_, err := New(tt.jobs, noop)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: New() = %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: New() = %v, want %s", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestExecuteRecordsRuns(t *testing.T) {
+	fail := errors.New("provider down")
+	var next error
+	job := Job{Name: "agg", Mode: "aggregate", Schedule: "@daily"}
+	s, err := New([]Job{job}, func(ctx context.Context, j Job) error { return next })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next = fail
+	s.execute(job)
+	if st := status(t, s, "agg"); st.Runs != 1 || st.Failures != 1 || st.LastError != "provider down" || st.Running {
+		t.Errorf("after a failure status = %+v", st)
+	}
+	next = nil
+	s.execute(job)
+	if st := status(t, s, "agg"); st.Runs != 2 || st.Failures != 1 || st.LastError != "" || st.LastEnd.Before(st.LastStart) {
+		t.Errorf("after a success status = %+v", st)
+	}
+}
+
+func TestExecuteSkipsOverlap(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	job := Job{Name: "slow", Schedule: "@hourly"}
+	s, err := New([]Job{job}, func(ctx context.Context, j Job) error {
+		close(started)
+		<-release
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.execute(job)
+		close(done)
+	}()
+	<-started
+	if st := status(t, s, "slow"); !st.Running {
+		t.Errorf("status = %+v, want running", st)
+	}
+	s.execute(job) // returns at once, the first run is still going
+	close(release)
+	<-done
+
+	if st := status(t, s, "slow"); st.Runs != 1 || st.Skipped != 1 {
+		t.Errorf("status = %+v, want 1 run and 1 skipped", st)
+	}
+}
+
+func TestExecuteTimeout(t *testing.T) {
+	job := Job{Name: "stuck", Schedule: "@hourly", Timeout: 10 * time.Millisecond}
+	s, err := New([]Job{job}, func(ctx context.Context, j Job) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.execute(job)
+	if st := status(t, s, "stuck"); st.Failures != 1 || !strings.HasPrefix(st.LastError, "timed out after 10ms") {
+		t.Errorf("status = %+v, want a timeout failure", st)
+	}
+}
+
+func TestStopCancelsRunningJobs(t *testing.T) {
+	started := make(chan struct{}, 1)
+	job := Job{Name: "long", Schedule: "@every 1s"}
+	s, err := New([]Job{job}, func(ctx context.Context, j Job) error {
+		started <- struct{}{}
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Start()
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("job never started")
+	}
+
+	// The job only ends when cancelled, so a Stop deadline cancels it
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := s.Stop(ctx); err == nil || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Stop() = %v, want a cancelled-at-shutdown error", err)
+	}
+
+	// Nothing runs once stopped
+	s.execute(job)
+	if st := status(t, s, "long"); st.Runs != 1 || st.Running {
+		t.Errorf("status = %+v, want the cancelled run and no other", st)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	s, err := New([]Job{{Name: "b", Mode: "anomaly", Schedule: "@hourly"}, {Name: "a", Mode: "aggregate", Schedule: "@daily"}}, noop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Start()
+	defer s.Stop(context.Background())
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/status", nil))
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %s", ct)
+	}
+	var statuses []Status
+	if err := json.Unmarshal(rec.Body.Bytes(), &statuses); err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 2 || statuses[0].Name != "a" || statuses[1].Mode != "anomaly" || statuses[0].Next.IsZero() {
+		t.Errorf("statuses = %+v, want a then b with their next runs", statuses)
+	}
+}
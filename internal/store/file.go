@@ -0,0 +1,387 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+// FileStore keeps one JSON file per day of line items and one per month of
+// rollups under a directory
+type FileStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewFileStore opens (creating if needed) a file store rooted at dir
+func NewFileStore(dir string) (*FileStore, error) {
+	for _, sub := range []string{"daily", "monthly", "checkpoints", "cache", "anomalies"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create store directory: %w", err)
+		}
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// SaveRecords writes records grouped by day, replacing each day's file and
+// leaving out days a monthly rollup already counts
+func (s *FileStore) SaveRecords(ctx context.Context, records []normalizer.CostRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	byMonth := make(map[string][]normalizer.CostRecord)
+	for _, r := range records {
+		month := r.Date.Format("2006-01")
+		byMonth[month] = append(byMonth[month], r)
+	}
+
+	byDay := make(map[string][]normalizer.CostRecord)
+	for month, monthRecords := range byMonth {
+		rollups, err := readJSON(s.monthlyPath(month))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		kept, _ := splitCovered(monthRecords, rollups)
+		for _, r := range kept {
+			day := r.Date.Format("2006-01-02")
+			byDay[day] = append(byDay[day], r)
+		}
+	}
+
+	for day, dayRecords := range byDay {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := writeJSON(s.dailyPath(day), dayRecords); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// QueryRange returns daily records and monthly rollups dated within [start, end)
+func (s *FileStore) QueryRange(ctx context.Context, start, end time.Time) ([]normalizer.CostRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var records []normalizer.CostRecord
+	for _, sub := range []string{"daily", "monthly"} {
+		files, err := s.list(sub)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			stored, err := readJSON(f.path)
+			if err != nil {
+				return nil, err
+			}
+			for _, r := range stored {
+				if !r.Date.Before(start) && r.Date.Before(end) {
+					records = append(records, r)
+				}
+			}
+		}
+	}
+	return records, nil
+}
+
+// LatestIngestDate returns the newest day with stored line items
+func (s *FileStore) LatestIngestDate(ctx context.Context) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	files, err := s.list("daily")
+	if err != nil || len(files) == 0 {
+		return time.Time{}, err
+	}
+	return files[len(files)-1].date, nil
+}
+
+// Prune rolls up whole months older than the daily window and deletes
+// rollups older than the monthly window
+func (s *FileStore) Prune(ctx context.Context, policy RetentionPolicy, now time.Time) (PruneStats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var stats PruneStats
+
+	if policy.DailyDays > 0 {
+		cutoff := now.AddDate(0, 0, -policy.DailyDays)
+		// Only months that ended before the cutoff are rolled up, so a month
+		// is never split between daily files and a rollup
+		monthCutoff := time.Date(cutoff.Year(), cutoff.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+		files, err := s.list("daily")
+		if err != nil {
+			return stats, err
+		}
+
+		byMonth := make(map[string][]storedFile)
+		for _, f := range files {
+			if f.date.Before(monthCutoff) {
+				month := f.date.Format("2006-01")
+				byMonth[month] = append(byMonth[month], f)
+			}
+		}
+
+		for month, days := range byMonth {
+			if err := ctx.Err(); err != nil {
+				return stats, err
+			}
+
+			existing, err := readJSON(s.monthlyPath(month))
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return stats, err
+			}
+			var daily []normalizer.CostRecord
+			for _, f := range days {
+				dayRecords, err := readJSON(f.path)
+				if err != nil {
+					return stats, err
+				}
+				daily = append(daily, dayRecords...)
+			}
+			kept, duplicates := splitCovered(daily, existing)
+			stats.RecordsBefore += len(daily)
+			stats.Duplicates += len(duplicates)
+
+			rolled := Rollup(append(existing, kept...), policy.KeepTags)
+			if err := writeJSON(s.monthlyPath(month), rolled); err != nil {
+				return stats, err
+			}
+			for _, f := range days {
+				if err := os.Remove(f.path); err != nil {
+					return stats, fmt.Errorf("failed to remove %s: %w", f.path, err)
+				}
+			}
+
+			stats.RecordsBefore += len(existing)
+			stats.RecordsAfter += len(rolled)
+			stats.DaysRolledUp += len(days)
+			stats.MonthsRolledUp++
+		}
+	}
+
+	if policy.MonthlyMonths > 0 {
+		current := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		cutoff := current.AddDate(0, -policy.MonthlyMonths, 0)
+
+		files, err := s.list("monthly")
+		if err != nil {
+			return stats, err
+		}
+		for _, f := range files {
+			if !f.date.Before(cutoff) {
+				continue
+			}
+			if err := os.Remove(f.path); err != nil {
+				return stats, fmt.Errorf("failed to remove %s: %w", f.path, err)
+			}
+			stats.MonthsDeleted++
+		}
+	}
+
+	return stats, nil
+}
+
+// SaveCheckpoint writes a job's progress to its own file
+func (s *FileStore) SaveCheckpoint(ctx context.Context, cp Checkpoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoint: %w", err)
+	}
+	return writeFile(s.checkpointPath(cp.Job), data)
+}
+
+// LoadCheckpoint reads a job's progress
+func (s *FileStore) LoadCheckpoint(ctx context.Context, job string) (Checkpoint, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.checkpointPath(job))
+	if errors.Is(err, os.ErrNotExist) {
+		return Checkpoint{}, false, nil
+	}
+	if err != nil {
+		return Checkpoint{}, false, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return Checkpoint{}, false, fmt.Errorf("failed to parse checkpoint %s: %w", job, err)
+	}
+	return cp, true, nil
+}
+
+// SaveCache writes a cache entry to its own file
+func (s *FileStore) SaveCache(ctx context.Context, key string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return writeFile(s.cachePath(key), data)
+}
+
+// LoadCache reads a cache entry
+func (s *FileStore) LoadCache(ctx context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.cachePath(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read cache entry: %w", err)
+	}
+	return data, true, nil
+}
+
+// SaveAnomalyState writes an anomaly's state to its own file
+func (s *FileStore) SaveAnomalyState(ctx context.Context, st AnomalyState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := json.Marshal(st)
+	if err != nil {
+		return fmt.Errorf("failed to encode anomaly state: %w", err)
+	}
+	return writeFile(s.anomalyPath(st.ID), data)
+}
+
+// LoadAnomalyStates reads every anomaly state file
+func (s *FileStore) LoadAnomalyStates(ctx context.Context) ([]AnomalyState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := os.ReadDir(filepath.Join(s.dir, "anomalies"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read anomaly states: %w", err)
+	}
+
+	var states []AnomalyState
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok || e.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(s.anomalyPath(id))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read anomaly state: %w", err)
+		}
+		var st AnomalyState
+		if err := json.Unmarshal(data, &st); err != nil {
+			return nil, fmt.Errorf("failed to parse anomaly state %s: %w", id, err)
+		}
+		states = append(states, st)
+	}
+	return states, nil
+}
+
+// Close is a no-op; files are closed after each operation
+func (s *FileStore) Close() error {
+	return nil
+}
+
+type storedFile struct {
+	path string
+	date time.Time
+}
+
+// list returns the files in a subdirectory in date order
+func (s *FileStore) list(sub string) ([]storedFile, error) {
+	layout := "2006-01-02"
+	if sub == "monthly" {
+		layout = "2006-01"
+	}
+
+	entries, err := os.ReadDir(filepath.Join(s.dir, sub))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read store: %w", err)
+	}
+
+	var files []storedFile
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok || e.IsDir() {
+			continue
+		}
+		date, err := time.Parse(layout, name)
+		if err != nil {
+			continue
+		}
+		files = append(files, storedFile{path: filepath.Join(s.dir, sub, e.Name()), date: date})
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].date.Before(files[j].date)
+	})
+	return files, nil
+}
+
+func (s *FileStore) dailyPath(day string) string {
+	return filepath.Join(s.dir, "daily", day+".json")
+}
+
+func (s *FileStore) monthlyPath(month string) string {
+	return filepath.Join(s.dir, "monthly", month+".json")
+}
+
+func (s *FileStore) checkpointPath(job string) string {
+	return filepath.Join(s.dir, "checkpoints", job+".json")
+}
+
+func (s *FileStore) cachePath(key string) string {
+	return filepath.Join(s.dir, "cache", key+".json")
+}
+
+func (s *FileStore) anomalyPath(id string) string {
+	return filepath.Join(s.dir, "anomalies", id+".json")
+}
+
+func readJSON(path string) ([]normalizer.CostRecord, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	var records []normalizer.CostRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return records, nil
+}
+
+// writeJSON writes through a temporary file so a crash never leaves a
+// half-written day behind
+func writeJSON(path string, records []normalizer.CostRecord) error {
+	data, err := json.Marshal(records)
+	if err != nil {
+		return fmt.Errorf("failed to encode records: %w", err)
+	}
+	return writeFile(path, data)
+}
+
+func writeFile(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
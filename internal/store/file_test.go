@@ -0,0 +1,143 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+func total(records []normalizer.CostRecord) float64 {
+	var sum float64
+	for _, r := range records {
+		sum += r.Cost
+	}
+	return sum
+}
+
+func TestFileStoreSaveReplacesDays(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := january.AddDate(0, 0, 4)
+	if err := s.SaveRecords(ctx, []normalizer.CostRecord{line("EC2", "web", day, 10), line("S3", "web", day.AddDate(0, 0, 1), 5)}); err != nil {
+		t.Fatal(err)
+	}
+	// Re-ingesting a day replaces it and leaves the next one alone
+	if err := s.SaveRecords(ctx, []normalizer.CostRecord{line("EC2", "web", day, 12)}); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := s.QueryRange(ctx, january, january.AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || total(records) != 17 {
+		t.Errorf("got %d records totalling %v, want 2 totalling 17", len(records), total(records))
+	}
+	if records, _ := s.QueryRange(ctx, day.AddDate(0, 0, 1), january.AddDate(0, 1, 0)); len(records) != 1 {
+		t.Errorf("range from Jan 6 holds %d records, want 1", len(records))
+	}
+	latest, err := s.LatestIngestDate(ctx)
+	if err != nil || !latest.Equal(day.AddDate(0, 0, 1)) {
+		t.Errorf("LatestIngestDate = %s, %v; want 2024-01-06", latest, err)
+	}
+}
+
+func TestFileStorePrune(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var records []normalizer.CostRecord
+	for _, month := range []time.Time{january.AddDate(0, -2, 0), january, january.AddDate(0, 2, 0)} {
+		for d := 0; d < 3; d++ {
+			records = append(records, line("EC2", "web", month.AddDate(0, 0, d), 10), line("EC2", "data", month.AddDate(0, 0, d), 1))
+		}
+	}
+	if err := s.SaveRecords(ctx, records); err != nil {
+		t.Fatal(err)
+	}
+
+	// On April 20 with 30 daily days, November and January are rolled up
+	// and, with 4 monthly months, November is then deleted
+	now := time.Date(2024, 4, 20, 0, 0, 0, 0, time.UTC)
+	stats, err := s.Prune(ctx, RetentionPolicy{DailyDays: 30, MonthlyMonths: 4, KeepTags: []string{"team"}}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := PruneStats{DaysRolledUp: 6, MonthsRolledUp: 2, MonthsDeleted: 1, RecordsBefore: 12, RecordsAfter: 4}
+	if stats != want {
+		t.Errorf("stats = %+v, want %+v", stats, want)
+	}
+
+	all, err := s.QueryRange(ctx, january.AddDate(-1, 0, 0), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 8 || total(all) != 66 {
+		t.Errorf("got %d records totalling %v, want January's 2 rollups and March's 6 days totalling 66", len(all), total(all))
+	}
+
+	// Re-ingesting a rolled-up month does not count it twice
+	if err := s.SaveRecords(ctx, records[6:12]); err != nil {
+		t.Fatal(err)
+	}
+	jan, err := s.QueryRange(ctx, january, january.AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jan) != 2 || total(jan) != 33 {
+		t.Errorf("January holds %d records totalling %v, want 2 rollups totalling 33", len(jan), total(jan))
+	}
+}
+
+func TestFileStoreBackfillsRolledUpMonth(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	early := []normalizer.CostRecord{line("EC2", "web", january, 10), line("EC2", "web", january.AddDate(0, 0, 1), 10)}
+	if err := s.SaveRecords(ctx, early); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Prune(ctx, RetentionPolicy{DailyDays: 30}, time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The rolled-up days are dropped, a day the rollup lacks is kept
+	if err := s.SaveRecords(ctx, append(early, line("EC2", "web", january.AddDate(0, 0, 9), 7))); err != nil {
+		t.Fatal(err)
+	}
+	records, err := s.QueryRange(ctx, january, january.AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || total(records) != 27 {
+		t.Errorf("January holds %d records totalling %v, want the rollup and Jan 10 totalling 27", len(records), total(records))
+	}
+}
+
+func TestFileStoreCache(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := s.LoadCache(ctx, "k"); ok || err != nil {
+		t.Fatalf("missing cache entry = %v, %v; want not found", ok, err)
+	}
+	for _, v := range []string{"v1", "v2"} {
+		if err := s.SaveCache(ctx, "k", []byte(v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if data, ok, err := s.LoadCache(ctx, "k"); err != nil || !ok || string(data) != "v2" {
+		t.Errorf("cache = %q, %v, %v; want v2", data, ok, err)
+	}
+}
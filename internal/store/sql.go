@@ -0,0 +1,426 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/lib/pq"           // postgres driver
+	_ "github.com/mattn/go-sqlite3" // sqlite3 driver
+
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+// Store drivers
+const (
+	DriverFile     = "file"
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+)
+
+// sqlSchema works unchanged on SQLite and PostgreSQL. Records are kept whole
+// as versioned JSON, so older rows are migrated on read like file-store data;
+// the other columns exist for filtering and ad-hoc queries.
+var sqlSchema = []string{
+	`CREATE TABLE IF NOT EXISTS cost_records (
+		day     TEXT NOT NULL,
+		rollup  INTEGER NOT NULL,
+		cloud   TEXT NOT NULL,
+		account TEXT NOT NULL,
+		service TEXT NOT NULL,
+		region  TEXT NOT NULL,
+		cost    DOUBLE PRECISION NOT NULL,
+		record  TEXT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS cost_records_day ON cost_records (rollup, day)`,
+	`CREATE TABLE IF NOT EXISTS checkpoints (
+		job  TEXT PRIMARY KEY,
+		data TEXT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS cache (
+		key  TEXT PRIMARY KEY,
+		data TEXT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS anomaly_states (
+		id   TEXT PRIMARY KEY,
+		data TEXT NOT NULL
+	)`,
+}
+
+// SQLStore keeps records in a SQLite or PostgreSQL database: line items by
+// day, and monthly rollups dated the first of their month
+type SQLStore struct {
+	db       *sql.DB
+	postgres bool
+}
+
+// NewSQLStore opens a store with a database/sql driver name ("sqlite3" or
+// "postgres") and creates its tables if needed
+func NewSQLStore(ctx context.Context, driver, dsn string) (*SQLStore, error) {
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s store: %w", driver, err)
+	}
+	s := &SQLStore{db: db, postgres: driver == "postgres"}
+	if !s.postgres {
+		db.SetMaxOpenConns(1) // SQLite allows one writer at a time
+	}
+
+	for _, stmt := range sqlSchema {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to create %s store schema: %w", driver, err)
+		}
+	}
+	return s, nil
+}
+
+// SaveRecords replaces the line items of every day in records, leaving out
+// days a monthly rollup already counts
+func (s *SQLStore) SaveRecords(ctx context.Context, records []normalizer.CostRecord) error {
+	byMonth := make(map[string][]normalizer.CostRecord)
+	for _, r := range records {
+		first := r.Date.Format("2006-01") + "-01"
+		byMonth[first] = append(byMonth[first], r)
+	}
+
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		byDay := make(map[string][]normalizer.CostRecord)
+		for first, monthRecords := range byMonth {
+			rows, err := tx.QueryContext(ctx, s.bind(`SELECT record FROM cost_records WHERE rollup = 1 AND day = ?`), first)
+			if err != nil {
+				return fmt.Errorf("failed to query store: %w", err)
+			}
+			rollups, err := scanRecords(rows)
+			if err != nil {
+				return err
+			}
+			kept, _ := splitCovered(monthRecords, rollups)
+			for _, r := range kept {
+				day := r.Date.Format("2006-01-02")
+				byDay[day] = append(byDay[day], r)
+			}
+		}
+
+		for day, dayRecords := range byDay {
+			if _, err := tx.ExecContext(ctx, s.bind(`DELETE FROM cost_records WHERE rollup = 0 AND day = ?`), day); err != nil {
+				return fmt.Errorf("failed to replace %s: %w", day, err)
+			}
+			if err := s.insert(ctx, tx, dayRecords, false); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// QueryRange returns daily records and monthly rollups dated within [start, end)
+func (s *SQLStore) QueryRange(ctx context.Context, start, end time.Time) ([]normalizer.CostRecord, error) {
+	// Days are compared as text, so widen to whole days and filter exactly below
+	rows, err := s.db.QueryContext(ctx, s.bind(`SELECT record FROM cost_records WHERE day >= ? AND day <= ? ORDER BY rollup DESC, day`),
+		start.Format("2006-01-02"), end.Format("2006-01-02"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query store: %w", err)
+	}
+	stored, err := scanRecords(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	records := stored[:0]
+	for _, r := range stored {
+		if !r.Date.Before(start) && r.Date.Before(end) {
+			records = append(records, r)
+		}
+	}
+	return records, nil
+}
+
+// LatestIngestDate returns the newest day with stored line items
+func (s *SQLStore) LatestIngestDate(ctx context.Context) (time.Time, error) {
+	var day sql.NullString
+	if err := s.db.QueryRowContext(ctx, `SELECT MAX(day) FROM cost_records WHERE rollup = 0`).Scan(&day); err != nil {
+		return time.Time{}, fmt.Errorf("failed to query store: %w", err)
+	}
+	if !day.Valid {
+		return time.Time{}, nil
+	}
+	return time.Parse("2006-01-02", day.String)
+}
+
+// Prune rolls up whole months older than the daily window and deletes
+// rollups older than the monthly window, in one transaction
+func (s *SQLStore) Prune(ctx context.Context, policy RetentionPolicy, now time.Time) (PruneStats, error) {
+	var stats PruneStats
+
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		if policy.DailyDays > 0 {
+			cutoff := now.AddDate(0, 0, -policy.DailyDays)
+			// Only months that ended before the cutoff are rolled up, so a
+			// month is never split between line items and a rollup
+			monthCutoff := time.Date(cutoff.Year(), cutoff.Month(), 1, 0, 0, 0, 0, time.UTC).Format("2006-01-02")
+
+			rows, err := tx.QueryContext(ctx, s.bind(`SELECT record FROM cost_records WHERE rollup = 0 AND day < ?`), monthCutoff)
+			if err != nil {
+				return fmt.Errorf("failed to query store: %w", err)
+			}
+			daily, err := scanRecords(rows)
+			if err != nil {
+				return err
+			}
+
+			byMonth := make(map[string][]normalizer.CostRecord)
+			days := make(map[string]map[string]bool)
+			for _, r := range daily {
+				month := r.Date.Format("2006-01")
+				byMonth[month] = append(byMonth[month], r)
+				if days[month] == nil {
+					days[month] = make(map[string]bool)
+				}
+				days[month][r.Date.Format("2006-01-02")] = true
+			}
+
+			months := make([]string, 0, len(byMonth))
+			for month := range byMonth {
+				months = append(months, month)
+			}
+			sort.Strings(months)
+
+			for _, month := range months {
+				first := month + "-01"
+				rows, err := tx.QueryContext(ctx, s.bind(`SELECT record FROM cost_records WHERE rollup = 1 AND day = ?`), first)
+				if err != nil {
+					return fmt.Errorf("failed to query store: %w", err)
+				}
+				existing, err := scanRecords(rows)
+				if err != nil {
+					return err
+				}
+
+				kept, duplicates := splitCovered(byMonth[month], existing)
+				rolled := Rollup(append(existing, kept...), policy.KeepTags)
+				next, _ := time.Parse("2006-01-02", first)
+				_, err = tx.ExecContext(ctx, s.bind(`DELETE FROM cost_records WHERE day >= ? AND day < ?`),
+					first, next.AddDate(0, 1, 0).Format("2006-01-02"))
+				if err != nil {
+					return fmt.Errorf("failed to roll up %s: %w", month, err)
+				}
+				if err := s.insert(ctx, tx, rolled, true); err != nil {
+					return err
+				}
+
+				stats.RecordsBefore += len(existing) + len(byMonth[month])
+				stats.RecordsAfter += len(rolled)
+				stats.Duplicates += len(duplicates)
+				stats.DaysRolledUp += len(days[month])
+				stats.MonthsRolledUp++
+			}
+		}
+
+		if policy.MonthlyMonths > 0 {
+			current := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+			cutoff := current.AddDate(0, -policy.MonthlyMonths, 0).Format("2006-01-02")
+
+			var months int
+			err := tx.QueryRowContext(ctx, s.bind(`SELECT COUNT(DISTINCT day) FROM cost_records WHERE rollup = 1 AND day < ?`), cutoff).Scan(&months)
+			if err != nil {
+				return fmt.Errorf("failed to query store: %w", err)
+			}
+			if _, err := tx.ExecContext(ctx, s.bind(`DELETE FROM cost_records WHERE rollup = 1 AND day < ?`), cutoff); err != nil {
+				return fmt.Errorf("failed to delete expired rollups: %w", err)
+			}
+			stats.MonthsDeleted = months
+		}
+		return nil
+	})
+	return stats, err
+}
+
+// SaveCheckpoint upserts a job's progress
+func (s *SQLStore) SaveCheckpoint(ctx context.Context, cp Checkpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoint: %w", err)
+	}
+	_, err = s.db.ExecContext(ctx, s.bind(`INSERT INTO checkpoints (job, data) VALUES (?, ?)
+		ON CONFLICT (job) DO UPDATE SET data = excluded.data`), cp.Job, string(data))
+	if err != nil {
+		return fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+	return nil
+}
+
+// LoadCheckpoint reads a job's progress
+func (s *SQLStore) LoadCheckpoint(ctx context.Context, job string) (Checkpoint, bool, error) {
+	var data string
+	err := s.db.QueryRowContext(ctx, s.bind(`SELECT data FROM checkpoints WHERE job = ?`), job).Scan(&data)
+	if err == sql.ErrNoRows {
+		return Checkpoint{}, false, nil
+	}
+	if err != nil {
+		return Checkpoint{}, false, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+
+	var cp Checkpoint
+	if err := json.Unmarshal([]byte(data), &cp); err != nil {
+		return Checkpoint{}, false, fmt.Errorf("failed to parse checkpoint %s: %w", job, err)
+	}
+	return cp, true, nil
+}
+
+// SaveCache upserts a cache entry
+func (s *SQLStore) SaveCache(ctx context.Context, key string, data []byte) error {
+	_, err := s.db.ExecContext(ctx, s.bind(`INSERT INTO cache (key, data) VALUES (?, ?)
+		ON CONFLICT (key) DO UPDATE SET data = excluded.data`), key, string(data))
+	if err != nil {
+		return fmt.Errorf("failed to save cache entry: %w", err)
+	}
+	return nil
+}
+
+// LoadCache reads a cache entry
+func (s *SQLStore) LoadCache(ctx context.Context, key string) ([]byte, bool, error) {
+	var data string
+	err := s.db.QueryRowContext(ctx, s.bind(`SELECT data FROM cache WHERE key = ?`), key).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read cache entry: %w", err)
+	}
+	return []byte(data), true, nil
+}
+
+// SaveAnomalyState upserts an anomaly's state
+func (s *SQLStore) SaveAnomalyState(ctx context.Context, st AnomalyState) error {
+	data, err := json.Marshal(st)
+	if err != nil {
+		return fmt.Errorf("failed to encode anomaly state: %w", err)
+	}
+	_, err = s.db.ExecContext(ctx, s.bind(`INSERT INTO anomaly_states (id, data) VALUES (?, ?)
+		ON CONFLICT (id) DO UPDATE SET data = excluded.data`), st.ID, string(data))
+	if err != nil {
+		return fmt.Errorf("failed to save anomaly state: %w", err)
+	}
+	return nil
+}
+
+// LoadAnomalyStates reads every anomaly state
+func (s *SQLStore) LoadAnomalyStates(ctx context.Context) ([]AnomalyState, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT data FROM anomaly_states ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read anomaly states: %w", err)
+	}
+	defer rows.Close()
+
+	var states []AnomalyState
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("failed to read anomaly states: %w", err)
+		}
+		var st AnomalyState
+		if err := json.Unmarshal([]byte(data), &st); err != nil {
+			return nil, fmt.Errorf("failed to parse anomaly state: %w", err)
+		}
+		states = append(states, st)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read anomaly states: %w", err)
+	}
+	return states, nil
+}
+
+// Close closes the database
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
+
+// insert writes records as line items, or as rollups dated their month
+func (s *SQLStore) insert(ctx context.Context, tx *sql.Tx, records []normalizer.CostRecord, rollup bool) error {
+	if len(records) == 0 {
+		return nil
+	}
+	stmt, err := tx.PrepareContext(ctx, s.bind(`INSERT INTO cost_records (day, rollup, cloud, account, service, region, cost, record) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`))
+	if err != nil {
+		return fmt.Errorf("failed to prepare insert: %w", err)
+	}
+	defer stmt.Close()
+
+	flag := 0
+	if rollup {
+		flag = 1
+	}
+	for _, r := range records {
+		data, err := json.Marshal(r)
+		if err != nil {
+			return fmt.Errorf("failed to encode records: %w", err)
+		}
+		_, err = stmt.ExecContext(ctx, r.Date.Format("2006-01-02"), flag, r.Cloud, r.Account, r.Service, r.Region, r.Cost, string(data))
+		if err != nil {
+			return fmt.Errorf("failed to insert records: %w", err)
+		}
+	}
+	return nil
+}
+
+// inTx runs fn in a transaction, committing only if it succeeds
+func (s *SQLStore) inTx(ctx context.Context, fn func(*sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit: %w", err)
+	}
+	return nil
+}
+
+// bind rewrites ? placeholders as $1, $2, ... for PostgreSQL
+func (s *SQLStore) bind(query string) string {
+	if !s.postgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// scanRecords decodes and closes a result set of record JSON
+func scanRecords(rows *sql.Rows) ([]normalizer.CostRecord, error) {
+	defer rows.Close()
+
+	var records []normalizer.CostRecord
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("failed to read store: %w", err)
+		}
+		var r normalizer.CostRecord
+		if err := json.Unmarshal([]byte(data), &r); err != nil {
+			return nil, fmt.Errorf("failed to parse stored record: %w", err)
+		}
+		records = append(records, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read store: %w", err)
+	}
+	return records, nil
+}
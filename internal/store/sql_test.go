@@ -0,0 +1,187 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+// newSQLite opens a SQLite store in a temporary directory
+func newSQLite(t *testing.T) *SQLStore {
+	t.Helper()
+	s, err := NewSQLStore(context.Background(), "sqlite3", filepath.Join(t.TempDir(), "costs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestSQLStoreSaveReplacesDays(t *testing.T) {
+	ctx := context.Background()
+	s := newSQLite(t)
+
+	latest, err := s.LatestIngestDate(ctx)
+	if err != nil || !latest.IsZero() {
+		t.Errorf("empty store LatestIngestDate = %s, %v; want zero", latest, err)
+	}
+
+	day := january.AddDate(0, 0, 4)
+	if err := s.SaveRecords(ctx, []normalizer.CostRecord{line("EC2", "web", day, 10), line("S3", "web", day.AddDate(0, 0, 1), 5)}); err != nil {
+		t.Fatal(err)
+	}
+	// Re-ingesting a day replaces it and leaves the next one alone
+	if err := s.SaveRecords(ctx, []normalizer.CostRecord{line("EC2", "web", day, 12)}); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := s.QueryRange(ctx, january, january.AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || total(records) != 17 {
+		t.Errorf("got %d records totalling %v, want 2 totalling 17", len(records), total(records))
+	}
+	if records[0].Tags["team"] != "web" || records[0].Resource != "r-web" {
+		t.Errorf("stored record = %+v, want it whole", records[0])
+	}
+	if records, _ := s.QueryRange(ctx, day.AddDate(0, 0, 1), january.AddDate(0, 1, 0)); len(records) != 1 {
+		t.Errorf("range from Jan 6 holds %d records, want 1", len(records))
+	}
+	latest, err = s.LatestIngestDate(ctx)
+	if err != nil || !latest.Equal(day.AddDate(0, 0, 1)) {
+		t.Errorf("LatestIngestDate = %s, %v; want 2024-01-06", latest, err)
+	}
+}
+
+func TestSQLStorePrune(t *testing.T) {
+	ctx := context.Background()
+	s := newSQLite(t)
+	var records []normalizer.CostRecord
+	for _, month := range []time.Time{january.AddDate(0, -2, 0), january, january.AddDate(0, 2, 0)} {
+		for d := 0; d < 3; d++ {
+			records = append(records, line("EC2", "web", month.AddDate(0, 0, d), 10), line("EC2", "data", month.AddDate(0, 0, d), 1))
+		}
+	}
+	if err := s.SaveRecords(ctx, records); err != nil {
+		t.Fatal(err)
+	}
+
+	// The same retention as TestFileStorePrune gives the same result
+	now := time.Date(2024, 4, 20, 0, 0, 0, 0, time.UTC)
+	stats, err := s.Prune(ctx, RetentionPolicy{DailyDays: 30, MonthlyMonths: 4, KeepTags: []string{"team"}}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := PruneStats{DaysRolledUp: 6, MonthsRolledUp: 2, MonthsDeleted: 1, RecordsBefore: 12, RecordsAfter: 4}
+	if stats != want {
+		t.Errorf("stats = %+v, want %+v", stats, want)
+	}
+
+	all, err := s.QueryRange(ctx, january.AddDate(-1, 0, 0), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 8 || total(all) != 66 {
+		t.Errorf("got %d records totalling %v, want January's 2 rollups and March's 6 days totalling 66", len(all), total(all))
+	}
+
+	// Re-ingesting a rolled-up month does not count it twice
+	if err := s.SaveRecords(ctx, records[6:12]); err != nil {
+		t.Fatal(err)
+	}
+	jan, err := s.QueryRange(ctx, january, january.AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jan) != 2 || total(jan) != 33 {
+		t.Errorf("January holds %d records totalling %v, want 2 rollups totalling 33", len(jan), total(jan))
+	}
+}
+
+func TestSQLStoreState(t *testing.T) {
+	ctx := context.Background()
+	s := newSQLite(t)
+
+	if _, ok, err := s.LoadCheckpoint(ctx, "ingest"); ok || err != nil {
+		t.Fatalf("missing checkpoint = %v, %v; want not found", ok, err)
+	}
+	for _, completed := range []time.Time{january, january.AddDate(0, 0, 7)} {
+		if err := s.SaveCheckpoint(ctx, Checkpoint{Job: "ingest", Completed: completed}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cp, ok, err := s.LoadCheckpoint(ctx, "ingest")
+	if err != nil || !ok || !cp.Completed.Equal(january.AddDate(0, 0, 7)) {
+		t.Errorf("checkpoint = %+v, %v, %v; want the second save", cp, ok, err)
+	}
+
+	if err := s.SaveCache(ctx, "k", []byte("v1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveCache(ctx, "k", []byte("v2")); err != nil {
+		t.Fatal(err)
+	}
+	if data, ok, err := s.LoadCache(ctx, "k"); err != nil || !ok || string(data) != "v2" {
+		t.Errorf("cache = %q, %v, %v; want v2", data, ok, err)
+	}
+	if _, ok, _ := s.LoadCache(ctx, "other"); ok {
+		t.Error("missing cache entry was found")
+	}
+
+	for _, st := range []AnomalyState{{ID: "b", Status: "open"}, {ID: "a", Status: "open"}, {ID: "b", Status: "acknowledged"}} {
+		if err := s.SaveAnomalyState(ctx, st); err != nil {
+			t.Fatal(err)
+		}
+	}
+	states, err := s.LoadAnomalyStates(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(states) != 2 || states[0].ID != "a" || states[1].Status != "acknowledged" {
+		t.Errorf("states = %+v, want a open and b acknowledged", states)
+	}
+}
+
+func TestBind(t *testing.T) {
+	query := `SELECT record FROM cost_records WHERE day >= ? AND day <= ?`
+	if got := (&SQLStore{}).bind(query); got != query {
+		t.Errorf("sqlite bind = %s", got)
+	}
+	want := `SELECT record FROM cost_records WHERE day >= $1 AND day <= $2`
+	if got := (&SQLStore{postgres: true}).bind(query); got != want {
+		t.Errorf("postgres bind = %s, want %s", got, want)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		cfg     config.StoreConfig
+		wantErr string
+	}{
+		{config.StoreConfig{Path: dir}, ""},
+		{config.StoreConfig{Driver: DriverFile}, "store path is not set"},
+		{config.StoreConfig{Driver: DriverSQLite, Path: filepath.Join(dir, "db", "costs.db")}, ""},
+		{config.StoreConfig{Driver: DriverSQLite}, "store path is not set"},
+		{config.StoreConfig{Driver: DriverPostgres}, "store dsn is not set"},
+		{config.StoreConfig{Driver: "mysql"}, `unknown store driver "mysql"`},
+	}
+	for _, tt := range tests {
+		s, err := Open(tt.cfg)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("Open(%+v) = %v", tt.cfg, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("Open(%+v) = %v, %v; want %s", tt.cfg, s, err, tt.wantErr)
+		}
+	}
+}
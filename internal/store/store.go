@@ -0,0 +1,253 @@
+// Package store persists normalized cost records between runs so history
+// is available without re-querying cloud APIs
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+// CostStore persists normalized cost records
+type CostStore interface {
+	// SaveRecords stores records, replacing any previously stored data for
+	// the same days so re-ingesting a window is idempotent. Records of days
+	// a monthly rollup already counts are dropped.
+	SaveRecords(ctx context.Context, records []normalizer.CostRecord) error
+	// QueryRange returns the records dated within [start, end)
+	QueryRange(ctx context.Context, start, end time.Time) ([]normalizer.CostRecord, error)
+	// LatestIngestDate returns the date of the newest stored record
+	LatestIngestDate(ctx context.Context) (time.Time, error)
+	// Prune rolls up and deletes data according to the retention policy
+	Prune(ctx context.Context, policy RetentionPolicy, now time.Time) (PruneStats, error)
+	// SaveCheckpoint records the progress of a long-running job
+	SaveCheckpoint(ctx context.Context, cp Checkpoint) error
+	// LoadCheckpoint returns the saved progress of a job, false if it has none
+	LoadCheckpoint(ctx context.Context, job string) (Checkpoint, bool, error)
+	// SaveCache stores an opaque cache entry under key
+	SaveCache(ctx context.Context, key string, data []byte) error
+	// LoadCache returns the cache entry under key, false if there is none
+	LoadCache(ctx context.Context, key string) ([]byte, bool, error)
+	// SaveAnomalyState records the workflow state of a detected anomaly
+	SaveAnomalyState(ctx context.Context, st AnomalyState) error
+	// LoadAnomalyStates returns the state of every recorded anomaly
+	LoadAnomalyStates(ctx context.Context) ([]AnomalyState, error)
+	Close() error
+}
+
+// Checkpoint is the saved progress of a job such as a backfill
+type Checkpoint struct {
+	Job       string    `json:"job"`
+	From      time.Time `json:"from"`
+	To        time.Time `json:"to"`
+	Completed time.Time `json:"completed"` // everything before this is stored
+	UpdatedAt time.Time `json:"updated_at"`
+
+	State json.RawMessage `json:"state,omitempty"` // the job's own state carried to its next run
+}
+
+// AnomalyState tracks a detected anomaly across runs so it can be
+// acknowledged or suppressed once instead of alerting on every run
+type AnomalyState struct {
+	ID        string    `json:"id"`
+	Status    string    `json:"status"` // open, acknowledged or suppressed
+	Scope     string    `json:"scope"`  // cloud/account/service the anomaly was found in
+	Date      time.Time `json:"date"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Note      string    `json:"note,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+	UpdatedBy string    `json:"updated_by,omitempty"` // who last changed the status
+}
+
+// RetentionPolicy keeps daily line items for DailyDays, then monthly rollups
+// for MonthlyMonths
+type RetentionPolicy struct {
+	DailyDays     int      // 0 keeps daily data forever
+	MonthlyMonths int      // 0 keeps rollups forever
+	KeepTags      []string // tag keys preserved in rollups (cost center, application)
+}
+
+// PruneStats summarizes a prune run
+type PruneStats struct {
+	DaysRolledUp   int
+	MonthsRolledUp int
+	MonthsDeleted  int
+	RecordsBefore  int
+	RecordsAfter   int
+
+	// Daily records discarded because the month's rollup already counted them
+	Duplicates int
+}
+
+// RollupPrefix marks the ID of records produced by a monthly rollup
+const RollupPrefix = "rollup:"
+
+// Rollup collapses line items into one record per month and per
+// cloud/account/service/region/kept tags. Resource-level detail is dropped;
+// every By* dimension used for comparisons and budgets is preserved. A
+// rollup's StartTime and EndTime span the days it counts.
+func Rollup(records []normalizer.CostRecord, keepTags []string) []normalizer.CostRecord {
+	type key struct {
+		month, cloud, account, service, cloudService, region, currency, tags string
+	}
+
+	rolled := make(map[key]*normalizer.CostRecord)
+	var order []key
+
+	for _, r := range records {
+		month := time.Date(r.Date.Year(), r.Date.Month(), 1, 0, 0, 0, 0, time.UTC)
+		start, end := span(r, month)
+
+		tags := make(map[string]string)
+		tagKey := ""
+		for _, t := range keepTags {
+			if v, ok := r.Tags[t]; ok {
+				tags[t] = v
+				tagKey += t + "=" + v + ";"
+			}
+		}
+
+		k := key{month.Format("2006-01"), r.Cloud, r.Account, r.Service, r.CloudService, r.Region, r.Currency, tagKey}
+		agg, ok := rolled[k]
+		if !ok {
+			agg = &normalizer.CostRecord{
+				ID:           RollupPrefix + k.month,
+				Cloud:        r.Cloud,
+				Account:      r.Account,
+				Region:       r.Region,
+				Service:      r.Service,
+				Currency:     r.Currency,
+				UsageUnit:    r.UsageUnit,
+				Date:         month,
+				StartTime:    start,
+				EndTime:      end,
+				Tags:         tags,
+				CloudService: r.CloudService,
+			}
+			rolled[k] = agg
+			order = append(order, k)
+		}
+		if start.Before(agg.StartTime) {
+			agg.StartTime = start
+		}
+		if end.After(agg.EndTime) {
+			agg.EndTime = end
+		}
+		agg.Cost += r.Cost
+		agg.RawCost += r.RawCost
+		agg.UsageQuantity += r.UsageQuantity
+		if agg.UsageUnit != r.UsageUnit {
+			agg.UsageUnit = ""
+		}
+	}
+
+	result := make([]normalizer.CostRecord, 0, len(order))
+	for _, k := range order {
+		result = append(result, *rolled[k])
+	}
+	return result
+}
+
+// span returns the days a record counts within its month: a line item's
+// day, or the days a rollup spans
+func span(r normalizer.CostRecord, month time.Time) (start, end time.Time) {
+	start, end = r.StartTime, r.EndTime
+	if start.IsZero() || !end.After(start) {
+		start, end = r.Date, r.Date.AddDate(0, 0, 1)
+	}
+	if start.Before(month) {
+		start = month
+	}
+	if next := month.AddDate(0, 1, 0); end.After(next) {
+		end = next
+	}
+	return start, end
+}
+
+// splitCovered splits daily records into those a month's rollups do not
+// count yet and the duplicates they do. Rollups cover the days they span
+// for their cloud, so re-ingesting a rolled-up month never counts it twice
+// while days missing from the rollup can still be backfilled.
+func splitCovered(records, rollups []normalizer.CostRecord) (kept, duplicates []normalizer.CostRecord) {
+	type window struct{ start, end time.Time }
+	covered := make(map[string][]window)
+	for _, r := range rollups {
+		month := time.Date(r.Date.Year(), r.Date.Month(), 1, 0, 0, 0, 0, time.UTC)
+		start, end := span(r, month)
+		covered[r.Cloud] = append(covered[r.Cloud], window{start, end})
+	}
+	if len(covered) == 0 {
+		return records, nil
+	}
+
+	for _, r := range records {
+		duplicate := false
+		for _, w := range covered[r.Cloud] {
+			if !r.Date.Before(w.start) && r.Date.Before(w.end) {
+				duplicate = true
+				break
+			}
+		}
+		if duplicate {
+			duplicates = append(duplicates, r)
+		} else {
+			kept = append(kept, r)
+		}
+	}
+	return kept, duplicates
+}
+
+// IsRollup reports whether a record is a monthly rollup
+func IsRollup(r normalizer.CostRecord) bool {
+	return strings.HasPrefix(r.ID, RollupPrefix)
+}
+
+// Open opens the configured store: a directory of JSON files by default, a
+// SQLite database file at Path, or a PostgreSQL database at DSN
+func Open(cfg config.StoreConfig) (CostStore, error) {
+	switch cfg.Driver {
+	case "", DriverFile:
+		if cfg.Path == "" {
+			return nil, fmt.Errorf("store path is not set")
+		}
+		return NewFileStore(cfg.Path)
+	case DriverSQLite:
+		if cfg.Path == "" {
+			return nil, fmt.Errorf("store path is not set")
+		}
+		if err := os.MkdirAll(filepath.Dir(cfg.Path), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create store directory: %w", err)
+		}
+		return NewSQLStore(context.Background(), "sqlite3", cfg.Path)
+	case DriverPostgres:
+		if cfg.DSN == "" {
+			return nil, fmt.Errorf("store dsn is not set")
+		}
+		return NewSQLStore(context.Background(), "postgres", cfg.DSN)
+	default:
+		return nil, fmt.Errorf("unknown store driver %q (want file, sqlite or postgres)", cfg.Driver)
+	}
+}
+
+// PolicyFrom builds the retention policy, keeping the tags chargeback and
+// application reporting group by
+func PolicyFrom(cfg *config.Config) RetentionPolicy {
+	policy := RetentionPolicy{
+		DailyDays:     cfg.Store.Retention.DailyDays,
+		MonthlyMonths: cfg.Store.Retention.MonthlyMonths,
+	}
+	for _, tag := range []string{cfg.Chargeback.PrimaryTag, cfg.Chargeback.FallbackTag, cfg.Applications.Tag} {
+		if tag != "" {
+			policy.KeepTags = append(policy.KeepTags, tag)
+		}
+	}
+	return policy
+}
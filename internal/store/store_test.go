@@ -0,0 +1,93 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+var january = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func line(service, team string, date time.Time, cost float64) normalizer.CostRecord {
+	return normalizer.CostRecord{
+		ID:       service + date.Format("20060102") + team,
+		Cloud:    "aws",
+		Account:  "111",
+		Service:  service,
+		Resource: "r-" + team,
+		Region:   "us-east-1",
+		Currency: "USD",
+		Date:     date,
+		Cost:     cost,
+		Tags:     map[string]string{"team": team, "name": "box-" + team},
+	}
+}
+
+func TestRollup(t *testing.T) {
+	records := []normalizer.CostRecord{
+		line("EC2", "web", january.AddDate(0, 0, 4), 10),
+		line("EC2", "web", january.AddDate(0, 0, 9), 15),
+		line("EC2", "data", january.AddDate(0, 0, 9), 7),
+		line("EC2", "web", january.AddDate(0, 1, 0), 3),
+	}
+	rolled := Rollup(records, []string{"team"})
+	if len(rolled) != 3 {
+		t.Fatalf("got %d rollups, want 3", len(rolled))
+	}
+
+	web := rolled[0]
+	if !IsRollup(web) || web.Cost != 25 || !web.Date.Equal(january) || web.Resource != "" {
+		t.Errorf("web rollup = %+v, want a January rollup of 25 without resource", web)
+	}
+	if len(web.Tags) != 1 || web.Tags["team"] != "web" {
+		t.Errorf("web rollup tags = %v, want only team", web.Tags)
+	}
+	if !web.StartTime.Equal(january.AddDate(0, 0, 4)) || !web.EndTime.Equal(january.AddDate(0, 0, 10)) {
+		t.Errorf("web rollup spans %s to %s, want Jan 5 to Jan 11", web.StartTime, web.EndTime)
+	}
+	if rolled[1].Cost != 7 || rolled[2].Date.Month() != time.February {
+		t.Errorf("rollups = %+v, want data at 7 and a February rollup", rolled[1:])
+	}
+}
+
+func TestPolicyFrom(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Store.Retention.DailyDays = 90
+	cfg.Store.Retention.MonthlyMonths = 24
+	cfg.Chargeback.PrimaryTag = "cost_center"
+	cfg.Applications.Tag = "app"
+
+	p := PolicyFrom(cfg)
+	if p.DailyDays != 90 || p.MonthlyMonths != 24 || len(p.KeepTags) != 2 || p.KeepTags[0] != "cost_center" || p.KeepTags[1] != "app" {
+		t.Errorf("policy = %+v, want 90 days, 24 months keeping cost_center and app", p)
+	}
+}
+
+func TestSplitCovered(t *testing.T) {
+	// January's rollup counts Jan 1 to Jan 3 of aws
+	rollups := Rollup([]normalizer.CostRecord{
+		line("EC2", "web", january, 10),
+		line("EC2", "web", january.AddDate(0, 0, 2), 10),
+	}, nil)
+	gcp := line("GCE", "web", january, 4)
+	gcp.Cloud = "gcp"
+	records := []normalizer.CostRecord{
+		line("EC2", "web", january.AddDate(0, 0, 1), 10),
+		line("S3", "data", january.AddDate(0, 0, 2), 1),
+		line("EC2", "web", january.AddDate(0, 0, 3), 10), // a backfilled day
+		gcp,
+	}
+	kept, duplicates := splitCovered(records, rollups)
+	if len(duplicates) != 2 || total(duplicates) != 11 {
+		t.Errorf("got %d duplicates totalling %v, want Jan 2 and Jan 3 totalling 11", len(duplicates), total(duplicates))
+	}
+	if len(kept) != 2 || kept[0].Date.Day() != 4 || kept[1].Cloud != "gcp" {
+		t.Errorf("kept %+v, want Jan 4 and the gcp record", kept)
+	}
+
+	if kept, duplicates := splitCovered(records, nil); len(kept) != 4 || duplicates != nil {
+		t.Errorf("without rollups kept %d and dropped %d, want every record kept", len(kept), len(duplicates))
+	}
+}
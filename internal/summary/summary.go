@@ -0,0 +1,168 @@
+// Package summary writes a plain-English account of what drove the bill
+package summary
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+	"github.com/lvonguyen/finops-platform/internal/compare"
+)
+
+// Input is everything a summary draws on
+type Input struct {
+	Period       string
+	Results      *aggregator.AggregationResult
+	Comparison   *compare.Comparison // nil when there is no prior period
+	Anomalies    []aggregator.Anomaly
+	BudgetAlerts []aggregator.BudgetAlert
+}
+
+// Generate returns a short paragraph covering total spend, the change from
+// the prior period and its top driver, budget breaches, and the biggest
+// anomaly. The wording is fixed, so the same input always gives the same text.
+func Generate(in Input) string {
+	sentences := []string{spend(in)}
+	if in.Results == nil || in.Results.TotalCost == 0 {
+		return sentences[0]
+	}
+
+	sentences = append(sentences, change(in.Comparison), budgets(in.BudgetAlerts), anomalies(in.Anomalies))
+	return strings.Join(sentences, " ")
+}
+
+func spend(in Input) string {
+	if in.Results == nil || in.Results.TotalCost == 0 {
+		return fmt.Sprintf("No cloud spend was reported for %s.", in.Period)
+	}
+
+	providers := make([]string, 0, len(in.Results.ByProvider))
+	for p := range in.Results.ByProvider {
+		providers = append(providers, strings.ToUpper(p))
+	}
+	sort.Strings(providers)
+
+	return fmt.Sprintf("Cloud spend for %s was %s across %s.", in.Period, dollars(in.Results.TotalCost), list(providers))
+}
+
+func change(c *compare.Comparison) string {
+	if c == nil || c.PreviousTotal == 0 {
+		return "There is no prior period to compare against."
+	}
+	if math.Abs(c.Change) < 0.005 {
+		return "That is flat compared with the prior period."
+	}
+
+	direction := "up"
+	if c.Change < 0 {
+		direction = "down"
+	}
+	sentence := fmt.Sprintf("That is %s %.1f%% (%s) from the prior period", direction, math.Abs(c.PercentChange), dollars(math.Abs(c.Change)))
+
+	driver, ok := c.TopDriver()
+	if !ok {
+		return sentence + "."
+	}
+	verb := "growth"
+	if c.Change < 0 {
+		verb = "drop"
+	}
+	return fmt.Sprintf("%s, with the largest %s in %s %s (%s).", sentence, verb, driver.Dimension, driver.Key, signed(driver.Change))
+}
+
+func budgets(alerts []aggregator.BudgetAlert) string {
+	var breached, warned []aggregator.BudgetAlert
+	for _, a := range alerts {
+		if a.PercentUsed >= 100 {
+			breached = append(breached, a)
+		} else {
+			warned = append(warned, a)
+		}
+	}
+
+	worst := func(alerts []aggregator.BudgetAlert) aggregator.BudgetAlert {
+		w := alerts[0]
+		for _, a := range alerts[1:] {
+			if a.PercentUsed > w.PercentUsed {
+				w = a
+			}
+		}
+		return w
+	}
+
+	switch {
+	case len(breached) == 1:
+		b := breached[0]
+		return fmt.Sprintf("The %s budget was exceeded at %.0f%% of its %s limit.", b.BudgetName, b.PercentUsed, dollars(b.BudgetLimit))
+	case len(breached) > 1:
+		b := worst(breached)
+		return fmt.Sprintf("%d budgets were exceeded, the furthest being %s at %.0f%% of its %s limit.", len(breached), b.BudgetName, b.PercentUsed, dollars(b.BudgetLimit))
+	case len(warned) > 0:
+		b := worst(warned)
+		return fmt.Sprintf("No budgets were exceeded, though %s is at %.0f%% of its limit.", b.BudgetName, b.PercentUsed)
+	}
+	return "No budgets were exceeded."
+}
+
+func anomalies(found []aggregator.Anomaly) string {
+	if len(found) == 0 {
+		return "No unusual spending was detected."
+	}
+
+	biggest := found[0]
+	for _, a := range found[1:] {
+		if a.ActualCost-a.ExpectedCost > biggest.ActualCost-biggest.ExpectedCost {
+			biggest = a
+		}
+	}
+
+	sentence := fmt.Sprintf("The biggest anomaly was %s", biggest.Service)
+	if !biggest.Date.IsZero() {
+		sentence += " on " + biggest.Date.Format("January 2")
+	}
+	sentence += fmt.Sprintf(", at %s against an expected %s", dollars(biggest.ActualCost), dollars(biggest.ExpectedCost))
+	if len(found) > 1 {
+		return fmt.Sprintf("%s (%d anomalies in total).", sentence, len(found))
+	}
+	return sentence + "."
+}
+
+// list joins items as "A", "A and B" or "A, B and C"
+func list(items []string) string {
+	switch len(items) {
+	case 0:
+		return "no providers"
+	case 1:
+		return items[0]
+	}
+	return strings.Join(items[:len(items)-1], ", ") + " and " + items[len(items)-1]
+}
+
+// dollars formats an amount with thousands separators, e.g. $12,345.67
+func dollars(v float64) string {
+	s := fmt.Sprintf("%.2f", math.Abs(v))
+	whole, cents, _ := strings.Cut(s, ".")
+
+	var b strings.Builder
+	for i, d := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(d)
+	}
+
+	sign := ""
+	if v < 0 {
+		sign = "-"
+	}
+	return sign + "$" + b.String() + "." + cents
+}
+
+func signed(v float64) string {
+	if v < 0 {
+		return dollars(v)
+	}
+	return "+" + dollars(v)
+}
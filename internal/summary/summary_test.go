@@ -0,0 +1,163 @@
+package summary
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/aggregator"
+	"github.com/lvonguyen/finops-platform/internal/compare"
+)
+
+// results returns an aggregation with the given total split across providers
+func results(total float64, providers ...string) *aggregator.AggregationResult {
+	r := &aggregator.AggregationResult{TotalCost: total, ByProvider: make(map[string]float64)}
+	for _, p := range providers {
+		r.ByProvider[p] = total / float64(len(providers))
+	}
+	return r
+}
+
+func TestGenerate(t *testing.T) {
+	up := &compare.Comparison{
+		PreviousTotal: 1000, CurrentTotal: 1100, Change: 100, PercentChange: 10,
+		ByService: []compare.Delta{
+			{Dimension: compare.DimensionService, Key: "AmazonEC2", Change: 80},
+			{Dimension: compare.DimensionService, Key: "AmazonS3", Change: -5},
+		},
+		ByProvider: []compare.Delta{{Dimension: compare.DimensionProvider, Key: "aws", Change: 75}},
+	}
+	down := &compare.Comparison{
+		PreviousTotal: 2000, CurrentTotal: 1500, Change: -500, PercentChange: -25,
+		ByService: []compare.Delta{{Dimension: compare.DimensionService, Key: "BigQuery", Change: -450}},
+	}
+	jan2 := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		in   Input
+		want string
+	}{
+		{
+			name: "no spend",
+			in:   Input{Period: "January 2024", Results: results(0)},
+			want: "No cloud spend was reported for January 2024.",
+		},
+		{
+			name: "no results",
+			in:   Input{Period: "January 2024"},
+			want: "No cloud spend was reported for January 2024.",
+		},
+		{
+			name: "no prior period",
+			in:   Input{Period: "January 2024", Results: results(1234.5, "aws")},
+			want: "Cloud spend for January 2024 was $1,234.50 across AWS. There is no prior period to compare against. " +
+				"No budgets were exceeded. No unusual spending was detected.",
+		},
+		{
+			name: "up with driver",
+			in:   Input{Period: "January 2024", Results: results(1100, "gcp", "aws", "azure"), Comparison: up},
+			want: "Cloud spend for January 2024 was $1,100.00 across AWS, AZURE and GCP. " +
+				"That is up 10.0% ($100.00) from the prior period, with the largest growth in service AmazonEC2 (+$80.00). " +
+				"No budgets were exceeded. No unusual spending was detected.",
+		},
+		{
+			name: "down with driver",
+			in:   Input{Period: "Q1", Results: results(1500, "gcp"), Comparison: down},
+			want: "Cloud spend for Q1 was $1,500.00 across GCP. " +
+				"That is down 25.0% ($500.00) from the prior period, with the largest drop in service BigQuery (-$450.00). " +
+				"No budgets were exceeded. No unusual spending was detected.",
+		},
+		{
+			name: "flat",
+			in:   Input{Period: "Q1", Results: results(10, "aws"), Comparison: &compare.Comparison{PreviousTotal: 10, CurrentTotal: 10}},
+			want: "Cloud spend for Q1 was $10.00 across AWS. That is flat compared with the prior period. " +
+				"No budgets were exceeded. No unusual spending was detected.",
+		},
+		{
+			name: "one budget exceeded",
+			in: Input{Period: "Q1", Results: results(10, "aws"), BudgetAlerts: []aggregator.BudgetAlert{
+				{BudgetName: "platform", BudgetLimit: 5000, PercentUsed: 112.4},
+				{BudgetName: "data", BudgetLimit: 100, PercentUsed: 85},
+			}},
+			want: "Cloud spend for Q1 was $10.00 across AWS. There is no prior period to compare against. " +
+				"The platform budget was exceeded at 112% of its $5,000.00 limit. No unusual spending was detected.",
+		},
+		{
+			name: "several budgets exceeded",
+			in: Input{Period: "Q1", Results: results(10, "aws"), BudgetAlerts: []aggregator.BudgetAlert{
+				{BudgetName: "platform", BudgetLimit: 5000, PercentUsed: 101},
+				{BudgetName: "data", BudgetLimit: 2000, PercentUsed: 130},
+			}},
+			want: "Cloud spend for Q1 was $10.00 across AWS. There is no prior period to compare against. " +
+				"2 budgets were exceeded, the furthest being data at 130% of its $2,000.00 limit. No unusual spending was detected.",
+		},
+		{
+			name: "warned only",
+			in: Input{Period: "Q1", Results: results(10, "aws"), BudgetAlerts: []aggregator.BudgetAlert{
+				{BudgetName: "platform", BudgetLimit: 5000, PercentUsed: 80},
+				{BudgetName: "data", BudgetLimit: 2000, PercentUsed: 95},
+			}},
+			want: "Cloud spend for Q1 was $10.00 across AWS. There is no prior period to compare against. " +
+				"No budgets were exceeded, though data is at 95% of its limit. No unusual spending was detected.",
+		},
+		{
+			name: "biggest anomaly",
+			in: Input{Period: "Q1", Results: results(10, "aws"), Anomalies: []aggregator.Anomaly{
+				{Service: "AmazonS3", Date: jan2, ActualCost: 500, ExpectedCost: 100},
+				{Service: "AmazonEC2", Date: jan2, ActualCost: 3000, ExpectedCost: 1200},
+				{Service: "Lambda", ActualCost: 50, ExpectedCost: 10},
+			}},
+			want: "Cloud spend for Q1 was $10.00 across AWS. There is no prior period to compare against. No budgets were exceeded. " +
+				"The biggest anomaly was AmazonEC2 on January 2, at $3,000.00 against an expected $1,200.00 (3 anomalies in total).",
+		},
+		{
+			name: "single undated anomaly",
+			in: Input{Period: "Q1", Results: results(10, "aws"), Anomalies: []aggregator.Anomaly{
+				{Service: "Lambda", ActualCost: 50, ExpectedCost: 10},
+			}},
+			want: "Cloud spend for Q1 was $10.00 across AWS. There is no prior period to compare against. No budgets were exceeded. " +
+				"The biggest anomaly was Lambda, at $50.00 against an expected $10.00.",
+		},
+	}
+	for _, tt := range tests {
+		if got := Generate(tt.in); got != tt.want {
+			t.Errorf("%s: Generate() =\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDollars(t *testing.T) {
+	tests := []struct {
+		v    float64
+		want string
+	}{
+		{0, "$0.00"},
+		{5.5, "$5.50"},
+		{999.999, "$1,000.00"},
+		{12345.67, "$12,345.67"},
+		{1234567, "$1,234,567.00"},
+		{-1234.5, "-$1,234.50"},
+	}
+	for _, tt := range tests {
+		if got := dollars(tt.v); got != tt.want {
+			t.Errorf("dollars(%v) = %s, want %s", tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestList(t *testing.T) {
+	tests := []struct {
+		items []string
+		want  string
+	}{
+		{nil, "no providers"},
+		{[]string{"AWS"}, "AWS"},
+		{[]string{"AWS", "GCP"}, "AWS and GCP"},
+		{[]string{"AWS", "AZURE", "GCP"}, "AWS, AZURE and GCP"},
+	}
+	for _, tt := range tests {
+		if got := list(tt.items); got != tt.want {
+			t.Errorf("list(%v) = %s, want %s", tt.items, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,301 @@
+package tagpolicy
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/calendar"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+// Granularity is the period of a compliance trend point
+type Granularity string
+
+const (
+	Daily   Granularity = "daily"
+	Monthly Granularity = "monthly"
+)
+
+// period names the trend period of a day: the day, or its fiscal month
+func (g Granularity) period(day time.Time, fis *calendar.Fiscal) string {
+	if g == Monthly {
+		return fis.MonthOf(day).Format("2006-01")
+	}
+	return day.Format("2006-01-02")
+}
+
+// Compliance reports how much spend carries the required tags, where it
+// does not, and how coverage moved over the period. Credits are left out.
+type Compliance struct {
+	Start               time.Time            `json:"start"`
+	End                 time.Time            `json:"end"`
+	Tags                []string             `json:"tags"` // required tag keys, in policy order
+	TotalCost           float64              `json:"total_cost"`
+	NonCompliantCost    float64              `json:"non_compliant_cost"` // spend on charges failing at least one rule
+	NonCompliantPercent float64              `json:"non_compliant_percent"`
+	ByTag               []TagCompliance      `json:"by_tag"`
+	ByScope             []ScopeCompliance    `json:"by_scope"`  // most non-compliant spend first
+	Resources           []ResourceCompliance `json:"resources"` // most expensive first
+	Trend               []TrendPoint         `json:"trend"`
+}
+
+// TagCompliance is the spend failing one required tag
+type TagCompliance struct {
+	Key            string  `json:"key"`
+	InScopeCost    float64 `json:"in_scope_cost"` // spend the rule applies to
+	MissingCost    float64 `json:"missing_cost"`
+	InvalidCost    float64 `json:"invalid_cost"`
+	MissingPercent float64 `json:"missing_percent"` // of in-scope spend
+}
+
+// ScopeCompliance is the compliance of one cloud, account and service
+type ScopeCompliance struct {
+	Cloud            string             `json:"cloud"`
+	Account          string             `json:"account"`
+	Service          string             `json:"service"`
+	Cost             float64            `json:"cost"`
+	NonCompliantCost float64            `json:"non_compliant_cost"`
+	Percent          float64            `json:"percent"` // non-compliant share of the scope's spend
+	Missing          map[string]float64 `json:"missing"` // tag -> spend missing it
+}
+
+// ResourceCompliance is a resource whose latest tags fail the policy
+type ResourceCompliance struct {
+	Cloud    string   `json:"cloud"`
+	Account  string   `json:"account"`
+	Service  string   `json:"service"`
+	Resource string   `json:"resource"` // empty for charges without a resource ID
+	Cost     float64  `json:"cost"`
+	Missing  []string `json:"missing"`
+	Invalid  []string `json:"invalid"`
+}
+
+// TrendPoint is the compliance of one day or month
+type TrendPoint struct {
+	Period           string  `json:"period"`
+	Cost             float64 `json:"cost"`
+	NonCompliantCost float64 `json:"non_compliant_cost"`
+	Percent          float64 `json:"percent"`
+}
+
+// Compliance evaluates every charge against the policy with the tags it was
+// billed with. The top most expensive non-compliant resources are listed,
+// judged by their most recent tags as in Evaluate. Monthly trend points are
+// the months of the fiscal calendar fis.
+func (p *Policy) Compliance(records []normalizer.CostRecord, start, end time.Time, granularity Granularity, fis *calendar.Fiscal, top int) *Compliance {
+	c := &Compliance{Start: start, End: end}
+	byTag := make([]TagCompliance, len(p.Rules))
+	seen := make(map[string]bool)
+	for i, rule := range p.Rules {
+		if !seen[rule.Key] {
+			seen[rule.Key] = true
+			c.Tags = append(c.Tags, rule.Key)
+		}
+		byTag[i].Key = rule.Key
+	}
+
+	type scopeKey struct{ cloud, account, service string }
+	scopes := make(map[scopeKey]*ScopeCompliance)
+	periods := make(map[string]*TrendPoint)
+
+	var charges []normalizer.CostRecord
+	for _, r := range records {
+		if r.IsCredit() {
+			continue
+		}
+		charges = append(charges, r)
+
+		sk := scopeKey{r.Cloud, r.Account, r.Service}
+		scope, ok := scopes[sk]
+		if !ok {
+			scope = &ScopeCompliance{Cloud: r.Cloud, Account: r.Account, Service: r.Service, Missing: make(map[string]float64)}
+			scopes[sk] = scope
+		}
+		period := granularity.period(r.Date, fis)
+		point, ok := periods[period]
+		if !ok {
+			point = &TrendPoint{Period: period}
+			periods[period] = point
+		}
+
+		failed := false
+		for i, rule := range p.Rules {
+			if !rule.AppliesTo(r) {
+				continue
+			}
+			byTag[i].InScopeCost += r.Cost
+			switch rule.check(strings.TrimSpace(r.Tags[rule.Key])) {
+			case SeverityMissing:
+				byTag[i].MissingCost += r.Cost
+				scope.Missing[rule.Key] += r.Cost
+				failed = true
+			case SeverityInvalid:
+				byTag[i].InvalidCost += r.Cost
+				failed = true
+			}
+		}
+
+		c.TotalCost += r.Cost
+		scope.Cost += r.Cost
+		point.Cost += r.Cost
+		if failed {
+			c.NonCompliantCost += r.Cost
+			scope.NonCompliantCost += r.Cost
+			point.NonCompliantCost += r.Cost
+		}
+	}
+	c.NonCompliantPercent = percent(c.NonCompliantCost, c.TotalCost)
+
+	for i := range byTag {
+		byTag[i].MissingPercent = percent(byTag[i].MissingCost, byTag[i].InScopeCost)
+	}
+	c.ByTag = byTag
+
+	for _, scope := range scopes {
+		scope.Percent = percent(scope.NonCompliantCost, scope.Cost)
+		c.ByScope = append(c.ByScope, *scope)
+	}
+	sort.Slice(c.ByScope, func(i, j int) bool {
+		a, b := c.ByScope[i], c.ByScope[j]
+		if a.NonCompliantCost != b.NonCompliantCost {
+			return a.NonCompliantCost > b.NonCompliantCost
+		}
+		return a.Cloud+a.Account+a.Service < b.Cloud+b.Account+b.Service
+	})
+
+	for _, point := range periods {
+		point.Percent = percent(point.NonCompliantCost, point.Cost)
+		c.Trend = append(c.Trend, *point)
+	}
+	sort.Slice(c.Trend, func(i, j int) bool { return c.Trend[i].Period < c.Trend[j].Period })
+
+	c.Resources = topResources(p.Evaluate(charges), top)
+	return c
+}
+
+// topResources groups violations, which Evaluate sorts most expensive
+// first, into at most top resources
+func topResources(violations []Violation, top int) []ResourceCompliance {
+	var resources []ResourceCompliance
+	index := make(map[resourceKey]int)
+	for _, v := range violations {
+		k := resourceKey{v.Cloud, v.Account, v.Service, v.Resource}
+		i, ok := index[k]
+		if !ok {
+			if len(resources) == top {
+				continue
+			}
+			i = len(resources)
+			index[k] = i
+			resources = append(resources, ResourceCompliance{
+				Cloud:    v.Cloud,
+				Account:  v.Account,
+				Service:  v.Service,
+				Resource: v.Resource,
+				Cost:     v.Cost,
+			})
+		}
+		if v.Severity == SeverityMissing {
+			resources[i].Missing = append(resources[i].Missing, v.Key)
+		} else {
+			resources[i].Invalid = append(resources[i].Invalid, v.Key)
+		}
+	}
+	return resources
+}
+
+func percent(part, total float64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return part / total * 100
+}
+
+// SaveCSV writes the per account and service compliance, with the spend
+// missing each required tag, as a CSV file
+func (c *Compliance) SaveCSV(path string) error {
+	header := []string{"Cloud", "Account", "Service", "Cost", "Non-Compliant Cost", "Non-Compliant %"}
+	for _, key := range c.Tags {
+		header = append(header, "Missing "+key)
+	}
+	rows := make([][]string, 0, len(c.ByScope))
+	for _, s := range c.ByScope {
+		row := []string{
+			s.Cloud,
+			s.Account,
+			s.Service,
+			fmt.Sprintf("%.2f", s.Cost),
+			fmt.Sprintf("%.2f", s.NonCompliantCost),
+			fmt.Sprintf("%.2f", s.Percent),
+		}
+		for _, key := range c.Tags {
+			row = append(row, fmt.Sprintf("%.2f", s.Missing[key]))
+		}
+		rows = append(rows, row)
+	}
+	return writeCSV(path, header, rows)
+}
+
+// SaveResourcesCSV writes the top non-compliant resources as a CSV file
+func (c *Compliance) SaveResourcesCSV(path string) error {
+	header := []string{"Cloud", "Account", "Service", "Resource", "Cost", "Missing Tags", "Invalid Tags"}
+	rows := make([][]string, 0, len(c.Resources))
+	for _, r := range c.Resources {
+		rows = append(rows, []string{
+			r.Cloud,
+			r.Account,
+			r.Service,
+			r.Resource,
+			fmt.Sprintf("%.2f", r.Cost),
+			strings.Join(r.Missing, ";"),
+			strings.Join(r.Invalid, ";"),
+		})
+	}
+	return writeCSV(path, header, rows)
+}
+
+// SaveTrendCSV writes the compliance of each period as a CSV file
+func (c *Compliance) SaveTrendCSV(path string) error {
+	header := []string{"Period", "Cost", "Non-Compliant Cost", "Non-Compliant %"}
+	rows := make([][]string, 0, len(c.Trend))
+	for _, p := range c.Trend {
+		rows = append(rows, []string{
+			p.Period,
+			fmt.Sprintf("%.2f", p.Cost),
+			fmt.Sprintf("%.2f", p.NonCompliantCost),
+			fmt.Sprintf("%.2f", p.Percent),
+		})
+	}
+	return writeCSV(path, header, rows)
+}
+
+// SaveJSON saves the whole report as a JSON file
+func (c *Compliance) SaveJSON(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	return nil
+}
+
+func writeCSV(path string, header []string, rows [][]string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	return writer.WriteAll(rows)
+}
@@ -0,0 +1,149 @@
+package tagpolicy
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/lvonguyen/finops-platform/internal/calendar"
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+// complianceRecords spends 100 over two accounts, 55 of it non-compliant
+func complianceRecords() []normalizer.CostRecord {
+	return []normalizer.CostRecord{
+		resource("111", "i-1", day, 35, map[string]string{"owner": "web", "env": "prod"}),
+		resource("111", "i-2", day, 20, map[string]string{"env": "staging"}),
+		resource("111", "i-4", day, 5, map[string]string{"env": "prod"}),
+		resource("111", "i-1", day.AddDate(0, 0, 1), -8, nil), // credits are left out
+		resource("222", "i-3", day.AddDate(0, 0, 1), 30, map[string]string{"owner": "data", "env": "dev"}),
+		// Tagged in April, so compliant as a resource
+		resource("222", "i-3", day.AddDate(0, 1, 0), 10, map[string]string{"owner": "data", "env": "dev", "cost_center": "42"}),
+	}
+}
+
+func TestCompliance(t *testing.T) {
+	c := testPolicy(t).Compliance(complianceRecords(), day, day.AddDate(0, 2, 0), Daily, nil, 10)
+
+	if c.TotalCost != 100 || c.NonCompliantCost != 55 || c.NonCompliantPercent != percent(55, 100) {
+		t.Errorf("total %v, non-compliant %v (%v%%), want 100, 55 (55%%)", c.TotalCost, c.NonCompliantCost, c.NonCompliantPercent)
+	}
+	if !reflect.DeepEqual(c.Tags, []string{"owner", "env", "cost_center"}) {
+		t.Errorf("Tags = %v, want the policy's keys in order", c.Tags)
+	}
+
+	wantTags := []TagCompliance{
+		{Key: "owner", InScopeCost: 100, MissingCost: 25, MissingPercent: 25},
+		{Key: "env", InScopeCost: 100, InvalidCost: 20},
+		{Key: "cost_center", InScopeCost: 40, MissingCost: 30, MissingPercent: 75}, // account 222 only
+	}
+	if !reflect.DeepEqual(c.ByTag, wantTags) {
+		t.Errorf("ByTag = %+v, want %+v", c.ByTag, wantTags)
+	}
+
+	wantScopes := []ScopeCompliance{
+		{Cloud: "aws", Account: "222", Service: "EC2", Cost: 40, NonCompliantCost: 30, Percent: 75, Missing: map[string]float64{"cost_center": 30}},
+		{Cloud: "aws", Account: "111", Service: "EC2", Cost: 60, NonCompliantCost: 25, Percent: percent(25, 60), Missing: map[string]float64{"owner": 25}},
+	}
+	if !reflect.DeepEqual(c.ByScope, wantScopes) {
+		t.Errorf("ByScope = %+v, want %+v", c.ByScope, wantScopes)
+	}
+
+	wantResources := []ResourceCompliance{
+		{Cloud: "aws", Account: "111", Service: "EC2", Resource: "i-2", Cost: 20, Missing: []string{"owner"}, Invalid: []string{"env"}},
+		{Cloud: "aws", Account: "111", Service: "EC2", Resource: "i-4", Cost: 5, Missing: []string{"owner"}},
+	}
+	if !reflect.DeepEqual(c.Resources, wantResources) {
+		t.Errorf("Resources = %+v, want %+v", c.Resources, wantResources)
+	}
+
+	wantTrend := []TrendPoint{
+		{Period: "2024-03-01", Cost: 60, NonCompliantCost: 25, Percent: percent(25, 60)},
+		{Period: "2024-03-02", Cost: 30, NonCompliantCost: 30, Percent: 100},
+		{Period: "2024-04-01", Cost: 10},
+	}
+	if !reflect.DeepEqual(c.Trend, wantTrend) {
+		t.Errorf("Trend = %+v, want %+v", c.Trend, wantTrend)
+	}
+}
+
+func TestComplianceMonthlyTop(t *testing.T) {
+	c := testPolicy(t).Compliance(complianceRecords(), day, day.AddDate(0, 2, 0), Monthly, nil, 1)
+
+	wantTrend := []TrendPoint{
+		{Period: "2024-03", Cost: 90, NonCompliantCost: 55, Percent: percent(55, 90)},
+		{Period: "2024-04", Cost: 10},
+	}
+	if !reflect.DeepEqual(c.Trend, wantTrend) {
+		t.Errorf("Trend = %+v, want %+v", c.Trend, wantTrend)
+	}
+	if len(c.Resources) != 1 || c.Resources[0].Resource != "i-2" {
+		t.Errorf("Resources = %+v, want only the most expensive, i-2", c.Resources)
+	}
+}
+
+func TestComplianceFiscalMonths(t *testing.T) {
+	// With 5-4-4 periods fiscal February runs from Feb 5 to Mar 4
+	fis, err := calendar.FiscalFromConfig(config.CalendarConfig{Periods: "5-4-4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := testPolicy(t).Compliance(complianceRecords(), day, day.AddDate(0, 2, 0), Monthly, fis, 10)
+
+	wantTrend := []TrendPoint{
+		{Period: "2024-02", Cost: 90, NonCompliantCost: 55, Percent: percent(55, 90)},
+		{Period: "2024-04", Cost: 10},
+	}
+	if !reflect.DeepEqual(c.Trend, wantTrend) {
+		t.Errorf("Trend = %+v, want %+v", c.Trend, wantTrend)
+	}
+}
+
+func TestComplianceSaveCSV(t *testing.T) {
+	c := testPolicy(t).Compliance(complianceRecords(), day, day.AddDate(0, 2, 0), Daily, nil, 10)
+	dir := t.TempDir()
+
+	tests := []struct {
+		file string
+		save func(string) error
+		want [][]string
+	}{
+		{"scopes.csv", c.SaveCSV, [][]string{
+			{"Cloud", "Account", "Service", "Cost", "Non-Compliant Cost", "Non-Compliant %", "Missing owner", "Missing env", "Missing cost_center"},
+			{"aws", "222", "EC2", "40.00", "30.00", "75.00", "0.00", "0.00", "30.00"},
+			{"aws", "111", "EC2", "60.00", "25.00", "41.67", "25.00", "0.00", "0.00"},
+		}},
+		{"resources.csv", c.SaveResourcesCSV, [][]string{
+			{"Cloud", "Account", "Service", "Resource", "Cost", "Missing Tags", "Invalid Tags"},
+			{"aws", "111", "EC2", "i-2", "20.00", "owner", "env"},
+			{"aws", "111", "EC2", "i-4", "5.00", "owner", ""},
+		}},
+		{"trend.csv", c.SaveTrendCSV, [][]string{
+			{"Period", "Cost", "Non-Compliant Cost", "Non-Compliant %"},
+			{"2024-03-01", "60.00", "25.00", "41.67"},
+			{"2024-03-02", "30.00", "30.00", "100.00"},
+			{"2024-04-01", "10.00", "0.00", "0.00"},
+		}},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(dir, tt.file)
+		if err := tt.save(path); err != nil {
+			t.Fatal(err)
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows, err := csv.NewReader(f).ReadAll()
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(rows, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.file, rows, tt.want)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+// Package tagpolicy validates resource tags against the configured tagging
+// policy and builds remediation lists
+package tagpolicy
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+// Severity of a policy violation
+type Severity string
+
+const (
+	SeverityMissing Severity = "missing" // required tag absent or empty
+	SeverityInvalid Severity = "invalid" // value does not match the allowed pattern
+)
+
+// Rule is a required tag key with an optional value pattern
+type Rule struct {
+	Key      string
+	Pattern  *regexp.Regexp
+	Clouds   []string // clouds the rule applies to, empty for all
+	Accounts []string // accounts the rule applies to, empty for all
+}
+
+// AppliesTo reports whether the rule covers a record's cloud and account
+func (rule Rule) AppliesTo(r normalizer.CostRecord) bool {
+	return inScope(rule.Clouds, r.Cloud) && inScope(rule.Accounts, r.Account)
+}
+
+func inScope(scope []string, value string) bool {
+	if len(scope) == 0 {
+		return true
+	}
+	for _, s := range scope {
+		if s == value {
+			return true
+		}
+	}
+	return false
+}
+
+// check returns how a tag value fails the rule, or "" when it complies
+func (rule Rule) check(value string) Severity {
+	switch {
+	case value == "":
+		return SeverityMissing
+	case rule.Pattern != nil && !rule.Pattern.MatchString(value):
+		return SeverityInvalid
+	}
+	return ""
+}
+
+// Policy is a set of required tag rules
+type Policy struct {
+	Rules []Rule
+}
+
+// Violation is one resource failing one rule
+type Violation struct {
+	Cloud    string            `json:"cloud"`
+	Account  string            `json:"account"`
+	Service  string            `json:"service"`
+	Resource string            `json:"resource"`
+	Key      string            `json:"key"`
+	Value    string            `json:"value"`
+	Severity Severity          `json:"severity"`
+	Cost     float64           `json:"cost"` // spend on the resource in the period
+	Tags     map[string]string `json:"tags"`
+}
+
+// resourceKey identifies a resource. Records without a resource ID share
+// their account and service's key.
+type resourceKey struct {
+	cloud, account, service, resource string
+}
+
+// FromConfig compiles the configured tag policy
+func FromConfig(cfg config.TagPolicyConfig) (*Policy, error) {
+	p := &Policy{}
+	for _, req := range cfg.Required {
+		if req.Key == "" {
+			return nil, fmt.Errorf("tag policy rule has no key")
+		}
+		rule := Rule{Key: req.Key, Clouds: req.Clouds, Accounts: req.Accounts}
+		if req.Pattern != "" {
+			re, err := regexp.Compile(req.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern for tag %q: %w", req.Key, err)
+			}
+			rule.Pattern = re
+		}
+		p.Rules = append(p.Rules, rule)
+	}
+	return p, nil
+}
+
+// Evaluate groups records by resource and returns every policy violation,
+// most expensive first. Records without a resource ID are grouped by account
+// and service.
+func (p *Policy) Evaluate(records []normalizer.CostRecord) []Violation {
+	type resource struct {
+		cost   float64
+		tags   map[string]string
+		latest time.Time
+	}
+
+	resources := make(map[resourceKey]*resource)
+	for _, r := range records {
+		k := resourceKey{r.Cloud, r.Account, r.Service, r.Resource}
+		res, ok := resources[k]
+		if !ok {
+			res = &resource{}
+			resources[k] = res
+		}
+		res.cost += r.Cost
+		// Judge the resource by its most recent tags
+		if !r.Date.Before(res.latest) {
+			res.tags = r.Tags
+			res.latest = r.Date
+		}
+	}
+
+	var violations []Violation
+	for k, res := range resources {
+		for _, rule := range p.Rules {
+			if !inScope(rule.Clouds, k.cloud) || !inScope(rule.Accounts, k.account) {
+				continue
+			}
+			value := strings.TrimSpace(res.tags[rule.Key])
+			severity := rule.check(value)
+			if severity == "" {
+				continue
+			}
+
+			violations = append(violations, Violation{
+				Cloud:    k.cloud,
+				Account:  k.account,
+				Service:  k.service,
+				Resource: k.resource,
+				Key:      rule.Key,
+				Value:    value,
+				Severity: severity,
+				Cost:     res.cost,
+				Tags:     res.tags,
+			})
+		}
+	}
+
+	sort.Slice(violations, func(i, j int) bool {
+		a, b := violations[i], violations[j]
+		if a.Cost != b.Cost {
+			return a.Cost > b.Cost
+		}
+		if a.Resource != b.Resource {
+			return a.Resource < b.Resource
+		}
+		return a.Key < b.Key
+	})
+
+	return violations
+}
+
+// SaveCSV writes a remediation list of violations as a CSV file
+func SaveCSV(path string, violations []Violation) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	header := []string{"Cloud", "Account", "Service", "Resource", "Tag", "Severity", "Current Value", "Cost At Stake", "Current Tags"}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, v := range violations {
+		row := []string{
+			v.Cloud,
+			v.Account,
+			v.Service,
+			v.Resource,
+			v.Key,
+			string(v.Severity),
+			v.Value,
+			fmt.Sprintf("%.2f", v.Cost),
+			formatTags(v.Tags),
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// formatTags renders tags as sorted key=value pairs
+func formatTags(tags map[string]string) string {
+	pairs := make([]string, 0, len(tags))
+	for k, v := range tags {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ";")
+}
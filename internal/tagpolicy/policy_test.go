@@ -0,0 +1,88 @@
+package tagpolicy
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+var day = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+func resource(account, id string, date time.Time, cost float64, tags map[string]string) normalizer.CostRecord {
+	return normalizer.CostRecord{Cloud: "aws", Account: account, Service: "EC2", Resource: id, Date: date, Cost: cost, Tags: tags}
+}
+
+func testPolicy(t *testing.T) *Policy {
+	t.Helper()
+	p, err := FromConfig(config.TagPolicyConfig{Required: []config.RequiredTag{
+		{Key: "owner"},
+		{Key: "env", Pattern: "^(prod|dev)$"},
+		{Key: "cost_center", Accounts: []string{"222"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestEvaluate(t *testing.T) {
+	records := []normalizer.CostRecord{
+		// Retagged on the second day; the latest tags count
+		resource("111", "i-1", day, 10, map[string]string{"env": "prod"}),
+		resource("111", "i-1", day.AddDate(0, 0, 1), 10, map[string]string{"owner": "web", "env": "prod"}),
+		resource("111", "i-2", day, 50, map[string]string{"owner": " ", "env": "staging"}),
+		resource("222", "i-3", day, 5, map[string]string{"owner": "data", "env": "dev"}),
+	}
+
+	got := testPolicy(t).Evaluate(records)
+	want := []Violation{
+		{Account: "111", Resource: "i-2", Key: "env", Value: "staging", Severity: SeverityInvalid, Cost: 50},
+		{Account: "111", Resource: "i-2", Key: "owner", Severity: SeverityMissing, Cost: 50},
+		{Account: "222", Resource: "i-3", Key: "cost_center", Severity: SeverityMissing, Cost: 5},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d violations %+v, want %d", len(got), got, len(want))
+	}
+	for i, v := range got {
+		w := want[i]
+		if v.Account != w.Account || v.Resource != w.Resource || v.Key != w.Key || v.Value != w.Value || v.Severity != w.Severity || v.Cost != w.Cost {
+			t.Errorf("violation %d = %+v, want %+v", i, v, w)
+		}
+	}
+}
+
+func TestFromConfigErrors(t *testing.T) {
+	for _, req := range []config.RequiredTag{{Pattern: "x"}, {Key: "env", Pattern: "("}} {
+		if _, err := FromConfig(config.TagPolicyConfig{Required: []config.RequiredTag{req}}); err == nil {
+			t.Errorf("rule %+v accepted", req)
+		}
+	}
+}
+
+func TestSaveCSV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "remediation.csv")
+	violations := []Violation{{Cloud: "aws", Account: "111", Service: "EC2", Resource: "i-2", Key: "env", Value: "staging", Severity: SeverityInvalid, Cost: 50, Tags: map[string]string{"owner": "web", "env": "staging"}}}
+	if err := SaveCSV(path, violations); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("got %d rows, want a header and 1 row", len(rows))
+	}
+	if rows[1][7] != "50.00" || rows[1][8] != "env=staging;owner=web" {
+		t.Errorf("row = %v, want cost 50.00 and sorted tags", rows[1])
+	}
+}
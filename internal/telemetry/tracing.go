@@ -0,0 +1,95 @@
+// Package telemetry traces provider fetches with OpenTelemetry
+package telemetry
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+const instrumentation = "github.com/lvonguyen/finops-platform"
+
+// Span attribute keys
+const (
+	AttrProvider = attribute.Key("finops.provider")
+	AttrRecords  = attribute.Key("finops.records")  // records returned by a call or fetch
+	AttrPage     = attribute.Key("finops.page")     // 1-based page of a paginated call
+	AttrPages    = attribute.Key("finops.pages")    // pagination depth of a fetch
+	AttrRetries  = attribute.Key("finops.retries")  // retries inside an SDK call, e.g. when throttled
+	AttrBackoffs = attribute.Key("finops.backoffs") // retries of a call after backing off, on top of SDK retries
+)
+
+// Setup installs an OTLP/HTTP exporting tracer provider. Without it spans
+// are no-ops. The returned function flushes and stops the exporter.
+func Setup(ctx context.Context, cfg config.TracingConfig) (func(context.Context) error, error) {
+	if !cfg.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+	if cfg.SampleRatio < 0 || cfg.SampleRatio > 1 {
+		return nil, fmt.Errorf("sample_ratio must be between 0 and 1, got %v", cfg.SampleRatio)
+	}
+
+	opts := []otlptracehttp.Option{}
+	if cfg.Endpoint != "" {
+		opts = append(opts, otlptracehttp.WithEndpoint(cfg.Endpoint))
+	}
+	if cfg.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	if len(cfg.Headers) > 0 {
+		opts = append(opts, otlptracehttp.WithHeaders(cfg.Headers))
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(cfg.ServiceName)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build trace resource: %w", err)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+// Start starts a span from the global tracer
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentation).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// StartCall starts a span for one provider API call. Page numbers are also
+// recorded on the enclosing fetch span as its pagination depth.
+func StartCall(ctx context.Context, name string, page int) (context.Context, trace.Span) {
+	if page > 0 {
+		trace.SpanFromContext(ctx).SetAttributes(AttrPages.Int(page))
+	}
+	return otel.Tracer(instrumentation).Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(AttrPage.Int(page)))
+}
+
+// End records a span's record count and error, then ends it
+func End(span trace.Span, records int, err error) {
+	span.SetAttributes(AttrRecords.Int(records))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
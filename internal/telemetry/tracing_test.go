@@ -0,0 +1,101 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+)
+
+// record installs a tracer provider that keeps ended spans in memory
+func record(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	sr := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+	return sr
+}
+
+// attrs returns the attributes of a span by key
+func attrs(s sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	m := make(map[attribute.Key]attribute.Value)
+	for _, kv := range s.Attributes() {
+		m[kv.Key] = kv.Value
+	}
+	return m
+}
+
+func TestSetup(t *testing.T) {
+	shutdown, err := Setup(context.Background(), config.TracingConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Errorf("disabled shutdown = %v, want nil", err)
+	}
+
+	for _, ratio := range []float64{-0.1, 1.5} {
+		if _, err := Setup(context.Background(), config.TracingConfig{Enabled: true, SampleRatio: ratio}); err == nil {
+			t.Errorf("sample ratio %v accepted, want an error", ratio)
+		}
+	}
+}
+
+func TestStartCallRecordsPagination(t *testing.T) {
+	sr := record(t)
+
+	ctx, fetch := Start(context.Background(), "GetCosts aws", AttrProvider.String("aws"))
+	for page := 1; page <= 3; page++ {
+		_, call := StartCall(ctx, "GetCostAndUsage", page)
+		End(call, 10*page, nil)
+	}
+	End(fetch, 60, nil)
+
+	spans := sr.Ended()
+	if len(spans) != 4 {
+		t.Fatalf("got %d spans, want 4", len(spans))
+	}
+	for i, s := range spans[:3] {
+		a := attrs(s)
+		if s.SpanKind().String() != "client" || a[AttrPage].AsInt64() != int64(i+1) || a[AttrRecords].AsInt64() != int64(10*(i+1)) {
+			t.Errorf("call %d: kind %s, attributes %v", i, s.SpanKind(), a)
+		}
+		if s.Parent().SpanID() != spans[3].SpanContext().SpanID() {
+			t.Errorf("call %d is not a child of the fetch span", i)
+		}
+	}
+	a := attrs(spans[3])
+	if a[AttrPages].AsInt64() != 3 || a[AttrRecords].AsInt64() != 60 || a[AttrProvider].AsString() != "aws" {
+		t.Errorf("fetch attributes = %v, want 3 pages of 60 records from aws", a)
+	}
+}
+
+func TestEndRecordsError(t *testing.T) {
+	sr := record(t)
+
+	_, span := StartCall(context.Background(), "query", 0)
+	End(span, 0, errors.New("throttled"))
+
+	spans := sr.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	s := spans[0]
+	if s.Status().Code != codes.Error || s.Status().Description != "throttled" {
+		t.Errorf("status = %+v, want the error", s.Status())
+	}
+	if len(s.Events()) != 1 || s.Events()[0].Name != "exception" {
+		t.Errorf("events = %+v, want the recorded error", s.Events())
+	}
+	if _, ok := attrs(s)[AttrPages]; ok {
+		t.Error("an unpaginated call set the pagination depth")
+	}
+}
@@ -0,0 +1,78 @@
+package unitcost
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+	"github.com/lvonguyen/finops-platform/internal/store"
+)
+
+// DefaultDays is the range the API covers without ?start=
+const DefaultDays = 30
+
+// Handler serves the metrics' cost per unit as JSON on GET, over the daily
+// history for ?start= and ?end= (YYYY-MM-DD, inclusive), defaulting to the
+// last DefaultDays days ending at the latest ingested day
+func Handler(history store.CostStore, calc *Calculator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		q := r.URL.Query()
+
+		var start, end time.Time
+		if v := q.Get("end"); v != "" {
+			last, err := time.Parse("2006-01-02", v)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("invalid end date %q: want YYYY-MM-DD", v), http.StatusBadRequest)
+				return
+			}
+			end = last.AddDate(0, 0, 1)
+		} else {
+			latest, err := history.LatestIngestDate(r.Context())
+			if err != nil {
+				http.Error(w, "failed to read history: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if latest.IsZero() {
+				latest = time.Now().UTC()
+			}
+			end = time.Date(latest.Year(), latest.Month(), latest.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, 1)
+		}
+		start = end.AddDate(0, 0, -DefaultDays)
+		if v := q.Get("start"); v != "" {
+			var err error
+			if start, err = time.Parse("2006-01-02", v); err != nil {
+				http.Error(w, fmt.Sprintf("invalid start date %q: want YYYY-MM-DD", v), http.StatusBadRequest)
+				return
+			}
+		}
+		if !start.Before(end) {
+			http.Error(w, "start date must not be after end date", http.StatusBadRequest)
+			return
+		}
+
+		stored, err := history.QueryRange(r.Context(), start, end)
+		if err != nil {
+			http.Error(w, "failed to query history: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		records := make([]normalizer.CostRecord, 0, len(stored))
+		for _, rec := range stored {
+			if !store.IsRollup(rec) {
+				records = append(records, rec)
+			}
+		}
+		series, err := calc.Compute(r.Context(), records, start, end)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(series)
+	})
+}
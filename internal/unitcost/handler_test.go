@@ -0,0 +1,76 @@
+package unitcost
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/store"
+)
+
+func TestHandler(t *testing.T) {
+	history, err := store.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := history.SaveRecords(context.Background(), fourDays()); err != nil {
+		t.Fatal(err)
+	}
+	calc, err := New(config.UnitCostConfig{Metrics: []config.UnitMetricConfig{
+		{Name: "orders", CostCenter: "CC-1", CSV: writeMetric(t, "2024-03-04,4\n2024-03-05,5\n")},
+	}}, costCenter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := Handler(history, calc)
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		want   int
+		days   int     // points served
+		cost   float64 // of the range
+	}{
+		// The default range is the 30 days to the latest ingested day
+		{"default range", http.MethodGet, "/", http.StatusOK, DefaultDays, 50},
+		{"date range", http.MethodGet, "/?start=2024-03-04&end=2024-03-05", http.StatusOK, 2, 20},
+		{"end only", http.MethodGet, "/?end=2024-03-04", http.StatusOK, DefaultDays, 40},
+		{"bad start", http.MethodGet, "/?start=March", http.StatusBadRequest, 0, 0},
+		{"bad end", http.MethodGet, "/?end=2024-3-5", http.StatusBadRequest, 0, 0},
+		{"backwards", http.MethodGet, "/?start=2024-03-05&end=2024-03-01", http.StatusBadRequest, 0, 0},
+		{"post", http.MethodPost, "/", http.StatusMethodNotAllowed, 0, 0},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, nil))
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, rec.Code, tt.want)
+			continue
+		}
+		if rec.Code != http.StatusOK {
+			continue
+		}
+		var series []Series
+		if err := json.NewDecoder(rec.Body).Decode(&series); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if len(series) != 1 || len(series[0].Points) != tt.days || series[0].Cost != tt.cost {
+			t.Errorf("%s: series = %+v, want %d days costing %v", tt.name, series, tt.days, tt.cost)
+		}
+	}
+
+	// A metric that cannot be read fails the request
+	missing, _ := New(config.UnitCostConfig{Metrics: []config.UnitMetricConfig{
+		{Name: "orders", CSV: filepath.Join(t.TempDir(), "missing.csv")},
+	}}, costCenter)
+	rec := httptest.NewRecorder()
+	Handler(history, missing).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("unreadable metric: status = %d, want %d", rec.Code, http.StatusBadGateway)
+	}
+}
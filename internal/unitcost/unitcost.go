@@ -0,0 +1,277 @@
+// Package unitcost divides daily cost by business metrics, such as requests,
+// orders or active users, into cost-per-unit trends
+package unitcost
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+// source returns a metric's value per day, keyed YYYY-MM-DD
+type source interface {
+	values(ctx context.Context, start, end time.Time) (map[string]float64, error)
+}
+
+// metric is a business metric and the cost it is divided into
+type metric struct {
+	config.UnitMetricConfig
+	source source
+}
+
+// Calculator computes cost per unit of the configured metrics
+type Calculator struct {
+	metrics    []metric
+	costCenter func(normalizer.CostRecord) string
+}
+
+// New builds a calculator for the configured metrics. costCenter resolves
+// the cost center a record is charged to, as chargeback does; it is needed
+// only by metrics scoped to a cost center.
+func New(cfg config.UnitCostConfig, costCenter func(normalizer.CostRecord) string) (*Calculator, error) {
+	c := &Calculator{costCenter: costCenter}
+	seen := make(map[string]bool)
+	for _, m := range cfg.Metrics {
+		if m.Name == "" {
+			return nil, fmt.Errorf("unit cost metric needs a name")
+		}
+		if seen[m.Name] {
+			return nil, fmt.Errorf("unit cost metric %q is defined twice", m.Name)
+		}
+		seen[m.Name] = true
+		if m.CostCenter != "" && m.Service != "" {
+			return nil, fmt.Errorf("unit cost metric %q: set cost_center or service, not both", m.Name)
+		}
+
+		var src source
+		switch {
+		case m.Query != "" && m.CSV != "":
+			return nil, fmt.Errorf("unit cost metric %q: set query or csv, not both", m.Name)
+		case m.Query != "":
+			if cfg.PrometheusURL == "" {
+				return nil, fmt.Errorf("unit cost metric %q: query needs unit_cost.prometheus_url", m.Name)
+			}
+			src = prometheus{url: strings.TrimSuffix(cfg.PrometheusURL, "/"), query: m.Query}
+		case m.CSV != "":
+			src = csvFile{path: m.CSV}
+		default:
+			return nil, fmt.Errorf("unit cost metric %q needs a query or csv", m.Name)
+		}
+		c.metrics = append(c.metrics, metric{UnitMetricConfig: m, source: src})
+	}
+	return c, nil
+}
+
+// Series is one metric's cost per unit over a window
+type Series struct {
+	Metric       string  `json:"metric"`
+	Unit         string  `json:"unit"`
+	Scope        string  `json:"scope"` // the cost divided: a cost center, a service or all spend
+	Cost         float64 `json:"cost"`
+	Units        float64 `json:"units"`
+	CostPerUnit  float64 `json:"cost_per_unit"` // over the window, 0 without units
+	TrendPercent float64 `json:"trend_percent"` // change from the window's first half to its second
+	Points       []Point `json:"points"`
+}
+
+// Point is one day's cost per unit, 0 on days without units
+type Point struct {
+	Date        time.Time `json:"date"`
+	Cost        float64   `json:"cost"`
+	Units       float64   `json:"units"`
+	CostPerUnit float64   `json:"cost_per_unit"`
+}
+
+// Compute divides each metric's daily cost in [start, end) by its daily
+// units. A metric that cannot be read fails the whole computation.
+func (c *Calculator) Compute(ctx context.Context, records []normalizer.CostRecord, start, end time.Time) ([]Series, error) {
+	all := make([]Series, 0, len(c.metrics))
+	for _, m := range c.metrics {
+		units, err := m.source.values(ctx, start, end)
+		if err != nil {
+			return nil, fmt.Errorf("unit cost metric %q: %w", m.Name, err)
+		}
+
+		costs := make(map[string]float64)
+		for _, r := range records {
+			if r.Date.Before(start) || !r.Date.Before(end) || !c.matches(m, r) {
+				continue
+			}
+			costs[r.Date.Format("2006-01-02")] += r.Cost
+		}
+
+		s := Series{Metric: m.Name, Unit: m.Unit, Scope: scope(m)}
+		var halves [2]struct{ cost, units float64 }
+		days := int(end.Sub(start).Hours() / 24)
+		for i, d := 0, start; d.Before(end); i, d = i+1, d.AddDate(0, 0, 1) {
+			key := d.Format("2006-01-02")
+			p := Point{Date: d, Cost: costs[key], Units: units[key]}
+			if p.Units > 0 {
+				p.CostPerUnit = p.Cost / p.Units
+			}
+			s.Points = append(s.Points, p)
+			s.Cost += p.Cost
+			s.Units += p.Units
+
+			half := 0
+			if i >= days/2 {
+				half = 1
+			}
+			halves[half].cost += p.Cost
+			halves[half].units += p.Units
+		}
+		if s.Units > 0 {
+			s.CostPerUnit = s.Cost / s.Units
+		}
+		if halves[0].units > 0 && halves[1].units > 0 && halves[0].cost > 0 {
+			first := halves[0].cost / halves[0].units
+			second := halves[1].cost / halves[1].units
+			s.TrendPercent = (second - first) / first * 100
+		}
+		all = append(all, s)
+	}
+	return all, nil
+}
+
+// matches reports whether a record's cost counts toward a metric. Credits
+// count, so the unit cost is net of them.
+func (c *Calculator) matches(m metric, r normalizer.CostRecord) bool {
+	switch {
+	case m.CostCenter != "":
+		return c.costCenter != nil && c.costCenter(r) == m.CostCenter
+	case m.Service != "":
+		return r.Service == m.Service
+	}
+	return true
+}
+
+// scope describes the cost a metric divides
+func scope(m metric) string {
+	switch {
+	case m.CostCenter != "":
+		return "cost center " + m.CostCenter
+	case m.Service != "":
+		return "service " + m.Service
+	}
+	return "all spend"
+}
+
+// prometheus evaluates a query at the end of each day through the HTTP API;
+// the query should give the day's total, e.g. sum(increase(orders_total[1d]))
+type prometheus struct {
+	url   string
+	query string
+}
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+func (p prometheus) values(ctx context.Context, start, end time.Time) (map[string]float64, error) {
+	params := url.Values{
+		"query": {p.query},
+		"start": {strconv.FormatInt(start.AddDate(0, 0, 1).Unix(), 10)},
+		"end":   {strconv.FormatInt(end.Unix(), 10)},
+		"step":  {"86400"},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url+"/api/v1/query_range?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query Prometheus: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Status string `json:"status"`
+		Error  string `json:"error"`
+		Data   struct {
+			Result []struct {
+				Values [][2]json.RawMessage `json:"values"` // [unix seconds, "value"]
+			} `json:"result"`
+		} `json:"data"`
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Prometheus response: %w", err)
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse Prometheus response (HTTP %d): %w", resp.StatusCode, err)
+	}
+	if result.Status != "success" {
+		return nil, fmt.Errorf("Prometheus query failed (HTTP %d): %s", resp.StatusCode, result.Error)
+	}
+
+	values := make(map[string]float64)
+	for _, series := range result.Data.Result {
+		for _, sample := range series.Values {
+			var ts float64
+			var raw string
+			if err := json.Unmarshal(sample[0], &ts); err != nil {
+				return nil, fmt.Errorf("invalid Prometheus timestamp %s", sample[0])
+			}
+			if err := json.Unmarshal(sample[1], &raw); err != nil {
+				return nil, fmt.Errorf("invalid Prometheus value %s", sample[1])
+			}
+			v, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid Prometheus value %q", raw)
+			}
+			// A sample at the end of a day is that day's total
+			day := time.Unix(int64(ts), 0).UTC().AddDate(0, 0, -1)
+			values[day.Format("2006-01-02")] += v
+		}
+	}
+	return values, nil
+}
+
+// csvFile reads date,value rows; a header row is skipped and repeated dates
+// add up
+type csvFile struct {
+	path string
+}
+
+func (f csvFile) values(ctx context.Context, start, end time.Time) (map[string]float64, error) {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metric file: %w", err)
+	}
+	defer file.Close()
+
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse metric file: %w", err)
+	}
+	values := make(map[string]float64)
+	for i, row := range rows {
+		if len(row) < 2 {
+			return nil, fmt.Errorf("metric file line %d: want date,value", i+1)
+		}
+		if i == 0 && strings.EqualFold(strings.TrimSpace(row[0]), "date") {
+			continue // header
+		}
+		day, err := time.Parse("2006-01-02", strings.TrimSpace(row[0]))
+		if err != nil {
+			return nil, fmt.Errorf("metric file line %d: invalid date %q", i+1, row[0])
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(row[1]), 64)
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("metric file line %d: invalid value %q", i+1, row[1])
+		}
+		if !day.Before(start) && day.Before(end) {
+			values[day.Format("2006-01-02")] += v
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,166 @@
+package unitcost
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/lvonguyen/finops-platform/internal/config"
+	"github.com/lvonguyen/finops-platform/internal/normalizer"
+)
+
+var march1 = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+// costCenter charges records to their cost_center tag
+func costCenter(r normalizer.CostRecord) string {
+	return r.Tags["cost_center"]
+}
+
+// writeMetric writes a metric file and returns its path
+func writeMetric(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "metric.csv")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// fourDays returns March 1 to 4 of 10 a day of EC2 for CC-1 and 5 of
+// BigQuery for CC-2, with a record on March 5 outside the window
+func fourDays() []normalizer.CostRecord {
+	var records []normalizer.CostRecord
+	for i := 0; i < 5; i++ {
+		d := march1.AddDate(0, 0, i)
+		records = append(records,
+			normalizer.CostRecord{Service: "EC2", Date: d, Cost: 10, Tags: map[string]string{"cost_center": "CC-1"}},
+			normalizer.CostRecord{Service: "BigQuery", Date: d, Cost: 5, Tags: map[string]string{"cost_center": "CC-2"}},
+		)
+	}
+	return records
+}
+
+// prometheusServer answers range queries with two series of the given
+// values at the end of each day from March 1
+func prometheusServer(t *testing.T, values ...float64) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/api/v1/query_range" || q.Get("query") != "sum(increase(queries_total[1d]))" || q.Get("step") != "86400" ||
+			q.Get("start") != strconv.FormatInt(march1.AddDate(0, 0, 1).Unix(), 10) || q.Get("end") != strconv.FormatInt(march1.AddDate(0, 0, 4).Unix(), 10) {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		var samples [][2]any
+		for i, v := range values {
+			samples = append(samples, [2]any{march1.AddDate(0, 0, i+1).Unix(), strconv.FormatFloat(v/2, 'f', -1, 64)})
+		}
+		json.NewEncoder(w).Encode(map[string]any{"status": "success", "data": map[string]any{
+			"resultType": "matrix",
+			"result":     []any{map[string]any{"values": samples}, map[string]any{"values": samples}},
+		}})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestNew(t *testing.T) {
+	csvPath := writeMetric(t, "")
+	for name, cfg := range map[string]config.UnitCostConfig{
+		"no name":      {Metrics: []config.UnitMetricConfig{{CSV: csvPath}}},
+		"twice":        {Metrics: []config.UnitMetricConfig{{Name: "orders", CSV: csvPath}, {Name: "orders", CSV: csvPath}}},
+		"both scopes":  {Metrics: []config.UnitMetricConfig{{Name: "orders", CSV: csvPath, CostCenter: "CC-1", Service: "EC2"}}},
+		"both sources": {Metrics: []config.UnitMetricConfig{{Name: "orders", CSV: csvPath, Query: "orders"}}, PrometheusURL: "http://prometheus:9090"},
+		"no source":    {Metrics: []config.UnitMetricConfig{{Name: "orders"}}},
+		"no URL":       {Metrics: []config.UnitMetricConfig{{Name: "orders", Query: "orders"}}},
+	} {
+		if _, err := New(cfg, costCenter); err == nil {
+			t.Errorf("%s: New() succeeded, want an error", name)
+		}
+	}
+}
+
+func TestCompute(t *testing.T) {
+	srv := prometheusServer(t, 100, 100, 50, 50)
+	calc, err := New(config.UnitCostConfig{PrometheusURL: srv.URL + "/", Metrics: []config.UnitMetricConfig{
+		{Name: "orders", Unit: "order", CostCenter: "CC-1", CSV: writeMetric(t, "date,orders\n2024-03-01,10\n2024-03-02,10\n2024-03-03,5\n2024-03-03,0\n2024-03-04,5\n2024-03-05,99\n")},
+		{Name: "queries", Unit: "query", Service: "BigQuery", Query: "sum(increase(queries_total[1d]))"},
+		{Name: "users", CSV: writeMetric(t, "2024-03-01,3\n2024-03-04,12\n")},
+	}}, costCenter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	series, err := calc.Compute(context.Background(), fourDays(), march1, march1.AddDate(0, 0, 4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series) != 3 {
+		t.Fatalf("got %d series, want 3", len(series))
+	}
+
+	// Orders halve in the second half while the cost holds, doubling the
+	// cost per order
+	orders := series[0]
+	wantPoints := []Point{
+		{Date: march1, Cost: 10, Units: 10, CostPerUnit: 1},
+		{Date: march1.AddDate(0, 0, 1), Cost: 10, Units: 10, CostPerUnit: 1},
+		{Date: march1.AddDate(0, 0, 2), Cost: 10, Units: 5, CostPerUnit: 2},
+		{Date: march1.AddDate(0, 0, 3), Cost: 10, Units: 5, CostPerUnit: 2},
+	}
+	if orders.Scope != "cost center CC-1" || orders.Cost != 40 || orders.Units != 30 || orders.CostPerUnit != 40.0/30 ||
+		orders.TrendPercent != 100 || !reflect.DeepEqual(orders.Points, wantPoints) {
+		t.Errorf("orders = %+v, want 40 over 30 orders trending +100%%", orders)
+	}
+
+	// Both Prometheus series add up: 5 of BigQuery over 100, 100, 50 and 50
+	queries := series[1]
+	var perUnit []float64
+	for _, p := range queries.Points {
+		perUnit = append(perUnit, p.CostPerUnit)
+	}
+	if queries.Scope != "service BigQuery" || queries.Cost != 20 || queries.Units != 300 ||
+		!reflect.DeepEqual(perUnit, []float64{0.05, 0.05, 0.1, 0.1}) || queries.TrendPercent != 100 {
+		t.Errorf("queries = %+v, want 20 over 300 queries trending +100%%", queries)
+	}
+
+	// Days without users have no cost per unit
+	users := series[2]
+	if users.Scope != "all spend" || users.Cost != 60 || users.Units != 15 || users.CostPerUnit != 4 ||
+		users.Points[1].CostPerUnit != 0 || users.Points[3].CostPerUnit != 1.25 || users.TrendPercent != -75 {
+		t.Errorf("users = %+v, want 60 over 15 users trending -75%%", users)
+	}
+}
+
+func TestComputeErrors(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"status": "error", "error": "parse error"}`))
+	}))
+	defer failing.Close()
+
+	tests := []struct {
+		name   string
+		metric config.UnitMetricConfig
+	}{
+		{"Prometheus error", config.UnitMetricConfig{Name: "m", Query: "orders"}},
+		{"missing file", config.UnitMetricConfig{Name: "m", CSV: filepath.Join(t.TempDir(), "missing.csv")}},
+		{"one column", config.UnitMetricConfig{Name: "m", CSV: writeMetric(t, "2024-03-01\n")}},
+		{"bad date", config.UnitMetricConfig{Name: "m", CSV: writeMetric(t, "March 1,10\n")}},
+		{"negative value", config.UnitMetricConfig{Name: "m", CSV: writeMetric(t, "2024-03-01,-1\n")}},
+	}
+	for _, tt := range tests {
+		calc, err := New(config.UnitCostConfig{PrometheusURL: failing.URL, Metrics: []config.UnitMetricConfig{tt.metric}}, costCenter)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if _, err := calc.Compute(context.Background(), fourDays(), march1, march1.AddDate(0, 0, 4)); err == nil {
+			t.Errorf("%s: Compute() succeeded, want an error", tt.name)
+		}
+	}
+}